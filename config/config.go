@@ -5,7 +5,9 @@ import (
 	"crypto/rand"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"reflect"
 	"slices"
 	"time"
 
@@ -29,8 +31,13 @@ import (
 	blankhost "github.com/libp2p/go-libp2p/p2p/host/blank"
 	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
 	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
+	"github.com/libp2p/go-libp2p/p2p/host/probecoord"
 	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
 	routed "github.com/libp2p/go-libp2p/p2p/host/routed"
+	"github.com/libp2p/go-libp2p/p2p/host/timeline"
+	"github.com/libp2p/go-libp2p/p2p/muxer/yamux"
+	ppnet "github.com/libp2p/go-libp2p/p2p/net/pnet"
+	"github.com/libp2p/go-libp2p/p2p/net/reuseport"
 	"github.com/libp2p/go-libp2p/p2p/net/swarm"
 	tptu "github.com/libp2p/go-libp2p/p2p/net/upgrader"
 	"github.com/libp2p/go-libp2p/p2p/protocol/autonatv2"
@@ -38,6 +45,7 @@ import (
 	relayv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
 	"github.com/libp2p/go-libp2p/p2p/protocol/holepunch"
 	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
+	"github.com/libp2p/go-libp2p/p2p/transport/certhash"
 	"github.com/libp2p/go-libp2p/p2p/transport/quicreuse"
 	"github.com/libp2p/go-libp2p/p2p/transport/tcpreuse"
 	libp2pwebrtc "github.com/libp2p/go-libp2p/p2p/transport/webrtc"
@@ -66,11 +74,14 @@ type AutoNATConfig struct {
 	ThrottleGlobalLimit int
 	ThrottlePeerLimit   int
 	ThrottleInterval    time.Duration
+	Scheduler           autonat.Scheduler
 }
 
 type Security struct {
 	ID          protocol.ID
 	Constructor interface{}
+	// Opts are passed to the variadic parameter of Constructor.
+	Opts []interface{}
 }
 
 // Config describes a set of settings for a libp2p node
@@ -97,6 +108,13 @@ type Config struct {
 	SecurityTransports []Security
 	Insecure           bool
 	PSK                pnet.PSK
+	// PSKProvider, if set, protects connections instead of PSK.
+	PSKProvider pnet.KeyProvider
+	// PSKExemption, if set, exempts connections from PSK protection.
+	PSKExemption *tptu.PSKExemption
+	// PSKModes, if set, are the authenticated private network modes to
+	// negotiate instead of using the legacy protector.
+	PSKModes []ppnet.Mode
 
 	DialTimeout time.Duration
 
@@ -106,9 +124,15 @@ type Config struct {
 	EnableRelayService bool // should we run a circuitv2 relay (if publicly reachable)
 	RelayServiceOpts   []relayv2.Option
 
-	ListenAddrs     []ma.Multiaddr
-	AddrsFactory    bhost.AddrsFactory
-	ConnectionGater connmgr.ConnectionGater
+	ListenAddrs []ma.Multiaddr
+	// OptionalListenAddrs are listen addresses that are skipped if no
+	// transport can listen on them, instead of failing to start the host.
+	// libp2p.DefaultListenAddrs adds its addresses to both ListenAddrs and
+	// OptionalListenAddrs.
+	OptionalListenAddrs []ma.Multiaddr
+	AddrsFactory        bhost.AddrsFactory
+	ConnectionGater     connmgr.ConnectionGater
+	ConnAdmission       tptu.AdmissionFunc
 
 	ConnManager     connmgr.ConnManager
 	ResourceManager network.ResourceManager
@@ -130,6 +154,14 @@ type Config struct {
 	EnableHolePunching  bool
 	HolePunchingOptions []holepunch.Option
 
+	// ClientOnly is set by the ClientOnly option. See its documentation.
+	ClientOnly bool
+
+	ProbeCoordinator *probecoord.Coordinator
+
+	EnablePeerTimeline  bool
+	PeerTimelineOptions []timeline.Option
+
 	DisableMetrics       bool
 	PrometheusRegisterer prometheus.Registerer
 
@@ -139,8 +171,20 @@ type Config struct {
 
 	DisableIdentifyAddressDiscovery bool
 
+	IdentifyRefreshInterval time.Duration
+	IdentifyRefreshJitter   time.Duration
+
+	IdentifyPushDebounce    time.Duration
+	IdentifyPushMinInterval time.Duration
+
+	IdentifyAddrCrossCheck identify.AddrCrossCheck
+
+	ProtocolNegotiationCacheTTL time.Duration
+
 	EnableAutoNATv2 bool
 
+	VerifyRelayAddrs bool
+
 	UDPBlackHoleSuccessCounter        *swarm.BlackHoleSuccessCounter
 	CustomUDPBlackHoleSuccessCounter  bool
 	IPv6BlackHoleSuccessCounter       *swarm.BlackHoleSuccessCounter
@@ -148,16 +192,45 @@ type Config struct {
 
 	UserFxOptions []fx.Option
 
-	ShareTCPListener bool
+	ShareTCPListener     bool
+	ShareTCPListenerOpts []tcpreuse.Option
+
+	// ShareCertHashes makes WebTransport and WebRTC Direct present the same
+	// certificates and rotate them together.
+	ShareCertHashes bool
+	// CertHashStore persists the key the shared certificates are derived
+	// from, if the host key isn't exportable.
+	CertHashStore certhash.Store
+
+	// QUICConnectionMigration enables migrating QUIC connections to a new
+	// path. QUICMigrationFilter, if set, decides whether to accept the
+	// migrations of peers.
+	QUICConnectionMigration bool
+	QUICMigrationFilter     quicreuse.MigrationFilter
+}
+
+// clientOnlyListenAddrs are the addresses client-only hosts listen on. Outbound
+// QUIC connections are dialed from the sockets of these listeners, which allows
+// the host to learn its public addresses from the peers it connects to, and to
+// hole punch from them. The addresses are never advertised.
+var clientOnlyListenAddrs = []ma.Multiaddr{
+	ma.StringCast("/ip4/0.0.0.0/udp/0/quic-v1"),
+	ma.StringCast("/ip6/::/udp/0/quic-v1"),
 }
 
-func (cfg *Config) makeSwarm(eventBus event.Bus, enableMetrics bool) (*swarm.Swarm, error) {
+// certManagerUser is implemented by transports that are dialed by the hash of
+// their certificate, so that they can share a single certhash.Manager.
+type certManagerUser interface {
+	SetCertManager(*certhash.Manager) error
+}
+
+func (cfg *Config) makeSwarm(eventBus event.Bus, enableMetrics bool, tl *timeline.Timeline) (*swarm.Swarm, error) {
 	if cfg.Peerstore == nil {
 		return nil, fmt.Errorf("no peerstore specified")
 	}
 
 	// Check this early. Prevents us from even *starting* without verifying this.
-	if pnet.ForcePrivateNetwork && len(cfg.PSK) == 0 {
+	if pnet.ForcePrivateNetwork && len(cfg.PSK) == 0 && cfg.PSKProvider == nil {
 		log.Error("tried to create a libp2p node with no Private" +
 			" Network Protector but usage of Private Networks" +
 			" is forced by the environment")
@@ -176,6 +249,13 @@ func (cfg *Config) makeSwarm(eventBus event.Bus, enableMetrics bool) (*swarm.Swa
 		return nil, err
 	}
 
+	// Peerstores that support it emit their mutations on the host's event bus.
+	if eps, ok := cfg.Peerstore.(interface{ SetEventBus(event.Bus) error }); ok {
+		if err := eps.SetEventBus(eventBus); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := cfg.Peerstore.AddPrivKey(pid, cfg.PeerKey); err != nil {
 		return nil, err
 	}
@@ -206,6 +286,10 @@ func (cfg *Config) makeSwarm(eventBus event.Bus, enableMetrics bool) (*swarm.Swa
 		opts = append(opts, swarm.WithDialRanker(cfg.DialRanker))
 	}
 
+	if tl != nil {
+		opts = append(opts, swarm.WithDialObserver(tl))
+	}
+
 	if enableMetrics {
 		opts = append(opts,
 			swarm.WithMetricsTracer(swarm.NewMetricsTracer(swarm.WithRegisterer(cfg.PrometheusRegisterer))))
@@ -230,6 +314,9 @@ func (cfg *Config) makeAutoNATV2Host() (host.Host, error) {
 		SecurityTransports:          cfg.SecurityTransports,
 		Insecure:                    cfg.Insecure,
 		PSK:                         cfg.PSK,
+		PSKProvider:                 cfg.PSKProvider,
+		PSKExemption:                cfg.PSKExemption,
+		PSKModes:                    cfg.PSKModes,
 		ConnectionGater:             cfg.ConnectionGater,
 		Reporter:                    cfg.Reporter,
 		PeerKey:                     autonatPrivKey,
@@ -238,6 +325,9 @@ func (cfg *Config) makeAutoNATV2Host() (host.Host, error) {
 		UDPBlackHoleSuccessCounter:  cfg.UDPBlackHoleSuccessCounter,
 		IPv6BlackHoleSuccessCounter: cfg.IPv6BlackHoleSuccessCounter,
 		ResourceManager:             cfg.ResourceManager,
+		// Allows dialing back relay addresses, verifying that the peer's
+		// relay reservation works.
+		Relay: cfg.Relay,
 		SwarmOpts: []swarm.Option{
 			// Don't update black hole state for failed autonat dials
 			swarm.WithReadOnlyBlackHoleDetector(),
@@ -255,7 +345,7 @@ func (cfg *Config) makeAutoNATV2Host() (host.Host, error) {
 				OnStop: func(context.Context) error {
 					return ps.Close()
 				}})
-			sw, err := autoNatCfg.makeSwarm(b, false)
+			sw, err := autoNatCfg.makeSwarm(b, false, nil)
 			return sw, err
 		}),
 		fx.Provide(func(sw *swarm.Swarm) *blankhost.BlankHost {
@@ -288,7 +378,32 @@ func (cfg *Config) makeAutoNATV2Host() (host.Host, error) {
 func (cfg *Config) addTransports() ([]fx.Option, error) {
 	fxopts := []fx.Option{
 		fx.WithLogger(func() fxevent.Logger { return getFXLogger() }),
-		fx.Provide(fx.Annotate(tptu.New, fx.ParamTags(`name:"security"`))),
+		fx.Provide(fx.Annotate(
+			func(security []sec.SecureTransport, muxers []tptu.StreamMuxer, psk pnet.PSK, rcmgr network.ResourceManager, connGater connmgr.ConnectionGater, b event.Bus, lifecycle fx.Lifecycle) (transport.Upgrader, error) {
+				opts := []tptu.Option{tptu.WithEventBus(b)}
+				if cfg.ConnAdmission != nil {
+					opts = append(opts, tptu.WithConnAdmission(cfg.ConnAdmission))
+				}
+				if cfg.PSKExemption != nil {
+					opts = append(opts, tptu.WithPSKExemption(*cfg.PSKExemption))
+				}
+				if cfg.PSKProvider != nil {
+					opts = append(opts, tptu.WithPSKProvider(cfg.PSKProvider))
+				}
+				if len(cfg.PSKModes) > 0 {
+					opts = append(opts, tptu.WithPSKModes(cfg.PSKModes...))
+				}
+				u, err := tptu.New(security, muxers, psk, rcmgr, connGater, opts...)
+				if err != nil {
+					return nil, err
+				}
+				if c, ok := u.(io.Closer); ok {
+					lifecycle.Append(fx.StopHook(c.Close))
+				}
+				return u, nil
+			},
+			fx.ParamTags(`name:"security"`),
+		)),
 		fx.Supply(cfg.Muxers),
 		fx.Provide(func() connmgr.ConnectionGater { return cfg.ConnectionGater }),
 		fx.Provide(func() pnet.PSK { return cfg.PSK }),
@@ -297,7 +412,7 @@ func (cfg *Config) addTransports() ([]fx.Option, error) {
 			if !cfg.ShareTCPListener {
 				return nil
 			}
-			return tcpreuse.NewConnMgr(tcpreuse.EnvReuseportVal, upgrader)
+			return tcpreuse.NewConnMgr(tcpreuse.EnvReuseportVal, upgrader, cfg.ShareTCPListenerOpts...)
 		}),
 		fx.Provide(func(cm *quicreuse.ConnManager, sw *swarm.Swarm) libp2pwebrtc.ListenUDPFn {
 			hasQuicAddrPortFor := func(network string, laddr *net.UDPAddr) bool {
@@ -342,10 +457,21 @@ func (cfg *Config) addTransports() ([]fx.Option, error) {
 		for _, s := range cfg.SecurityTransports {
 			fxName := fmt.Sprintf(`name:"security_%s"`, s.ID)
 			fxopts = append(fxopts, fx.Supply(fx.Annotate(s.ID, fx.ResultTags(fxName))))
+			params := []string{fxName}
+			if len(s.Opts) > 0 {
+				// the options are the variadic, and therefore last, parameter
+				optsTag := fmt.Sprintf(`group:"securityopt_%s"`, s.ID)
+				params = make([]string, reflect.TypeOf(s.Constructor).NumIn())
+				params[0] = fxName
+				params[len(params)-1] = optsTag
+				for _, opt := range s.Opts {
+					fxopts = append(fxopts, fx.Supply(fx.Annotate(opt, fx.ResultTags(optsTag))))
+				}
+			}
 			fxopts = append(fxopts,
 				fx.Provide(fx.Annotate(
 					s.Constructor,
-					fx.ParamTags(fxName),
+					fx.ParamTags(params...),
 					fx.As(new(sec.SecureTransport)),
 					fx.ResultTags(`group:"security_unordered"`),
 				)),
@@ -405,6 +531,12 @@ func (cfg *Config) addTransports() ([]fx.Option, error) {
 				if !cfg.DisableMetrics {
 					opts = append(opts, quicreuse.EnableMetrics(cfg.PrometheusRegisterer))
 				}
+				if cfg.QUICConnectionMigration {
+					opts = append(opts, quicreuse.EnableConnectionMigration())
+				}
+				if cfg.QUICMigrationFilter != nil {
+					opts = append(opts, quicreuse.WithMigrationFilter(cfg.QUICMigrationFilter))
+				}
 				cm, err := quicreuse.NewConnManager(key, tokenGenerator, opts...)
 				if err != nil {
 					return nil, err
@@ -417,7 +549,15 @@ func (cfg *Config) addTransports() ([]fx.Option, error) {
 
 	fxopts = append(fxopts, fx.Invoke(
 		fx.Annotate(
-			func(swrm *swarm.Swarm, tpts []transport.Transport) error {
+			func(swrm *swarm.Swarm, tpts []transport.Transport, lifecycle fx.Lifecycle) error {
+				if err := cfg.shareCertManager(tpts, lifecycle); err != nil {
+					return err
+				}
+				if cfg.PSKProvider != nil {
+					if err := checkPrivateTransports(tpts); err != nil {
+						return err
+					}
+				}
 				for _, t := range tpts {
 					if err := swrm.AddTransport(t); err != nil {
 						return err
@@ -434,7 +574,68 @@ func (cfg *Config) addTransports() ([]fx.Option, error) {
 	return fxopts, nil
 }
 
-func (cfg *Config) newBasicHost(swrm *swarm.Swarm, eventBus event.Bus, an *autonatv2.AutoNAT) (*bhost.BasicHost, error) {
+// checkPrivateTransports returns an error if one of the transports secures
+// connections itself, instead of using the upgrader, and therefore can't be
+// protected by a private network. These transports refuse to be constructed
+// with a PSK, but they don't see a PSK key provider.
+func checkPrivateTransports(tpts []transport.Transport) error {
+	for _, t := range tpts {
+		for _, p := range t.Protocols() {
+			switch p {
+			case ma.P_QUIC, ma.P_QUIC_V1, ma.P_WEBTRANSPORT, ma.P_WEBRTC, ma.P_WEBRTC_DIRECT:
+				return fmt.Errorf("%s transport doesn't support private networks", ma.ProtocolWithCode(p).Name)
+			}
+		}
+	}
+	return nil
+}
+
+// shareCertManager makes the transports dialed by certificate hash, i.e.
+// WebTransport and WebRTC Direct, use the same certificates, so that they
+// advertise the same hashes and rotate them at the same time.
+func (cfg *Config) shareCertManager(tpts []transport.Transport, lifecycle fx.Lifecycle) error {
+	if !cfg.ShareCertHashes {
+		return nil
+	}
+	var users []certManagerUser
+	for _, t := range tpts {
+		if u, ok := t.(certManagerUser); ok {
+			users = append(users, u)
+		}
+	}
+	if len(users) == 0 {
+		return nil
+	}
+	m, err := certhash.NewManager(cfg.PeerKey, certhash.WithStore(cfg.CertHashStore))
+	if err != nil {
+		return err
+	}
+	lifecycle.Append(fx.StopHook(m.Close))
+	for _, u := range users {
+		if err := u.SetCertManager(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *Config) newBasicHost(swrm *swarm.Swarm, eventBus event.Bus, an *autonatv2.AutoNAT, tl *timeline.Timeline) (*bhost.BasicHost, error) {
+	var secIDs []protocol.ID
+	if cfg.Insecure {
+		secIDs = []protocol.ID{insecure.ID}
+	} else {
+		for _, s := range cfg.SecurityTransports {
+			secIDs = append(secIDs, s.ID)
+		}
+	}
+	muxerIDs := make([]protocol.ID, 0, len(cfg.Muxers))
+	for _, m := range cfg.Muxers {
+		muxerIDs = append(muxerIDs, m.ID)
+	}
+	holePunchingOpts := cfg.HolePunchingOptions
+	if cfg.ProbeCoordinator != nil {
+		holePunchingOpts = append([]holepunch.Option{holepunch.WithProbeCoordinator(cfg.ProbeCoordinator)}, holePunchingOpts...)
+	}
 	h, err := bhost.NewHost(swrm, &bhost.HostOpts{
 		EventBus:                        eventBus,
 		ConnManager:                     cfg.ConnManager,
@@ -444,13 +645,23 @@ func (cfg *Config) newBasicHost(swrm *swarm.Swarm, eventBus event.Bus, an *auton
 		UserAgent:                       cfg.UserAgent,
 		ProtocolVersion:                 cfg.ProtocolVersion,
 		EnableHolePunching:              cfg.EnableHolePunching,
-		HolePunchingOptions:             cfg.HolePunchingOptions,
+		HolePunchingOptions:             holePunchingOpts,
 		EnableRelayService:              cfg.EnableRelayService,
 		RelayServiceOpts:                cfg.RelayServiceOpts,
 		EnableMetrics:                   !cfg.DisableMetrics,
 		PrometheusRegisterer:            cfg.PrometheusRegisterer,
 		DisableIdentifyAddressDiscovery: cfg.DisableIdentifyAddressDiscovery,
+		IdentifyRefreshInterval:         cfg.IdentifyRefreshInterval,
+		IdentifyRefreshJitter:           cfg.IdentifyRefreshJitter,
+		IdentifyPushDebounce:            cfg.IdentifyPushDebounce,
+		IdentifyPushMinInterval:         cfg.IdentifyPushMinInterval,
+		IdentifyAddrCrossCheck:          cfg.IdentifyAddrCrossCheck,
 		AutoNATv2:                       an,
+		VerifyRelayAddrs:                cfg.VerifyRelayAddrs,
+		SecurityProtocols:               secIDs,
+		Muxers:                          muxerIDs,
+		PeerTimeline:                    tl,
+		ProtocolNegotiationCacheTTL:     cfg.ProtocolNegotiationCacheTTL,
 	})
 	if err != nil {
 		return nil, err
@@ -462,6 +673,9 @@ func (cfg *Config) validate() error {
 	if cfg.EnableAutoRelay && !cfg.Relay {
 		return fmt.Errorf("cannot enable autorelay; relay is not enabled")
 	}
+	if cfg.VerifyRelayAddrs && !cfg.EnableAutoNATv2 {
+		return fmt.Errorf("cannot verify relay addresses; autonatv2 is not enabled")
+	}
 	// If possible check that the resource manager conn limit is higher than the
 	// limit set in the conn manager.
 	if l, ok := cfg.ResourceManager.(connmgr.GetConnLimiter); ok {
@@ -471,10 +685,29 @@ func (cfg *Config) validate() error {
 		}
 	}
 
-	if len(cfg.PSK) > 0 && cfg.ShareTCPListener {
+	if (len(cfg.PSK) > 0 || cfg.PSKProvider != nil) && cfg.ShareTCPListener {
 		return errors.New("cannot use shared TCP listener with PSK")
 	}
 
+	if cfg.CertHashStore != nil && !cfg.ShareCertHashes {
+		return errors.New("cannot use a cert hash store without sharing cert hashes")
+	}
+
+	if cfg.ClientOnly {
+		switch {
+		case len(cfg.ListenAddrs) > 0:
+			return errors.New("cannot listen on addresses with a client-only host")
+		case cfg.EnableRelayService:
+			return errors.New("cannot run a relay service with a client-only host")
+		case cfg.EnableAutoRelay:
+			return errors.New("cannot enable autorelay with a client-only host")
+		case cfg.AutoNATConfig.EnableService:
+			return errors.New("cannot run an autonat service with a client-only host")
+		case cfg.NATManager != nil:
+			return errors.New("cannot use a NAT manager with a client-only host")
+		}
+	}
+
 	return nil
 }
 
@@ -500,6 +733,8 @@ func (cfg *Config) NewNode() (host.Host, error) {
 
 	if !cfg.DisableMetrics {
 		rcmgr.MustRegisterWith(cfg.PrometheusRegisterer)
+		yamux.MustRegisterWith(cfg.PrometheusRegisterer)
+		reuseport.RegisterAcceptMetrics(cfg.PrometheusRegisterer)
 	}
 
 	fxopts := []fx.Option{
@@ -512,16 +747,28 @@ func (cfg *Config) NewNode() (host.Host, error) {
 		// Make sure the swarm constructor depends on the quicreuse.ConnManager.
 		// That way, the ConnManager will be started before the swarm, and more importantly,
 		// the swarm will be stopped before the ConnManager.
-		fx.Provide(func(eventBus event.Bus, _ *quicreuse.ConnManager, lifecycle fx.Lifecycle) (*swarm.Swarm, error) {
-			sw, err := cfg.makeSwarm(eventBus, !cfg.DisableMetrics)
+		fx.Provide(func() (*timeline.Timeline, error) {
+			if !cfg.EnablePeerTimeline {
+				return nil, nil
+			}
+			return timeline.New(cfg.PeerTimelineOptions...)
+		}),
+		fx.Provide(func(eventBus event.Bus, _ *quicreuse.ConnManager, tl *timeline.Timeline, lifecycle fx.Lifecycle) (*swarm.Swarm, error) {
+			sw, err := cfg.makeSwarm(eventBus, !cfg.DisableMetrics, tl)
 			if err != nil {
 				return nil, err
 			}
 			lifecycle.Append(fx.Hook{
 				OnStart: func(context.Context) error {
-					// TODO: This method succeeds if listening on one address succeeds. We
-					// should probably fail if listening on *any* addr fails.
-					return sw.Listen(cfg.ListenAddrs...)
+					listenAddrs := slices.DeleteFunc(slices.Clone(cfg.ListenAddrs), func(a ma.Multiaddr) bool {
+						return sw.TransportForListening(a) == nil && slices.ContainsFunc(cfg.OptionalListenAddrs, a.Equal)
+					})
+					if cfg.ClientOnly {
+						listenAddrs = slices.DeleteFunc(slices.Clone(clientOnlyListenAddrs), func(a ma.Multiaddr) bool {
+							return sw.TransportForListening(a) == nil
+						})
+					}
+					return sw.Listen(listenAddrs...)
 				},
 				OnStop: func(context.Context) error {
 					return sw.Close()
@@ -582,6 +829,9 @@ func (cfg *Config) NewNode() (host.Host, error) {
 					mtOpts := []autorelay.Option{mt}
 					cfg.AutoRelayOpts = append(mtOpts, cfg.AutoRelayOpts...)
 				}
+				if cfg.ProbeCoordinator != nil {
+					cfg.AutoRelayOpts = append([]autorelay.Option{autorelay.WithProbeCoordinator(cfg.ProbeCoordinator)}, cfg.AutoRelayOpts...)
+				}
 
 				ar, err := autorelay.NewAutoRelay(h, cfg.AutoRelayOpts...)
 				if err != nil {
@@ -609,6 +859,12 @@ func (cfg *Config) NewNode() (host.Host, error) {
 
 	app := fx.New(fxopts...)
 	if err := app.Start(context.Background()); err != nil {
+		// fx only stops the components that started successfully. Close the
+		// host to release everything else, e.g. if listening failed.
+		if bh != nil {
+			bh.Close()
+		}
+		app.Stop(context.Background())
 		return nil, err
 	}
 
@@ -653,6 +909,12 @@ func (cfg *Config) addAutoNAT(h *bhost.BasicHost) error {
 			autonat.WithThrottling(cfg.AutoNATConfig.ThrottleGlobalLimit, cfg.AutoNATConfig.ThrottleInterval),
 			autonat.WithPeerThrottling(cfg.AutoNATConfig.ThrottlePeerLimit))
 	}
+	if cfg.AutoNATConfig.Scheduler != nil {
+		autonatOpts = append(autonatOpts, autonat.WithScheduler(cfg.AutoNATConfig.Scheduler))
+	}
+	if cfg.ProbeCoordinator != nil {
+		autonatOpts = append(autonatOpts, autonat.WithProbeCoordinator(cfg.ProbeCoordinator))
+	}
 	if cfg.AutoNATConfig.EnableService {
 		autonatPrivKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
 		if err != nil {
@@ -671,6 +933,9 @@ func (cfg *Config) addAutoNAT(h *bhost.BasicHost) error {
 			SecurityTransports: cfg.SecurityTransports,
 			Insecure:           cfg.Insecure,
 			PSK:                cfg.PSK,
+			PSKProvider:        cfg.PSKProvider,
+			PSKExemption:       cfg.PSKExemption,
+			PSKModes:           cfg.PSKModes,
 			ConnectionGater:    cfg.ConnectionGater,
 			Reporter:           cfg.Reporter,
 			PeerKey:            autonatPrivKey,
@@ -697,7 +962,7 @@ func (cfg *Config) addAutoNAT(h *bhost.BasicHost) error {
 						return ps.Close()
 					}})
 				var err error
-				dialer, err = autoNatCfg.makeSwarm(b, false)
+				dialer, err = autoNatCfg.makeSwarm(b, false, nil)
 				return dialer, err
 
 			}),
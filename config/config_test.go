@@ -2,6 +2,10 @@ package config
 
 import (
 	"testing"
+
+	"github.com/libp2p/go-libp2p/core/transport"
+
+	ma "github.com/multiformats/go-multiaddr"
 )
 
 func TestNilOption(t *testing.T) {
@@ -21,3 +25,22 @@ func TestNilOption(t *testing.T) {
 		t.Fatalf("expected to have handled 3 options, handled %d", optsRun)
 	}
 }
+
+type protocolsTransport struct {
+	transport.Transport
+	protocols []int
+}
+
+func (t protocolsTransport) Protocols() []int { return t.protocols }
+
+func TestCheckPrivateTransports(t *testing.T) {
+	tcp := protocolsTransport{protocols: []int{ma.P_TCP}}
+	ws := protocolsTransport{protocols: []int{ma.P_WS, ma.P_WSS}}
+	quic := protocolsTransport{protocols: []int{ma.P_QUIC_V1}}
+	if err := checkPrivateTransports([]transport.Transport{tcp, ws}); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkPrivateTransports([]transport.Transport{tcp, quic}); err == nil {
+		t.Fatal("expected QUIC to be rejected")
+	}
+}
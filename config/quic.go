@@ -1,7 +1,9 @@
 package config
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
+	"errors"
 	"io"
 
 	"golang.org/x/crypto/hkdf"
@@ -16,9 +18,12 @@ const (
 	tokenGeneratorKeyInfo = "libp2p quic token generator key"
 )
 
+// PrivKeyToStatelessResetKey derives the QUIC stateless reset key from the
+// host key. If the host key is not exportable, e.g. because it is held by an
+// HSM, a random key is used.
 func PrivKeyToStatelessResetKey(key crypto.PrivKey) (quic.StatelessResetKey, error) {
 	var statelessResetKey quic.StatelessResetKey
-	keyBytes, err := key.Raw()
+	keyBytes, err := keyMaterial(key)
 	if err != nil {
 		return statelessResetKey, err
 	}
@@ -29,9 +34,11 @@ func PrivKeyToStatelessResetKey(key crypto.PrivKey) (quic.StatelessResetKey, err
 	return statelessResetKey, nil
 }
 
+// PrivKeyToTokenGeneratorKey derives the QUIC token generator key from the
+// host key. If the host key is not exportable, a random key is used.
 func PrivKeyToTokenGeneratorKey(key crypto.PrivKey) (quic.TokenGeneratorKey, error) {
 	var tokenKey quic.TokenGeneratorKey
-	keyBytes, err := key.Raw()
+	keyBytes, err := keyMaterial(key)
 	if err != nil {
 		return tokenKey, err
 	}
@@ -41,3 +48,14 @@ func PrivKeyToTokenGeneratorKey(key crypto.PrivKey) (quic.TokenGeneratorKey, err
 	}
 	return tokenKey, nil
 }
+
+// keyMaterial returns the raw bytes of key, or random bytes if the key is not
+// exportable. In that case, keys derived from it don't survive restarts.
+func keyMaterial(key crypto.PrivKey) ([]byte, error) {
+	keyBytes, err := key.Raw()
+	if errors.Is(err, crypto.ErrKeyNotExportable) {
+		keyBytes = make([]byte, 32)
+		_, err = rand.Read(keyBytes)
+	}
+	return keyBytes, err
+}
@@ -1,11 +1,16 @@
 package connmgr
 
 import (
+	"context"
+	"crypto/x509"
+	"fmt"
+
 	ma "github.com/multiformats/go-multiaddr"
 
 	"github.com/libp2p/go-libp2p/core/control"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
 )
 
 // ConnectionGater can be implemented by a type that supports active
@@ -32,6 +37,9 @@ import (
 //
 //	InterceptSecured is called for both inbound and outbound connections,
 //	after a security handshake has taken place and we've authenticated the peer.
+//	Gaters that need to know the transport and the security protocol of the
+//	connection can implement SecuredConnGater instead, and gaters that need to
+//	do I/O, e.g. to query an external policy engine, ContextSecuredConnGater.
 //
 //	InterceptUpgraded is called for inbound and outbound connections, after
 //	libp2p has finished upgrading the connection entirely to a secure,
@@ -87,3 +95,87 @@ type ConnectionGater interface {
 	// NOTE: the go-libp2p implementation currently IGNORES the disconnect reason.
 	InterceptUpgraded(network.Conn) (allow bool, reason control.DisconnectReason)
 }
+
+// SecuredConnInfo describes a connection that passed the security handshake.
+type SecuredConnInfo struct {
+	network.ConnMultiaddrs
+	// Transport is the transport of the connection. For example: tcp,
+	// websocket, quic-v1, webtransport, webrtc-direct or p2p-circuit.
+	Transport string
+	// Security is the negotiated security protocol. It is empty for
+	// transports with built-in security, like QUIC.
+	Security protocol.ID
+	// Relayed is true if the connection is relayed over a circuit.
+	Relayed bool
+	// TLSClientCertificates are the certificates the remote peer presented at
+	// the transport layer, see network.ConnectionState.
+	TLSClientCertificates []*x509.Certificate
+}
+
+// SecuredConnGater is an optional interface for ConnectionGaters. If a gater
+// implements it, InterceptSecuredConn is called in place of InterceptSecured,
+// which allows for policies like only allowing a peer over direct QUIC
+// connections.
+type SecuredConnGater interface {
+	InterceptSecuredConn(network.Direction, peer.ID, SecuredConnInfo) (allow bool)
+}
+
+// ContextSecuredConnGater is an optional interface for ConnectionGaters whose
+// check of secured connections needs to do I/O, e.g. to query an external
+// policy engine. If a gater implements it, InterceptSecuredCtx is called in
+// place of InterceptSecured and InterceptSecuredConn.
+//
+// ctx is cancelled when the connection attempt is abandoned. The check holds
+// up the connection, and for some transports other inbound connections too,
+// so implementations should bound the time they take.
+type ContextSecuredConnGater interface {
+	// InterceptSecuredCtx returns nil to allow the connection, and the
+	// reason for refusing it otherwise.
+	InterceptSecuredCtx(ctx context.Context, dir network.Direction, p peer.ID, info SecuredConnInfo) error
+}
+
+// InterceptSecured tests whether g allows the secured connection described by
+// info. It calls InterceptSecuredConn if g implements SecuredConnGater, and
+// InterceptSecured otherwise. Callers that can wait for gaters doing I/O
+// should use InterceptSecuredContext.
+func InterceptSecured(g ConnectionGater, dir network.Direction, p peer.ID, info SecuredConnInfo) (allow bool) {
+	if sg, ok := g.(SecuredConnGater); ok {
+		return sg.InterceptSecuredConn(dir, p, info)
+	}
+	return g.InterceptSecured(dir, p, info.ConnMultiaddrs)
+}
+
+// InterceptSecuredContext is like InterceptSecured, but calls
+// InterceptSecuredCtx if g implements ContextSecuredConnGater. It returns a
+// *GatedError if the connection is refused.
+func InterceptSecuredContext(ctx context.Context, g ConnectionGater, dir network.Direction, p peer.ID, info SecuredConnInfo) error {
+	if cg, ok := g.(ContextSecuredConnGater); ok {
+		if err := cg.InterceptSecuredCtx(ctx, dir, p, info); err != nil {
+			return &GatedError{Peer: p, Addr: info.RemoteMultiaddr(), Direction: dir, Err: err}
+		}
+		return nil
+	}
+	if !InterceptSecured(g, dir, p, info) {
+		return &GatedError{Peer: p, Addr: info.RemoteMultiaddr(), Direction: dir}
+	}
+	return nil
+}
+
+// GatedError is returned when the connection gater refuses a secured
+// connection, so that dialers can tell gated connections from failed ones.
+type GatedError struct {
+	Peer      peer.ID
+	Addr      ma.Multiaddr
+	Direction network.Direction
+	// Err is the reason given by a ContextSecuredConnGater, if any.
+	Err error
+}
+
+func (e *GatedError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("gater rejected connection with peer %s and addr %s with direction %s: %s", e.Peer, e.Addr, e.Direction, e.Err)
+	}
+	return fmt.Sprintf("gater rejected connection with peer %s and addr %s with direction %s", e.Peer, e.Addr, e.Direction)
+}
+
+func (e *GatedError) Unwrap() error { return e.Err }
@@ -2,7 +2,10 @@ package connmgr
 
 import (
 	"math"
+	"sync"
 	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
 )
 
 // DecayNone applies no decay.
@@ -38,6 +41,186 @@ func DecayExpireWhenInactive(after time.Duration) DecayFn {
 	}
 }
 
+// DecayExponential halves the value of the tag every halfLife. The decay
+// applied on every tick is derived from the interval of the tag. Values are
+// integers, but the fractional part left by every tick is carried over to the
+// next one, so that the value follows the half-life rather than decaying faster
+// by rounding down on every tick. It erases the tag when the value reaches
+// zero.
+//
+// The returned DecayFn keeps the fractional parts per peer, and must be
+// registered on a single tag. DecayExponential panics if halfLife is not
+// positive.
+func DecayExponential(halfLife time.Duration) DecayFn {
+	if halfLife <= 0 {
+		panic("connmgr: non-positive half-life for DecayExponential")
+	}
+	e := &exponentialDecay{halfLife: halfLife, peers: make(map[peer.ID]*peerRemainder)}
+	return e.decay
+}
+
+type exponentialDecay struct {
+	halfLife time.Duration
+
+	mu    sync.Mutex
+	peers map[peer.ID]*peerRemainder
+	sweep staleSweep
+}
+
+// peerRemainder is the fractional part of the value of a peer, dropped when
+// the value was rounded down to an integer.
+type peerRemainder struct {
+	added     time.Time
+	visited   time.Time
+	remainder float64
+}
+
+// roundingSlack absorbs the floating point error of repeatedly multiplying by
+// the decay coefficient, so that e.g. two ticks of half a half-life halve a
+// value exactly.
+const roundingSlack = 1e-9
+
+func (e *exponentialDecay) decay(value DecayingValue) (after int, rm bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if cutoff, ok := e.sweep.observe(value); ok {
+		for p, pr := range e.peers {
+			if p != value.Peer && pr.visited.Before(cutoff) {
+				delete(e.peers, p)
+			}
+		}
+	}
+	v := float64(value.Value)
+	pr, ok := e.peers[value.Peer]
+	// a different Added time means the value was removed and bumped again
+	if ok && pr.added.Equal(value.Added) {
+		v += pr.remainder
+	}
+	v *= math.Exp2(-float64(value.Tag.Interval()) / float64(e.halfLife))
+	after = int(math.Floor(v + roundingSlack))
+	if after <= 0 {
+		delete(e.peers, value.Peer)
+		return 0, true
+	}
+	e.peers[value.Peer] = &peerRemainder{
+		added:     value.Added,
+		visited:   value.LastVisit,
+		remainder: max(v-float64(after), 0),
+	}
+	return after, false
+}
+
+// staleVisits is the number of intervals after which a peer that was neither
+// bumped nor decayed is forgotten. Values are decayed on every interval while
+// they exist, so such a peer was untagged, trimmed, or its tag was closed.
+const staleVisits = 3
+
+// staleSweep schedules the eviction of the state kept for peers whose values
+// were removed without being decayed to zero.
+type staleSweep struct {
+	// latest is the most recent visit seen across all peers.
+	latest time.Time
+	next   time.Time
+}
+
+// observe records the visit of value. At most once per interval, it returns
+// true along with the cutoff before which the state of a peer last visited is
+// stale.
+func (s *staleSweep) observe(value DecayingValue) (cutoff time.Time, sweep bool) {
+	if value.LastVisit.After(s.latest) {
+		s.latest = value.LastVisit
+	}
+	if s.latest.Before(s.next) {
+		return time.Time{}, false
+	}
+	interval := value.Tag.Interval()
+	s.next = s.latest.Add(interval)
+	return s.latest.Add(-staleVisits * interval), true
+}
+
+// DecaySlidingWindow returns a DecayFn and a BumpFn that keep the value of a
+// tag equal to the sum of the deltas it was bumped by within the last window,
+// at the resolution of the interval of the tag. It erases the tag once no
+// bumps fall within the window.
+//
+// The two functions share the bumps of each peer, and must be registered
+// together, on a single tag.
+func DecaySlidingWindow(window time.Duration) (DecayFn, BumpFn) {
+	w := &slidingWindow{window: window, peers: make(map[peer.ID]*peerWindow)}
+	return w.decay, w.bump
+}
+
+type slidingWindow struct {
+	window time.Duration
+
+	mu    sync.Mutex
+	peers map[peer.ID]*peerWindow
+	sweep staleSweep
+}
+
+// peerWindow holds the bumps of a peer, summed per tick. buckets[head] is the
+// bucket of the current tick.
+type peerWindow struct {
+	added   time.Time
+	visited time.Time
+	buckets []int
+	head    int
+}
+
+// observe records the visit of value and, at most once per interval, evicts
+// the peers that have not been visited for staleVisits intervals.
+func (w *slidingWindow) observe(value DecayingValue) {
+	cutoff, ok := w.sweep.observe(value)
+	if !ok {
+		return
+	}
+	for p, pw := range w.peers {
+		if p != value.Peer && pw.visited.Before(cutoff) {
+			delete(w.peers, p)
+		}
+	}
+}
+
+func (w *slidingWindow) bump(value DecayingValue, delta int) (after int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.observe(value)
+	pw, ok := w.peers[value.Peer]
+	// a different Added time means the value was removed and bumped again
+	if !ok || !pw.added.Equal(value.Added) {
+		n := int((w.window + value.Tag.Interval() - 1) / value.Tag.Interval())
+		pw = &peerWindow{added: value.Added, buckets: make([]int, max(n, 1))}
+		w.peers[value.Peer] = pw
+	}
+	pw.visited = value.LastVisit
+	pw.buckets[pw.head] += delta
+	return value.Value + delta
+}
+
+func (w *slidingWindow) decay(value DecayingValue) (after int, rm bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.observe(value)
+	pw, ok := w.peers[value.Peer]
+	if !ok || !pw.added.Equal(value.Added) {
+		return 0, true
+	}
+	pw.visited = value.LastVisit
+	pw.head = (pw.head + 1) % len(pw.buckets)
+	after = value.Value - pw.buckets[pw.head]
+	pw.buckets[pw.head] = 0
+	for _, b := range pw.buckets {
+		if b != 0 {
+			return after, false
+		}
+	}
+	delete(w.peers, value.Peer)
+	return 0, true
+}
+
 // BumpSumUnbounded adds the incoming value to the peer's score.
 func BumpSumUnbounded() BumpFn {
 	return func(value DecayingValue, delta int) (after int) {
@@ -0,0 +1,65 @@
+package connmgr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/test"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testTag struct{ interval time.Duration }
+
+func (t testTag) Name() string            { return "test" }
+func (t testTag) Interval() time.Duration { return t.interval }
+func (t testTag) Bump(peer.ID, int) error { return nil }
+func (t testTag) Remove(peer.ID) error    { return nil }
+func (t testTag) Close() error            { return nil }
+
+func TestDecayExponentialRejectsNonPositiveHalfLife(t *testing.T) {
+	require.Panics(t, func() { DecayExponential(0) })
+	require.Panics(t, func() { DecayExponential(-time.Second) })
+}
+
+func TestDecayExponentialKeepsFractions(t *testing.T) {
+	decay := DecayExponential(2 * time.Second)
+	tag := testTag{interval: time.Second}
+	start := time.Now()
+	v := DecayingValue{Tag: tag, Peer: test.RandPeerIDFatal(t), Added: start, LastVisit: start, Value: 10}
+
+	// rounding down on every tick would give 7, 4, 2, 1, 0
+	var values []int
+	for {
+		var rm bool
+		v.Value, rm = decay(v)
+		if rm {
+			break
+		}
+		values = append(values, v.Value)
+	}
+	require.Equal(t, []int{7, 5, 3, 2, 1, 1}, values)
+}
+
+func TestSlidingWindowForgetsRemovedPeers(t *testing.T) {
+	w := &slidingWindow{window: time.Minute, peers: make(map[peer.ID]*peerWindow)}
+	tag := testTag{interval: time.Second}
+	start := time.Now()
+
+	gone, live := test.RandPeerIDFatal(t), test.RandPeerIDFatal(t)
+	w.bump(DecayingValue{Tag: tag, Peer: gone, Added: start, LastVisit: start}, 1)
+	v := DecayingValue{Tag: tag, Peer: live, Added: start, LastVisit: start}
+	v.Value = w.bump(v, 1)
+	require.Len(t, w.peers, 2)
+
+	// the value of gone is removed from the tag, and is never decayed again
+	for i := 1; i <= staleVisits+2; i++ {
+		var rm bool
+		v.Value, rm = w.decay(v)
+		require.False(t, rm)
+		v.LastVisit = start.Add(time.Duration(i) * time.Second)
+	}
+	require.Len(t, w.peers, 1)
+	require.Contains(t, w.peers, live)
+}
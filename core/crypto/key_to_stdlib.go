@@ -52,6 +52,8 @@ func PrivKeyToStdKey(priv PrivKey) (crypto.PrivateKey, error) {
 		return &p.k, nil
 	case *Secp256k1PrivateKey:
 		return p, nil
+	case *SignerPrivateKey:
+		return p.signer, nil
 	default:
 		return nil, ErrBadKeyType
 	}
@@ -0,0 +1,146 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"errors"
+
+	"github.com/libp2p/go-libp2p/core/crypto/pb"
+	"github.com/libp2p/go-libp2p/core/internal/catch"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secp256k1ecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// ErrKeyNotExportable is returned when trying to access the raw bytes of a
+// private key that is not held in memory, e.g. a SignerPrivateKey.
+var ErrKeyNotExportable = errors.New("private key is not exportable")
+
+// SignerPrivateKey is a private key that delegates signing to a crypto.Signer,
+// e.g. a key held by an HSM, a KMS or a TPM. Only the public key is held in
+// memory. It can be used wherever a PrivKey is used, except where the raw key
+// bytes are needed: Raw and MarshalPrivateKey return ErrKeyNotExportable.
+type SignerPrivateKey struct {
+	signer crypto.Signer
+	pub    PubKey
+}
+
+var _ PrivKey = (*SignerPrivateKey)(nil)
+
+// KeyPairFromSigner wraps a crypto.Signer in a PrivKey. The signer's public key
+// must be an *rsa.PublicKey, an *ecdsa.PublicKey, an ed25519.PublicKey or a
+// *secp256k1.PublicKey. ECDSA keys on the secp256k1 curve are treated as
+// Secp256k1 keys.
+//
+// Signatures are requested from the signer in the format libp2p uses for the
+// respective key type: PKCS #1 v1.5 over a SHA-256 digest for RSA, ASN.1 over
+// a SHA-256 digest for ECDSA and Secp256k1, and over the message itself for
+// Ed25519.
+func KeyPairFromSigner(signer crypto.Signer) (PrivKey, PubKey, error) {
+	if signer == nil {
+		return nil, nil, ErrNilPrivateKey
+	}
+	pub, err := pubKeyFromStdKey(signer.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+	return &SignerPrivateKey{signer: signer, pub: pub}, pub, nil
+}
+
+func pubKeyFromStdKey(pub crypto.PublicKey) (PubKey, error) {
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		if p.N.BitLen() < MinRsaKeyBits {
+			return nil, ErrRsaKeyTooSmall
+		}
+		return &RsaPublicKey{k: *p}, nil
+	case *ecdsa.PublicKey:
+		if p.Curve == secp256k1.S256() {
+			var x, y secp256k1.FieldVal
+			if x.SetByteSlice(p.X.Bytes()) || y.SetByteSlice(p.Y.Bytes()) {
+				return nil, ErrBadKeyType
+			}
+			return (*Secp256k1PublicKey)(secp256k1.NewPublicKey(&x, &y)), nil
+		}
+		return &ECDSAPublicKey{p}, nil
+	case ed25519.PublicKey:
+		if len(p) != ed25519.PublicKeySize {
+			return nil, ErrBadKeyType
+		}
+		return &Ed25519PublicKey{p}, nil
+	case *secp256k1.PublicKey:
+		return (*Secp256k1PublicKey)(p), nil
+	default:
+		return nil, ErrBadKeyType
+	}
+}
+
+// Signer returns the underlying signer.
+func (k *SignerPrivateKey) Signer() crypto.Signer {
+	return k.signer
+}
+
+// Type returns the type of the signer's key.
+func (k *SignerPrivateKey) Type() pb.KeyType {
+	return k.pub.Type()
+}
+
+// Raw returns ErrKeyNotExportable.
+func (k *SignerPrivateKey) Raw() ([]byte, error) {
+	return nil, ErrKeyNotExportable
+}
+
+// Equals checks whether o is a private key with the same public key.
+func (k *SignerPrivateKey) Equals(o Key) bool {
+	sk, ok := o.(PrivKey)
+	if !ok {
+		return false
+	}
+	return k.pub.Equals(sk.GetPublic())
+}
+
+// GetPublic returns the signer's public key.
+func (k *SignerPrivateKey) GetPublic() PubKey {
+	return k.pub
+}
+
+// Sign signs data using the signer.
+func (k *SignerPrivateKey) Sign(data []byte) (sig []byte, err error) {
+	defer func() { catch.HandlePanic(recover(), &err, "signer signing") }()
+
+	if k.pub.Type() == pb.KeyType_Ed25519 {
+		return k.signer.Sign(rand.Reader, data, crypto.Hash(0))
+	}
+	hash := sha256.Sum256(data)
+	sig, err = k.signer.Sign(rand.Reader, hash[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	if k.pub.Type() == pb.KeyType_Secp256k1 {
+		return canonicalSecp256k1Signature(sig)
+	}
+	return sig, nil
+}
+
+// canonicalSecp256k1Signature converts an ASN.1 ECDSA signature to the
+// canonical encoding with a low S value, as produced by Secp256k1PrivateKey.
+func canonicalSecp256k1Signature(sig []byte) ([]byte, error) {
+	var parsed ECDSASig
+	rest, err := asn1.Unmarshal(sig, &parsed)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 || parsed.R.Sign() <= 0 || parsed.S.Sign() <= 0 {
+		return nil, errors.New("invalid secp256k1 signature")
+	}
+	var r, s secp256k1.ModNScalar
+	if r.SetByteSlice(parsed.R.Bytes()) || s.SetByteSlice(parsed.S.Bytes()) {
+		return nil, errors.New("invalid secp256k1 signature")
+	}
+	return secp256k1ecdsa.NewSignature(&r, &s).Serialize(), nil
+}
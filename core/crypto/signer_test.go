@@ -0,0 +1,164 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"errors"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/crypto/pb"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secp256k1ecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// secp256k1Signer is a crypto.Signer for secp256k1 keys that returns
+// signatures with a high S value, like some KMSs do.
+type secp256k1Signer struct {
+	key *secp256k1.PrivateKey
+	pub crypto.PublicKey
+}
+
+func (s *secp256k1Signer) Public() crypto.PublicKey { return s.pub }
+
+func (s *secp256k1Signer) Sign(_ io.Reader, digest []byte, _ crypto.SignerOpts) ([]byte, error) {
+	sig := secp256k1ecdsa.Sign(s.key, digest)
+	r, sv := sig.R(), sig.S()
+	rBytes, sBytes := r.Bytes(), sv.Bytes()
+	highS := new(big.Int).Sub(secp256k1.S256().N, new(big.Int).SetBytes(sBytes[:]))
+	return asn1.Marshal(ECDSASig{R: new(big.Int).SetBytes(rBytes[:]), S: highS})
+}
+
+func TestSignerSignAndVerify(t *testing.T) {
+	_, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secpPriv, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		signer  crypto.Signer
+		keyType pb.KeyType
+	}{
+		{"Ed25519", edPriv, pb.KeyType_Ed25519},
+		{"ECDSA", ecPriv, pb.KeyType_ECDSA},
+		{"RSA", rsaPriv, pb.KeyType_RSA},
+		{"Secp256k1", &secp256k1Signer{key: secpPriv, pub: secpPriv.PubKey()}, pb.KeyType_Secp256k1},
+		{"Secp256k1 as ECDSA", &secp256k1Signer{key: secpPriv, pub: &secpPriv.ToECDSA().PublicKey}, pb.KeyType_Secp256k1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			priv, pub, err := KeyPairFromSigner(tc.signer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if priv.Type() != tc.keyType || pub.Type() != tc.keyType {
+				t.Fatalf("expected key type %s, got %s", tc.keyType, priv.Type())
+			}
+
+			data := []byte("hello! and welcome to some awesome crypto primitives")
+			sig, err := priv.Sign(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Verify with a public key that went through serialization, as
+			// a remote peer would.
+			pubBytes, err := MarshalPublicKey(pub)
+			if err != nil {
+				t.Fatal(err)
+			}
+			remotePub, err := UnmarshalPublicKey(pubBytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ok, err := remotePub.Verify(data, sig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ok {
+				t.Fatal("signature didn't match")
+			}
+			data[0] = ^data[0]
+			// RSA keys return an error for invalid signatures.
+			if ok, _ := remotePub.Verify(data, sig); ok {
+				t.Fatal("signature matched and shouldn't")
+			}
+
+			if _, err := priv.Raw(); !errors.Is(err, ErrKeyNotExportable) {
+				t.Fatalf("expected ErrKeyNotExportable, got %v", err)
+			}
+			if _, err := MarshalPrivateKey(priv); err == nil {
+				t.Fatal("expected marshaling the private key to fail")
+			}
+			if !priv.Equals(priv) || !priv.GetPublic().Equals(pub) {
+				t.Fatal("expected keys to be equal")
+			}
+			std, err := PrivKeyToStdKey(priv)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := std.(crypto.Signer); !ok {
+				t.Fatal("expected the signer")
+			}
+		})
+	}
+}
+
+func TestSignerEquals(t *testing.T) {
+	_, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, _, err := KeyPairFromSigner(edPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inMemory, _, err := KeyPairFromStdKey(&edPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !priv.Equals(inMemory) {
+		t.Fatal("expected a signer key to equal the in-memory key")
+	}
+	other, _, err := GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if priv.Equals(other) {
+		t.Fatal("expected keys to differ")
+	}
+	if priv.Equals(priv.GetPublic()) {
+		t.Fatal("expected a private key not to equal a public key")
+	}
+}
+
+func TestSignerUnsupportedKeys(t *testing.T) {
+	if _, _, err := KeyPairFromSigner(nil); !errors.Is(err, ErrNilPrivateKey) {
+		t.Fatalf("expected ErrNilPrivateKey, got %v", err)
+	}
+	smallRSA, err := rsa.GenerateKey(rand.Reader, MinRsaKeyBits/2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := KeyPairFromSigner(smallRSA); err != ErrRsaKeyTooSmall {
+		t.Fatalf("expected ErrRsaKeyTooSmall, got %v", err)
+	}
+}
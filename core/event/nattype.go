@@ -7,6 +7,9 @@ import "github.com/libp2p/go-libp2p/core/network"
 // Note: This event is meaningful ONLY if the AutoNAT Reachability is Private.
 // Consumers of this event should ALSO consume the `EvtLocalReachabilityChanged` event and interpret
 // this event ONLY if the Reachability on the `EvtLocalReachabilityChanged` is Private.
+//
+// The event is emitted for each Transport Protocol, but only the last one is kept for new subscribers.
+// Consumers that need the NAT Device Type of both protocols should consume `EvtNATDeviceTypesChanged`.
 type EvtNATDeviceTypeChanged struct {
 	// TransportProtocol is the Transport Protocol for which the NAT Device Type has been determined.
 	TransportProtocol network.NATTransportProtocol
@@ -15,4 +18,28 @@ type EvtNATDeviceTypeChanged struct {
 	// on `network.NATDeviceType` enumerations for a better understanding of what these types mean and
 	// how they impact Connectivity and Hole Punching.
 	NatDeviceType network.NATDeviceType
+	// Confidence is the share of the observations that agree with NatDeviceType, between 0 and 1.
+	// It is 0 if the NAT Device Type is unknown.
+	Confidence float64
+	// Evidence are the observations NatDeviceType was determined from.
+	Evidence network.NATDeviceTypeEvidence
+}
+
+// NATDeviceTypeInfo is the NAT Device Type determined for a Transport Protocol,
+// see EvtNATDeviceTypeChanged for the meaning of the fields.
+type NATDeviceTypeInfo struct {
+	NatDeviceType network.NATDeviceType
+	Confidence    float64
+	Evidence      network.NATDeviceTypeEvidence
+}
+
+// EvtNATDeviceTypesChanged is emitted when the NAT Device Type, or the evidence it is
+// determined from, changes for any Transport Protocol. It carries the NAT Device Type
+// of both protocols, so that hole punching can pick a strategy per transport.
+//
+// This event is sticky: new subscribers receive the last one. Like
+// EvtNATDeviceTypeChanged, it is ONLY meaningful if the AutoNAT Reachability is Private.
+type EvtNATDeviceTypesChanged struct {
+	UDP NATDeviceTypeInfo
+	TCP NATDeviceTypeInfo
 }
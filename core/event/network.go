@@ -1,8 +1,12 @@
 package event
 
 import (
+	"time"
+
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
+
+	ma "github.com/multiformats/go-multiaddr"
 )
 
 // EvtPeerConnectednessChanged should be emitted every time the "connectedness" to a
@@ -53,3 +57,42 @@ type EvtPeerConnectednessChanged struct {
 	// Connectedness is the new connectedness state.
 	Connectedness network.Connectedness
 }
+
+// EvtPeerConnectionIdleClosed is emitted when a connection is closed because
+// it was idle for longer than the idle timeout configured on the swarm.
+type EvtPeerConnectionIdleClosed struct {
+	// Peer is the remote peer of the closed connection.
+	Peer peer.ID
+	// Conn is the closed connection.
+	Conn network.Conn
+	// IdleFor is the time the connection was idle before it was closed.
+	IdleFor time.Duration
+}
+
+// EvtPeerConnectionPathChanged is emitted when a connection migrated to a new
+// network path without being re-established, for example when a mobile peer
+// switched from Wi-Fi to a cellular network. Only transports supporting
+// connection migration, like QUIC, emit this event.
+type EvtPeerConnectionPathChanged struct {
+	// Peer is the remote peer of the connection.
+	Peer peer.ID
+	// Conn is the connection that changed its path.
+	Conn network.Conn
+	// OldRemoteAddr is the remote address of the previous path.
+	OldRemoteAddr ma.Multiaddr
+	// NewRemoteAddr is the remote address of the new path.
+	NewRemoteAddr ma.Multiaddr
+	// LocalAddr is the local address of the new path.
+	LocalAddr ma.Multiaddr
+}
+
+// EvtPrivateNetworkExemption is emitted when a connection is exempted from
+// private network (PSK) protection, because it matches an exemption
+// configured on the upgrader.
+type EvtPrivateNetworkExemption struct {
+	// Direction is the direction of the connection.
+	Direction network.Direction
+	// LocalAddr and RemoteAddr are the addresses of the connection.
+	LocalAddr  ma.Multiaddr
+	RemoteAddr ma.Multiaddr
+}
@@ -0,0 +1,44 @@
+package event
+
+import (
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// EvtPeerstoreAddrsUpdated is emitted by the peerstore when addresses of a
+// peer are added or removed, allowing routing layers to track the address book
+// without polling it.
+type EvtPeerstoreAddrsUpdated struct {
+	// Peer is the peer whose addresses were updated.
+	Peer peer.ID
+	// Added are the addresses that were added.
+	Added []ma.Multiaddr
+	// Removed are the addresses that were removed or garbage collected after
+	// they expired. Expired addresses are only reported once they're garbage
+	// collected.
+	Removed []ma.Multiaddr
+}
+
+// EvtPeerstoreProtocolsUpdated is emitted by the peerstore when the protocols
+// recorded for a peer change. Unlike EvtPeerProtocolsUpdated, which is emitted
+// by identify, it covers every change made to the peerstore.
+type EvtPeerstoreProtocolsUpdated struct {
+	// Peer is the peer whose protocols were updated.
+	Peer peer.ID
+	// Added are the protocols that were added.
+	Added []protocol.ID
+	// Removed are the protocols that were removed.
+	Removed []protocol.ID
+}
+
+// EvtPeerstoreKeyAdded is emitted by the peerstore when the public key of a
+// peer is stored for the first time.
+type EvtPeerstoreKeyAdded struct {
+	// Peer is the peer whose key was added.
+	Peer peer.ID
+	// PubKey is the peer's public key.
+	PubKey crypto.PubKey
+}
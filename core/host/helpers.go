@@ -1,6 +1,13 @@
 package host
 
-import "github.com/libp2p/go-libp2p/core/peer"
+import (
+	"context"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
 
 // InfoFromHost returns a peer.AddrInfo struct with the Host's ID and all of its Addrs.
 func InfoFromHost(h Host) *peer.AddrInfo {
@@ -9,3 +16,52 @@ func InfoFromHost(h Host) *peer.AddrInfo {
 		Addrs: h.Addrs(),
 	}
 }
+
+// SetVersionedStreamHandler sets a handler for a versioned protocol ID, e.g.
+// "/app/kad/1.2.3". The handler is invoked for requests of all versions of
+// the protocol that the given version satisfies, see protocol.Version.Satisfies.
+func SetVersionedStreamHandler(h Host, id protocol.ID, handler network.StreamHandler) error {
+	match, err := protocol.VersionMatcher(id)
+	if err != nil {
+		return err
+	}
+	h.SetStreamHandlerMatch(id, match, handler)
+	return nil
+}
+
+// NewVersionedStream opens a stream to peer p for a versioned protocol ID,
+// e.g. "/app/kad/1.2.3". It negotiates the highest version the peer advertises
+// that satisfies the given version, falling back to requesting id itself. The
+// negotiated version is returned along with the stream.
+func NewVersionedStream(ctx context.Context, h Host, p peer.ID, id protocol.ID) (network.Stream, protocol.Version, error) {
+	if _, _, err := protocol.SplitVersion(id); err != nil {
+		return nil, protocol.Version{}, err
+	}
+	// Connecting waits for identify, so we know which protocols the peer
+	// supports.
+	if nodial, _ := network.GetNoDial(ctx); !nodial {
+		if err := h.Connect(ctx, peer.AddrInfo{ID: p}); err != nil {
+			return nil, protocol.Version{}, err
+		}
+	}
+	supported, err := h.Peerstore().GetProtocols(p)
+	if err != nil {
+		return nil, protocol.Version{}, err
+	}
+	pids, err := protocol.CompatibleIDs(id, supported)
+	if err != nil {
+		return nil, protocol.Version{}, err
+	}
+	pids = append(pids, id)
+
+	s, err := h.NewStream(ctx, p, pids...)
+	if err != nil {
+		return nil, protocol.Version{}, err
+	}
+	_, v, err := protocol.SplitVersion(s.Protocol())
+	if err != nil {
+		s.Reset()
+		return nil, protocol.Version{}, fmt.Errorf("negotiated unexpected protocol: %w", err)
+	}
+	return s, v, nil
+}
@@ -2,6 +2,8 @@ package network
 
 import (
 	"context"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 
@@ -54,6 +56,7 @@ const (
 	ConnShutdown                  ConnErrorCode = 0x1006
 	ConnGated                     ConnErrorCode = 0x1007
 	ConnCodeOutOfRange            ConnErrorCode = 0x1008
+	ConnMaintenance               ConnErrorCode = 0x1009
 )
 
 // Conn is a connection to a remote peer. It multiplexes streams.
@@ -89,6 +92,18 @@ type Conn interface {
 	IsClosed() bool
 }
 
+// ErrMigrationUnsupported is returned by Migrate if the transport of a
+// connection doesn't support connection migration.
+var ErrMigrationUnsupported = errors.New("connection migration not supported")
+
+// MigratableConn is implemented by connections that can move to a new local
+// socket without interrupting their streams, e.g. QUIC connections after the
+// network interface they were using went away.
+type MigratableConn interface {
+	// Migrate moves the connection to a new local socket.
+	Migrate(ctx context.Context) error
+}
+
 // ConnectionState holds information about the connection.
 type ConnectionState struct {
 	// The stream multiplexer used on this connection (if any). For example: /yamux/1.0.0
@@ -99,6 +114,11 @@ type ConnectionState struct {
 	Transport string
 	// indicates whether StreamMultiplexer was selected using inlined muxer negotiation
 	UsedEarlyMuxerNegotiation bool
+	// TLSClientCertificates are the certificates the remote peer presented to
+	// a transport that terminates TLS below the security protocol, like a
+	// secure WebSocket listener requesting client certificates. They are
+	// unrelated to the libp2p identity of the peer.
+	TLSClientCertificates []*x509.Certificate
 }
 
 // ConnSecurity is the interface that one can mix into a connection interface to
@@ -129,6 +149,13 @@ type ConnMultiaddrs interface {
 	RemoteMultiaddr() ma.Multiaddr
 }
 
+// ConnTLSClientCerts is an optional interface for raw transport connections
+// that terminate TLS below the security protocol. It returns the certificates
+// the client presented, if any.
+type ConnTLSClientCerts interface {
+	TLSClientCertificates() []*x509.Certificate
+}
+
 // ConnStat is an interface mixin for connection types that provide connection statistics.
 type ConnStat interface {
 	// Stat stores metadata pertaining to this conn.
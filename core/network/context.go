@@ -15,6 +15,7 @@ type dialPeerTimeoutCtxKey struct{}
 type forceDirectDialCtxKey struct{}
 type allowLimitedConnCtxKey struct{}
 type simConnectCtxKey struct{ isClient bool }
+type dialBudgetCtxKey struct{}
 
 var noDial = noDialCtxKey{}
 var forceDirectDial = forceDirectDialCtxKey{}
@@ -128,3 +129,28 @@ func GetUseTransient(ctx context.Context) (usetransient bool, reason string) {
 	}
 	return false, ""
 }
+
+// DialBudget limits the dials made to connect to a peer, across all the
+// addresses of the peer. A zero field means no limit.
+type DialBudget struct {
+	// MaxConcurrent is the maximum number of dials in flight at the same time.
+	MaxConcurrent int
+	// MaxAttempts is the maximum number of addresses dialed. The addresses
+	// ranked first by the dial ranker are dialed.
+	MaxAttempts int
+	// Timeout is the deadline for connecting. It can only shorten the DialPeer
+	// timeout.
+	Timeout time.Duration
+}
+
+// WithDialBudget constructs a new context with the budget for dialing a peer,
+// overriding the network's default budget.
+func WithDialBudget(ctx context.Context, b DialBudget) context.Context {
+	return context.WithValue(ctx, dialBudgetCtxKey{}, b)
+}
+
+// GetDialBudget returns the dial budget set in the context, if any.
+func GetDialBudget(ctx context.Context) (b DialBudget, ok bool) {
+	b, ok = ctx.Value(dialBudgetCtxKey{}).(DialBudget)
+	return b, ok
+}
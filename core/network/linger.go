@@ -0,0 +1,84 @@
+package network
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrExpectedEOF is returned by CloseWithLinger when the remote peer keeps
+// sending data instead of closing its side of the stream.
+var ErrExpectedEOF = errors.New("expected EOF, got data")
+
+// maxLingerDiscard is the amount of data CloseWithLinger reads and discards
+// while waiting for an EOF.
+const maxLingerDiscard = 4 << 10
+
+// Lingerer is implemented by streams and connections that allow configuring
+// how long closing a stream waits for the written data to be received. See
+// CloseWithLinger for the meaning of the linger duration.
+type Lingerer interface {
+	SetLinger(linger time.Duration)
+}
+
+// CloseWriteWithDeadline closes s for writing, flushing the buffered data and
+// sending an EOF. If that doesn't complete before deadline, s is reset.
+//
+// Stream muxers differ in whether CloseWrite blocks until buffered data was
+// sent, so the deadline is an upper bound rather than an exact flush timeout.
+func CloseWriteWithDeadline(s MuxedStream, deadline time.Time) error {
+	if err := s.SetWriteDeadline(deadline); err != nil {
+		s.Reset()
+		return err
+	}
+	if err := s.CloseWrite(); err != nil {
+		s.Reset()
+		return err
+	}
+	return nil
+}
+
+// CloseWithLinger closes s, controlling what happens to data written to s that
+// the remote peer didn't receive yet:
+//
+//   - If linger is negative, s is closed with Close, which doesn't wait for
+//     the data to be received. Depending on the stream muxer, the data may be
+//     lost if the connection is closed shortly after.
+//   - If linger is zero, s is reset, discarding the data.
+//   - If linger is positive, s is closed for writing and CloseWithLinger waits
+//     up to linger for the remote peer to acknowledge the data by closing its
+//     side of the stream. If it doesn't, s is reset.
+//
+// Data the remote peer sends while waiting is discarded.
+func CloseWithLinger(s MuxedStream, linger time.Duration) error {
+	switch {
+	case linger < 0:
+		return s.Close()
+	case linger == 0:
+		return s.Reset()
+	}
+
+	deadline := time.Now().Add(linger)
+	if err := CloseWriteWithDeadline(s, deadline); err != nil {
+		return err
+	}
+	if err := awaitEOF(s, deadline); err != nil {
+		s.Reset()
+		return err
+	}
+	return s.Close()
+}
+
+func awaitEOF(s MuxedStream, deadline time.Time) error {
+	if err := s.SetReadDeadline(deadline); err != nil {
+		return err
+	}
+	n, err := io.Copy(io.Discard, io.LimitReader(s, maxLingerDiscard+1))
+	if err != nil {
+		return err
+	}
+	if n > maxLingerDiscard {
+		return ErrExpectedEOF
+	}
+	return nil
+}
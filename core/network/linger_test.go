@@ -0,0 +1,83 @@
+package network
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type lingerStream struct {
+	io.Reader
+	io.Writer
+
+	writeDeadline, readDeadline time.Time
+	closed, closedWrite, reset  bool
+}
+
+var _ MuxedStream = &lingerStream{}
+
+func (s *lingerStream) Close() error      { s.closed = true; return nil }
+func (s *lingerStream) CloseWrite() error { s.closedWrite = true; return nil }
+func (s *lingerStream) CloseRead() error  { return nil }
+func (s *lingerStream) Reset() error      { s.reset = true; return nil }
+func (s *lingerStream) ResetWithError(StreamErrorCode) error {
+	s.reset = true
+	return nil
+}
+func (s *lingerStream) SetDeadline(t time.Time) error {
+	s.readDeadline, s.writeDeadline = t, t
+	return nil
+}
+func (s *lingerStream) SetReadDeadline(t time.Time) error  { s.readDeadline = t; return nil }
+func (s *lingerStream) SetWriteDeadline(t time.Time) error { s.writeDeadline = t; return nil }
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+func TestCloseWithLinger(t *testing.T) {
+	t.Run("negative", func(t *testing.T) {
+		s := &lingerStream{}
+		require.NoError(t, CloseWithLinger(s, -1))
+		require.True(t, s.closed)
+		require.False(t, s.closedWrite)
+		require.False(t, s.reset)
+	})
+
+	t.Run("zero", func(t *testing.T) {
+		s := &lingerStream{}
+		require.NoError(t, CloseWithLinger(s, 0))
+		require.True(t, s.reset)
+		require.False(t, s.closed)
+	})
+
+	t.Run("EOF", func(t *testing.T) {
+		s := &lingerStream{Reader: strings.NewReader("response")}
+		start := time.Now()
+		require.NoError(t, CloseWithLinger(s, time.Minute))
+		require.True(t, s.closedWrite)
+		require.True(t, s.closed)
+		require.False(t, s.reset)
+		require.WithinDuration(t, start.Add(time.Minute), s.writeDeadline, time.Second)
+		require.Equal(t, s.writeDeadline, s.readDeadline)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		s := &lingerStream{Reader: errReader{os.ErrDeadlineExceeded}}
+		require.ErrorIs(t, CloseWithLinger(s, time.Second), os.ErrDeadlineExceeded)
+		require.True(t, s.closedWrite)
+		require.True(t, s.reset)
+		require.False(t, s.closed)
+	})
+
+	t.Run("too much data", func(t *testing.T) {
+		s := &lingerStream{Reader: bytes.NewReader(make([]byte, maxLingerDiscard+1))}
+		require.ErrorIs(t, CloseWithLinger(s, time.Second), ErrExpectedEOF)
+		require.True(t, s.reset)
+	})
+}
@@ -12,8 +12,21 @@ import (
 // ErrReset is returned when reading or writing on a reset stream.
 var ErrReset = errors.New("stream reset")
 
+// StreamErrorCode is the error code a stream is reset with, see
+// Stream.ResetWithError. The code is sent to the remote, which gets it in a
+// *StreamError from reads and writes, on all transports and muxers.
+//
+// Codes from 0x1000 to 0x1FFF are reserved for libp2p, see the Stream
+// constants below. Applications can use any other code. Note that the
+// WebTransport implementations of some browsers only support codes up to
+// 0xFF, so applications talking to browsers should stay in that range.
 type StreamErrorCode uint32
 
+// IsLibp2p reports whether c is in the range of codes reserved for libp2p.
+func (c StreamErrorCode) IsLibp2p() bool {
+	return c >= 0x1000 && c <= 0x1FFF
+}
+
 type StreamError struct {
 	ErrorCode      StreamErrorCode
 	Remote         bool
@@ -53,8 +66,29 @@ const (
 	StreamShutdown                  StreamErrorCode = 0x1007
 	StreamGated                     StreamErrorCode = 0x1008
 	StreamCodeOutOfRange            StreamErrorCode = 0x1009
+	StreamMaintenance               StreamErrorCode = 0x100A
 )
 
+// ErrStreamPriorityUnsupported is returned by SetPriority if the stream muxer
+// of a stream doesn't support priorities.
+var ErrStreamPriorityUnsupported = errors.New("stream muxer doesn't support stream priorities")
+
+// StreamPrioritizer is implemented by streams whose stream muxer can schedule
+// writes by priority.
+type StreamPrioritizer interface {
+	// SetPriority sets the priority of the stream's writes. While streams
+	// with a higher priority are writing, writes of streams with a lower
+	// priority on the same connection are held back, so that
+	// latency-sensitive streams aren't queued behind bulk transfers. Streams
+	// start with priority 0.
+	//
+	// Priorities are strict, not weighted: a stream doesn't get a share of
+	// the connection proportional to its priority. Muxers bound how long
+	// writes are held back, which caps, rather than stops, the throughput of
+	// lower priority streams.
+	SetPriority(priority uint8) error
+}
+
 // MuxedStream is a bidirectional io pipe within a connection.
 type MuxedStream interface {
 	io.Reader
@@ -139,6 +173,29 @@ type MuxedConn interface {
 	AcceptStream() (MuxedStream, error)
 }
 
+// MuxerStats are statistics about the streams multiplexed on a connection.
+// They help diagnose throughput problems that aren't visible on the
+// underlying socket, like streams starving each other or waiting for flow
+// control credit.
+type MuxerStats struct {
+	// OpenStreams is the number of streams that are open in at least one
+	// direction.
+	OpenStreams int
+	// StalledWrites is the number of stream writes that were blocked for at
+	// least a millisecond, usually because the flow control window of the
+	// stream or the connection was exhausted.
+	StalledWrites uint64
+	// StalledWriteTime is the total time these writes were blocked, i.e. the
+	// head-of-line blocking time of the connection's writers.
+	StalledWriteTime time.Duration
+}
+
+// ConnMuxerStats is implemented by connections whose stream muxer reports
+// MuxerStats.
+type ConnMuxerStats interface {
+	MuxerStats() MuxerStats
+}
+
 // Multiplexer wraps a net.Conn with a stream multiplexing
 // implementation and returns a MuxedConn that supports opening
 // multiple streams over the underlying net.Conn
@@ -56,3 +56,18 @@ func (n NATTransportProtocol) String() string {
 		return "unrecognized"
 	}
 }
+
+// NATDeviceTypeEvidence are the observations the NAT device type of a
+// transport protocol is determined from. Peers report the address they see us
+// connecting from, and a Cone NAT makes most of them report the same few
+// addresses.
+type NATDeviceTypeEvidence struct {
+	// Observations is the number of times peers observed our external
+	// addresses.
+	Observations int
+	// ExternalAddrs is the number of distinct external addresses observed.
+	ExternalAddrs int
+	// TopObservations is the number of observations of the most observed
+	// external addresses, which are the ones we advertise.
+	TopObservations int
+}
@@ -124,6 +124,14 @@ type Stats struct {
 	Limited bool
 	// Extra stores additional metadata about this connection.
 	Extra map[interface{}]interface{}
+	// BytesRead and BytesWritten are the number of bytes read from and
+	// written to this stream / conn. They're only set by implementations that
+	// track them, e.g. the swarm.
+	BytesRead    uint64
+	BytesWritten uint64
+	// Duration is how long this stream has been open or, once it's closed,
+	// how long it was open. It's only set by implementations that track it.
+	Duration time.Duration
 }
 
 // StreamHandler is the type of function used to listen for
@@ -216,3 +224,11 @@ type AddrDelay struct {
 
 // DialRanker provides a schedule of dialing the provided addresses
 type DialRanker func([]ma.Multiaddr) []AddrDelay
+
+// PeerDialRanker is like DialRanker, but it is also passed the peer being
+// dialed, so that the schedule can depend on it, e.g. on how recently the peer
+// was reachable. Addresses that aren't returned are not dialed.
+//
+// Dials to a peer are shared by concurrent callers, so ctx isn't the context
+// passed to DialPeer. It only carries the dial options, e.g. WithForceDirectDial.
+type PeerDialRanker func(ctx context.Context, p peer.ID, addrs []ma.Multiaddr) []AddrDelay
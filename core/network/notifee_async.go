@@ -0,0 +1,358 @@
+package network
+
+import (
+	"sync"
+	"sync/atomic"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// NotifyDropPolicy decides which notification an AsyncNotifiee drops when its
+// queue is full.
+//
+// Only Listen and Connected notifications are dropped. Dropping a Disconnected
+// or ListenClose notification would leave the Notifiee with state about a
+// connection or listener that's gone, so these are queued even if the queue is
+// full. They are discarded only if the matching Listen or Connected
+// notification was dropped. Since every queued Disconnected or ListenClose
+// notification belongs to an open connection or listener, this bounds the
+// queue.
+type NotifyDropPolicy int
+
+const (
+	// DropNewest drops the Listen or Connected notification that doesn't fit
+	// in the queue.
+	DropNewest NotifyDropPolicy = iota
+	// DropOldest drops the oldest queued Listen or Connected notification to
+	// make room for the new one.
+	DropOldest
+)
+
+type notifyConfig struct {
+	queueSize  int
+	dropPolicy NotifyDropPolicy
+	coalesce   bool
+}
+
+// NotifyOption configures an AsyncNotifiee.
+type NotifyOption func(*notifyConfig)
+
+// NotifyQueueSize sets the number of notifications that can be queued. It
+// defaults to 128.
+func NotifyQueueSize(n int) NotifyOption {
+	return func(cfg *notifyConfig) {
+		if n > 0 {
+			cfg.queueSize = n
+		}
+	}
+}
+
+// NotifyDrop sets the policy for dropping notifications when the queue is
+// full. It defaults to DropNewest.
+func NotifyDrop(p NotifyDropPolicy) NotifyOption {
+	return func(cfg *notifyConfig) {
+		cfg.dropPolicy = p
+	}
+}
+
+// NotifyCoalesce makes the AsyncNotifiee discard notifications that cancel
+// each other out while they are queued: a Disconnected notification for a
+// connection whose Connected notification wasn't delivered yet, or a
+// ListenClose notification for an address whose Listen notification wasn't
+// delivered yet. Neither notification is delivered.
+func NotifyCoalesce() NotifyOption {
+	return func(cfg *notifyConfig) {
+		cfg.coalesce = true
+	}
+}
+
+type notificationKind int
+
+const (
+	notifyListen notificationKind = iota
+	notifyListenClose
+	notifyConnected
+	notifyDisconnected
+)
+
+type notification struct {
+	kind notificationKind
+	net  Network
+	conn Conn
+	addr ma.Multiaddr
+}
+
+// closes returns whether n is a Disconnected or ListenClose notification.
+func (n notification) closes() bool {
+	return n.kind == notifyDisconnected || n.kind == notifyListenClose
+}
+
+// cancels returns whether n and other cancel each other out.
+func (n notification) cancels(other notification) bool {
+	switch n.kind {
+	case notifyDisconnected:
+		return other.kind == notifyConnected && other.conn == n.conn
+	case notifyListenClose:
+		return other.kind == notifyListen && other.addr.Equal(n.addr)
+	default:
+		return false
+	}
+}
+
+// notificationQueue is a FIFO ring buffer of notifications.
+type notificationQueue struct {
+	buf  []notification
+	head int
+	len  int
+}
+
+func (q *notificationQueue) at(i int) *notification {
+	return &q.buf[(q.head+i)%len(q.buf)]
+}
+
+func (q *notificationQueue) push(n notification) {
+	if q.len == len(q.buf) {
+		buf := make([]notification, max(2*len(q.buf), 1))
+		for i := 0; i < q.len; i++ {
+			buf[i] = *q.at(i)
+		}
+		q.buf, q.head = buf, 0
+	}
+	*q.at(q.len) = n
+	q.len++
+}
+
+func (q *notificationQueue) pop() notification {
+	n := *q.at(0)
+	*q.at(0) = notification{}
+	q.head = (q.head + 1) % len(q.buf)
+	q.len--
+	return n
+}
+
+// remove removes the i-th notification from the queue.
+func (q *notificationQueue) remove(i int) {
+	for ; i < q.len-1; i++ {
+		*q.at(i) = *q.at(i + 1)
+	}
+	*q.at(q.len - 1) = notification{}
+	q.len--
+}
+
+// AsyncNotifiee is a Notifiee that delivers notifications to another Notifiee
+// on a dedicated goroutine, so that a slow Notifiee doesn't block the network.
+// Notifications are delivered in order. When more notifications arrive than
+// the queue can hold, notifications are dropped according to the drop policy.
+type AsyncNotifiee struct {
+	n   Notifiee
+	cfg notifyConfig
+
+	mx    sync.Mutex
+	queue notificationQueue
+	// droppedConns and droppedListens track the dropped Connected and Listen
+	// notifications, to discard the matching Disconnected and ListenClose
+	// notifications.
+	droppedConns   map[Conn]struct{}
+	droppedListens map[string]int
+	closed         bool
+
+	dropped, coalesced atomic.Uint64
+
+	wake chan struct{} // cap: 1
+	done chan struct{}
+}
+
+var _ Notifiee = (*AsyncNotifiee)(nil)
+
+// NotifyAsync returns a Notifiee that delivers notifications to n
+// asynchronously. Close it after unregistering it from the network to stop its
+// goroutine.
+func NotifyAsync(n Notifiee, opts ...NotifyOption) *AsyncNotifiee {
+	cfg := notifyConfig{queueSize: 128}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	an := &AsyncNotifiee{
+		n:              n,
+		cfg:            cfg,
+		queue:          notificationQueue{buf: make([]notification, cfg.queueSize)},
+		droppedConns:   make(map[Conn]struct{}),
+		droppedListens: make(map[string]int),
+		wake:           make(chan struct{}, 1),
+		done:           make(chan struct{}),
+	}
+	go an.loop()
+	return an
+}
+
+// Listen queues a Listen notification.
+func (an *AsyncNotifiee) Listen(n Network, a ma.Multiaddr) {
+	an.push(notification{kind: notifyListen, net: n, addr: a})
+}
+
+// ListenClose queues a ListenClose notification.
+func (an *AsyncNotifiee) ListenClose(n Network, a ma.Multiaddr) {
+	an.push(notification{kind: notifyListenClose, net: n, addr: a})
+}
+
+// Connected queues a Connected notification.
+func (an *AsyncNotifiee) Connected(n Network, c Conn) {
+	an.push(notification{kind: notifyConnected, net: n, conn: c})
+}
+
+// Disconnected queues a Disconnected notification.
+func (an *AsyncNotifiee) Disconnected(n Network, c Conn) {
+	an.push(notification{kind: notifyDisconnected, net: n, conn: c})
+}
+
+// Dropped returns the number of notifications that were dropped because the
+// queue was full, including the Disconnected and ListenClose notifications
+// discarded because their Connected or Listen notification was dropped.
+func (an *AsyncNotifiee) Dropped() uint64 {
+	return an.dropped.Load()
+}
+
+// Coalesced returns the number of notifications that were discarded because
+// they cancelled each other out. See NotifyCoalesce.
+func (an *AsyncNotifiee) Coalesced() uint64 {
+	return an.coalesced.Load()
+}
+
+// Close discards the queued notifications and waits for the notification that
+// is being delivered, if any, to return. Notifications received after Close
+// are ignored.
+func (an *AsyncNotifiee) Close() error {
+	an.mx.Lock()
+	if an.closed {
+		an.mx.Unlock()
+		return nil
+	}
+	an.closed = true
+	an.queue = notificationQueue{}
+	an.mx.Unlock()
+	close(an.wake)
+	<-an.done
+	return nil
+}
+
+func (an *AsyncNotifiee) push(n notification) {
+	an.mx.Lock()
+	defer an.mx.Unlock()
+	if an.closed {
+		return
+	}
+	if n.closes() && an.forgetDropped(n) {
+		an.dropped.Add(1)
+		return
+	}
+	if an.cfg.coalesce && n.closes() {
+		for i := 0; i < an.queue.len; i++ {
+			if n.cancels(*an.queue.at(i)) {
+				an.queue.remove(i)
+				an.coalesced.Add(2)
+				return
+			}
+		}
+	}
+	if !n.closes() && an.queue.len >= an.cfg.queueSize {
+		if an.cfg.dropPolicy != DropOldest {
+			an.drop(n)
+			return
+		}
+		oldest := -1
+		for i := 0; i < an.queue.len; i++ {
+			if !an.queue.at(i).closes() {
+				oldest = i
+				break
+			}
+		}
+		if oldest < 0 {
+			an.drop(n)
+			return
+		}
+		an.dropQueued(oldest)
+	}
+	an.queue.push(n)
+	select {
+	case an.wake <- struct{}{}:
+	default:
+	}
+}
+
+// drop records that the Connected or Listen notification n was dropped.
+func (an *AsyncNotifiee) drop(n notification) {
+	an.dropped.Add(1)
+	switch n.kind {
+	case notifyConnected:
+		an.droppedConns[n.conn] = struct{}{}
+	case notifyListen:
+		an.droppedListens[string(n.addr.Bytes())]++
+	}
+}
+
+// dropQueued drops the i-th queued notification, which is a Connected or
+// Listen notification, along with its queued Disconnected or ListenClose
+// notification.
+func (an *AsyncNotifiee) dropQueued(i int) {
+	n := *an.queue.at(i)
+	an.queue.remove(i)
+	for j := i; j < an.queue.len; j++ {
+		if an.queue.at(j).cancels(n) {
+			an.queue.remove(j)
+			an.dropped.Add(2)
+			return
+		}
+	}
+	an.drop(n)
+}
+
+// forgetDropped returns whether the Connected or Listen notification matching
+// the Disconnected or ListenClose notification n was dropped, and forgets it.
+func (an *AsyncNotifiee) forgetDropped(n notification) bool {
+	switch n.kind {
+	case notifyDisconnected:
+		if _, ok := an.droppedConns[n.conn]; ok {
+			delete(an.droppedConns, n.conn)
+			return true
+		}
+	case notifyListenClose:
+		k := string(n.addr.Bytes())
+		if an.droppedListens[k] > 0 {
+			an.droppedListens[k]--
+			if an.droppedListens[k] == 0 {
+				delete(an.droppedListens, k)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func (an *AsyncNotifiee) loop() {
+	defer close(an.done)
+	for range an.wake {
+		for {
+			an.mx.Lock()
+			if an.queue.len == 0 {
+				an.mx.Unlock()
+				break
+			}
+			n := an.queue.pop()
+			an.mx.Unlock()
+			an.deliver(n)
+		}
+	}
+}
+
+func (an *AsyncNotifiee) deliver(n notification) {
+	switch n.kind {
+	case notifyListen:
+		an.n.Listen(n.net, n.addr)
+	case notifyListenClose:
+		an.n.ListenClose(n.net, n.addr)
+	case notifyConnected:
+		an.n.Connected(n.net, n.conn)
+	case notifyDisconnected:
+		an.n.Disconnected(n.net, n.conn)
+	}
+}
@@ -1,6 +1,7 @@
 package network
 
 import (
+	"slices"
 	"testing"
 
 	ma "github.com/multiformats/go-multiaddr"
@@ -85,3 +86,176 @@ func TestDisconnected(T *testing.T) {
 		T.Fatal("Disconnected should have been called")
 	}
 }
+
+type testConn struct {
+	Conn
+	id int
+}
+
+func TestNotifyAsync(t *testing.T) {
+	unblock := make(chan struct{})
+	delivered := make(chan int, 10)
+	an := NotifyAsync(&NotifyBundle{
+		ConnectedF: func(_ Network, c Conn) {
+			<-unblock
+			delivered <- c.(*testConn).id
+		},
+	}, NotifyQueueSize(2))
+	defer an.Close()
+
+	// The first notification is picked up by the delivery goroutine and
+	// blocks it. The next two are queued, and the last one is dropped.
+	an.Connected(nil, &testConn{id: 1})
+	for an.Dropped() == 0 {
+		an.Connected(nil, &testConn{id: 2})
+		an.Connected(nil, &testConn{id: 3})
+		an.Connected(nil, &testConn{id: 4})
+	}
+	close(unblock)
+	if id := <-delivered; id != 1 {
+		t.Fatalf("expected conn 1 to be delivered first, got %d", id)
+	}
+	if id := <-delivered; id != 2 {
+		t.Fatalf("expected conn 2 to be delivered second, got %d", id)
+	}
+}
+
+func TestNotifyAsyncDropOldest(t *testing.T) {
+	unblock := make(chan struct{})
+	var delivered []int
+	done := make(chan struct{})
+	an := NotifyAsync(&NotifyBundle{
+		ConnectedF: func(_ Network, c Conn) {
+			<-unblock
+			delivered = append(delivered, c.(*testConn).id)
+		},
+		ListenF: func(Network, ma.Multiaddr) { close(done) },
+	}, NotifyQueueSize(2), NotifyDrop(DropOldest))
+	defer an.Close()
+
+	an.Connected(nil, &testConn{id: 0})
+	// Wait for the first notification to be taken off the queue.
+	for {
+		an.mx.Lock()
+		n := an.queue.len
+		an.mx.Unlock()
+		if n == 0 {
+			break
+		}
+	}
+	for i := 1; i <= 4; i++ {
+		an.Connected(nil, &testConn{id: i})
+	}
+	an.Listen(nil, ma.StringCast("/ip4/127.0.0.1/tcp/1"))
+	close(unblock)
+	<-done
+	if an.Dropped() != 3 {
+		t.Fatalf("expected 3 dropped notifications, got %d", an.Dropped())
+	}
+	if len(delivered) != 2 || delivered[0] != 0 || delivered[1] != 4 {
+		t.Fatalf("unexpected delivered notifications: %v", delivered)
+	}
+}
+
+func TestNotifyAsyncCoalesce(t *testing.T) {
+	unblock := make(chan struct{})
+	var connected, disconnected []int
+	done := make(chan struct{})
+	an := NotifyAsync(&NotifyBundle{
+		ConnectedF: func(_ Network, c Conn) {
+			<-unblock
+			connected = append(connected, c.(*testConn).id)
+		},
+		DisconnectedF: func(_ Network, c Conn) {
+			disconnected = append(disconnected, c.(*testConn).id)
+		},
+		ListenF: func(Network, ma.Multiaddr) { close(done) },
+	}, NotifyCoalesce())
+	defer an.Close()
+
+	c1, c2 := &testConn{id: 1}, &testConn{id: 2}
+	an.Connected(nil, c1)
+	// Wait for the first notification to be taken off the queue.
+	for {
+		an.mx.Lock()
+		n := an.queue.len
+		an.mx.Unlock()
+		if n == 0 {
+			break
+		}
+	}
+	an.Connected(nil, c2)
+	an.Disconnected(nil, c2)
+	an.Disconnected(nil, c1)
+	an.Listen(nil, ma.StringCast("/ip4/127.0.0.1/tcp/1"))
+	close(unblock)
+	<-done
+	if an.Coalesced() != 2 {
+		t.Fatalf("expected 2 coalesced notifications, got %d", an.Coalesced())
+	}
+	if len(connected) != 1 || connected[0] != 1 || len(disconnected) != 1 || disconnected[0] != 1 {
+		t.Fatalf("unexpected notifications: connected %v, disconnected %v", connected, disconnected)
+	}
+}
+
+func TestNotifyAsyncKeepsCloseNotifications(t *testing.T) {
+	for _, policy := range []NotifyDropPolicy{DropNewest, DropOldest} {
+		unblock := make(chan struct{})
+		var connected, disconnected []int
+		done := make(chan struct{})
+		an := NotifyAsync(&NotifyBundle{
+			ConnectedF: func(_ Network, c Conn) {
+				<-unblock
+				connected = append(connected, c.(*testConn).id)
+			},
+			DisconnectedF: func(_ Network, c Conn) {
+				if c == nil {
+					close(done)
+					return
+				}
+				disconnected = append(disconnected, c.(*testConn).id)
+			},
+		}, NotifyQueueSize(2), NotifyDrop(policy))
+
+		conns := make([]*testConn, 5)
+		for i := range conns {
+			conns[i] = &testConn{id: i}
+		}
+		an.Connected(nil, conns[0])
+		// Wait for the first notification to be taken off the queue.
+		for {
+			an.mx.Lock()
+			n := an.queue.len
+			an.mx.Unlock()
+			if n == 0 {
+				break
+			}
+		}
+		an.Connected(nil, conns[1])
+		an.Connected(nil, conns[2])
+		// The queue is full, but Disconnected notifications are still queued.
+		an.Disconnected(nil, conns[0])
+		an.Disconnected(nil, conns[1])
+		// With DropNewest, this is dropped, and so is its Disconnected
+		// notification.
+		an.Connected(nil, conns[3])
+		an.Disconnected(nil, conns[3])
+		// Listen notifications would be dropped, so signal the end with a
+		// Disconnected notification.
+		an.Disconnected(nil, nil)
+		close(unblock)
+		<-done
+		an.Close()
+
+		wantConnected, wantDisconnected := []int{0, 1, 2}, []int{0, 1}
+		if policy == DropOldest {
+			// Connected for conn 1 is the oldest queued Connected notification.
+			// It's dropped along with its Disconnected notification, making
+			// room for conn 3.
+			wantConnected, wantDisconnected = []int{0, 2, 3}, []int{0, 3}
+		}
+		if !slices.Equal(connected, wantConnected) || !slices.Equal(disconnected, wantDisconnected) {
+			t.Fatalf("unexpected notifications with policy %d: connected %v, disconnected %v", policy, connected, disconnected)
+		}
+	}
+}
@@ -176,6 +176,28 @@ type ResourceScope interface {
 	BeginSpan() (ResourceScopeSpan, error)
 }
 
+// ScopeLimit is the resource limit of a scope.
+type ScopeLimit interface {
+	// GetMemoryLimit returns the memory limit.
+	GetMemoryLimit() int64
+	// GetStreamLimit returns the stream limit, for inbound or outbound streams.
+	GetStreamLimit(Direction) int
+	// GetStreamTotalLimit returns the total stream limit.
+	GetStreamTotalLimit() int
+	// GetConnLimit returns the connection limit, for inbound or outbound connections.
+	GetConnLimit(Direction) int
+	// GetConnTotalLimit returns the total connection limit.
+	GetConnTotalLimit() int
+	// GetFDLimit returns the file descriptor limit.
+	GetFDLimit() int
+}
+
+// ResourceScopeLimitViewer is an optional interface of a ResourceScope that
+// exposes its limit.
+type ResourceScopeLimitViewer interface {
+	ScopeLimit() ScopeLimit
+}
+
 // ResourceScopeSpan is a ResourceScope with a delimited span.
 // Span scopes are control flow delimited and release all their associated resources
 // when the programmer calls Done.
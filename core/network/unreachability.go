@@ -0,0 +1,99 @@
+package network
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// UnreachabilityReporter is implemented by Networks that can explain why a
+// peer is unreachable.
+type UnreachabilityReporter interface {
+	// UnreachabilityReport returns what the network knows about its recent
+	// failures to connect to p.
+	UnreachabilityReport(p peer.ID) UnreachabilityReport
+}
+
+// UnreachabilityReport combines the information a Network has about why a
+// peer is unreachable: the dial backoffs of its addresses and the errors of
+// the last failed dial, including connection gater denials and resource limit
+// rejections.
+type UnreachabilityReport struct {
+	Peer          peer.ID
+	Connectedness Connectedness
+
+	// LastDialFailure is when the last dial of the peer failed. It is zero if
+	// no dial failed since the last successful one.
+	LastDialFailure time.Time
+	// DialError is the error of the last failed dial that isn't specific to
+	// an address, e.g. because the peer has no addresses.
+	DialError error
+	// GaterDenied is set if the connection gater denied dialing the peer.
+	GaterDenied bool
+
+	// Addrs are the addresses of the peer, and those the last failed dial
+	// tried.
+	Addrs []AddrReachability
+}
+
+// AddrReachability is the state of an address in an UnreachabilityReport.
+type AddrReachability struct {
+	Addr ma.Multiaddr
+	// BackoffUntil is set if dials of the address are backed off until then.
+	BackoffUntil time.Time
+	// Error is the error of the address in the last failed dial, if any.
+	Error error
+	// GaterDenied is set if the connection gater refused the address or the
+	// connection made to it.
+	GaterDenied bool
+	// ResourceLimited is set if the resource manager refused the connection.
+	ResourceLimited bool
+}
+
+// Unreachable reports whether the report has any reason why the peer is
+// unreachable.
+func (r *UnreachabilityReport) Unreachable() bool {
+	if r.DialError != nil || r.GaterDenied {
+		return true
+	}
+	for _, a := range r.Addrs {
+		if a.Error != nil || !a.BackoffUntil.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *UnreachabilityReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s", r.Peer, r.Connectedness)
+	if !r.LastDialFailure.IsZero() {
+		fmt.Fprintf(&b, ", last dial failed at %s", r.LastDialFailure.Format(time.RFC3339))
+	}
+	if r.GaterDenied {
+		b.WriteString(", denied by gater")
+	}
+	if r.DialError != nil {
+		fmt.Fprintf(&b, ": %s", r.DialError)
+	}
+	for _, a := range r.Addrs {
+		fmt.Fprintf(&b, "\n  * %s", a.Addr)
+		if !a.BackoffUntil.IsZero() {
+			fmt.Fprintf(&b, " [backoff until %s]", a.BackoffUntil.Format(time.RFC3339))
+		}
+		if a.GaterDenied {
+			b.WriteString(" [denied by gater]")
+		}
+		if a.ResourceLimited {
+			b.WriteString(" [resource limit]")
+		}
+		if a.Error != nil {
+			fmt.Fprintf(&b, " %s", a.Error)
+		}
+	}
+	return b.String()
+}
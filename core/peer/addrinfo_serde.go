@@ -1,13 +1,22 @@
 package peer
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
 
 	"github.com/libp2p/go-libp2p/core/internal/catch"
 
 	ma "github.com/multiformats/go-multiaddr"
 )
 
+// URIScheme is the scheme of the URI form of AddrInfos and signed peer
+// records, e.g. "libp2p:12D3KooW...?addr=/ip4/1.2.3.4/tcp/4001".
+const URIScheme = "libp2p"
+
 // Helper struct for decoding as we can't unmarshal into an interface (Multiaddr).
 type addrInfoJson struct {
 	ID    ID
@@ -33,6 +42,9 @@ func (pi *AddrInfo) UnmarshalJSON(b []byte) (err error) {
 	if err := json.Unmarshal(b, &data); err != nil {
 		return err
 	}
+	if err := data.ID.Validate(); err != nil {
+		return err
+	}
 	addrs := make([]ma.Multiaddr, len(data.Addrs))
 	for i, addr := range data.Addrs {
 		maddr, err := ma.NewMultiaddr(addr)
@@ -46,3 +58,150 @@ func (pi *AddrInfo) UnmarshalJSON(b []byte) (err error) {
 	pi.Addrs = addrs
 	return nil
 }
+
+// MarshalCBOR returns the CBOR encoding of the AddrInfo: a map with the keys
+// "ID", holding the binary peer ID, and "Addrs", holding an array of binary
+// multiaddrs. The encoding is deterministic.
+func (pi AddrInfo) MarshalCBOR() (res []byte, err error) {
+	defer func() { catch.HandlePanic(recover(), &err, "libp2p addr info marshal") }()
+
+	if err := pi.ID.Validate(); err != nil {
+		return nil, err
+	}
+	b := cborAppendHead(nil, cborMajorMap, 2)
+	b = cborAppendBytes(b, cborMajorText, []byte("ID"))
+	b = cborAppendBytes(b, cborMajorBytes, []byte(pi.ID))
+	b = cborAppendBytes(b, cborMajorText, []byte("Addrs"))
+	b = cborAppendHead(b, cborMajorArray, uint64(len(pi.Addrs)))
+	for _, addr := range pi.Addrs {
+		b = cborAppendBytes(b, cborMajorBytes, addr.Bytes())
+	}
+	return b, nil
+}
+
+// UnmarshalCBOR decodes an AddrInfo encoded by MarshalCBOR. Encodings that
+// are not deterministic, or that contain additional fields, are rejected.
+func (pi *AddrInfo) UnmarshalCBOR(b []byte) (err error) {
+	defer func() { catch.HandlePanic(recover(), &err, "libp2p addr info unmarshal") }()
+
+	r := cborReader{b: b}
+	n, err := r.length(cborMajorMap)
+	if err != nil {
+		return err
+	}
+	if n != 2 {
+		return fmt.Errorf("cbor: expected a map with 2 entries, got %d", n)
+	}
+	if err := r.key("ID"); err != nil {
+		return err
+	}
+	idBytes, err := r.bytes(cborMajorBytes)
+	if err != nil {
+		return err
+	}
+	id, err := IDFromBytes(idBytes)
+	if err != nil {
+		return err
+	}
+	if err := r.key("Addrs"); err != nil {
+		return err
+	}
+	n, err = r.length(cborMajorArray)
+	if err != nil {
+		return err
+	}
+	addrs := make([]ma.Multiaddr, n)
+	for i := range addrs {
+		addrBytes, err := r.bytes(cborMajorBytes)
+		if err != nil {
+			return err
+		}
+		if addrs[i], err = ma.NewMultiaddrBytes(addrBytes); err != nil {
+			return err
+		}
+	}
+	if err := r.done(); err != nil {
+		return err
+	}
+
+	info := AddrInfo{ID: id, Addrs: addrs}
+	// Lengths are the only thing that can be encoded in more than one way.
+	canonical, err := info.MarshalCBOR()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(canonical, b) {
+		return errors.New("cbor: non-deterministic encoding")
+	}
+	*pi = info
+	return nil
+}
+
+// URI returns the URI form of the AddrInfo, e.g.
+// "libp2p:12D3KooW...?addr=/ip4/1.2.3.4/tcp/4001&addr=/ip4/1.2.3.4/udp/4001/quic-v1".
+func (pi AddrInfo) URI() string {
+	var b strings.Builder
+	b.WriteString(URIScheme + ":" + pi.ID.String())
+	for i, addr := range pi.Addrs {
+		if i == 0 {
+			b.WriteString("?")
+		} else {
+			b.WriteString("&")
+		}
+		b.WriteString("addr=" + uriQueryEscape(addr.String()))
+	}
+	return b.String()
+}
+
+// AddrInfoFromURI parses the URI form of an AddrInfo, as returned by
+// AddrInfo.URI. URIs with other parameters are rejected.
+func AddrInfoFromURI(s string) (*AddrInfo, error) {
+	id, query, err := parseURI(s)
+	if err != nil {
+		return nil, err
+	}
+	info := &AddrInfo{ID: id}
+	for k, vs := range query {
+		if k != "addr" {
+			return nil, fmt.Errorf("unexpected URI parameter %q", k)
+		}
+		for _, v := range vs {
+			addr, err := ma.NewMultiaddr(v)
+			if err != nil {
+				return nil, err
+			}
+			info.Addrs = append(info.Addrs, addr)
+		}
+	}
+	return info, nil
+}
+
+// parseURI parses a URI of the form "libp2p:<peer ID>?<query>".
+func parseURI(s string) (ID, url.Values, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", nil, err
+	}
+	if u.Scheme != URIScheme {
+		return "", nil, fmt.Errorf("unexpected URI scheme %q, expected %q", u.Scheme, URIScheme)
+	}
+	if u.Opaque == "" || u.Fragment != "" || u.RawFragment != "" || u.ForceQuery {
+		return "", nil, fmt.Errorf("invalid %s URI: %s", URIScheme, s)
+	}
+	id, err := Decode(u.Opaque)
+	if err != nil {
+		return "", nil, err
+	}
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return "", nil, err
+	}
+	return id, query, nil
+}
+
+// uriQueryEscape escapes s for use in a query parameter. Slashes and colons
+// are valid in queries, and are kept to keep multiaddrs readable.
+func uriQueryEscape(s string) string {
+	s = url.QueryEscape(s)
+	return strings.NewReplacer("%2F", "/", "%3A", ":").Replace(s)
+}
@@ -1,6 +1,7 @@
 package peer_test
 
 import (
+	"slices"
 	"testing"
 
 	. "github.com/libp2p/go-libp2p/core/peer"
@@ -164,3 +165,74 @@ func TestAddrInfoJSON(t *testing.T) {
 		t.Fatalf("expected addrs to match %v, got %v", maddrFull, addrInfo.Addrs)
 	}
 }
+
+func TestAddrInfoJSONInvalid(t *testing.T) {
+	var ai AddrInfo
+	require.ErrorIs(t, ai.UnmarshalJSON([]byte(`{"Addrs":[]}`)), ErrEmptyPeerID)
+	require.Error(t, ai.UnmarshalJSON([]byte(`{"ID":"`+testID.String()+`","Addrs":["/foo"]}`)))
+}
+
+func TestAddrInfoCBOR(t *testing.T) {
+	ai := AddrInfo{ID: testID, Addrs: []ma.Multiaddr{maddrTpt, ma.StringCast("/ip6/::1/udp/1234/quic-v1")}}
+	b, err := ai.MarshalCBOR()
+	require.NoError(t, err)
+	var decoded AddrInfo
+	require.NoError(t, decoded.UnmarshalCBOR(b))
+	require.Equal(t, ai.ID, decoded.ID)
+	require.Len(t, decoded.Addrs, 2)
+	for i := range ai.Addrs {
+		require.True(t, ai.Addrs[i].Equal(decoded.Addrs[i]))
+	}
+
+	empty, err := AddrInfo{ID: testID}.MarshalCBOR()
+	require.NoError(t, err)
+	require.NoError(t, decoded.UnmarshalCBOR(empty))
+	require.Equal(t, testID, decoded.ID)
+	require.Empty(t, decoded.Addrs)
+
+	_, err = AddrInfo{}.MarshalCBOR()
+	require.ErrorIs(t, err, ErrEmptyPeerID)
+
+	// Truncated data and trailing data.
+	require.Error(t, decoded.UnmarshalCBOR(b[:len(b)-1]))
+	require.Error(t, decoded.UnmarshalCBOR(append(b, 0)))
+	// A non-minimal map length (0xb8 0x02 instead of 0xa2).
+	require.Error(t, decoded.UnmarshalCBOR(append([]byte{0xb8, 0x02}, b[1:]...)))
+	// An indefinite length map.
+	require.Error(t, decoded.UnmarshalCBOR(append([]byte{0xbf}, b[1:]...)))
+	// A huge array length.
+	huge := append(slices.Clone(empty[:len(empty)-1]), 0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+	require.Error(t, decoded.UnmarshalCBOR(huge))
+}
+
+func TestAddrInfoURI(t *testing.T) {
+	ai := AddrInfo{ID: testID, Addrs: []ma.Multiaddr{maddrTpt, ma.StringCast("/dns/example.com/tcp/443/wss")}}
+	uri := ai.URI()
+	require.Equal(t, "libp2p:"+testID.String()+"?addr=/ip4/127.0.0.1/tcp/1234&addr=/dns/example.com/tcp/443/wss", uri)
+	decoded, err := AddrInfoFromURI(uri)
+	require.NoError(t, err)
+	require.Equal(t, testID, decoded.ID)
+	require.Len(t, decoded.Addrs, 2)
+	for i := range ai.Addrs {
+		require.True(t, ai.Addrs[i].Equal(decoded.Addrs[i]))
+	}
+
+	decoded, err = AddrInfoFromURI(AddrInfo{ID: testID}.URI())
+	require.NoError(t, err)
+	require.Equal(t, testID, decoded.ID)
+	require.Empty(t, decoded.Addrs)
+
+	for _, s := range []string{
+		"",
+		"libp2p:",
+		"ipfs:" + testID.String(),
+		"libp2p://" + testID.String(),
+		"libp2p:foobar",
+		"libp2p:" + testID.String() + "?addr=/foo",
+		"libp2p:" + testID.String() + "?other=1",
+		"libp2p:" + testID.String() + "#fragment",
+	} {
+		_, err := AddrInfoFromURI(s)
+		require.Error(t, err, s)
+	}
+}
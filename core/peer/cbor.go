@@ -0,0 +1,124 @@
+package peer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// This file implements the small subset of CBOR (RFC 8949) needed to encode
+// AddrInfos and signed peer records: byte strings, text strings, arrays and
+// maps with definite lengths. Encoding is always deterministic (RFC 8949,
+// section 4.2.1), and decoders reject anything else.
+
+const (
+	cborMajorBytes byte = 2
+	cborMajorText  byte = 3
+	cborMajorArray byte = 4
+	cborMajorMap   byte = 5
+)
+
+var errCBORTruncated = errors.New("cbor: unexpected end of data")
+
+// cborAppendHead appends the head of a data item with the given major type
+// and argument, using the shortest possible encoding.
+func cborAppendHead(b []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= 0xff:
+		return append(b, major|24, byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, major|27), n)
+	}
+}
+
+func cborAppendBytes(b []byte, major byte, data []byte) []byte {
+	return append(cborAppendHead(b, major, uint64(len(data))), data...)
+}
+
+type cborReader struct {
+	b []byte
+}
+
+// head reads the head of a data item of the expected major type, and
+// returns its argument.
+func (r *cborReader) head(major byte) (uint64, error) {
+	if len(r.b) == 0 {
+		return 0, errCBORTruncated
+	}
+	if m := r.b[0] >> 5; m != major {
+		return 0, fmt.Errorf("cbor: unexpected major type %d, expected %d", m, major)
+	}
+	info := r.b[0] & 0x1f
+	r.b = r.b[1:]
+	if info < 24 {
+		return uint64(info), nil
+	}
+	if info > 27 {
+		// Indefinite lengths and reserved values.
+		return 0, fmt.Errorf("cbor: unsupported additional information %d", info)
+	}
+	size := 1 << (info - 24)
+	if len(r.b) < size {
+		return 0, errCBORTruncated
+	}
+	var n uint64
+	for _, c := range r.b[:size] {
+		n = n<<8 | uint64(c)
+	}
+	r.b = r.b[size:]
+	return n, nil
+}
+
+// bytes reads a byte or text string.
+func (r *cborReader) bytes(major byte) ([]byte, error) {
+	n, err := r.head(major)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(r.b)) {
+		return nil, errCBORTruncated
+	}
+	data := r.b[:n]
+	r.b = r.b[n:]
+	return data, nil
+}
+
+// length reads the head of an array or a map. Since every item takes at
+// least one byte, lengths exceeding the remaining data are rejected before
+// the caller allocates anything.
+func (r *cborReader) length(major byte) (int, error) {
+	n, err := r.head(major)
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(len(r.b)) {
+		return 0, errCBORTruncated
+	}
+	return int(n), nil
+}
+
+// key reads a text string map key, and checks that it is the expected key.
+func (r *cborReader) key(expected string) error {
+	k, err := r.bytes(cborMajorText)
+	if err != nil {
+		return err
+	}
+	if string(k) != expected {
+		return fmt.Errorf("cbor: unexpected key %q, expected %q", k, expected)
+	}
+	return nil
+}
+
+func (r *cborReader) done() error {
+	if len(r.b) != 0 {
+		return fmt.Errorf("cbor: %d bytes of trailing data", len(r.b))
+	}
+	return nil
+}
@@ -156,6 +156,12 @@ func (r *PeerRecord) Codec() []byte {
 	return PeerRecordEnvelopePayloadType
 }
 
+// Sequence returns the Seq field of the record, so that a record.Consumer can
+// reject outdated PeerRecords.
+func (r *PeerRecord) Sequence() uint64 {
+	return r.Seq
+}
+
 // UnmarshalRecord parses a PeerRecord from a byte slice.
 // This method is called automatically when consuming a record.Envelope
 // whose PayloadType indicates that it contains a PeerRecord.
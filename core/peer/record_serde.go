@@ -0,0 +1,184 @@
+package peer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/internal/catch"
+	"github.com/libp2p/go-libp2p/core/record"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// SignedPeerRecord is a PeerRecord together with the Envelope it was signed
+// in. It can be marshaled to JSON, CBOR and a URI, for exchanging peer
+// records with applications that don't speak protobuf.
+//
+// Unmarshaling always verifies the envelope's signature, and that the
+// record was signed by the peer it describes.
+type SignedPeerRecord struct {
+	Envelope *record.Envelope
+	Record   *PeerRecord
+}
+
+// NewSignedPeerRecord returns the SignedPeerRecord for an envelope containing
+// a peer record. It returns an error if the signature is invalid, or if the
+// record wasn't signed by the peer it describes.
+func NewSignedPeerRecord(env *record.Envelope) (*SignedPeerRecord, error) {
+	b, err := env.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return ConsumeSignedPeerRecord(b)
+}
+
+// ConsumeSignedPeerRecord unmarshals a serialized envelope containing a peer
+// record, and validates it like NewSignedPeerRecord.
+func ConsumeSignedPeerRecord(data []byte) (*SignedPeerRecord, error) {
+	rec := &PeerRecord{}
+	env, err := record.ConsumeTypedEnvelope(data, rec)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := IDFromPublicKey(env.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if signer != rec.PeerID {
+		return nil, fmt.Errorf("peer record for %s was signed by %s", rec.PeerID, signer)
+	}
+	return &SignedPeerRecord{Envelope: env, Record: rec}, nil
+}
+
+// AddrInfo returns the peer ID and addresses of the record.
+func (r SignedPeerRecord) AddrInfo() AddrInfo {
+	return AddrInfo{ID: r.Record.PeerID, Addrs: r.Record.Addrs}
+}
+
+// signedPeerRecordJSON is the JSON form of a SignedPeerRecord. ID, Addrs and
+// Seq are informational, they must match the signed record.
+type signedPeerRecordJSON struct {
+	ID       ID
+	Addrs    []string
+	Seq      uint64
+	Envelope []byte
+}
+
+func (r SignedPeerRecord) MarshalJSON() (res []byte, err error) {
+	defer func() { catch.HandlePanic(recover(), &err, "libp2p signed peer record marshal") }()
+
+	env, err := r.Envelope.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, len(r.Record.Addrs))
+	for i, addr := range r.Record.Addrs {
+		addrs[i] = addr.String()
+	}
+	return json.Marshal(&signedPeerRecordJSON{
+		ID:       r.Record.PeerID,
+		Addrs:    addrs,
+		Seq:      r.Record.Seq,
+		Envelope: env,
+	})
+}
+
+func (r *SignedPeerRecord) UnmarshalJSON(b []byte) (err error) {
+	defer func() { catch.HandlePanic(recover(), &err, "libp2p signed peer record unmarshal") }()
+
+	var data signedPeerRecordJSON
+	if err := json.Unmarshal(b, &data); err != nil {
+		return err
+	}
+	spr, err := ConsumeSignedPeerRecord(data.Envelope)
+	if err != nil {
+		return err
+	}
+	rec := spr.Record
+	if data.ID != rec.PeerID || data.Seq != rec.Seq || len(data.Addrs) != len(rec.Addrs) {
+		return errors.New("signed peer record doesn't match its envelope")
+	}
+	for i, s := range data.Addrs {
+		addr, err := ma.NewMultiaddr(s)
+		if err != nil {
+			return err
+		}
+		if !addr.Equal(rec.Addrs[i]) {
+			return errors.New("signed peer record doesn't match its envelope")
+		}
+	}
+	*r = *spr
+	return nil
+}
+
+// MarshalCBOR returns the CBOR encoding of the record: a byte string holding
+// the serialized envelope.
+func (r SignedPeerRecord) MarshalCBOR() (res []byte, err error) {
+	defer func() { catch.HandlePanic(recover(), &err, "libp2p signed peer record marshal") }()
+
+	env, err := r.Envelope.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return cborAppendBytes(nil, cborMajorBytes, env), nil
+}
+
+// UnmarshalCBOR decodes a record encoded by MarshalCBOR.
+func (r *SignedPeerRecord) UnmarshalCBOR(b []byte) (err error) {
+	defer func() { catch.HandlePanic(recover(), &err, "libp2p signed peer record unmarshal") }()
+
+	cr := cborReader{b: b}
+	env, err := cr.bytes(cborMajorBytes)
+	if err != nil {
+		return err
+	}
+	if err := cr.done(); err != nil {
+		return err
+	}
+	if len(cborAppendHead(nil, cborMajorBytes, uint64(len(env))))+len(env) != len(b) {
+		return errors.New("cbor: non-deterministic encoding")
+	}
+	spr, err := ConsumeSignedPeerRecord(env)
+	if err != nil {
+		return err
+	}
+	*r = *spr
+	return nil
+}
+
+// URI returns the URI form of the record, e.g. "libp2p:12D3KooW...?spr=<envelope>",
+// where the envelope is encoded as unpadded base64url.
+func (r SignedPeerRecord) URI() (string, error) {
+	env, err := r.Envelope.Marshal()
+	if err != nil {
+		return "", err
+	}
+	return URIScheme + ":" + r.Record.PeerID.String() + "?spr=" + base64.RawURLEncoding.EncodeToString(env), nil
+}
+
+// SignedPeerRecordFromURI parses the URI form of a signed peer record, as
+// returned by SignedPeerRecord.URI. The peer ID of the URI must match the
+// record.
+func SignedPeerRecordFromURI(s string) (*SignedPeerRecord, error) {
+	id, query, err := parseURI(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(query) != 1 || len(query["spr"]) != 1 {
+		return nil, errors.New("expected exactly one spr URI parameter")
+	}
+	env, err := base64.RawURLEncoding.Strict().DecodeString(query.Get("spr"))
+	if err != nil {
+		return nil, err
+	}
+	spr, err := ConsumeSignedPeerRecord(env)
+	if err != nil {
+		return nil, err
+	}
+	if spr.Record.PeerID != id {
+		return nil, fmt.Errorf("URI peer ID %s doesn't match the record's peer ID %s", id, spr.Record.PeerID)
+	}
+	return spr, nil
+}
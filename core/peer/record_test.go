@@ -2,12 +2,16 @@ package peer_test
 
 import (
 	"bytes"
+	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/libp2p/go-libp2p/core/crypto"
 	. "github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/record"
 	"github.com/libp2p/go-libp2p/core/test"
+
+	"github.com/stretchr/testify/require"
 )
 
 func TestPeerRecordConstants(t *testing.T) {
@@ -65,3 +69,70 @@ func TestTimestampSeq(t *testing.T) {
 		last = next
 	}
 }
+
+func TestSignedPeerRecordSerialization(t *testing.T) {
+	priv, _, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+	require.NoError(t, err)
+	id, err := IDFromPrivateKey(priv)
+	require.NoError(t, err)
+	rec := &PeerRecord{PeerID: id, Addrs: test.GenerateTestAddrs(3), Seq: TimestampSeq()}
+	env, err := record.Seal(rec, priv)
+	require.NoError(t, err)
+	spr, err := NewSignedPeerRecord(env)
+	require.NoError(t, err)
+
+	check := func(t *testing.T, decoded *SignedPeerRecord) {
+		t.Helper()
+		require.True(t, rec.Equal(decoded.Record))
+		require.True(t, env.Equal(decoded.Envelope))
+		require.Equal(t, id, decoded.AddrInfo().ID)
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		b, err := json.Marshal(spr)
+		require.NoError(t, err)
+		var decoded SignedPeerRecord
+		require.NoError(t, json.Unmarshal(b, &decoded))
+		check(t, &decoded)
+
+		// The informational fields must match the envelope.
+		var m map[string]any
+		require.NoError(t, json.Unmarshal(b, &m))
+		m["Seq"] = rec.Seq + 1
+		b, err = json.Marshal(m)
+		require.NoError(t, err)
+		require.Error(t, json.Unmarshal(b, &decoded))
+	})
+
+	t.Run("CBOR", func(t *testing.T) {
+		b, err := spr.MarshalCBOR()
+		require.NoError(t, err)
+		var decoded SignedPeerRecord
+		require.NoError(t, decoded.UnmarshalCBOR(b))
+		check(t, &decoded)
+		require.Error(t, decoded.UnmarshalCBOR(append(b, 0)))
+	})
+
+	t.Run("URI", func(t *testing.T) {
+		uri, err := spr.URI()
+		require.NoError(t, err)
+		decoded, err := SignedPeerRecordFromURI(uri)
+		require.NoError(t, err)
+		check(t, decoded)
+
+		other := test.RandPeerIDFatal(t)
+		_, err = SignedPeerRecordFromURI(strings.Replace(uri, id.String(), other.String(), 1))
+		require.Error(t, err)
+		_, err = SignedPeerRecordFromURI(uri + "&addr=/ip4/1.2.3.4/tcp/1")
+		require.Error(t, err)
+	})
+
+	t.Run("signed by another peer", func(t *testing.T) {
+		otherPriv, _, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+		require.NoError(t, err)
+		env, err := record.Seal(rec, otherPriv)
+		require.NoError(t, err)
+		_, err = NewSignedPeerRecord(env)
+		require.Error(t, err)
+	})
+}
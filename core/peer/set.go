@@ -0,0 +1,176 @@
+package peer
+
+import (
+	"sync"
+	"time"
+)
+
+// Set is a thread-safe set of peers whose changes can be observed. Entries
+// can be added permanently or with a TTL, after which they're removed.
+//
+// A Set is meant to be the single source of truth for admission decisions,
+// e.g. an allowlist or denylist shared by the connection gater, the relay
+// service and stream handlers. Contains, Peers and Len can be called on a nil
+// *Set, which is empty.
+type Set struct {
+	mx      sync.Mutex
+	peers   map[ID]*setEntry
+	nextSub int
+	subs    map[int]func(p ID, added bool)
+
+	// notifyMx serializes notifications, so that subscribers observe the
+	// changes in the order they were made. It's taken before mx is released.
+	notifyMx sync.Mutex
+}
+
+type setEntry struct {
+	timer *time.Timer // nil for permanent entries
+}
+
+// NewSet returns an empty set.
+func NewSet() *Set {
+	return &Set{
+		peers: make(map[ID]*setEntry),
+		subs:  make(map[int]func(ID, bool)),
+	}
+}
+
+// Add adds p to the set permanently, replacing any TTL it was added with.
+func (s *Set) Add(p ID) {
+	s.add(p, 0)
+}
+
+// AddWithTTL adds p to the set until ttl elapses. Adding a peer that is
+// already in the set resets its TTL.
+func (s *Set) AddWithTTL(p ID, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	s.add(p, ttl)
+}
+
+func (s *Set) add(p ID, ttl time.Duration) {
+	s.mx.Lock()
+	old, found := s.peers[p]
+	if found && old.timer != nil {
+		old.timer.Stop()
+	}
+	e := &setEntry{}
+	if ttl > 0 {
+		e.timer = time.AfterFunc(ttl, func() { s.expire(p, e) })
+	}
+	s.peers[p] = e
+	if found {
+		s.mx.Unlock()
+		return
+	}
+	s.unlockAndNotify(p, true)
+}
+
+// expire removes p if it's still in the set with entry e.
+func (s *Set) expire(p ID, e *setEntry) {
+	s.mx.Lock()
+	if cur, ok := s.peers[p]; !ok || cur != e {
+		s.mx.Unlock()
+		return
+	}
+	delete(s.peers, p)
+	s.unlockAndNotify(p, false)
+}
+
+// Remove removes p from the set.
+func (s *Set) Remove(p ID) {
+	s.mx.Lock()
+	e, found := s.peers[p]
+	if !found {
+		s.mx.Unlock()
+		return
+	}
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+	delete(s.peers, p)
+	s.unlockAndNotify(p, false)
+}
+
+// Contains returns whether p is in the set.
+func (s *Set) Contains(p ID) bool {
+	if s == nil {
+		return false
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	_, ok := s.peers[p]
+	return ok
+}
+
+// Peers returns the peers in the set.
+func (s *Set) Peers() []ID {
+	if s == nil {
+		return nil
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	out := make([]ID, 0, len(s.peers))
+	for p := range s.peers {
+		out = append(out, p)
+	}
+	return out
+}
+
+// Len returns the number of peers in the set.
+func (s *Set) Len() int {
+	if s == nil {
+		return 0
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return len(s.peers)
+}
+
+// Subscribe registers f to be called whenever a peer is added to or removed
+// from the set, including when its TTL expires. f is called synchronously, in
+// the order the changes were made. It's called without holding the set's lock,
+// so it may read the set, but it must not modify it. Calling the returned
+// function unsubscribes f.
+func (s *Set) Subscribe(f func(p ID, added bool)) (cancel func()) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	id := s.nextSub
+	s.nextSub++
+	s.subs[id] = f
+	return func() {
+		s.mx.Lock()
+		defer s.mx.Unlock()
+		delete(s.subs, id)
+	}
+}
+
+func (s *Set) subscribers() []func(ID, bool) {
+	if len(s.subs) == 0 {
+		return nil
+	}
+	subs := make([]func(ID, bool), 0, len(s.subs))
+	for _, f := range s.subs {
+		subs = append(subs, f)
+	}
+	return subs
+}
+
+// unlockAndNotify releases s.mx and notifies the subscribers that p was added
+// or removed. s.mx must be held.
+func (s *Set) unlockAndNotify(p ID, added bool) {
+	subs := s.subscribers()
+	s.notifyMx.Lock()
+	defer s.notifyMx.Unlock()
+	s.mx.Unlock()
+	for _, f := range subs {
+		f(p, added)
+	}
+}
+
+// Admitted returns whether p passes an allowlist and a denylist: p must be in
+// allow, unless allow is nil, and must not be in deny. Either set may be nil.
+func Admitted(allow, deny *Set, p ID) bool {
+	return (allow == nil || allow.Contains(p)) && !deny.Contains(p)
+}
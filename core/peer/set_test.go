@@ -0,0 +1,105 @@
+package peer_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/stretchr/testify/require"
+)
+
+type setChange struct {
+	p     ID
+	added bool
+}
+
+func TestSet(t *testing.T) {
+	s := NewSet()
+	var mx sync.Mutex
+	var changes []setChange
+	cancel := s.Subscribe(func(p ID, added bool) {
+		mx.Lock()
+		defer mx.Unlock()
+		changes = append(changes, setChange{p, added})
+	})
+	getChanges := func() []setChange {
+		mx.Lock()
+		defer mx.Unlock()
+		return append([]setChange(nil), changes...)
+	}
+
+	s.Add("a")
+	s.Add("a")
+	s.AddWithTTL("b", 50*time.Millisecond)
+	require.True(t, s.Contains("a"))
+	require.True(t, s.Contains("b"))
+	require.ElementsMatch(t, []ID{"a", "b"}, s.Peers())
+
+	require.Eventually(t, func() bool { return !s.Contains("b") }, time.Second, 10*time.Millisecond)
+	s.Remove("a")
+	s.Remove("a")
+	require.Zero(t, s.Len())
+	require.Equal(t, []setChange{{"a", true}, {"b", true}, {"b", false}, {"a", false}}, getChanges())
+
+	// re-adding a peer permanently cancels its TTL
+	s.AddWithTTL("c", 50*time.Millisecond)
+	s.Add("c")
+	time.Sleep(100 * time.Millisecond)
+	require.True(t, s.Contains("c"))
+
+	cancel()
+	s.Remove("c")
+	require.Len(t, getChanges(), 5)
+}
+
+func TestSetNotificationOrder(t *testing.T) {
+	s := NewSet()
+	var mx sync.Mutex
+	var changes []bool
+	notified := make(chan struct{}, 1)
+	release := make(chan struct{})
+	s.Subscribe(func(_ ID, added bool) {
+		if added {
+			notified <- struct{}{}
+			<-release
+		}
+		mx.Lock()
+		changes = append(changes, added)
+		mx.Unlock()
+	})
+
+	go s.Add("a")
+	<-notified
+	removed := make(chan struct{})
+	go func() {
+		s.Remove("a")
+		close(removed)
+	}()
+	// the removal isn't notified before the addition
+	select {
+	case <-removed:
+		t.Fatal("removal notified while the addition is still being notified")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-removed
+	mx.Lock()
+	defer mx.Unlock()
+	require.Equal(t, []bool{true, false}, changes)
+}
+
+func TestAdmitted(t *testing.T) {
+	allow := NewSet()
+	allow.Add("a")
+	allow.Add("b")
+	deny := NewSet()
+	deny.Add("b")
+
+	require.True(t, Admitted(nil, nil, "a"))
+	require.True(t, Admitted(allow, deny, "a"))
+	require.False(t, Admitted(allow, deny, "b"))
+	require.False(t, Admitted(allow, deny, "c"))
+	require.True(t, Admitted(nil, deny, "c"))
+}
@@ -1,7 +1,22 @@
 // Package pnet provides interfaces for private networking in libp2p.
 package pnet
 
+import "crypto/cipher"
+
 // A PSK enables private network implementation to be transparent in libp2p.
 // It is used to ensure that peers can only establish connections to other peers
 // that are using the same PSK.
 type PSK []byte
+
+// KeyProvider performs the cryptographic operations of the private network
+// protector with a PSK that it doesn't reveal, like crypto.Signer does for
+// private keys. This allows keeping the PSK in an HSM or a KMS.
+type KeyProvider interface {
+	// XSalsa20 returns the XSalsa20 key stream of the PSK and the 24 byte
+	// nonce. It is only used by the legacy protector, and providers that
+	// can't compute it may return an error.
+	XSalsa20(nonce []byte) (cipher.Stream, error)
+	// DeriveKey derives a 32 byte key from the PSK with HKDF-SHA256, using
+	// salt and info. It is used by the negotiated protector modes.
+	DeriveKey(salt, info []byte) ([]byte, error)
+}
@@ -0,0 +1,131 @@
+package protocol
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version of a protocol, without pre-release or build
+// metadata. Versioned protocol IDs have the form "<base>/<major>.<minor>.<patch>",
+// e.g. "/app/kad/1.2.3".
+type Version struct {
+	Major, Minor, Patch uint64
+}
+
+// ParseVersion parses a version of the form "<major>.<minor>.<patch>".
+func ParseVersion(s string) (Version, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return Version{}, fmt.Errorf("invalid version %q: expected <major>.<minor>.<patch>", s)
+	}
+	var nums [3]uint64
+	for i, p := range parts {
+		// Reject leading zeros, like semver does.
+		if p == "" || (len(p) > 1 && p[0] == '0') {
+			return Version{}, fmt.Errorf("invalid version %q", s)
+		}
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+		nums[i] = n
+	}
+	return Version{Major: nums[0], Minor: nums[1], Patch: nums[2]}, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Compare returns -1 if v is lower than o, 0 if they are equal, and +1 if v is
+// higher than o.
+func (v Version) Compare(o Version) int {
+	if c := cmp.Compare(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	return cmp.Compare(v.Patch, o.Patch)
+}
+
+// Satisfies returns true if an implementation of version v can serve a peer
+// that requires version required. Following semantic versioning, this is the
+// case if both versions have the same major version, and v is not lower than
+// required. For major version 0, the minor versions have to match as well.
+func (v Version) Satisfies(required Version) bool {
+	if v.Major != required.Major {
+		return false
+	}
+	if v.Major == 0 && v.Minor != required.Minor {
+		return false
+	}
+	return v.Compare(required) >= 0
+}
+
+// VersionedID returns the protocol ID of version v of the protocol base,
+// e.g. VersionedID("/app/kad", Version{1, 2, 3}) returns "/app/kad/1.2.3".
+func VersionedID(base ID, v Version) ID {
+	return ID(strings.TrimSuffix(string(base), "/") + "/" + v.String())
+}
+
+// SplitVersion splits a versioned protocol ID into its base and version.
+func SplitVersion(id ID) (base ID, v Version, err error) {
+	i := strings.LastIndexByte(string(id), '/')
+	if i <= 0 {
+		return "", Version{}, fmt.Errorf("protocol %s is not versioned", id)
+	}
+	v, err = ParseVersion(string(id[i+1:]))
+	if err != nil {
+		return "", Version{}, fmt.Errorf("protocol %s is not versioned: %w", id, err)
+	}
+	return id[:i], v, nil
+}
+
+// VersionMatcher returns a match function for Router.AddHandlerWithFunc and
+// host.SetStreamHandlerMatch. It accepts requests for all versions of the
+// protocol that version id of the protocol satisfies. The match function
+// passes the requested protocol ID to the handler, use SplitVersion to get
+// the version the remote peer asked for.
+func VersionMatcher(id ID) (func(ID) bool, error) {
+	base, v, err := SplitVersion(id)
+	if err != nil {
+		return nil, err
+	}
+	return func(requested ID) bool {
+		b, rv, err := SplitVersion(requested)
+		return err == nil && b == base && v.Satisfies(rv)
+	}, nil
+}
+
+// CompatibleIDs returns the protocol IDs in candidates, e.g. the protocols
+// supported by a remote peer, whose versions satisfy version id of the
+// protocol. The result is sorted from the highest to the lowest version, so it
+// can be passed to host.NewStream to negotiate the highest compatible version.
+func CompatibleIDs(id ID, candidates []ID) ([]ID, error) {
+	base, v, err := SplitVersion(id)
+	if err != nil {
+		return nil, err
+	}
+	type versioned struct {
+		id ID
+		v  Version
+	}
+	var compatible []versioned
+	for _, c := range candidates {
+		b, cv, err := SplitVersion(c)
+		if err != nil || b != base || !cv.Satisfies(v) {
+			continue
+		}
+		compatible = append(compatible, versioned{id: c, v: cv})
+	}
+	slices.SortStableFunc(compatible, func(a, b versioned) int { return b.v.Compare(a.v) })
+	res := make([]ID, 0, len(compatible))
+	for _, c := range compatible {
+		res = append(res, c.id)
+	}
+	return res, nil
+}
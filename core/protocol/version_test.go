@@ -0,0 +1,83 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersion(t *testing.T) {
+	v, err := ParseVersion("1.22.333")
+	require.NoError(t, err)
+	require.Equal(t, Version{Major: 1, Minor: 22, Patch: 333}, v)
+	require.Equal(t, "1.22.333", v.String())
+
+	for _, s := range []string{"", "1", "1.2", "1.2.3.4", "1.2.x", "01.2.3", "1..3", "-1.2.3", "+1.2.3", "1.2.3-rc1"} {
+		_, err := ParseVersion(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestVersionSatisfies(t *testing.T) {
+	for _, tc := range []struct {
+		v, required string
+		ok          bool
+	}{
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "1.2.0", true},
+		{"1.2.3", "1.0.9", true},
+		{"1.2.3", "1.2.4", false},
+		{"1.2.3", "1.3.0", false},
+		{"2.0.0", "1.9.9", false},
+		{"1.9.9", "2.0.0", false},
+		{"0.2.3", "0.2.1", true},
+		{"0.3.0", "0.2.1", false},
+	} {
+		v, err := ParseVersion(tc.v)
+		require.NoError(t, err)
+		required, err := ParseVersion(tc.required)
+		require.NoError(t, err)
+		require.Equal(t, tc.ok, v.Satisfies(required), "%s satisfies %s", tc.v, tc.required)
+	}
+}
+
+func TestSplitVersion(t *testing.T) {
+	base, v, err := SplitVersion("/app/kad/1.2.3")
+	require.NoError(t, err)
+	require.Equal(t, ID("/app/kad"), base)
+	require.Equal(t, Version{1, 2, 3}, v)
+	require.Equal(t, ID("/app/kad/1.2.3"), VersionedID(base, v))
+
+	for _, id := range []ID{"/app/kad", "1.2.3", "/1.2.3", "/app/kad/1.2"} {
+		_, _, err := SplitVersion(id)
+		require.Error(t, err, id)
+	}
+}
+
+func TestVersionMatcher(t *testing.T) {
+	match, err := VersionMatcher("/app/kad/1.2.3")
+	require.NoError(t, err)
+	require.True(t, match("/app/kad/1.2.3"))
+	require.True(t, match("/app/kad/1.0.0"))
+	require.False(t, match("/app/kad/1.3.0"))
+	require.False(t, match("/app/kad/2.0.0"))
+	require.False(t, match("/app/other/1.0.0"))
+	require.False(t, match("/app/kad"))
+
+	_, err = VersionMatcher("/app/kad")
+	require.Error(t, err)
+}
+
+func TestCompatibleIDs(t *testing.T) {
+	ids, err := CompatibleIDs("/app/kad/1.2.0", []ID{
+		"/app/kad/1.1.0",
+		"/app/kad/1.4.0",
+		"/app/kad/2.0.0",
+		"/app/kad/1.2.1",
+		"/app/other/1.5.0",
+		"/ipfs/id/1.0.0",
+		"/app/kad",
+	})
+	require.NoError(t, err)
+	require.Equal(t, []ID{"/app/kad/1.4.0", "/app/kad/1.2.1"}, ids)
+}
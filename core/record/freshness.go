@@ -0,0 +1,259 @@
+package record
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+var (
+	// ErrStaleRecord is returned by Consumer when a record is older than, or
+	// conflicts with, a record of the same type and signer it already accepted.
+	ErrStaleRecord = errors.New("record is older than the last accepted record")
+
+	// ErrRecordExpired is returned by Consumer when a record has expired.
+	ErrRecordExpired = errors.New("record has expired")
+
+	// ErrKeyRotated is returned by Consumer when a record is signed by a key
+	// that has been rotated.
+	ErrKeyRotated = errors.New("record is signed by a rotated key")
+)
+
+// SequencedRecord is a Record that is ordered in time by a sequence number.
+// Newer versions of a record MUST have a greater sequence number than older
+// versions signed by the same key.
+type SequencedRecord interface {
+	Record
+
+	// Sequence returns the sequence number of the record.
+	Sequence() uint64
+}
+
+// ExpiringRecord is a Record that is only valid until a point in time.
+type ExpiringRecord interface {
+	Record
+
+	// Expiry returns the time after which the record must no longer be
+	// accepted. A zero time means that the record doesn't expire.
+	Expiry() time.Time
+}
+
+// ConsumerOption is an option for NewConsumer.
+type ConsumerOption func(*Consumer) error
+
+// WithClock sets the function used by a Consumer to get the current time.
+// It defaults to time.Now.
+func WithClock(now func() time.Time) ConsumerOption {
+	return func(c *Consumer) error {
+		if now == nil {
+			return errors.New("clock must not be nil")
+		}
+		c.now = now
+		return nil
+	}
+}
+
+// WithMaxClockSkew sets how long after its expiry a Consumer still accepts an
+// ExpiringRecord, to tolerate clocks that are slightly off. It defaults to 0.
+func WithMaxClockSkew(d time.Duration) ConsumerOption {
+	return func(c *Consumer) error {
+		if d < 0 {
+			return errors.New("clock skew must not be negative")
+		}
+		c.maxClockSkew = d
+		return nil
+	}
+}
+
+type seqKey struct {
+	signer      string
+	payloadType string
+}
+
+type lastSeq struct {
+	seq     uint64
+	payload []byte
+}
+
+// Consumer consumes envelopes like ConsumeEnvelope, and additionally
+// enforces the freshness of the records:
+//
+//   - An ExpiringRecord is rejected with ErrRecordExpired once it has expired.
+//   - A SequencedRecord is rejected with ErrStaleRecord if the Consumer already
+//     accepted a record of the same payload type from the same signer with a
+//     greater sequence number, or with the same sequence number but a
+//     different payload. Consuming the same record again is allowed.
+//   - A record signed by a key that was rotated (see AddKeyRotation) is
+//     rejected with ErrKeyRotated. Records signed by the new key continue the
+//     sequence of the old key.
+//
+// A Consumer keeps state for every signer it has seen, so applications should
+// scope it to the set of peers they track, e.g. use one Consumer per topic.
+// It is safe for concurrent use.
+type Consumer struct {
+	now          func() time.Time
+	maxClockSkew time.Duration
+
+	mx   sync.Mutex
+	seqs map[seqKey]lastSeq
+	// identities maps every key that is the result of a rotation to the key
+	// that started the rotation chain.
+	identities map[string]crypto.PubKey
+	// rotated maps rotated keys to the key they were rotated to.
+	rotated map[string]string
+}
+
+// NewConsumer creates a new Consumer.
+func NewConsumer(opts ...ConsumerOption) (*Consumer, error) {
+	c := &Consumer{
+		now:        time.Now,
+		seqs:       make(map[seqKey]lastSeq),
+		identities: make(map[string]crypto.PubKey),
+		rotated:    make(map[string]string),
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Consume unmarshals a serialized Envelope and validates its signature using
+// the provided domain string, like ConsumeEnvelope. It then checks the
+// freshness of the record, and returns an error if it is expired or stale.
+// The record must be of a type registered with RegisterType.
+func (c *Consumer) Consume(data []byte, domain string) (*Envelope, Record, error) {
+	env, rec, err := ConsumeEnvelope(data, domain)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := c.check(env, rec); err != nil {
+		return nil, nil, err
+	}
+	return env, rec, nil
+}
+
+// ConsumeTyped is like Consume, but it unmarshals the record into destRecord,
+// like ConsumeTypedEnvelope.
+func (c *Consumer) ConsumeTyped(data []byte, destRecord Record) (*Envelope, error) {
+	env, err := ConsumeTypedEnvelope(data, destRecord)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.check(env, destRecord); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+func (c *Consumer) check(env *Envelope, rec Record) error {
+	if r, ok := rec.(ExpiringRecord); ok {
+		if expiry := r.Expiry(); !expiry.IsZero() && c.now().After(expiry.Add(c.maxClockSkew)) {
+			return ErrRecordExpired
+		}
+	}
+
+	signer, err := keyID(env.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if _, ok := c.rotated[signer]; ok {
+		return ErrKeyRotated
+	}
+	r, ok := rec.(SequencedRecord)
+	if !ok {
+		return nil
+	}
+	if root, ok := c.identities[signer]; ok {
+		if signer, err = keyID(root); err != nil {
+			return err
+		}
+	}
+	key := seqKey{signer: signer, payloadType: string(env.PayloadType)}
+	seq := r.Sequence()
+	if last, ok := c.seqs[key]; ok {
+		if seq < last.seq || (seq == last.seq && !bytes.Equal(env.RawPayload, last.payload)) {
+			return fmt.Errorf("%w: got sequence number %d, already accepted %d", ErrStaleRecord, seq, last.seq)
+		}
+	}
+	c.seqs[key] = lastSeq{seq: seq, payload: env.RawPayload}
+	return nil
+}
+
+// AddKeyRotation consumes a serialized key rotation envelope (see
+// SealKeyRotation). Once it is added, records signed by the old key are
+// rejected with ErrKeyRotated, and records signed by the new key are treated
+// as coming from the same signer as the old key.
+//
+// Rotating a key that was already rotated to a different key returns an
+// error, as does rotating back to a key of the same chain.
+func (c *Consumer) AddKeyRotation(data []byte) error {
+	env, rot, err := ConsumeKeyRotation(data)
+	if err != nil {
+		return err
+	}
+	oldID, err := keyID(env.PublicKey)
+	if err != nil {
+		return err
+	}
+	newID, err := keyID(rot.NewKey)
+	if err != nil {
+		return err
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if to, ok := c.rotated[oldID]; ok {
+		if to == newID {
+			return nil
+		}
+		return errors.New("key was already rotated to a different key")
+	}
+	root := env.PublicKey
+	if r, ok := c.identities[oldID]; ok {
+		root = r
+	}
+	if _, ok := c.rotated[newID]; ok || rot.NewKey.Equals(root) {
+		return errors.New("can't rotate to a key that was rotated")
+	}
+	if _, ok := c.identities[newID]; ok {
+		return errors.New("new key already belongs to a rotation chain")
+	}
+	c.rotated[oldID] = newID
+	c.identities[newID] = root
+	return nil
+}
+
+// Identity returns the key that started the rotation chain that key belongs
+// to, or key itself if it is not the result of a rotation known to the
+// Consumer.
+func (c *Consumer) Identity(key crypto.PubKey) (crypto.PubKey, error) {
+	id, err := keyID(key)
+	if err != nil {
+		return nil, err
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if root, ok := c.identities[id]; ok {
+		return root, nil
+	}
+	return key, nil
+}
+
+func keyID(key crypto.PubKey) (string, error) {
+	b, err := crypto.MarshalPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
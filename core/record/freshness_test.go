@@ -0,0 +1,182 @@
+package record_test
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	. "github.com/libp2p/go-libp2p/core/record"
+	"github.com/libp2p/go-libp2p/core/test"
+)
+
+// freshRecord is a record with a sequence number and an expiry, encoded as
+// "<seq>:<unix expiry>:<message>".
+type freshRecord struct {
+	seq     uint64
+	expiry  time.Time
+	message string
+}
+
+func init() {
+	RegisterType(&freshRecord{})
+}
+
+func (r *freshRecord) Domain() string    { return "libp2p-testing-fresh" }
+func (r *freshRecord) Codec() []byte     { return []byte("/libp2p/testdata/fresh") }
+func (r *freshRecord) Sequence() uint64  { return r.seq }
+func (r *freshRecord) Expiry() time.Time { return r.expiry }
+func (r *freshRecord) UnmarshalRecord(b []byte) error {
+	parts := strings.SplitN(string(b), ":", 3)
+	if len(parts) != 3 {
+		return errors.New("invalid record")
+	}
+	seq, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return err
+	}
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return err
+	}
+	r.seq = seq
+	if expiry != 0 {
+		r.expiry = time.Unix(expiry, 0)
+	}
+	r.message = parts[2]
+	return nil
+}
+
+func (r *freshRecord) MarshalRecord() ([]byte, error) {
+	var expiry int64
+	if !r.expiry.IsZero() {
+		expiry = r.expiry.Unix()
+	}
+	return []byte(strconv.FormatUint(r.seq, 10) + ":" + strconv.FormatInt(expiry, 10) + ":" + r.message), nil
+}
+
+func sealFresh(t *testing.T, priv crypto.PrivKey, rec *freshRecord) []byte {
+	t.Helper()
+	env, err := Seal(rec, priv)
+	test.AssertNilError(t, err)
+	b, err := env.Marshal()
+	test.AssertNilError(t, err)
+	return b
+}
+
+func TestConsumerSequence(t *testing.T) {
+	priv, _, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+	test.AssertNilError(t, err)
+	other, _, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+	test.AssertNilError(t, err)
+
+	c, err := NewConsumer()
+	test.AssertNilError(t, err)
+
+	rec5 := sealFresh(t, priv, &freshRecord{seq: 5, message: "five"})
+	_, rec, err := c.Consume(rec5, "libp2p-testing-fresh")
+	test.AssertNilError(t, err)
+	if rec.(*freshRecord).message != "five" {
+		t.Fatal("unexpected record")
+	}
+	// Consuming the same record again is fine.
+	_, _, err = c.Consume(rec5, "libp2p-testing-fresh")
+	test.AssertNilError(t, err)
+
+	if _, _, err := c.Consume(sealFresh(t, priv, &freshRecord{seq: 4}), "libp2p-testing-fresh"); !errors.Is(err, ErrStaleRecord) {
+		t.Fatalf("expected ErrStaleRecord for an older record, got %v", err)
+	}
+	if _, _, err := c.Consume(sealFresh(t, priv, &freshRecord{seq: 5, message: "other five"}), "libp2p-testing-fresh"); !errors.Is(err, ErrStaleRecord) {
+		t.Fatalf("expected ErrStaleRecord for a conflicting record, got %v", err)
+	}
+	// Sequence numbers are tracked per signer.
+	_, _, err = c.Consume(sealFresh(t, other, &freshRecord{seq: 1}), "libp2p-testing-fresh")
+	test.AssertNilError(t, err)
+
+	var dest freshRecord
+	_, err = c.ConsumeTyped(sealFresh(t, priv, &freshRecord{seq: 6, message: "six"}), &dest)
+	test.AssertNilError(t, err)
+	if dest.message != "six" {
+		t.Fatal("unexpected record")
+	}
+	if _, err := c.ConsumeTyped(rec5, &dest); !errors.Is(err, ErrStaleRecord) {
+		t.Fatalf("expected ErrStaleRecord, got %v", err)
+	}
+}
+
+func TestConsumerExpiry(t *testing.T) {
+	priv, _, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+	test.AssertNilError(t, err)
+
+	now := time.Unix(1000000, 0)
+	c, err := NewConsumer(WithClock(func() time.Time { return now }), WithMaxClockSkew(time.Minute))
+	test.AssertNilError(t, err)
+
+	_, _, err = c.Consume(sealFresh(t, priv, &freshRecord{seq: 1}), "libp2p-testing-fresh")
+	test.AssertNilError(t, err)
+	_, _, err = c.Consume(sealFresh(t, priv, &freshRecord{seq: 2, expiry: now.Add(-30 * time.Second)}), "libp2p-testing-fresh")
+	test.AssertNilError(t, err)
+	if _, _, err := c.Consume(sealFresh(t, priv, &freshRecord{seq: 3, expiry: now.Add(-2 * time.Minute)}), "libp2p-testing-fresh"); !errors.Is(err, ErrRecordExpired) {
+		t.Fatalf("expected ErrRecordExpired, got %v", err)
+	}
+
+	if _, err := NewConsumer(WithMaxClockSkew(-time.Second)); err == nil {
+		t.Fatal("expected an error for a negative clock skew")
+	}
+}
+
+func TestConsumerKeyRotation(t *testing.T) {
+	k1, pub1, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+	test.AssertNilError(t, err)
+	k2, pub2, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+	test.AssertNilError(t, err)
+	k3, _, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+	test.AssertNilError(t, err)
+
+	c, err := NewConsumer()
+	test.AssertNilError(t, err)
+	_, _, err = c.Consume(sealFresh(t, k1, &freshRecord{seq: 10}), "libp2p-testing-fresh")
+	test.AssertNilError(t, err)
+
+	rotate := func(from, to crypto.PrivKey, seq uint64) []byte {
+		env, err := SealKeyRotation(from, to, seq)
+		test.AssertNilError(t, err)
+		b, err := env.Marshal()
+		test.AssertNilError(t, err)
+		return b
+	}
+	rot12 := rotate(k1, k2, 1)
+	test.AssertNilError(t, c.AddKeyRotation(rot12))
+	// Adding the same rotation again is a no-op.
+	test.AssertNilError(t, c.AddKeyRotation(rot12))
+	if err := c.AddKeyRotation(rotate(k1, k3, 2)); err == nil {
+		t.Fatal("expected an error when rotating a key twice")
+	}
+	if err := c.AddKeyRotation(rotate(k2, k1, 2)); err == nil {
+		t.Fatal("expected an error when rotating back to a rotated key")
+	}
+
+	if _, _, err := c.Consume(sealFresh(t, k1, &freshRecord{seq: 11}), "libp2p-testing-fresh"); !errors.Is(err, ErrKeyRotated) {
+		t.Fatalf("expected ErrKeyRotated, got %v", err)
+	}
+	// The new key continues the sequence of the old key.
+	if _, _, err := c.Consume(sealFresh(t, k2, &freshRecord{seq: 9}), "libp2p-testing-fresh"); !errors.Is(err, ErrStaleRecord) {
+		t.Fatalf("expected ErrStaleRecord, got %v", err)
+	}
+	_, _, err = c.Consume(sealFresh(t, k2, &freshRecord{seq: 11}), "libp2p-testing-fresh")
+	test.AssertNilError(t, err)
+
+	test.AssertNilError(t, c.AddKeyRotation(rotate(k2, k3, 2)))
+	id, err := c.Identity(k3.GetPublic())
+	test.AssertNilError(t, err)
+	if !id.Equals(pub1) {
+		t.Fatal("expected the identity of the rotated key to be the first key")
+	}
+	id, err = c.Identity(pub2)
+	test.AssertNilError(t, err)
+	if !id.Equals(pub1) {
+		t.Fatal("expected the identity of the rotated key to be the first key")
+	}
+}
@@ -1,8 +1,11 @@
 package record
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"reflect"
+	"sync"
 
 	"github.com/libp2p/go-libp2p/core/internal/catch"
 )
@@ -12,7 +15,12 @@ var (
 	// PayloadType does not match any registered Record types.
 	ErrPayloadTypeNotRegistered = errors.New("payload type is not registered")
 
-	payloadTypeRegistry = make(map[string]reflect.Type)
+	// ErrTypeAlreadyRegistered is returned from TryRegisterType when a
+	// different Record type is already registered for the payload type.
+	ErrTypeAlreadyRegistered = errors.New("payload type is already registered")
+
+	payloadTypeRegistryMx sync.RWMutex
+	payloadTypeRegistry   = make(map[string]reflect.Type)
 )
 
 // Record represents a data type that can be used as the payload of an Envelope.
@@ -67,10 +75,45 @@ type Record interface {
 //	}
 //
 //	type HelloRecord struct { } // etc..
+//
+// RegisterType replaces any Record type previously registered for the same
+// payload type. Use TryRegisterType to detect conflicts instead.
 func RegisterType(prototype Record) {
+	payloadTypeRegistryMx.Lock()
+	defer payloadTypeRegistryMx.Unlock()
 	payloadTypeRegistry[string(prototype.Codec())] = getValueType(prototype)
 }
 
+// TryRegisterType is like RegisterType, but it validates the Record type, and
+// returns ErrTypeAlreadyRegistered if a different Record type is already
+// registered for its payload type. Registering the same type twice is not an
+// error. Applications registering their own Record types at runtime should
+// prefer it over RegisterType.
+func TryRegisterType(prototype Record) error {
+	if prototype.Domain() == "" {
+		return ErrEmptyDomain
+	}
+	codec := prototype.Codec()
+	if len(codec) == 0 {
+		return ErrEmptyPayloadType
+	}
+	valueType := getValueType(prototype)
+	if valueType.Kind() != reflect.Struct {
+		return fmt.Errorf("record type %s must be a pointer to a struct", reflect.TypeOf(prototype))
+	}
+	if blank := reflect.New(valueType).Interface().(Record); !bytes.Equal(blank.Codec(), codec) {
+		return fmt.Errorf("record type %s doesn't have a fixed payload type", reflect.TypeOf(prototype))
+	}
+
+	payloadTypeRegistryMx.Lock()
+	defer payloadTypeRegistryMx.Unlock()
+	if existing, ok := payloadTypeRegistry[string(codec)]; ok && existing != valueType {
+		return fmt.Errorf("%w: %q is registered to %s", ErrTypeAlreadyRegistered, codec, existing)
+	}
+	payloadTypeRegistry[string(codec)] = valueType
+	return nil
+}
+
 func unmarshalRecordPayload(payloadType []byte, payloadBytes []byte) (_rec Record, err error) {
 	defer func() { catch.HandlePanic(recover(), &err, "libp2p envelope record unmarshal") }()
 
@@ -86,7 +129,9 @@ func unmarshalRecordPayload(payloadType []byte, payloadBytes []byte) (_rec Recor
 }
 
 func blankRecordForPayloadType(payloadType []byte) (Record, error) {
+	payloadTypeRegistryMx.RLock()
 	valueType, ok := payloadTypeRegistry[string(payloadType)]
+	payloadTypeRegistryMx.RUnlock()
 	if !ok {
 		return nil, ErrPayloadTypeNotRegistered
 	}
@@ -1,6 +1,9 @@
 package record
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 var testPayloadType = []byte("/libp2p/test/record/payload-type")
 
@@ -49,3 +52,32 @@ func TestUnmarshalPayload(t *testing.T) {
 		}
 	})
 }
+
+type otherTestPayload struct {
+	testPayload
+}
+
+type emptyCodecPayload struct {
+	testPayload
+}
+
+func (p *emptyCodecPayload) Codec() []byte {
+	return nil
+}
+
+func TestTryRegisterType(t *testing.T) {
+	codec := []byte("/libp2p/test/record/try-register")
+	RegisterType(&testPayload{})
+	if err := TryRegisterType(&testPayload{}); err != nil {
+		t.Fatalf("expected registering the same type twice to succeed, got %v", err)
+	}
+	if err := TryRegisterType(&otherTestPayload{}); !errors.Is(err, ErrTypeAlreadyRegistered) {
+		t.Fatalf("expected ErrTypeAlreadyRegistered, got %v", err)
+	}
+	if err := TryRegisterType(&emptyCodecPayload{}); err != ErrEmptyPayloadType {
+		t.Fatalf("expected ErrEmptyPayloadType, got %v", err)
+	}
+	if _, err := blankRecordForPayloadType(codec); err != ErrPayloadTypeNotRegistered {
+		t.Fatal("expected failed registrations not to register anything")
+	}
+}
@@ -0,0 +1,198 @@
+package record
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/internal/catch"
+	"github.com/libp2p/go-libp2p/core/record/pb"
+
+	pool "github.com/libp2p/go-buffer-pool"
+
+	"github.com/multiformats/go-varint"
+	"google.golang.org/protobuf/proto"
+)
+
+func init() {
+	RegisterType(&KeyRotation{})
+}
+
+// KeyRotationEnvelopeDomain is the domain string used for key rotation records contained in an Envelope.
+const KeyRotationEnvelopeDomain = "libp2p-key-rotation"
+
+// KeyRotationEnvelopePayloadType is the type hint used to identify key rotation records in an Envelope.
+var KeyRotationEnvelopePayloadType = []byte("/libp2p/key-rotation")
+
+// keyRotationProofDomain is the domain of the new key's signature over the
+// rotation.
+const keyRotationProofDomain = "libp2p-key-rotation-proof"
+
+// ErrInvalidKeyRotation is returned when a key rotation record or chain fails
+// validation.
+var ErrInvalidKeyRotation = errors.New("invalid key rotation")
+
+// KeyRotation is a record announcing that the key signing its Envelope has
+// been replaced by NewKey. Besides being signed by the old key, it contains a
+// signature of the new key, so a key can't be rotated to a key whose owner
+// didn't agree to it.
+//
+// Rotations chain: a key that was rotated to can itself be rotated, and
+// VerifyKeyRotationChain follows such a chain from its first key to its
+// current key. Create a KeyRotation with SealKeyRotation.
+type KeyRotation struct {
+	// NewKey is the key replacing the signer of the envelope.
+	NewKey crypto.PubKey
+
+	// Seq orders the rotations of a chain, it must increase with every
+	// rotation.
+	Seq uint64
+
+	// newKeySignature is the signature of NewKey over the old key and Seq.
+	newKeySignature []byte
+}
+
+var _ SequencedRecord = (*KeyRotation)(nil)
+
+// SealKeyRotation creates a KeyRotation from oldKey to newKey, and seals it
+// in an Envelope signed by oldKey.
+func SealKeyRotation(oldKey, newKey crypto.PrivKey, seq uint64) (*Envelope, error) {
+	if oldKey.Equals(newKey) {
+		return nil, fmt.Errorf("%w: can't rotate a key to itself", ErrInvalidKeyRotation)
+	}
+	unsigned, err := makeKeyRotationProof(oldKey.GetPublic(), seq)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Put(unsigned)
+	sig, err := newKey.Sign(unsigned)
+	if err != nil {
+		return nil, err
+	}
+	return Seal(&KeyRotation{NewKey: newKey.GetPublic(), Seq: seq, newKeySignature: sig}, oldKey)
+}
+
+// ConsumeKeyRotation unmarshals a serialized Envelope containing a
+// KeyRotation, and validates the signatures of both the old and the new key.
+func ConsumeKeyRotation(data []byte) (*Envelope, *KeyRotation, error) {
+	rot := &KeyRotation{}
+	env, err := ConsumeTypedEnvelope(data, rot)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := rot.verify(env.PublicKey); err != nil {
+		return nil, nil, err
+	}
+	return env, rot, nil
+}
+
+// VerifyKeyRotationChain verifies a chain of key rotation envelopes starting
+// at root, and returns the current key. Every envelope must be signed by the
+// key the previous envelope rotated to (or root for the first one), and the
+// sequence numbers must increase. An empty chain returns root.
+func VerifyKeyRotationChain(root crypto.PubKey, chain ...*Envelope) (crypto.PubKey, error) {
+	current := root
+	var lastSeq uint64
+	for i, env := range chain {
+		if !env.PublicKey.Equals(current) {
+			return nil, fmt.Errorf("%w: rotation %d is not signed by the current key", ErrInvalidKeyRotation, i)
+		}
+		if err := env.validate(KeyRotationEnvelopeDomain); err != nil {
+			return nil, err
+		}
+		rot := &KeyRotation{}
+		if err := env.TypedRecord(rot); err != nil {
+			return nil, err
+		}
+		if err := rot.verify(env.PublicKey); err != nil {
+			return nil, err
+		}
+		if i > 0 && rot.Seq <= lastSeq {
+			return nil, fmt.Errorf("%w: rotation %d has sequence number %d, expected more than %d", ErrInvalidKeyRotation, i, rot.Seq, lastSeq)
+		}
+		lastSeq = rot.Seq
+		current = rot.NewKey
+	}
+	return current, nil
+}
+
+func (r *KeyRotation) verify(oldKey crypto.PubKey) error {
+	if r.NewKey.Equals(oldKey) {
+		return fmt.Errorf("%w: key is rotated to itself", ErrInvalidKeyRotation)
+	}
+	unsigned, err := makeKeyRotationProof(oldKey, r.Seq)
+	if err != nil {
+		return err
+	}
+	defer pool.Put(unsigned)
+	valid, err := r.NewKey.Verify(unsigned, r.newKeySignature)
+	if err != nil {
+		return fmt.Errorf("failed while verifying new key signature: %w", err)
+	}
+	if !valid {
+		return fmt.Errorf("%w: invalid new key signature", ErrInvalidKeyRotation)
+	}
+	return nil
+}
+
+// makeKeyRotationProof returns the buffer the new key signs. It returns a byte
+// slice from a pool. The caller MUST return this slice to the pool.
+func makeKeyRotationProof(oldKey crypto.PubKey, seq uint64) ([]byte, error) {
+	oldKeyBytes, err := crypto.MarshalPublicKey(oldKey)
+	if err != nil {
+		return nil, err
+	}
+	return makeUnsigned(keyRotationProofDomain, oldKeyBytes, varint.ToUvarint(seq))
+}
+
+// Domain is used when signing and validating KeyRotation records contained in Envelopes.
+func (r *KeyRotation) Domain() string {
+	return KeyRotationEnvelopeDomain
+}
+
+// Codec is a binary identifier for the KeyRotation type.
+func (r *KeyRotation) Codec() []byte {
+	return KeyRotationEnvelopePayloadType
+}
+
+// Sequence returns the sequence number of the rotation.
+func (r *KeyRotation) Sequence() uint64 {
+	return r.Seq
+}
+
+// UnmarshalRecord parses a KeyRotation from a byte slice.
+// This method is called automatically when consuming a record envelope.
+func (r *KeyRotation) UnmarshalRecord(bytes []byte) (err error) {
+	if r == nil {
+		return fmt.Errorf("cannot unmarshal KeyRotation to nil receiver")
+	}
+
+	defer func() { catch.HandlePanic(recover(), &err, "libp2p key rotation unmarshal") }()
+
+	var msg pb.KeyRotation
+	if err := proto.Unmarshal(bytes, &msg); err != nil {
+		return err
+	}
+	newKey, err := crypto.PublicKeyFromProto(msg.GetNewPublicKey())
+	if err != nil {
+		return err
+	}
+	*r = KeyRotation{NewKey: newKey, Seq: msg.GetSeq(), newKeySignature: msg.GetNewKeySignature()}
+	return nil
+}
+
+// MarshalRecord serializes a KeyRotation to a byte slice.
+// This method is called automatically by SealKeyRotation.
+func (r *KeyRotation) MarshalRecord() (res []byte, err error) {
+	defer func() { catch.HandlePanic(recover(), &err, "libp2p key rotation marshal") }()
+
+	newKey, err := crypto.PublicKeyToProto(r.NewKey)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(&pb.KeyRotation{
+		NewPublicKey:    newKey,
+		Seq:             r.Seq,
+		NewKeySignature: r.newKeySignature,
+	})
+}
@@ -0,0 +1,97 @@
+package record_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	. "github.com/libp2p/go-libp2p/core/record"
+	"github.com/libp2p/go-libp2p/core/test"
+)
+
+func TestKeyRotation(t *testing.T) {
+	oldKey, oldPub, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+	test.AssertNilError(t, err)
+	newKey, newPub, err := test.RandTestKeyPair(crypto.Secp256k1, 256)
+	test.AssertNilError(t, err)
+
+	env, err := SealKeyRotation(oldKey, newKey, 1)
+	test.AssertNilError(t, err)
+	b, err := env.Marshal()
+	test.AssertNilError(t, err)
+
+	env2, rot, err := ConsumeKeyRotation(b)
+	test.AssertNilError(t, err)
+	if !env2.PublicKey.Equals(oldPub) || !rot.NewKey.Equals(newPub) || rot.Seq != 1 {
+		t.Fatal("unexpected key rotation")
+	}
+
+	// Key rotations are registered, so ConsumeEnvelope returns them too.
+	_, rec, err := ConsumeEnvelope(b, KeyRotationEnvelopeDomain)
+	test.AssertNilError(t, err)
+	if _, ok := rec.(*KeyRotation); !ok {
+		t.Fatalf("expected a *KeyRotation, got %T", rec)
+	}
+
+	if _, err := SealKeyRotation(oldKey, oldKey, 1); !errors.Is(err, ErrInvalidKeyRotation) {
+		t.Fatalf("expected ErrInvalidKeyRotation, got %v", err)
+	}
+}
+
+func TestKeyRotationRequiresNewKeySignature(t *testing.T) {
+	oldKey, _, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+	test.AssertNilError(t, err)
+	_, victim, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+	test.AssertNilError(t, err)
+
+	// A rotation to a key without its owner's consent carries no valid proof.
+	env, err := Seal(&KeyRotation{NewKey: victim, Seq: 1}, oldKey)
+	test.AssertNilError(t, err)
+	b, err := env.Marshal()
+	test.AssertNilError(t, err)
+	if _, _, err := ConsumeKeyRotation(b); err == nil {
+		t.Fatal("expected a rotation without the new key's signature to be rejected")
+	}
+	if _, err := VerifyKeyRotationChain(oldKey.GetPublic(), env); err == nil {
+		t.Fatal("expected a chain with a rotation without the new key's signature to be rejected")
+	}
+}
+
+func TestVerifyKeyRotationChain(t *testing.T) {
+	var keys []crypto.PrivKey
+	for i := 0; i < 4; i++ {
+		k, _, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+		test.AssertNilError(t, err)
+		keys = append(keys, k)
+	}
+	var chain []*Envelope
+	for i := 0; i < 3; i++ {
+		env, err := SealKeyRotation(keys[i], keys[i+1], uint64(i+1))
+		test.AssertNilError(t, err)
+		chain = append(chain, env)
+	}
+
+	current, err := VerifyKeyRotationChain(keys[0].GetPublic(), chain...)
+	test.AssertNilError(t, err)
+	if !current.Equals(keys[3].GetPublic()) {
+		t.Fatal("expected the chain to end at the last key")
+	}
+	current, err = VerifyKeyRotationChain(keys[0].GetPublic())
+	test.AssertNilError(t, err)
+	if !current.Equals(keys[0].GetPublic()) {
+		t.Fatal("expected an empty chain to return the root")
+	}
+
+	if _, err := VerifyKeyRotationChain(keys[1].GetPublic(), chain...); !errors.Is(err, ErrInvalidKeyRotation) {
+		t.Fatalf("expected ErrInvalidKeyRotation for the wrong root, got %v", err)
+	}
+	if _, err := VerifyKeyRotationChain(keys[0].GetPublic(), chain[0], chain[2]); !errors.Is(err, ErrInvalidKeyRotation) {
+		t.Fatalf("expected ErrInvalidKeyRotation for a broken chain, got %v", err)
+	}
+
+	env, err := SealKeyRotation(keys[1], keys[2], 1)
+	test.AssertNilError(t, err)
+	if _, err := VerifyKeyRotationChain(keys[0].GetPublic(), chain[0], env); !errors.Is(err, ErrInvalidKeyRotation) {
+		t.Fatalf("expected ErrInvalidKeyRotation for a non-increasing sequence number, got %v", err)
+	}
+}
@@ -2,9 +2,11 @@ package test
 
 import (
 	"fmt"
+	"net"
 	"testing"
 
 	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
 )
 
 func GenerateTestAddrs(n int) []ma.Multiaddr {
@@ -40,3 +42,36 @@ func AssertAddressesEqual(t *testing.T, exp, act []ma.Multiaddr) {
 		}
 	}
 }
+
+// LinkLocalAddr returns the /ip6zone/.../ip6/... multiaddr of an IPv6
+// link-local address of a network interface. It skips the test if there is
+// no such address.
+func LinkLocalAddr(t testing.TB) ma.Multiaddr {
+	t.Helper()
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatalf("failed to list interfaces: %s", err)
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			ipnet, ok := a.(*net.IPNet)
+			if !ok || ipnet.IP.To4() != nil || !ipnet.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			addr, err := manet.FromIPAndZone(ipnet.IP, iface.Name)
+			if err != nil {
+				t.Fatalf("failed to convert %s%%%s to a multiaddr: %s", ipnet.IP, iface.Name, err)
+			}
+			return addr
+		}
+	}
+	t.Skip("no interface with an IPv6 link-local address")
+	return nil
+}
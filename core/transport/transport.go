@@ -97,6 +97,24 @@ type SkipResolver interface {
 	SkipResolve(ctx context.Context, maddr ma.Multiaddr) bool
 }
 
+// NamedTransport can be optionally implemented by transports that use an
+// Upgrader, to name the transport in network.ConnectionState.Transport and
+// connmgr.SecuredConnInfo.Transport, e.g. "websocket". Without it, the
+// upgrader derives the name from the connection's multiaddr.
+type NamedTransport interface {
+	TransportName() string
+}
+
+// PathChangeNotifier can be optionally implemented by transports whose
+// connections can migrate to a new network path without being re-established,
+// e.g. QUIC connections migrating after a peer switched networks.
+type PathChangeNotifier interface {
+	// NotifyPathChange registers f to be called when the remote address of a
+	// connection changes. oldRemote is the previous remote address, the new
+	// one is returned by c.RemoteMultiaddr.
+	NotifyPathChange(f func(c CapableConn, oldRemote ma.Multiaddr))
+}
+
 // Listener is an interface closely resembling the net.Listener interface. The
 // only real difference is that Accept() returns Conn's of the type in this
 // package, and also exposes a Multiaddr method as opposed to a regular Addr
@@ -50,7 +50,8 @@ var DefaultTransports = ChainOptions(
 	Transport(libp2pwebrtc.New),
 )
 
-// DefaultPrivateTransports are the default libp2p transports when a PSK is supplied.
+// DefaultPrivateTransports are the default libp2p transports when a PSK or a
+// PSK key provider is supplied.
 //
 // Use this option when you want to *extend* the set of transports used by
 // libp2p instead of replacing them.
@@ -78,6 +79,7 @@ var RandomIdentity = func(cfg *Config) error {
 }
 
 // DefaultListenAddrs configures libp2p to use default listen address.
+// Addresses no configured transport can listen on are skipped.
 var DefaultListenAddrs = func(cfg *Config) error {
 	addrs := []string{
 		"/ip4/0.0.0.0/tcp/0",
@@ -97,6 +99,7 @@ var DefaultListenAddrs = func(cfg *Config) error {
 		}
 		listenAddrs = append(listenAddrs, addr)
 	}
+	cfg.OptionalListenAddrs = append(cfg.OptionalListenAddrs, listenAddrs...)
 	return cfg.Apply(ListenAddrs(listenAddrs...))
 }
 
@@ -157,11 +160,11 @@ var defaults = []struct {
 		opt:      DefaultListenAddrs,
 	},
 	{
-		fallback: func(cfg *Config) bool { return cfg.Transports == nil && cfg.PSK == nil },
+		fallback: func(cfg *Config) bool { return cfg.Transports == nil && cfg.PSK == nil && cfg.PSKProvider == nil },
 		opt:      DefaultTransports,
 	},
 	{
-		fallback: func(cfg *Config) bool { return cfg.Transports == nil && cfg.PSK != nil },
+		fallback: func(cfg *Config) bool { return cfg.Transports == nil && (cfg.PSK != nil || cfg.PSKProvider != nil) },
 		opt:      DefaultPrivateTransports,
 	},
 	{
@@ -2,6 +2,7 @@ package libp2p
 
 import (
 	"context"
+	gocrypto "crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -30,10 +31,13 @@ import (
 	"github.com/libp2p/go-libp2p/core/routing"
 	"github.com/libp2p/go-libp2p/core/transport"
 	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+	ppnet "github.com/libp2p/go-libp2p/p2p/net/pnet"
 	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+	tptu "github.com/libp2p/go-libp2p/p2p/net/upgrader"
 	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
 	"github.com/libp2p/go-libp2p/p2p/security/noise"
 	sectls "github.com/libp2p/go-libp2p/p2p/security/tls"
+	"github.com/libp2p/go-libp2p/p2p/transport/certhash"
 	quic "github.com/libp2p/go-libp2p/p2p/transport/quic"
 	"github.com/libp2p/go-libp2p/p2p/transport/quicreuse"
 	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
@@ -42,7 +46,9 @@ import (
 	webtransport "github.com/libp2p/go-libp2p/p2p/transport/webtransport"
 	"go.uber.org/goleak"
 
+	"github.com/ipfs/go-datastore"
 	ma "github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multibase"
 	"github.com/stretchr/testify/require"
 )
 
@@ -78,6 +84,37 @@ func TestNoListenAddrs(t *testing.T) {
 	}
 }
 
+func TestClientOnly(t *testing.T) {
+	h, err := New(ClientOnly())
+	require.NoError(t, err)
+	defer h.Close()
+	require.Empty(t, h.Addrs())
+	for _, a := range h.Network().ListenAddresses() {
+		if a.Equal(ma.StringCast("/p2p-circuit")) {
+			continue
+		}
+		_, err := a.ValueForProtocol(ma.P_QUIC_V1)
+		require.NoError(t, err, "unexpected listen address %s", a)
+	}
+
+	server, err := New(ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer server.Close()
+	require.NoError(t, h.Connect(context.Background(), peer.AddrInfo{ID: server.ID(), Addrs: server.Addrs()}))
+	require.Empty(t, h.Addrs())
+
+	for _, opts := range [][]Option{
+		{ClientOnly(), ListenAddrStrings("/ip4/127.0.0.1/tcp/0")},
+		{ListenAddrStrings("/ip4/127.0.0.1/tcp/0"), ClientOnly()},
+		{ClientOnly(), EnableRelayService()},
+		{ClientOnly(), EnableNATService()},
+		{AddrsFactory(func(addrs []ma.Multiaddr) []ma.Multiaddr { return addrs }), ClientOnly()},
+	} {
+		_, err := New(opts...)
+		require.Error(t, err)
+	}
+}
+
 func TestNoTransports(t *testing.T) {
 	ctx := context.Background()
 	a, err := New(NoTransports)
@@ -260,6 +297,101 @@ func TestTransportConstructorWithWrongOpts(t *testing.T) {
 	require.EqualError(t, err, "transport constructor doesn't take any options")
 }
 
+func TestListenAddrErrors(t *testing.T) {
+	// No transport for QUIC.
+	_, err := New(
+		Transport(tcp.NewTCPTransport),
+		ListenAddrStrings("/ip4/127.0.0.1/tcp/0", "/ip4/127.0.0.1/udp/0/quic-v1"),
+	)
+	require.ErrorIs(t, err, swarm.ErrNoTransport)
+
+	// The default listen addresses are skipped if there's no transport.
+	h, err := New(
+		Transport(tcp.NewTCPTransport),
+		DefaultListenAddrs,
+	)
+	require.NoError(t, err)
+	h.Close()
+
+	// get a free port
+	l, err := net.Listen("tcp", "0.0.0.0:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	_, err = New(
+		Transport(tcp.NewTCPTransport),
+		ListenAddrStrings(fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", port), fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port)),
+	)
+	require.ErrorIs(t, err, swarm.ErrListenAddrConflict)
+	var lerr *swarm.ListenError
+	require.ErrorAs(t, err, &lerr)
+	require.Len(t, lerr.ListenErrors, 1)
+	require.Equal(t, fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port), lerr.ListenErrors[0].Address.String())
+}
+
+func TestSignerIdentity(t *testing.T) {
+	stdKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	// Only the crypto.Signer interface is passed to the host.
+	priv, _, err := crypto.KeyPairFromSigner(struct{ cryptoSigner }{stdKey})
+	require.NoError(t, err)
+	h, err := New(
+		Identity(priv),
+		ListenAddrStrings(
+			"/ip4/127.0.0.1/tcp/0",
+			"/ip4/127.0.0.1/udp/0/quic-v1",
+			"/ip4/127.0.0.1/udp/0/quic-v1/webtransport",
+		),
+	)
+	require.NoError(t, err)
+	defer h.Close()
+	id, err := peer.IDFromPrivateKey(priv)
+	require.NoError(t, err)
+	require.Equal(t, id, h.ID())
+
+	for _, tc := range []struct {
+		name     string
+		protocol int
+		opts     []Option
+	}{
+		{"TCP noise", ma.P_TCP, []Option{Security(noise.ID, noise.New)}},
+		{"TCP TLS", ma.P_TCP, []Option{Security(sectls.ID, sectls.New)}},
+		{"QUIC", ma.P_QUIC_V1, nil},
+		{"WebTransport", ma.P_WEBTRANSPORT, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var addrs []ma.Multiaddr
+			for _, a := range h.Addrs() {
+				if _, err := a.ValueForProtocol(tc.protocol); err != nil {
+					continue
+				}
+				if tc.protocol == ma.P_QUIC_V1 {
+					if _, err := a.ValueForProtocol(ma.P_WEBTRANSPORT); err == nil {
+						continue
+					}
+				}
+				addrs = append(addrs, a)
+			}
+			require.NotEmpty(t, addrs)
+
+			client, err := New(append(tc.opts, NoListenAddrs)...)
+			require.NoError(t, err)
+			defer client.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			require.NoError(t, client.Connect(ctx, peer.AddrInfo{ID: h.ID(), Addrs: addrs}))
+			res := <-ping.Ping(ctx, client, h.ID())
+			require.NoError(t, res.Error)
+		})
+	}
+}
+
+// cryptoSigner hides the concrete type of a signer.
+type cryptoSigner interface {
+	Public() gocrypto.PublicKey
+	Sign(rand io.Reader, digest []byte, opts gocrypto.SignerOpts) ([]byte, error)
+}
+
 func TestSecurityConstructor(t *testing.T) {
 	h, err := New(
 		Transport(tcp.NewTCPTransport),
@@ -299,6 +431,30 @@ func TestSecurityConstructor(t *testing.T) {
 	require.NoError(t, h2.Connect(context.Background(), ai))
 }
 
+func TestSecurityOptions(t *testing.T) {
+	newHost := func(t *testing.T, prologue string) host.Host {
+		h, err := New(
+			Transport(tcp.NewTCPTransport),
+			Security(noise.ID, noise.New, noise.WithPrologue([]byte(prologue)), noise.WithRekeyInterval(1<<20, 0)),
+			ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+			DisableRelay(),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() { h.Close() })
+		return h
+	}
+
+	h := newHost(t, "foo")
+	require.NoError(t, newHost(t, "foo").Connect(context.Background(), peer.AddrInfo{ID: h.ID(), Addrs: h.Addrs()}))
+	// the handshake fails with a different prologue
+	require.Error(t, newHost(t, "bar").Connect(context.Background(), peer.AddrInfo{ID: h.ID(), Addrs: h.Addrs()}))
+
+	_, err := New(Security(noise.ID, noise.New, "not an option"))
+	require.Error(t, err)
+	_, err = New(Security(sectls.ID, sectls.New, noise.WithPrologue(nil)))
+	require.Error(t, err)
+}
+
 func TestTransportConstructorWebTransport(t *testing.T) {
 	h, err := New(
 		Transport(webtransport.New),
@@ -498,6 +654,63 @@ func TestHostAddrsFactoryAddsCerthashes(t *testing.T) {
 	h.Close()
 }
 
+func TestSharedCertHashes(t *testing.T) {
+	certhashes := func(h host.Host) (wt, wrtc []string) {
+		decode := func(a ma.Multiaddr) []string {
+			var hashes []string
+			for _, c := range a {
+				if c.Protocol().Code == ma.P_CERTHASH {
+					_, b, err := multibase.Decode(c.Value())
+					require.NoError(t, err)
+					hashes = append(hashes, string(b))
+				}
+			}
+			return hashes
+		}
+		for _, a := range h.Addrs() {
+			if ok, _ := webtransport.IsWebtransportMultiaddr(a); ok {
+				wt = decode(a)
+			}
+			if ok, _ := libp2pwebrtc.IsWebRTCDirectMultiaddr(a); ok {
+				wrtc = decode(a)
+			}
+		}
+		return wt, wrtc
+	}
+	newHost := func(opts ...Option) host.Host {
+		h, err := New(append([]Option{
+			ListenAddrStrings(
+				"/ip4/127.0.0.1/udp/0/quic-v1/webtransport",
+				"/ip4/127.0.0.1/udp/0/webrtc-direct",
+			),
+			Transport(webtransport.New),
+			Transport(libp2pwebrtc.New),
+		}, opts...)...)
+		require.NoError(t, err)
+		t.Cleanup(func() { h.Close() })
+		return h
+	}
+
+	t.Run("shared", func(t *testing.T) {
+		wt, wrtc := certhashes(newHost(ShareCertHashes()))
+		// WebTransport advertises the current and the next certificate.
+		require.Len(t, wt, 2)
+		require.Equal(t, wt[:1], wrtc)
+	})
+
+	t.Run("not shared by default", func(t *testing.T) {
+		wt, wrtc := certhashes(newHost())
+		require.Len(t, wt, 2)
+		require.Len(t, wrtc, 1)
+		require.NotContains(t, wt, wrtc[0])
+	})
+
+	t.Run("store requires sharing", func(t *testing.T) {
+		_, err := New(CertHashStore(certhash.NewDatastoreStore(datastore.NewMapDatastore())))
+		require.Error(t, err)
+	})
+}
+
 func newRandomPort(t *testing.T) string {
 	t.Helper()
 	// Find an available port
@@ -784,6 +997,38 @@ func TestSharedTCPAddr(t *testing.T) {
 	require.ErrorContains(t, err, "cannot use shared TCP listener with PSK")
 }
 
+func TestPrivateNetworkKeyProvider(t *testing.T) {
+	psk := make([]byte, 32)
+	_, err := rand.Read(psk)
+	require.NoError(t, err)
+	kp, err := ppnet.NewKeyProvider(psk)
+	require.NoError(t, err)
+
+	h1, err := New(PrivateNetwork(psk), ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer h1.Close()
+	h2, err := New(PrivateNetworkKeyProvider(kp), ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer h2.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, h2.Connect(ctx, peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+	res := <-ping.Ping(ctx, h2, h1.ID())
+	require.NoError(t, res.Error)
+
+	// a host without the PSK can't connect
+	h3, err := New(ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer h3.Close()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.Error(t, h3.Connect(ctx, peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+
+	_, err = New(PrivateNetwork(psk), PrivateNetworkKeyProvider(kp))
+	require.ErrorContains(t, err, "cannot specify multiple private network options")
+}
+
 func TestCustomTCPDialer(t *testing.T) {
 	expectedErr := errors.New("custom dialer called, but not implemented")
 	customDialer := func(_ ma.Multiaddr) (tcp.ContextDialer, error) {
@@ -825,3 +1070,86 @@ func BenchmarkAllAddrs(b *testing.B) {
 		addrsHost.AllAddrs()
 	}
 }
+
+func TestConnectionAdmission(t *testing.T) {
+	type listenerKey struct{}
+	h1, err := New(
+		Transport(tcp.NewTCPTransport),
+		ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+		ConnectionAdmission(func(info tptu.ConnInfo) (map[any]any, error) {
+			if info.Direction == network.DirInbound {
+				return map[any]any{listenerKey{}: info.ListenAddr}, nil
+			}
+			return nil, nil
+		}),
+	)
+	require.NoError(t, err)
+	defer h1.Close()
+
+	h2, err := New(
+		Transport(tcp.NewTCPTransport),
+		NoListenAddrs,
+		ConnectionAdmission(func(info tptu.ConnInfo) (map[any]any, error) {
+			if info.Peer == h1.ID() {
+				return nil, errors.New("blocked")
+			}
+			return nil, nil
+		}),
+	)
+	require.NoError(t, err)
+	defer h2.Close()
+
+	err = h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()})
+	require.ErrorContains(t, err, "blocked")
+
+	h3, err := New(Transport(tcp.NewTCPTransport), NoListenAddrs)
+	require.NoError(t, err)
+	defer h3.Close()
+	require.NoError(t, h3.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+	require.Eventually(t, func() bool { return len(h1.Network().ConnsToPeer(h3.ID())) == 1 }, 5*time.Second, 10*time.Millisecond)
+	c := h1.Network().ConnsToPeer(h3.ID())[0]
+	// the relay transport listens on /p2p-circuit too
+	var tcpAddr ma.Multiaddr
+	for _, a := range h1.Network().ListenAddresses() {
+		if _, err := a.ValueForProtocol(ma.P_TCP); err == nil {
+			tcpAddr = a
+		}
+	}
+	require.Equal(t, tcpAddr, c.Stat().Extra[listenerKey{}])
+}
+
+func TestConnMuxerStats(t *testing.T) {
+	// metrics are enabled by default, which wraps the connections in the swarm
+	h1, err := New(Transport(tcp.NewTCPTransport), ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer h1.Close()
+	h2, err := New(Transport(tcp.NewTCPTransport), NoListenAddrs)
+	require.NoError(t, err)
+	defer h2.Close()
+
+	h1.SetStreamHandler("/test", func(s network.Stream) {
+		defer s.Close()
+		io.Copy(io.Discard, s)
+	})
+	require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+	for i := 0; i < 3; i++ {
+		s, err := h2.NewStream(context.Background(), h1.ID(), "/test")
+		require.NoError(t, err)
+		defer s.Reset()
+		_, err = s.Write([]byte("hello"))
+		require.NoError(t, err)
+	}
+
+	openStreams := func(h host.Host, p peer.ID) int {
+		conns := h.Network().ConnsToPeer(p)
+		if len(conns) != 1 {
+			return -1
+		}
+		stats, ok := conns[0].(network.ConnMuxerStats)
+		require.True(t, ok)
+		return stats.MuxerStats().OpenStreams
+	}
+	// identify may still have a stream open
+	require.Eventually(t, func() bool { return openStreams(h2, h1.ID()) == 3 }, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return openStreams(h1, h2.ID()) == 3 }, 5*time.Second, 10*time.Millisecond)
+}
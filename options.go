@@ -21,13 +21,20 @@ import (
 	"github.com/libp2p/go-libp2p/core/pnet"
 	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/libp2p/go-libp2p/core/transport"
+	"github.com/libp2p/go-libp2p/p2p/host/autonat"
 	"github.com/libp2p/go-libp2p/p2p/host/autorelay"
 	bhost "github.com/libp2p/go-libp2p/p2p/host/basic"
+	"github.com/libp2p/go-libp2p/p2p/host/probecoord"
+	"github.com/libp2p/go-libp2p/p2p/host/timeline"
+	ppnet "github.com/libp2p/go-libp2p/p2p/net/pnet"
 	"github.com/libp2p/go-libp2p/p2p/net/swarm"
 	tptu "github.com/libp2p/go-libp2p/p2p/net/upgrader"
 	relayv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
 	"github.com/libp2p/go-libp2p/p2p/protocol/holepunch"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
+	"github.com/libp2p/go-libp2p/p2p/transport/certhash"
 	"github.com/libp2p/go-libp2p/p2p/transport/quicreuse"
+	"github.com/libp2p/go-libp2p/p2p/transport/tcpreuse"
 	"github.com/prometheus/client_golang/prometheus"
 
 	ma "github.com/multiformats/go-multiaddr"
@@ -70,12 +77,27 @@ func ListenAddrs(addrs ...ma.Multiaddr) Option {
 // * Host
 // * Network
 // * Peerstore
-func Security(name string, constructor interface{}) Option {
+//
+// Like for Transport, opts are passed to a variadic constructor, e.g. the
+// options of noise.New.
+func Security(name string, constructor interface{}, opts ...interface{}) Option {
 	return func(cfg *Config) error {
 		if cfg.Insecure {
 			return fmt.Errorf("cannot use security transports with an insecure libp2p configuration")
 		}
-		cfg.SecurityTransports = append(cfg.SecurityTransports, config.Security{ID: protocol.ID(name), Constructor: constructor})
+		if len(opts) > 0 {
+			typ := reflect.TypeOf(constructor)
+			if typ.Kind() != reflect.Func || !typ.IsVariadic() {
+				return errors.New("security transport constructor doesn't take any options")
+			}
+			paramType := typ.In(typ.NumIn() - 1).Elem()
+			for _, opt := range opts {
+				if typ := reflect.TypeOf(opt); !typ.AssignableTo(paramType) {
+					return fmt.Errorf("security transport option of type %s not assignable to %s", typ, paramType)
+				}
+			}
+		}
+		cfg.SecurityTransports = append(cfg.SecurityTransports, config.Security{ID: protocol.ID(name), Constructor: constructor, Opts: opts})
 		return nil
 	}
 }
@@ -209,7 +231,7 @@ func Peerstore(ps peerstore.Peerstore) Option {
 // PrivateNetwork configures libp2p to use the given private network protector.
 func PrivateNetwork(psk pnet.PSK) Option {
 	return func(cfg *Config) error {
-		if cfg.PSK != nil {
+		if cfg.PSK != nil || cfg.PSKProvider != nil {
 			return fmt.Errorf("cannot specify multiple private network options")
 		}
 
@@ -218,6 +240,67 @@ func PrivateNetwork(psk pnet.PSK) Option {
 	}
 }
 
+// PrivateNetworkKeyProvider configures libp2p to use a private network whose
+// PSK is only accessible through kp, e.g. because it's kept in an HSM or a
+// KMS. It can't be combined with PrivateNetwork.
+//
+// This only applies to transports that use the upgrader, e.g. TCP and
+// WebSocket. Other transports are rejected.
+func PrivateNetworkKeyProvider(kp pnet.KeyProvider) Option {
+	return func(cfg *Config) error {
+		if cfg.PSK != nil || cfg.PSKProvider != nil {
+			return fmt.Errorf("cannot specify multiple private network options")
+		}
+		if kp == nil {
+			return errors.New("nil private network key provider")
+		}
+		cfg.PSKProvider = kp
+		return nil
+	}
+}
+
+// PrivateNetworkModes makes private network protection negotiate one of the
+// given authenticated modes, which detect tampering with every frame, instead
+// of using the legacy XSalsa20 protector. Nodes using the legacy protector
+// can't connect to nodes using the negotiated modes.
+//
+// This only applies to transports that use the upgrader, e.g. TCP and
+// WebSocket.
+func PrivateNetworkModes(modes ...ppnet.Mode) Option {
+	return func(cfg *Config) error {
+		if cfg.PSKModes != nil {
+			return errors.New("cannot specify multiple private network modes options")
+		}
+		if len(modes) == 0 {
+			return errors.New("no private network modes")
+		}
+		for _, m := range modes {
+			if !m.Negotiated() {
+				return fmt.Errorf("private network mode %s can't be negotiated", m)
+			}
+		}
+		cfg.PSKModes = modes
+		return nil
+	}
+}
+
+// PrivateNetworkExemption exempts the connections described by e from private
+// network protection, e.g. connections to a local admin API or sidecar over
+// loopback. Protection is still enforced on all other connections. Exemptions
+// are logged and emitted as event.EvtPrivateNetworkExemption.
+//
+// This only applies to transports that use the upgrader, e.g. TCP and
+// WebSocket.
+func PrivateNetworkExemption(e tptu.PSKExemption) Option {
+	return func(cfg *Config) error {
+		if cfg.PSKExemption != nil {
+			return errors.New("cannot specify multiple private network exemption options")
+		}
+		cfg.PSKExemption = &e
+		return nil
+	}
+}
+
 // BandwidthReporter configures libp2p to use the given bandwidth reporter.
 func BandwidthReporter(rep metrics.Reporter) Option {
 	return func(cfg *Config) error {
@@ -387,6 +470,33 @@ func AutoNATServiceRateLimit(global, perPeer int, interval time.Duration) Option
 	}
 }
 
+// AutoNATScheduler configures when the AutoNAT client probes the reachability
+// of the host and which peers it asks to dial it back, e.g. to probe less
+// often on battery-powered devices.
+func AutoNATScheduler(s autonat.Scheduler) Option {
+	return func(cfg *Config) error {
+		if s == nil {
+			return errors.New("autonat scheduler must not be nil")
+		}
+		cfg.AutoNATConfig.Scheduler = s
+		return nil
+	}
+}
+
+// ProbeCoordinator schedules the background probes of AutoNAT, AutoRelay and
+// hole punching with the timers of c. The timers are jittered, and spaced out
+// so that they don't wake up the radio of mobile devices in bursts. Pass c to
+// ping.WithProbeCoordinator to coordinate keepalive pings too.
+func ProbeCoordinator(c *probecoord.Coordinator) Option {
+	return func(cfg *Config) error {
+		if c == nil {
+			return errors.New("probe coordinator must not be nil")
+		}
+		cfg.ProbeCoordinator = c
+		return nil
+	}
+}
+
 // ConnectionGater configures libp2p to use the given ConnectionGater
 // to actively reject inbound/outbound connections based on the lifecycle stage
 // of the connection.
@@ -402,6 +512,22 @@ func ConnectionGater(cg connmgr.ConnectionGater) Option {
 	}
 }
 
+// ConnectionAdmission configures libp2p to call f for every connection before
+// it is upgraded, i.e. before the security handshake. f can reject a
+// connection, or annotate it. See upgrader.AdmissionFunc for details.
+//
+// This only applies to transports that use the upgrader, e.g. TCP and
+// WebSocket. Transports with built-in security, like QUIC, are not affected.
+func ConnectionAdmission(f tptu.AdmissionFunc) Option {
+	return func(cfg *Config) error {
+		if cfg.ConnAdmission != nil {
+			return errors.New("cannot configure multiple connection admission functions")
+		}
+		cfg.ConnAdmission = f
+		return nil
+	}
+}
+
 // ResourceManager configures libp2p to use the given ResourceManager.
 // When using the p2p/host/resource-manager implementation of the ResourceManager interface,
 // it is recommended to set limits for libp2p protocol by calling SetDefaultServiceLimits.
@@ -467,6 +593,43 @@ var NoListenAddrs = func(cfg *Config) error {
 	return nil
 }
 
+// ClientOnly configures libp2p for hosts that only make outbound connections,
+// such as browsers and mobile apps, replacing a combination of NoListenAddrs,
+// EnableRelay, EnableHolePunching, ForceReachabilityPrivate and an AddrsFactory
+// hiding all addresses:
+//
+//   - The host doesn't listen on any configured address, and doesn't
+//     advertise any address, neither direct nor relayed.
+//   - It dials peers behind NATs through their relays, and takes part in the
+//     hole punches these peers start to upgrade to a direct connection. To
+//     learn its public addresses for hole punching, it dials QUIC connections
+//     from ephemeral, unadvertised listening ports.
+//   - AutoNAT probing is disabled, as the host never expects to be dialed.
+//
+// It can't be combined with ListenAddrs, AddrsFactory, EnableRelayService,
+// EnableAutoRelay, EnableNATService or NATPortMap.
+func ClientOnly() Option {
+	return func(cfg *Config) error {
+		if cfg.AddrsFactory != nil {
+			return errors.New("cannot use an address factory with a client-only host")
+		}
+		if len(cfg.ListenAddrs) > 0 {
+			return errors.New("cannot listen on addresses with a client-only host")
+		}
+		cfg.ClientOnly = true
+		cfg.ListenAddrs = []ma.Multiaddr{}
+		cfg.RelayCustom = true
+		cfg.Relay = true
+		cfg.EnableHolePunching = true
+		if cfg.AutoNATConfig.ForceReachability == nil {
+			private := network.ReachabilityPrivate
+			cfg.AutoNATConfig.ForceReachability = &private
+		}
+		cfg.AddrsFactory = func([]ma.Multiaddr) []ma.Multiaddr { return nil }
+		return nil
+	}
+}
+
 // NoTransports will configure libp2p to not enable any transports.
 //
 // This will both clear any configured transports (specified in prior libp2p
@@ -540,6 +703,18 @@ func EnableHolePunching(opts ...holepunch.Option) Option {
 	}
 }
 
+// EnablePeerTimeline keeps a bounded history of the connection events of each
+// peer: dials, connections, disconnections and hole punches. It is accessible
+// via BasicHost.PeerTimeline, and BasicHost.Timeline can be served on a debug
+// endpoint.
+func EnablePeerTimeline(opts ...timeline.Option) Option {
+	return func(cfg *Config) error {
+		cfg.EnablePeerTimeline = true
+		cfg.PeerTimelineOptions = opts
+		return nil
+	}
+}
+
 func WithDialTimeout(t time.Duration) Option {
 	return func(cfg *Config) error {
 		if t <= 0 {
@@ -609,6 +784,64 @@ func DisableIdentifyAddressDiscovery() Option {
 	}
 }
 
+// IdentifyRefreshInterval configures the host to periodically re-run identify on
+// connections, to pick up address and protocol changes of peers that don't send
+// identify pushes. A peer is identified again after interval plus a random
+// duration of up to jitter. Use BasicHost.LastIdentified to find out how
+// stale a peer's identify information is.
+func IdentifyRefreshInterval(interval, jitter time.Duration) Option {
+	return func(cfg *Config) error {
+		if interval <= 0 || jitter < 0 {
+			return errors.New("identify refresh interval must be positive and jitter must not be negative")
+		}
+		cfg.IdentifyRefreshInterval = interval
+		cfg.IdentifyRefreshJitter = jitter
+		return nil
+	}
+}
+
+// IdentifyPushCoalescing limits the identify pushes sent when the host's
+// addresses or protocols change rapidly. Pushes are delayed by debounce to
+// coalesce changes, and no peer is pushed to more than once per minInterval.
+// See identify.WithPushCoalescing.
+func IdentifyPushCoalescing(debounce, minInterval time.Duration) Option {
+	return func(cfg *Config) error {
+		if debounce < 0 || minInterval < 0 {
+			return errors.New("identify push debounce and minimum interval must not be negative")
+		}
+		cfg.IdentifyPushDebounce = debounce
+		cfg.IdentifyPushMinInterval = minInterval
+		return nil
+	}
+}
+
+// IdentifyAddrCrossCheck discards or down-ranks the addresses claimed by peers
+// in identify that are inconsistent with the source address of the
+// connection. See identify.WithAddrCrossCheck.
+func IdentifyAddrCrossCheck(mode identify.AddrCrossCheck) Option {
+	return func(cfg *Config) error {
+		if mode < identify.AddrCrossCheckDisabled || mode > identify.AddrCrossCheckDiscard {
+			return errors.New("invalid identify address cross-check mode")
+		}
+		cfg.IdentifyAddrCrossCheck = mode
+		return nil
+	}
+}
+
+// ProtocolNegotiationCache caches the protocols that peers refused during
+// protocol negotiation for ttl, so that opening streams for these protocols
+// fails without a round trip. A peer's entries are invalidated when it is
+// identified again or pushes an update of its protocols.
+func ProtocolNegotiationCache(ttl time.Duration) Option {
+	return func(cfg *Config) error {
+		if ttl <= 0 {
+			return errors.New("protocol negotiation cache TTL must be positive")
+		}
+		cfg.ProtocolNegotiationCacheTTL = ttl
+		return nil
+	}
+}
+
 // EnableAutoNATv2 enables autonat v2
 func EnableAutoNATv2() Option {
 	return func(cfg *Config) error {
@@ -617,6 +850,22 @@ func EnableAutoNATv2() Option {
 	}
 }
 
+// VerifyRelayAddrs makes the host advertise its relay addresses only after
+// autonat v2 has verified them with a dial-back over the relay, and stop
+// advertising them when verification fails, so that peers don't waste dials
+// on broken relay reservations. The verification state of the relay addresses
+// is reported by BasicHost.ConfirmedAddrs and the EvtHostReachableAddrsChanged
+// event.
+//
+// Requires EnableAutoNATv2. Verification only succeeds with autonat v2
+// servers that support dialing back relay addresses.
+func VerifyRelayAddrs() Option {
+	return func(cfg *Config) error {
+		cfg.VerifyRelayAddrs = true
+		return nil
+	}
+}
+
 // UDPBlackHoleSuccessCounter configures libp2p to use f as the black hole filter for UDP addrs
 func UDPBlackHoleSuccessCounter(f *swarm.BlackHoleSuccessCounter) Option {
 	return func(cfg *Config) error {
@@ -644,14 +893,65 @@ func WithFxOption(opts ...fx.Option) Option {
 	}
 }
 
+// ShareCertHashes makes the WebTransport and WebRTC Direct transports use the
+// same certificates, so that they advertise the same certificate hashes.
+//
+// Shared certificates are rotated every few days, as browsers require for
+// WebTransport. A WebRTC Direct address only carries a single certificate
+// hash, so WebRTC Direct addresses advertised before a rotation stop working
+// after it. Without this option, WebRTC Direct keeps the certificate it
+// generated on startup.
+func ShareCertHashes() Option {
+	return func(cfg *Config) error {
+		cfg.ShareCertHashes = true
+		return nil
+	}
+}
+
+// CertHashStore configures a store to persist the key the certificates shared
+// using ShareCertHashes are derived from. It's only used if the host key isn't
+// exportable, e.g. if it's backed by a hardware security module. Otherwise,
+// the certificates are derived from the host key. Without a store, the
+// certificate hashes of such hosts change on every restart.
+func CertHashStore(s certhash.Store) Option {
+	return func(cfg *Config) error {
+		if cfg.CertHashStore != nil {
+			return errors.New("cannot specify multiple cert hash stores")
+		}
+		cfg.CertHashStore = s
+		return nil
+	}
+}
+
+// QUICConnectionMigration enables migrating dialed QUIC connections to a new
+// local socket using network.MigratableConn. If filter is non-nil, it's called
+// when a peer migrates a connection to a new remote address, and a refused
+// migration closes the connection. This option is ignored if the QUIC
+// connection manager is replaced using QUICReuse; pass
+// quicreuse.EnableConnectionMigration and quicreuse.WithMigrationFilter to it
+// instead.
+func QUICConnectionMigration(filter quicreuse.MigrationFilter) Option {
+	return func(cfg *Config) error {
+		if cfg.QUICConnectionMigration {
+			return errors.New("cannot specify multiple QUIC connection migration options")
+		}
+		cfg.QUICConnectionMigration = true
+		cfg.QUICMigrationFilter = filter
+		return nil
+	}
+}
+
 // ShareTCPListener shares the same listen address between TCP and Websocket
 // transports. This lets both transports use the same TCP port.
 //
 // Currently this behavior is Opt-in. In a future release this will be the
 // default, and this option will be removed.
-func ShareTCPListener() Option {
+//
+// opts configure the shared listeners, e.g. tcpreuse.WithAcceptLoops.
+func ShareTCPListener(opts ...tcpreuse.Option) Option {
 	return func(cfg *Config) error {
 		cfg.ShareTCPListener = true
+		cfg.ShareTCPListenerOpts = opts
 		return nil
 	}
 }
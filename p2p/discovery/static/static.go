@@ -0,0 +1,354 @@
+// Package static implements discovery.Discovery with a static list of peers,
+// read from a file or URL. This lets fleets with centrally managed topologies
+// use the standard discovery interfaces.
+//
+// The list is a JSON document:
+//
+//	{
+//	  "ttl": "1h",
+//	  "peers": [
+//	    {
+//	      "id": "12D3KooW...",
+//	      "addrs": ["/ip4/192.0.2.1/tcp/4001"],
+//	      "namespaces": ["my-app"],
+//	      "expires": "2030-01-01T00:00:00Z"
+//	    }
+//	  ]
+//	}
+//
+// A peer without namespaces is found in every namespace. Peers are not found
+// after their optional expiry time. If the list sets a TTL, no peers are found
+// once the list couldn't be reloaded for longer than the TTL.
+package static
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/discovery"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	logging "github.com/ipfs/go-log/v2"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+var log = logging.Logger("discovery-static")
+
+const (
+	// DefaultPollInterval is how often the list is checked for changes.
+	DefaultPollInterval = 10 * time.Second
+
+	// maxListSize is the maximum size of a list.
+	maxListSize = 16 << 20
+)
+
+// ErrAdvertiseNotSupported is returned by Advertise. The list of peers is
+// managed outside of libp2p.
+var ErrAdvertiseNotSupported = errors.New("static discovery doesn't support advertising")
+
+var _ discovery.Discovery = (*Discovery)(nil)
+
+// Option is an option for New.
+type Option func(*Discovery) error
+
+// WithPollInterval sets how often the list is checked for changes. Files are
+// only read if their size or modification time changed. URLs are fetched with
+// the ETag of the last response, if the server sent one.
+func WithPollInterval(interval time.Duration) Option {
+	return func(d *Discovery) error {
+		if interval <= 0 {
+			return errors.New("poll interval must be positive")
+		}
+		d.pollInterval = interval
+		return nil
+	}
+}
+
+// WithHTTPClient sets the client used to fetch lists from URLs. By default,
+// http.DefaultClient is used.
+func WithHTTPClient(c *http.Client) Option {
+	return func(d *Discovery) error {
+		if c == nil {
+			return errors.New("http client is nil")
+		}
+		d.client = c
+		return nil
+	}
+}
+
+// Discovery finds peers in a static list, see the package documentation for
+// the format of the list. It reloads the list when it changes.
+type Discovery struct {
+	source       string
+	isURL        bool
+	pollInterval time.Duration
+	client       *http.Client
+
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	wg        sync.WaitGroup
+
+	// version identifies the loaded version of the list: the size and
+	// modification time of a file, or the ETag of a URL.
+	version string
+	digest  [sha256.Size]byte
+
+	mx    sync.RWMutex
+	peers []record
+	ttl   time.Duration
+	// loaded is the last time the list was loaded, or found unchanged.
+	loaded time.Time
+}
+
+type record struct {
+	info       peer.AddrInfo
+	namespaces []string
+	expires    time.Time
+}
+
+// New returns a Discovery reading the list from source, a file path or an
+// http(s) URL. It fails if the list can't be loaded. Later errors reloading
+// the list are logged, and the last loaded list is used.
+func New(source string, opts ...Option) (*Discovery, error) {
+	d := &Discovery{
+		source:       source,
+		isURL:        strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://"),
+		pollInterval: DefaultPollInterval,
+		client:       http.DefaultClient,
+	}
+	for _, o := range opts {
+		if err := o(d); err != nil {
+			return nil, err
+		}
+	}
+	d.ctx, d.ctxCancel = context.WithCancel(context.Background())
+	if err := d.reload(); err != nil {
+		d.ctxCancel()
+		return nil, fmt.Errorf("failed to load peer list from %s: %w", source, err)
+	}
+	d.wg.Add(1)
+	go d.poll()
+	return d, nil
+}
+
+// Close stops reloading the list.
+func (d *Discovery) Close() error {
+	d.ctxCancel()
+	d.wg.Wait()
+	return nil
+}
+
+// Advertise returns ErrAdvertiseNotSupported.
+func (d *Discovery) Advertise(context.Context, string, ...discovery.Option) (time.Duration, error) {
+	return 0, ErrAdvertiseNotSupported
+}
+
+// FindPeers returns the peers of the list in namespace ns.
+func (d *Discovery) FindPeers(ctx context.Context, ns string, opts ...discovery.Option) (<-chan peer.AddrInfo, error) {
+	var options discovery.Options
+	if err := options.Apply(opts...); err != nil {
+		return nil, err
+	}
+	peers := d.peersIn(ns, time.Now())
+	if options.Limit > 0 && len(peers) > options.Limit {
+		peers = peers[:options.Limit]
+	}
+
+	ch := make(chan peer.AddrInfo, len(peers))
+	for _, p := range peers {
+		ch <- p
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (d *Discovery) peersIn(ns string, now time.Time) []peer.AddrInfo {
+	d.mx.RLock()
+	defer d.mx.RUnlock()
+
+	if d.ttl > 0 && now.Sub(d.loaded) > d.ttl {
+		log.Debugf("peer list from %s is older than its TTL", d.source)
+		return nil
+	}
+	var peers []peer.AddrInfo
+	for _, r := range d.peers {
+		if !r.expires.IsZero() && now.After(r.expires) {
+			continue
+		}
+		if len(r.namespaces) > 0 && !slices.Contains(r.namespaces, ns) {
+			continue
+		}
+		peers = append(peers, r.info)
+	}
+	return peers
+}
+
+func (d *Discovery) poll() {
+	defer d.wg.Done()
+	t := time.NewTicker(d.pollInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := d.reload(); err != nil {
+				log.Warnf("failed to reload peer list from %s: %s", d.source, err)
+			}
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// reload loads the list if it changed. It is only called by one goroutine at
+// a time.
+func (d *Discovery) reload() error {
+	var data []byte
+	var version string
+	var err error
+	if d.isURL {
+		data, version, err = d.fetch()
+	} else {
+		data, version, err = d.read()
+	}
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		d.touch()
+		return nil
+	}
+	// Files may be rewritten without changing their contents.
+	digest := sha256.Sum256(data)
+	if digest == d.digest {
+		d.version = version
+		d.touch()
+		return nil
+	}
+
+	peers, ttl, err := parseList(data)
+	if err != nil {
+		return err
+	}
+	d.version = version
+	d.digest = digest
+	d.mx.Lock()
+	d.peers = peers
+	d.ttl = ttl
+	d.loaded = time.Now()
+	d.mx.Unlock()
+	log.Debugf("loaded %d peers from %s", len(peers), d.source)
+	return nil
+}
+
+// touch records that the loaded list is still current.
+func (d *Discovery) touch() {
+	d.mx.Lock()
+	d.loaded = time.Now()
+	d.mx.Unlock()
+}
+
+// read reads the list from a file. It returns nil data if the file didn't
+// change since the last read.
+func (d *Discovery) read() (data []byte, version string, err error) {
+	fi, err := os.Stat(d.source)
+	if err != nil {
+		return nil, "", err
+	}
+	version = fmt.Sprintf("%d-%d", fi.Size(), fi.ModTime().UnixNano())
+	if version == d.version {
+		return nil, version, nil
+	}
+	if fi.Size() > maxListSize {
+		return nil, "", errors.New("peer list too large")
+	}
+	data, err = os.ReadFile(d.source)
+	return data, version, err
+}
+
+// fetch fetches the list from a URL. It returns nil data if the server
+// reports that the list didn't change.
+func (d *Discovery) fetch() (data []byte, version string, err error) {
+	ctx, cancel := context.WithTimeout(d.ctx, max(d.pollInterval, 10*time.Second))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.source, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if d.version != "" {
+		req.Header.Set("If-None-Match", d.version)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil, d.version, nil
+	default:
+		return nil, "", fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	data, err = io.ReadAll(io.LimitReader(resp.Body, maxListSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxListSize {
+		return nil, "", errors.New("peer list too large")
+	}
+	return data, resp.Header.Get("ETag"), nil
+}
+
+type listJSON struct {
+	TTL   string `json:"ttl,omitempty"`
+	Peers []struct {
+		ID         peer.ID   `json:"id"`
+		Addrs      []string  `json:"addrs"`
+		Namespaces []string  `json:"namespaces,omitempty"`
+		Expires    time.Time `json:"expires"`
+	} `json:"peers"`
+}
+
+func parseList(data []byte) ([]record, time.Duration, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var l listJSON
+	if err := dec.Decode(&l); err != nil {
+		return nil, 0, fmt.Errorf("invalid peer list: %w", err)
+	}
+	var ttl time.Duration
+	if l.TTL != "" {
+		var err error
+		ttl, err = time.ParseDuration(l.TTL)
+		if err != nil || ttl < 0 {
+			return nil, 0, fmt.Errorf("invalid peer list TTL %q", l.TTL)
+		}
+	}
+	peers := make([]record, 0, len(l.Peers))
+	for _, p := range l.Peers {
+		if p.ID == "" {
+			return nil, 0, errors.New("invalid peer list: peer without ID")
+		}
+		r := record{info: peer.AddrInfo{ID: p.ID}, namespaces: p.Namespaces, expires: p.Expires}
+		for _, s := range p.Addrs {
+			a, err := ma.NewMultiaddr(s)
+			if err != nil {
+				return nil, 0, fmt.Errorf("invalid address %q of peer %s: %w", s, p.ID, err)
+			}
+			r.info.Addrs = append(r.info.Addrs, a)
+		}
+		peers = append(peers, r)
+	}
+	return peers, ttl, nil
+}
@@ -0,0 +1,132 @@
+package static
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/discovery"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/test"
+
+	"github.com/stretchr/testify/require"
+)
+
+func findPeers(t *testing.T, d *Discovery, ns string, opts ...discovery.Option) []peer.ID {
+	t.Helper()
+	ch, err := d.FindPeers(context.Background(), ns, opts...)
+	require.NoError(t, err)
+	var ids []peer.ID
+	for ai := range ch {
+		ids = append(ids, ai.ID)
+	}
+	return ids
+}
+
+func TestFile(t *testing.T) {
+	p1, p2, p3 := test.RandPeerIDFatal(t), test.RandPeerIDFatal(t), test.RandPeerIDFatal(t)
+	path := filepath.Join(t.TempDir(), "peers.json")
+	write := func(list string) {
+		t.Helper()
+		// write atomically, so that the list is never read half-written
+		tmp := path + ".tmp"
+		require.NoError(t, os.WriteFile(tmp, []byte(list), 0o644))
+		require.NoError(t, os.Rename(tmp, path))
+	}
+	write(fmt.Sprintf(`{"peers": [
+		{"id": %q, "addrs": ["/ip4/1.2.3.4/tcp/1"]},
+		{"id": %q, "addrs": ["/ip4/1.2.3.4/tcp/2"], "namespaces": ["foo"]},
+		{"id": %q, "addrs": ["/ip4/1.2.3.4/tcp/3"], "expires": "2000-01-01T00:00:00Z"}
+	]}`, p1, p2, p3))
+
+	d, err := New(path, WithPollInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	defer d.Close()
+
+	ch, err := d.FindPeers(context.Background(), "foo")
+	require.NoError(t, err)
+	ai := <-ch
+	require.Equal(t, p1, ai.ID)
+	require.Equal(t, "/ip4/1.2.3.4/tcp/1", ai.Addrs[0].String())
+
+	// the expired peer isn't found
+	require.ElementsMatch(t, []peer.ID{p1, p2}, findPeers(t, d, "foo"))
+	require.Equal(t, []peer.ID{p1}, findPeers(t, d, "bar"))
+	require.Len(t, findPeers(t, d, "foo", discovery.Limit(1)), 1)
+
+	// changes are picked up
+	write(fmt.Sprintf(`{"peers": [{"id": %q, "addrs": []}]}`, p3))
+	require.Eventually(t, func() bool {
+		ids := findPeers(t, d, "foo")
+		return len(ids) == 1 && ids[0] == p3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// invalid lists are ignored
+	write(`{"peers": [{"id": "invalid"}]}`)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, []peer.ID{p3}, findPeers(t, d, "foo"))
+
+	_, err = d.Advertise(context.Background(), "foo")
+	require.ErrorIs(t, err, ErrAdvertiseNotSupported)
+}
+
+func TestURL(t *testing.T) {
+	p1 := test.RandPeerIDFatal(t)
+	list := fmt.Sprintf(`{"ttl": "200ms", "peers": [{"id": %q, "addrs": ["/ip4/1.2.3.4/udp/1/quic-v1"]}]}`, p1)
+
+	var mx sync.Mutex
+	var fail bool
+	var notModified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mx.Lock()
+		defer mx.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(list))
+	}))
+	defer srv.Close()
+
+	d, err := New(srv.URL, WithPollInterval(20*time.Millisecond))
+	require.NoError(t, err)
+	defer d.Close()
+	require.Equal(t, []peer.ID{p1}, findPeers(t, d, "foo"))
+
+	// unchanged lists are revalidated using the ETag, and stay valid
+	time.Sleep(300 * time.Millisecond)
+	require.Equal(t, []peer.ID{p1}, findPeers(t, d, "foo"))
+	mx.Lock()
+	require.NotZero(t, notModified)
+	fail = true
+	mx.Unlock()
+
+	// once the list can't be reloaded for longer than its TTL, no peers are found
+	require.Eventually(t, func() bool { return len(findPeers(t, d, "foo")) == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestNewErrors(t *testing.T) {
+	_, err := New(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "peers.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"peers": [{"id": "invalid"}]}`), 0o644))
+	_, err = New(path)
+	require.Error(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"peers": []}`), 0o644))
+	_, err = New(path, WithPollInterval(0))
+	require.Error(t, err)
+}
@@ -46,6 +46,7 @@ type AmbientAutoNAT struct {
 	confidence    int
 	lastInbound   time.Time
 	lastProbe     time.Time
+	failures      int // consecutive probes that didn't yield a result
 	recentProbes  map[peer.ID]time.Time
 	pendingProbes int
 	ourAddrs      map[string]struct{}
@@ -86,6 +87,9 @@ func New(h host.Host, options ...Option) (AutoNAT, error) {
 			return nil, err
 		}
 	}
+	if conf.scheduler == nil {
+		conf.scheduler = DefaultScheduler(conf.retryInterval, conf.refreshInterval)
+	}
 	emitReachabilityChanged, _ := h.EventBus().Emitter(new(event.EvtLocalReachabilityChanged), eventbus.Stateful)
 
 	var service *autoNATService
@@ -179,10 +183,11 @@ func (as *AmbientAutoNAT) background() {
 	addrChangeTicker := time.NewTicker(30 * time.Minute)
 	defer addrChangeTicker.Stop()
 
-	timer := time.NewTimer(delay)
+	timer := as.config.probeCoordinator.NewTimer(delay)
 	defer timer.Stop()
 	timerRunning := true
 	forceProbe := false
+	addrsChanged := false
 	for {
 		select {
 		case conn := <-as.inboundConn:
@@ -213,6 +218,7 @@ func (as *AmbientAutoNAT) background() {
 			}
 			as.pendingProbes--
 			if IsDialRefused(err) {
+				as.failures++
 				forceProbe = true
 			} else {
 				as.handleDialResponse(err)
@@ -220,6 +226,7 @@ func (as *AmbientAutoNAT) background() {
 		case <-timer.C:
 			timerRunning = false
 			forceProbe = false
+			addrsChanged = false
 			// Update the last probe time. We use it to ensure
 			// that we don't spam the peerstore.
 			as.lastProbe = time.Now()
@@ -234,11 +241,12 @@ func (as *AmbientAutoNAT) background() {
 		if hasNewAddr && as.confidence == maxConfidence {
 			as.confidence--
 		}
+		addrsChanged = addrsChanged || hasNewAddr
 
 		if timerRunning && !timer.Stop() {
 			<-timer.C
 		}
-		timer.Reset(as.scheduleProbe(forceProbe))
+		timer.Reset(as.scheduleProbe(forceProbe, addrsChanged))
 		timerRunning = true
 	}
 }
@@ -260,30 +268,17 @@ func (as *AmbientAutoNAT) checkAddrs() (hasNewAddr bool) {
 }
 
 // scheduleProbe calculates when the next probe should be scheduled for.
-func (as *AmbientAutoNAT) scheduleProbe(forceProbe bool) time.Duration {
+func (as *AmbientAutoNAT) scheduleProbe(forceProbe, addrsChanged bool) time.Duration {
 	now := time.Now()
-	currentStatus := *as.status.Load()
-	nextProbeAfter := as.config.refreshInterval
-	receivedInbound := as.lastInbound.After(as.lastProbe)
-	switch {
-	case forceProbe && currentStatus == network.ReachabilityUnknown:
-		// retry very quicky if forceProbe is true *and* we don't know our reachability
-		// limit all peers fetch from peerstore to 1 per second.
-		nextProbeAfter = 2 * time.Second
-	case currentStatus == network.ReachabilityUnknown,
-		as.confidence < maxConfidence,
-		currentStatus != network.ReachabilityPublic && receivedInbound:
-		// Retry quickly in case:
-		// 1. Our reachability is Unknown
-		// 2. We don't have enough confidence in our reachability.
-		// 3. We're private but we received an inbound connection.
-		nextProbeAfter = as.config.retryInterval
-	case currentStatus == network.ReachabilityPublic && receivedInbound:
-		// We are public and we received an inbound connection recently,
-		// wait a little longer
-		nextProbeAfter *= 2
-		nextProbeAfter = min(nextProbeAfter, maxRefreshInterval)
-	}
+	nextProbeAfter := as.config.scheduler.NextProbe(ProbeState{
+		Reachability: *as.status.Load(),
+		Confidence:   as.confidence,
+		LastProbe:    as.lastProbe,
+		LastInbound:  as.lastInbound,
+		Failures:     as.failures,
+		Forced:       forceProbe,
+		AddrsChanged: addrsChanged,
+	})
 	nextProbeTime := as.lastProbe.Add(nextProbeAfter)
 	if nextProbeTime.Before(now) {
 		nextProbeTime = now
@@ -306,6 +301,11 @@ func (as *AmbientAutoNAT) handleDialResponse(dialErr error) {
 	default:
 		observation = network.ReachabilityUnknown
 	}
+	if observation == network.ReachabilityUnknown {
+		as.failures++
+	} else {
+		as.failures = 0
+	}
 
 	as.recordObservation(observation)
 }
@@ -426,6 +426,9 @@ func (as *AmbientAutoNAT) getPeerToProbe() peer.ID {
 		if as.config.dialPolicy.skipPeer(info.Addrs) {
 			continue
 		}
+		if !as.config.scheduler.AllowPeer(p, info.Addrs) {
+			continue
+		}
 		return p
 	}
 
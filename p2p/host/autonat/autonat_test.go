@@ -2,6 +2,8 @@ package autonat
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -14,6 +16,7 @@ import (
 	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
 
 	"github.com/libp2p/go-msgio/pbio"
+	ma "github.com/multiformats/go-multiaddr"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -259,6 +262,60 @@ func TestAutoNATDialRefused(t *testing.T) {
 	close(done)
 }
 
+type mockScheduler struct {
+	allow    atomic.Bool
+	mx       sync.Mutex
+	failures int
+}
+
+func (s *mockScheduler) NextProbe(st ProbeState) time.Duration {
+	s.mx.Lock()
+	s.failures = max(s.failures, st.Failures)
+	s.mx.Unlock()
+	return 100 * time.Millisecond
+}
+
+func (s *mockScheduler) AllowPeer(peer.ID, []ma.Multiaddr) bool { return s.allow.Load() }
+
+func TestAutoNATScheduler(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	hs := makeAutoNATRefuseDialRequest(t, done)
+	defer hs.Close()
+
+	sched := &mockScheduler{}
+	hc := bhost.NewBlankHost(swarmt.GenSwarm(t))
+	defer hc.Close()
+	identifyAsServer(hs, hc)
+	an, err := New(hc, WithScheduler(sched), WithoutStartupDelay())
+	require.NoError(t, err)
+	defer an.Close()
+	an.(*AmbientAutoNAT).config.dialPolicy.allowSelfDials = true
+	an.(*AmbientAutoNAT).config.throttlePeerPeriod = 100 * time.Millisecond
+
+	s, err := hc.EventBus().Subscribe(&event.EvtLocalReachabilityChanged{})
+	require.NoError(t, err)
+	connect(t, hs, hc)
+
+	// the server is filtered out, so it is never probed
+	require.Never(t, func() bool {
+		sched.mx.Lock()
+		defer sched.mx.Unlock()
+		return sched.failures > 0
+	}, time.Second, 100*time.Millisecond)
+
+	// the server refuses to dial us back, which the scheduler sees as failures
+	sched.allow.Store(true)
+	require.Eventually(t, func() bool {
+		sched.mx.Lock()
+		defer sched.mx.Unlock()
+		return sched.failures > 1
+	}, 5*time.Second, 100*time.Millisecond)
+
+	hs.SetStreamHandler(AutoNATProto, sayPrivateStreamHandler(t))
+	expectEvent(t, s, network.ReachabilityPrivate, 3*time.Second)
+}
+
 func recordObservation(an *AmbientAutoNAT, status network.Reachability) {
 	an.observations <- status
 }
@@ -6,6 +6,7 @@ import (
 
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/p2p/host/probecoord"
 )
 
 // config holds configurable options for the autonat subsystem.
@@ -18,6 +19,8 @@ type config struct {
 	forceReachability bool
 	reachability      network.Reachability
 	metricsTracer     MetricsTracer
+	scheduler         Scheduler
+	probeCoordinator  *probecoord.Coordinator
 
 	// client
 	bootDelay          time.Duration
@@ -106,6 +109,27 @@ func WithSchedule(retryInterval, refreshInterval time.Duration) Option {
 	}
 }
 
+// WithScheduler lets s decide when probes are made and which peers are used
+// for them. It takes precedence over WithSchedule.
+func WithScheduler(s Scheduler) Option {
+	return func(c *config) error {
+		if s == nil {
+			return errors.New("invalid scheduler supplied")
+		}
+		c.scheduler = s
+		return nil
+	}
+}
+
+// WithProbeCoordinator schedules probes with the timers of c, so that they are
+// jittered and spaced out from the other background probes of the host.
+func WithProbeCoordinator(c *probecoord.Coordinator) Option {
+	return func(cfg *config) error {
+		cfg.probeCoordinator = c
+		return nil
+	}
+}
+
 // WithoutStartupDelay removes the initial delay the NAT subsystem typically
 // uses as a buffer for ensuring that connectivity and guesses as to the hosts
 // local interfaces have settled down during startup.
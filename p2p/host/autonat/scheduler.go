@@ -0,0 +1,91 @@
+package autonat
+
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// ProbeState is the state of the AutoNAT client passed to a Scheduler when
+// scheduling the next probe.
+type ProbeState struct {
+	// Reachability is the current reachability of the host.
+	Reachability network.Reachability
+	// Confidence in Reachability, between 0 and 3.
+	Confidence int
+	// LastProbe is the time of the last probe.
+	LastProbe time.Time
+	// LastInbound is the time of the last inbound connection from a public
+	// address.
+	LastInbound time.Time
+	// Failures is the number of consecutive probes that didn't yield a
+	// result, either because the server refused to dial us back or because
+	// of an error.
+	Failures int
+	// Forced is true when a probe was requested since the last probe, e.g.
+	// because a new AutoNAT server was discovered or a server refused to dial
+	// us back.
+	Forced bool
+	// AddrsChanged is true when the host's public addresses changed since the
+	// last probe.
+	AddrsChanged bool
+}
+
+// Scheduler controls the cadence of AutoNAT probes and the peers used for
+// them. Methods are called from the AutoNAT background goroutine and must not
+// block.
+type Scheduler interface {
+	// NextProbe returns the time to wait after s.LastProbe before probing
+	// again. It is called after every event that may affect the schedule.
+	NextProbe(s ProbeState) time.Duration
+	// AllowPeer reports whether p, which supports the AutoNAT protocol and
+	// is reachable on addrs, may be asked to dial us back.
+	AllowPeer(p peer.ID, addrs []ma.Multiaddr) bool
+}
+
+// DefaultScheduler returns the Scheduler used when none is configured. It
+// probes every retryInterval until it is confident about the reachability of
+// the host, then every refreshInterval.
+func DefaultScheduler(retryInterval, refreshInterval time.Duration) Scheduler {
+	return &defaultScheduler{
+		retryInterval:   retryInterval,
+		refreshInterval: refreshInterval,
+	}
+}
+
+type defaultScheduler struct {
+	retryInterval   time.Duration
+	refreshInterval time.Duration
+}
+
+var _ Scheduler = (*defaultScheduler)(nil)
+
+func (s *defaultScheduler) NextProbe(st ProbeState) time.Duration {
+	nextProbeAfter := s.refreshInterval
+	receivedInbound := st.LastInbound.After(st.LastProbe)
+	switch {
+	case st.Forced && st.Reachability == network.ReachabilityUnknown:
+		// retry very quicky if forceProbe is true *and* we don't know our reachability
+		// limit all peers fetch from peerstore to 1 per second.
+		nextProbeAfter = 2 * time.Second
+	case st.Reachability == network.ReachabilityUnknown,
+		st.Confidence < maxConfidence,
+		st.Reachability != network.ReachabilityPublic && receivedInbound:
+		// Retry quickly in case:
+		// 1. Our reachability is Unknown
+		// 2. We don't have enough confidence in our reachability.
+		// 3. We're private but we received an inbound connection.
+		nextProbeAfter = s.retryInterval
+	case st.Reachability == network.ReachabilityPublic && receivedInbound:
+		// We are public and we received an inbound connection recently,
+		// wait a little longer
+		nextProbeAfter *= 2
+		nextProbeAfter = min(nextProbeAfter, maxRefreshInterval)
+	}
+	return nextProbeAfter
+}
+
+func (s *defaultScheduler) AllowPeer(peer.ID, []ma.Multiaddr) bool { return true }
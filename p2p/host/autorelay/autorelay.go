@@ -9,6 +9,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
 
 	logging "github.com/ipfs/go-log/v2"
@@ -49,6 +50,11 @@ func NewAutoRelay(host host.Host, opts ...Option) (*AutoRelay, error) {
 	}
 	r.relayFinder = rf
 	r.metricsTracer = &wrappedMetricsTracer{conf.metricsTracer}
+	if conf.controller != nil {
+		conf.controller.mx.Lock()
+		conf.controller.ar = r
+		conf.controller.mx.Unlock()
+	}
 
 	return r, nil
 }
@@ -99,6 +105,19 @@ func (r *AutoRelay) background() {
 	}
 }
 
+// Relays returns the relays we currently have a reservation with.
+func (r *AutoRelay) Relays() []peer.ID {
+	return r.relayFinder.relayIDs()
+}
+
+// RotateAway drops the reservation with the relay p, closing our connections
+// to p so that the relay frees the slot, and backs off from p (see
+// WithBackoff), so that a reservation with another relay is obtained instead.
+// It returns false, and does nothing, if p wasn't one of our relays.
+func (r *AutoRelay) RotateAway(p peer.ID) bool {
+	return r.relayFinder.rotateAway(p)
+}
+
 func (r *AutoRelay) Close() error {
 	r.ctxCancel()
 	err := r.relayFinder.Stop()
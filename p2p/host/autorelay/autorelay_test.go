@@ -17,8 +17,10 @@ import (
 	"github.com/libp2p/go-libp2p/core/test"
 	"github.com/libp2p/go-libp2p/p2p/host/autorelay"
 	circuitv2_proto "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/proto"
+	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
 
 	ma "github.com/multiformats/go-multiaddr"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -88,9 +90,9 @@ func newPrivateNodeWithStaticRelays(t *testing.T, static []peer.AddrInfo, opts .
 	return h
 }
 
-func newRelay(t *testing.T) host.Host {
+func newRelay(t *testing.T, opts ...libp2p.Option) host.Host {
 	t.Helper()
-	h, err := libp2p.New(
+	h, err := libp2p.New(append([]libp2p.Option{
 		libp2p.DisableRelay(),
 		libp2p.EnableRelayService(),
 		libp2p.ForceReachabilityPublic(),
@@ -107,7 +109,7 @@ func newRelay(t *testing.T) host.Host {
 			}
 			return addrs
 		}),
-	)
+	}, opts...)...)
 	require.NoError(t, err)
 	require.Eventually(t, func() bool {
 		for _, p := range h.Mux().Protocols() {
@@ -176,6 +178,73 @@ func TestSingleRelay(t *testing.T) {
 	require.Never(t, func() bool { return numRelays(h) > 1 }, 200*time.Millisecond, 50*time.Millisecond)
 }
 
+func TestMaxCandidateRTT(t *testing.T) {
+	peerChan := make(chan peer.AddrInfo, 1)
+	r := newRelay(t)
+	t.Cleanup(func() { r.Close() })
+	peerChan <- peer.AddrInfo{ID: r.ID(), Addrs: r.Addrs()}
+	close(peerChan)
+
+	h := newPrivateNode(t,
+		func(context.Context, int) <-chan peer.AddrInfo { return peerChan },
+		autorelay.WithMaxCandidates(1),
+		autorelay.WithNumRelays(1),
+		autorelay.WithBootDelay(0),
+		autorelay.WithMinInterval(time.Hour),
+		autorelay.WithMaxCandidateRTT(time.Nanosecond),
+	)
+	defer h.Close()
+
+	// The relay is reachable, but it is rejected as a candidate since no ping
+	// completes within a nanosecond.
+	require.Never(t, func() bool { return numRelays(h) > 0 }, 500*time.Millisecond, 50*time.Millisecond)
+}
+
+func TestCandidateNotRespondingToPing(t *testing.T) {
+	peerChan := make(chan peer.AddrInfo, 1)
+	r := newRelay(t, libp2p.Ping(false))
+	t.Cleanup(func() { r.Close() })
+	peerChan <- peer.AddrInfo{ID: r.ID(), Addrs: r.Addrs()}
+	close(peerChan)
+
+	h := newPrivateNode(t,
+		func(context.Context, int) <-chan peer.AddrInfo { return peerChan },
+		autorelay.WithMaxCandidates(1),
+		autorelay.WithNumRelays(1),
+		autorelay.WithBootDelay(0),
+		autorelay.WithMinInterval(time.Hour),
+	)
+	defer h.Close()
+
+	// The relay speaks the hop protocol, but it can't be probed.
+	require.Never(t, func() bool { return numRelays(h) > 0 }, 500*time.Millisecond, 50*time.Millisecond)
+}
+
+func TestCandidateWithoutStatusQueries(t *testing.T) {
+	peerChan := make(chan peer.AddrInfo, 1)
+	r := newRelay(t)
+	t.Cleanup(func() { r.Close() })
+	// make the relay look like one that doesn't support status queries
+	require.Eventually(t, func() bool {
+		return slices.Contains(r.Mux().Protocols(), circuitv2_proto.ProtoIDRelayStatus)
+	}, time.Second, 10*time.Millisecond)
+	r.RemoveStreamHandler(circuitv2_proto.ProtoIDRelayStatus)
+	peerChan <- peer.AddrInfo{ID: r.ID(), Addrs: r.Addrs()}
+	close(peerChan)
+
+	h := newPrivateNode(t,
+		func(context.Context, int) <-chan peer.AddrInfo { return peerChan },
+		autorelay.WithMaxCandidates(1),
+		autorelay.WithNumRelays(1),
+		autorelay.WithBootDelay(0),
+		autorelay.WithMinInterval(time.Hour),
+	)
+	defer h.Close()
+
+	// its capacity is unknown, but it is still used
+	require.Eventually(t, func() bool { return numRelays(h) > 0 }, 5*time.Second, 50*time.Millisecond)
+}
+
 func TestWaitForCandidates(t *testing.T) {
 	peerChan := make(chan peer.AddrInfo)
 	h := newPrivateNode(t,
@@ -290,6 +359,49 @@ func TestStaticRelays(t *testing.T) {
 	require.Eventually(t, func() bool { return numRelays(h) > 0 }, 10*time.Second, 50*time.Millisecond)
 }
 
+type refreshCountingTracer struct {
+	autorelay.MetricsTracer
+	refreshes atomic.Int32
+}
+
+func (mt *refreshCountingTracer) ReservationRequestFinished(isRefresh bool, err error) {
+	if isRefresh && err == nil {
+		mt.refreshes.Add(1)
+	}
+	mt.MetricsTracer.ReservationRequestFinished(isRefresh, err)
+}
+
+func TestRefreshOnConnectivityChange(t *testing.T) {
+	r := newRelay(t)
+	t.Cleanup(func() { r.Close() })
+
+	mt := &refreshCountingTracer{MetricsTracer: autorelay.NewMetricsTracer(autorelay.WithRegisterer(prometheus.NewRegistry()))}
+	h := newPrivateNodeWithStaticRelays(t,
+		[]peer.AddrInfo{{ID: r.ID(), Addrs: r.Addrs()}},
+		autorelay.WithMetricsTracer(mt),
+		autorelay.WithReservationRefreshMargin(time.Minute),
+		autorelay.WithReservationRefreshJitter(time.Minute),
+	)
+	defer h.Close()
+	require.Eventually(t, func() bool { return numRelays(h) > 0 }, 10*time.Second, 50*time.Millisecond)
+
+	em, err := h.EventBus().Emitter(new(event.EvtLocalAddressesUpdated))
+	require.NoError(t, err)
+	defer em.Close()
+	var current []event.UpdatedAddress
+	for _, a := range h.Addrs() {
+		current = append(current, event.UpdatedAddress{Address: a})
+	}
+	// Addresses that didn't change don't trigger a refresh.
+	require.NoError(t, em.Emit(event.EvtLocalAddressesUpdated{Current: current}))
+	require.Never(t, func() bool { return mt.refreshes.Load() > 0 }, 300*time.Millisecond, 50*time.Millisecond)
+
+	current = append(current, event.UpdatedAddress{Address: ma.StringCast("/ip4/1.2.3.4/tcp/1")})
+	require.NoError(t, em.Emit(event.EvtLocalAddressesUpdated{Current: current}))
+	require.Eventually(t, func() bool { return mt.refreshes.Load() > 0 }, 10*time.Second, 50*time.Millisecond)
+	require.Equal(t, 1, numRelays(h))
+}
+
 func TestConnectOnDisconnect(t *testing.T) {
 	const num = 3
 	peerChan := make(chan peer.AddrInfo, num)
@@ -601,3 +713,72 @@ func TestAutoRelayAddrsEvent(t *testing.T) {
 	case <-time.After(1 * time.Second):
 	}
 }
+
+func TestRelaySelectorAndRotation(t *testing.T) {
+	tcpOnly := []libp2p.Option{
+		libp2p.NoTransports,
+		libp2p.Transport(tcp.NewTCPTransport),
+		libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+	}
+	const num = 3
+	relays := make([]host.Host, 0, num)
+	peerChan := make(chan peer.AddrInfo, num)
+	for i := 0; i < num; i++ {
+		r := newRelay(t, tcpOnly...)
+		t.Cleanup(func() { r.Close() })
+		relays = append(relays, r)
+		// the advertised addresses of the relay can't be resolved
+		peerChan <- peer.AddrInfo{ID: r.ID(), Addrs: r.Network().ListenAddresses()}
+	}
+	close(peerChan)
+
+	// prefer the last relay, and never use the first one
+	scores := map[peer.ID]float64{relays[0].ID(): -1, relays[1].ID(): 1, relays[2].ID(): 2}
+	cl := newMockClock()
+	ctrl := autorelay.NewController()
+	_, err := ctrl.Relays()
+	require.Error(t, err)
+
+	h, err := libp2p.New(append(tcpOnly,
+		libp2p.ForceReachabilityPrivate(),
+		libp2p.EnableAutoRelayWithPeerSource(
+			func(context.Context, int) <-chan peer.AddrInfo { return peerChan },
+			autorelay.WithMinCandidates(num),
+			autorelay.WithMaxCandidates(num),
+			autorelay.WithNumRelays(1),
+			autorelay.WithBootDelay(time.Minute),
+			autorelay.WithMinInterval(time.Hour),
+			autorelay.WithClock(cl),
+			autorelay.WithRelaySelector(autorelay.RelaySelectorFunc(func(c autorelay.RelayCandidate) float64 {
+				return scores[c.ID]
+			})),
+			autorelay.WithController(ctrl),
+		),
+	)...)
+	require.NoError(t, err)
+	defer h.Close()
+
+	require.Eventually(t, func() bool { return numRelays(h) > 0 }, 10*time.Second, 100*time.Millisecond)
+	require.Equal(t, []peer.ID{relays[2].ID()}, usedRelays(h))
+	rs, err := ctrl.Relays()
+	require.NoError(t, err)
+	require.Equal(t, []peer.ID{relays[2].ID()}, rs)
+
+	// after the boot delay, we don't wait for more candidates before rotating
+	cl.AdvanceBy(time.Minute)
+	ok, err := ctrl.RotateAway(relays[2].ID())
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Eventually(t, func() bool {
+		used := usedRelays(h)
+		return len(used) == 1 && used[0] == relays[1].ID()
+	}, 10*time.Second, 100*time.Millisecond)
+	// the old relay frees our slot when we disconnect
+	require.Eventually(t, func() bool {
+		return relays[2].Network().Connectedness(h.ID()) != network.Connected
+	}, 5*time.Second, 10*time.Millisecond)
+
+	ok, err = ctrl.RotateAway(relays[2].ID())
+	require.NoError(t, err)
+	require.False(t, ok)
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/host/probecoord"
 )
 
 // AutoRelay will call this function when it needs new candidates because it is
@@ -42,6 +43,18 @@ type config struct {
 	setMinCandidates bool
 	// see WithMetricsTracer
 	metricsTracer MetricsTracer
+	// see WithReservationRefreshMargin
+	rsvpRefreshMargin time.Duration
+	// see WithReservationRefreshJitter
+	rsvpRefreshJitter time.Duration
+	// see WithMaxCandidateRTT
+	maxCandidateRTT time.Duration
+	// see WithRelaySelector
+	selector RelaySelector
+	// see WithController
+	controller *Controller
+	// see WithProbeCoordinator
+	probeCoordinator *probecoord.Coordinator
 }
 
 var defaultConfig = config{
@@ -53,6 +66,9 @@ var defaultConfig = config{
 	desiredRelays:   2,
 	maxCandidateAge: 30 * time.Minute,
 	minInterval:     30 * time.Second,
+
+	rsvpRefreshMargin: 2 * time.Minute,
+	rsvpRefreshJitter: 2 * time.Minute,
 }
 
 var (
@@ -162,6 +178,45 @@ func WithMaxCandidateAge(d time.Duration) Option {
 	}
 }
 
+// WithReservationRefreshMargin sets how long before a reservation expires we
+// refresh it.
+func WithReservationRefreshMargin(d time.Duration) Option {
+	return func(c *config) error {
+		if d < 0 {
+			return errors.New("reservation refresh margin must not be negative")
+		}
+		c.rsvpRefreshMargin = d
+		return nil
+	}
+}
+
+// WithReservationRefreshJitter sets the maximum random amount of time by which
+// a reservation is refreshed earlier than the refresh margin requires. This
+// prevents nodes that obtained their reservations at the same time, e.g. after
+// a relay restarted, from all refreshing them at the same time.
+func WithReservationRefreshJitter(d time.Duration) Option {
+	return func(c *config) error {
+		if d < 0 {
+			return errors.New("reservation refresh jitter must not be negative")
+		}
+		c.rsvpRefreshJitter = d
+		return nil
+	}
+}
+
+// WithMaxCandidateRTT rejects candidates whose round trip time, as measured by
+// pinging them when they're found, exceeds d. Candidates that don't respond to
+// pings are always rejected. By default, there is no limit.
+func WithMaxCandidateRTT(d time.Duration) Option {
+	return func(c *config) error {
+		if d < 0 {
+			return errors.New("max candidate RTT must not be negative")
+		}
+		c.maxCandidateRTT = d
+		return nil
+	}
+}
+
 // InstantTimer is a timer that triggers at some instant rather than some duration
 type InstantTimer interface {
 	Reset(d time.Time) bool
@@ -215,6 +270,33 @@ func WithClock(cl ClockWithInstantTimer) Option {
 	}
 }
 
+// WithProbeCoordinator schedules the periodic relay work, like refreshing
+// reservations and checking candidates, with the timers of c, so that it is
+// jittered and spaced out from the other background probes of the host. c
+// uses its own clock, not the one set with WithClock.
+func WithProbeCoordinator(c *probecoord.Coordinator) Option {
+	return func(cfg *config) error {
+		cfg.probeCoordinator = c
+		return nil
+	}
+}
+
+type coordinatedTimer struct{ t *probecoord.Timer }
+
+var _ InstantTimer = coordinatedTimer{}
+
+func (t coordinatedTimer) Ch() <-chan time.Time {
+	return t.t.C
+}
+
+func (t coordinatedTimer) Reset(d time.Time) bool {
+	return t.t.Reset(time.Until(d))
+}
+
+func (t coordinatedTimer) Stop() bool {
+	return t.t.Stop()
+}
+
 // WithMinInterval sets the minimum interval after which peerSource callback will be called for more
 // candidates even if AutoRelay needs new candidates.
 func WithMinInterval(interval time.Duration) Option {
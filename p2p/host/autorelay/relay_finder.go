@@ -2,6 +2,7 @@ package autorelay
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
@@ -20,6 +21,7 @@ import (
 	circuitv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/client"
 	circuitv2_proto "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/proto"
 	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
 
 	ma "github.com/multiformats/go-multiaddr"
 )
@@ -31,12 +33,16 @@ const protoIDv2 = circuitv2_proto.ProtoIDv2Hop
 // we call it a candidate, and consider using it as a relay.
 //
 // Relay: Out of the list candidates, the ones we have a reservation with.
-// Currently, we just randomly select a candidate, but we can employ more sophisticated
-// selection strategies here (e.g. by facotring in the RTT).
+// Candidates are pinged and asked whether they have room for a reservation
+// when they're found. Candidates that don't respond to the ping in time or
+// report that they're full are dropped, and of the rest, the ones with the
+// lowest RTT are tried first.
 
 const (
 	rsvpRefreshInterval = time.Minute
-	rsvpExpirationSlack = 2 * time.Minute
+	// candidateProbeTimeout is how long we wait for a candidate to respond to
+	// the ping measuring its RTT and to the status query.
+	candidateProbeTimeout = 5 * time.Second
 
 	autorelayTag  = "autorelay"
 	maxRelayAddrs = 100
@@ -46,6 +52,8 @@ type candidate struct {
 	added           time.Time
 	supportsRelayV2 bool
 	ai              peer.AddrInfo
+	// rtt is the round trip time measured when the candidate was found.
+	rtt time.Duration
 }
 
 // relayFinder is a Host that uses relays for connectivity when a NAT is detected.
@@ -78,6 +86,12 @@ type relayFinder struct {
 
 	relayMx sync.Mutex
 	relays  map[peer.ID]*circuitv2.Reservation
+	// refreshAt is the time at which we refresh the reservation with a relay.
+	refreshAt map[peer.ID]time.Time
+
+	// connectivityChanged receives when our own addresses change, so that
+	// relays are told about them without waiting for the next refresh.
+	connectivityChanged chan struct{} // cap: 1
 
 	circuitAddrs []ma.Multiaddr
 
@@ -112,6 +126,8 @@ func newRelayFinder(host host.Host, conf *config) (*relayFinder, error) {
 		maybeRequestNewCandidates:  make(chan struct{}, 1),
 		triggerRunScheduledWork:    make(chan struct{}, 1),
 		relays:                     make(map[peer.ID]*circuitv2.Reservation),
+		refreshAt:                  make(map[peer.ID]time.Time),
+		connectivityChanged:        make(chan struct{}, 1),
 		relayReservationUpdated:    make(chan struct{}, 1),
 		metricsTracer:              &wrappedMetricsTracer{conf.metricsTracer},
 		emitter:                    emitter,
@@ -151,6 +167,7 @@ func (rf *relayFinder) cleanupDisconnectedPeers(ctx context.Context) {
 			if rf.usingRelay(evt.Peer) { // we were disconnected from a relay
 				log.Debugw("disconnected from relay", "id", evt.Peer)
 				delete(rf.relays, evt.Peer)
+				delete(rf.refreshAt, evt.Peer)
 				rf.notifyMaybeConnectToRelay()
 				rf.notifyMaybeNeedNewCandidates()
 				push = true
@@ -165,6 +182,15 @@ func (rf *relayFinder) cleanupDisconnectedPeers(ctx context.Context) {
 	}
 }
 
+// newWorkTimer creates the timer for the scheduled work, from the probe
+// coordinator if one is configured.
+func (rf *relayFinder) newWorkTimer(when time.Time) InstantTimer {
+	if rf.conf.probeCoordinator != nil {
+		return coordinatedTimer{rf.conf.probeCoordinator.NewTimer(time.Until(when))}
+	}
+	return rf.conf.clock.InstantTimer(when)
+}
+
 func (rf *relayFinder) background(ctx context.Context) {
 	peerSourceRateLimiter := make(chan struct{}, 1)
 	rf.refCount.Add(1)
@@ -204,10 +230,11 @@ func (rf *relayFinder) background(ctx context.Context) {
 		nextAllowedCallToPeerSource: now.Add(-time.Second), // allow immediately
 	}
 
-	workTimer := rf.conf.clock.InstantTimer(rf.runScheduledWork(ctx, now, scheduledWork, peerSourceRateLimiter))
+	workTimer := rf.newWorkTimer(rf.runScheduledWork(ctx, now, scheduledWork, peerSourceRateLimiter))
 	defer workTimer.Stop()
 
 	go rf.cleanupDisconnectedPeers(ctx)
+	go rf.watchConnectivityChanges(ctx)
 
 	// update addrs on starting the relay finder.
 	rf.updateAddrs()
@@ -225,6 +252,10 @@ func (rf *relayFinder) background(ctx context.Context) {
 			// future work at a specific time.
 			nextTime := rf.runScheduledWork(ctx, now, scheduledWork, peerSourceRateLimiter)
 			workTimer.Reset(nextTime)
+		case <-rf.connectivityChanged:
+			if rf.refreshReservations(ctx, rf.conf.clock.Now(), true) {
+				rf.notifyRelayReservationUpdated()
+			}
 		case <-rf.triggerRunScheduledWork:
 			// Ignore the next time because we aren't scheduling any future work here
 			_ = rf.runScheduledWork(ctx, rf.conf.clock.Now(), scheduledWork, peerSourceRateLimiter)
@@ -277,7 +308,7 @@ func (rf *relayFinder) runScheduledWork(ctx context.Context, now time.Time, sche
 
 	if now.After(scheduledWork.nextRefresh) {
 		scheduledWork.nextRefresh = now.Add(rsvpRefreshInterval)
-		if rf.refreshReservations(ctx, now) {
+		if rf.refreshReservations(ctx, now, false) {
 			rf.notifyRelayReservationUpdated()
 		}
 	}
@@ -498,6 +529,17 @@ func (rf *relayFinder) handleNewNode(ctx context.Context, pi peer.AddrInfo) (add
 		}
 		return false
 	}
+	rtt, err := rf.probeRTT(ctx, pi.ID)
+	if err != nil {
+		log.Debugw("node not accepted as a candidate; probe failed", "peer", pi.ID, "error", err)
+		rf.metricsTracer.CandidateChecked(false)
+		return false
+	}
+	if rf.conf.maxCandidateRTT > 0 && rtt > rf.conf.maxCandidateRTT {
+		log.Debugw("node not accepted as a candidate; RTT too high", "peer", pi.ID, "rtt", rtt)
+		rf.metricsTracer.CandidateChecked(false)
+		return false
+	}
 	rf.metricsTracer.CandidateChecked(true)
 
 	rf.candidateMx.Lock()
@@ -505,11 +547,12 @@ func (rf *relayFinder) handleNewNode(ctx context.Context, pi peer.AddrInfo) (add
 		rf.candidateMx.Unlock()
 		return false
 	}
-	log.Debugw("node supports relay protocol", "peer", pi.ID, "supports circuit v2", supportsV2)
+	log.Debugw("node supports relay protocol", "peer", pi.ID, "supports circuit v2", supportsV2, "rtt", rtt)
 	rf.addCandidate(&candidate{
 		added:           rf.conf.clock.Now(),
 		ai:              pi,
 		supportsRelayV2: supportsV2,
+		rtt:             rtt,
 	})
 	rf.candidateMx.Unlock()
 	return true
@@ -567,6 +610,32 @@ func (rf *relayFinder) tryNode(ctx context.Context, pi peer.AddrInfo) (supportsR
 	return true, nil
 }
 
+// probeRTT measures the round trip time to a candidate with a single ping,
+// and asks the candidate whether it has room for a reservation.
+// It returns an error if the candidate doesn't respond to the ping in time, or
+// reports that it's full.
+func (rf *relayFinder) probeRTT(ctx context.Context, p peer.ID) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, candidateProbeTimeout)
+	defer cancel()
+	ctx = network.WithNoDial(ctx, "autorelay probe")
+	res := <-ping.Ping(ctx, rf.host, p)
+	if res.Error != nil {
+		return 0, fmt.Errorf("ping failed: %w", res.Error)
+	}
+	if err := circuitv2.QueryStatus(ctx, rf.host, p); err != nil {
+		// Only a relay reporting that it's full is rejected. If the query
+		// failed, e.g. because the relay doesn't support status queries, its
+		// capacity is unknown, and we learn whether it has room when we
+		// reserve.
+		var rerr circuitv2.ReservationError
+		if errors.As(err, &rerr) {
+			return 0, fmt.Errorf("relay has no room: %w", err)
+		}
+		log.Debugw("relay candidate capacity unknown", "peer", p, "error", err)
+	}
+	return res.RTT, nil
+}
+
 // When a new node that could be a relay is found, we receive a notification on the maybeConnectToRelayTrigger chan.
 // This function makes sure that we only run one instance of maybeConnectToRelay at once, and buffers
 // exactly one more trigger event to run maybeConnectToRelay.
@@ -629,6 +698,7 @@ func (rf *relayFinder) maybeConnectToRelay(ctx context.Context) {
 		log.Debugw("adding new relay", "id", id)
 		rf.relayMx.Lock()
 		rf.relays[id] = rsvp
+		rf.refreshAt[id] = rf.refreshTime(rsvp)
 		numRelays := len(rf.relays)
 		rf.relayMx.Unlock()
 		rf.notifyMaybeNeedNewCandidates()
@@ -679,13 +749,15 @@ func (rf *relayFinder) connectToRelay(ctx context.Context, cand *candidate) (*ci
 	return rsvp, err
 }
 
-func (rf *relayFinder) refreshReservations(ctx context.Context, now time.Time) bool {
+// refreshReservations refreshes the reservations that are about to expire, or
+// all reservations if all is set. It returns true if any refresh failed.
+func (rf *relayFinder) refreshReservations(ctx context.Context, now time.Time, all bool) bool {
 	rf.relayMx.Lock()
 
 	// find reservations about to expire and refresh them in parallel
 	g := new(errgroup.Group)
-	for p, rsvp := range rf.relays {
-		if now.Add(rsvpExpirationSlack).Before(rsvp.Expiration) {
+	for p := range rf.relays {
+		if !all && now.Before(rf.refreshAt[p]) {
 			continue
 		}
 
@@ -710,6 +782,7 @@ func (rf *relayFinder) refreshRelayReservation(ctx context.Context, p peer.ID) e
 		log.Debugw("failed to refresh relay slot reservation", "relay", p, "error", err)
 		_, exists := rf.relays[p]
 		delete(rf.relays, p)
+		delete(rf.refreshAt, p)
 		// unprotect the connection
 		rf.host.ConnManager().Unprotect(p, autorelayTag)
 		rf.relayMx.Unlock()
@@ -721,10 +794,109 @@ func (rf *relayFinder) refreshRelayReservation(ctx context.Context, p peer.ID) e
 
 	log.Debugw("refreshed relay slot reservation", "relay", p)
 	rf.relays[p] = rsvp
+	rf.refreshAt[p] = rf.refreshTime(rsvp)
 	rf.relayMx.Unlock()
 	return nil
 }
 
+// refreshTime returns the time at which rsvp should be refreshed: the refresh
+// margin before it expires, minus a random jitter.
+func (rf *relayFinder) refreshTime(rsvp *circuitv2.Reservation) time.Time {
+	t := rsvp.Expiration.Add(-rf.conf.rsvpRefreshMargin)
+	if rf.conf.rsvpRefreshJitter > 0 {
+		t = t.Add(-time.Duration(rand.Int63n(int64(rf.conf.rsvpRefreshJitter))))
+	}
+	return t
+}
+
+// watchConnectivityChanges notifies connectivityChanged when our non-relay
+// addresses change, e.g. because we switched networks.
+func (rf *relayFinder) watchConnectivityChanges(ctx context.Context) {
+	sub, err := rf.host.EventBus().Subscribe(new(event.EvtLocalAddressesUpdated), eventbus.Name("autorelay (connectivity)"))
+	if err != nil {
+		log.Error("failed to subscribe to the EvtLocalAddressesUpdated")
+		return
+	}
+	defer sub.Close()
+
+	var addrs []ma.Multiaddr
+	first := true
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-sub.Out():
+			if !ok {
+				return
+			}
+			evt := ev.(event.EvtLocalAddressesUpdated)
+			newAddrs := make([]ma.Multiaddr, 0, len(evt.Current))
+			for _, a := range evt.Current {
+				// Our relay addresses change whenever we refresh reservations.
+				if !isRelayAddr(a.Address) {
+					newAddrs = append(newAddrs, a.Address)
+				}
+			}
+			slices.SortFunc(newAddrs, func(a, b ma.Multiaddr) int { return bytes.Compare(a.Bytes(), b.Bytes()) })
+			changed := !first && areSortedAddrsDifferent(addrs, newAddrs)
+			addrs, first = newAddrs, false
+			if !changed {
+				continue
+			}
+			log.Debugw("local addresses changed, refreshing relay reservations")
+			select {
+			case rf.connectivityChanged <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// relayIDs returns the relays we have a reservation with.
+func (rf *relayFinder) relayIDs() []peer.ID {
+	rf.relayMx.Lock()
+	defer rf.relayMx.Unlock()
+	ids := make([]peer.ID, 0, len(rf.relays))
+	for p := range rf.relays {
+		ids = append(ids, p)
+	}
+	return ids
+}
+
+// rotateAway drops the reservation with p, if any, and puts p on backoff, so
+// that another relay is used instead. It returns false if p wasn't a relay.
+func (rf *relayFinder) rotateAway(p peer.ID) bool {
+	rf.relayMx.Lock()
+	ok := rf.usingRelay(p)
+	if ok {
+		delete(rf.relays, p)
+		delete(rf.refreshAt, p)
+		rf.host.ConnManager().Unprotect(p, autorelayTag)
+	}
+	rf.relayMx.Unlock()
+	if !ok {
+		return false
+	}
+
+	rf.candidateMx.Lock()
+	rf.removeCandidate(p)
+	rf.backoff[p] = rf.conf.clock.Now()
+	rf.candidateMx.Unlock()
+
+	log.Debugw("rotating away from relay", "id", p)
+	// Reservations can't be cancelled, but the relay drops them when we
+	// disconnect. Otherwise, it would keep a slot for us until the
+	// reservation expires.
+	if err := rf.host.Network().ClosePeer(p); err != nil {
+		log.Debugw("failed to close connections to relay", "id", p, "error", err)
+	}
+	rf.metricsTracer.ReservationEnded(1)
+	rf.notifyRelayReservationUpdated()
+	rf.notifyMaybeNeedNewCandidates()
+	rf.notifyMaybeConnectToRelay()
+	return true
+}
+
 // usingRelay returns if we're currently using the given relay.
 func (rf *relayFinder) usingRelay(p peer.ID) bool {
 	_, ok := rf.relays[p]
@@ -759,11 +931,42 @@ func (rf *relayFinder) selectCandidates() []*candidate {
 		}
 	}
 
-	// TODO: better relay selection strategy; this just selects random relays,
-	// but we should probably use ping latency as the selection metric
 	rand.Shuffle(len(candidates), func(i, j int) {
 		candidates[i], candidates[j] = candidates[j], candidates[i]
 	})
+	if rf.conf.selector != nil {
+		return rf.scoreCandidates(candidates)
+	}
+
+	// Prefer candidates with a low RTT. Candidates with the same RTT are
+	// selected randomly.
+	slices.SortStableFunc(candidates, func(a, b *candidate) int {
+		return cmp.Compare(a.rtt, b.rtt)
+	})
+	return candidates
+}
+
+// scoreCandidates orders candidates by the scores of the RelaySelector, dropping
+// the candidates with a negative score.
+func (rf *relayFinder) scoreCandidates(candidates []*candidate) []*candidate {
+	type scored struct {
+		cand  *candidate
+		score float64
+	}
+	s := make([]scored, 0, len(candidates))
+	for _, cand := range candidates {
+		score := rf.conf.selector.Score(RelayCandidate{AddrInfo: cand.ai, RTT: cand.rtt, Added: cand.added})
+		if score < 0 {
+			log.Debugw("relay candidate rejected by selector", "id", cand.ai.ID)
+			continue
+		}
+		s = append(s, scored{cand, score})
+	}
+	slices.SortStableFunc(s, func(a, b scored) int { return cmp.Compare(b.score, a.score) })
+	candidates = candidates[:0]
+	for _, sc := range s {
+		candidates = append(candidates, sc.cand)
+	}
 	return candidates
 }
 
@@ -0,0 +1,100 @@
+package autorelay
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// RelayCandidate is a peer that supports the relay protocol, and that we
+// may obtain a reservation with.
+type RelayCandidate struct {
+	peer.AddrInfo
+	// RTT is the round trip time measured when the candidate was found.
+	// Candidates that don't respond to the probe are dropped, so it is
+	// always set.
+	RTT time.Duration
+	// Added is when the candidate was found.
+	Added time.Time
+}
+
+// RelaySelector scores relay candidates, e.g. by latency, geography or
+// reputation. AutoRelay tries to obtain reservations with the candidates with
+// the highest scores first. Candidates with the same score are tried in
+// random order.
+type RelaySelector interface {
+	// Score returns the score of c. Candidates with a negative score are not
+	// used. Score is called with AutoRelay's locks held and must not block.
+	Score(c RelayCandidate) float64
+}
+
+// RelaySelectorFunc is a function implementing RelaySelector.
+type RelaySelectorFunc func(RelayCandidate) float64
+
+func (f RelaySelectorFunc) Score(c RelayCandidate) float64 { return f(c) }
+
+// WithRelaySelector sets the selector used to choose among relay candidates.
+// By default, candidates with a lower RTT are preferred.
+func WithRelaySelector(s RelaySelector) Option {
+	return func(c *config) error {
+		if s == nil {
+			return errors.New("relay selector is nil")
+		}
+		c.selector = s
+		return nil
+	}
+}
+
+var errNotAttached = errors.New("controller isn't attached to an AutoRelay")
+
+// Controller controls an AutoRelay at runtime. It is useful when the AutoRelay
+// is constructed by libp2p.New, see WithController.
+type Controller struct {
+	mx sync.Mutex
+	ar *AutoRelay
+}
+
+// NewController returns a Controller, to be passed to WithController.
+func NewController() *Controller {
+	return &Controller{}
+}
+
+// WithController attaches c to the AutoRelay.
+func WithController(c *Controller) Option {
+	return func(conf *config) error {
+		if c == nil {
+			return errors.New("controller is nil")
+		}
+		conf.controller = c
+		return nil
+	}
+}
+
+func (c *Controller) autoRelay() (*AutoRelay, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.ar == nil {
+		return nil, errNotAttached
+	}
+	return c.ar, nil
+}
+
+// Relays returns the relays we currently have a reservation with.
+func (c *Controller) Relays() ([]peer.ID, error) {
+	ar, err := c.autoRelay()
+	if err != nil {
+		return nil, err
+	}
+	return ar.Relays(), nil
+}
+
+// RotateAway stops using the relay p. See AutoRelay.RotateAway.
+func (c *Controller) RotateAway(p peer.ID) (bool, error) {
+	ar, err := c.autoRelay()
+	if err != nil {
+		return false, err
+	}
+	return ar.RotateAway(p), nil
+}
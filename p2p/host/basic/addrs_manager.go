@@ -48,6 +48,9 @@ type addrsManager struct {
 	observedAddrsManager     observedAddrsManager
 	interfaceAddrs           *interfaceAddrsCache
 	addrsReachabilityTracker *addrsReachabilityTracker
+	// verifyRelayAddrs restricts the advertised relay addresses to those
+	// confirmed reachable by the addrsReachabilityTracker.
+	verifyRelayAddrs bool
 
 	// addrsUpdatedChan is notified when addrs change. This is provided by the caller.
 	addrsUpdatedChan chan struct{}
@@ -76,9 +79,13 @@ func newAddrsManager(
 	observedAddrsManager observedAddrsManager,
 	addrsUpdatedChan chan struct{},
 	client autonatv2Client,
+	verifyRelayAddrs bool,
 	enableMetrics bool,
 	registerer prometheus.Registerer,
 ) (*addrsManager, error) {
+	if verifyRelayAddrs && client == nil {
+		return nil, errors.New("relay address verification requires autonatv2")
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	as := &addrsManager{
 		bus:                       bus,
@@ -87,6 +94,7 @@ func newAddrsManager(
 		observedAddrsManager:      observedAddrsManager,
 		natManager:                natmgr,
 		addrsFactory:              addrsFactory,
+		verifyRelayAddrs:          verifyRelayAddrs,
 		triggerAddrsUpdateChan:    make(chan struct{}, 1),
 		triggerReachabilityUpdate: make(chan struct{}, 1),
 		addrsUpdatedChan:          addrsUpdatedChan,
@@ -258,17 +266,17 @@ func (a *addrsManager) updateAddrs(updateRelayAddrs bool, relayAddrs []ma.Multia
 	defer a.addrsMx.Unlock()
 
 	localAddrs := a.getLocalAddrs()
-	var currReachableAddrs, currUnreachableAddrs, currUnknownAddrs []ma.Multiaddr
-	if a.addrsReachabilityTracker != nil {
-		currReachableAddrs, currUnreachableAddrs, currUnknownAddrs = a.getConfirmedAddrs(localAddrs)
-	}
 	if !updateRelayAddrs {
 		relayAddrs = a.currentAddrs.relayAddrs
 	} else {
 		// Copy the callers slice
 		relayAddrs = slices.Clone(relayAddrs)
 	}
-	currAddrs := a.getAddrs(slices.Clone(localAddrs), relayAddrs)
+	var currReachableAddrs, currUnreachableAddrs, currUnknownAddrs []ma.Multiaddr
+	if a.addrsReachabilityTracker != nil {
+		currReachableAddrs, currUnreachableAddrs, currUnknownAddrs = a.getConfirmedAddrs(a.trackedAddrs(localAddrs, relayAddrs))
+	}
+	currAddrs := a.getAddrs(slices.Clone(localAddrs), a.advertisedRelayAddrs(relayAddrs, currReachableAddrs))
 
 	a.currentAddrs = hostAddrs{
 		addrs:            append(a.currentAddrs.addrs[:0], currAddrs...),
@@ -292,9 +300,10 @@ func (a *addrsManager) updateAddrs(updateRelayAddrs bool, relayAddrs []ma.Multia
 func (a *addrsManager) notifyAddrsChanged(emitter event.Emitter, previous, current hostAddrs) {
 	if areAddrsDifferent(previous.localAddrs, current.localAddrs) {
 		log.Debugf("host local addresses updated: %s", current.localAddrs)
-		if a.addrsReachabilityTracker != nil {
-			a.addrsReachabilityTracker.UpdateAddrs(current.localAddrs)
-		}
+	}
+	if a.addrsReachabilityTracker != nil && (areAddrsDifferent(previous.localAddrs, current.localAddrs) ||
+		(a.verifyRelayAddrs && areAddrsDifferent(previous.relayAddrs, current.relayAddrs))) {
+		a.addrsReachabilityTracker.UpdateAddrs(a.trackedAddrs(current.localAddrs, current.relayAddrs))
 	}
 	if areAddrsDifferent(previous.addrs, current.addrs) {
 		log.Debugf("host addresses updated: %s", current.localAddrs)
@@ -332,7 +341,7 @@ func (a *addrsManager) notifyAddrsChanged(emitter event.Emitter, previous, curre
 func (a *addrsManager) Addrs() []ma.Multiaddr {
 	a.addrsMx.RLock()
 	directAddrs := slices.Clone(a.currentAddrs.localAddrs)
-	relayAddrs := slices.Clone(a.currentAddrs.relayAddrs)
+	relayAddrs := slices.Clone(a.advertisedRelayAddrs(a.currentAddrs.relayAddrs, a.currentAddrs.reachableAddrs))
 	a.addrsMx.RUnlock()
 	return a.getAddrs(directAddrs, relayAddrs)
 }
@@ -377,20 +386,53 @@ func (a *addrsManager) DirectAddrs() []ma.Multiaddr {
 	return slices.Clone(a.currentAddrs.localAddrs)
 }
 
-// ConfirmedAddrs returns all addresses of the host that are reachable from the internet
+// ConfirmedAddrs returns all addresses of the host that are reachable from the internet.
+// With relay address verification enabled, it includes the relay addresses.
 func (a *addrsManager) ConfirmedAddrs() (reachable []ma.Multiaddr, unreachable []ma.Multiaddr, unknown []ma.Multiaddr) {
 	a.addrsMx.RLock()
 	defer a.addrsMx.RUnlock()
 	return slices.Clone(a.currentAddrs.reachableAddrs), slices.Clone(a.currentAddrs.unreachableAddrs), slices.Clone(a.currentAddrs.unknownAddrs)
 }
 
-func (a *addrsManager) getConfirmedAddrs(localAddrs []ma.Multiaddr) (reachableAddrs, unreachableAddrs, unknownAddrs []ma.Multiaddr) {
+// getConfirmedAddrs returns the confirmed addrs of the tracker that are in trackedAddrs.
+// trackedAddrs must be sorted.
+func (a *addrsManager) getConfirmedAddrs(trackedAddrs []ma.Multiaddr) (reachableAddrs, unreachableAddrs, unknownAddrs []ma.Multiaddr) {
 	reachableAddrs, unreachableAddrs, unknownAddrs = a.addrsReachabilityTracker.ConfirmedAddrs()
-	return removeNotInSource(reachableAddrs, localAddrs), removeNotInSource(unreachableAddrs, localAddrs), removeNotInSource(unknownAddrs, localAddrs)
+	return removeNotInSource(reachableAddrs, trackedAddrs), removeNotInSource(unreachableAddrs, trackedAddrs), removeNotInSource(unknownAddrs, trackedAddrs)
+}
+
+// trackedAddrs returns the sorted addrs whose reachability is tracked by the
+// addrsReachabilityTracker: the local addrs, and the relay addrs if relay
+// address verification is enabled.
+func (a *addrsManager) trackedAddrs(localAddrs, relayAddrs []ma.Multiaddr) []ma.Multiaddr {
+	if !a.verifyRelayAddrs || len(relayAddrs) == 0 {
+		return localAddrs
+	}
+	addrs := append(slices.Clone(localAddrs), relayAddrs...)
+	slices.SortFunc(addrs, func(a, b ma.Multiaddr) int { return a.Compare(b) })
+	return addrs
+}
+
+// advertisedRelayAddrs returns the relay addrs to advertise. If relay address
+// verification is enabled, these are only the relay addrs that were confirmed
+// reachable by a dial-back over the relay. Relay addrs that fail verification
+// are not advertised, so peers don't waste dials on broken reservations.
+func (a *addrsManager) advertisedRelayAddrs(relayAddrs, reachableAddrs []ma.Multiaddr) []ma.Multiaddr {
+	if !a.verifyRelayAddrs {
+		return relayAddrs
+	}
+	return slices.DeleteFunc(slices.Clone(relayAddrs), func(r ma.Multiaddr) bool {
+		return !slices.ContainsFunc(reachableAddrs, r.Equal)
+	})
 }
 
 var p2pCircuitAddr = ma.StringCast("/p2p-circuit")
 
+// isUnixAddr returns true if addr is a Unix domain socket address.
+func isUnixAddr(addr ma.Multiaddr) bool {
+	return len(addr) > 0 && addr[0].Code() == ma.P_UNIX
+}
+
 func (a *addrsManager) getLocalAddrs() []ma.Multiaddr {
 	listenAddrs := a.listenAddrs()
 	if len(listenAddrs) == 0 {
@@ -414,6 +456,10 @@ func (a *addrsManager) getLocalAddrs() []ma.Multiaddr {
 		return manet.IsIPUnspecified(a)
 	})
 
+	// Remove /unix addresses from the list. They name a path on this machine,
+	// which is meaningless to other peers.
+	finalAddrs = slices.DeleteFunc(finalAddrs, isUnixAddr)
+
 	// Add certhashes for /webrtc-direct, /webtransport, etc addresses discovered
 	// using identify.
 	finalAddrs = a.addCertHashes(finalAddrs)
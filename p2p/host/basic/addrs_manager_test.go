@@ -176,6 +176,7 @@ type addrsManagerArgs struct {
 	ObservedAddrsManager observedAddrsManager
 	ListenAddrs          func() []ma.Multiaddr
 	AutoNATClient        autonatv2Client
+	VerifyRelayAddrs     bool
 	Bus                  event.Bus
 }
 
@@ -195,7 +196,7 @@ func newAddrsManagerTestCase(t *testing.T, args addrsManagerArgs) addrsManagerTe
 	}
 	addrsUpdatedChan := make(chan struct{}, 1)
 	am, err := newAddrsManager(
-		eb, args.NATManager, args.AddrsFactory, args.ListenAddrs, nil, args.ObservedAddrsManager, addrsUpdatedChan, args.AutoNATClient, true, prometheus.DefaultRegisterer,
+		eb, args.NATManager, args.AddrsFactory, args.ListenAddrs, nil, args.ObservedAddrsManager, addrsUpdatedChan, args.AutoNATClient, args.VerifyRelayAddrs, true, prometheus.DefaultRegisterer,
 	)
 	require.NoError(t, err)
 
@@ -546,3 +547,59 @@ func BenchmarkRemoveIfNotInSource(b *testing.B) {
 		removeNotInSource(slices.Clone(addrs[:5]), addrs[:])
 	}
 }
+
+func TestAddrsManagerVerifyRelayAddrs(t *testing.T) {
+	publicTCP := ma.StringCast("/ip4/1.2.3.4/tcp/1234")
+	relayOK := ma.StringCast("/ip4/1.1.1.1/tcp/1/p2p/12D3KooWJmGh1a6jmXRKeA6dCV6kiMS7MD6SPxJP3i4qk8BCeqWt/p2p-circuit")
+	relayBroken := ma.StringCast("/ip4/2.2.2.2/tcp/1/p2p/12D3KooWGzxzKZYveHXtpG6AsrUJBcWxHBFS2HsEoGTxrMLvKXtf/p2p-circuit")
+
+	verify := make(chan struct{})
+	am := newAddrsManagerTestCase(t, addrsManagerArgs{
+		ListenAddrs: func() []ma.Multiaddr { return []ma.Multiaddr{publicTCP} },
+		AutoNATClient: mockAutoNATClient{
+			F: func(ctx context.Context, reqs []autonatv2.Request) (autonatv2.Result, error) {
+				select {
+				case <-verify:
+				case <-ctx.Done():
+					return autonatv2.Result{}, ctx.Err()
+				}
+				rch := network.ReachabilityPrivate
+				if reqs[0].Addr.Equal(relayOK) {
+					rch = network.ReachabilityPublic
+				}
+				return autonatv2.Result{Addr: reqs[0].Addr, Idx: 0, Reachability: rch}, nil
+			},
+		},
+		VerifyRelayAddrs: true,
+	})
+	am.PushReachability(network.ReachabilityPrivate)
+	am.PushRelay([]ma.Multiaddr{relayOK, relayBroken})
+
+	// Unverified relay addrs aren't advertised.
+	require.Eventually(t, func() bool {
+		_, _, unknown := am.ConfirmedAddrs()
+		return slices.ContainsFunc(unknown, relayOK.Equal) && slices.ContainsFunc(unknown, relayBroken.Equal)
+	}, 5*time.Second, 50*time.Millisecond)
+	require.ElementsMatch(t, []ma.Multiaddr{publicTCP}, am.Addrs())
+
+	close(verify)
+	require.Eventually(t, func() bool {
+		return slices.EqualFunc(am.Addrs(), []ma.Multiaddr{relayOK}, ma.Multiaddr.Equal)
+	}, 5*time.Second, 50*time.Millisecond)
+	reachable, unreachable, unknown := am.ConfirmedAddrs()
+	require.ElementsMatch(t, []ma.Multiaddr{relayOK}, reachable)
+	require.ElementsMatch(t, []ma.Multiaddr{publicTCP, relayBroken}, unreachable)
+	require.Empty(t, unknown)
+
+	// Removed relay addrs are no longer tracked.
+	am.PushRelay([]ma.Multiaddr{relayBroken})
+	require.Eventually(t, func() bool {
+		reachable, _, _ := am.ConfirmedAddrs()
+		return len(reachable) == 0 && slices.EqualFunc(am.Addrs(), []ma.Multiaddr{publicTCP}, ma.Multiaddr.Equal)
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestAddrsManagerVerifyRelayAddrsRequiresAutoNAT(t *testing.T) {
+	_, err := newAddrsManager(eventbus.NewBus(), nil, nil, nil, nil, nil, nil, nil, true, false, nil)
+	require.Error(t, err)
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
 	"github.com/libp2p/go-libp2p/p2p/host/pstoremanager"
 	"github.com/libp2p/go-libp2p/p2p/host/relaysvc"
+	"github.com/libp2p/go-libp2p/p2p/host/timeline"
 	"github.com/libp2p/go-libp2p/p2p/protocol/autonatv2"
 	relayv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
 	"github.com/libp2p/go-libp2p/p2p/protocol/holepunch"
@@ -82,6 +83,19 @@ type BasicHost struct {
 
 	negtimeout time.Duration
 
+	middlewareMx sync.RWMutex
+	middleware   []StreamMiddleware
+
+	// see Shutdown and EnterMaintenance
+	drainMx     sync.Mutex
+	draining    bool
+	maintenance *maintenanceConfig
+	inflight    sync.WaitGroup
+	// inflightPeers counts the in-flight inbound stream handlers per peer
+	inflightPeers map[peer.ID]int
+	// handlerDone is closed when an in-flight handler returns, if set
+	handlerDone chan struct{}
+
 	emitters struct {
 		evtLocalProtocolsUpdated event.Emitter
 		evtLocalAddrsUpdated     event.Emitter
@@ -97,6 +111,16 @@ type BasicHost struct {
 	autonatv2        *autonatv2.AutoNAT
 	addressManager   *addrsManager
 	addrsUpdatedChan chan struct{}
+
+	// see StartupReport
+	securityProtocols []protocol.ID
+	muxers            []protocol.ID
+
+	timeline *timeline.Timeline
+
+	// unsupportedProtos is nil unless HostOpts.ProtocolNegotiationCacheTTL
+	// is set.
+	unsupportedProtos *unsupportedProtocols
 }
 
 var _ host.Host = (*BasicHost)(nil)
@@ -159,7 +183,42 @@ type HostOpts struct {
 	// DisableIdentifyAddressDiscovery disables address discovery using peer provided observed addresses in identify
 	DisableIdentifyAddressDiscovery bool
 
+	// IdentifyRefreshInterval and IdentifyRefreshJitter configure periodically
+	// re-running identify on connections. See identify.WithRefreshInterval.
+	IdentifyRefreshInterval time.Duration
+	IdentifyRefreshJitter   time.Duration
+
+	// IdentifyPushDebounce and IdentifyPushMinInterval limit identify pushes.
+	// See identify.WithPushCoalescing.
+	IdentifyPushDebounce    time.Duration
+	IdentifyPushMinInterval time.Duration
+
+	// IdentifyAddrCrossCheck checks the addresses claimed by peers against the
+	// source of the connection. See identify.WithAddrCrossCheck.
+	IdentifyAddrCrossCheck identify.AddrCrossCheck
+
 	AutoNATv2 *autonatv2.AutoNAT
+
+	// VerifyRelayAddrs only advertises relay addresses once AutoNATv2 has
+	// verified them with a dial-back over the relay. Requires AutoNATv2.
+	VerifyRelayAddrs bool
+
+	// SecurityProtocols and Muxers are the IDs of the security protocols and
+	// stream muxers used by the network. They are only reported by
+	// StartupReport.
+	SecurityProtocols []protocol.ID
+	Muxers            []protocol.ID
+
+	// PeerTimeline records the connection events of peers. See PeerTimeline.
+	// Dials are only recorded if it is also passed to swarm.WithDialObserver.
+	PeerTimeline *timeline.Timeline
+
+	// ProtocolNegotiationCacheTTL enables caching the protocols that peers
+	// refused during protocol negotiation, for the given duration. NewStream
+	// fails right away for protocols a peer is known not to support. The
+	// cache of a peer is invalidated when the peer is identified again or
+	// pushes an update of its protocols, see also InvalidateProtocolCache.
+	ProtocolNegotiationCacheTTL time.Duration
 }
 
 // NewHost constructs a new *BasicHost and activates it by attaching its stream and connection handlers to the given inet.Network.
@@ -171,7 +230,13 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 		opts.EventBus = eventbus.NewBus()
 	}
 
-	psManager, err := pstoremanager.NewPeerstoreManager(n.Peerstore(), opts.EventBus, n)
+	var psOpts []pstoremanager.Option
+	if opts.EnableMetrics {
+		psOpts = append(psOpts,
+			pstoremanager.WithMetricsTracer(
+				pstoremanager.NewMetricsTracer(pstoremanager.WithRegisterer(opts.PrometheusRegisterer))))
+	}
+	psManager, err := pstoremanager.NewPeerstoreManager(n.Peerstore(), opts.EventBus, n, psOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -187,6 +252,9 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 		ctxCancel:               cancel,
 		disableSignedPeerRecord: opts.DisableSignedPeerRecord,
 		addrsUpdatedChan:        make(chan struct{}, 1),
+		securityProtocols:       opts.SecurityProtocols,
+		muxers:                  opts.Muxers,
+		timeline:                opts.PeerTimeline,
 	}
 
 	if h.emitters.evtLocalProtocolsUpdated, err = h.eventbus.Emitter(&event.EvtLocalProtocolsUpdated{}, eventbus.Stateful); err != nil {
@@ -196,6 +264,19 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 		return nil, err
 	}
 
+	if opts.ProtocolNegotiationCacheTTL > 0 {
+		sub, err := h.eventbus.Subscribe(
+			[]any{new(event.EvtPeerIdentificationCompleted), new(event.EvtPeerProtocolsUpdated)},
+			eventbus.Name("basichost (protocol cache)"),
+		)
+		if err != nil {
+			return nil, err
+		}
+		h.unsupportedProtos = newUnsupportedProtocols(opts.ProtocolNegotiationCacheTTL, nil)
+		h.refCount.Add(1)
+		go h.invalidateProtocolCache(sub)
+	}
+
 	if opts.MultistreamMuxer != nil {
 		h.mux = opts.MultistreamMuxer
 	}
@@ -217,6 +298,15 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 	if opts.DisableIdentifyAddressDiscovery {
 		idOpts = append(idOpts, identify.DisableObservedAddrManager())
 	}
+	if opts.IdentifyRefreshInterval > 0 {
+		idOpts = append(idOpts, identify.WithRefreshInterval(opts.IdentifyRefreshInterval, opts.IdentifyRefreshJitter))
+	}
+	if opts.IdentifyPushDebounce > 0 || opts.IdentifyPushMinInterval > 0 {
+		idOpts = append(idOpts, identify.WithPushCoalescing(opts.IdentifyPushDebounce, opts.IdentifyPushMinInterval))
+	}
+	if opts.IdentifyAddrCrossCheck != identify.AddrCrossCheckDisabled {
+		idOpts = append(idOpts, identify.WithAddrCrossCheck(opts.IdentifyAddrCrossCheck))
+	}
 
 	h.ids, err = identify.NewIDService(h, idOpts...)
 	if err != nil {
@@ -256,6 +346,7 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 		h.ids,
 		h.addrsUpdatedChan,
 		autonatv2Client,
+		opts.VerifyRelayAddrs,
 		opts.EnableMetrics,
 		opts.PrometheusRegisterer,
 	)
@@ -266,12 +357,24 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 	// so we can update our address set and push events if needed
 	h.Network().Notify(h.addressManager.NetNotifee())
 
-	if opts.EnableHolePunching {
-		if opts.EnableMetrics {
-			hpOpts := []holepunch.Option{
-				holepunch.WithMetricsTracer(holepunch.NewMetricsTracer(holepunch.WithRegisterer(opts.PrometheusRegisterer)))}
-			opts.HolePunchingOptions = append(hpOpts, opts.HolePunchingOptions...)
+	if h.timeline != nil {
+		h.Network().Notify(h.timeline.Notifiee())
+	}
 
+	if opts.EnableHolePunching {
+		// A tracer passed in HolePunchingOptions replaces these.
+		var tracerOpt holepunch.Option
+		switch {
+		case opts.EnableMetrics && h.timeline != nil:
+			tracerOpt = holepunch.WithMetricsAndEventTracer(
+				holepunch.NewMetricsTracer(holepunch.WithRegisterer(opts.PrometheusRegisterer)), h.timeline)
+		case opts.EnableMetrics:
+			tracerOpt = holepunch.WithMetricsTracer(holepunch.NewMetricsTracer(holepunch.WithRegisterer(opts.PrometheusRegisterer)))
+		case h.timeline != nil:
+			tracerOpt = holepunch.WithTracer(h.timeline)
+		}
+		if tracerOpt != nil {
+			opts.HolePunchingOptions = append([]holepunch.Option{tracerOpt}, opts.HolePunchingOptions...)
 		}
 		h.hps, err = holepunch.NewService(h, h.ids, h.addressManager.HolePunchAddrs, opts.HolePunchingOptions...)
 		if err != nil {
@@ -360,6 +463,26 @@ func (h *BasicHost) Start() {
 // newStreamHandler is the remote-opened stream handler for network.Network
 // TODO: this feels a bit wonky
 func (h *BasicHost) newStreamHandler(s network.Stream) {
+	h.drainMx.Lock()
+	if h.draining {
+		h.drainMx.Unlock()
+		s.ResetWithError(network.StreamShutdown)
+		return
+	}
+	p := s.Conn().RemotePeer()
+	if h.maintenance != nil && !h.maintenance.allowed(p) {
+		h.drainMx.Unlock()
+		s.ResetWithError(network.StreamMaintenance)
+		return
+	}
+	h.inflight.Add(1)
+	if h.inflightPeers == nil {
+		h.inflightPeers = make(map[peer.ID]int)
+	}
+	h.inflightPeers[p]++
+	h.drainMx.Unlock()
+	defer h.streamHandlerDone(p)
+
 	before := time.Now()
 
 	if h.negtimeout > 0 {
@@ -402,7 +525,15 @@ func (h *BasicHost) newStreamHandler(s network.Stream) {
 
 	log.Debugf("negotiated: %s (took %s)", protoID, took)
 
-	handle(protoID, s)
+	h.middlewareMx.RLock()
+	middleware := h.middleware
+	h.middlewareMx.RUnlock()
+	next := func(s network.Stream) { handle(protoID, s) }
+	for i := len(middleware) - 1; i >= 0; i-- {
+		mw, inner := middleware[i], next
+		next = func(s network.Stream) { mw(s, inner) }
+	}
+	next(s)
 }
 
 func (h *BasicHost) makeUpdatedAddrEvent(prev, current []ma.Multiaddr) *event.EvtLocalAddressesUpdated {
@@ -541,6 +672,12 @@ func (h *BasicHost) IDService() identify.IDService {
 	return h.ids
 }
 
+// HolePunchService returns the hole punching service, e.g. to inspect hole
+// punching attempts. It returns nil if hole punching is disabled.
+func (h *BasicHost) HolePunchService() *holepunch.Service {
+	return h.hps
+}
+
 func (h *BasicHost) EventBus() event.Bus {
 	return h.eventbus
 }
@@ -562,6 +699,35 @@ func (h *BasicHost) SetStreamHandler(pid protocol.ID, handler network.StreamHand
 	})
 }
 
+// StreamMiddleware intercepts inbound streams before they reach the handler of
+// their protocol. The stream's protocol has already been negotiated. A
+// middleware calls next to continue handling the stream, possibly with a
+// wrapped stream, or resets the stream to reject it.
+type StreamMiddleware func(s network.Stream, next network.StreamHandler)
+
+// UseStreamMiddleware adds middleware that wraps the handling of every inbound
+// stream, regardless of its protocol. Middleware is called in the order it was
+// added, the first one being the outermost.
+//
+// (Thread-safe)
+func (h *BasicHost) UseStreamMiddleware(mw ...StreamMiddleware) {
+	h.middlewareMx.Lock()
+	defer h.middlewareMx.Unlock()
+	h.middleware = append(slices.Clip(h.middleware), mw...)
+}
+
+// AdmitPeers returns middleware that resets inbound streams from peers that
+// aren't admitted by allow and deny, see peer.Admitted. Either set may be nil.
+func AdmitPeers(allow, deny *peer.Set) StreamMiddleware {
+	return func(s network.Stream, next network.StreamHandler) {
+		if !peer.Admitted(allow, deny, s.Conn().RemotePeer()) {
+			s.ResetWithError(network.StreamGated)
+			return
+		}
+		next(s)
+	}
+}
+
 // SetStreamHandlerMatch sets the protocol handler on the Host's Mux
 // using a matching function to do protocol comparisons
 func (h *BasicHost) SetStreamHandlerMatch(pid protocol.ID, m func(protocol.ID) bool, handler network.StreamHandler) {
@@ -588,6 +754,14 @@ func (h *BasicHost) RemoveStreamHandler(pid protocol.ID) {
 // to create one. If ProtocolID is "", writes no header.
 // (Thread-safe)
 func (h *BasicHost) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (str network.Stream, strErr error) {
+	if h.unsupportedProtos != nil {
+		if filtered := h.unsupportedProtos.filter(p, pids); len(filtered) > 0 {
+			pids = filtered
+		} else if len(pids) > 0 {
+			return nil, fmt.Errorf("failed to negotiate protocol: %w", msmux.ErrNotSupported[protocol.ID]{Protos: pids})
+		}
+	}
+
 	if _, ok := ctx.Deadline(); !ok {
 		if h.negtimeout > 0 {
 			var cancel context.CancelFunc
@@ -656,6 +830,9 @@ func (h *BasicHost) NewStream(ctx context.Context, p peer.ID, pids ...protocol.I
 	select {
 	case err = <-errCh:
 		if err != nil {
+			if h.unsupportedProtos != nil && errors.Is(err, msmux.ErrNotSupported[protocol.ID]{}) {
+				h.unsupportedProtos.add(p, pids)
+			}
 			return nil, fmt.Errorf("failed to negotiate protocol: %w", err)
 		}
 	case <-ctx.Done():
@@ -731,6 +908,22 @@ func (h *BasicHost) dialPeer(ctx context.Context, p peer.ID) error {
 	return nil
 }
 
+// PeerTimeline returns the connection events recorded for p, oldest first. It
+// returns nil if HostOpts.PeerTimeline wasn't set.
+func (h *BasicHost) PeerTimeline(p peer.ID) []timeline.Event {
+	if h.timeline == nil {
+		return nil
+	}
+	return h.timeline.Peer(p)
+}
+
+// Timeline returns the timeline of connection events, or nil if
+// HostOpts.PeerTimeline wasn't set. It can be served on a debug endpoint, as
+// it implements http.Handler.
+func (h *BasicHost) Timeline() *timeline.Timeline {
+	return h.timeline
+}
+
 func (h *BasicHost) ConnManager() connmgr.ConnManager {
 	return h.cmgr
 }
@@ -760,13 +953,78 @@ func (h *BasicHost) NormalizeMultiaddr(addr ma.Multiaddr) ma.Multiaddr {
 	return addr
 }
 
+// LastIdentified returns when we last received an identify message from p,
+// i.e. how stale the identify information in the peerstore is. It returns
+// false if p wasn't identified on any current connection, or if the identify
+// service doesn't track this.
+func (h *BasicHost) LastIdentified(p peer.ID) (time.Time, bool) {
+	if ids, ok := h.ids.(identify.LastIdentifiedTracker); ok {
+		return ids.LastIdentified(p)
+	}
+	return time.Time{}, false
+}
+
+// ObservedAddrs returns the addresses other peers have observed for the host,
+// with the number of observations and distinct observers for each of them,
+// and whether they're advertised.
+func (h *BasicHost) ObservedAddrs() []identify.ObservedAddr {
+	if ids, ok := h.ids.(identify.ObservedAddrService); ok {
+		return ids.ObservedAddrs()
+	}
+	return nil
+}
+
+// SetObservedAddrOverride pins or vetoes the observed address addr. Pinned
+// addresses are advertised as soon as a single peer observes them, vetoed
+// addresses are never advertised. Setting identify.ObservedAddrNoOverride
+// removes the override.
+func (h *BasicHost) SetObservedAddrOverride(addr ma.Multiaddr, ov identify.ObservedAddrOverride) error {
+	ids, ok := h.ids.(identify.ObservedAddrService)
+	if !ok {
+		return errors.New("identify service doesn't support observed address overrides")
+	}
+	if err := ids.SetObservedAddrOverride(addr, ov); err != nil {
+		return err
+	}
+	h.addressManager.triggerAddrsUpdate()
+	return nil
+}
+
+// Listen starts listening on addrs in addition to the addresses the host
+// already listens on, e.g. to enable a public listener on demand. Like
+// Network().Listen, it succeeds if the host listens on at least one of the
+// addresses. The new addresses are returned by Addrs once Listen returns, and
+// are announced to connected peers.
+func (h *BasicHost) Listen(addrs ...ma.Multiaddr) error {
+	return h.Network().Listen(addrs...)
+}
+
+// CloseListener stops listening on addr, which must be one of the addresses
+// returned by Network().ListenAddresses. Connections accepted by the listener
+// stay open. The address is removed from Addrs once CloseListener returns, and
+// connected peers are told that it's gone.
+func (h *BasicHost) CloseListener(addr ma.Multiaddr) error {
+	lc, ok := h.Network().(interface{ ListenClose(...ma.Multiaddr) })
+	if !ok {
+		return errors.New("network doesn't support closing listeners")
+	}
+	if !ma.Contains(h.Network().ListenAddresses(), addr) {
+		return fmt.Errorf("not listening on %s", addr)
+	}
+	lc.ListenClose(addr)
+	// The swarm notifies about the closed listener asynchronously.
+	h.addressManager.triggerAddrsUpdate()
+	return nil
+}
+
 // AllAddrs returns all the addresses the host is listening on except circuit addresses.
 func (h *BasicHost) AllAddrs() []ma.Multiaddr {
 	return h.addressManager.DirectAddrs()
 }
 
 // ConfirmedAddrs returns all addresses of the host grouped by their reachability
-// as verified by autonatv2.
+// as verified by autonatv2. If HostOpts.VerifyRelayAddrs is set, this includes
+// the relay addresses, reporting which of them are verified.
 //
 // Experimental: This API may change in the future without deprecation.
 //
@@ -775,6 +1033,45 @@ func (h *BasicHost) ConfirmedAddrs() (reachable []ma.Multiaddr, unreachable []ma
 	return h.addressManager.ConfirmedAddrs()
 }
 
+// WaitForAddrs blocks until at least one of the host's addresses, as returned
+// by Addrs, matches matcher, and returns the matching addresses. It returns an
+// error if ctx is cancelled or the host is closed first.
+//
+// This allows applications to wait for a usable address set, e.g. a public
+// QUIC address or a relay address, before publishing the host's addresses to
+// discovery systems.
+func (h *BasicHost) WaitForAddrs(ctx context.Context, matcher func(ma.Multiaddr) bool) ([]ma.Multiaddr, error) {
+	// subscribe before checking the addresses, so that no update is missed
+	sub, err := h.eventbus.Subscribe(&event.EvtLocalAddressesUpdated{}, eventbus.Name("basichost (wait for addrs)"))
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Close()
+
+	for {
+		var matching []ma.Multiaddr
+		for _, a := range h.Addrs() {
+			if matcher(a) {
+				matching = append(matching, a)
+			}
+		}
+		if len(matching) > 0 {
+			return matching, nil
+		}
+
+		select {
+		case _, ok := <-sub.Out():
+			if !ok {
+				return nil, errors.New("host closed")
+			}
+		case <-h.ctx.Done():
+			return nil, errors.New("host closed")
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
 func trimHostAddrList(addrs []ma.Multiaddr, maxSize int) []ma.Multiaddr {
 	totalSize := 0
 	for _, a := range addrs {
@@ -897,6 +1194,201 @@ func (h *BasicHost) Close() error {
 	return nil
 }
 
+type shutdownConfig struct {
+	notifyPeers bool
+}
+
+// ShutdownOption configures Shutdown.
+type ShutdownOption func(*shutdownConfig)
+
+// ShutdownNotifyPeers makes Shutdown tell connected peers that the host is
+// going away. The host removes the handlers of all protocols but identify, and
+// pushes the remaining protocols to the connected peers before waiting for the
+// in-flight stream handlers. This is best effort: the push may not reach all
+// peers before the context passed to Shutdown is done.
+func ShutdownNotifyPeers() ShutdownOption {
+	return func(cfg *shutdownConfig) {
+		cfg.notifyPeers = true
+	}
+}
+
+// Shutdown gracefully shuts down the host. It closes the listeners and refuses
+// new inbound connections. Streams that peers open on existing connections are
+// reset with network.StreamShutdown. It then waits for the handlers of the
+// inbound streams that are in flight to return, and closes the host. If ctx is
+// done before all handlers have returned, the host is closed anyway and
+// ctx.Err() is returned.
+func (h *BasicHost) Shutdown(ctx context.Context, opts ...ShutdownOption) error {
+	var cfg shutdownConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	h.drainMx.Lock()
+	h.draining = true
+	h.drainMx.Unlock()
+
+	if pn, ok := h.Network().(interface{ PauseInbound(func(peer.ID) bool) }); ok {
+		pn.PauseInbound(nil)
+	}
+	if ln, ok := h.Network().(interface{ ListenClose(...ma.Multiaddr) }); ok {
+		ln.ListenClose(h.Network().ListenAddresses()...)
+	}
+
+	if cfg.notifyPeers {
+		// Identify stays registered, so that peers can still identify us
+		// while we're pushing.
+		for _, p := range h.Mux().Protocols() {
+			if p != identify.ID && p != identify.IDPush {
+				h.RemoveStreamHandler(p)
+			}
+		}
+		if ids, ok := h.ids.(interface{ PushNow(context.Context) }); ok {
+			ids.PushNow(ctx)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		h.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return h.Close()
+	case <-ctx.Done():
+		h.Close()
+		return ctx.Err()
+	}
+}
+
+func (h *BasicHost) streamHandlerDone(p peer.ID) {
+	h.drainMx.Lock()
+	h.inflightPeers[p]--
+	if h.inflightPeers[p] == 0 {
+		delete(h.inflightPeers, p)
+	}
+	if h.handlerDone != nil {
+		close(h.handlerDone)
+		h.handlerDone = nil
+	}
+	h.drainMx.Unlock()
+	h.inflight.Done()
+}
+
+type maintenanceConfig struct {
+	cmgr       connmgr.ConnManager
+	allow      func(peer.ID) bool
+	closeConns bool
+}
+
+func (cfg *maintenanceConfig) allowed(p peer.ID) bool {
+	return cfg.cmgr.IsProtected(p, "") || (cfg.allow != nil && cfg.allow(p))
+}
+
+// MaintenanceOption configures EnterMaintenance.
+type MaintenanceOption func(*maintenanceConfig)
+
+// MaintenanceAllow keeps accepting connections and streams from the peers
+// allow returns true for, in addition to the protected peers.
+func MaintenanceAllow(allow func(peer.ID) bool) MaintenanceOption {
+	return func(cfg *maintenanceConfig) {
+		cfg.allow = allow
+	}
+}
+
+// MaintenanceCloseConns makes EnterMaintenance close the connections to the
+// peers that aren't allowed once their in-flight streams have been handled.
+func MaintenanceCloseConns() MaintenanceOption {
+	return func(cfg *maintenanceConfig) {
+		cfg.closeConns = true
+	}
+}
+
+// EnterMaintenance puts the host in maintenance mode, to drain it without
+// shutting it down. New inbound connections are closed with
+// network.ConnMaintenance, and new inbound streams are reset with
+// network.StreamMaintenance, unless the remote peer is protected by the
+// connection manager or allowed by MaintenanceAllow. Listeners stay open,
+// and outbound connections and streams keep working.
+//
+// EnterMaintenance waits for the handlers of the in-flight inbound streams of
+// the peers that aren't allowed to return. If ctx is done first, it returns
+// ctx.Err(), but the host stays in maintenance mode until ExitMaintenance is
+// called.
+func (h *BasicHost) EnterMaintenance(ctx context.Context, opts ...MaintenanceOption) error {
+	cfg := &maintenanceConfig{cmgr: h.cmgr}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	h.drainMx.Lock()
+	h.maintenance = cfg
+	h.drainMx.Unlock()
+	if pn, ok := h.Network().(interface{ PauseInbound(func(peer.ID) bool) }); ok {
+		pn.PauseInbound(cfg.allowed)
+	}
+
+	err := h.waitForHandlers(ctx, cfg.allowed)
+
+	if cfg.closeConns {
+		for _, c := range h.Network().Conns() {
+			if !cfg.allowed(c.RemotePeer()) {
+				c.CloseWithError(network.ConnMaintenance)
+			}
+		}
+	}
+	return err
+}
+
+// waitForHandlers waits for the in-flight inbound stream handlers of the peers
+// allowed returns false for to return.
+func (h *BasicHost) waitForHandlers(ctx context.Context, allowed func(peer.ID) bool) error {
+	for {
+		h.drainMx.Lock()
+		pending := false
+		for p := range h.inflightPeers {
+			if !allowed(p) {
+				pending = true
+				break
+			}
+		}
+		if !pending {
+			h.drainMx.Unlock()
+			return nil
+		}
+		if h.handlerDone == nil {
+			h.handlerDone = make(chan struct{})
+		}
+		done := h.handlerDone
+		h.drainMx.Unlock()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// ExitMaintenance makes the host accept inbound connections and streams
+// again after EnterMaintenance.
+func (h *BasicHost) ExitMaintenance() {
+	h.drainMx.Lock()
+	h.maintenance = nil
+	h.drainMx.Unlock()
+	if pn, ok := h.Network().(interface{ ResumeInbound() }); ok {
+		pn.ResumeInbound()
+	}
+}
+
+// InMaintenance reports whether the host is in maintenance mode.
+func (h *BasicHost) InMaintenance() bool {
+	h.drainMx.Lock()
+	defer h.drainMx.Unlock()
+	return h.maintenance != nil
+}
+
 type streamWrapper struct {
 	network.Stream
 	rw io.ReadWriteCloser
@@ -914,6 +1406,13 @@ func (s *streamWrapper) Close() error {
 	return s.rw.Close()
 }
 
+func (s *streamWrapper) SetPriority(priority uint8) error {
+	if p, ok := s.Stream.(network.StreamPrioritizer); ok {
+		return p.SetPriority(priority)
+	}
+	return network.ErrStreamPriorityUnsupported
+}
+
 func (s *streamWrapper) CloseWrite() error {
 	// Flush the handshake before closing, but ignore the error. The other
 	// end may have closed their side for reading.
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -22,11 +23,15 @@ import (
 	"github.com/libp2p/go-libp2p/core/record"
 	"github.com/libp2p/go-libp2p/p2p/host/autonat"
 	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+	"github.com/libp2p/go-libp2p/p2p/host/timeline"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
 	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
 	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
 
 	ma "github.com/multiformats/go-multiaddr"
 	"github.com/multiformats/go-multiaddr/matest"
+	msmux "github.com/multiformats/go-multistream"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -82,6 +87,36 @@ func TestHostSimple(t *testing.T) {
 	require.Equal(t, buf1, buf3)
 }
 
+func TestLastIdentified(t *testing.T) {
+	h1, err := NewHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport), nil)
+	require.NoError(t, err)
+	defer h1.Close()
+	h1.Start()
+	h2, err := NewHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport), nil)
+	require.NoError(t, err)
+	defer h2.Close()
+	h2.Start()
+
+	_, ok := h1.LastIdentified(h2.ID())
+	require.False(t, ok)
+	before := time.Now()
+	require.NoError(t, h1.Connect(context.Background(), h2.Peerstore().PeerInfo(h2.ID())))
+	last, ok := h1.LastIdentified(h2.ID())
+	require.True(t, ok)
+	require.False(t, last.Before(before))
+}
+
+func TestObservedAddrServiceUnsupported(t *testing.T) {
+	h, err := NewHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport), nil)
+	require.NoError(t, err)
+	defer h.Close()
+	// hide the optional methods of the identify service
+	h.ids = struct{ identify.IDService }{h.ids}
+
+	require.Nil(t, h.ObservedAddrs())
+	require.Error(t, h.SetObservedAddrOverride(ma.StringCast("/ip4/1.2.3.4/tcp/1"), identify.ObservedAddrPinned))
+}
+
 func TestMultipleClose(t *testing.T) {
 	h, err := NewHost(swarmt.GenSwarm(t), nil)
 	require.NoError(t, err)
@@ -374,6 +409,337 @@ func TestHostProtoPreference(t *testing.T) {
 	s3.Close()
 }
 
+func TestStreamMiddleware(t *testing.T) {
+	h1, h2 := getHostPair(t)
+	defer h1.Close()
+	defer h2.Close()
+
+	var mx sync.Mutex
+	var calls []string
+	record := func(name string, s network.Stream) {
+		// ignore the streams of the host's own protocols, e.g. identify
+		if s.Protocol() != "/testing" && s.Protocol() != "/reject" {
+			return
+		}
+		mx.Lock()
+		defer mx.Unlock()
+		calls = append(calls, fmt.Sprintf("%s %s", name, s.Protocol()))
+	}
+	h2.(*BasicHost).UseStreamMiddleware(
+		func(s network.Stream, next network.StreamHandler) {
+			record("first", s)
+			next(s)
+		},
+		func(s network.Stream, next network.StreamHandler) {
+			record("second", s)
+			if s.Protocol() == "/reject" {
+				s.Reset()
+				return
+			}
+			next(s)
+		},
+	)
+
+	handled := make(chan protocol.ID, 2)
+	handler := func(s network.Stream) {
+		handled <- s.Protocol()
+		s.Close()
+	}
+	h2.SetStreamHandler("/testing", handler)
+	h2.SetStreamHandler("/reject", handler)
+
+	s, err := h1.NewStream(context.Background(), h2.ID(), "/testing")
+	require.NoError(t, err)
+	_, err = s.Write([]byte("hello"))
+	require.NoError(t, err)
+	assertWait(t, handled, "/testing")
+	s.Close()
+
+	// depending on whether the protocol is negotiated lazily, the reset is
+	// either observed when opening the stream or when reading from it
+	s, err = h1.NewStream(context.Background(), h2.ID(), "/reject")
+	if err == nil {
+		s.Write([]byte("hello"))
+		_, err = s.Read(make([]byte, 1))
+	}
+	require.Error(t, err)
+	require.Empty(t, handled)
+
+	mx.Lock()
+	defer mx.Unlock()
+	require.Equal(t, []string{"first /testing", "second /testing", "first /reject", "second /reject"}, calls)
+}
+
+func TestShutdown(t *testing.T) {
+	h1, h2 := getHostPair(t)
+	defer h1.Close()
+
+	release := make(chan struct{})
+	handling := make(chan struct{})
+	h2.SetStreamHandler("/testing", func(s network.Stream) {
+		close(handling)
+		<-release
+		s.Close()
+	})
+
+	s, err := h1.NewStream(context.Background(), h2.ID(), "/testing")
+	require.NoError(t, err)
+	_, err = s.Write([]byte("hello"))
+	require.NoError(t, err)
+	<-handling
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- h2.(*BasicHost).Shutdown(context.Background(), ShutdownNotifyPeers())
+	}()
+
+	// peers are told that the host is going away, and can still identify it
+	require.Eventually(t, func() bool {
+		protos, err := h1.Peerstore().GetProtocols(h2.ID())
+		return err == nil && !slices.Contains(protos, "/testing")
+	}, 5*time.Second, 10*time.Millisecond)
+	protos, err := h1.Peerstore().GetProtocols(h2.ID())
+	require.NoError(t, err)
+	require.ElementsMatch(t, []protocol.ID{identify.ID, identify.IDPush}, protos)
+	require.Contains(t, h2.Mux().Protocols(), protocol.ID(identify.ID))
+	require.Empty(t, h2.Network().ListenAddresses())
+
+	// new streams are rejected while the in-flight stream is handled
+	s2, err := h1.NewStream(context.Background(), h2.ID(), "/testing")
+	if err == nil {
+		s2.Write([]byte("hello"))
+		_, err = s2.Read(make([]byte, 1))
+	}
+	require.Error(t, err)
+
+	select {
+	case <-shutdownErr:
+		t.Fatal("shutdown didn't wait for the in-flight stream")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case err := <-shutdownErr:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for shutdown")
+	}
+	require.Empty(t, h2.Network().Conns())
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	h1, h2 := getHostPair(t)
+	defer h1.Close()
+
+	handling := make(chan struct{})
+	h2.SetStreamHandler("/testing", func(s network.Stream) {
+		close(handling)
+		io.Copy(io.Discard, s)
+	})
+
+	s, err := h1.NewStream(context.Background(), h2.ID(), "/testing")
+	require.NoError(t, err)
+	_, err = s.Write([]byte("hello"))
+	require.NoError(t, err)
+	<-handling
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, h2.(*BasicHost).Shutdown(ctx), context.DeadlineExceeded)
+	require.Empty(t, h2.Network().Conns())
+}
+
+func newMaintenanceHosts(t *testing.T, n int) []host.Host {
+	t.Helper()
+	hosts := make([]host.Host, n)
+	for i := range hosts {
+		h, err := NewHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC), nil)
+		require.NoError(t, err)
+		h.Start()
+		t.Cleanup(func() { h.Close() })
+		hosts[i] = h
+	}
+	require.NoError(t, hosts[0].Connect(context.Background(), hosts[1].Peerstore().PeerInfo(hosts[1].ID())))
+	return hosts
+}
+
+func TestMaintenance(t *testing.T) {
+	hosts := newMaintenanceHosts(t, 4)
+	h1, h2, h3, h4 := hosts[0], hosts[1], hosts[2], hosts[3]
+
+	release := make(chan struct{})
+	handling := make(chan struct{}, 1)
+	h2.SetStreamHandler("/testing", func(s network.Stream) {
+		select {
+		case handling <- struct{}{}:
+		default:
+		}
+		<-release
+		s.Write([]byte("x"))
+		s.Close()
+	})
+	openStream := func(h host.Host) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s, err := h.NewStream(ctx, h2.ID(), "/testing")
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+		if _, err := s.Write([]byte("hello")); err != nil {
+			return err
+		}
+		_, err = s.Read(make([]byte, 1))
+		return err
+	}
+
+	inflight := make(chan error, 1)
+	go func() { inflight <- openStream(h1) }()
+	<-handling
+
+	bh := h2.(*BasicHost)
+	entered := make(chan error, 1)
+	go func() {
+		entered <- bh.EnterMaintenance(context.Background(),
+			MaintenanceAllow(func(p peer.ID) bool { return p == h3.ID() }),
+		)
+	}()
+	require.Eventually(t, bh.InMaintenance, 5*time.Second, 10*time.Millisecond)
+	select {
+	case <-entered:
+		t.Fatal("EnterMaintenance didn't wait for the in-flight stream")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// new streams from peers that aren't allowed are reset
+	err := openStream(h1)
+	var serr *network.StreamError
+	require.ErrorAs(t, err, &serr)
+	require.Equal(t, network.StreamMaintenance, serr.ErrorCode)
+	require.True(t, serr.Remote)
+
+	close(release)
+	require.NoError(t, <-inflight)
+	require.NoError(t, <-entered)
+
+	// new connections are refused, unless the peer is allowed
+	h2pi := h2.Peerstore().PeerInfo(h2.ID())
+	// the connection is established, but h2 closes it right away
+	require.NoError(t, h4.Connect(context.Background(), h2pi))
+	require.Eventually(t, func() bool { return len(h4.Network().ConnsToPeer(h2.ID())) == 0 }, 5*time.Second, 10*time.Millisecond)
+	h4.Peerstore().AddAddrs(h2.ID(), h2pi.Addrs, peerstore.PermanentAddrTTL)
+	require.Error(t, openStream(h4))
+	require.Empty(t, h2.Network().ConnsToPeer(h4.ID()))
+	require.NoError(t, h3.Connect(context.Background(), h2pi))
+	require.NoError(t, openStream(h3))
+
+	// outbound connections keep working
+	require.NoError(t, h2.Connect(context.Background(), h4.Peerstore().PeerInfo(h4.ID())))
+	h2.Network().ClosePeer(h4.ID())
+
+	bh.ExitMaintenance()
+	require.False(t, bh.InMaintenance())
+	require.NoError(t, openStream(h1))
+	h4.Network().(interface{ Backoff() *swarm.DialBackoff }).Backoff().Clear(h2.ID())
+	require.NoError(t, openStream(h4))
+}
+
+func TestMaintenanceCloseConns(t *testing.T) {
+	hosts := newMaintenanceHosts(t, 2)
+	h1, h2 := hosts[0], hosts[1]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, h2.(*BasicHost).EnterMaintenance(ctx, MaintenanceCloseConns()))
+	require.Empty(t, h2.Network().Conns())
+	require.Eventually(t, func() bool { return len(h1.Network().ConnsToPeer(h2.ID())) == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestStartupReport(t *testing.T) {
+	muxers := []protocol.ID{"/yamux/1.0.0"}
+	secs := []protocol.ID{"/noise"}
+	h, err := NewHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC), &HostOpts{
+		EnablePing:        true,
+		Muxers:            muxers,
+		SecurityProtocols: secs,
+	})
+	require.NoError(t, err)
+	h.Start()
+	defer h.Close()
+
+	r := h.StartupReport()
+	require.Equal(t, h.ID(), r.ID)
+	require.Equal(t, muxers, r.Muxers)
+	require.Equal(t, secs, r.SecurityProtocols)
+	require.NotEmpty(t, r.Addrs)
+	require.Len(t, r.Listeners, len(h.Network().ListenAddresses()))
+	for _, l := range r.Listeners {
+		require.Equal(t, "TCP", l.Transport, "listener %s", l.Addr)
+	}
+	require.Contains(t, r.Services, "identify")
+	require.Contains(t, r.Services, "ping")
+	require.NotContains(t, r.Services, "relay")
+	require.Nil(t, r.SystemLimits)
+
+	limiter := rcmgr.NewFixedLimiter(rcmgr.DefaultLimits.AutoScale())
+	rm, err := rcmgr.NewResourceManager(limiter)
+	require.NoError(t, err)
+	h2, err := NewHost(swarmt.GenSwarm(t, swarmt.WithSwarmOpts(swarm.WithResourceManager(rm)),
+		swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC), nil)
+	require.NoError(t, err)
+	h2.Start()
+	defer h2.Close()
+	r = h2.StartupReport()
+	require.NotNil(t, r.SystemLimits)
+	require.Equal(t, limiter.GetSystemLimits().GetConnTotalLimit(), r.SystemLimits.Conns)
+	require.Equal(t, limiter.GetSystemLimits().GetMemoryLimit(), r.SystemLimits.Memory)
+}
+
+func TestPeerTimeline(t *testing.T) {
+	tl, err := timeline.New()
+	require.NoError(t, err)
+	sw := swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC,
+		swarmt.WithSwarmOpts(swarm.WithDialObserver(tl)))
+	h1, err := NewHost(sw, &HostOpts{PeerTimeline: tl})
+	require.NoError(t, err)
+	h1.Start()
+	defer h1.Close()
+	h2, err := NewHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC), nil)
+	require.NoError(t, err)
+	h2.Start()
+	defer h2.Close()
+
+	types := func(p peer.ID) []timeline.EventType {
+		var types []timeline.EventType
+		for _, e := range h1.PeerTimeline(p) {
+			types = append(types, e.Type)
+		}
+		return types
+	}
+
+	// no addresses; dials that don't go through the host are recorded too
+	_, err = h1.Network().DialPeer(context.Background(), h2.ID())
+	require.Error(t, err)
+	require.Equal(t, []timeline.EventType{timeline.DialStarted, timeline.DialFailed}, types(h2.ID()))
+
+	require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+	conns := h1.Network().ConnsToPeer(h2.ID())
+	require.Len(t, conns, 1)
+	require.NoError(t, conns[0].CloseWithError(network.ConnGarbageCollected))
+	require.Eventually(t, func() bool { return len(types(h2.ID())) == 5 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []timeline.EventType{
+		timeline.DialStarted, timeline.DialFailed, timeline.DialStarted, timeline.Connected, timeline.Disconnected,
+	}, types(h2.ID()))
+
+	evts := h1.PeerTimeline(h2.ID())
+	connected, disconnected := evts[3], evts[4]
+	require.Equal(t, network.DirOutbound, connected.Direction)
+	require.True(t, connected.Addr.Equal(conns[0].RemoteMultiaddr()))
+	require.False(t, connected.Relayed)
+	require.Contains(t, disconnected.Err, "0x1005")
+	require.Positive(t, disconnected.Duration)
+}
+
 func TestHostProtoMismatch(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -540,6 +906,42 @@ func TestNewStreamResolve(t *testing.T) {
 	assertWait(t, connectedOn, "/testing")
 }
 
+func TestProtocolNegotiationCache(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	h1, err := NewHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC), &HostOpts{ProtocolNegotiationCacheTTL: time.Hour})
+	require.NoError(t, err)
+	h1.Start()
+	defer h1.Close()
+	h2, err := NewHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC), nil)
+	require.NoError(t, err)
+	h2.Start()
+	defer h2.Close()
+	h2pi := h2.Peerstore().PeerInfo(h2.ID())
+	require.NoError(t, h1.Connect(ctx, h2pi))
+
+	_, err = h1.NewStream(ctx, h2.ID(), "/unsupported")
+	require.ErrorIs(t, err, msmux.ErrNotSupported[protocol.ID]{})
+
+	// The refusal is cached, so we don't even need a connection to fail.
+	require.NoError(t, h1.Network().ClosePeer(h2.ID()))
+	_, err = h1.NewStream(network.WithNoDial(ctx, "test"), h2.ID(), "/unsupported")
+	require.ErrorIs(t, err, msmux.ErrNotSupported[protocol.ID]{})
+
+	// Identifying the peer again invalidates the cache.
+	h2.SetStreamHandler("/unsupported", func(s network.Stream) { s.Close() })
+	require.NoError(t, h1.Connect(ctx, h2pi))
+	require.Eventually(t, func() bool {
+		s, err := h1.NewStream(ctx, h2.ID(), "/unsupported")
+		if err != nil {
+			return false
+		}
+		s.Close()
+		return true
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
 func TestProtoDowngrade(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -596,6 +998,48 @@ func TestProtoDowngrade(t *testing.T) {
 	assertWait(t, connectedOn, "/testing")
 }
 
+func TestVersionedStream(t *testing.T) {
+	h1, err := NewHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC), nil)
+	require.NoError(t, err)
+	h1.Start()
+	defer h1.Close()
+	h2, err := NewHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC), nil)
+	require.NoError(t, err)
+	h2.Start()
+	defer h2.Close()
+
+	connectedOn := make(chan protocol.ID, 1)
+	require.NoError(t, host.SetVersionedStreamHandler(h2, "/testing/1.4.2", func(s network.Stream) {
+		connectedOn <- s.Protocol()
+		s.Close()
+	}))
+	require.Error(t, host.SetVersionedStreamHandler(h2, "/testing", func(network.Stream) {}))
+	h1.Peerstore().AddAddrs(h2.ID(), h2.Addrs(), peerstore.PermanentAddrTTL)
+
+	// h2 advertises 1.4.2, which is the highest version satisfying 1.2.0.
+	s, v, err := host.NewVersionedStream(context.Background(), h1, h2.ID(), "/testing/1.2.0")
+	require.NoError(t, err)
+	require.Equal(t, protocol.Version{Major: 1, Minor: 4, Patch: 2}, v)
+	// the protocol is selected lazily, write to trigger the handler
+	_, err = s.Write([]byte("foo"))
+	require.NoError(t, err)
+	assertWait(t, connectedOn, "/testing/1.4.2")
+	s.Close()
+
+	// h2 doesn't satisfy 1.5.0 or 2.0.0.
+	_, _, err = host.NewVersionedStream(context.Background(), h1, h2.ID(), "/testing/1.5.0")
+	require.Error(t, err)
+	_, _, err = host.NewVersionedStream(context.Background(), h1, h2.ID(), "/testing/2.0.0")
+	require.Error(t, err)
+
+	// Requests for older versions are accepted, even if not advertised.
+	s, err = h1.NewStream(context.Background(), h2.ID(), "/testing/1.0.0")
+	require.NoError(t, err)
+	_, err = s.Read(make([]byte, 1))
+	require.ErrorIs(t, err, io.EOF)
+	assertWait(t, connectedOn, "/testing/1.0.0")
+}
+
 func TestAddrChangeImmediatelyIfAddressNonEmpty(t *testing.T) {
 	ctx := context.Background()
 	taddrs := []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/1234")}
@@ -755,6 +1199,121 @@ func TestHostAddrChangeDetection(t *testing.T) {
 	}
 }
 
+func TestWaitForAddrs(t *testing.T) {
+	var lk sync.Mutex
+	var addrs []ma.Multiaddr
+	addrsFactory := func(_ []ma.Multiaddr) []ma.Multiaddr {
+		lk.Lock()
+		defer lk.Unlock()
+		return addrs
+	}
+	h, err := NewHost(swarmt.GenSwarm(t), &HostOpts{AddrsFactory: addrsFactory})
+	require.NoError(t, err)
+	h.Start()
+	defer h.Close()
+
+	isQUIC := func(a ma.Multiaddr) bool {
+		_, err := a.ValueForProtocol(ma.P_QUIC_V1)
+		return err == nil
+	}
+	setAddrs := func(s ...string) {
+		lk.Lock()
+		addrs = nil
+		for _, a := range s {
+			addrs = append(addrs, ma.StringCast(a))
+		}
+		lk.Unlock()
+		h.addressManager.triggerAddrsUpdate()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = h.WaitForAddrs(ctx, isQUIC)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	type result struct {
+		addrs []ma.Multiaddr
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		addrs, err := h.WaitForAddrs(ctx, isQUIC)
+		done <- result{addrs, err}
+	}()
+	setAddrs("/ip4/1.2.3.4/tcp/1234")
+	select {
+	case <-done:
+		t.Fatal("expected WaitForAddrs to block without a matching address")
+	case <-time.After(100 * time.Millisecond):
+	}
+	setAddrs("/ip4/1.2.3.4/tcp/1234", "/ip4/1.2.3.4/udp/1234/quic-v1")
+	res := <-done
+	require.NoError(t, res.err)
+	require.Equal(t, []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/udp/1234/quic-v1")}, res.addrs)
+
+	// returns immediately if an address already matches
+	matching, err := h.WaitForAddrs(context.Background(), isQUIC)
+	require.NoError(t, err)
+	require.Len(t, matching, 1)
+}
+
+func TestListenAndCloseListener(t *testing.T) {
+	opts := []swarmt.Option{swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport}
+	h1, err := NewHost(swarmt.GenSwarm(t, opts...), nil)
+	require.NoError(t, err)
+	h1.Start()
+	defer h1.Close()
+	h2, err := NewHost(swarmt.GenSwarm(t, opts...), nil)
+	require.NoError(t, err)
+	h2.Start()
+	defer h2.Close()
+	require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+
+	sub, err := h1.EventBus().Subscribe(&event.EvtLocalAddressesUpdated{}, eventbus.BufSize(10))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	before := h1.Network().ListenAddresses()
+	require.NoError(t, h1.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0")))
+	var addr ma.Multiaddr
+	for _, a := range h1.Network().ListenAddresses() {
+		if !ma.Contains(before, a) {
+			addr = a
+		}
+	}
+	require.NotNil(t, addr)
+	require.True(t, ma.Contains(h1.Addrs(), addr))
+	waitForAddrUpdate := func(action event.AddrAction) {
+		t.Helper()
+		for {
+			evt := waitForAddrChangeEvent(context.Background(), sub, t)
+			for _, u := range append(evt.Current, evt.Removed...) {
+				if u.Address.Equal(addr) && u.Action == action {
+					return
+				}
+			}
+		}
+	}
+	waitForAddrUpdate(event.Added)
+	require.Eventually(t, func() bool {
+		return ma.Contains(h2.Peerstore().Addrs(h1.ID()), addr)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, h1.CloseListener(addr))
+	require.False(t, ma.Contains(h1.Network().ListenAddresses(), addr))
+	require.False(t, ma.Contains(h1.Addrs(), addr))
+	waitForAddrUpdate(event.Removed)
+	require.Eventually(t, func() bool {
+		return !ma.Contains(h2.Peerstore().Addrs(h1.ID()), addr)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.Error(t, h1.CloseListener(addr))
+	// Existing connections are unaffected.
+	require.Equal(t, network.Connected, h2.Network().Connectedness(h1.ID()))
+}
+
 func TestNegotiationCancel(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -0,0 +1,108 @@
+package basichost
+
+import (
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// unsupportedProtocols caches the protocols that peers refused during
+// multistream negotiation, so that NewStream fails fast for them. Supported
+// protocols are cached in the peerstore.
+type unsupportedProtocols struct {
+	ttl   time.Duration
+	clock clock.Clock
+
+	mx    sync.Mutex
+	peers map[peer.ID]map[protocol.ID]time.Time // protocol -> expiry
+}
+
+func newUnsupportedProtocols(ttl time.Duration, cl clock.Clock) *unsupportedProtocols {
+	if cl == nil {
+		cl = clock.New()
+	}
+	return &unsupportedProtocols{ttl: ttl, clock: cl, peers: make(map[peer.ID]map[protocol.ID]time.Time)}
+}
+
+// add records that p doesn't support pids.
+func (c *unsupportedProtocols) add(p peer.ID, pids []protocol.ID) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	protos, ok := c.peers[p]
+	if !ok {
+		protos = make(map[protocol.ID]time.Time, len(pids))
+		c.peers[p] = protos
+	}
+	expiry := c.clock.Now().Add(c.ttl)
+	for _, pid := range pids {
+		protos[pid] = expiry
+	}
+}
+
+// filter returns the protocols of pids that p isn't known not to support.
+func (c *unsupportedProtocols) filter(p peer.ID, pids []protocol.ID) []protocol.ID {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	protos, ok := c.peers[p]
+	if !ok {
+		return pids
+	}
+	now := c.clock.Now()
+	filtered := make([]protocol.ID, 0, len(pids))
+	for _, pid := range pids {
+		if expiry, ok := protos[pid]; ok {
+			if now.Before(expiry) {
+				continue
+			}
+			delete(protos, pid)
+		}
+		filtered = append(filtered, pid)
+	}
+	if len(protos) == 0 {
+		delete(c.peers, p)
+	}
+	return filtered
+}
+
+// invalidate forgets the protocols p refused.
+func (c *unsupportedProtocols) invalidate(p peer.ID) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	delete(c.peers, p)
+}
+
+// InvalidateProtocolCache forgets the protocols p refused during protocol
+// negotiation. It is a no-op unless the cache is enabled, see
+// HostOpts.ProtocolNegotiationCacheTTL.
+func (h *BasicHost) InvalidateProtocolCache(p peer.ID) {
+	if h.unsupportedProtos != nil {
+		h.unsupportedProtos.invalidate(p)
+	}
+}
+
+// invalidateProtocolCache forgets the protocols refused by peers that were
+// identified again, or that pushed an update of their protocols.
+func (h *BasicHost) invalidateProtocolCache(sub event.Subscription) {
+	defer h.refCount.Done()
+	defer sub.Close()
+	for {
+		select {
+		case evt, ok := <-sub.Out():
+			if !ok {
+				return
+			}
+			switch evt := evt.(type) {
+			case event.EvtPeerIdentificationCompleted:
+				h.unsupportedProtos.invalidate(evt.Peer)
+			case event.EvtPeerProtocolsUpdated:
+				h.unsupportedProtos.invalidate(evt.Peer)
+			}
+		case <-h.ctx.Done():
+			return
+		}
+	}
+}
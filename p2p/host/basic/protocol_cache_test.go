@@ -0,0 +1,38 @@
+package basichost
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/core/test"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnsupportedProtocolsExpire(t *testing.T) {
+	cl := clock.NewMock()
+	c := newUnsupportedProtocols(time.Minute, cl)
+	p := test.RandPeerIDFatal(t)
+	pids := []protocol.ID{"/a", "/b"}
+
+	c.add(p, []protocol.ID{"/a"})
+	require.Equal(t, []protocol.ID{"/b"}, c.filter(p, pids))
+
+	cl.Add(time.Minute - time.Second)
+	c.add(p, []protocol.ID{"/b"})
+	require.Empty(t, c.filter(p, pids))
+
+	// /a expires, /b is still cached
+	cl.Add(time.Second)
+	require.Equal(t, []protocol.ID{"/a"}, c.filter(p, pids))
+
+	cl.Add(time.Minute)
+	require.Equal(t, pids, c.filter(p, pids))
+	require.Empty(t, c.peers)
+
+	c.add(p, pids)
+	c.invalidate(p)
+	require.Equal(t, pids, c.filter(p, pids))
+}
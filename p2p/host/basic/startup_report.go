@@ -0,0 +1,128 @@
+package basichost
+
+import (
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/core/transport"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// ListenerReport describes an address the host listens on.
+type ListenerReport struct {
+	Addr ma.Multiaddr
+	// Transport is the name of the transport listening on Addr. It is empty if
+	// the network doesn't expose its transports.
+	Transport string
+}
+
+// ResourceLimits are the limits of a resource manager scope.
+type ResourceLimits struct {
+	Streams         int
+	StreamsInbound  int
+	StreamsOutbound int
+	Conns           int
+	ConnsInbound    int
+	ConnsOutbound   int
+	FD              int
+	Memory          int64
+}
+
+// StartupReport summarizes what a host was actually started with, so that
+// deployments can check their configuration in tests and logs.
+type StartupReport struct {
+	ID peer.ID
+	// Listeners are the addresses the host's transports are bound to.
+	Listeners []ListenerReport
+	// Addrs are the addresses the host advertises.
+	Addrs []ma.Multiaddr
+	// SecurityProtocols and Muxers are the security protocols and stream
+	// muxers negotiated on connections, if they were passed in HostOpts.
+	SecurityProtocols []protocol.ID
+	Muxers            []protocol.ID
+	// Services are the names of the services the host runs, e.g. "identify",
+	// "ping", "holepunch" or "relay".
+	Services []string
+	// SystemLimits are the system wide limits of the resource manager. It's
+	// nil if the resource manager doesn't expose its limits.
+	SystemLimits *ResourceLimits
+}
+
+// StartupReport returns a summary of what the host was started with. Call it
+// after the host started listening, e.g. after libp2p.New returned.
+func (h *BasicHost) StartupReport() StartupReport {
+	r := StartupReport{
+		ID:                h.ID(),
+		Addrs:             h.Addrs(),
+		SecurityProtocols: h.securityProtocols,
+		Muxers:            h.muxers,
+	}
+
+	tfl, _ := h.Network().(interface {
+		TransportForListening(ma.Multiaddr) transport.Transport
+	})
+	for _, a := range h.Network().ListenAddresses() {
+		l := ListenerReport{Addr: a}
+		if tfl != nil {
+			if t := tfl.TransportForListening(a); t != nil {
+				l.Transport = transportName(t)
+			}
+		}
+		r.Listeners = append(r.Listeners, l)
+	}
+
+	if h.ids != nil {
+		r.Services = append(r.Services, "identify")
+	}
+	if h.pings != nil {
+		r.Services = append(r.Services, "ping")
+	}
+	if h.hps != nil {
+		r.Services = append(r.Services, "holepunch")
+	}
+	if h.relayManager != nil {
+		r.Services = append(r.Services, "relay")
+	}
+	if h.addressManager.natManager != nil {
+		r.Services = append(r.Services, "nat")
+	}
+	if h.GetAutoNat() != nil {
+		r.Services = append(r.Services, "autonat")
+	}
+	if h.autonatv2 != nil {
+		r.Services = append(r.Services, "autonatv2")
+	}
+
+	if rm := h.Network().ResourceManager(); rm != nil {
+		_ = rm.ViewSystem(func(s network.ResourceScope) error {
+			if lv, ok := s.(network.ResourceScopeLimitViewer); ok {
+				r.SystemLimits = resourceLimits(lv.ScopeLimit())
+			}
+			return nil
+		})
+	}
+	return r
+}
+
+func transportName(t transport.Transport) string {
+	if s, ok := t.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", t)
+}
+
+func resourceLimits(l network.ScopeLimit) *ResourceLimits {
+	return &ResourceLimits{
+		Streams:         l.GetStreamTotalLimit(),
+		StreamsInbound:  l.GetStreamLimit(network.DirInbound),
+		StreamsOutbound: l.GetStreamLimit(network.DirOutbound),
+		Conns:           l.GetConnTotalLimit(),
+		ConnsInbound:    l.GetConnLimit(network.DirInbound),
+		ConnsOutbound:   l.GetConnLimit(network.DirOutbound),
+		FD:              l.GetFDLimit(),
+		Memory:          l.GetMemoryLimit(),
+	}
+}
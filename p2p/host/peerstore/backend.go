@@ -0,0 +1,100 @@
+package peerstore
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	pstore "github.com/libp2p/go-libp2p/core/peerstore"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("peerstore")
+
+// ErrNotFound is returned by Backend.Get if there's no entry for a key, or if
+// the entry has expired.
+var ErrNotFound = errors.New("entry not found")
+
+// Entry is a value stored in a Backend.
+type Entry struct {
+	Key   string
+	Value []byte
+	// Expires is when the entry expires. Expired entries are never returned.
+	// It is zero for entries that don't expire.
+	Expires time.Time
+}
+
+// Expired returns whether the entry has expired at now.
+func (e Entry) Expired(now time.Time) bool {
+	return !e.Expires.IsZero() && !now.Before(e.Expires)
+}
+
+// Backend is a persistent storage backend for peerstore data. It allows a
+// host to warm-start from the peers it knew before it was restarted, see Save
+// and Load.
+//
+// Implementations must be safe for concurrent use.
+type Backend interface {
+	// Get returns the entry for key. It returns ErrNotFound if there is no
+	// entry for key or the entry has expired.
+	Get(key string) (Entry, error)
+	// Put stores e, replacing any existing entry with the same key.
+	Put(e Entry) error
+	// Delete removes the entry for key. Deleting a key that doesn't exist is
+	// not an error.
+	Delete(key string) error
+	// Iterate calls f for every entry whose key starts with prefix and that
+	// hasn't expired, in no particular order, until f returns false.
+	Iterate(prefix string, f func(Entry) bool) error
+	io.Closer
+}
+
+const backendPeersPrefix = "/peers/"
+
+// Save writes the peers with addresses in ps to b, in the format written by
+// Export, with one entry per peer. The entries expire after ttl.
+func Save(ps pstore.Peerstore, b Backend, ttl time.Duration) error {
+	cab, _ := pstore.GetCertifiedAddrBook(ps)
+	expires := time.Now().Add(ttl)
+	for _, p := range ps.PeersWithAddrs() {
+		sp, ok, err := exportPeer(ps, cab, p)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		val, err := json.Marshal(sp)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(Entry{Key: backendPeersPrefix + p.String(), Value: val, Expires: expires}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load adds the peers stored in b by Save to ps, like Import. Addresses are
+// added with the TTL remaining until their entry expires, or with
+// pstore.AddressTTL if the entry doesn't expire. Entries that can't be decoded
+// are skipped.
+func Load(ps pstore.Peerstore, b Backend) error {
+	cab, _ := pstore.GetCertifiedAddrBook(ps)
+	now := time.Now()
+	return b.Iterate(backendPeersPrefix, func(e Entry) bool {
+		var sp snapshotPeer
+		if err := json.Unmarshal(e.Value, &sp); err != nil {
+			log.Debugw("skipping invalid peerstore entry", "key", e.Key, "error", err)
+			return true
+		}
+		ttl := pstore.AddressTTL
+		if !e.Expires.IsZero() {
+			ttl = e.Expires.Sub(now)
+		}
+		importPeer(ps, cab, sp, ttl)
+		return true
+	})
+}
@@ -0,0 +1,85 @@
+package peerstore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	corepstore "github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/core/test"
+	pstore "github.com/libp2p/go-libp2p/p2p/host/peerstore"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstorefile"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveLoad(t *testing.T) {
+	_, pub, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+	require.NoError(t, err)
+	p, err := peer.IDFromPublicKey(pub)
+	require.NoError(t, err)
+	addrs := []ma.Multiaddr{
+		ma.StringCast("/ip4/1.2.3.4/tcp/1"),
+		ma.StringCast("/ip6/::1/udp/2/quic-v1"),
+	}
+	protos := []protocol.ID{"/foo/1.0.0", "/bar/1.0.0"}
+
+	ps, err := pstoremem.NewPeerstore()
+	require.NoError(t, err)
+	defer ps.Close()
+	ps.AddAddrs(p, addrs, time.Hour)
+	require.NoError(t, ps.AddPubKey(p, pub))
+	require.NoError(t, ps.AddProtocols(p, protos...))
+
+	dir := t.TempDir()
+	b, err := pstorefile.New(dir)
+	require.NoError(t, err)
+	require.NoError(t, pstore.Save(ps, b, time.Hour))
+	require.NoError(t, b.Close())
+
+	// warm-start a new peerstore
+	b, err = pstorefile.New(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	ps2, err := pstoremem.NewPeerstore()
+	require.NoError(t, err)
+	defer ps2.Close()
+	require.NoError(t, pstore.Load(ps2, b))
+
+	require.ElementsMatch(t, addrs, ps2.Addrs(p))
+	require.True(t, pub.Equals(ps2.PubKey(p)))
+	loaded, err := ps2.GetProtocols(p)
+	require.NoError(t, err)
+	require.ElementsMatch(t, protos, loaded)
+}
+
+func TestLoadWithoutExpiry(t *testing.T) {
+	p := test.RandPeerIDFatal(t)
+	addrs := []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/1")}
+	ps, err := pstoremem.NewPeerstore()
+	require.NoError(t, err)
+	defer ps.Close()
+	ps.AddAddrs(p, addrs, time.Hour)
+
+	b, err := pstorefile.New(t.TempDir())
+	require.NoError(t, err)
+	defer b.Close()
+	require.NoError(t, pstore.Save(ps, b, time.Hour))
+	e, err := b.Get("/peers/" + p.String())
+	require.NoError(t, err)
+	e.Expires = time.Time{}
+	require.NoError(t, b.Put(e))
+
+	ps2, err := pstoremem.NewPeerstore()
+	require.NoError(t, err)
+	defer ps2.Close()
+	require.NoError(t, pstore.Load(ps2, b))
+	require.ElementsMatch(t, addrs, ps2.Addrs(p))
+	// The addresses expire, instead of being added permanently.
+	ps2.UpdateAddrs(p, corepstore.AddressTTL, 0)
+	require.Empty(t, ps2.Addrs(p))
+}
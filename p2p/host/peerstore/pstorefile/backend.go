@@ -0,0 +1,234 @@
+// Package pstorefile implements a peerstore.Backend that stores every entry in
+// a file in a directory. It has no dependencies beyond the standard library,
+// and is meant for hosts that want to warm-start from a moderate number of
+// known peers.
+package pstorefile
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	pstore "github.com/libp2p/go-libp2p/p2p/host/peerstore"
+)
+
+// expiryLen is the length of the expiry header of an entry file.
+const expiryLen = 8
+
+// tmpPrefix is the prefix of the files entries are written to before they're
+// renamed to their final name. File names of entries never start with it.
+const tmpPrefix = ".tmp-"
+
+var errClosed = errors.New("backend closed")
+
+var nameEncoding = base32.HexEncoding.WithPadding(base32.NoPadding)
+
+// Backend is a peerstore.Backend storing entries as files in a directory.
+// Every entry is written and synced to a temporary file that then replaces the
+// entry's file, so that a crash never leaves a partially written entry behind.
+// Files are named after the hash of their key, which keeps the names short
+// regardless of the key length. The key is stored in the file.
+type Backend struct {
+	dir string
+
+	mx     sync.RWMutex
+	closed bool
+}
+
+var _ pstore.Backend = (*Backend)(nil)
+
+// New returns a Backend storing entries in dir. dir is created if it doesn't
+// exist.
+func New(dir string) (*Backend, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create peerstore directory: %w", err)
+	}
+	b := &Backend{dir: dir}
+	// clean up temporary files left behind by a crash
+	tmps, err := filepath.Glob(filepath.Join(dir, tmpPrefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range tmps {
+		os.Remove(f)
+	}
+	return b, nil
+}
+
+func (b *Backend) path(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return filepath.Join(b.dir, nameEncoding.EncodeToString(h[:]))
+}
+
+// Get returns the entry for key.
+func (b *Backend) Get(key string) (pstore.Entry, error) {
+	b.mx.RLock()
+	defer b.mx.RUnlock()
+	if b.closed {
+		return pstore.Entry{}, errClosed
+	}
+	e, err := b.read(b.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return pstore.Entry{}, pstore.ErrNotFound
+	}
+	if err != nil {
+		return pstore.Entry{}, err
+	}
+	if e.Key != key || e.Expired(time.Now()) {
+		return pstore.Entry{}, pstore.ErrNotFound
+	}
+	return e, nil
+}
+
+// Put stores e.
+func (b *Backend) Put(e pstore.Entry) error {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if b.closed {
+		return errClosed
+	}
+
+	buf := make([]byte, expiryLen, expiryLen+binary.MaxVarintLen64+len(e.Key)+len(e.Value))
+	if !e.Expires.IsZero() {
+		binary.BigEndian.PutUint64(buf, uint64(e.Expires.UnixNano()))
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(e.Key)))
+	buf = append(buf, e.Key...)
+	buf = append(buf, e.Value...)
+
+	f, err := os.CreateTemp(b.dir, tmpPrefix+"*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), b.path(e.Key)); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return b.syncDir()
+}
+
+// syncDir syncs the directory, which makes renames and removals of entry
+// files durable.
+func (b *Backend) syncDir() error {
+	d, err := os.Open(b.dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Delete removes the entry for key.
+func (b *Backend) Delete(key string) error {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	if b.closed {
+		return errClosed
+	}
+	if err := os.Remove(b.path(key)); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return b.syncDir()
+}
+
+// Iterate calls f for every unexpired entry whose key starts with prefix.
+// Expired entries are removed.
+func (b *Backend) Iterate(prefix string, f func(pstore.Entry) bool) error {
+	b.mx.RLock()
+	if b.closed {
+		b.mx.RUnlock()
+		return errClosed
+	}
+	files, err := os.ReadDir(b.dir)
+	if err != nil {
+		b.mx.RUnlock()
+		return err
+	}
+
+	now := time.Now()
+	var entries []pstore.Entry
+	var expired []string
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), tmpPrefix) {
+			continue
+		}
+		e, err := b.read(filepath.Join(b.dir, file.Name()))
+		if err != nil {
+			// the entry might have been deleted concurrently
+			continue
+		}
+		if !strings.HasPrefix(e.Key, prefix) {
+			continue
+		}
+		if e.Expired(now) {
+			expired = append(expired, e.Key)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	b.mx.RUnlock()
+
+	for _, key := range expired {
+		b.Delete(key)
+	}
+	// f is called without holding the lock, so that it can use the backend.
+	for _, e := range entries {
+		if !f(e) {
+			break
+		}
+	}
+	return nil
+}
+
+// Close closes the backend. All entries have already been written to disk.
+func (b *Backend) Close() error {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.closed = true
+	return nil
+}
+
+func (b *Backend) read(path string) (pstore.Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return pstore.Entry{}, err
+	}
+	if len(data) < expiryLen {
+		return pstore.Entry{}, fmt.Errorf("invalid entry file %s", path)
+	}
+	l, n := binary.Uvarint(data[expiryLen:])
+	if n <= 0 || uint64(len(data)-expiryLen-n) < l {
+		return pstore.Entry{}, fmt.Errorf("invalid entry file %s", path)
+	}
+	key := data[expiryLen+n : expiryLen+n+int(l)]
+	e := pstore.Entry{Key: string(key), Value: data[expiryLen+n+int(l):]}
+	if exp := binary.BigEndian.Uint64(data); exp != 0 {
+		e.Expires = time.Unix(0, int64(exp))
+	}
+	return e, nil
+}
@@ -0,0 +1,75 @@
+package pstorefile
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	pstore "github.com/libp2p/go-libp2p/p2p/host/peerstore"
+
+	"github.com/stretchr/testify/require"
+)
+
+func iterate(t *testing.T, b *Backend, prefix string) map[string]string {
+	t.Helper()
+	m := make(map[string]string)
+	require.NoError(t, b.Iterate(prefix, func(e pstore.Entry) bool {
+		m[e.Key] = string(e.Value)
+		return true
+	}))
+	return m
+}
+
+func TestBackend(t *testing.T) {
+	dir := t.TempDir()
+	b, err := New(dir)
+	require.NoError(t, err)
+
+	expires := time.Now().Add(time.Hour).Truncate(0)
+	require.NoError(t, b.Put(pstore.Entry{Key: "/a/1", Value: []byte("foo"), Expires: expires}))
+	require.NoError(t, b.Put(pstore.Entry{Key: "/a/2", Value: []byte("bar")}))
+	require.NoError(t, b.Put(pstore.Entry{Key: "/b/1", Value: []byte("baz")}))
+	require.NoError(t, b.Put(pstore.Entry{Key: "/a/expired", Value: []byte("old"), Expires: time.Now().Add(-time.Second)}))
+
+	e, err := b.Get("/a/1")
+	require.NoError(t, err)
+	require.Equal(t, []byte("foo"), e.Value)
+	require.True(t, expires.Equal(e.Expires))
+	e, err = b.Get("/a/2")
+	require.NoError(t, err)
+	require.True(t, e.Expires.IsZero())
+	_, err = b.Get("/a/expired")
+	require.ErrorIs(t, err, pstore.ErrNotFound)
+	_, err = b.Get("/missing")
+	require.ErrorIs(t, err, pstore.ErrNotFound)
+
+	require.Equal(t, map[string]string{"/a/1": "foo", "/a/2": "bar"}, iterate(t, b, "/a/"))
+
+	// entries are replaced
+	require.NoError(t, b.Put(pstore.Entry{Key: "/a/2", Value: []byte("qux")}))
+	require.NoError(t, b.Delete("/b/1"))
+	require.NoError(t, b.Delete("/b/1"))
+	require.NoError(t, b.Close())
+	_, err = b.Get("/a/1")
+	require.Error(t, err)
+
+	// entries survive reopening
+	b, err = New(dir)
+	require.NoError(t, err)
+	defer b.Close()
+	require.Equal(t, map[string]string{"/a/1": "foo", "/a/2": "qux"}, iterate(t, b, ""))
+}
+
+func TestLongKeys(t *testing.T) {
+	b, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer b.Close()
+
+	// Encoding the key in the file name would exceed NAME_MAX.
+	key := "/peers/" + strings.Repeat("a", 500)
+	require.NoError(t, b.Put(pstore.Entry{Key: key, Value: []byte("foo")}))
+	e, err := b.Get(key)
+	require.NoError(t, err)
+	require.Equal(t, key, e.Key)
+	require.Equal(t, map[string]string{key: "foo"}, iterate(t, b, "/peers/"))
+}
@@ -180,6 +180,7 @@ type memoryAddrBook struct {
 
 	subManager *AddrSubManager
 	clock      clock
+	events     *peerstoreEvents
 }
 
 var _ peerstore.AddrBook = (*memoryAddrBook)(nil)
@@ -257,6 +258,12 @@ func (mab *memoryAddrBook) Close() error {
 // gc garbage collects the in-memory address book.
 func (mab *memoryAddrBook) gc() {
 	now := mab.clock.Now()
+	expired := make(map[peer.ID][]ma.Multiaddr)
+	defer func() {
+		for p, addrs := range expired {
+			mab.events.addrsUpdated(p, nil, addrs)
+		}
+	}()
 	mab.mu.Lock()
 	defer mab.mu.Unlock()
 	for {
@@ -264,6 +271,7 @@ func (mab *memoryAddrBook) gc() {
 		if !ok {
 			return
 		}
+		expired[ea.Peer] = append(expired[ea.Peer], ea.Addr)
 		mab.maybeDeleteSignedPeerRecordUnlocked(ea.Peer)
 	}
 }
@@ -304,6 +312,8 @@ func (mab *memoryAddrBook) ConsumePeerRecord(recordEnvelope *record.Envelope, tt
 		return false, fmt.Errorf("signing key does not match PeerID in PeerRecord")
 	}
 
+	var added []ma.Multiaddr
+	defer func() { mab.events.addrsUpdated(rec.PeerID, added, nil) }()
 	mab.mu.Lock()
 	defer mab.mu.Unlock()
 
@@ -320,7 +330,7 @@ func (mab *memoryAddrBook) ConsumePeerRecord(recordEnvelope *record.Envelope, tt
 		Envelope: recordEnvelope,
 		Seq:      rec.Seq,
 	}
-	mab.addAddrsUnlocked(rec.PeerID, rec.Addrs, ttl)
+	added = mab.addAddrsUnlocked(rec.PeerID, rec.Addrs, ttl)
 	return true, nil
 }
 
@@ -332,22 +342,25 @@ func (mab *memoryAddrBook) maybeDeleteSignedPeerRecordUnlocked(p peer.ID) {
 
 func (mab *memoryAddrBook) addAddrs(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration) {
 	mab.mu.Lock()
-	defer mab.mu.Unlock()
+	added := mab.addAddrsUnlocked(p, addrs, ttl)
+	mab.mu.Unlock()
 
-	mab.addAddrsUnlocked(p, addrs, ttl)
+	mab.events.addrsUpdated(p, added, nil)
 }
 
-func (mab *memoryAddrBook) addAddrsUnlocked(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration) {
+// addAddrsUnlocked adds addrs and returns the addresses that weren't in the
+// address book before.
+func (mab *memoryAddrBook) addAddrsUnlocked(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration) (added []ma.Multiaddr) {
 	defer mab.maybeDeleteSignedPeerRecordUnlocked(p)
 
 	// if ttl is zero, exit. nothing to do.
 	if ttl <= 0 {
-		return
+		return nil
 	}
 
 	// we are over limit, drop these addrs.
 	if !ttlIsConnected(ttl) && mab.addrs.NumUnconnectedAddrs() >= mab.maxUnconnectedAddrs {
-		return
+		return nil
 	}
 
 	exp := mab.clock.Now().Add(ttl)
@@ -368,6 +381,7 @@ func (mab *memoryAddrBook) addAddrsUnlocked(p peer.ID, addrs []ma.Multiaddr, ttl
 			entry := &expiringAddr{Addr: addr, Expiry: exp, TTL: ttl, Peer: p}
 			mab.addrs.Insert(entry)
 			mab.subManager.BroadcastAddr(p, addr)
+			added = append(added, addr)
 		} else {
 			// update ttl & exp to whichever is greater between new and existing entry
 			var changed bool
@@ -384,6 +398,7 @@ func (mab *memoryAddrBook) addAddrsUnlocked(p peer.ID, addrs []ma.Multiaddr, ttl
 			}
 		}
 	}
+	return added
 }
 
 // SetAddr calls mgr.SetAddrs(p, addr, ttl)
@@ -394,6 +409,8 @@ func (mab *memoryAddrBook) SetAddr(p peer.ID, addr ma.Multiaddr, ttl time.Durati
 // SetAddrs sets the ttl on addresses. This clears any TTL there previously.
 // This is used when we receive the best estimate of the validity of an address.
 func (mab *memoryAddrBook) SetAddrs(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration) {
+	var added, removed []ma.Multiaddr
+	defer func() { mab.events.addrsUpdated(p, added, removed) }()
 	mab.mu.Lock()
 	defer mab.mu.Unlock()
 
@@ -415,6 +432,7 @@ func (mab *memoryAddrBook) SetAddrs(p peer.ID, addrs []ma.Multiaddr, ttl time.Du
 			if ttl > 0 {
 				if a.IsConnected() && !ttlIsConnected(ttl) && mab.addrs.NumUnconnectedAddrs() >= mab.maxUnconnectedAddrs {
 					mab.addrs.Delete(a)
+					removed = append(removed, a.Addr)
 				} else {
 					a.Addr = addr
 					a.Expiry = exp
@@ -424,6 +442,7 @@ func (mab *memoryAddrBook) SetAddrs(p peer.ID, addrs []ma.Multiaddr, ttl time.Du
 				}
 			} else {
 				mab.addrs.Delete(a)
+				removed = append(removed, a.Addr)
 			}
 		} else {
 			if ttl > 0 {
@@ -433,6 +452,7 @@ func (mab *memoryAddrBook) SetAddrs(p peer.ID, addrs []ma.Multiaddr, ttl time.Du
 				entry := &expiringAddr{Addr: addr, Expiry: exp, TTL: ttl, Peer: p}
 				mab.addrs.Insert(entry)
 				mab.subManager.BroadcastAddr(p, addr)
+				added = append(added, addr)
 			}
 		}
 	}
@@ -441,6 +461,8 @@ func (mab *memoryAddrBook) SetAddrs(p peer.ID, addrs []ma.Multiaddr, ttl time.Du
 // UpdateAddrs updates the addresses associated with the given peer that have
 // the given oldTTL to have the given newTTL.
 func (mab *memoryAddrBook) UpdateAddrs(p peer.ID, oldTTL time.Duration, newTTL time.Duration) {
+	var removed []ma.Multiaddr
+	defer func() { mab.events.addrsUpdated(p, nil, removed) }()
 	mab.mu.Lock()
 	defer mab.mu.Unlock()
 
@@ -451,10 +473,12 @@ func (mab *memoryAddrBook) UpdateAddrs(p peer.ID, oldTTL time.Duration, newTTL t
 		if oldTTL == a.TTL {
 			if newTTL == 0 {
 				mab.addrs.Delete(a)
+				removed = append(removed, a.Addr)
 			} else {
 				// We are over limit, drop these addresses.
 				if ttlIsConnected(oldTTL) && !ttlIsConnected(newTTL) && mab.addrs.NumUnconnectedAddrs() >= mab.maxUnconnectedAddrs {
 					mab.addrs.Delete(a)
+					removed = append(removed, a.Addr)
 				} else {
 					a.TTL = newTTL
 					a.Expiry = exp
@@ -512,12 +536,15 @@ func (mab *memoryAddrBook) GetPeerRecord(p peer.ID) *record.Envelope {
 
 // ClearAddrs removes all previously stored addresses
 func (mab *memoryAddrBook) ClearAddrs(p peer.ID) {
+	var removed []ma.Multiaddr
+	defer func() { mab.events.addrsUpdated(p, nil, removed) }()
 	mab.mu.Lock()
 	defer mab.mu.Unlock()
 
 	delete(mab.signedPeerRecords, p)
 	for _, a := range mab.addrs.Addrs[p] {
 		mab.addrs.Delete(a)
+		removed = append(removed, a.Addr)
 	}
 }
 
@@ -0,0 +1,92 @@
+package pstoremem
+
+import (
+	"errors"
+	"sync/atomic"
+
+	ic "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+type peerstoreEmitters struct {
+	addrs  event.Emitter
+	protos event.Emitter
+	keys   event.Emitter
+}
+
+func (e *peerstoreEmitters) Close() error {
+	return errors.Join(e.addrs.Close(), e.protos.Close(), e.keys.Close())
+}
+
+// peerstoreEvents emits peerstore mutations on an event bus. A nil
+// *peerstoreEvents, or one without a bus, doesn't emit anything.
+//
+// Events must be emitted without holding the locks of the books, since
+// subscribers may call back into the peerstore.
+type peerstoreEvents struct {
+	emitters atomic.Pointer[peerstoreEmitters]
+}
+
+func (pe *peerstoreEvents) setBus(b event.Bus) error {
+	addrs, err := b.Emitter(new(event.EvtPeerstoreAddrsUpdated))
+	if err != nil {
+		return err
+	}
+	protos, err := b.Emitter(new(event.EvtPeerstoreProtocolsUpdated))
+	if err != nil {
+		addrs.Close()
+		return err
+	}
+	keys, err := b.Emitter(new(event.EvtPeerstoreKeyAdded))
+	if err != nil {
+		addrs.Close()
+		protos.Close()
+		return err
+	}
+	if old := pe.emitters.Swap(&peerstoreEmitters{addrs: addrs, protos: protos, keys: keys}); old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+func (pe *peerstoreEvents) get() *peerstoreEmitters {
+	if pe == nil {
+		return nil
+	}
+	return pe.emitters.Load()
+}
+
+func (pe *peerstoreEvents) addrsUpdated(p peer.ID, added, removed []ma.Multiaddr) {
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+	if em := pe.get(); em != nil {
+		em.addrs.Emit(event.EvtPeerstoreAddrsUpdated{Peer: p, Added: added, Removed: removed})
+	}
+}
+
+func (pe *peerstoreEvents) protocolsUpdated(p peer.ID, added, removed []protocol.ID) {
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+	if em := pe.get(); em != nil {
+		em.protos.Emit(event.EvtPeerstoreProtocolsUpdated{Peer: p, Added: added, Removed: removed})
+	}
+}
+
+func (pe *peerstoreEvents) keyAdded(p peer.ID, pk ic.PubKey) {
+	if em := pe.get(); em != nil {
+		em.keys.Emit(event.EvtPeerstoreKeyAdded{Peer: p, PubKey: pk})
+	}
+}
+
+func (pe *peerstoreEvents) Close() error {
+	if em := pe.emitters.Swap(nil); em != nil {
+		return em.Close()
+	}
+	return nil
+}
@@ -13,6 +13,7 @@ type memoryKeyBook struct {
 	sync.RWMutex // same lock. wont happen a ton.
 	pks          map[peer.ID]ic.PubKey
 	sks          map[peer.ID]ic.PrivKey
+	events       *peerstoreEvents
 }
 
 var _ pstore.KeyBook = (*memoryKeyBook)(nil)
@@ -62,8 +63,13 @@ func (mkb *memoryKeyBook) AddPubKey(p peer.ID, pk ic.PubKey) error {
 	}
 
 	mkb.Lock()
+	_, found := mkb.pks[p]
 	mkb.pks[p] = pk
 	mkb.Unlock()
+
+	if !found {
+		mkb.events.keyAdded(p, pk)
+	}
 	return nil
 }
 
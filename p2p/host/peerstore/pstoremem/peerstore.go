@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
 	pstore "github.com/libp2p/go-libp2p/p2p/host/peerstore"
@@ -16,6 +17,8 @@ type pstoremem struct {
 	*memoryAddrBook
 	*memoryProtoBook
 	*memoryPeerMetadata
+
+	events *peerstoreEvents
 }
 
 var _ peerstore.Peerstore = &pstoremem{}
@@ -46,15 +49,31 @@ func NewPeerstore(opts ...Option) (ps *pstoremem, err error) {
 		return nil, err
 	}
 
+	events := &peerstoreEvents{}
+	kb := NewKeyBook()
+	kb.events = events
+	ab.events = events
+	pb.events = events
+
 	return &pstoremem{
 		Metrics:            pstore.NewMetrics(),
-		memoryKeyBook:      NewKeyBook(),
+		memoryKeyBook:      kb,
 		memoryAddrBook:     ab,
 		memoryProtoBook:    pb,
 		memoryPeerMetadata: NewPeerMetadata(),
+		events:             events,
 	}, nil
 }
 
+// SetEventBus makes the peerstore emit event.EvtPeerstoreAddrsUpdated,
+// event.EvtPeerstoreProtocolsUpdated and event.EvtPeerstoreKeyAdded on b
+// whenever it's mutated. It replaces any previously set bus.
+//
+// libp2p.New sets the host's event bus on the peerstore.
+func (ps *pstoremem) SetEventBus(b event.Bus) error {
+	return ps.events.setBus(b)
+}
+
 func (ps *pstoremem) Close() (err error) {
 	var errs []error
 	weakClose := func(name string, c interface{}) {
@@ -68,6 +87,7 @@ func (ps *pstoremem) Close() (err error) {
 	weakClose("addressbook", ps.memoryAddrBook)
 	weakClose("protobook", ps.memoryProtoBook)
 	weakClose("peermetadata", ps.memoryPeerMetadata)
+	weakClose("events", ps.events)
 
 	if len(errs) > 0 {
 		return fmt.Errorf("failed while closing peerstore; err(s): %q", errs)
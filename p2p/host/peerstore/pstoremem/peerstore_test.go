@@ -2,8 +2,16 @@ package pstoremem
 
 import (
 	"testing"
+	"time"
 
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+
+	mockClock "github.com/benbjohnson/clock"
 	ma "github.com/multiformats/go-multiaddr"
 	"github.com/stretchr/testify/require"
 )
@@ -21,3 +29,65 @@ func TestPeerStoreAddrBookOpts(t *testing.T) {
 	res = ps.Addrs("p2")
 	require.Empty(t, res)
 }
+
+func TestPeerstoreEvents(t *testing.T) {
+	clk := mockClock.NewMock()
+	ps, err := NewPeerstore(WithClock(clk))
+	require.NoError(t, err)
+	defer ps.Close()
+
+	bus := eventbus.NewBus()
+	require.NoError(t, ps.SetEventBus(bus))
+	sub, err := bus.Subscribe([]any{
+		new(event.EvtPeerstoreAddrsUpdated),
+		new(event.EvtPeerstoreProtocolsUpdated),
+		new(event.EvtPeerstoreKeyAdded),
+	})
+	require.NoError(t, err)
+	defer sub.Close()
+	next := func() any {
+		t.Helper()
+		select {
+		case e := <-sub.Out():
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for event")
+			return nil
+		}
+	}
+
+	_, pub, err := crypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	p, err := peer.IDFromPublicKey(pub)
+	require.NoError(t, err)
+	a1 := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+	a2 := ma.StringCast("/ip4/1.2.3.4/tcp/2")
+
+	ps.AddAddrs(p, []ma.Multiaddr{a1, a2}, time.Hour)
+	require.Equal(t, event.EvtPeerstoreAddrsUpdated{Peer: p, Added: []ma.Multiaddr{a1, a2}}, next())
+	// adding known addresses doesn't emit anything
+	ps.AddAddrs(p, []ma.Multiaddr{a1}, 2*time.Hour)
+	ps.SetAddr(p, a2, time.Minute)
+
+	require.NoError(t, ps.AddProtocols(p, "/foo", "/bar"))
+	e := next().(event.EvtPeerstoreProtocolsUpdated)
+	require.Equal(t, p, e.Peer)
+	require.ElementsMatch(t, []protocol.ID{"/foo", "/bar"}, e.Added)
+	require.NoError(t, ps.SetProtocols(p, "/foo", "/baz"))
+	require.Equal(t, event.EvtPeerstoreProtocolsUpdated{Peer: p, Added: []protocol.ID{"/baz"}, Removed: []protocol.ID{"/bar"}}, next())
+
+	require.NoError(t, ps.AddPubKey(p, pub))
+	require.Equal(t, event.EvtPeerstoreKeyAdded{Peer: p, PubKey: pub}, next())
+
+	clk.Add(time.Hour)
+	ps.gc()
+	require.Equal(t, event.EvtPeerstoreAddrsUpdated{Peer: p, Removed: []ma.Multiaddr{a2}}, next())
+	ps.ClearAddrs(p)
+	require.Equal(t, event.EvtPeerstoreAddrsUpdated{Peer: p, Removed: []ma.Multiaddr{a1}}, next())
+
+	select {
+	case e := <-sub.Out():
+		t.Fatalf("unexpected event: %v", e)
+	default:
+	}
+}
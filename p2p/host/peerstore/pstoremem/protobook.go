@@ -26,6 +26,7 @@ type memoryProtoBook struct {
 	segments protoSegments
 
 	maxProtos int
+	events    *peerstoreEvents
 }
 
 var _ pstore.ProtoBook = (*memoryProtoBook)(nil)
@@ -72,13 +73,28 @@ func (pb *memoryProtoBook) SetProtocols(p peer.ID, protos ...protocol.ID) error
 
 	s := pb.segments.get(p)
 	s.Lock()
+	old := s.protocols[p]
 	s.protocols[p] = newprotos
 	s.Unlock()
 
+	var added, removed []protocol.ID
+	for proto := range newprotos {
+		if _, ok := old[proto]; !ok {
+			added = append(added, proto)
+		}
+	}
+	for proto := range old {
+		if _, ok := newprotos[proto]; !ok {
+			removed = append(removed, proto)
+		}
+	}
+	pb.events.protocolsUpdated(p, added, removed)
 	return nil
 }
 
 func (pb *memoryProtoBook) AddProtocols(p peer.ID, protos ...protocol.ID) error {
+	var added []protocol.ID
+	defer func() { pb.events.protocolsUpdated(p, added, nil) }()
 	s := pb.segments.get(p)
 	s.Lock()
 	defer s.Unlock()
@@ -93,7 +109,10 @@ func (pb *memoryProtoBook) AddProtocols(p peer.ID, protos ...protocol.ID) error
 	}
 
 	for _, proto := range protos {
-		protomap[proto] = struct{}{}
+		if _, ok := protomap[proto]; !ok {
+			protomap[proto] = struct{}{}
+			added = append(added, proto)
+		}
 	}
 	return nil
 }
@@ -112,6 +131,8 @@ func (pb *memoryProtoBook) GetProtocols(p peer.ID) ([]protocol.ID, error) {
 }
 
 func (pb *memoryProtoBook) RemoveProtocols(p peer.ID, protos ...protocol.ID) error {
+	var removed []protocol.ID
+	defer func() { pb.events.protocolsUpdated(p, nil, removed) }()
 	s := pb.segments.get(p)
 	s.Lock()
 	defer s.Unlock()
@@ -123,7 +144,10 @@ func (pb *memoryProtoBook) RemoveProtocols(p peer.ID, protos ...protocol.ID) err
 	}
 
 	for _, proto := range protos {
-		delete(protomap, proto)
+		if _, ok := protomap[proto]; ok {
+			delete(protomap, proto)
+			removed = append(removed, proto)
+		}
 	}
 	if len(protomap) == 0 {
 		delete(s.protocols, p)
@@ -162,6 +186,15 @@ func (pb *memoryProtoBook) FirstSupportedProtocol(p peer.ID, protos ...protocol.
 func (pb *memoryProtoBook) RemovePeer(p peer.ID) {
 	s := pb.segments.get(p)
 	s.Lock()
+	old := s.protocols[p]
 	delete(s.protocols, p)
 	s.Unlock()
+
+	if len(old) > 0 {
+		removed := make([]protocol.ID, 0, len(old))
+		for proto := range old {
+			removed = append(removed, proto)
+		}
+		pb.events.protocolsUpdated(p, nil, removed)
+	}
 }
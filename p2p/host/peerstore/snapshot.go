@@ -0,0 +1,166 @@
+package peerstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	ic "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	pstore "github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/core/record"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// SnapshotVersion is the version of the snapshot format written by Export.
+const SnapshotVersion = 1
+
+type snapshot struct {
+	Version int            `json:"version"`
+	Peers   []snapshotPeer `json:"peers"`
+}
+
+type snapshotPeer struct {
+	ID        string   `json:"id"`
+	Addrs     []string `json:"addrs,omitempty"`
+	PubKey    []byte   `json:"pubkey,omitempty"`
+	Protocols []string `json:"protocols,omitempty"`
+	// SignedPeerRecord is the marshaled envelope of the peer's signed peer
+	// record, if the peerstore has one.
+	SignedPeerRecord []byte `json:"signedPeerRecord,omitempty"`
+}
+
+// Export writes a snapshot of the addresses, public keys, protocols and signed
+// peer records of the peers with addresses in ps to w. The snapshot can be
+// imported into another peerstore with Import, e.g. to seed a new node with
+// the peers known to a healthy one.
+func Export(ps pstore.Peerstore, w io.Writer) error {
+	cab, _ := pstore.GetCertifiedAddrBook(ps)
+	s := snapshot{Version: SnapshotVersion}
+	for _, p := range ps.PeersWithAddrs() {
+		sp, ok, err := exportPeer(ps, cab, p)
+		if err != nil {
+			return err
+		}
+		if ok {
+			s.Peers = append(s.Peers, sp)
+		}
+	}
+	return json.NewEncoder(w).Encode(s)
+}
+
+// Import adds the peers of a snapshot written by Export to ps. Addresses are
+// added with pstore.AddressTTL. Signed peer records are verified before
+// they're added; invalid records and entries that can't be decoded are
+// skipped. If ps has a signed peer record for a peer, the unsigned addresses
+// of the peer in the snapshot are skipped.
+func Import(ps pstore.Peerstore, r io.Reader) error {
+	var s snapshot
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return fmt.Errorf("failed to decode peerstore snapshot: %w", err)
+	}
+	if s.Version != SnapshotVersion {
+		return fmt.Errorf("unsupported peerstore snapshot version %d", s.Version)
+	}
+
+	cab, _ := pstore.GetCertifiedAddrBook(ps)
+	for _, sp := range s.Peers {
+		importPeer(ps, cab, sp, pstore.AddressTTL)
+	}
+	return nil
+}
+
+// exportPeer returns the snapshot of p. It returns false if p has no
+// addresses.
+func exportPeer(ps pstore.Peerstore, cab pstore.CertifiedAddrBook, p peer.ID) (snapshotPeer, bool, error) {
+	addrs := ps.Addrs(p)
+	if len(addrs) == 0 {
+		return snapshotPeer{}, false, nil
+	}
+	sp := snapshotPeer{ID: p.String()}
+	for _, a := range addrs {
+		sp.Addrs = append(sp.Addrs, a.String())
+	}
+	if pk := ps.PubKey(p); pk != nil {
+		b, err := ic.MarshalPublicKey(pk)
+		if err != nil {
+			return snapshotPeer{}, false, fmt.Errorf("failed to marshal public key of %s: %w", p, err)
+		}
+		sp.PubKey = b
+	}
+	if protos, err := ps.GetProtocols(p); err == nil {
+		sp.Protocols = protocol.ConvertToStrings(protos)
+	}
+	if cab != nil {
+		if env := cab.GetPeerRecord(p); env != nil {
+			b, err := env.Marshal()
+			if err != nil {
+				return snapshotPeer{}, false, fmt.Errorf("failed to marshal signed peer record of %s: %w", p, err)
+			}
+			sp.SignedPeerRecord = b
+		}
+	}
+	return sp, true, nil
+}
+
+// importPeer adds the snapshot of a peer to ps, with addresses expiring after
+// ttl.
+func importPeer(ps pstore.Peerstore, cab pstore.CertifiedAddrBook, sp snapshotPeer, ttl time.Duration) {
+	p, err := peer.Decode(sp.ID)
+	if err != nil {
+		log.Debugw("skipping snapshot entry with invalid peer ID", "id", sp.ID, "error", err)
+		return
+	}
+
+	if len(sp.PubKey) > 0 {
+		pk, err := ic.UnmarshalPublicKey(sp.PubKey)
+		if err != nil {
+			log.Debugw("skipping invalid public key in snapshot", "peer", p, "error", err)
+		} else if err := ps.AddPubKey(p, pk); err != nil {
+			log.Debugw("failed to add public key", "peer", p, "error", err)
+		}
+	}
+
+	if cab != nil && len(sp.SignedPeerRecord) > 0 {
+		importSignedPeerRecord(cab, p, sp.SignedPeerRecord, ttl)
+	}
+	if cab != nil && cab.GetPeerRecord(p) != nil {
+		sp.Addrs = nil
+	}
+
+	addrs := make([]ma.Multiaddr, 0, len(sp.Addrs))
+	for _, s := range sp.Addrs {
+		a, err := ma.NewMultiaddr(s)
+		if err != nil {
+			log.Debugw("skipping invalid address in snapshot", "peer", p, "addr", s, "error", err)
+			continue
+		}
+		addrs = append(addrs, a)
+	}
+	ps.AddAddrs(p, addrs, ttl)
+
+	if len(sp.Protocols) > 0 {
+		if err := ps.AddProtocols(p, protocol.ConvertFromStrings(sp.Protocols)...); err != nil {
+			log.Debugw("failed to add protocols", "peer", p, "error", err)
+		}
+	}
+}
+
+func importSignedPeerRecord(cab pstore.CertifiedAddrBook, p peer.ID, b []byte, ttl time.Duration) {
+	env, rec, err := record.ConsumeEnvelope(b, peer.PeerRecordEnvelopeDomain)
+	if err != nil {
+		log.Debugw("skipping invalid signed peer record in snapshot", "peer", p, "error", err)
+		return
+	}
+	pr, ok := rec.(*peer.PeerRecord)
+	if !ok || pr.PeerID != p {
+		log.Debugw("skipping signed peer record of another peer in snapshot", "peer", p)
+		return
+	}
+	if _, err := cab.ConsumePeerRecord(env, ttl); err != nil {
+		log.Debugw("failed to add signed peer record", "peer", p, "error", err)
+	}
+}
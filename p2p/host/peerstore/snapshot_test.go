@@ -0,0 +1,85 @@
+package peerstore_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/core/record"
+	"github.com/libp2p/go-libp2p/core/test"
+	pstore "github.com/libp2p/go-libp2p/p2p/host/peerstore"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImport(t *testing.T) {
+	priv, pub, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+	require.NoError(t, err)
+	p, err := peer.IDFromPublicKey(pub)
+	require.NoError(t, err)
+	certified := []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/1")}
+	addrs := []ma.Multiaddr{ma.StringCast("/ip6/::1/udp/2/quic-v1")}
+	protos := []protocol.ID{"/foo/1.0.0", "/bar/1.0.0"}
+
+	rec := peer.NewPeerRecord()
+	rec.PeerID = p
+	rec.Addrs = certified
+	env, err := record.Seal(rec, priv)
+	require.NoError(t, err)
+
+	ps, err := pstoremem.NewPeerstore()
+	require.NoError(t, err)
+	defer ps.Close()
+	ps.AddAddrs(p, addrs, time.Hour)
+	_, err = ps.ConsumePeerRecord(env, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, ps.AddPubKey(p, pub))
+	require.NoError(t, ps.AddProtocols(p, protos...))
+
+	var buf bytes.Buffer
+	require.NoError(t, pstore.Export(ps, &buf))
+
+	ps2, err := pstoremem.NewPeerstore()
+	require.NoError(t, err)
+	defer ps2.Close()
+	require.NoError(t, pstore.Import(ps2, &buf))
+
+	// The unsigned addresses are skipped, since there's a signed peer record.
+	require.ElementsMatch(t, certified, ps2.Addrs(p))
+	require.True(t, pub.Equals(ps2.PubKey(p)))
+	loaded, err := ps2.GetProtocols(p)
+	require.NoError(t, err)
+	require.ElementsMatch(t, protos, loaded)
+	imported := ps2.GetPeerRecord(p)
+	require.NotNil(t, imported)
+	require.True(t, env.Equal(imported))
+}
+
+func TestImportWithoutSignedPeerRecord(t *testing.T) {
+	p := test.RandPeerIDFatal(t)
+	addrs := []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/1")}
+	ps, err := pstoremem.NewPeerstore()
+	require.NoError(t, err)
+	defer ps.Close()
+	ps.AddAddrs(p, addrs, time.Hour)
+
+	var buf bytes.Buffer
+	require.NoError(t, pstore.Export(ps, &buf))
+	ps2, err := pstoremem.NewPeerstore()
+	require.NoError(t, err)
+	defer ps2.Close()
+	require.NoError(t, pstore.Import(ps2, &buf))
+	require.ElementsMatch(t, addrs, ps2.Addrs(p))
+}
+
+func TestImportUnsupportedVersion(t *testing.T) {
+	ps, err := pstoremem.NewPeerstore()
+	require.NoError(t, err)
+	defer ps.Close()
+	require.Error(t, pstore.Import(ps, bytes.NewBufferString(`{"version":2,"peers":[]}`)))
+}
@@ -0,0 +1,204 @@
+// Package probecoord schedules the periodic background probes of a host.
+//
+// AutoNAT probes, relay reservation refreshes and keepalive pings each run on
+// their own timer. Independent timers tend to synchronize into bursts, and on
+// mobile devices every one of them wakes up the radio. The timers of a
+// Coordinator are jittered, and a Coordinator spaces out the probes of all of
+// its timers by a minimum gap.
+package probecoord
+
+import (
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+)
+
+const (
+	defaultJitter = 0.1
+	defaultMinGap = time.Second
+)
+
+// Coordinator creates timers that fire in a coordinated way. It is safe for
+// concurrent use, and meant to be shared by all the services of a host. A nil
+// Coordinator creates plain timers, without jitter and spacing.
+type Coordinator struct {
+	clock  clock.Clock
+	jitter float64
+	minGap time.Duration
+
+	mx sync.Mutex
+	// nextSlot is the earliest time the next timer may fire.
+	nextSlot time.Time
+}
+
+// Option is an option for New.
+type Option func(*Coordinator) error
+
+// WithJitter randomly extends every timer by up to a fraction f of its
+// duration, with f in [0, 1]. The default is 0.1.
+func WithJitter(f float64) Option {
+	return func(c *Coordinator) error {
+		if f < 0 || f > 1 {
+			return errors.New("jitter must be in [0, 1]")
+		}
+		c.jitter = f
+		return nil
+	}
+}
+
+// WithMinGap sets the minimum time between the firing of any two timers. The
+// default is one second.
+func WithMinGap(d time.Duration) Option {
+	return func(c *Coordinator) error {
+		if d < 0 {
+			return errors.New("minimum gap must not be negative")
+		}
+		c.minGap = d
+		return nil
+	}
+}
+
+// WithClock sets the clock of the coordinator, for testing.
+func WithClock(cl clock.Clock) Option {
+	return func(c *Coordinator) error {
+		c.clock = cl
+		return nil
+	}
+}
+
+// New creates a new Coordinator.
+func New(opts ...Option) (*Coordinator, error) {
+	c := &Coordinator{
+		clock:  clock.New(),
+		jitter: defaultJitter,
+		minGap: defaultMinGap,
+	}
+	for _, o := range opts {
+		if err := o(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// reserve returns the time at which a timer that is due now may fire.
+func (c *Coordinator) reserve(now time.Time) time.Time {
+	if c == nil {
+		return now
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	slot := now
+	if c.nextSlot.After(slot) {
+		slot = c.nextSlot
+	}
+	c.nextSlot = slot.Add(c.minGap)
+	return slot
+}
+
+func (c *Coordinator) jittered(d time.Duration) time.Duration {
+	if c == nil {
+		return d
+	}
+	if j := int64(float64(d) * c.jitter); j > 0 {
+		d += time.Duration(rand.Int64N(j + 1))
+	}
+	return d
+}
+
+// Timer is a timer created by a Coordinator. Like a time.Timer, it sends the
+// current time on C when it fires. It fires after at least the duration it
+// was set to, plus jitter, and later if other timers of the coordinator fired
+// less than the minimum gap before.
+type Timer struct {
+	C <-chan time.Time
+
+	c     *Coordinator
+	clock clock.Clock
+	ch    chan time.Time
+
+	mx      sync.Mutex
+	t       *clock.Timer
+	gen     int
+	pending bool
+}
+
+// NewTimer creates a timer that fires once after d.
+func (c *Coordinator) NewTimer(d time.Duration) *Timer {
+	ch := make(chan time.Time, 1)
+	t := &Timer{C: ch, c: c, clock: clock.New(), ch: ch}
+	if c != nil {
+		t.clock = c.clock
+	}
+	t.Reset(d)
+	return t
+}
+
+// Reset changes the timer to fire once after d. It returns true if the timer
+// had been pending, and false if it had fired or been stopped.
+func (t *Timer) Reset(d time.Duration) bool {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	wasPending := t.stopLocked()
+	t.pending = true
+	gen := t.gen
+	t.t = t.clock.AfterFunc(t.c.jittered(d), func() { t.due(gen) })
+	return wasPending
+}
+
+// Stop prevents the timer from firing. It returns true if the timer had been
+// pending, and false if it had fired or been stopped. As with a time.Timer,
+// if Stop returns false after the timer fired, the time is available on C
+// unless it was already received.
+func (t *Timer) Stop() bool {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.stopLocked()
+}
+
+func (t *Timer) stopLocked() bool {
+	t.gen++
+	if t.t != nil {
+		t.t.Stop()
+	}
+	wasPending := t.pending
+	t.pending = false
+	return wasPending
+}
+
+// due is called when the duration of the timer elapsed. It waits for the
+// coordinator to grant the timer a slot.
+func (t *Timer) due(gen int) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if gen != t.gen {
+		return
+	}
+	now := t.clock.Now()
+	slot := t.c.reserve(now)
+	if slot.After(now) {
+		t.t = t.clock.AfterFunc(slot.Sub(now), func() { t.fire(gen) })
+		return
+	}
+	t.fireLocked()
+}
+
+func (t *Timer) fire(gen int) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if gen != t.gen {
+		return
+	}
+	t.fireLocked()
+}
+
+func (t *Timer) fireLocked() {
+	t.pending = false
+	select {
+	case t.ch <- t.clock.Now():
+	default:
+	}
+}
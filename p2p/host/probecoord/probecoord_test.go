@@ -0,0 +1,92 @@
+package probecoord
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+func fired(timers ...*Timer) int {
+	var n int
+	for _, t := range timers {
+		select {
+		case <-t.C:
+			n++
+		default:
+		}
+	}
+	return n
+}
+
+func TestMinGap(t *testing.T) {
+	cl := clock.NewMock()
+	c, err := New(WithClock(cl), WithJitter(0), WithMinGap(time.Second))
+	require.NoError(t, err)
+
+	timers := []*Timer{c.NewTimer(10 * time.Second), c.NewTimer(10 * time.Second), c.NewTimer(10 * time.Second)}
+	cl.Add(9 * time.Second)
+	time.Sleep(20 * time.Millisecond)
+	require.Zero(t, fired(timers...))
+
+	// all timers are due, but only one may fire every second
+	var n int
+	for i := 0; i < 3; i++ {
+		cl.Add(time.Second)
+		require.Eventually(t, func() bool { n += fired(timers...); return n == i+1 }, time.Second, 5*time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+		n += fired(timers...)
+		require.Equal(t, i+1, n)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	c, err := New(WithJitter(0.5))
+	require.NoError(t, err)
+	var jittered bool
+	for i := 0; i < 100; i++ {
+		d := c.jittered(10 * time.Second)
+		require.GreaterOrEqual(t, d, 10*time.Second)
+		require.LessOrEqual(t, d, 15*time.Second)
+		jittered = jittered || d != 10*time.Second
+	}
+	require.True(t, jittered)
+
+	_, err = New(WithJitter(1.5))
+	require.Error(t, err)
+	_, err = New(WithMinGap(-time.Second))
+	require.Error(t, err)
+}
+
+func TestTimerStopReset(t *testing.T) {
+	cl := clock.NewMock()
+	c, err := New(WithClock(cl), WithJitter(0), WithMinGap(0))
+	require.NoError(t, err)
+
+	tm := c.NewTimer(time.Second)
+	require.True(t, tm.Stop())
+	require.False(t, tm.Stop())
+	cl.Add(time.Second)
+	time.Sleep(20 * time.Millisecond)
+	require.Zero(t, fired(tm))
+
+	require.False(t, tm.Reset(time.Second))
+	require.True(t, tm.Reset(2*time.Second))
+	cl.Add(time.Second)
+	time.Sleep(20 * time.Millisecond)
+	require.Zero(t, fired(tm))
+	cl.Add(time.Second)
+	require.Eventually(t, func() bool { return fired(tm) == 1 }, time.Second, 5*time.Millisecond)
+	require.False(t, tm.Stop())
+}
+
+func TestNilCoordinator(t *testing.T) {
+	var c *Coordinator
+	tm := c.NewTimer(10 * time.Millisecond)
+	select {
+	case <-tm.C:
+	case <-time.After(time.Second):
+		t.Fatal("timer didn't fire")
+	}
+}
@@ -0,0 +1,78 @@
+package pstoremanager
+
+import (
+	"github.com/libp2p/go-libp2p/p2p/metricshelper"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricNamespace = "libp2p_peerstore"
+
+var (
+	peersCount = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Name:      "peers",
+			Help:      "Number of peers in the peerstore",
+		},
+	)
+	addrsCount = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Name:      "addrs",
+			Help:      "Number of addresses in the peerstore",
+		},
+	)
+	protocolsCount = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Name:      "protocols",
+			Help:      "Number of protocol entries in the peerstore",
+		},
+	)
+	collectors = []prometheus.Collector{
+		peersCount,
+		addrsCount,
+		protocolsCount,
+	}
+)
+
+// MetricsTracer tracks the size of the peerstore
+type MetricsTracer interface {
+	// PeerstoreSize updates the number of peers, addresses and protocol
+	// entries in the peerstore
+	PeerstoreSize(peers, addrs, protocols int)
+}
+
+type metricsTracer struct{}
+
+var _ MetricsTracer = &metricsTracer{}
+
+type metricsTracerSetting struct {
+	reg prometheus.Registerer
+}
+
+type MetricsTracerOption func(*metricsTracerSetting)
+
+func WithRegisterer(reg prometheus.Registerer) MetricsTracerOption {
+	return func(s *metricsTracerSetting) {
+		if reg != nil {
+			s.reg = reg
+		}
+	}
+}
+
+func NewMetricsTracer(opts ...MetricsTracerOption) MetricsTracer {
+	setting := &metricsTracerSetting{reg: prometheus.DefaultRegisterer}
+	for _, opt := range opts {
+		opt(setting)
+	}
+	metricshelper.RegisterCollectors(setting.reg, collectors...)
+	return &metricsTracer{}
+}
+
+func (t *metricsTracer) PeerstoreSize(peers, addrs, protocols int) {
+	peersCount.Set(float64(peers))
+	addrsCount.Set(float64(addrs))
+	protocolsCount.Set(float64(protocols))
+}
@@ -0,0 +1,21 @@
+//go:build nocover
+
+package pstoremanager
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestMetricsNoAllocNoCover(t *testing.T) {
+	tr := NewMetricsTracer()
+	tests := map[string]func(){
+		"PeerstoreSize": func() { tr.PeerstoreSize(rand.Intn(1000), rand.Intn(10000), rand.Intn(10000)) },
+	}
+	for method, f := range tests {
+		allocs := testing.AllocsPerRun(1000, f)
+		if allocs > 0 {
+			t.Fatalf("Alloc Test: %s, got: %0.2f, expected: 0 allocs", method, allocs)
+		}
+	}
+}
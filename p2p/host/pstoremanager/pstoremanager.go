@@ -38,6 +38,15 @@ func WithCleanupInterval(t time.Duration) Option {
 	}
 }
 
+// WithMetricsTracer sets the tracer that the size of the peerstore is
+// reported to after every clean up run.
+func WithMetricsTracer(mt MetricsTracer) Option {
+	return func(m *PeerstoreManager) error {
+		m.metricsTracer = mt
+		return nil
+	}
+}
+
 type PeerstoreManager struct {
 	pstore   peerstore.Peerstore
 	eventBus event.Bus
@@ -48,6 +57,7 @@ type PeerstoreManager struct {
 
 	gracePeriod     time.Duration
 	cleanupInterval time.Duration
+	metricsTracer   MetricsTracer
 }
 
 func NewPeerstoreManager(pstore peerstore.Peerstore, eventBus event.Bus, network network.Network, opts ...Option) (*PeerstoreManager, error) {
@@ -129,12 +139,27 @@ func (m *PeerstoreManager) background(ctx context.Context, sub event.Subscriptio
 					delete(disconnected, p)
 				}
 			}
+			if m.metricsTracer != nil {
+				m.reportSize()
+			}
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+func (m *PeerstoreManager) reportSize() {
+	peers := m.pstore.Peers()
+	var addrs, protos int
+	for _, p := range peers {
+		addrs += len(m.pstore.Addrs(p))
+		if ps, err := m.pstore.GetProtocols(p); err == nil {
+			protos += len(ps)
+		}
+	}
+	m.metricsTracer.PeerstoreSize(len(peers), addrs, protos)
+}
+
 func (m *PeerstoreManager) Close() error {
 	if m.cancel != nil {
 		m.cancel()
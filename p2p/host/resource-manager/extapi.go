@@ -16,7 +16,10 @@ type ResourceScopeLimiter interface {
 	SetLimit(Limit)
 }
 
-var _ ResourceScopeLimiter = (*resourceScope)(nil)
+var (
+	_ ResourceScopeLimiter             = (*resourceScope)(nil)
+	_ network.ResourceScopeLimitViewer = (*resourceScope)(nil)
+)
 
 // ResourceManagerStat is a trait that allows you to access resource manager state.
 type ResourceManagerState interface {
@@ -44,6 +47,11 @@ func (s *resourceScope) Limit() Limit {
 	return s.rc.limit
 }
 
+// ScopeLimit implements network.ResourceScopeLimitViewer.
+func (s *resourceScope) ScopeLimit() network.ScopeLimit {
+	return s.Limit()
+}
+
 func (s *resourceScope) SetLimit(limit Limit) {
 	s.Lock()
 	defer s.Unlock()
@@ -147,5 +155,5 @@ func (r *resourceManager) Stat() (result ResourceManagerStat) {
 }
 
 func (r *resourceManager) GetConnLimit() int {
-	return r.limits.GetSystemLimits().GetConnTotalLimit()
+	return r.getLimits().GetSystemLimits().GetConnTotalLimit()
 }
@@ -23,17 +23,20 @@ import (
 var log = logging.Logger("rcmgr")
 
 type resourceManager struct {
-	limits Limiter
+	limitsMx sync.RWMutex
+	limits   Limiter
 
 	connLimiter                    *connLimiter
 	connRateLimiter                *rate.Limiter
 	verifySourceAddressRateLimiter *rate.Limiter
+	svcStreamRates                 map[string]*serviceStreamRate
 
 	trace          *trace
 	metrics        *metrics
 	disableMetrics bool
 
 	allowlist *Allowlist
+	shedding  *connShedding
 
 	system    *systemScope
 	transient *transientScope
@@ -171,7 +174,7 @@ func NewResourceManager(limits Limiter, opts ...Option) (network.ResourceManager
 			// connlimiter doesn't know about this network. Let's fix that
 			r.connLimiter.addNetworkPrefixLimit(prefix.Addr().Is6(), NetworkPrefixLimit{
 				Network:   prefix,
-				ConnCount: r.limits.GetAllowlistedSystemLimits().GetConnTotalLimit(),
+				ConnCount: r.getLimits().GetAllowlistedSystemLimits().GetConnTotalLimit(),
 			})
 		}
 	}
@@ -217,6 +220,10 @@ func NewResourceManager(limits Limiter, opts ...Option) (network.ResourceManager
 
 	r.wg.Add(1)
 	go r.background()
+	if r.shedding != nil {
+		r.wg.Add(1)
+		go r.shedConnsLoop()
+	}
 
 	return r, nil
 }
@@ -272,7 +279,7 @@ func (r *resourceManager) getServiceScope(svc string) *serviceScope {
 
 	s, ok := r.svc[svc]
 	if !ok {
-		s = newServiceScope(svc, r.limits.GetServiceLimits(svc), r)
+		s = newServiceScope(svc, r.getLimits().GetServiceLimits(svc), r)
 		r.svc[svc] = s
 	}
 
@@ -286,7 +293,7 @@ func (r *resourceManager) getProtocolScope(proto protocol.ID) *protocolScope {
 
 	s, ok := r.proto[proto]
 	if !ok {
-		s = newProtocolScope(proto, r.limits.GetProtocolLimits(proto), r)
+		s = newProtocolScope(proto, r.getLimits().GetProtocolLimits(proto), r)
 		r.proto[proto] = s
 	}
 
@@ -310,7 +317,7 @@ func (r *resourceManager) getPeerScope(p peer.ID) *peerScope {
 
 	s, ok := r.peer[p]
 	if !ok {
-		s = newPeerScope(p, r.limits.GetPeerLimits(p), r)
+		s = newPeerScope(p, r.getLimits().GetPeerLimits(p), r)
 		r.peer[p] = s
 	}
 
@@ -391,7 +398,7 @@ func (r *resourceManager) openConnection(dir network.Direction, usefd bool, endp
 	}
 
 	var conn *connectionScope
-	conn = newConnectionScope(dir, usefd, r.limits.GetConnLimits(), r, endpoint, ip)
+	conn = newConnectionScope(dir, usefd, r.getLimits().GetConnLimits(), r, endpoint, ip)
 
 	err := conn.AddConn(dir, usefd)
 	if err != nil && ip.IsValid() {
@@ -400,7 +407,7 @@ func (r *resourceManager) openConnection(dir network.Direction, usefd bool, endp
 		allowed := r.allowlist.Allowed(endpoint)
 		if allowed {
 			conn.Done()
-			conn = newAllowListedConnectionScope(dir, usefd, r.limits.GetConnLimits(), r, endpoint)
+			conn = newAllowListedConnectionScope(dir, usefd, r.getLimits().GetConnLimits(), r, endpoint)
 			err = conn.AddConn(dir, usefd)
 		}
 	}
@@ -417,7 +424,7 @@ func (r *resourceManager) openConnection(dir network.Direction, usefd bool, endp
 
 func (r *resourceManager) OpenStream(p peer.ID, dir network.Direction) (network.StreamManagementScope, error) {
 	peer := r.getPeerScope(p)
-	stream := newStreamScope(dir, r.limits.GetStreamLimits(p), peer, r)
+	stream := newStreamScope(dir, r.getLimits().GetStreamLimits(p), peer, r)
 	peer.DecRef() // we have the reference in edges
 
 	err := stream.AddStream(dir)
@@ -667,7 +674,7 @@ func (s *serviceScope) getPeerScope(p peer.ID) *resourceScope {
 		return ps
 	}
 
-	l := s.rcmgr.limits.GetServicePeerLimits(s.service)
+	l := s.rcmgr.getLimits().GetServicePeerLimits(s.service)
 
 	if s.peers == nil {
 		s.peers = make(map[peer.ID]*resourceScope)
@@ -694,7 +701,7 @@ func (s *protocolScope) getPeerScope(p peer.ID) *resourceScope {
 		return ps
 	}
 
-	l := s.rcmgr.limits.GetProtocolPeerLimits(s.proto)
+	l := s.rcmgr.getLimits().GetProtocolPeerLimits(s.proto)
 
 	if s.peers == nil {
 		s.peers = make(map[peer.ID]*resourceScope)
@@ -918,6 +925,10 @@ func (s *streamScope) SetService(svc string) error {
 	if s.proto == nil {
 		return fmt.Errorf("stream scope not attached to a protocol")
 	}
+	if err := s.rcmgr.allowServiceStream(svc, s.peer.peer); err != nil {
+		s.rcmgr.metrics.BlockService(svc)
+		return err
+	}
 
 	s.svc = s.rcmgr.getServiceScope(svc)
 
@@ -0,0 +1,148 @@
+package rcmgr
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// ResourceManagerLimiter is a trait that allows you to change the limits of a
+// running resource manager, e.g. to raise the limits of a node that hits them
+// during an incident without restarting it.
+type ResourceManagerLimiter interface {
+	// SetLimits replaces the limits of the resource manager with limits. The
+	// new limits apply to the system, transient, service, protocol and peer
+	// scopes right away, overriding limits set with ResourceScopeLimiter.
+	// Connection and stream scopes keep the limits they were opened with.
+	//
+	// Resources that are already reserved are never released: if a limit is
+	// lowered below the current usage, new reservations fail until the usage
+	// drops below the limit.
+	SetLimits(limits ConcreteLimitConfig)
+}
+
+var _ ResourceManagerLimiter = (*resourceManager)(nil)
+
+func (r *resourceManager) getLimits() Limiter {
+	r.limitsMx.RLock()
+	defer r.limitsMx.RUnlock()
+	return r.limits
+}
+
+func (r *resourceManager) SetLimits(limits ConcreteLimitConfig) {
+	limiter := NewFixedLimiter(limits)
+	// Swap the limiter before collecting the scopes, so that scopes created
+	// concurrently either use the new limits or are updated below.
+	r.limitsMx.Lock()
+	r.limits = limiter
+	r.limitsMx.Unlock()
+
+	r.mx.Lock()
+	svcs := make([]*serviceScope, 0, len(r.svc))
+	for _, svc := range r.svc {
+		svcs = append(svcs, svc)
+	}
+	protos := make([]*protocolScope, 0, len(r.proto))
+	for _, proto := range r.proto {
+		protos = append(protos, proto)
+	}
+	peers := make([]*peerScope, 0, len(r.peer))
+	for _, p := range r.peer {
+		peers = append(peers, p)
+	}
+	r.mx.Unlock()
+
+	r.system.resourceScope.SetLimit(limiter.GetSystemLimits())
+	r.transient.resourceScope.SetLimit(limiter.GetTransientLimits())
+	r.allowlistedSystem.resourceScope.SetLimit(limiter.GetAllowlistedSystemLimits())
+	r.allowlistedTransient.resourceScope.SetLimit(limiter.GetAllowlistedTransientLimits())
+
+	for _, svc := range svcs {
+		svc.resourceScope.SetLimit(limiter.GetServiceLimits(svc.service))
+		setPeerScopeLimits(svc.resourceScope, svc.peers, limiter.GetServicePeerLimits(svc.service))
+	}
+	for _, proto := range protos {
+		proto.resourceScope.SetLimit(limiter.GetProtocolLimits(proto.proto))
+		setPeerScopeLimits(proto.resourceScope, proto.peers, limiter.GetProtocolPeerLimits(proto.proto))
+	}
+	for _, p := range peers {
+		p.resourceScope.SetLimit(limiter.GetPeerLimits(p.peer))
+	}
+	log.Infow("resource manager limits updated", "limits", limits)
+}
+
+// setPeerScopeLimits sets the limit of the per-peer scopes of a service or
+// protocol scope s. peers is guarded by the lock of s.
+func setPeerScopeLimits(s *resourceScope, peers map[peer.ID]*resourceScope, limit Limit) {
+	s.Lock()
+	defer s.Unlock()
+	for _, ps := range peers {
+		ps.SetLimit(limit)
+	}
+}
+
+// WatchLimitConfigFile applies the JSON limit config at path to rcmgr, and
+// polls the file every interval to apply it again whenever it changes, until
+// ctx is done. Limits missing from the file are taken from defaults, like with
+// NewLimiterFromJSON.
+//
+// An error is returned if the file can't be loaded initially. Later errors
+// are logged, and the limits in effect are kept.
+func WatchLimitConfigFile(ctx context.Context, rcmgr ResourceManagerLimiter, path string, defaults ConcreteLimitConfig, interval time.Duration) error {
+	if interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+	fi, err := loadLimitConfigFile(rcmgr, path, defaults)
+	if err != nil {
+		return err
+	}
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				return
+			}
+			cur, err := os.Stat(path)
+			if err != nil {
+				log.Warnw("failed to stat limit config file", "path", path, "error", err)
+				continue
+			}
+			if cur.ModTime().Equal(fi.ModTime()) && cur.Size() == fi.Size() {
+				continue
+			}
+			newFi, err := loadLimitConfigFile(rcmgr, path, defaults)
+			if err != nil {
+				log.Warnw("failed to reload limit config file", "path", path, "error", err)
+				// don't retry until the file changes again
+				fi = cur
+				continue
+			}
+			fi = newFi
+		}
+	}()
+	return nil
+}
+
+func loadLimitConfigFile(rcmgr ResourceManagerLimiter, path string, defaults ConcreteLimitConfig) (os.FileInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := readLimiterConfigFromJSON(f, defaults)
+	if err != nil {
+		return nil, err
+	}
+	rcmgr.SetLimits(cfg)
+	return fi, nil
+}
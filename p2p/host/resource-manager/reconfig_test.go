@@ -0,0 +1,83 @@
+package rcmgr
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+
+	"github.com/multiformats/go-multiaddr"
+)
+
+func TestSetLimits(t *testing.T) {
+	limits := DefaultLimits.AutoScale()
+	limits.system.ConnsInbound = 1
+	rm, err := NewResourceManager(NewFixedLimiter(limits))
+	require.NoError(t, err)
+	defer rm.Close()
+
+	p := peer.ID("A")
+	peerLimit := func() Limit {
+		var l Limit
+		require.NoError(t, rm.ViewPeer(p, func(s network.PeerScope) error {
+			l = s.(ResourceScopeLimiter).Limit()
+			return nil
+		}))
+		return l
+	}
+	require.Equal(t, limits.peerDefault.GetStreamTotalLimit(), peerLimit().GetStreamTotalLimit())
+
+	c1, err := rm.OpenConnection(network.DirInbound, true, multiaddr.StringCast("/ip4/1.2.3.4/tcp/1"))
+	require.NoError(t, err)
+	defer c1.Done()
+	_, err = rm.OpenConnection(network.DirInbound, true, multiaddr.StringCast("/ip4/1.2.3.5/tcp/1"))
+	require.ErrorIs(t, err, network.ErrResourceLimitExceeded)
+
+	limits.system.ConnsInbound = 2
+	limits.peerDefault.Streams = 7
+	rm.(ResourceManagerLimiter).SetLimits(limits)
+
+	c2, err := rm.OpenConnection(network.DirInbound, true, multiaddr.StringCast("/ip4/1.2.3.5/tcp/1"))
+	require.NoError(t, err)
+	defer c2.Done()
+	require.Equal(t, 7, peerLimit().GetStreamTotalLimit())
+
+	// lowering a limit below the usage keeps the reserved resources
+	limits.system.ConnsInbound = 1
+	rm.(ResourceManagerLimiter).SetLimits(limits)
+	require.Equal(t, 2, rm.(ResourceManagerState).Stat().System.NumConnsInbound)
+	_, err = rm.OpenConnection(network.DirInbound, true, multiaddr.StringCast("/ip4/1.2.3.6/tcp/1"))
+	require.ErrorIs(t, err, network.ErrResourceLimitExceeded)
+}
+
+func TestWatchLimitConfigFile(t *testing.T) {
+	rm, err := NewResourceManager(NewFixedLimiter(DefaultLimits.AutoScale()))
+	require.NoError(t, err)
+	defer rm.Close()
+	systemLimit := func() int {
+		return rm.(*resourceManager).system.Limit().GetConnLimit(network.DirInbound)
+	}
+
+	path := filepath.Join(t.TempDir(), "limits.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"System": {"ConnsInbound": 10}}`), 0o644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, WatchLimitConfigFile(ctx, rm.(ResourceManagerLimiter), path, DefaultLimits.AutoScale(), 10*time.Millisecond))
+	require.Equal(t, 10, systemLimit())
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"System": {"ConnsInbound": 20}}`), 0o644))
+	require.Eventually(t, func() bool { return systemLimit() == 20 }, 5*time.Second, 10*time.Millisecond)
+
+	// invalid configs are ignored
+	require.NoError(t, os.WriteFile(path, []byte(`{"System": `), 0o644))
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, 20, systemLimit())
+
+	require.Error(t, WatchLimitConfigFile(ctx, rm.(ResourceManagerLimiter), filepath.Join(t.TempDir(), "missing.json"), DefaultLimits.AutoScale(), time.Second))
+}
@@ -0,0 +1,149 @@
+package rcmgr
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/x/rate"
+	"github.com/prometheus/client_golang/prometheus"
+
+	xrate "golang.org/x/time/rate"
+)
+
+var serviceStreamsRateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metricNamespace,
+	Name:      "service_streams_rate_limited_total",
+	Help:      "Number of streams blocked by the stream rate limit of a service",
+}, []string{"service"})
+
+// minPeerSweep is the number of per-peer buckets of a service above which
+// full buckets are evicted.
+const minPeerSweep = 64
+
+// serviceStreamRate limits the rate at which streams are attached to a
+// service, in total and per peer.
+type serviceStreamRate struct {
+	// total is shared by all peers. It is nil if the total rate isn't
+	// limited.
+	total *xrate.Limiter
+
+	// peerLimit is the limit of every peer. It is zero if the rate of peers
+	// isn't limited.
+	peerLimit rate.Limit
+	mx        sync.Mutex
+	peers     map[peer.ID]*xrate.Limiter
+	// nextSweep is the number of peers at which full buckets are evicted.
+	nextSweep int
+}
+
+// allow reports whether p may attach a stream now. The peer's bucket comes
+// first, so that a single peer can't drain the shared bucket.
+func (r *serviceStreamRate) allow(p peer.ID) bool {
+	if r.peerLimit.RPS == 0 {
+		return r.total == nil || r.total.Allow()
+	}
+
+	now := time.Now()
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	l, ok := r.peers[p]
+	if !ok {
+		l = xrate.NewLimiter(xrate.Limit(r.peerLimit.RPS), r.peerLimit.Burst)
+		r.peers[p] = l
+		if len(r.peers) >= r.nextSweep {
+			r.sweep(now)
+		}
+	}
+	rsv := l.ReserveN(now, 1)
+	if !rsv.OK() || rsv.DelayFrom(now) > 0 {
+		rsv.CancelAt(now)
+		return false
+	}
+	if r.total != nil && !r.total.AllowN(now, 1) {
+		// the stream isn't admitted, so it doesn't count against the peer
+		rsv.CancelAt(now)
+		return false
+	}
+	return true
+}
+
+// sweep evicts the buckets of peers that are full. A full bucket behaves like
+// a new one, so this forgets peers without changing what they're allowed.
+// r.mx must be held.
+func (r *serviceStreamRate) sweep(now time.Time) {
+	for p, l := range r.peers {
+		if l.TokensAt(now) >= float64(r.peerLimit.Burst) {
+			delete(r.peers, p)
+		}
+	}
+	r.nextSweep = max(2*len(r.peers), minPeerSweep)
+}
+
+func (r *resourceManager) serviceStreamRate(svc string) *serviceStreamRate {
+	if r.svcStreamRates == nil {
+		r.svcStreamRates = make(map[string]*serviceStreamRate)
+	}
+	sr, ok := r.svcStreamRates[svc]
+	if !ok {
+		sr = &serviceStreamRate{}
+		r.svcStreamRates[svc] = sr
+	}
+	return sr
+}
+
+// WithServiceStreamRateLimit limits the rate at which streams are attached to
+// the service svc. Unlike the stream limits of the service scope, which bound
+// the number of concurrent streams, this smooths out spikes of short-lived
+// streams: limit.RPS streams are admitted per second in the steady state, and
+// up to limit.Burst streams at once after a quiet period.
+//
+// This rate is shared by all peers, so a single peer can use it up. Use
+// WithServicePeerStreamRateLimit to limit the rate of every peer as well.
+//
+// Streams exceeding the rate fail to set the service with
+// network.ErrResourceLimitExceeded, and are counted by the
+// libp2p_rcmgr_service_streams_rate_limited_total metric.
+func WithServiceStreamRateLimit(svc string, limit rate.Limit) Option {
+	return func(r *resourceManager) error {
+		if limit.RPS <= 0 || limit.Burst <= 0 {
+			return errors.New("service stream rate and burst must be positive")
+		}
+		r.serviceStreamRate(svc).total = xrate.NewLimiter(xrate.Limit(limit.RPS), limit.Burst)
+		return nil
+	}
+}
+
+// WithServicePeerStreamRateLimit limits the rate at which every peer attaches
+// streams to the service svc, like WithServiceStreamRateLimit does for all
+// peers together. If both are set, a stream has to pass the peer's limit
+// first, so that a peer exceeding its rate doesn't use up the rate shared by
+// all peers.
+func WithServicePeerStreamRateLimit(svc string, limit rate.Limit) Option {
+	return func(r *resourceManager) error {
+		if limit.RPS <= 0 || limit.Burst <= 0 {
+			return errors.New("service stream rate and burst must be positive")
+		}
+		sr := r.serviceStreamRate(svc)
+		sr.peerLimit = limit
+		sr.peers = make(map[peer.ID]*xrate.Limiter)
+		sr.nextSweep = minPeerSweep
+		return nil
+	}
+}
+
+// allowServiceStream reports whether p may attach a stream to svc now.
+func (r *resourceManager) allowServiceStream(svc string, p peer.ID) error {
+	// svcStreamRates is only written during construction
+	sr, ok := r.svcStreamRates[svc]
+	if !ok || sr.allow(p) {
+		return nil
+	}
+	if !r.disableMetrics {
+		serviceStreamsRateLimited.WithLabelValues(svc).Inc()
+	}
+	return fmt.Errorf("service %s: stream rate limit exceeded: %w", svc, network.ErrResourceLimitExceeded)
+}
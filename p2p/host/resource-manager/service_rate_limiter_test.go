@@ -0,0 +1,83 @@
+package rcmgr
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/x/rate"
+	"github.com/stretchr/testify/require"
+
+	xrate "golang.org/x/time/rate"
+)
+
+func TestServiceStreamRateLimit(t *testing.T) {
+	rm, err := NewResourceManager(NewFixedLimiter(DefaultLimits.AutoScale()),
+		WithServiceStreamRateLimit("limited", rate.Limit{RPS: 0.0001, Burst: 2}))
+	require.NoError(t, err)
+	defer rm.Close()
+
+	setService := func(svc string) error {
+		s, err := rm.OpenStream("A", network.DirInbound)
+		require.NoError(t, err)
+		t.Cleanup(s.Done)
+		require.NoError(t, s.SetProtocol("/proto"))
+		return s.SetService(svc)
+	}
+	// the burst is admitted
+	require.NoError(t, setService("limited"))
+	require.NoError(t, setService("limited"))
+	require.ErrorIs(t, setService("limited"), network.ErrResourceLimitExceeded)
+	// other services aren't affected
+	require.NoError(t, setService("other"))
+
+	_, err = NewResourceManager(NewFixedLimiter(DefaultLimits.AutoScale()),
+		WithServiceStreamRateLimit("limited", rate.Limit{RPS: 1}))
+	require.Error(t, err)
+}
+
+func TestServicePeerStreamRateLimit(t *testing.T) {
+	rm, err := NewResourceManager(NewFixedLimiter(DefaultLimits.AutoScale()),
+		WithServiceStreamRateLimit("limited", rate.Limit{RPS: 0.0001, Burst: 3}),
+		WithServicePeerStreamRateLimit("limited", rate.Limit{RPS: 0.0001, Burst: 1}))
+	require.NoError(t, err)
+	defer rm.Close()
+
+	setService := func(p peer.ID) error {
+		s, err := rm.OpenStream(p, network.DirInbound)
+		require.NoError(t, err)
+		t.Cleanup(s.Done)
+		require.NoError(t, s.SetProtocol("/proto"))
+		return s.SetService("limited")
+	}
+	require.NoError(t, setService("A"))
+	// A exceeding its rate doesn't use up the rate of the other peers
+	for i := 0; i < 5; i++ {
+		require.ErrorIs(t, setService("A"), network.ErrResourceLimitExceeded)
+	}
+	require.NoError(t, setService("B"))
+	require.NoError(t, setService("C"))
+	// the total rate still applies
+	require.ErrorIs(t, setService("D"), network.ErrResourceLimitExceeded)
+	// and D wasn't charged for the refused stream
+	sr := rm.(*resourceManager).svcStreamRates["limited"]
+	require.InDelta(t, 1, sr.peers["D"].Tokens(), 0.01)
+}
+
+func TestServicePeerStreamRateLimitForgetsPeers(t *testing.T) {
+	sr := &serviceStreamRate{
+		peerLimit: rate.Limit{RPS: 1000, Burst: 1},
+		peers:     make(map[peer.ID]*xrate.Limiter),
+		nextSweep: minPeerSweep,
+	}
+	for i := 0; i < 10*minPeerSweep; i++ {
+		require.True(t, sr.allow(peer.ID(fmt.Sprint(i))))
+		// let the buckets refill
+		if i%minPeerSweep == 0 {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	require.Less(t, len(sr.peers), 2*minPeerSweep)
+}
@@ -0,0 +1,109 @@
+package rcmgr
+
+import (
+	"errors"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var shedConns = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: metricNamespace,
+	Name:      "shed_connections_total",
+	Help:      "Number of connections closed to relieve resource pressure",
+})
+
+// ConnShedder closes connections when the resource manager is under pressure.
+// *connmgr.BasicConnMgr implements it.
+type ConnShedder interface {
+	// ShedConns closes about n of the least valuable connections, sparing
+	// protected peers, and returns the number of connections closed.
+	ShedConns(n int) int
+}
+
+// connShedding sheds connections when the usage of the system scope crosses
+// the high watermark.
+type connShedding struct {
+	shedder   ConnShedder
+	highWater float64
+	lowWater  float64
+	interval  time.Duration
+}
+
+// WithConnShedding proactively closes connections when the system scope is
+// under pressure, instead of only rejecting new work once a limit is reached,
+// which causes peers to retry against us.
+//
+// The pressure is the highest ratio of the usage of the system scope to its
+// limit, among connections, streams, memory and file descriptors. Once per
+// second, if the pressure reaches highWater, shedder is asked to close enough
+// connections to bring the pressure down to lowWater, assuming usage is
+// proportional to the number of connections. Watermarks are fractions of the
+// limits, e.g. 0.9 and 0.8.
+func WithConnShedding(shedder ConnShedder, highWater, lowWater float64) Option {
+	return func(r *resourceManager) error {
+		if shedder == nil {
+			return errors.New("conn shedder is nil")
+		}
+		if lowWater <= 0 || lowWater >= highWater || highWater > 1 {
+			return errors.New("watermarks must satisfy 0 < lowWater < highWater <= 1")
+		}
+		r.shedding = &connShedding{
+			shedder:   shedder,
+			highWater: highWater,
+			lowWater:  lowWater,
+			interval:  time.Second,
+		}
+		return nil
+	}
+}
+
+// pressure returns the highest ratio of usage to limit of the scope.
+func pressure(st network.ScopeStat, l Limit) float64 {
+	ratio := func(used, limit int64) float64 {
+		if limit <= 0 {
+			return 0
+		}
+		return float64(used) / float64(limit)
+	}
+	return max(
+		ratio(int64(st.NumConnsInbound+st.NumConnsOutbound), int64(l.GetConnTotalLimit())),
+		ratio(int64(st.NumStreamsInbound+st.NumStreamsOutbound), int64(l.GetStreamTotalLimit())),
+		ratio(st.Memory, l.GetMemoryLimit()),
+		ratio(int64(st.NumFD), int64(l.GetFDLimit())),
+	)
+}
+
+func (r *resourceManager) shedConnsLoop() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.shedding.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.shedConns()
+		case <-r.cancelCtx.Done():
+			return
+		}
+	}
+}
+
+// shedConns sheds connections if the system scope is above the high
+// watermark.
+func (r *resourceManager) shedConns() {
+	st := r.system.Stat()
+	p := pressure(st, r.system.Limit())
+	if p < r.shedding.highWater {
+		return
+	}
+	conns := st.NumConnsInbound + st.NumConnsOutbound
+	n := max(1, conns-int(float64(conns)*r.shedding.lowWater/p))
+	closed := r.shedding.shedder.ShedConns(n)
+	log.Infow("resource pressure above high watermark, shedding connections", "pressure", p, "target", n, "closed", closed)
+	if !r.disableMetrics {
+		shedConns.Add(float64(closed))
+	}
+}
@@ -0,0 +1,57 @@
+package rcmgr
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/stretchr/testify/require"
+
+	"github.com/multiformats/go-multiaddr"
+)
+
+type mockShedder struct {
+	requested []int
+}
+
+func (s *mockShedder) ShedConns(n int) int {
+	s.requested = append(s.requested, n)
+	return n
+}
+
+func TestConnShedding(t *testing.T) {
+	limits := DefaultLimits.AutoScale()
+	limits.system.Conns = 10
+	limits.system.ConnsInbound = 10
+	limits.transient.Conns = 10
+	limits.transient.ConnsInbound = 10
+	shedder := &mockShedder{}
+	rm, err := NewResourceManager(NewFixedLimiter(limits), WithConnShedding(shedder, 0.8, 0.5))
+	require.NoError(t, err)
+	defer rm.Close()
+	r := rm.(*resourceManager)
+
+	var conns []network.ConnManagementScope
+	for i := 0; i < 9; i++ {
+		c, err := rm.OpenConnection(network.DirInbound, false, multiaddr.StringCast(fmt.Sprintf("/ip4/1.2.3.%d/tcp/1", i)))
+		require.NoError(t, err)
+		conns = append(conns, c)
+	}
+
+	// 9 of 10 connections: shed down to half the limit
+	r.shedConns()
+	require.Equal(t, []int{4}, shedder.requested)
+
+	// below the high watermark, nothing is shed
+	conns[0].Done()
+	conns[1].Done()
+	r.shedConns()
+	require.Equal(t, []int{4}, shedder.requested)
+
+	for _, c := range conns[2:] {
+		c.Done()
+	}
+
+	_, err = NewResourceManager(NewFixedLimiter(limits), WithConnShedding(shedder, 0.5, 0.8))
+	require.Error(t, err)
+}
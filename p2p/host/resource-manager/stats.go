@@ -157,6 +157,8 @@ func MustRegisterWith(reg prometheus.Registerer) {
 		previousConnMemory,
 		fds,
 		blockedResources,
+		serviceStreamsRateLimited,
+		shedConns,
 	)
 }
 
@@ -0,0 +1,131 @@
+package rcmgr
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// UsageOrder is the resource that scopes are ranked by in a UsageQuery.
+type UsageOrder int
+
+const (
+	// OrderByStreams ranks scopes by their number of streams.
+	OrderByStreams UsageOrder = iota
+	// OrderByConns ranks scopes by their number of connections.
+	OrderByConns
+	// OrderByMemory ranks scopes by their reserved memory.
+	OrderByMemory
+)
+
+// UsageQuery selects the scopes returned by TopPeers and TopProtocols.
+type UsageQuery struct {
+	// OrderBy is the resource by which scopes are ranked, in descending
+	// order.
+	OrderBy UsageOrder
+	// Direction restricts the streams and connections counted by OrderBy to
+	// one direction. DirUnknown counts both.
+	Direction network.Direction
+	// N is the maximum number of scopes returned. Zero returns all scopes.
+	N int
+	// Filter, if set, skips the scopes for which it returns false.
+	Filter func(network.ScopeStat) bool
+}
+
+// PeerUsage is the resource usage of a peer scope.
+type PeerUsage struct {
+	Peer  peer.ID
+	Stat  network.ScopeStat
+	Limit Limit
+}
+
+// ProtocolUsage is the resource usage of a protocol scope.
+type ProtocolUsage struct {
+	Protocol protocol.ID
+	Stat     network.ScopeStat
+	Limit    Limit
+}
+
+// ResourceManagerUsage is a trait that allows you to find the peers and
+// protocols using the most resources.
+type ResourceManagerUsage interface {
+	// TopPeers returns the usage of the peers ranked first by q.
+	TopPeers(q UsageQuery) []PeerUsage
+	// TopProtocols returns the usage of the protocols ranked first by q.
+	TopProtocols(q UsageQuery) []ProtocolUsage
+}
+
+var _ ResourceManagerUsage = (*resourceManager)(nil)
+
+func (q UsageQuery) usage(st network.ScopeStat) int64 {
+	switch q.OrderBy {
+	case OrderByConns:
+		switch q.Direction {
+		case network.DirInbound:
+			return int64(st.NumConnsInbound)
+		case network.DirOutbound:
+			return int64(st.NumConnsOutbound)
+		}
+		return int64(st.NumConnsInbound + st.NumConnsOutbound)
+	case OrderByMemory:
+		return st.Memory
+	default:
+		switch q.Direction {
+		case network.DirInbound:
+			return int64(st.NumStreamsInbound)
+		case network.DirOutbound:
+			return int64(st.NumStreamsOutbound)
+		}
+		return int64(st.NumStreamsInbound + st.NumStreamsOutbound)
+	}
+}
+
+// topUsage filters, sorts and truncates usage according to q.
+func topUsage[T any](q UsageQuery, usage []T, stat func(T) network.ScopeStat) []T {
+	if q.Filter != nil {
+		usage = slices.DeleteFunc(usage, func(u T) bool { return !q.Filter(stat(u)) })
+	}
+	slices.SortStableFunc(usage, func(a, b T) int {
+		return cmp.Compare(q.usage(stat(b)), q.usage(stat(a)))
+	})
+	if q.N > 0 && len(usage) > q.N {
+		usage = usage[:q.N]
+	}
+	return usage
+}
+
+func (r *resourceManager) TopPeers(q UsageQuery) []PeerUsage {
+	r.mx.Lock()
+	peers := make([]*peerScope, 0, len(r.peer))
+	for _, p := range r.peer {
+		peers = append(peers, p)
+	}
+	r.mx.Unlock()
+
+	usage := make([]PeerUsage, 0, len(peers))
+	for _, p := range peers {
+		usage = append(usage, PeerUsage{Peer: p.peer, Stat: p.Stat(), Limit: p.Limit()})
+	}
+	// rank peers with the same usage consistently
+	slices.SortFunc(usage, func(a, b PeerUsage) int { return cmp.Compare(a.Peer, b.Peer) })
+	return topUsage(q, usage, func(u PeerUsage) network.ScopeStat { return u.Stat })
+}
+
+func (r *resourceManager) TopProtocols(q UsageQuery) []ProtocolUsage {
+	r.mx.Lock()
+	protos := make([]*protocolScope, 0, len(r.proto))
+	for _, p := range r.proto {
+		protos = append(protos, p)
+	}
+	r.mx.Unlock()
+
+	usage := make([]ProtocolUsage, 0, len(protos))
+	for _, p := range protos {
+		usage = append(usage, ProtocolUsage{Protocol: p.proto, Stat: p.Stat(), Limit: p.Limit()})
+	}
+	slices.SortFunc(usage, func(a, b ProtocolUsage) int { return cmp.Compare(a.Protocol, b.Protocol) })
+	return topUsage(q, usage, func(u ProtocolUsage) network.ScopeStat { return u.Stat })
+}
@@ -0,0 +1,58 @@
+package rcmgr
+
+import (
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopUsage(t *testing.T) {
+	rm, err := NewResourceManager(NewFixedLimiter(DefaultLimits.AutoScale()))
+	require.NoError(t, err)
+	defer rm.Close()
+	usage := rm.(ResourceManagerUsage)
+
+	openStreams := func(p peer.ID, proto protocol.ID, dir network.Direction, n int) {
+		for i := 0; i < n; i++ {
+			s, err := rm.OpenStream(p, dir)
+			require.NoError(t, err)
+			require.NoError(t, s.SetProtocol(proto))
+			t.Cleanup(s.Done)
+		}
+	}
+	openStreams("A", "/a", network.DirInbound, 1)
+	openStreams("B", "/b", network.DirInbound, 3)
+	openStreams("C", "/a", network.DirOutbound, 2)
+	require.NoError(t, rm.ViewPeer("A", func(s network.PeerScope) error {
+		return s.ReserveMemory(1024, network.ReservationPriorityAlways)
+	}))
+
+	peerIDs := func(u []PeerUsage) []peer.ID {
+		var ids []peer.ID
+		for _, pu := range u {
+			ids = append(ids, pu.Peer)
+		}
+		return ids
+	}
+
+	top := usage.TopPeers(UsageQuery{OrderBy: OrderByStreams})
+	require.Equal(t, []peer.ID{"B", "C", "A"}, peerIDs(top))
+	require.Equal(t, 3, top[0].Stat.NumStreamsInbound)
+	require.NotNil(t, top[0].Limit)
+
+	require.Equal(t, []peer.ID{"B", "A"}, peerIDs(usage.TopPeers(UsageQuery{Direction: network.DirInbound, N: 2})))
+	require.Equal(t, []peer.ID{"A"}, peerIDs(usage.TopPeers(UsageQuery{OrderBy: OrderByMemory, N: 1})))
+	require.Equal(t, []peer.ID{"C"}, peerIDs(usage.TopPeers(UsageQuery{
+		Filter: func(st network.ScopeStat) bool { return st.NumStreamsOutbound > 0 },
+	})))
+
+	protos := usage.TopProtocols(UsageQuery{})
+	require.Len(t, protos, 2)
+	require.Equal(t, protocol.ID("/a"), protos[0].Protocol)
+	require.Equal(t, 1, protos[0].Stat.NumStreamsInbound)
+	require.Equal(t, 2, protos[0].Stat.NumStreamsOutbound)
+	require.Equal(t, protocol.ID("/b"), protos[1].Protocol)
+}
@@ -0,0 +1,68 @@
+package timeline
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+type jsonEvent struct {
+	Time      string `json:"time"`
+	Type      string `json:"type"`
+	Addr      string `json:"addr,omitempty"`
+	Direction string `json:"direction,omitempty"`
+	Relayed   bool   `json:"relayed,omitempty"`
+	Duration  string `json:"duration,omitempty"`
+	Err       string `json:"error,omitempty"`
+}
+
+func toJSON(evts []Event) []jsonEvent {
+	out := make([]jsonEvent, 0, len(evts))
+	for _, e := range evts {
+		je := jsonEvent{
+			Time:    e.Time.UTC().Format("2006-01-02T15:04:05.000Z07:00"),
+			Type:    e.Type.String(),
+			Relayed: e.Relayed,
+			Err:     e.Err,
+		}
+		if e.Addr != nil {
+			je.Addr = e.Addr.String()
+		}
+		if e.Type == Connected || e.Type == Disconnected {
+			je.Direction = e.Direction.String()
+		}
+		if e.Duration > 0 {
+			je.Duration = e.Duration.String()
+		}
+		out = append(out, je)
+	}
+	return out
+}
+
+// ServeHTTP serves the timeline as JSON, e.g. on a debug endpoint. The events
+// of a single peer are returned if the peer query parameter is set, otherwise
+// the events of all peers are returned, keyed by peer ID.
+func (t *Timeline) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var resp any
+	if s := r.URL.Query().Get("peer"); s != "" {
+		p, err := peer.Decode(s)
+		if err != nil {
+			http.Error(w, "invalid peer ID", http.StatusBadRequest)
+			return
+		}
+		resp = toJSON(t.Peer(p))
+	} else {
+		all := make(map[string][]jsonEvent)
+		for _, p := range t.Peers() {
+			if evts := t.Peer(p); len(evts) > 0 {
+				all[p.String()] = toJSON(evts)
+			}
+		}
+		resp = all
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Debugw("failed to write timeline", "error", err)
+	}
+}
@@ -0,0 +1,277 @@
+// Package timeline keeps a bounded history of the connection events of each
+// peer, to debug what happened with a peer recently.
+package timeline
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/holepunch"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+	logging "github.com/ipfs/go-log/v2"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+var log = logging.Logger("timeline")
+
+// EventType is the type of an Event.
+type EventType int
+
+const (
+	// DialStarted is recorded when the swarm starts dialing the peer.
+	DialStarted EventType = iota
+	// DialFailed is recorded when dialing the peer failed.
+	DialFailed
+	// Connected is recorded when a connection to the peer is opened.
+	Connected
+	// Disconnected is recorded when a connection to the peer is closed.
+	Disconnected
+	// HolePunchStarted is recorded when a hole punch with the peer starts.
+	HolePunchStarted
+	// HolePunchSucceeded is recorded when a hole punch with the peer
+	// resulted in a direct connection.
+	HolePunchSucceeded
+	// HolePunchFailed is recorded when a hole punch with the peer failed.
+	HolePunchFailed
+)
+
+func (t EventType) String() string {
+	switch t {
+	case DialStarted:
+		return "dial started"
+	case DialFailed:
+		return "dial failed"
+	case Connected:
+		return "connected"
+	case Disconnected:
+		return "disconnected"
+	case HolePunchStarted:
+		return "hole punch started"
+	case HolePunchSucceeded:
+		return "hole punch succeeded"
+	case HolePunchFailed:
+		return "hole punch failed"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a connection event of a peer.
+type Event struct {
+	Time time.Time
+	Type EventType
+	// Addr is the remote address of the connection, for Connected and
+	// Disconnected events.
+	Addr      ma.Multiaddr
+	Direction network.Direction
+	// Relayed is true if the connection is relayed.
+	Relayed bool
+	// Duration is how long the connection was open for Disconnected events,
+	// and how long the hole punch took for HolePunchSucceeded and
+	// HolePunchFailed events.
+	Duration time.Duration
+	// Err is the error of failed dials and hole punches, or the reason the
+	// connection was closed if it is known.
+	Err string
+}
+
+// Option configures a Timeline.
+type Option func(*Timeline) error
+
+// WithMaxEvents sets the number of events kept per peer. Defaults to 100.
+func WithMaxEvents(n int) Option {
+	return func(t *Timeline) error {
+		if n <= 0 {
+			return errors.New("max events must be positive")
+		}
+		t.maxEvents = n
+		return nil
+	}
+}
+
+// WithMaxPeers sets the number of peers whose events are kept. The events of
+// the peer that was least recently active are dropped first. Defaults to 1000.
+func WithMaxPeers(n int) Option {
+	return func(t *Timeline) error {
+		if n <= 0 {
+			return errors.New("max peers must be positive")
+		}
+		t.maxPeers = n
+		return nil
+	}
+}
+
+// WithMaxAge sets how long events are kept. Defaults to 1 hour.
+func WithMaxAge(d time.Duration) Option {
+	return func(t *Timeline) error {
+		if d <= 0 {
+			return errors.New("max age must be positive")
+		}
+		t.maxAge = d
+		return nil
+	}
+}
+
+// Timeline records the connection events of peers.
+type Timeline struct {
+	maxEvents int
+	maxPeers  int
+	maxAge    time.Duration
+
+	mx    sync.Mutex
+	peers *simplelru.LRU[peer.ID, []Event]
+}
+
+var _ holepunch.EventTracer = (*Timeline)(nil)
+
+// New creates a Timeline.
+func New(opts ...Option) (*Timeline, error) {
+	t := &Timeline{
+		maxEvents: 100,
+		maxPeers:  1000,
+		maxAge:    time.Hour,
+	}
+	for _, o := range opts {
+		if err := o(t); err != nil {
+			return nil, err
+		}
+	}
+	peers, err := simplelru.NewLRU[peer.ID, []Event](t.maxPeers, nil)
+	if err != nil {
+		return nil, err
+	}
+	t.peers = peers
+	return t, nil
+}
+
+// Record adds e to the events of p. The time of e is set to the current time
+// if it is zero.
+func (t *Timeline) Record(p peer.ID, e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	evts, _ := t.peers.Get(p)
+	evts = t.prune(evts, e.Time)
+	if len(evts) >= t.maxEvents {
+		evts = slices.Delete(evts, 0, len(evts)-t.maxEvents+1)
+	}
+	t.peers.Add(p, append(evts, e))
+}
+
+// prune removes the events older than maxAge. Events are sorted by time.
+func (t *Timeline) prune(evts []Event, now time.Time) []Event {
+	cutoff := now.Add(-t.maxAge)
+	i := 0
+	for i < len(evts) && evts[i].Time.Before(cutoff) {
+		i++
+	}
+	return evts[i:]
+}
+
+// Peer returns the events of p, oldest first.
+func (t *Timeline) Peer(p peer.ID) []Event {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	evts, ok := t.peers.Peek(p)
+	if !ok {
+		return nil
+	}
+	evts = t.prune(evts, time.Now())
+	if len(evts) == 0 {
+		t.peers.Remove(p)
+		return nil
+	}
+	return slices.Clone(evts)
+}
+
+// Peers returns the peers with recorded events, most recently active first.
+func (t *Timeline) Peers() []peer.ID {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	now := time.Now()
+	var peers []peer.ID
+	for _, p := range t.peers.Keys() {
+		evts, _ := t.peers.Peek(p)
+		if len(t.prune(evts, now)) > 0 {
+			peers = append(peers, p)
+		}
+	}
+	slices.Reverse(peers)
+	return peers
+}
+
+// Notifiee returns a network.Notifiee recording Connected and Disconnected
+// events. Register it with network.Network.Notify.
+func (t *Timeline) Notifiee() network.Notifiee {
+	return &network.NotifyBundle{
+		ConnectedF: func(_ network.Network, c network.Conn) {
+			t.Record(c.RemotePeer(), connEvent(Connected, c))
+		},
+		DisconnectedF: func(_ network.Network, c network.Conn) {
+			e := connEvent(Disconnected, c)
+			if opened := c.Stat().Opened; !opened.IsZero() {
+				e.Duration = time.Since(opened)
+			}
+			if cr, ok := c.(interface{ CloseReason() error }); ok {
+				if err := cr.CloseReason(); err != nil {
+					e.Err = err.Error()
+				}
+			}
+			t.Record(c.RemotePeer(), e)
+		},
+	}
+}
+
+// DialStarted records that the swarm started dialing p. Together with
+// DialFailed, it implements swarm.DialObserver; pass the Timeline to
+// swarm.WithDialObserver to record dials.
+func (t *Timeline) DialStarted(p peer.ID) {
+	t.Record(p, Event{Type: DialStarted})
+}
+
+// DialFailed records that dialing p failed.
+func (t *Timeline) DialFailed(p peer.ID, err error) {
+	t.Record(p, Event{Type: DialFailed, Err: err.Error()})
+}
+
+func connEvent(typ EventType, c network.Conn) Event {
+	return Event{
+		Type:      typ,
+		Addr:      c.RemoteMultiaddr(),
+		Direction: c.Stat().Direction,
+		Relayed:   isRelayAddr(c.RemoteMultiaddr()),
+	}
+}
+
+func isRelayAddr(a ma.Multiaddr) bool {
+	_, err := a.ValueForProtocol(ma.P_CIRCUIT)
+	return err == nil
+}
+
+// Trace records the hole punches traced by the holepunch service. Pass the
+// Timeline to holepunch.WithTracer to use it.
+func (t *Timeline) Trace(evt *holepunch.Event) {
+	e := Event{Time: time.Unix(0, evt.Timestamp)}
+	switch ev := evt.Evt.(type) {
+	case *holepunch.StartHolePunchEvt:
+		e.Type = HolePunchStarted
+	case *holepunch.EndHolePunchEvt:
+		e.Type = HolePunchFailed
+		if ev.Success {
+			e.Type = HolePunchSucceeded
+		}
+		e.Duration = ev.EllapsedTime
+		e.Err = ev.Error
+	default:
+		return
+	}
+	t.Record(evt.Remote, e)
+}
@@ -0,0 +1,117 @@
+package timeline
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/test"
+	"github.com/libp2p/go-libp2p/p2p/protocol/holepunch"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxEvents(t *testing.T) {
+	tl, err := New(WithMaxEvents(3))
+	require.NoError(t, err)
+	p := test.RandPeerIDFatal(t)
+	for i := 0; i < 5; i++ {
+		tl.Record(p, Event{Type: DialFailed, Err: string(rune('a' + i))})
+	}
+	evts := tl.Peer(p)
+	require.Len(t, evts, 3)
+	for i, e := range evts {
+		require.Equal(t, string(rune('c'+i)), e.Err)
+		require.False(t, e.Time.IsZero())
+	}
+}
+
+func TestMaxPeers(t *testing.T) {
+	tl, err := New(WithMaxPeers(2))
+	require.NoError(t, err)
+	p1, p2, p3 := test.RandPeerIDFatal(t), test.RandPeerIDFatal(t), test.RandPeerIDFatal(t)
+	tl.Record(p1, Event{Type: DialStarted})
+	tl.Record(p2, Event{Type: DialStarted})
+	tl.Record(p1, Event{Type: DialFailed})
+	tl.Record(p3, Event{Type: DialStarted})
+
+	// p2 was least recently active
+	require.Nil(t, tl.Peer(p2))
+	require.Equal(t, []peer.ID{p3, p1}, tl.Peers())
+	require.Len(t, tl.Peer(p1), 2)
+}
+
+func TestMaxAge(t *testing.T) {
+	tl, err := New(WithMaxAge(time.Hour))
+	require.NoError(t, err)
+	p := test.RandPeerIDFatal(t)
+	now := time.Now()
+	tl.Record(p, Event{Type: DialStarted, Time: now.Add(-2 * time.Hour)})
+	tl.Record(p, Event{Type: DialFailed, Time: now.Add(-time.Minute)})
+	evts := tl.Peer(p)
+	require.Len(t, evts, 1)
+	require.Equal(t, DialFailed, evts[0].Type)
+
+	p2 := test.RandPeerIDFatal(t)
+	tl.Record(p2, Event{Type: DialStarted, Time: now.Add(-2 * time.Hour)})
+	require.Nil(t, tl.Peer(p2))
+	require.Equal(t, []peer.ID{p}, tl.Peers())
+}
+
+func TestHolePunchTrace(t *testing.T) {
+	tl, err := New()
+	require.NoError(t, err)
+	p := test.RandPeerIDFatal(t)
+	tl.Trace(&holepunch.Event{Timestamp: time.Now().UnixNano(), Remote: p, Evt: &holepunch.StartHolePunchEvt{}})
+	tl.Trace(&holepunch.Event{Timestamp: time.Now().UnixNano(), Remote: p, Evt: &holepunch.HolePunchAttemptEvt{Attempt: 1}})
+	tl.Trace(&holepunch.Event{
+		Timestamp: time.Now().UnixNano(),
+		Remote:    p,
+		Evt:       &holepunch.EndHolePunchEvt{EllapsedTime: time.Second, Error: "timeout"},
+	})
+	evts := tl.Peer(p)
+	require.Len(t, evts, 2)
+	require.Equal(t, HolePunchStarted, evts[0].Type)
+	require.Equal(t, HolePunchFailed, evts[1].Type)
+	require.Equal(t, time.Second, evts[1].Duration)
+	require.Equal(t, "timeout", evts[1].Err)
+}
+
+func TestServeHTTP(t *testing.T) {
+	tl, err := New()
+	require.NoError(t, err)
+	p1, p2 := test.RandPeerIDFatal(t), test.RandPeerIDFatal(t)
+	addr := ma.StringCast("/ip4/1.2.3.4/tcp/1/p2p/" + p2.String() + "/p2p-circuit")
+	tl.Record(p1, Event{Type: Connected, Addr: addr, Relayed: true})
+	tl.Record(p2, Event{Type: DialFailed, Err: "no addresses"})
+
+	w := httptest.NewRecorder()
+	tl.ServeHTTP(w, httptest.NewRequest("GET", "/?peer="+p1.String(), nil))
+	require.Equal(t, 200, w.Code)
+	var evts []jsonEvent
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &evts))
+	require.Len(t, evts, 1)
+	require.Equal(t, "connected", evts[0].Type)
+	require.Equal(t, addr.String(), evts[0].Addr)
+	require.True(t, evts[0].Relayed)
+
+	w = httptest.NewRecorder()
+	tl.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	var all map[string][]jsonEvent
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &all))
+	require.Len(t, all, 2)
+	require.Equal(t, "dial failed", all[p2.String()][0].Type)
+	require.Equal(t, "no addresses", all[p2.String()][0].Err)
+
+	w = httptest.NewRecorder()
+	tl.ServeHTTP(w, httptest.NewRequest("GET", "/?peer=foo", nil))
+	require.Equal(t, 400, w.Code)
+}
+
+func TestIsRelayAddr(t *testing.T) {
+	require.True(t, isRelayAddr(ma.StringCast("/ip4/1.2.3.4/tcp/1/p2p/QmcgpsyWgH8Y8ajJz1Cu72KnS5uo2Aa2LpzU7kinSupXvB/p2p-circuit")))
+	require.False(t, isRelayAddr(ma.StringCast("/ip4/1.2.3.4/tcp/1")))
+}
@@ -0,0 +1,78 @@
+package libp2phttp
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	gostream "github.com/libp2p/go-libp2p/p2p/net/gostream"
+)
+
+// ErrNotStreamRequest is returned by HijackStream for requests that weren't
+// received over a libp2p stream.
+var ErrNotStreamRequest = errors.New("request was not received over a libp2p stream")
+
+// HijackStream lets an HTTP handler take over the libp2p stream a request was
+// received on, e.g. to implement WebSocket-style protocol upgrades. The
+// returned bufio.ReadWriter holds any data the HTTP server already read from
+// the stream. After a call to HijackStream, the HTTP server will not do
+// anything else with the stream, and the handler is responsible for closing
+// it.
+//
+// Streaming responses don't require hijacking: responses to requests received
+// over libp2p streams support http.Flusher, and writes block when the stream's
+// flow control window is exhausted, applying backpressure to the handler.
+func HijackStream(w http.ResponseWriter) (network.Stream, *bufio.ReadWriter, error) {
+	c, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	s, ok := gostream.UnderlyingStream(c)
+	if !ok {
+		c.Close()
+		return nil, nil, ErrNotStreamRequest
+	}
+	return s, rw, nil
+}
+
+// isUpgradeRequest returns true if the request asks the server to switch
+// protocols.
+func isUpgradeRequest(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, v := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgradedStream is the body of a 101 Switching Protocols response received
+// over a libp2p stream. Like the body returned by http.Transport, it
+// implements io.ReadWriteCloser.
+type upgradedStream struct {
+	io.Reader
+	s network.Stream
+}
+
+var _ io.ReadWriteCloser = &upgradedStream{}
+
+func (u *upgradedStream) Write(b []byte) (int, error) {
+	return u.s.Write(b)
+}
+
+func (u *upgradedStream) Close() error {
+	return u.s.Close()
+}
+
+// Stream returns the underlying libp2p stream.
+func (u *upgradedStream) Stream() network.Stream {
+	return u.s
+}
@@ -0,0 +1,177 @@
+package libp2phttp_test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/peer"
+	libp2phttp "github.com/libp2p/go-libp2p/p2p/http"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamingResponseOverStreams(t *testing.T) {
+	serverHost, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer serverHost.Close()
+
+	next := make(chan struct{})
+	httpHost := libp2phttp.Host{StreamHost: serverHost}
+	httpHost.SetHTTPHandler("/events", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "data: %d\n\n", i)
+			w.(http.Flusher).Flush()
+			// Only send the next event once the client received this one.
+			<-next
+		}
+	}))
+	go httpHost.Serve()
+	defer httpHost.Close()
+
+	clientStreamHost, err := libp2p.New(libp2p.NoListenAddrs)
+	require.NoError(t, err)
+	defer clientStreamHost.Close()
+	clientHTTPHost := libp2phttp.Host{StreamHost: clientStreamHost}
+	rt, err := clientHTTPHost.NewConstrainedRoundTripper(peer.AddrInfo{ID: serverHost.ID(), Addrs: serverHost.Addrs()})
+	require.NoError(t, err)
+
+	resp, err := (&http.Client{Transport: rt}).Get("/events/")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	r := bufio.NewReader(resp.Body)
+	for i := 0; i < 3; i++ {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("data: %d\n", i), line)
+		_, err = r.ReadString('\n')
+		require.NoError(t, err)
+		next <- struct{}{}
+	}
+	_, err = r.ReadString('\n')
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestHijackStream(t *testing.T) {
+	serverHost, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer serverHost.Close()
+
+	httpHost := libp2phttp.Host{StreamHost: serverHost}
+	httpHost.SetHTTPHandler("/echo", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "echo" {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+		s, rw, err := libp2phttp.HijackStream(w)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer s.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		rw.Flush()
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		rw.WriteString(line)
+		rw.Flush()
+	}))
+	go httpHost.Serve()
+	defer httpHost.Close()
+
+	clientStreamHost, err := libp2p.New(libp2p.NoListenAddrs)
+	require.NoError(t, err)
+	defer clientStreamHost.Close()
+	clientHTTPHost := libp2phttp.Host{StreamHost: clientStreamHost}
+	rt, err := clientHTTPHost.NewConstrainedRoundTripper(peer.AddrInfo{ID: serverHost.ID(), Addrs: serverHost.Addrs()})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, "/echo/", nil)
+	require.NoError(t, err)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "echo")
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	rwc, ok := resp.Body.(io.ReadWriteCloser)
+	require.True(t, ok)
+	defer rwc.Close()
+	_, err = rwc.Write([]byte("hello\n"))
+	require.NoError(t, err)
+	line, err := bufio.NewReader(rwc).ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "hello\n", line)
+}
+
+// TestUpgradeRefused checks that the client only closes the stream for
+// writing once the request body was written when the server refuses an
+// upgrade, even if the response arrives first.
+func TestUpgradeRefused(t *testing.T) {
+	serverHost, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer serverHost.Close()
+
+	const bodySize = 1 << 20
+	received := make(chan int64, 1)
+	httpHost := libp2phttp.Host{StreamHost: serverHost}
+	httpHost.SetHTTPHandler("/echo", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := http.NewResponseController(w).EnableFullDuplex(); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusUpgradeRequired)
+		w.(http.Flusher).Flush()
+		n, _ := io.Copy(io.Discard, r.Body)
+		received <- n
+	}))
+	go httpHost.Serve()
+	defer httpHost.Close()
+
+	clientStreamHost, err := libp2p.New(libp2p.NoListenAddrs)
+	require.NoError(t, err)
+	defer clientStreamHost.Close()
+	clientHTTPHost := libp2phttp.Host{StreamHost: clientStreamHost}
+	rt, err := clientHTTPHost.NewConstrainedRoundTripper(peer.AddrInfo{ID: serverHost.ID(), Addrs: serverHost.Addrs()})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, "/echo/", bytes.NewReader(make([]byte, bodySize)))
+	require.NoError(t, err)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "echo")
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusUpgradeRequired, resp.StatusCode)
+	select {
+	case n := <-received:
+		require.Equal(t, int64(bodySize), n)
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting for the request body")
+	}
+}
+
+func TestHijackStreamPlainHTTP(t *testing.T) {
+	errCh := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _, err := libp2phttp.HijackStream(w)
+		errCh <- err
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err == nil {
+		resp.Body.Close()
+	}
+	require.ErrorIs(t, <-errCh, libp2phttp.ErrNotStreamRequest)
+}
@@ -18,7 +18,6 @@ import (
 	"sync"
 	"time"
 
-	lru "github.com/hashicorp/golang-lru/v2"
 	logging "github.com/ipfs/go-log/v2"
 	host "github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
@@ -170,6 +169,8 @@ type Host struct {
 	// also update the WellKnownHandler's protocol mapping.
 	ServeMux           *http.ServeMux
 	initializeServeMux sync.Once
+	// middlewares wrap the ServeMux. See Use.
+	middlewares []Middleware
 
 	// DefaultClientRoundTripper is the default http.RoundTripper for clients to
 	// use when making requests over an HTTP transport. This must be an
@@ -196,8 +197,15 @@ type Host struct {
 	// newer go-libp2p version and we can remove all this code.
 	EnableCompatibilityWithLegacyWellKnownEndpoint bool
 
+	// PeerMetadataTTL is how long a peer's well-known protocol map is cached.
+	// If zero, cached protocol maps don't expire, but may still be evicted
+	// from the cache. Use RemovePeerMetadata or PurgePeerMetadata to
+	// invalidate cached protocol maps.
+	PeerMetadataTTL time.Duration
+
 	// peerMetadata is an LRU cache of a peer's well-known protocol map.
-	peerMetadata *lru.Cache[peer.ID, PeerMeta]
+	peerMetadata       *peerMetadataCache
+	createPeerMetadata sync.Once
 	// createHTTPTransport is used to lazily create the httpTransport in a thread-safe way.
 	createHTTPTransport sync.Once
 	// createDefaultClientRoundTripper is used to lazily create the default
@@ -213,15 +221,6 @@ type httpTransport struct {
 	waitingForListeners chan struct{}
 }
 
-func newPeerMetadataCache() *lru.Cache[peer.ID, PeerMeta] {
-	peerMetadata, err := lru.New[peer.ID, PeerMeta](peerMetadataLRUSize)
-	if err != nil {
-		// Only happens if size is < 1. We make sure to not do that, so this should never happen.
-		panic(err)
-	}
-	return peerMetadata
-}
-
 func (h *Host) httpTransportInit() {
 	h.createHTTPTransport.Do(func() {
 		h.httpTransport = &httpTransport{
@@ -231,6 +230,12 @@ func (h *Host) httpTransportInit() {
 	})
 }
 
+func (h *Host) peerMetadataInit() {
+	h.createPeerMetadata.Do(func() {
+		h.peerMetadata = newPeerMetadataCache(h.PeerMetadataTTL)
+	})
+}
+
 func (h *Host) serveMuxInit() {
 	h.initializeServeMux.Do(func() {
 		if h.ServeMux == nil {
@@ -255,7 +260,7 @@ func (h *Host) PeerID() peer.ID {
 
 var ErrNoListeners = errors.New("nothing to listen on")
 
-func (h *Host) setupListeners(listenerErrCh chan error) error {
+func (h *Host) setupListeners(handler http.Handler, listenerErrCh chan error) error {
 	for _, addr := range h.ListenAddrs {
 		parsedAddr, err := parseMultiaddr(addr)
 		if err != nil {
@@ -294,7 +299,7 @@ func (h *Host) setupListeners(listenerErrCh chan error) error {
 		if parsedAddr.useHTTPS {
 			go func() {
 				srv := http.Server{
-					Handler:   maybeDecorateContextWithAuthMiddleware(h.ServerPeerIDAuth, h.ServeMux),
+					Handler:   maybeDecorateContextWithAuthMiddleware(h.ServerPeerIDAuth, handler),
 					TLSConfig: h.TLSConfig,
 				}
 				listenerErrCh <- srv.ServeTLS(l, "", "")
@@ -303,7 +308,7 @@ func (h *Host) setupListeners(listenerErrCh chan error) error {
 		} else if h.InsecureAllowHTTP {
 			go func() {
 				srv := http.Server{
-					Handler: maybeDecorateContextWithAuthMiddleware(h.ServerPeerIDAuth, h.ServeMux),
+					Handler: maybeDecorateContextWithAuthMiddleware(h.ServerPeerIDAuth, handler),
 				}
 				listenerErrCh <- srv.Serve(l)
 			}()
@@ -333,6 +338,8 @@ func (h *Host) Serve() error {
 		h.ServeMux.Handle(LegacyWellKnownProtocols, &h.WellKnownHandler)
 	}
 
+	handler := h.handler()
+
 	h.httpTransportInit()
 
 	closedWaitingForListeners := false
@@ -366,7 +373,7 @@ func (h *Host) Serve() error {
 
 		go func() {
 			srv := &http.Server{
-				Handler: connectionCloseHeaderMiddleware(h.ServeMux),
+				Handler: connectionCloseHeaderMiddleware(handler),
 				ConnContext: func(ctx context.Context, c net.Conn) context.Context {
 					remote := c.RemoteAddr()
 					if remote.Network() == gostream.Network {
@@ -388,7 +395,7 @@ func (h *Host) Serve() error {
 		}
 	}
 
-	err := h.setupListeners(errCh)
+	err := h.setupListeners(handler, errCh)
 	if err != nil {
 		closeAllListeners()
 		return err
@@ -488,7 +495,10 @@ func (rt *streamRoundTripper) GetPeerMetadata() (PeerMeta, error) {
 	return rt.httpHost.getAndStorePeerMetadata(ctx, rt, rt.server)
 }
 
-// RoundTrip implements http.RoundTripper.
+// RoundTrip implements http.RoundTripper. The response body is read from the
+// stream as the server sends it, so it's suitable for streaming responses. If
+// the server switches protocols, the body of the 101 response implements
+// io.ReadWriteCloser, as it does for http.Transport.
 func (rt *streamRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
 	// Add the addresses we learned about for this server
 	if !rt.skipAddAddrs {
@@ -514,27 +524,53 @@ func (rt *streamRoundTripper) RoundTrip(r *http.Request) (*http.Response, error)
 		return nil, err
 	}
 
-	// Write connection: close header to ensure the stream is closed after the response
-	r.Header.Add("connection", "close")
+	// Upgrade requests keep the stream open in both directions once the
+	// server switched protocols.
+	upgrade := isUpgradeRequest(r)
+	if !upgrade {
+		// Write connection: close header to ensure the stream is closed after the response
+		r.Header.Add("connection", "close")
+	}
 
+	// For upgrade requests, closeWrite tells the writer whether to close the
+	// stream for writing once the request is written, depending on whether
+	// the server switched protocols.
+	var closeWrite chan bool
+	if upgrade {
+		closeWrite = make(chan bool, 1)
+	}
 	go func() {
-		defer s.CloseWrite()
 		r.Write(s)
 		if r.Body != nil {
 			r.Body.Close()
 		}
+		if !upgrade || <-closeWrite {
+			s.CloseWrite()
+		}
 	}()
 
 	if deadline, ok := r.Context().Deadline(); ok {
 		s.SetReadDeadline(deadline)
 	}
 
-	resp, err := http.ReadResponse(bufio.NewReader(s), r)
+	br := bufio.NewReader(s)
+	resp, err := http.ReadResponse(br, r)
 	if err != nil {
+		if upgrade {
+			closeWrite <- false
+		}
 		s.Close()
 		return nil, err
 	}
-	resp.Body = &streamReadCloser{resp.Body, s}
+	switchedProtocols := resp.StatusCode == http.StatusSwitchingProtocols
+	if upgrade {
+		closeWrite <- !switchedProtocols
+	}
+	if switchedProtocols {
+		resp.Body = &upgradedStream{Reader: br, s: s}
+	} else {
+		resp.Body = &streamReadCloser{resp.Body, s}
+	}
 
 	if r.URL.Scheme == "multiaddr" {
 		// This was a multiaddr uri, we may need to convert relative URI
@@ -635,7 +671,9 @@ type roundTripperForSpecificServer struct {
 	targetServerAddr string
 	sni              string
 	scheme           string
-	cachedProtos     PeerMeta
+	// cachedProtos caches the protocol mapping if the server's peer ID is
+	// unknown. Otherwise, the mapping is cached by the httpHost.
+	cachedProtos PeerMeta
 }
 
 func (rt *roundTripperForSpecificServer) GetPeerMetadata() (PeerMeta, error) {
@@ -648,7 +686,7 @@ func (rt *roundTripperForSpecificServer) GetPeerMetadata() (PeerMeta, error) {
 	if g, ok := rt.RoundTripper.(PeerMetadataGetter); ok {
 		wk, err := g.GetPeerMetadata()
 		if err == nil {
-			rt.cachedProtos = wk
+			rt.cacheProtos(wk)
 			return wk, nil
 		}
 	}
@@ -658,12 +696,20 @@ func (rt *roundTripperForSpecificServer) GetPeerMetadata() (PeerMeta, error) {
 	defer cancel()
 	wk, err := rt.httpHost.getAndStorePeerMetadata(ctx, rt, rt.server)
 	if err == nil {
-		rt.cachedProtos = wk
+		rt.cacheProtos(wk)
 		return wk, nil
 	}
 	return wk, err
 }
 
+func (rt *roundTripperForSpecificServer) cacheProtos(meta PeerMeta) {
+	if rt.server == "" {
+		rt.cachedProtos = meta
+		return
+	}
+	rt.httpHost.SetPeerMetadata(rt.server, meta)
+}
+
 // RoundTrip implements http.RoundTripper.
 func (rt *roundTripperForSpecificServer) RoundTrip(r *http.Request) (*http.Response, error) {
 	if (r.URL.Scheme != "" && r.URL.Scheme != rt.scheme) || (r.URL.Host != "" && r.URL.Host != rt.targetServerAddr) {
@@ -1082,9 +1128,7 @@ func normalizeHTTPMultiaddr(addr ma.Multiaddr) (ma.Multiaddr, bool) {
 // returns it. Will only store the peer's protocol mapping if the server ID is
 // provided.
 func (h *Host) getAndStorePeerMetadata(ctx context.Context, roundtripper http.RoundTripper, server peer.ID) (PeerMeta, error) {
-	if h.peerMetadata == nil {
-		h.peerMetadata = newPeerMetadataCache()
-	}
+	h.peerMetadataInit()
 	if meta, ok := h.peerMetadata.Get(server); server != "" && ok {
 		return meta, nil
 	}
@@ -1175,18 +1219,14 @@ func requestPeerMeta(ctx context.Context, roundtripper http.RoundTripper, wellKn
 // SetPeerMetadata adds a peer's protocol metadata to the http host. Useful if
 // you have out-of-band knowledge of a peer's protocol mapping.
 func (h *Host) SetPeerMetadata(server peer.ID, meta PeerMeta) {
-	if h.peerMetadata == nil {
-		h.peerMetadata = newPeerMetadataCache()
-	}
+	h.peerMetadataInit()
 	h.peerMetadata.Add(server, meta)
 }
 
 // AddPeerMetadata merges the given peer's protocol metadata to the http host. Useful if
 // you have out-of-band knowledge of a peer's protocol mapping.
 func (h *Host) AddPeerMetadata(server peer.ID, meta PeerMeta) {
-	if h.peerMetadata == nil {
-		h.peerMetadata = newPeerMetadataCache()
-	}
+	h.peerMetadataInit()
 	origMeta, ok := h.peerMetadata.Get(server)
 	if !ok {
 		h.peerMetadata.Add(server, meta)
@@ -1200,17 +1240,13 @@ func (h *Host) AddPeerMetadata(server peer.ID, meta PeerMeta) {
 
 // GetPeerMetadata gets a peer's cached protocol metadata from the http host.
 func (h *Host) GetPeerMetadata(server peer.ID) (PeerMeta, bool) {
-	if h.peerMetadata == nil {
-		return nil, false
-	}
+	h.peerMetadataInit()
 	return h.peerMetadata.Get(server)
 }
 
 // RemovePeerMetadata removes a peer's protocol metadata from the http host
 func (h *Host) RemovePeerMetadata(server peer.ID) {
-	if h.peerMetadata == nil {
-		return
-	}
+	h.peerMetadataInit()
 	h.peerMetadata.Remove(server)
 }
 
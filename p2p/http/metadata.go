@@ -0,0 +1,147 @@
+package libp2phttp
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+)
+
+// maxConcurrentPrefetches limits the number of well-known resource requests
+// made in the background when prefetching on connect.
+const maxConcurrentPrefetches = 8
+
+type peerMetaEntry struct {
+	meta PeerMeta
+	// expires is the zero value if the entry doesn't expire.
+	expires time.Time
+}
+
+// peerMetadataCache is an LRU cache of peers' protocol maps whose entries
+// optionally expire after a TTL.
+type peerMetadataCache struct {
+	ttl   time.Duration
+	cache *lru.Cache[peer.ID, peerMetaEntry]
+}
+
+func newPeerMetadataCache(ttl time.Duration) *peerMetadataCache {
+	cache, err := lru.New[peer.ID, peerMetaEntry](peerMetadataLRUSize)
+	if err != nil {
+		// Only happens if size is < 1. We make sure to not do that, so this should never happen.
+		panic(err)
+	}
+	return &peerMetadataCache{ttl: ttl, cache: cache}
+}
+
+func (c *peerMetadataCache) Get(p peer.ID) (PeerMeta, bool) {
+	e, ok := c.cache.Get(p)
+	if !ok {
+		return nil, false
+	}
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		c.cache.Remove(p)
+		return nil, false
+	}
+	return e.meta, true
+}
+
+func (c *peerMetadataCache) Add(p peer.ID, meta PeerMeta) {
+	e := peerMetaEntry{meta: meta}
+	if c.ttl > 0 {
+		e.expires = time.Now().Add(c.ttl)
+	}
+	c.cache.Add(p, e)
+}
+
+func (c *peerMetadataCache) Remove(p peer.ID) {
+	c.cache.Remove(p)
+}
+
+func (c *peerMetadataCache) Purge() {
+	c.cache.Purge()
+}
+
+// PurgePeerMetadata removes all peers' cached protocol metadata from the http
+// host.
+func (h *Host) PurgePeerMetadata() {
+	h.peerMetadataInit()
+	h.peerMetadata.Purge()
+}
+
+// PrefetchPeerMetadata fetches the server's well-known protocol map and caches
+// it, so later requests to the server don't have to start with a well-known
+// resource round trip. Cached metadata is returned without making a request.
+func (h *Host) PrefetchPeerMetadata(ctx context.Context, server peer.AddrInfo, opts ...RoundTripperOption) (PeerMeta, error) {
+	if server.ID == "" {
+		return nil, errors.New("can not prefetch metadata without a server peer ID")
+	}
+	if meta, ok := h.GetPeerMetadata(server.ID); ok {
+		return meta, nil
+	}
+	rt, err := h.NewConstrainedRoundTripper(server, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return h.getAndStorePeerMetadata(ctx, rt, server.ID)
+}
+
+// PrefetchPeerMetadataOnConnect makes the http host fetch the protocol
+// metadata of every peer the StreamHost connects to that supports HTTP over
+// libp2p streams. Prefetching stops when the http host is closed.
+func (h *Host) PrefetchPeerMetadataOnConnect() error {
+	if h.StreamHost == nil {
+		return errors.New("can not prefetch metadata on connect without a StreamHost")
+	}
+	sub, err := h.StreamHost.EventBus().Subscribe(new(event.EvtPeerIdentificationCompleted), eventbus.Name("libp2phttp (prefetch)"))
+	if err != nil {
+		return err
+	}
+	h.httpTransportInit()
+	closing := h.httpTransport.closeListeners
+
+	go func() {
+		defer sub.Close()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sem := make(chan struct{}, maxConcurrentPrefetches)
+		for {
+			var e interface{}
+			select {
+			case ev, ok := <-sub.Out():
+				if !ok {
+					return
+				}
+				e = ev
+			case <-closing:
+				return
+			}
+			evt := e.(event.EvtPeerIdentificationCompleted)
+			if !slices.Contains(evt.Protocols, ProtocolIDForMultistreamSelect) {
+				continue
+			}
+			if _, ok := h.GetPeerMetadata(evt.Peer); ok {
+				continue
+			}
+			select {
+			case sem <- struct{}{}:
+			default:
+				log.Debugf("too many concurrent prefetches, not prefetching metadata of %s", evt.Peer)
+				continue
+			}
+			go func() {
+				defer func() { <-sem }()
+				ctx, cancel := context.WithTimeout(ctx, WellKnownRequestTimeout)
+				defer cancel()
+				if _, err := h.PrefetchPeerMetadata(ctx, peer.AddrInfo{ID: evt.Peer}, ServerMustAuthenticatePeerID); err != nil {
+					log.Debugf("failed to prefetch metadata of %s: %s", evt.Peer, err)
+				}
+			}()
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,66 @@
+package libp2phttp_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/peer"
+	libp2phttp "github.com/libp2p/go-libp2p/p2p/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeerMetadataTTL(t *testing.T) {
+	h := libp2phttp.Host{PeerMetadataTTL: 50 * time.Millisecond}
+	meta := libp2phttp.PeerMeta{"/hello": {Path: "/hello/"}}
+	h.SetPeerMetadata("a", meta)
+	h.SetPeerMetadata("b", meta)
+
+	got, ok := h.GetPeerMetadata("a")
+	require.True(t, ok)
+	require.Equal(t, meta, got)
+
+	h.RemovePeerMetadata("a")
+	_, ok = h.GetPeerMetadata("a")
+	require.False(t, ok)
+
+	require.Eventually(t, func() bool {
+		_, ok := h.GetPeerMetadata("b")
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+
+	h.SetPeerMetadata("c", meta)
+	h.PurgePeerMetadata()
+	_, ok = h.GetPeerMetadata("c")
+	require.False(t, ok)
+}
+
+func TestPrefetchPeerMetadataOnConnect(t *testing.T) {
+	serverHost, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer serverHost.Close()
+
+	httpHost := libp2phttp.Host{StreamHost: serverHost}
+	httpHost.SetHTTPHandler("/hello", nil)
+	go httpHost.Serve()
+	defer httpHost.Close()
+
+	clientStreamHost, err := libp2p.New(libp2p.NoListenAddrs)
+	require.NoError(t, err)
+	defer clientStreamHost.Close()
+	clientHTTPHost := libp2phttp.Host{StreamHost: clientStreamHost}
+	defer clientHTTPHost.Close()
+	require.NoError(t, clientHTTPHost.PrefetchPeerMetadataOnConnect())
+
+	// Wait for the server to start listening for HTTP over streams.
+	httpHost.Addrs()
+	require.NoError(t, clientStreamHost.Connect(context.Background(), peer.AddrInfo{ID: serverHost.ID(), Addrs: serverHost.Addrs()}))
+
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		meta, ok := clientHTTPHost.GetPeerMetadata(serverHost.ID())
+		assert.True(c, ok)
+		assert.Equal(c, "/hello/", meta["/hello"].Path)
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,70 @@
+package libp2phttp
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Middleware is a standard net/http middleware.
+type Middleware func(http.Handler) http.Handler
+
+// Use registers middleware that wraps every request served by the host, on
+// both the libp2p stream transport and the HTTP transports. Middleware is
+// applied in the order given, so the first middleware is the outermost one.
+// The authenticated peer ID of the client, if any, is available to
+// middleware through ClientPeerID or PeerIDFromContext.
+//
+// Use must be called before Serve.
+func (h *Host) Use(mws ...Middleware) {
+	h.middlewares = append(h.middlewares, mws...)
+}
+
+// handler returns the ServeMux wrapped in the registered middleware.
+func (h *Host) handler() http.Handler {
+	var handler http.Handler = h.ServeMux
+	for i := len(h.middlewares) - 1; i >= 0; i-- {
+		handler = h.middlewares[i](handler)
+	}
+	return handler
+}
+
+// PeerIDFromContext returns the authenticated peer ID of the client that made
+// the request the context belongs to. The peer ID is set for requests
+// received over libp2p streams, and for requests authenticated using the
+// host's ServerPeerIDAuth.
+func PeerIDFromContext(ctx context.Context) (peer.ID, bool) {
+	id, ok := ctx.Value(clientPeerIDContextKey{}).(peer.ID)
+	return id, ok && id != ""
+}
+
+// PeerIDHandlerFunc is an http.Handler for requests from authenticated peers.
+// Requests without an authenticated peer ID are rejected with 401
+// Unauthorized.
+type PeerIDHandlerFunc func(p peer.ID, w http.ResponseWriter, r *http.Request)
+
+func (f PeerIDHandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p, ok := PeerIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "peer ID authentication required", http.StatusUnauthorized)
+		return
+	}
+	f(p, w, r)
+}
+
+// AllowPeers returns middleware that only passes on requests from
+// authenticated peers for which allow returns true. Requests without an
+// authenticated peer ID are rejected with 401 Unauthorized, and requests from
+// other peers with 403 Forbidden.
+func AllowPeers(allow func(peer.ID) bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		return PeerIDHandlerFunc(func(p peer.ID, w http.ResponseWriter, r *http.Request) {
+			if !allow(p) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package libp2phttp_test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/peer"
+	libp2phttp "github.com/libp2p/go-libp2p/p2p/http"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	serverHost, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer serverHost.Close()
+
+	allowedHost, err := libp2p.New(libp2p.NoListenAddrs)
+	require.NoError(t, err)
+	defer allowedHost.Close()
+	deniedHost, err := libp2p.New(libp2p.NoListenAddrs)
+	require.NoError(t, err)
+	defer deniedHost.Close()
+
+	httpHost := libp2phttp.Host{
+		StreamHost:        serverHost,
+		InsecureAllowHTTP: true,
+		ListenAddrs:       []ma.Multiaddr{ma.StringCast("/ip4/127.0.0.1/tcp/0/http")},
+	}
+	var order []string
+	httpHost.Use(
+		func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, "outer")
+				w.Header().Set("X-Middleware", "outer")
+				next.ServeHTTP(w, r)
+			})
+		},
+		libp2phttp.AllowPeers(func(p peer.ID) bool { return p == allowedHost.ID() }),
+	)
+	httpHost.SetHTTPHandler("/echo-id", libp2phttp.PeerIDHandlerFunc(func(p peer.ID, w http.ResponseWriter, _ *http.Request) {
+		order = append(order, "handler")
+		w.Write([]byte(p.String()))
+	}))
+	go httpHost.Serve()
+	defer httpHost.Close()
+
+	var httpAddr ma.Multiaddr
+	for _, a := range httpHost.Addrs() {
+		if _, err := a.ValueForProtocol(ma.P_HTTP); err == nil {
+			httpAddr = a
+		}
+	}
+	require.NotNil(t, httpAddr)
+
+	get := func(clientHost *libp2phttp.Host, addr ma.Multiaddr) *http.Response {
+		t.Helper()
+		rt, err := clientHost.NewConstrainedRoundTripper(peer.AddrInfo{ID: serverHost.ID(), Addrs: []ma.Multiaddr{addr}})
+		require.NoError(t, err)
+		resp, err := (&http.Client{Transport: rt}).Get("/echo-id/")
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := get(&libp2phttp.Host{StreamHost: allowedHost}, serverHost.Addrs()[0])
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "outer", resp.Header.Get("X-Middleware"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, allowedHost.ID().String(), string(body))
+	require.Equal(t, []string{"outer", "handler"}, order)
+
+	resp = get(&libp2phttp.Host{StreamHost: deniedHost}, serverHost.Addrs()[0])
+	resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// Plain HTTP requests aren't authenticated.
+	resp = get(&libp2phttp.Host{}, httpAddr)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.Equal(t, "outer", resp.Header.Get("X-Middleware"))
+}
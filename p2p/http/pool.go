@@ -0,0 +1,256 @@
+package libp2phttp
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// DefaultRoundTripperPoolSize is the default number of peers a RoundTripperPool
+// keeps round trippers for.
+const DefaultRoundTripperPoolSize = 256
+
+// RoundTripperPoolStats are counters describing the usage of a RoundTripperPool.
+type RoundTripperPoolStats struct {
+	// Size is the number of peers with a cached round tripper.
+	Size int
+	// Hits is the number of lookups served from the cache.
+	Hits uint64
+	// Misses is the number of lookups that created a new round tripper.
+	Misses uint64
+	// Evictions is the number of round trippers removed from the pool.
+	Evictions uint64
+	// Requests is the number of requests made through pooled round trippers.
+	Requests uint64
+	// Failures is the number of requests that failed with an error.
+	Failures uint64
+	// Redials is the number of requests retried after a failure to reach the
+	// peer over a libp2p stream.
+	Redials uint64
+}
+
+// RoundTripperPool caches an http.RoundTripper per peer, so that clients making
+// many requests to the same peers don't pay the round tripper setup cost on
+// every request. Round trippers backed by libp2p streams keep the peer's
+// addresses around and redial the peer if the connection was lost in between
+// requests.
+type RoundTripperPool struct {
+	host *Host
+	opts []RoundTripperOption
+
+	mu  sync.Mutex
+	rts *lru.Cache[peer.ID, *pooledRoundTripper]
+
+	hits, misses, evictions    atomic.Uint64
+	requests, failures, redial atomic.Uint64
+}
+
+// NewRoundTripperPool returns a pool that keeps round trippers for up to size
+// peers, evicting the least recently used ones. If size is 0,
+// DefaultRoundTripperPoolSize is used. The options are applied to every round
+// tripper created by the pool.
+func (h *Host) NewRoundTripperPool(size int, opts ...RoundTripperOption) (*RoundTripperPool, error) {
+	if size < 0 {
+		return nil, errors.New("round tripper pool size must not be negative")
+	}
+	if size == 0 {
+		size = DefaultRoundTripperPoolSize
+	}
+	p := &RoundTripperPool{host: h, opts: opts}
+	rts, err := lru.NewWithEvict(size, func(_ peer.ID, rt *pooledRoundTripper) {
+		p.evictions.Add(1)
+		rt.closeIdleConnections()
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.rts = rts
+	return p, nil
+}
+
+// RoundTripper returns the pooled round tripper for the server, creating it if
+// necessary. Addresses passed for a server that's already in the pool are
+// added to the addresses used for redialing it.
+func (p *RoundTripperPool) RoundTripper(server peer.AddrInfo) (http.RoundTripper, error) {
+	if server.ID == "" {
+		return nil, errors.New("round tripper pool requires a server peer ID")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if rt, ok := p.rts.Get(server.ID); ok {
+		p.hits.Add(1)
+		rt.addAddrs(server.Addrs)
+		return rt, nil
+	}
+	p.misses.Add(1)
+
+	inner, err := p.host.NewConstrainedRoundTripper(server, p.opts...)
+	if err != nil {
+		return nil, err
+	}
+	rt := &pooledRoundTripper{RoundTripper: inner, pool: p, server: server.ID}
+	if srt, ok := inner.(*streamRoundTripper); ok {
+		// The pooled round tripper manages the server's addresses itself.
+		srt.skipAddAddrs = true
+		rt.stream = srt
+		rt.addAddrs(server.Addrs)
+	}
+	p.rts.Add(server.ID, rt)
+	return rt, nil
+}
+
+// Client returns an http.Client using the pooled round tripper for the server.
+func (p *RoundTripperPool) Client(server peer.AddrInfo) (*http.Client, error) {
+	rt, err := p.RoundTripper(server)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: rt}, nil
+}
+
+// Remove drops the round tripper for the given peer from the pool.
+func (p *RoundTripperPool) Remove(server peer.ID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rts.Remove(server)
+}
+
+// Close removes all round trippers from the pool and closes their idle
+// connections.
+func (p *RoundTripperPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rts.Purge()
+	return nil
+}
+
+// Stats returns the pool's current statistics.
+func (p *RoundTripperPool) Stats() RoundTripperPoolStats {
+	return RoundTripperPoolStats{
+		Size:      p.rts.Len(),
+		Hits:      p.hits.Load(),
+		Misses:    p.misses.Load(),
+		Evictions: p.evictions.Load(),
+		Requests:  p.requests.Load(),
+		Failures:  p.failures.Load(),
+		Redials:   p.redial.Load(),
+	}
+}
+
+type pooledRoundTripper struct {
+	http.RoundTripper
+	pool   *RoundTripperPool
+	server peer.ID
+	// stream is set if the round tripper uses libp2p streams.
+	stream *streamRoundTripper
+
+	addrsMu sync.Mutex
+	addrs   []ma.Multiaddr
+}
+
+func (rt *pooledRoundTripper) addAddrs(addrs []ma.Multiaddr) {
+	if rt.stream == nil {
+		return
+	}
+	rt.addrsMu.Lock()
+	defer rt.addrsMu.Unlock()
+	for _, a := range addrs {
+		if _, isHTTP := normalizeHTTPMultiaddr(a); isHTTP {
+			continue
+		}
+		if !ma.Contains(rt.addrs, a) {
+			rt.addrs = append(rt.addrs, a)
+		}
+	}
+}
+
+// refreshAddrs makes sure we can redial the server if we're not connected to
+// it anymore. Addresses are added with a temporary TTL, so they may have
+// expired since the round tripper was created.
+func (rt *pooledRoundTripper) refreshAddrs() {
+	h := rt.stream.h
+	if h.Network().Connectedness(rt.server) == network.Connected {
+		return
+	}
+	rt.addrsMu.Lock()
+	addrs := rt.addrs
+	rt.addrsMu.Unlock()
+	if len(addrs) > 0 {
+		h.Peerstore().AddAddrs(rt.server, addrs, peerstore.TempAddrTTL)
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rt *pooledRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	rt.pool.requests.Add(1)
+	if rt.stream == nil {
+		resp, err := rt.RoundTripper.RoundTrip(r)
+		if err != nil {
+			rt.pool.failures.Add(1)
+		}
+		return resp, err
+	}
+
+	rt.refreshAddrs()
+	// The stream round tripper writes the request asynchronously, so keep
+	// our own copy in case we have to send it again.
+	retry := r.Clone(r.Context())
+	if r.Body != nil && r.Body != http.NoBody {
+		retry = nil
+		if r.GetBody != nil {
+			if body, err := r.GetBody(); err == nil {
+				retry = r.Clone(r.Context())
+				retry.Body = body
+			}
+		}
+	}
+	resp, err := rt.RoundTripper.RoundTrip(r)
+	if err == nil {
+		return resp, nil
+	}
+	if retry == nil || r.Context().Err() != nil || !isIdempotent(r.Method) {
+		rt.pool.failures.Add(1)
+		return nil, err
+	}
+
+	// The connection may have died without us noticing. Try once more, which
+	// redials the server if needed.
+	log.Debugf("pooled request to %s failed, retrying: %s", rt.server, err)
+	rt.pool.redial.Add(1)
+	rt.refreshAddrs()
+	resp, err = rt.RoundTripper.RoundTrip(retry)
+	if err != nil {
+		rt.pool.failures.Add(1)
+	}
+	return resp, err
+}
+
+// GetPeerMetadata implements PeerMetadataGetter.
+func (rt *pooledRoundTripper) GetPeerMetadata() (PeerMeta, error) {
+	if g, ok := rt.RoundTripper.(PeerMetadataGetter); ok {
+		return g.GetPeerMetadata()
+	}
+	return nil, errors.New("can not get peer protocol map. Inner roundtripper does not implement GetPeerMetadata")
+}
+
+func (rt *pooledRoundTripper) closeIdleConnections() {
+	if c, ok := rt.RoundTripper.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
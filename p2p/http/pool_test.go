@@ -0,0 +1,75 @@
+package libp2phttp_test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/peer"
+	libp2phttp "github.com/libp2p/go-libp2p/p2p/http"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTripperPool(t *testing.T) {
+	serverHost, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer serverHost.Close()
+
+	httpHost := libp2phttp.Host{StreamHost: serverHost}
+	httpHost.SetHTTPHandler("/hello", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	go httpHost.Serve()
+	defer httpHost.Close()
+
+	clientStreamHost, err := libp2p.New(libp2p.NoListenAddrs)
+	require.NoError(t, err)
+	defer clientStreamHost.Close()
+	clientHTTPHost := libp2phttp.Host{StreamHost: clientStreamHost}
+
+	pool, err := clientHTTPHost.NewRoundTripperPool(1)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	server := peer.AddrInfo{ID: serverHost.ID(), Addrs: serverHost.Addrs()}
+	get := func() {
+		t.Helper()
+		client, err := pool.Client(server)
+		require.NoError(t, err)
+		resp, err := client.Get("/hello/")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, "hello", string(body))
+	}
+
+	get()
+	get()
+	stats := pool.Stats()
+	require.Equal(t, 1, stats.Size)
+	require.Equal(t, uint64(1), stats.Misses)
+	require.Equal(t, uint64(1), stats.Hits)
+	require.Equal(t, uint64(2), stats.Requests)
+
+	// The pool redials the server after the connection is gone, even if the
+	// peerstore forgot its addresses.
+	require.NoError(t, clientStreamHost.Network().ClosePeer(serverHost.ID()))
+	clientStreamHost.Peerstore().ClearAddrs(serverHost.ID())
+	get()
+	require.Zero(t, pool.Stats().Failures)
+
+	// A different peer evicts the first one.
+	otherHost, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer otherHost.Close()
+	_, err = pool.RoundTripper(peer.AddrInfo{ID: otherHost.ID(), Addrs: otherHost.Addrs()})
+	require.NoError(t, err)
+	stats = pool.Stats()
+	require.Equal(t, 1, stats.Size)
+	require.Equal(t, uint64(1), stats.Evictions)
+
+	pool.Remove(otherHost.ID())
+	require.Zero(t, pool.Stats().Size)
+}
@@ -0,0 +1,86 @@
+package libp2phttp
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// DefaultPeerIDHeader is the header a reverse proxy uses to tell the backend
+// the authenticated peer ID of the client.
+const DefaultPeerIDHeader = "Libp2p-Peer-Id"
+
+type reverseProxyConfig struct {
+	peerIDHeader string
+	allowed      map[peer.ID]struct{}
+}
+
+// ReverseProxyOption configures a reverse proxy created by NewReverseProxy.
+type ReverseProxyOption func(*reverseProxyConfig)
+
+// WithPeerIDHeader sets the header used to pass the client's peer ID to the
+// backend. Defaults to DefaultPeerIDHeader.
+func WithPeerIDHeader(name string) ReverseProxyOption {
+	return func(c *reverseProxyConfig) {
+		c.peerIDHeader = name
+	}
+}
+
+// WithAllowedPeers only forwards requests from the given authenticated peers.
+// Other requests are rejected with 401 Unauthorized or 403 Forbidden.
+func WithAllowedPeers(peers ...peer.ID) ReverseProxyOption {
+	return func(c *reverseProxyConfig) {
+		if c.allowed == nil {
+			c.allowed = make(map[peer.ID]struct{}, len(peers))
+		}
+		for _, p := range peers {
+			c.allowed[p] = struct{}{}
+		}
+	}
+}
+
+// NewReverseProxy returns an http.Handler forwarding requests to the target,
+// usually a local HTTP service. This allows exposing an existing web service
+// to libp2p peers without changing it.
+//
+// The authenticated peer ID of the client is passed to the backend in the
+// peer ID header. The header is removed from incoming requests, so clients
+// can't impersonate other peers. Requests without an authenticated peer ID are
+// forwarded without the header, unless an allowlist is configured.
+func NewReverseProxy(target *url.URL, opts ...ReverseProxyOption) http.Handler {
+	cfg := reverseProxyConfig{peerIDHeader: DefaultPeerIDHeader}
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	var handler http.Handler = &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.SetXForwarded()
+			r.Out.Header.Del(cfg.peerIDHeader)
+			if p, ok := PeerIDFromContext(r.In.Context()); ok {
+				r.Out.Header.Set(cfg.peerIDHeader, p.String())
+			}
+		},
+		ErrorHandler: func(w http.ResponseWriter, _ *http.Request, err error) {
+			log.Debugf("reverse proxy to %s failed: %s", target, err)
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}
+	if cfg.allowed != nil {
+		handler = AllowPeers(func(p peer.ID) bool {
+			_, ok := cfg.allowed[p]
+			return ok
+		})(handler)
+	}
+	return handler
+}
+
+// SetReverseProxy forwards requests for the given protocol to the target. See
+// NewReverseProxy.
+func (h *Host) SetReverseProxy(p protocol.ID, target *url.URL, opts ...ReverseProxyOption) {
+	h.SetHTTPHandler(p, NewReverseProxy(target, opts...))
+}
@@ -0,0 +1,61 @@
+package libp2phttp_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/peer"
+	libp2phttp "github.com/libp2p/go-libp2p/p2p/http"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReverseProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path + " " + r.Header.Get(libp2phttp.DefaultPeerIDHeader)))
+	}))
+	defer backend.Close()
+	target, err := url.Parse(backend.URL + "/api")
+	require.NoError(t, err)
+
+	serverHost, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer serverHost.Close()
+	allowedHost, err := libp2p.New(libp2p.NoListenAddrs)
+	require.NoError(t, err)
+	defer allowedHost.Close()
+	deniedHost, err := libp2p.New(libp2p.NoListenAddrs)
+	require.NoError(t, err)
+	defer deniedHost.Close()
+
+	httpHost := libp2phttp.Host{StreamHost: serverHost}
+	httpHost.SetReverseProxy("/backend", target, libp2phttp.WithAllowedPeers(allowedHost.ID()))
+	go httpHost.Serve()
+	defer httpHost.Close()
+
+	get := func(clientHost *libp2phttp.Host) (int, string) {
+		t.Helper()
+		client, err := clientHost.NamespacedClient("/backend", peer.AddrInfo{ID: serverHost.ID(), Addrs: serverHost.Addrs()})
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodGet, "/users", nil)
+		require.NoError(t, err)
+		// Clients can't spoof the peer ID header.
+		req.Header.Set(libp2phttp.DefaultPeerIDHeader, "spoofed")
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	status, body := get(&libp2phttp.Host{StreamHost: allowedHost})
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "/api/users "+allowedHost.ID().String(), body)
+
+	status, _ = get(&libp2phttp.Host{StreamHost: deniedHost})
+	require.Equal(t, http.StatusForbidden, status)
+}
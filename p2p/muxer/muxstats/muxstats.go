@@ -0,0 +1,30 @@
+// Package muxstats helps stream muxers to track network.MuxerStats.
+package muxstats
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// StallThreshold is how long a write has to block to count as stalled.
+const StallThreshold = time.Millisecond
+
+// WriteStalls counts the stalled writes of the streams of a connection.
+type WriteStalls struct {
+	count atomic.Uint64
+	total atomic.Int64
+}
+
+// Track records a write that started at start and just returned.
+func (s *WriteStalls) Track(start time.Time) {
+	if d := time.Since(start); d >= StallThreshold {
+		s.count.Add(1)
+		s.total.Add(int64(d))
+	}
+}
+
+// Stats returns the number of stalled writes, and the total time they were
+// blocked.
+func (s *WriteStalls) Stats() (count uint64, total time.Duration) {
+	return s.count.Load(), time.Duration(s.total.Load())
+}
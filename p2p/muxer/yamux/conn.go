@@ -2,20 +2,41 @@ package yamux
 
 import (
 	"context"
+	"sync/atomic"
 
 	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/p2p/muxer/muxstats"
 
 	"github.com/libp2p/go-yamux/v5"
 )
 
+// MaxUnreadStreams is the maximum number of inbound streams per connection
+// that were accepted, but haven't been read from, closed or reset yet. Inbound
+// streams beyond this limit are reset with network.StreamResourceLimitExceeded.
+// This prevents a peer from making us buffer data on thousands of streams
+// before the resource manager accounts for them.
+// If 0, the number of unread streams isn't limited.
+var MaxUnreadStreams = 256
+
 // conn implements mux.MuxedConn over yamux.Session.
-type conn yamux.Session
+type conn struct {
+	session *yamux.Session
+
+	maxUnread int
+	unread    atomic.Int32
 
-var _ network.MuxedConn = &conn{}
+	sched  *writeScheduler
+	stalls muxstats.WriteStalls
+}
+
+var (
+	_ network.MuxedConn      = &conn{}
+	_ network.ConnMuxerStats = &conn{}
+)
 
 // NewMuxedConn constructs a new MuxedConn from a yamux.Session.
 func NewMuxedConn(m *yamux.Session) network.MuxedConn {
-	return (*conn)(m)
+	return &conn{session: m, maxUnread: MaxUnreadStreams, sched: newWriteScheduler()}
 }
 
 // Close closes underlying yamux
@@ -39,15 +60,39 @@ func (c *conn) OpenStream(ctx context.Context) (network.MuxedStream, error) {
 		return nil, parseError(err)
 	}
 
-	return (*stream)(s), nil
+	return &stream{s: s, sched: c.sched, stalls: &c.stalls}, nil
 }
 
-// AcceptStream accepts a stream opened by the other side.
+// AcceptStream accepts a stream opened by the other side. Streams exceeding
+// MaxUnreadStreams are reset.
 func (c *conn) AcceptStream() (network.MuxedStream, error) {
-	s, err := c.yamux().AcceptStream()
-	return (*stream)(s), parseError(err)
+	for {
+		s, err := c.yamux().AcceptStream()
+		if err != nil {
+			return nil, parseError(err)
+		}
+		if c.maxUnread > 0 && int(c.unread.Load()) >= c.maxUnread {
+			s.ResetWithError(uint32(network.StreamResourceLimitExceeded))
+			streamsRejected.Inc()
+			continue
+		}
+		c.unread.Add(1)
+		str := &stream{s: s, sched: c.sched, stalls: &c.stalls}
+		str.unreadOn.Store(c)
+		return str, nil
+	}
 }
 
 func (c *conn) yamux() *yamux.Session {
-	return (*yamux.Session)(c)
+	return c.session
+}
+
+// MuxerStats returns statistics about the streams of the connection.
+func (c *conn) MuxerStats() network.MuxerStats {
+	stalled, stalledTime := c.stalls.Stats()
+	return network.MuxerStats{
+		OpenStreams:      c.yamux().NumStreams(),
+		StalledWrites:    stalled,
+		StalledWriteTime: stalledTime,
+	}
 }
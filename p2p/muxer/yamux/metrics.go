@@ -0,0 +1,24 @@
+package yamux
+
+import (
+	"github.com/libp2p/go-libp2p/p2p/metricshelper"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricNamespace = "libp2p_yamux"
+
+var (
+	streamsRejected = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "streams_rejected_total",
+			Help:      "Number of inbound streams reset because there were too many unread streams",
+		},
+	)
+)
+
+// MustRegisterWith registers the yamux metrics with reg.
+func MustRegisterWith(reg prometheus.Registerer) {
+	metricshelper.RegisterCollectors(reg, streamsRejected)
+}
@@ -0,0 +1,96 @@
+package yamux
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// priorityChunkSize is the size of the chunks the writes of streams are split
+// into while priorities are in use. Streams yield to streams with a higher
+// priority between chunks.
+const priorityChunkSize = 16 << 10
+
+// maxPriorityWait is the longest a chunk of a stream waits for streams with a
+// higher priority. It prevents starving streams with a lower priority,
+// including when the streams with a higher priority are blocked by flow
+// control. While higher priority streams write continuously, a stream thus
+// writes one priorityChunkSize chunk per maxPriorityWait, about 1.6 MB/s.
+var maxPriorityWait = 10 * time.Millisecond
+
+// writeScheduler schedules the writes of the streams of a connection by
+// their priority, see stream.SetPriority.
+type writeScheduler struct {
+	// enabled is set once a stream of the connection sets a priority, so
+	// that connections without priorities don't pay for the scheduling.
+	enabled atomic.Bool
+
+	mx sync.Mutex
+	// writing counts the streams that are writing, by priority.
+	writing [256]int
+	// changed is closed and replaced when a stream finishes writing a chunk.
+	changed chan struct{}
+}
+
+func newWriteScheduler() *writeScheduler {
+	return &writeScheduler{changed: make(chan struct{})}
+}
+
+func (ws *writeScheduler) startWrite(priority uint8) {
+	ws.mx.Lock()
+	ws.writing[priority]++
+	ws.mx.Unlock()
+}
+
+func (ws *writeScheduler) endWrite(priority uint8) {
+	ws.mx.Lock()
+	ws.writing[priority]--
+	ws.notifyLocked()
+	ws.mx.Unlock()
+}
+
+func (ws *writeScheduler) chunkWritten() {
+	ws.mx.Lock()
+	ws.notifyLocked()
+	ws.mx.Unlock()
+}
+
+func (ws *writeScheduler) notifyLocked() {
+	close(ws.changed)
+	ws.changed = make(chan struct{})
+}
+
+// waitTurn waits until no stream with a priority higher than priority is
+// writing, or up to maxPriorityWait.
+func (ws *writeScheduler) waitTurn(priority uint8) {
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		ws.mx.Lock()
+		higher := false
+		for p := int(priority) + 1; p < len(ws.writing); p++ {
+			if ws.writing[p] > 0 {
+				higher = true
+				break
+			}
+		}
+		changed := ws.changed
+		ws.mx.Unlock()
+		if !higher {
+			return
+		}
+
+		if timer == nil {
+			timer = time.NewTimer(maxPriorityWait)
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return
+		}
+	}
+}
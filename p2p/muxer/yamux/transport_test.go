@@ -1,9 +1,21 @@
 package yamux
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/libp2p/go-libp2p/core/network"
 	tmux "github.com/libp2p/go-libp2p/p2p/muxer/testsuite"
+
+	"github.com/stretchr/testify/require"
 )
 
 func TestDefaultTransport(t *testing.T) {
@@ -13,3 +25,237 @@ func TestDefaultTransport(t *testing.T) {
 
 	tmux.SubtestAll(t, DefaultTransport)
 }
+
+func TestMaxUnreadStreams(t *testing.T) {
+	defer func(n int) { MaxUnreadStreams = n }(MaxUnreadStreams)
+	MaxUnreadStreams = 2
+
+	accepted := make(chan network.MuxedStream, 10)
+	client := newConnPair(t, func(s network.MuxedStream) { accepted <- s })
+
+	openStream := func() network.MuxedStream {
+		t.Helper()
+		s, err := client.OpenStream(context.Background())
+		require.NoError(t, err)
+		_, err = s.Write([]byte("foo"))
+		require.NoError(t, err)
+		return s
+	}
+	waitAccepted := func() network.MuxedStream {
+		t.Helper()
+		select {
+		case s := <-accepted:
+			return s
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for stream")
+			return nil
+		}
+	}
+
+	openStream()
+	first := waitAccepted()
+	openStream()
+	waitAccepted()
+
+	// the server hasn't read from the accepted streams yet
+	rejected := openStream()
+	_, err := rejected.Read(make([]byte, 1))
+	var se *network.StreamError
+	require.ErrorAs(t, err, &se)
+	require.True(t, se.Remote)
+	require.Equal(t, network.StreamResourceLimitExceeded, se.ErrorCode)
+	require.Empty(t, accepted)
+
+	// reading from a stream makes room for another one
+	_, err = first.Read(make([]byte, 3))
+	require.NoError(t, err)
+	openStream()
+	waitAccepted()
+}
+
+func TestWriteSchedulerWaitsForHigherPriority(t *testing.T) {
+	ws := newWriteScheduler()
+	ws.startWrite(1)
+
+	done := make(chan struct{})
+	go func() {
+		ws.waitTurn(0)
+		close(done)
+	}()
+	// chunks of higher priority streams don't let the lower priority stream through
+	ws.chunkWritten()
+	select {
+	case <-done:
+		t.Fatal("lower priority write didn't wait")
+	case <-time.After(maxPriorityWait / 2):
+	}
+	ws.endWrite(1)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("lower priority write still waiting")
+	}
+
+	// writes of equal or higher priority don't wait
+	ws.startWrite(1)
+	defer ws.endWrite(1)
+	start := time.Now()
+	ws.waitTurn(1)
+	ws.waitTurn(2)
+	require.Less(t, time.Since(start), maxPriorityWait)
+}
+
+func TestWriteSchedulerDoesntStarve(t *testing.T) {
+	ws := newWriteScheduler()
+	ws.startWrite(255)
+	defer ws.endWrite(255)
+
+	start := time.Now()
+	ws.waitTurn(0)
+	require.GreaterOrEqual(t, time.Since(start), maxPriorityWait)
+}
+
+// newConnPair returns two connected yamux connections. The streams opened on
+// client are handled by handle on the server side.
+func newConnPair(t *testing.T, handle func(network.MuxedStream)) (client network.MuxedConn) {
+	c1, c2 := net.Pipe()
+	type result struct {
+		conn network.MuxedConn
+		err  error
+	}
+	serverCh := make(chan result, 1)
+	go func() {
+		sc, err := DefaultTransport.NewConn(c2, true, nil)
+		serverCh <- result{sc, err}
+	}()
+	client, err := DefaultTransport.NewConn(c1, false, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	res := <-serverCh
+	require.NoError(t, res.err)
+	server := res.conn
+	t.Cleanup(func() { server.Close() })
+
+	go func() {
+		for {
+			s, err := server.AcceptStream()
+			if err != nil {
+				return
+			}
+			go handle(s)
+		}
+	}()
+	return client
+}
+
+func TestStreamPriority(t *testing.T) {
+	client := newConnPair(t, func(s network.MuxedStream) {
+		defer s.Close()
+		io.Copy(s, s)
+	})
+
+	bulk, err := client.OpenStream(context.Background())
+	require.NoError(t, err)
+	control, err := client.OpenStream(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, control.(network.StreamPrioritizer).SetPriority(10))
+
+	data := make([]byte, 1<<20)
+	_, err = rand.Read(data)
+	require.NoError(t, err)
+	bulkErr := make(chan error, 1)
+	go func() {
+		_, err := bulk.Write(data)
+		if err == nil {
+			err = bulk.CloseWrite()
+		}
+		bulkErr <- err
+	}()
+	go func() {
+		got, err := io.ReadAll(bulk)
+		if err == nil && !bytes.Equal(got, data) {
+			err = errors.New("bulk data corrupted")
+		}
+		bulkErr <- err
+	}()
+
+	for i := 0; i < 10; i++ {
+		msg := []byte(fmt.Sprintf("message %d", i))
+		_, err := control.Write(msg)
+		require.NoError(t, err)
+		buf := make([]byte, len(msg))
+		_, err = io.ReadFull(control, buf)
+		require.NoError(t, err)
+		require.Equal(t, msg, buf)
+	}
+	require.NoError(t, <-bulkErr)
+	require.NoError(t, <-bulkErr)
+}
+
+func TestMuxerStats(t *testing.T) {
+	// The write exceeds the initial flow control window, so it stalls until
+	// the server starts reading.
+	const stall = 50 * time.Millisecond
+	received := make(chan error, 1)
+	client := newConnPair(t, func(s network.MuxedStream) {
+		time.Sleep(stall)
+		_, err := io.Copy(io.Discard, s)
+		s.Close()
+		received <- err
+	})
+
+	str, err := client.OpenStream(context.Background())
+	require.NoError(t, err)
+	stats := client.(network.ConnMuxerStats).MuxerStats()
+	require.Equal(t, 1, stats.OpenStreams)
+	require.Zero(t, stats.StalledWrites)
+
+	data := make([]byte, 1<<20)
+	_, err = str.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, str.CloseWrite())
+	require.NoError(t, <-received)
+
+	stats = client.(network.ConnMuxerStats).MuxerStats()
+	require.NotZero(t, stats.StalledWrites)
+	require.GreaterOrEqual(t, stats.StalledWriteTime, stall/2)
+
+	_, err = io.ReadAll(str)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return client.(network.ConnMuxerStats).MuxerStats().OpenStreams == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestStreamPriorityOvertakesBulk(t *testing.T) {
+	client := newConnPair(t, func(s network.MuxedStream) { io.Copy(io.Discard, s) })
+
+	bulk, err := client.OpenStream(context.Background())
+	require.NoError(t, err)
+	defer bulk.Reset()
+	control, err := client.OpenStream(context.Background())
+	require.NoError(t, err)
+	defer control.Close()
+	require.NoError(t, control.(network.StreamPrioritizer).SetPriority(10))
+
+	// the bulk stream writes continuously, counting the bytes written
+	var bulkWritten atomic.Int64
+	go func() {
+		buf := make([]byte, priorityChunkSize)
+		for {
+			n, err := bulk.Write(buf)
+			bulkWritten.Add(int64(n))
+			if err != nil {
+				return
+			}
+		}
+	}()
+	require.Eventually(t, func() bool { return bulkWritten.Load() >= 1<<20 }, 5*time.Second, time.Millisecond)
+
+	// without priorities, the two streams would share the connection evenly
+	const controlSize = 4 << 20
+	before := bulkWritten.Load()
+	_, err = control.Write(make([]byte, controlSize))
+	require.NoError(t, err)
+	require.Less(t, bulkWritten.Load()-before, int64(controlSize/4))
+}
@@ -0,0 +1,538 @@
+package conngater
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+const defaultReloadInterval = 5 * time.Minute
+
+// Kinds of blocklist rules, as reported to the BlocklistMetricsTracer.
+const (
+	RulePeer = "peer"
+	RuleCIDR = "cidr"
+	RuleASN  = "asn"
+)
+
+// Origins of blocklist rules, as reported to the BlocklistMetricsTracer.
+const (
+	// OriginList rules come from the BlocklistSource.
+	OriginList = "list"
+	// OriginBan rules are temporary bans, see Blocklist.BanPeer.
+	OriginBan = "ban"
+)
+
+// BlocklistSource returns the current blocklist. See ParseBlocklist for its
+// format.
+type BlocklistSource func(ctx context.Context) (io.ReadCloser, error)
+
+// BlocklistFile reads the blocklist from the file at path.
+func BlocklistFile(path string) BlocklistSource {
+	return func(context.Context) (io.ReadCloser, error) {
+		return os.Open(path)
+	}
+}
+
+// blocklistHTTPClient fetches blocklists for BlocklistURL. Its timeout covers
+// reading the response body, so a stalled server can't hold up a reload.
+var blocklistHTTPClient = &http.Client{Timeout: time.Minute}
+
+// BlocklistURL fetches the blocklist from url with an HTTP GET request. The
+// request, including reading the blocklist, times out after a minute.
+func BlocklistURL(url string) BlocklistSource {
+	return func(ctx context.Context) (io.ReadCloser, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := blocklistHTTPClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("fetching blocklist: unexpected status %s", resp.Status)
+		}
+		return resp.Body, nil
+	}
+}
+
+// ASNResolver returns the number of the autonomous system ip belongs to, e.g.
+// by looking it up in a GeoIP database.
+type ASNResolver func(ip net.IP) (asn uint32, ok bool)
+
+// BlocklistRules are the rules of a blocklist. A zero expiry time means that
+// the rule doesn't expire.
+type BlocklistRules struct {
+	Peers   map[peer.ID]time.Time
+	Subnets []SubnetRule
+	ASNs    map[uint32]time.Time
+}
+
+// SubnetRule blocks an IP subnet.
+type SubnetRule struct {
+	Subnet  *net.IPNet
+	Expires time.Time
+}
+
+// ParseBlocklist parses a blocklist. It has one rule per line, optionally
+// followed by the time the rule expires, in RFC 3339 format. A rule is a peer
+// ID, an IP address, a CIDR subnet, or an autonomous system number prefixed
+// with "AS". Empty lines and lines starting with # are ignored:
+//
+//	# abusive peer
+//	12D3KooWLRPJAA5o6y7QbZx2A9ZRbLMWPWoVBw9LudmbYyNwyNBa
+//	192.0.2.0/24
+//	2001:db8::1 2030-01-01T00:00:00Z
+//	AS64496
+func ParseBlocklist(r io.Reader) (*BlocklistRules, error) {
+	rules := &BlocklistRules{
+		Peers: make(map[peer.ID]time.Time),
+		ASNs:  make(map[uint32]time.Time),
+	}
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("blocklist line %d: too many fields", n)
+		}
+		var expires time.Time
+		if len(fields) == 2 {
+			var err error
+			expires, err = time.Parse(time.RFC3339, fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("blocklist line %d: invalid expiry: %w", n, err)
+			}
+		}
+		if err := rules.add(fields[0], expires); err != nil {
+			return nil, fmt.Errorf("blocklist line %d: %w", n, err)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (r *BlocklistRules) add(rule string, expires time.Time) error {
+	if strings.HasPrefix(rule, "AS") {
+		asn, err := strconv.ParseUint(rule[2:], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid AS number %q", rule)
+		}
+		r.ASNs[uint32(asn)] = expires
+		return nil
+	}
+	if strings.Contains(rule, "/") {
+		_, ipnet, err := net.ParseCIDR(rule)
+		if err != nil {
+			return err
+		}
+		r.Subnets = append(r.Subnets, SubnetRule{Subnet: ipnet, Expires: expires})
+		return nil
+	}
+	if ip := net.ParseIP(rule); ip != nil {
+		r.Subnets = append(r.Subnets, SubnetRule{Subnet: hostSubnet(ip), Expires: expires})
+		return nil
+	}
+	p, err := peer.Decode(rule)
+	if err != nil {
+		return fmt.Errorf("invalid rule %q", rule)
+	}
+	r.Peers[p] = expires
+	return nil
+}
+
+func hostSubnet(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+func active(expires, now time.Time) bool {
+	return expires.IsZero() || now.Before(expires)
+}
+
+// Blocklist is a connection gater that blocks peers, IP subnets and
+// autonomous systems. Its rules come from a BlocklistSource, which is reloaded
+// periodically, and from temporary bans.
+type Blocklist struct {
+	source         BlocklistSource
+	reloadInterval time.Duration
+	resolveASN     ASNResolver
+	metricsTracer  BlocklistMetricsTracer
+	now            func() time.Time
+
+	mx   sync.RWMutex
+	list *BlocklistRules
+	bans *BlocklistRules
+
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	refCount  sync.WaitGroup
+}
+
+// BlocklistOption is an option for NewBlocklist.
+type BlocklistOption func(*Blocklist) error
+
+// WithBlocklistSource loads the rules of the blocklist from src.
+func WithBlocklistSource(src BlocklistSource) BlocklistOption {
+	return func(b *Blocklist) error {
+		if src == nil {
+			return errors.New("blocklist source must not be nil")
+		}
+		b.source = src
+		return nil
+	}
+}
+
+// WithReloadInterval sets how often the blocklist source is reloaded. The
+// default is 5 minutes. An interval of 0 disables periodic reloading, see
+// Blocklist.Reload.
+func WithReloadInterval(d time.Duration) BlocklistOption {
+	return func(b *Blocklist) error {
+		if d < 0 {
+			return errors.New("reload interval must not be negative")
+		}
+		b.reloadInterval = d
+		return nil
+	}
+}
+
+// WithASNResolver sets the resolver that blocklist rules for autonomous
+// systems are matched with. Loading a blocklist with such rules fails without
+// a resolver.
+func WithASNResolver(r ASNResolver) BlocklistOption {
+	return func(b *Blocklist) error {
+		b.resolveASN = r
+		return nil
+	}
+}
+
+// WithBlocklistMetricsTracer configures the blocklist to use mt to track
+// metrics.
+func WithBlocklistMetricsTracer(mt BlocklistMetricsTracer) BlocklistOption {
+	return func(b *Blocklist) error {
+		b.metricsTracer = mt
+		return nil
+	}
+}
+
+// NewBlocklist creates a new Blocklist. If a source is configured, it is
+// loaded before NewBlocklist returns, and reloaded in the background until the
+// blocklist is closed.
+func NewBlocklist(opts ...BlocklistOption) (*Blocklist, error) {
+	b := &Blocklist{
+		reloadInterval: defaultReloadInterval,
+		now:            time.Now,
+		list:           &BlocklistRules{},
+		bans:           &BlocklistRules{Peers: make(map[peer.ID]time.Time)},
+	}
+	for _, o := range opts {
+		if err := o(b); err != nil {
+			return nil, err
+		}
+	}
+	b.ctx, b.ctxCancel = context.WithCancel(context.Background())
+
+	if b.source != nil {
+		if err := b.Reload(b.ctx); err != nil {
+			b.ctxCancel()
+			return nil, err
+		}
+		if b.reloadInterval > 0 {
+			b.refCount.Add(1)
+			go b.background()
+		}
+	}
+	return b, nil
+}
+
+func (b *Blocklist) background() {
+	defer b.refCount.Done()
+
+	t := time.NewTicker(b.reloadInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := b.Reload(b.ctx); err != nil && b.ctx.Err() == nil {
+				log.Warnw("failed to reload blocklist; keeping the current rules", "error", err)
+			}
+		case <-b.ctx.Done():
+			return
+		}
+	}
+}
+
+// Reload loads the rules from the blocklist source, replacing the rules
+// loaded before. If loading fails, the current rules are kept.
+func (b *Blocklist) Reload(ctx context.Context) error {
+	if b.source == nil {
+		return errors.New("blocklist has no source")
+	}
+	rules, err := b.load(ctx)
+	if b.metricsTracer != nil {
+		b.metricsTracer.BlocklistReloaded(err == nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.list = rules
+	b.pruneLocked()
+	return nil
+}
+
+func (b *Blocklist) load(ctx context.Context) (*BlocklistRules, error) {
+	rc, err := b.source(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	rules, err := ParseBlocklist(rc)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules.ASNs) > 0 && b.resolveASN == nil {
+		return nil, errors.New("blocklist has AS rules, but no ASN resolver is configured")
+	}
+	return rules, nil
+}
+
+// Close stops reloading the blocklist source.
+func (b *Blocklist) Close() error {
+	b.ctxCancel()
+	b.refCount.Wait()
+	return nil
+}
+
+// BanPeer blocks p for d, in addition to the rules of the blocklist source.
+// Note: active connections to the peer are not automatically closed.
+func (b *Blocklist) BanPeer(p peer.ID, d time.Duration) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.pruneLocked()
+	b.bans.Peers[p] = b.now().Add(d)
+}
+
+// UnbanPeer lifts the ban of p. It doesn't affect the rules of the blocklist
+// source.
+func (b *Blocklist) UnbanPeer(p peer.ID) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	delete(b.bans.Peers, p)
+}
+
+// BanSubnet blocks ipnet for d, in addition to the rules of the blocklist
+// source.
+// Note: active connections to the IP subnet are not automatically closed.
+func (b *Blocklist) BanSubnet(ipnet *net.IPNet, d time.Duration) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.pruneLocked()
+	b.bans.Subnets = append(b.bans.Subnets, SubnetRule{Subnet: ipnet, Expires: b.now().Add(d)})
+}
+
+// UnbanSubnet lifts the bans of ipnet. It doesn't affect the rules of the
+// blocklist source.
+func (b *Blocklist) UnbanSubnet(ipnet *net.IPNet) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.bans.Subnets = deleteSubnetRules(b.bans.Subnets, func(r SubnetRule) bool {
+		return r.Subnet.String() == ipnet.String()
+	})
+}
+
+// pruneLocked removes expired rules.
+func (b *Blocklist) pruneLocked() {
+	now := b.now()
+	for _, rules := range []*BlocklistRules{b.list, b.bans} {
+		for p, expires := range rules.Peers {
+			if !active(expires, now) {
+				delete(rules.Peers, p)
+			}
+		}
+		for asn, expires := range rules.ASNs {
+			if !active(expires, now) {
+				delete(rules.ASNs, asn)
+			}
+		}
+		rules.Subnets = deleteSubnetRules(rules.Subnets, func(r SubnetRule) bool {
+			return !active(r.Expires, now)
+		})
+	}
+}
+
+func deleteSubnetRules(rules []SubnetRule, del func(SubnetRule) bool) []SubnetRule {
+	kept := rules[:0]
+	for _, r := range rules {
+		if !del(r) {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// Rules returns a copy of the active rules of the blocklist source and of the
+// active temporary bans.
+func (b *Blocklist) Rules() (list, bans BlocklistRules) {
+	b.mx.RLock()
+	defer b.mx.RUnlock()
+	now := b.now()
+	return b.list.active(now), b.bans.active(now)
+}
+
+func (r *BlocklistRules) active(now time.Time) BlocklistRules {
+	c := BlocklistRules{
+		Peers: make(map[peer.ID]time.Time),
+		ASNs:  make(map[uint32]time.Time),
+	}
+	for p, expires := range r.Peers {
+		if active(expires, now) {
+			c.Peers[p] = expires
+		}
+	}
+	for asn, expires := range r.ASNs {
+		if active(expires, now) {
+			c.ASNs[asn] = expires
+		}
+	}
+	for _, s := range r.Subnets {
+		if active(s.Expires, now) {
+			c.Subnets = append(c.Subnets, s)
+		}
+	}
+	return c
+}
+
+// blockedPeer returns the origin of the rule that blocks p, if any.
+func (b *Blocklist) blockedPeer(p peer.ID) (origin string, blocked bool) {
+	b.mx.RLock()
+	defer b.mx.RUnlock()
+	now := b.now()
+	if expires, ok := b.list.Peers[p]; ok && active(expires, now) {
+		return OriginList, true
+	}
+	if expires, ok := b.bans.Peers[p]; ok && active(expires, now) {
+		return OriginBan, true
+	}
+	return "", false
+}
+
+// blockedAddr returns the kind and origin of the rule that blocks the IP of
+// a, if any.
+func (b *Blocklist) blockedAddr(a ma.Multiaddr) (rule, origin string, blocked bool) {
+	ip, err := manet.ToIP(a)
+	if err != nil {
+		// not an IP address, e.g. a relayed connection
+		return "", "", false
+	}
+
+	b.mx.RLock()
+	now := b.now()
+	for _, s := range b.list.Subnets {
+		if active(s.Expires, now) && s.Subnet.Contains(ip) {
+			b.mx.RUnlock()
+			return RuleCIDR, OriginList, true
+		}
+	}
+	for _, s := range b.bans.Subnets {
+		if active(s.Expires, now) && s.Subnet.Contains(ip) {
+			b.mx.RUnlock()
+			return RuleCIDR, OriginBan, true
+		}
+	}
+	hasASNs := len(b.list.ASNs) > 0
+	b.mx.RUnlock()
+
+	// resolve the AS outside of the lock, as the resolver may be slow
+	if !hasASNs {
+		return "", "", false
+	}
+	asn, ok := b.resolveASN(ip)
+	if !ok {
+		return "", "", false
+	}
+	b.mx.RLock()
+	defer b.mx.RUnlock()
+	if expires, ok := b.list.ASNs[asn]; ok && active(expires, b.now()) {
+		return RuleASN, OriginList, true
+	}
+	return "", "", false
+}
+
+func (b *Blocklist) rejected(rule, origin string, dir network.Direction) {
+	if b.metricsTracer != nil {
+		b.metricsTracer.ConnRejected(rule, origin, dir)
+	}
+}
+
+// ConnectionGater interface
+var _ connmgr.ConnectionGater = (*Blocklist)(nil)
+
+func (b *Blocklist) InterceptPeerDial(p peer.ID) (allow bool) {
+	if origin, blocked := b.blockedPeer(p); blocked {
+		b.rejected(RulePeer, origin, network.DirOutbound)
+		return false
+	}
+	return true
+}
+
+func (b *Blocklist) InterceptAddrDial(_ peer.ID, a ma.Multiaddr) (allow bool) {
+	if rule, origin, blocked := b.blockedAddr(a); blocked {
+		b.rejected(rule, origin, network.DirOutbound)
+		return false
+	}
+	return true
+}
+
+func (b *Blocklist) InterceptAccept(cma network.ConnMultiaddrs) (allow bool) {
+	if rule, origin, blocked := b.blockedAddr(cma.RemoteMultiaddr()); blocked {
+		b.rejected(rule, origin, network.DirInbound)
+		return false
+	}
+	return true
+}
+
+func (b *Blocklist) InterceptSecured(dir network.Direction, p peer.ID, _ network.ConnMultiaddrs) (allow bool) {
+	if dir == network.DirOutbound {
+		// we have already filtered those in InterceptPeerDial/InterceptAddrDial
+		return true
+	}
+	// we have already filtered addrs in InterceptAccept, so we just check the peer ID
+	if origin, blocked := b.blockedPeer(p); blocked {
+		b.rejected(RulePeer, origin, network.DirInbound)
+		return false
+	}
+	return true
+}
+
+func (b *Blocklist) InterceptUpgraded(network.Conn) (allow bool, reason control.DisconnectReason) {
+	return true, 0
+}
@@ -0,0 +1,87 @@
+package conngater
+
+import (
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/p2p/metricshelper"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricNamespace = "libp2p_conngater"
+
+var (
+	blocklistRejectedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "blocklist_rejected_total",
+			Help:      "Connections rejected by the blocklist, by rule",
+		},
+		[]string{"rule", "origin", "dir"},
+	)
+	blocklistReloadsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "blocklist_reloads_total",
+			Help:      "Blocklist reloads, by outcome",
+		},
+		[]string{"outcome"},
+	)
+
+	collectors = []prometheus.Collector{
+		blocklistRejectedTotal,
+		blocklistReloadsTotal,
+	}
+)
+
+// BlocklistMetricsTracer tracks metrics of a Blocklist.
+type BlocklistMetricsTracer interface {
+	// ConnRejected is called when a connection is rejected. rule is one of
+	// RulePeer, RuleCIDR and RuleASN, and origin one of OriginList and
+	// OriginBan.
+	ConnRejected(rule, origin string, dir network.Direction)
+	// BlocklistReloaded is called after every attempt to load the blocklist
+	// source.
+	BlocklistReloaded(success bool)
+}
+
+type blocklistMetricsTracer struct{}
+
+var _ BlocklistMetricsTracer = &blocklistMetricsTracer{}
+
+type metricsTracerSetting struct {
+	reg prometheus.Registerer
+}
+
+type MetricsTracerOption func(*metricsTracerSetting)
+
+func WithRegisterer(reg prometheus.Registerer) MetricsTracerOption {
+	return func(s *metricsTracerSetting) {
+		if reg != nil {
+			s.reg = reg
+		}
+	}
+}
+
+func NewBlocklistMetricsTracer(opts ...MetricsTracerOption) BlocklistMetricsTracer {
+	setting := &metricsTracerSetting{reg: prometheus.DefaultRegisterer}
+	for _, opt := range opts {
+		opt(setting)
+	}
+	metricshelper.RegisterCollectors(setting.reg, collectors...)
+	return &blocklistMetricsTracer{}
+}
+
+func (mt *blocklistMetricsTracer) ConnRejected(rule, origin string, dir network.Direction) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, rule, origin, metricshelper.GetDirection(dir))
+	blocklistRejectedTotal.WithLabelValues(*tags...).Inc()
+}
+
+func (mt *blocklistMetricsTracer) BlocklistReloaded(success bool) {
+	outcome := "success"
+	if !success {
+		outcome = "failed"
+	}
+	blocklistReloadsTotal.WithLabelValues(outcome).Inc()
+}
@@ -0,0 +1,265 @@
+package conngater
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/test"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+type mockBlocklistTracer struct {
+	mx       sync.Mutex
+	rejected []string
+	reloads  []bool
+}
+
+func (m *mockBlocklistTracer) ConnRejected(rule, origin string, dir network.Direction) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.rejected = append(m.rejected, rule+"/"+origin+"/"+dir.String())
+}
+
+func (m *mockBlocklistTracer) BlocklistReloaded(success bool) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.reloads = append(m.reloads, success)
+}
+
+func accept(b *Blocklist, addr string) bool {
+	return b.InterceptAccept(&mockConnMultiaddrs{remote: ma.StringCast(addr)})
+}
+
+func TestParseBlocklist(t *testing.T) {
+	p := test.RandPeerIDFatal(t)
+	rules, err := ParseBlocklist(strings.NewReader(`
+# comment
+` + p.String() + `
+192.0.2.0/24
+2001:db8::1 2030-01-01T00:00:00Z
+AS64496
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rules.Peers[p]; !ok || len(rules.Peers) != 1 {
+		t.Fatalf("unexpected peers: %v", rules.Peers)
+	}
+	if len(rules.Subnets) != 2 || rules.Subnets[0].Subnet.String() != "192.0.2.0/24" || rules.Subnets[1].Subnet.String() != "2001:db8::1/128" {
+		t.Fatalf("unexpected subnets: %v", rules.Subnets)
+	}
+	if !rules.Subnets[1].Expires.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected expiry: %s", rules.Subnets[1].Expires)
+	}
+	if _, ok := rules.ASNs[64496]; !ok || len(rules.ASNs) != 1 {
+		t.Fatalf("unexpected ASNs: %v", rules.ASNs)
+	}
+
+	for _, bad := range []string{"not-a-peer", "192.0.2.0/33", "ASx", "192.0.2.1 tomorrow", "192.0.2.1 2030-01-01T00:00:00Z extra"} {
+		if _, err := ParseBlocklist(strings.NewReader(bad)); err == nil {
+			t.Fatalf("expected error parsing %q", bad)
+		}
+	}
+}
+
+func TestBlocklistFileReload(t *testing.T) {
+	p := test.RandPeerIDFatal(t)
+	path := filepath.Join(t.TempDir(), "blocklist")
+	if err := os.WriteFile(path, []byte("192.0.2.0/24\n"+p.String()+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mt := &mockBlocklistTracer{}
+	b, err := NewBlocklist(WithBlocklistSource(BlocklistFile(path)), WithReloadInterval(10*time.Millisecond), WithBlocklistMetricsTracer(mt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if accept(b, "/ip4/192.0.2.1/tcp/1234") {
+		t.Fatal("expected blocked subnet to be refused")
+	}
+	if !accept(b, "/ip4/198.51.100.1/tcp/1234") {
+		t.Fatal("expected other address to be accepted")
+	}
+	if b.InterceptPeerDial(p) {
+		t.Fatal("expected dial to blocked peer to be refused")
+	}
+	if b.InterceptSecured(network.DirInbound, p, nil) {
+		t.Fatal("expected connection from blocked peer to be refused")
+	}
+	if !b.InterceptSecured(network.DirInbound, test.RandPeerIDFatal(t), nil) {
+		t.Fatal("expected connection from other peer to be accepted")
+	}
+
+	// an invalid blocklist keeps the current rules
+	if err := os.WriteFile(path, []byte("invalid\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if accept(b, "/ip4/192.0.2.1/tcp/1234") {
+		t.Fatal("expected rules to be kept after failed reload")
+	}
+
+	if err := os.WriteFile(path, []byte("198.51.100.0/24\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for accept(b, "/ip4/198.51.100.1/tcp/1234") {
+		if time.Now().After(deadline) {
+			t.Fatal("blocklist wasn't reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !accept(b, "/ip4/192.0.2.1/tcp/1234") || !b.InterceptPeerDial(p) {
+		t.Fatal("expected rules to be replaced on reload")
+	}
+
+	mt.mx.Lock()
+	defer mt.mx.Unlock()
+	if mt.rejected[0] != "cidr/list/Inbound" || mt.rejected[1] != "peer/list/Outbound" {
+		t.Fatalf("unexpected rejections: %v", mt.rejected)
+	}
+	var failed bool
+	for _, ok := range mt.reloads {
+		failed = failed || !ok
+	}
+	if !mt.reloads[0] || !failed {
+		t.Fatalf("unexpected reloads: %v", mt.reloads)
+	}
+}
+
+func TestBlocklistURL(t *testing.T) {
+	var status atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(int(status.Load()))
+		w.Write([]byte("192.0.2.0/24\n"))
+	}))
+	defer srv.Close()
+
+	status.Store(http.StatusNotFound)
+	if _, err := NewBlocklist(WithBlocklistSource(BlocklistURL(srv.URL))); err == nil {
+		t.Fatal("expected error loading blocklist")
+	}
+
+	status.Store(http.StatusOK)
+	b, err := NewBlocklist(WithBlocklistSource(BlocklistURL(srv.URL)), WithReloadInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if b.InterceptAddrDial("", ma.StringCast("/ip4/192.0.2.1/udp/1234/quic-v1")) {
+		t.Fatal("expected dial to blocked subnet to be refused")
+	}
+}
+
+func TestBlocklistURLTimeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	defaultClient := blocklistHTTPClient
+	blocklistHTTPClient = &http.Client{Timeout: 100 * time.Millisecond}
+	defer func() { blocklistHTTPClient = defaultClient }()
+
+	if _, err := NewBlocklist(WithBlocklistSource(BlocklistURL(srv.URL))); err == nil {
+		t.Fatal("expected error loading a stalled blocklist")
+	}
+}
+
+func TestBlocklistBans(t *testing.T) {
+	now := time.Now()
+	b, err := NewBlocklist()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	b.now = func() time.Time { return now }
+
+	p := test.RandPeerIDFatal(t)
+	_, ipnet, _ := net.ParseCIDR("192.0.2.0/24")
+	b.BanPeer(p, time.Minute)
+	b.BanSubnet(ipnet, 2*time.Minute)
+	if b.InterceptPeerDial(p) {
+		t.Fatal("expected dial to banned peer to be refused")
+	}
+	if accept(b, "/ip4/192.0.2.1/tcp/1234") {
+		t.Fatal("expected banned subnet to be refused")
+	}
+	if _, bans := b.Rules(); len(bans.Peers) != 1 || len(bans.Subnets) != 1 {
+		t.Fatalf("unexpected bans: %v", bans)
+	}
+
+	now = now.Add(time.Minute)
+	if !b.InterceptPeerDial(p) {
+		t.Fatal("expected peer ban to expire")
+	}
+	if accept(b, "/ip4/192.0.2.1/tcp/1234") {
+		t.Fatal("expected subnet ban not to expire yet")
+	}
+	b.UnbanSubnet(ipnet)
+	if !accept(b, "/ip4/192.0.2.1/tcp/1234") {
+		t.Fatal("expected subnet to be unbanned")
+	}
+
+	b.BanPeer(p, time.Minute)
+	b.UnbanPeer(p)
+	if !b.InterceptPeerDial(p) {
+		t.Fatal("expected peer to be unbanned")
+	}
+
+	// relayed connections don't have an IP
+	if !accept(b, "/p2p-circuit") {
+		t.Fatal("expected relayed connection to be accepted")
+	}
+}
+
+func TestBlocklistASN(t *testing.T) {
+	src := func(context.Context) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("AS64496\nAS64497 2000-01-01T00:00:00Z\n")), nil
+	}
+	if _, err := NewBlocklist(WithBlocklistSource(src)); err == nil {
+		t.Fatal("expected error loading AS rules without a resolver")
+	}
+
+	resolver := func(ip net.IP) (uint32, bool) {
+		switch ip.String() {
+		case "192.0.2.1":
+			return 64496, true
+		case "192.0.2.2":
+			return 64497, true
+		}
+		return 0, false
+	}
+	b, err := NewBlocklist(WithBlocklistSource(src), WithASNResolver(resolver))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if accept(b, "/ip4/192.0.2.1/tcp/1234") {
+		t.Fatal("expected address in blocked AS to be refused")
+	}
+	if !accept(b, "/ip4/192.0.2.2/tcp/1234") {
+		t.Fatal("expected expired AS rule to be ignored")
+	}
+	if !accept(b, "/ip4/192.0.2.3/tcp/1234") {
+		t.Fatal("expected unresolved address to be accepted")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/connmgr"
 	"github.com/libp2p/go-libp2p/core/control"
@@ -28,9 +29,38 @@ type BasicConnectionGater struct {
 	blockedAddrs   map[string]struct{}
 	blockedSubnets map[string]*net.IPNet
 
+	allowlist *peer.Set
+	denylist  *peer.Set
+
+	policy            SecuredConnPolicy
+	policyTimeout     time.Duration
+	policyFailureMode FailureMode
+
 	ds datastore.Datastore
 }
 
+// Option is an option for NewBasicConnectionGater.
+type Option func(*BasicConnectionGater) error
+
+// WithAllowlist only allows connections with peers in s. Changes to s apply
+// to new connections immediately.
+func WithAllowlist(s *peer.Set) Option {
+	return func(cg *BasicConnectionGater) error {
+		cg.allowlist = s
+		return nil
+	}
+}
+
+// WithDenylist blocks connections with peers in s, in addition to the peers
+// blocked with BlockPeer. Unlike blocked peers, peers in s aren't persisted in
+// the datastore.
+func WithDenylist(s *peer.Set) Option {
+	return func(cg *BasicConnectionGater) error {
+		cg.denylist = s
+		return nil
+	}
+}
+
 var log = logging.Logger("net/conngater")
 
 const (
@@ -43,12 +73,17 @@ const (
 // NewBasicConnectionGater creates a new connection gater.
 // The ds argument is an (optional, can be nil) datastore to persist the connection gater
 // filters.
-func NewBasicConnectionGater(ds datastore.Datastore) (*BasicConnectionGater, error) {
+func NewBasicConnectionGater(ds datastore.Datastore, opts ...Option) (*BasicConnectionGater, error) {
 	cg := &BasicConnectionGater{
 		blockedPeers:   make(map[peer.ID]struct{}),
 		blockedAddrs:   make(map[string]struct{}),
 		blockedSubnets: make(map[string]*net.IPNet),
 	}
+	for _, o := range opts {
+		if err := o(cg); err != nil {
+			return nil, err
+		}
+	}
 
 	if ds != nil {
 		cg.ds = namespace.Wrap(ds, datastore.NewKey(ns))
@@ -275,6 +310,10 @@ func (cg *BasicConnectionGater) ListBlockedSubnets() []*net.IPNet {
 var _ connmgr.ConnectionGater = (*BasicConnectionGater)(nil)
 
 func (cg *BasicConnectionGater) InterceptPeerDial(p peer.ID) (allow bool) {
+	if !peer.Admitted(cg.allowlist, cg.denylist, p) {
+		return false
+	}
+
 	cg.RLock()
 	defer cg.RUnlock()
 
@@ -340,6 +379,10 @@ func (cg *BasicConnectionGater) InterceptSecured(dir network.Direction, p peer.I
 	}
 
 	// we have already filtered addrs in InterceptAccept, so we just check the peer ID
+	if !peer.Admitted(cg.allowlist, cg.denylist, p) {
+		return false
+	}
+
 	cg.RLock()
 	defer cg.RUnlock()
 
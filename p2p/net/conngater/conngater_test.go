@@ -1,10 +1,14 @@
 package conngater
 
 import (
+	"context"
+	"errors"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p/core/connmgr"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 
@@ -372,3 +376,86 @@ func (cma *mockConnMultiaddrs) LocalMultiaddr() ma.Multiaddr {
 func (cma *mockConnMultiaddrs) RemoteMultiaddr() ma.Multiaddr {
 	return cma.remote
 }
+
+func TestConnectionGaterPeerSets(t *testing.T) {
+	allow := peer.NewSet()
+	deny := peer.NewSet()
+	cg, err := NewBasicConnectionGater(nil, WithAllowlist(allow), WithDenylist(deny))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cg.InterceptPeerDial("A") {
+		t.Fatal("expected gater to deny peer not in allowlist")
+	}
+	allow.Add("A")
+	if !cg.InterceptPeerDial("A") {
+		t.Fatal("expected gater to allow peer in allowlist")
+	}
+	if !cg.InterceptSecured(network.DirInbound, "A", nil) {
+		t.Fatal("expected gater to allow inbound connection from peer in allowlist")
+	}
+
+	deny.Add("A")
+	if cg.InterceptPeerDial("A") {
+		t.Fatal("expected gater to deny peer in denylist")
+	}
+	if cg.InterceptSecured(network.DirInbound, "A", nil) {
+		t.Fatal("expected gater to deny inbound connection from peer in denylist")
+	}
+}
+
+func TestConnectionGaterSecuredConnPolicy(t *testing.T) {
+	info := connmgr.SecuredConnInfo{ConnMultiaddrs: &mockConnMultiaddrs{remote: ma.StringCast("/ip4/1.2.3.4/tcp/1234")}}
+	errUnavailable := errors.New("policy engine unavailable")
+	release := make(chan struct{})
+	defer close(release)
+	policy := func(ctx context.Context, _ network.Direction, p peer.ID, _ connmgr.SecuredConnInfo) (bool, error) {
+		switch p {
+		case "unavailable":
+			return false, errUnavailable
+		case "slow":
+			<-ctx.Done()
+			return false, ctx.Err()
+		case "hung":
+			// ignores the deadline
+			<-release
+			return true, nil
+		}
+		return p == "allowed", nil
+	}
+
+	for _, mode := range []FailureMode{FailClosed, FailOpen} {
+		cg, err := NewBasicConnectionGater(nil, WithSecuredConnPolicy(policy, 50*time.Millisecond, mode))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cg.InterceptSecuredCtx(context.Background(), network.DirOutbound, "allowed", info); err != nil {
+			t.Fatalf("expected connection to be allowed, got %s", err)
+		}
+		if err := cg.InterceptSecuredCtx(context.Background(), network.DirOutbound, "refused", info); err == nil {
+			t.Fatal("expected connection to be refused")
+		}
+		for _, p := range []peer.ID{"unavailable", "slow", "hung"} {
+			err := cg.InterceptSecuredCtx(context.Background(), network.DirOutbound, p, info)
+			if mode == FailOpen && err != nil {
+				t.Fatalf("expected failing open for %s, got %s", p, err)
+			}
+			if mode == FailClosed && err == nil {
+				t.Fatalf("expected failing closed for %s", p)
+			}
+		}
+
+		// the rules of the gater apply before the policy
+		if err := cg.BlockPeer("allowed"); err != nil {
+			t.Fatal(err)
+		}
+		if err := cg.InterceptSecuredCtx(context.Background(), network.DirInbound, "allowed", info); err == nil {
+			t.Fatal("expected connection from blocked peer to be refused")
+		}
+	}
+
+	if _, err := NewBasicConnectionGater(nil, WithSecuredConnPolicy(policy, 0, FailOpen)); err == nil {
+		t.Fatal("expected error for zero timeout")
+	}
+}
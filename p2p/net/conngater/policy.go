@@ -0,0 +1,97 @@
+package conngater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// SecuredConnPolicy decides whether a secured connection is allowed, e.g. by
+// querying an external policy engine. It returns an error if it can't decide.
+type SecuredConnPolicy func(ctx context.Context, dir network.Direction, p peer.ID, info connmgr.SecuredConnInfo) (allow bool, err error)
+
+// FailureMode decides what happens to a connection when its
+// SecuredConnPolicy fails or times out.
+type FailureMode int
+
+const (
+	// FailClosed refuses the connection.
+	FailClosed FailureMode = iota
+	// FailOpen allows the connection.
+	FailOpen
+)
+
+var (
+	errPeerBlocked   = errors.New("peer is blocked")
+	errPolicyRefused = errors.New("refused by secured connection policy")
+)
+
+// WithSecuredConnPolicy consults policy for every secured connection the
+// rules of the gater allow. policy is given up to timeout to decide, after
+// which mode decides whether the connection is allowed, as it does when
+// policy returns an error.
+func WithSecuredConnPolicy(policy SecuredConnPolicy, timeout time.Duration, mode FailureMode) Option {
+	return func(cg *BasicConnectionGater) error {
+		if policy == nil {
+			return errors.New("secured connection policy must not be nil")
+		}
+		if timeout <= 0 {
+			return errors.New("secured connection policy timeout must be positive")
+		}
+		if mode != FailClosed && mode != FailOpen {
+			return errors.New("invalid secured connection policy failure mode")
+		}
+		cg.policy = policy
+		cg.policyTimeout = timeout
+		cg.policyFailureMode = mode
+		return nil
+	}
+}
+
+var _ connmgr.ContextSecuredConnGater = (*BasicConnectionGater)(nil)
+
+// InterceptSecuredCtx applies the rules of InterceptSecured, and then consults
+// the policy set with WithSecuredConnPolicy, if any.
+func (cg *BasicConnectionGater) InterceptSecuredCtx(ctx context.Context, dir network.Direction, p peer.ID, info connmgr.SecuredConnInfo) error {
+	if !cg.InterceptSecured(dir, p, info.ConnMultiaddrs) {
+		return errPeerBlocked
+	}
+	if cg.policy == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cg.policyTimeout)
+	defer cancel()
+	type result struct {
+		allow bool
+		err   error
+	}
+	// don't rely on the policy to respect the deadline
+	resCh := make(chan result, 1)
+	go func() {
+		allow, err := cg.policy(ctx, dir, p, info)
+		resCh <- result{allow: allow, err: err}
+	}()
+	var res result
+	select {
+	case res = <-resCh:
+	case <-ctx.Done():
+		res.err = ctx.Err()
+	}
+
+	switch {
+	case res.err != nil && cg.policyFailureMode == FailOpen:
+		log.Warnw("secured connection policy failed; allowing connection", "peer", p, "addr", info.RemoteMultiaddr(), "error", res.err)
+		return nil
+	case res.err != nil:
+		return fmt.Errorf("secured connection policy failed: %w", res.err)
+	case !res.allow:
+		return errPolicyRefused
+	}
+	return nil
+}
@@ -40,6 +40,8 @@ type BasicConnMgr struct {
 	// channel-based semaphore that enforces only a single trim is in progress
 	trimMutex sync.Mutex
 	connCount atomic.Int32
+	// inboundCount and outboundCount count the connections in each direction.
+	inboundCount, outboundCount atomic.Int32
 	// to be accessed atomically. This is mimicking the implementation of a sync.Once.
 	// Take care of correct alignment when modifying this struct.
 	trimCount uint64
@@ -180,6 +182,59 @@ func (cm *BasicConnMgr) ForceTrim() {
 	cm.lastTrimMu.Unlock()
 }
 
+// ShedConns closes about n connections to relieve resource pressure, ignoring
+// the watermarks, the silence period and the grace period. Unlike ForceTrim,
+// it never closes the connections of protected peers. Peers are closed
+// starting with the lowest value, and all connections of a peer are closed
+// together. It returns the number of connections closed.
+func (cm *BasicConnMgr) ShedConns(n int) int {
+	if n <= 0 {
+		return 0
+	}
+
+	cm.trimMutex.Lock()
+	defer atomic.AddUint64(&cm.trimCount, 1)
+	defer cm.trimMutex.Unlock()
+
+	candidates := make(peerInfos, 0, cm.segments.countPeers())
+	cm.plk.RLock()
+	for _, s := range cm.segments.buckets {
+		s.Lock()
+		for id, inf := range s.peers {
+			if _, ok := cm.protected[id]; ok {
+				continue
+			}
+			candidates = append(candidates, inf)
+		}
+		s.Unlock()
+	}
+	cm.plk.RUnlock()
+
+	candidates.SortByValueAndStreams(&cm.segments, true)
+
+	selected := make([]network.Conn, 0, n)
+	for _, inf := range candidates {
+		if len(selected) >= n {
+			break
+		}
+		s := cm.segments.get(inf.id)
+		s.Lock()
+		for c := range inf.conns {
+			selected = append(selected, c)
+		}
+		s.Unlock()
+	}
+	for _, c := range selected {
+		log.Debugw("shedding conn", "peer", c.RemotePeer())
+		c.CloseWithError(network.ConnGarbageCollected)
+	}
+
+	cm.lastTrimMu.Lock()
+	cm.lastTrim = cm.clock.Now()
+	cm.lastTrimMu.Unlock()
+	return len(selected)
+}
+
 func (cm *BasicConnMgr) Close() error {
 	cm.cancel()
 	if cm.unregisterMemoryWatcher != nil {
@@ -354,7 +409,7 @@ func (cm *BasicConnMgr) background() {
 	for {
 		select {
 		case <-ticker.C:
-			if cm.connCount.Load() < int32(cm.cfg.highWater) {
+			if !cm.aboveHighWater() {
 				// Below high water, skip.
 				continue
 			}
@@ -365,6 +420,33 @@ func (cm *BasicConnMgr) background() {
 	}
 }
 
+// aboveHighWater returns whether the number of connections, overall or in a
+// direction with its own watermarks, has reached the high watermark.
+func (cm *BasicConnMgr) aboveHighWater() bool {
+	if cm.cfg.highWater != 0 && cm.connCount.Load() >= int32(cm.cfg.highWater) {
+		return true
+	}
+	for _, dir := range []network.Direction{network.DirInbound, network.DirOutbound} {
+		if w := cm.cfg.watermarksFor(dir); w.enabled() && cm.dirConnCount(dir).Load() >= int32(w.high) {
+			return true
+		}
+	}
+	return false
+}
+
+// dirConnCount returns the counter of connections in direction dir, or nil if
+// connections in dir aren't counted.
+func (cm *BasicConnMgr) dirConnCount(dir network.Direction) *atomic.Int32 {
+	switch dir {
+	case network.DirInbound:
+		return &cm.inboundCount
+	case network.DirOutbound:
+		return &cm.outboundCount
+	default:
+		return nil
+	}
+}
+
 func (cm *BasicConnMgr) doTrim() {
 	// This logic is mimicking the implementation of sync.Once in the standard library.
 	count := atomic.LoadUint64(&cm.trimCount)
@@ -457,20 +539,24 @@ func (cm *BasicConnMgr) getConnsToCloseEmergency(target int) []network.Conn {
 }
 
 // getConnsToClose runs the heuristics described in TrimOpenConns and returns the
-// connections to close.
+// connections to close. Connections are trimmed down to the low watermark
+// overall, and down to the low watermark of each direction that has its own
+// watermarks.
 func (cm *BasicConnMgr) getConnsToClose() []network.Conn {
-	if cm.cfg.lowWater == 0 || cm.cfg.highWater == 0 {
-		// disabled
-		return nil
+	trimAll := cm.cfg.lowWater != 0 && cm.cfg.highWater != 0 && int(cm.connCount.Load()) > cm.cfg.lowWater
+	var trimDir [3]bool // indexed by network.Direction
+	for _, dir := range []network.Direction{network.DirInbound, network.DirOutbound} {
+		w := cm.cfg.watermarksFor(dir)
+		trimDir[dir] = w.enabled() && int(cm.dirConnCount(dir).Load()) > w.low
 	}
-
-	if int(cm.connCount.Load()) <= cm.cfg.lowWater {
+	if !trimAll && !trimDir[network.DirInbound] && !trimDir[network.DirOutbound] {
 		log.Info("open connection count below limit")
 		return nil
 	}
 
 	candidates := make(peerInfos, 0, cm.segments.countPeers())
 	var ncandidates int
+	var ndirCandidates [3]int // indexed by network.Direction
 	gracePeriodStart := cm.clock.Now().Add(-cm.cfg.gracePeriod)
 
 	cm.plk.RLock()
@@ -489,30 +575,46 @@ func (cm *BasicConnMgr) getConnsToClose() []network.Conn {
 			// but since inf.conns is a map, it will still point to the original object
 			candidates = append(candidates, inf)
 			ncandidates += len(inf.conns)
+			for c := range inf.conns {
+				ndirCandidates[c.Stat().Direction]++
+			}
 		}
 		s.Unlock()
 	}
 	cm.plk.RUnlock()
 
-	if ncandidates < cm.cfg.lowWater {
-		log.Info("open connection count above limit but too many are in the grace period")
-		// We have too many connections but fewer than lowWater
-		// connections out of the grace period.
-		//
-		// If we trimmed now, we'd kill potentially useful connections.
+	// target is the number of connections to close overall, dirTarget the
+	// number to close in each direction.
+	var target int
+	if trimAll {
+		if ncandidates < cm.cfg.lowWater {
+			// We have too many connections but fewer than lowWater
+			// connections out of the grace period.
+			//
+			// If we trimmed now, we'd kill potentially useful connections.
+			log.Info("open connection count above limit but too many are in the grace period")
+		} else {
+			target = ncandidates - cm.cfg.lowWater
+		}
+	}
+	var dirTarget [3]int // indexed by network.Direction
+	for _, dir := range []network.Direction{network.DirInbound, network.DirOutbound} {
+		if low := cm.cfg.watermarksFor(dir).low; trimDir[dir] && ndirCandidates[dir] >= low {
+			dirTarget[dir] = ndirCandidates[dir] - low
+		}
+	}
+	if target <= 0 && dirTarget[network.DirInbound] <= 0 && dirTarget[network.DirOutbound] <= 0 {
 		return nil
 	}
 
 	// Sort peers according to their value.
 	candidates.SortByValueAndStreams(&cm.segments, false)
 
-	target := ncandidates - cm.cfg.lowWater
-
 	// slightly overallocate because we may have more than one conns per peer
-	selected := make([]network.Conn, 0, target+10)
+	selected := make([]network.Conn, 0, max(target, dirTarget[network.DirInbound], dirTarget[network.DirOutbound])+10)
 
 	for _, inf := range candidates {
-		if target <= 0 {
+		if target <= 0 && dirTarget[network.DirInbound] <= 0 && dirTarget[network.DirOutbound] <= 0 {
 			break
 		}
 
@@ -524,10 +626,18 @@ func (cm *BasicConnMgr) getConnsToClose() []network.Conn {
 			// and still holds no connections, so prune it.
 			delete(s.peers, inf.id)
 		} else {
+			// Unless we're trimming connections overall, only close the
+			// peer's connections in the directions that are over budget.
+			trimPeer := target > 0
 			for c := range inf.conns {
+				dir := c.Stat().Direction
+				if !trimPeer && dirTarget[dir] <= 0 {
+					continue
+				}
 				selected = append(selected, c)
+				target--
+				dirTarget[dir]--
 			}
-			target -= len(inf.conns)
 		}
 		s.Unlock()
 	}
@@ -567,6 +677,73 @@ func (cm *BasicConnMgr) GetTagInfo(p peer.ID) *connmgr.TagInfo {
 	return out
 }
 
+// TagValue is the value a single tag contributes to a peer.
+type TagValue struct {
+	Name  string
+	Value int
+
+	// Decaying is true for decaying tags. Added and LastVisit are only set
+	// for decaying tags.
+	Decaying  bool
+	Added     time.Time
+	LastVisit time.Time
+}
+
+// TagBreakdown details the tags of a peer.
+type TagBreakdown struct {
+	Peer peer.ID
+	// Value is the sum of the values of all tags.
+	Value int
+	// Protected holds the tags the peer is protected with, sorted by name.
+	Protected []string
+	// Tags holds the tags of the peer, in descending order of value.
+	Tags []TagValue
+}
+
+// TagBreakdown returns the tags and protections of peer p, or nil if the
+// connection manager knows neither.
+func (cm *BasicConnMgr) TagBreakdown(p peer.ID) *TagBreakdown {
+	out := &TagBreakdown{Peer: p}
+
+	cm.plk.Lock()
+	for t := range cm.protected[p] {
+		out.Protected = append(out.Protected, t)
+	}
+	cm.plk.Unlock()
+	sort.Strings(out.Protected)
+
+	s := cm.segments.get(p)
+	s.Lock()
+	pi, ok := s.peers[p]
+	if ok {
+		out.Value = pi.value
+		for t, v := range pi.tags {
+			out.Tags = append(out.Tags, TagValue{Name: t, Value: v})
+		}
+		for t, v := range pi.decaying {
+			out.Tags = append(out.Tags, TagValue{
+				Name:      t.name,
+				Value:     v.Value,
+				Decaying:  true,
+				Added:     v.Added,
+				LastVisit: v.LastVisit,
+			})
+		}
+	}
+	s.Unlock()
+
+	if !ok && len(out.Protected) == 0 {
+		return nil
+	}
+	sort.Slice(out.Tags, func(i, j int) bool {
+		if out.Tags[i].Value != out.Tags[j].Value {
+			return out.Tags[i].Value > out.Tags[j].Value
+		}
+		return out.Tags[i].Name < out.Tags[j].Name
+	})
+	return out
+}
+
 // TagPeer is called to associate a string and integer with a given peer.
 func (cm *BasicConnMgr) TagPeer(p peer.ID, tag string, val int) {
 	s := cm.segments.get(p)
@@ -627,6 +804,14 @@ type CMInfo struct {
 
 	// The current connection count.
 	ConnCount int
+
+	// The watermarks for inbound and outbound connections. They are zero if
+	// not set, see WithInboundWatermarks and WithOutboundWatermarks.
+	InboundLowWater, InboundHighWater   int
+	OutboundLowWater, OutboundHighWater int
+
+	// The current inbound and outbound connection counts.
+	InboundConnCount, OutboundConnCount int
 }
 
 // GetInfo returns the configuration and status data for this connection manager.
@@ -641,6 +826,13 @@ func (cm *BasicConnMgr) GetInfo() CMInfo {
 		LastTrim:    lastTrim,
 		GracePeriod: cm.cfg.gracePeriod,
 		ConnCount:   int(cm.connCount.Load()),
+
+		InboundLowWater:   cm.cfg.inbound.low,
+		InboundHighWater:  cm.cfg.inbound.high,
+		OutboundLowWater:  cm.cfg.outbound.low,
+		OutboundHighWater: cm.cfg.outbound.high,
+		InboundConnCount:  int(cm.inboundCount.Load()),
+		OutboundConnCount: int(cm.outboundCount.Load()),
 	}
 }
 
@@ -695,6 +887,9 @@ func (nn *cmNotifee) Connected(_ network.Network, c network.Conn) {
 
 	pinfo.conns[c] = cm.clock.Now()
 	cm.connCount.Add(1)
+	if n := cm.dirConnCount(c.Stat().Direction); n != nil {
+		n.Add(1)
+	}
 }
 
 // Disconnected is called by notifiers to inform that an existing connection has been closed or terminated.
@@ -724,6 +919,9 @@ func (nn *cmNotifee) Disconnected(_ network.Network, c network.Conn) {
 		delete(s.peers, p)
 	}
 	cm.connCount.Add(-1)
+	if n := cm.dirConnCount(c.Stat().Direction); n != nil {
+		n.Add(-1)
+	}
 }
 
 // Listen is no-op in this implementation.
@@ -219,6 +219,85 @@ func TestConnsToClose(t *testing.T) {
 	})
 }
 
+type dirConn struct {
+	*tconn
+	dir network.Direction
+}
+
+func (c *dirConn) Stat() network.ConnStats {
+	return network.ConnStats{
+		Stats:      network.Stats{Direction: c.dir},
+		NumStreams: 1,
+	}
+}
+
+func TestDirectionalWatermarks(t *testing.T) {
+	addConns := func(cm *BasicConnMgr, dir network.Direction, n int) {
+		not := cm.Notifee()
+		for i := 0; i < n; i++ {
+			not.Connected(nil, &dirConn{tconn: randConn(t, nil).(*tconn), dir: dir})
+		}
+	}
+	countDirs := func(conns []network.Conn) (in, out int) {
+		for _, c := range conns {
+			if c.Stat().Direction == network.DirInbound {
+				in++
+			} else {
+				out++
+			}
+		}
+		return
+	}
+
+	t.Run("only the direction over budget is trimmed", func(t *testing.T) {
+		cm, err := NewConnManager(0, 0, WithGracePeriod(0), WithInboundWatermarks(5, 10))
+		require.NoError(t, err)
+		defer cm.Close()
+		addConns(cm, network.DirInbound, 8)
+		addConns(cm, network.DirOutbound, 20)
+		require.False(t, cm.aboveHighWater())
+		addConns(cm, network.DirInbound, 2)
+		require.True(t, cm.aboveHighWater())
+
+		in, out := countDirs(cm.getConnsToClose())
+		require.Equal(t, 5, in)
+		require.Zero(t, out)
+	})
+
+	t.Run("both directions", func(t *testing.T) {
+		cm, err := NewConnManager(0, 0, WithGracePeriod(0), WithInboundWatermarks(5, 10), WithOutboundWatermarks(1, 2))
+		require.NoError(t, err)
+		defer cm.Close()
+		addConns(cm, network.DirInbound, 8)
+		addConns(cm, network.DirOutbound, 3)
+
+		in, out := countDirs(cm.getConnsToClose())
+		require.Equal(t, 3, in)
+		require.Equal(t, 2, out)
+
+		info := cm.GetInfo()
+		require.Equal(t, 8, info.InboundConnCount)
+		require.Equal(t, 3, info.OutboundConnCount)
+		require.Equal(t, 5, info.InboundLowWater)
+		require.Equal(t, 2, info.OutboundHighWater)
+	})
+
+	t.Run("overall watermarks still apply", func(t *testing.T) {
+		cm, err := NewConnManager(4, 6, WithGracePeriod(0), WithInboundWatermarks(5, 10))
+		require.NoError(t, err)
+		defer cm.Close()
+		addConns(cm, network.DirOutbound, 8)
+		require.Len(t, cm.getConnsToClose(), 4)
+	})
+
+	t.Run("invalid watermarks", func(t *testing.T) {
+		_, err := NewConnManager(0, 0, WithOutboundWatermarks(10, 5))
+		require.Error(t, err)
+		_, err = NewConnManager(0, 0, WithInboundWatermarks(-1, 5))
+		require.Error(t, err)
+	})
+}
+
 func TestGetTagInfo(t *testing.T) {
 	start := time.Now()
 	cm, err := NewConnManager(1, 1, WithGracePeriod(10*time.Minute))
@@ -1074,3 +1153,27 @@ func TestErrorCode(t *testing.T) {
 	_, err = cr.NewStream(context.Background())
 	require.ErrorIs(t, err, &network.ConnError{ErrorCode: network.ConnGarbageCollected, Remote: true})
 }
+
+func TestShedConns(t *testing.T) {
+	// high watermarks and grace period that would normally prevent any trim
+	cm, err := NewConnManager(100, 200, WithGracePeriod(time.Hour), WithSilencePeriod(time.Hour))
+	require.NoError(t, err)
+	defer cm.Close()
+	not := cm.Notifee()
+
+	var conns []network.Conn
+	for i := 0; i < 10; i++ {
+		rc := randConn(t, not.Disconnected)
+		conns = append(conns, rc)
+		not.Connected(nil, rc)
+		cm.TagPeer(rc.RemotePeer(), "test", i)
+	}
+	// the lowest-value peer is protected
+	cm.Protect(conns[0].RemotePeer(), "keep")
+
+	require.Equal(t, 3, cm.ShedConns(3))
+	for i, c := range conns {
+		require.Equal(t, i >= 1 && i <= 3, c.(*tconn).isClosed(), "conn %d", i)
+	}
+	require.Zero(t, cm.ShedConns(0))
+}
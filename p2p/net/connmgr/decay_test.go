@@ -316,6 +316,99 @@ func TestTagClosure(t *testing.T) {
 	require.Error(t, tag1.Bump(id, 5))
 }
 
+func TestExponentialDecay(t *testing.T) {
+	id := tu.RandPeerIDFatal(t)
+	mgr, decay, mockClock := testDecayTracker(t)
+
+	tag1, err := decay.RegisterDecayingTag("beep", 250*time.Millisecond, connmgr.DecayExponential(250*time.Millisecond), connmgr.BumpSumUnbounded())
+	require.NoError(t, err)
+	tag2, err := decay.RegisterDecayingTag("bop", 250*time.Millisecond, connmgr.DecayExponential(500*time.Millisecond), connmgr.BumpSumUnbounded())
+	require.NoError(t, err)
+
+	require.NoError(t, tag1.Bump(id, 1000))
+	require.NoError(t, tag2.Bump(id, 1000))
+	waitForTag(t, mgr, id)
+	eventuallyEqual(t, func() int { return mgr.GetTagInfo(id).Value }, 2000)
+
+	mockClock.Add(250 * time.Millisecond)
+	eventuallyEqual(t, func() int { return mgr.GetTagInfo(id).Tags["beep"] }, 500)
+	require.Equal(t, 707, mgr.GetTagInfo(id).Tags["bop"])
+
+	// the value halves every half-life, not faster
+	mockClock.Add(250 * time.Millisecond)
+	eventuallyEqual(t, func() int { return mgr.GetTagInfo(id).Tags["beep"] }, 250)
+	require.Equal(t, 500, mgr.GetTagInfo(id).Tags["bop"])
+
+	mockClock.Add(500 * time.Millisecond)
+	eventuallyEqual(t, func() int { return mgr.GetTagInfo(id).Tags["beep"] }, 62)
+	require.Equal(t, 250, mgr.GetTagInfo(id).Tags["bop"])
+}
+
+func TestSlidingWindowDecay(t *testing.T) {
+	id := tu.RandPeerIDFatal(t)
+	mgr, decay, mockClock := testDecayTracker(t)
+
+	decayFn, bumpFn := connmgr.DecaySlidingWindow(500 * time.Millisecond)
+	tag, err := decay.RegisterDecayingTag("window", 250*time.Millisecond, decayFn, bumpFn)
+	require.NoError(t, err)
+
+	require.NoError(t, tag.Bump(id, 10))
+	waitForTag(t, mgr, id)
+	eventuallyEqual(t, func() int { return mgr.GetTagInfo(id).Value }, 10)
+
+	mockClock.Add(250 * time.Millisecond)
+	require.NoError(t, tag.Bump(id, 5))
+	eventuallyEqual(t, func() int { return mgr.GetTagInfo(id).Value }, 15)
+
+	// the first bump leaves the window
+	mockClock.Add(250 * time.Millisecond)
+	eventuallyEqual(t, func() int { return mgr.GetTagInfo(id).Value }, 5)
+
+	// the second one too, and the tag is erased
+	mockClock.Add(250 * time.Millisecond)
+	eventuallyEqual(t, func() int { return mgr.GetTagInfo(id).Value }, 0)
+	require.NotContains(t, mgr.GetTagInfo(id).Tags, "window")
+
+	// bumping again starts a new window
+	require.NoError(t, tag.Bump(id, 3))
+	eventuallyEqual(t, func() int { return mgr.GetTagInfo(id).Value }, 3)
+}
+
+func TestTagBreakdown(t *testing.T) {
+	id := tu.RandPeerIDFatal(t)
+	mgr, decay, _ := testDecayTracker(t)
+
+	require.Nil(t, mgr.TagBreakdown(id))
+
+	tag, err := decay.RegisterDecayingTag("beep", 250*time.Millisecond, connmgr.DecayNone(), connmgr.BumpSumUnbounded())
+	require.NoError(t, err)
+	require.NoError(t, tag.Bump(id, 10))
+	waitForTag(t, mgr, id)
+	mgr.TagPeer(id, "foo", 20)
+	mgr.TagPeer(id, "bar", 5)
+	mgr.Protect(id, "b")
+	mgr.Protect(id, "a")
+
+	tb := mgr.TagBreakdown(id)
+	require.Equal(t, id, tb.Peer)
+	require.Equal(t, 35, tb.Value)
+	require.Equal(t, []string{"a", "b"}, tb.Protected)
+	require.Len(t, tb.Tags, 3)
+	require.Equal(t, TagValue{Name: "foo", Value: 20}, tb.Tags[0])
+	require.Equal(t, "beep", tb.Tags[1].Name)
+	require.Equal(t, 10, tb.Tags[1].Value)
+	require.True(t, tb.Tags[1].Decaying)
+	require.False(t, tb.Tags[1].Added.IsZero())
+	require.Equal(t, TagValue{Name: "bar", Value: 5}, tb.Tags[2])
+
+	// protected peers are reported even if they have no tags
+	other := tu.RandPeerIDFatal(t)
+	mgr.Protect(other, "a")
+	tb = mgr.TagBreakdown(other)
+	require.Equal(t, []string{"a"}, tb.Protected)
+	require.Empty(t, tb.Tags)
+}
+
 func testDecayTracker(tb testing.TB) (*BasicConnMgr, connmgr.Decayer, *clock.Mock) {
 	mockClock := clock.NewMock()
 	cfg := &DecayerCfg{
@@ -2,9 +2,11 @@ package connmgr
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/benbjohnson/clock"
+	"github.com/libp2p/go-libp2p/core/network"
 )
 
 // config is the configuration struct for the basic connection manager.
@@ -15,6 +17,30 @@ type config struct {
 	silencePeriod time.Duration
 	decayer       *DecayerCfg
 	clock         clock.Clock
+	// watermarks for inbound and outbound connections, see
+	// WithInboundWatermarks and WithOutboundWatermarks.
+	inbound, outbound watermarks
+}
+
+// watermarks are the low and high watermarks for the connections in one
+// direction. The zero value disables trimming for the direction.
+type watermarks struct {
+	low, high int
+}
+
+func (w watermarks) enabled() bool {
+	return w.low != 0 && w.high != 0
+}
+
+func (cfg *config) watermarksFor(dir network.Direction) watermarks {
+	switch dir {
+	case network.DirInbound:
+		return cfg.inbound
+	case network.DirOutbound:
+		return cfg.outbound
+	default:
+		return watermarks{}
+	}
 }
 
 // Option represents an option for the basic connection manager.
@@ -61,3 +87,43 @@ func WithSilencePeriod(p time.Duration) Option {
 		return nil
 	}
 }
+
+// WithInboundWatermarks sets separate watermarks for inbound connections. When
+// the number of inbound connections exceeds high, inbound connections are
+// trimmed until low remain. The overall watermarks passed to NewConnManager
+// still apply.
+func WithInboundWatermarks(low, high int) Option {
+	return func(cfg *config) error {
+		w, err := newWatermarks(low, high)
+		if err != nil {
+			return fmt.Errorf("invalid inbound watermarks: %w", err)
+		}
+		cfg.inbound = w
+		return nil
+	}
+}
+
+// WithOutboundWatermarks sets separate watermarks for outbound connections.
+// When the number of outbound connections exceeds high, outbound connections
+// are trimmed until low remain. The overall watermarks passed to
+// NewConnManager still apply.
+func WithOutboundWatermarks(low, high int) Option {
+	return func(cfg *config) error {
+		w, err := newWatermarks(low, high)
+		if err != nil {
+			return fmt.Errorf("invalid outbound watermarks: %w", err)
+		}
+		cfg.outbound = w
+		return nil
+	}
+}
+
+func newWatermarks(low, high int) (watermarks, error) {
+	if low < 0 || high < 0 {
+		return watermarks{}, errors.New("watermarks must be non-negative")
+	}
+	if low > high {
+		return watermarks{}, errors.New("low watermark must not exceed high watermark")
+	}
+	return watermarks{low: low, high: high}, nil
+}
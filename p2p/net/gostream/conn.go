@@ -31,6 +31,15 @@ func newConn(s network.Stream, ignoreEOF bool) net.Conn {
 	return &conn{s, ignoreEOF}
 }
 
+// UnderlyingStream returns the libp2p stream wrapped by a net.Conn returned by
+// this package. It returns false for any other net.Conn.
+func UnderlyingStream(c net.Conn) (network.Stream, bool) {
+	if c, ok := c.(*conn); ok {
+		return c.Stream, true
+	}
+	return nil, false
+}
+
 // LocalAddr returns the local network address.
 func (c *conn) LocalAddr() net.Addr {
 	return &addr{c.Stream.Conn().LocalPeer()}
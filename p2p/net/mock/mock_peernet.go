@@ -185,8 +185,8 @@ func checkSecureAndUpgrade(dir network.Direction, gater connmgr.ConnectionGater,
 	if gater == nil {
 		return nil
 	}
-	if !gater.InterceptSecured(dir, c.remote, c) {
-		return fmt.Errorf("%v rejected secure handshake with %v", c.local, c.remote)
+	if err := connmgr.InterceptSecuredContext(context.Background(), gater, dir, c.remote, connmgr.SecuredConnInfo{ConnMultiaddrs: c}); err != nil {
+		return err
 	}
 	allow, _ := gater.InterceptUpgraded(c)
 	if !allow {
@@ -130,27 +130,21 @@ func (s *stream) Close() error {
 }
 
 func (s *stream) Reset() error {
-	// Cancel any pending reads/writes with an error.
-	s.write.CloseWithError(network.ErrReset)
-	s.read.CloseWithError(network.ErrReset)
-
-	select {
-	case s.reset <- struct{}{}:
-	default:
-	}
-	<-s.closed
+	return s.resetWith(network.ErrReset)
+}
 
-	// No meaningful error case here.
-	return nil
+// ResetWithError resets the stream. The remote stream fails reads and writes
+// with a *network.StreamError carrying errCode.
+func (s *stream) ResetWithError(errCode network.StreamErrorCode) error {
+	return s.resetWith(&network.StreamError{ErrorCode: errCode, Remote: true})
 }
 
-// ResetWithError resets the stream. It ignores the provided error code.
-// TODO: Implement error code support.
-func (s *stream) ResetWithError(_ network.StreamErrorCode) error {
+// resetWith resets the stream, failing the reads and writes of the remote
+// stream with remoteErr.
+func (s *stream) resetWith(remoteErr error) error {
 	// Cancel any pending reads/writes with an error.
-
-	s.write.CloseWithError(network.ErrReset)
-	s.read.CloseWithError(network.ErrReset)
+	s.write.CloseWithError(remoteErr)
+	s.read.CloseWithError(remoteErr)
 
 	select {
 	case s.reset <- struct{}{}:
@@ -350,6 +350,30 @@ func TestStreams(t *testing.T) {
 
 }
 
+func TestStreamResetWithError(t *testing.T) {
+	mn, err := FullMeshConnected(2)
+	require.NoError(t, err)
+	defer mn.Close()
+
+	hosts := mn.Hosts()
+	hosts[1].SetStreamHandler(protocol.TestingID, func(s network.Stream) {
+		b := make([]byte, 4)
+		if _, err := io.ReadFull(s, b); err != nil {
+			s.Reset()
+			return
+		}
+		s.ResetWithError(42)
+	})
+
+	s, err := hosts[0].NewStream(context.Background(), hosts[1].ID(), protocol.TestingID)
+	require.NoError(t, err)
+	_, err = s.Write([]byte("beep"))
+	require.NoError(t, err)
+	_, err = s.Read(make([]byte, 1))
+	require.ErrorIs(t, err, &network.StreamError{ErrorCode: 42, Remote: true})
+	require.ErrorIs(t, err, network.ErrReset)
+}
+
 func TestAdding(t *testing.T) {
 	mn := New()
 	defer mn.Close()
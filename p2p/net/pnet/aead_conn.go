@@ -0,0 +1,268 @@
+package pnet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/pnet"
+
+	pool "github.com/libp2p/go-buffer-pool"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// The negotiated protector starts with both sides sending a hello: the
+// versioned magic, a bitmask of the modes they support and a random salt.
+// The keys of both directions are derived from the PSK and both hellos, so
+// that tampering with the hellos makes the first frame fail to authenticate.
+// The side with the lower salt writes with the low key and reads with the high
+// key, so a peer reflecting our hello can never make both keys the same.
+// Data is then sent in frames of a 2 byte big endian length followed by the
+// sealed payload. Frame nonces are counters, which is safe because the keys
+// are unique to the connection.
+const (
+	helloMagic   = "/pnet/2\n"
+	saltLen      = 32
+	helloLen     = len(helloMagic) + 1 + saltLen
+	maxFrameData = 16 * 1024
+)
+
+// modePreference is the order the negotiated modes are preferred in.
+var modePreference = []Mode{ModeAESGCM, ModeXChaCha20Poly1305}
+
+var (
+	errShortHello   = pnet.NewError("could not read full protector hello")
+	errBadHello     = pnet.NewError("peer didn't send a protector hello, is it using the legacy protector?")
+	errNoCommonMode = pnet.NewError("no common protector mode")
+	errReflected    = pnet.NewError("peer reflected the protector hello")
+	errFrameSize    = pnet.NewError("invalid frame size")
+	errFrameAuth    = pnet.NewError("frame failed to authenticate")
+	errNonceLimit   = pnet.NewError("frame counter exhausted")
+)
+
+type aeadConn struct {
+	net.Conn
+	kp    pnet.KeyProvider
+	modes []Mode
+
+	handshakeOnce sync.Once
+	handshakeErr  error
+	mode          Mode
+
+	writeAEAD  cipher.AEAD
+	writeCount uint64
+
+	readAEAD  cipher.AEAD
+	readCount uint64
+	readFrame []byte
+	readBuf   []byte // decrypted data that hasn't been read yet
+}
+
+var _ net.Conn = (*aeadConn)(nil)
+
+func newAEADConn(kp pnet.KeyProvider, insecure net.Conn, modes []Mode) (*aeadConn, error) {
+	if insecure == nil {
+		return nil, errInsecureNil
+	}
+	if kp == nil {
+		return nil, errPSKNil
+	}
+	return &aeadConn{
+		Conn:  insecure,
+		kp:    kp,
+		modes: modes,
+	}, nil
+}
+
+func modeMask(modes []Mode) byte {
+	var mask byte
+	for _, m := range modes {
+		mask |= 1 << m
+	}
+	return mask
+}
+
+func (c *aeadConn) handshake() error {
+	c.handshakeOnce.Do(func() {
+		c.handshakeErr = c.runHandshake()
+	})
+	return c.handshakeErr
+}
+
+func (c *aeadConn) runHandshake() error {
+	hello := make([]byte, helloLen)
+	copy(hello, helloMagic)
+	hello[len(helloMagic)] = modeMask(c.modes)
+	salt := hello[len(helloMagic)+1:]
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+
+	// write concurrently with reading, so that neither side blocks on
+	// unbuffered connections
+	werr := make(chan error, 1)
+	go func() {
+		_, err := c.Conn.Write(hello)
+		werr <- err
+	}()
+	remote := make([]byte, helloLen)
+	_, rerr := io.ReadFull(c.Conn, remote)
+	if err := <-werr; err != nil {
+		return err
+	}
+	if rerr != nil {
+		return fmt.Errorf("%w: %w", errShortHello, rerr)
+	}
+
+	if !bytes.Equal(remote[:len(helloMagic)], []byte(helloMagic)) {
+		return errBadHello
+	}
+	remoteSalt := remote[len(helloMagic)+1:]
+	// compare the salts only, as the rest of the hello may be altered
+	if bytes.Equal(remoteSalt, salt) {
+		return errReflected
+	}
+	common := modeMask(c.modes) & remote[len(helloMagic)]
+	found := false
+	for _, m := range modePreference {
+		if common&(1<<m) != 0 {
+			c.mode, found = m, true
+			break
+		}
+	}
+	if !found {
+		return errNoCommonMode
+	}
+
+	// both sides need to agree on the transcript
+	transcript := append(hello, remote...)
+	if bytes.Compare(hello, remote) > 0 {
+		transcript = append(remote, hello...)
+	}
+	writeRole, readRole := roleLow, roleHigh
+	if bytes.Compare(salt, remoteSalt) > 0 {
+		writeRole, readRole = roleHigh, roleLow
+	}
+	var err error
+	if c.writeAEAD, err = c.deriveAEAD(transcript, writeRole); err != nil {
+		return err
+	}
+	c.readAEAD, err = c.deriveAEAD(transcript, readRole)
+	return err
+}
+
+// The roles of the directions of a connection. The side that sent the lower
+// salt writes in the low direction.
+const (
+	roleLow  = "low"
+	roleHigh = "high"
+)
+
+// deriveAEAD derives the AEAD of the direction role.
+func (c *aeadConn) deriveAEAD(transcript []byte, role string) (cipher.AEAD, error) {
+	info := append([]byte(helloMagic), byte(c.mode))
+	info = append(info, role...)
+	key, err := c.kp.DeriveKey(transcript, info)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key provider derived a %d byte key, expected 32 bytes", len(key))
+	}
+	switch c.mode {
+	case ModeAESGCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case ModeXChaCha20Poly1305:
+		return chacha20poly1305.NewX(key)
+	default:
+		return nil, fmt.Errorf("unsupported mode %s", c.mode)
+	}
+}
+
+func nonce(aead cipher.AEAD, count uint64) []byte {
+	n := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(n[len(n)-8:], count)
+	return n
+}
+
+func (c *aeadConn) Read(out []byte) (int, error) {
+	if err := c.handshake(); err != nil {
+		return 0, err
+	}
+	for len(c.readBuf) == 0 {
+		if err := c.readNextFrame(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(out, c.readBuf)
+	c.readBuf = c.readBuf[n:]
+	return n, nil
+}
+
+func (c *aeadConn) readNextFrame() error {
+	var lenBuf [2]byte
+	if _, err := io.ReadFull(c.Conn, lenBuf[:]); err != nil {
+		return err
+	}
+	l := int(binary.BigEndian.Uint16(lenBuf[:]))
+	overhead := c.readAEAD.Overhead()
+	if l < overhead || l > maxFrameData+overhead {
+		return errFrameSize
+	}
+	if c.readFrame == nil {
+		c.readFrame = make([]byte, maxFrameData+overhead)
+	}
+	frame := c.readFrame[:l]
+	if _, err := io.ReadFull(c.Conn, frame); err != nil {
+		return err
+	}
+	if c.readCount == ^uint64(0) {
+		return errNonceLimit
+	}
+	data, err := c.readAEAD.Open(frame[:0], nonce(c.readAEAD, c.readCount), frame, nil)
+	if err != nil {
+		return errFrameAuth
+	}
+	c.readCount++
+	c.readBuf = data
+	return nil
+}
+
+func (c *aeadConn) Write(in []byte) (int, error) {
+	if err := c.handshake(); err != nil {
+		return 0, err
+	}
+	overhead := c.writeAEAD.Overhead()
+	var written int
+	for len(in) > 0 {
+		data := in
+		if len(data) > maxFrameData {
+			data = data[:maxFrameData]
+		}
+		if c.writeCount == ^uint64(0) {
+			return written, errNonceLimit
+		}
+		frame := pool.Get(2 + len(data) + overhead)
+		binary.BigEndian.PutUint16(frame, uint16(len(data)+overhead))
+		c.writeAEAD.Seal(frame[2:2], nonce(c.writeAEAD, c.writeCount), data, nil)
+		c.writeCount++
+		_, err := c.Conn.Write(frame)
+		pool.Put(frame)
+		if err != nil {
+			return written, err
+		}
+		written += len(data)
+		in = in[len(data):]
+	}
+	return written, nil
+}
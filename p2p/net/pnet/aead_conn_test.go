@@ -0,0 +1,172 @@
+package pnet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+func setupAEADConns(t *testing.T, modes1, modes2 []Mode) (net.Conn, net.Conn) {
+	testPSK := make([]byte, 32)
+	conn1, conn2 := net.Pipe()
+	t.Cleanup(func() {
+		conn1.Close()
+		conn2.Close()
+	})
+
+	c1, err := NewProtectedConn(testPSK, conn1, WithModes(modes1...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := NewProtectedConn(testPSK, conn2, WithModes(modes2...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c1, c2
+}
+
+func TestAEADModes(t *testing.T) {
+	for _, tc := range []struct {
+		modes1, modes2 []Mode
+		expected       Mode
+	}{
+		{[]Mode{ModeAESGCM}, []Mode{ModeAESGCM}, ModeAESGCM},
+		{[]Mode{ModeXChaCha20Poly1305}, []Mode{ModeXChaCha20Poly1305}, ModeXChaCha20Poly1305},
+		{[]Mode{ModeXChaCha20Poly1305, ModeAESGCM}, []Mode{ModeAESGCM, ModeXChaCha20Poly1305}, ModeAESGCM},
+		{[]Mode{ModeXChaCha20Poly1305, ModeAESGCM}, []Mode{ModeXChaCha20Poly1305}, ModeXChaCha20Poly1305},
+	} {
+		t.Run(tc.expected.String(), func(t *testing.T) {
+			c1, c2 := setupAEADConns(t, tc.modes1, tc.modes2)
+
+			in := make([]byte, 3*maxFrameData+100)
+			if _, err := rand.Read(in); err != nil {
+				t.Fatal(err)
+			}
+			wch := make(chan error, 1)
+			go func() {
+				_, err := c1.Write(in)
+				wch <- err
+			}()
+			out := make([]byte, len(in))
+			if _, err := io.ReadFull(c2, out); err != nil {
+				t.Fatal(err)
+			}
+			if err := <-wch; err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(in, out) {
+				t.Fatal("input and output are not the same")
+			}
+
+			for _, c := range []net.Conn{c1, c2} {
+				if m := c.(*aeadConn).mode; m != tc.expected {
+					t.Fatalf("expected mode %s, got %s", tc.expected, m)
+				}
+			}
+		})
+	}
+}
+
+func TestAEADNoCommonMode(t *testing.T) {
+	c1, c2 := setupAEADConns(t, []Mode{ModeAESGCM}, []Mode{ModeXChaCha20Poly1305})
+	go c1.Write([]byte("hello"))
+	if _, err := c2.Read(make([]byte, 5)); !errors.Is(err, errNoCommonMode) {
+		t.Fatalf("expected no common mode error, got %v", err)
+	}
+}
+
+func TestAEADLegacyPeer(t *testing.T) {
+	testPSK := make([]byte, 32)
+	conn1, conn2 := net.Pipe()
+	defer conn1.Close()
+	defer conn2.Close()
+
+	legacy, err := NewProtectedConn(testPSK, conn1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewProtectedConn(testPSK, conn2, WithModes(ModeAESGCM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go legacy.Write(make([]byte, 100))
+	go io.Copy(io.Discard, legacy)
+	if _, err := c.Read(make([]byte, 1)); !errors.Is(err, errBadHello) {
+		t.Fatalf("expected bad hello error, got %v", err)
+	}
+}
+
+// tamperConn flips a bit of the byte at offset pos of the written stream.
+type tamperConn struct {
+	net.Conn
+	pos, written int
+}
+
+func (c *tamperConn) Write(b []byte) (int, error) {
+	if c.pos >= c.written && c.pos < c.written+len(b) {
+		b = append([]byte(nil), b...)
+		b[c.pos-c.written] ^= 1
+	}
+	c.written += len(b)
+	return c.Conn.Write(b)
+}
+
+func TestAEADTampering(t *testing.T) {
+	for _, pos := range []int{
+		len(helloMagic), // the modes
+		helloLen + 10,   // the first frame
+	} {
+		testPSK := make([]byte, 32)
+		conn1, conn2 := net.Pipe()
+		c1, err := NewProtectedConn(testPSK, &tamperConn{Conn: conn1, pos: pos}, WithModes(ModeAESGCM, ModeXChaCha20Poly1305))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c2, err := NewProtectedConn(testPSK, conn2, WithModes(ModeAESGCM, ModeXChaCha20Poly1305))
+		if err != nil {
+			t.Fatal(err)
+		}
+		go c1.Write([]byte("hello world"))
+		go io.Copy(io.Discard, c1)
+		if _, err := c2.Read(make([]byte, 11)); !errors.Is(err, errFrameAuth) {
+			t.Fatalf("expected authentication error when tampering with byte %d, got %v", pos, err)
+		}
+		conn1.Close()
+		conn2.Close()
+	}
+}
+
+func TestAEADReflectedHello(t *testing.T) {
+	conn1, conn2 := net.Pipe()
+	defer conn1.Close()
+	defer conn2.Close()
+
+	c, err := NewProtectedConn(make([]byte, 32), conn1, WithModes(ModeAESGCM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// send our hello back with an unused mode bit flipped
+	go func() {
+		hello := make([]byte, helloLen)
+		if _, err := io.ReadFull(conn2, hello); err != nil {
+			return
+		}
+		hello[len(helloMagic)] ^= 1 << 7
+		conn2.Write(hello)
+	}()
+	if _, err := c.Read(make([]byte, 1)); !errors.Is(err, errReflected) {
+		t.Fatalf("expected reflected hello error, got %v", err)
+	}
+}
+
+func TestAEADInvalidModes(t *testing.T) {
+	if _, err := NewProtectedConn(make([]byte, 32), &net.TCPConn{}, WithModes(ModeXSalsa20)); err == nil {
+		t.Fatal("expected error negotiating the legacy mode")
+	}
+	if _, err := NewProtectedConn(make([]byte, 32), &net.TCPConn{}, WithModes()); err == nil {
+		t.Fatal("expected error without modes")
+	}
+}
@@ -0,0 +1,45 @@
+package pnet
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"errors"
+	"io"
+
+	ipnet "github.com/libp2p/go-libp2p/core/pnet"
+
+	"github.com/davidlazar/go-crypto/salsa20"
+	"golang.org/x/crypto/hkdf"
+)
+
+// pskProvider is a KeyProvider that holds the PSK in memory.
+type pskProvider struct {
+	psk [32]byte
+}
+
+var _ ipnet.KeyProvider = (*pskProvider)(nil)
+
+// NewKeyProvider returns a KeyProvider that holds psk in memory.
+func NewKeyProvider(psk ipnet.PSK) (ipnet.KeyProvider, error) {
+	if len(psk) != 32 {
+		return nil, errors.New("expected 32 byte PSK")
+	}
+	p := &pskProvider{}
+	copy(p.psk[:], psk)
+	return p, nil
+}
+
+func (p *pskProvider) XSalsa20(nonce []byte) (cipher.Stream, error) {
+	if len(nonce) != 24 {
+		return nil, errors.New("expected 24 byte nonce")
+	}
+	return salsa20.New(&p.psk, nonce), nil
+}
+
+func (p *pskProvider) DeriveKey(salt, info []byte) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, p.psk[:], salt, info), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,101 @@
+package pnet
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	ipnet "github.com/libp2p/go-libp2p/core/pnet"
+)
+
+// countingProvider wraps a KeyProvider, like a client of an HSM would.
+type countingProvider struct {
+	ipnet.KeyProvider
+	streams, derived int
+}
+
+func (p *countingProvider) XSalsa20(nonce []byte) (cipher.Stream, error) {
+	p.streams++
+	return p.KeyProvider.XSalsa20(nonce)
+}
+
+func (p *countingProvider) DeriveKey(salt, info []byte) ([]byte, error) {
+	p.derived++
+	return p.KeyProvider.DeriveKey(salt, info)
+}
+
+func TestKeyProvider(t *testing.T) {
+	psk := make([]byte, 32)
+	psk[0] = 42
+	inner, err := NewKeyProvider(psk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opts := range [][]Option{nil, {WithModes(ModeAESGCM)}} {
+		kp := &countingProvider{KeyProvider: inner}
+		conn1, conn2 := net.Pipe()
+		c1, err := NewProtectedConnFromProvider(kp, conn1, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c2, err := NewProtectedConn(psk, conn2, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msg := []byte("hello world")
+		wch := make(chan error, 1)
+		go func() {
+			_, err := c1.Write(msg)
+			wch <- err
+		}()
+		out := make([]byte, len(msg))
+		if _, err := io.ReadFull(c2, out); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-wch; err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(msg, out) {
+			t.Fatal("input and output are not the same")
+		}
+		if opts == nil && kp.streams != 1 {
+			t.Fatalf("expected the provider to create 1 key stream, got %d", kp.streams)
+		}
+		if opts != nil && kp.derived != 2 {
+			t.Fatalf("expected the provider to derive 2 keys, got %d", kp.derived)
+		}
+		conn1.Close()
+		conn2.Close()
+	}
+}
+
+// legacyProvider doesn't support the legacy mode.
+type legacyProvider struct{ ipnet.KeyProvider }
+
+var errNoLegacy = errors.New("legacy mode not supported")
+
+func (legacyProvider) XSalsa20([]byte) (cipher.Stream, error) { return nil, errNoLegacy }
+
+func TestKeyProviderErrors(t *testing.T) {
+	conn1, conn2 := net.Pipe()
+	defer conn1.Close()
+	defer conn2.Close()
+	go io.Copy(io.Discard, conn2)
+
+	c, err := NewProtectedConnFromProvider(legacyProvider{}, conn1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Write([]byte("hello")); !errors.Is(err, errNoLegacy) {
+		t.Fatalf("expected provider error, got %v", err)
+	}
+
+	if _, err := NewKeyProvider(make([]byte, 16)); err == nil {
+		t.Fatal("expected error for short PSK")
+	}
+}
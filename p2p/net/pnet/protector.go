@@ -2,17 +2,96 @@ package pnet
 
 import (
 	"errors"
+	"fmt"
 	"net"
 
 	ipnet "github.com/libp2p/go-libp2p/core/pnet"
 )
 
+// Mode is the cipher a protected connection encrypts data with.
+type Mode uint8
+
+const (
+	// ModeXSalsa20 is the legacy mode: an unauthenticated XSalsa20 stream.
+	// Tampering is only detected by the security protocol running on top.
+	ModeXSalsa20 Mode = iota
+	// ModeAESGCM frames the data and authenticates every frame with
+	// AES-256-GCM.
+	ModeAESGCM
+	// ModeXChaCha20Poly1305 frames the data and authenticates every frame
+	// with XChaCha20-Poly1305.
+	ModeXChaCha20Poly1305
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeXSalsa20:
+		return "xsalsa20"
+	case ModeAESGCM:
+		return "aes-gcm"
+	case ModeXChaCha20Poly1305:
+		return "xchacha20-poly1305"
+	default:
+		return fmt.Sprintf("unknown mode (%d)", uint8(m))
+	}
+}
+
+// Negotiated reports whether m is one of the authenticated modes that are
+// negotiated with WithModes.
+func (m Mode) Negotiated() bool {
+	return m == ModeAESGCM || m == ModeXChaCha20Poly1305
+}
+
+type config struct {
+	modes []Mode
+}
+
+// Option configures NewProtectedConn.
+type Option func(*config) error
+
+// WithModes sets the authenticated modes the connection supports. Both sides
+// exchange the modes they support, and use the first common one in the order
+// ModeAESGCM, ModeXChaCha20Poly1305. The handshake fails if they have none in
+// common.
+//
+// The negotiated modes can't interoperate with the legacy ModeXSalsa20, which
+// is used if WithModes isn't set, so all nodes of a network need to switch
+// together.
+func WithModes(modes ...Mode) Option {
+	return func(cfg *config) error {
+		if len(modes) == 0 {
+			return errors.New("no modes")
+		}
+		for _, m := range modes {
+			if !m.Negotiated() {
+				return fmt.Errorf("mode %s can't be negotiated", m)
+			}
+		}
+		cfg.modes = modes
+		return nil
+	}
+}
+
 // NewProtectedConn creates a new protected connection
-func NewProtectedConn(psk ipnet.PSK, conn net.Conn) (net.Conn, error) {
-	if len(psk) != 32 {
-		return nil, errors.New("expected 32 byte PSK")
+func NewProtectedConn(psk ipnet.PSK, conn net.Conn, opts ...Option) (net.Conn, error) {
+	kp, err := NewKeyProvider(psk)
+	if err != nil {
+		return nil, err
+	}
+	return NewProtectedConnFromProvider(kp, conn, opts...)
+}
+
+// NewProtectedConnFromProvider creates a new protected connection, using kp
+// instead of the raw PSK.
+func NewProtectedConnFromProvider(kp ipnet.KeyProvider, conn net.Conn, opts ...Option) (net.Conn, error) {
+	var cfg config
+	for _, opt := range opts {
+		if err := opt(&cfg); err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.modes) > 0 {
+		return newAEADConn(kp, conn, cfg.modes)
 	}
-	var p [32]byte
-	copy(p[:], psk)
-	return newPSKConn(&p, conn)
+	return newPSKConn(kp, conn)
 }
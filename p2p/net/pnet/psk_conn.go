@@ -9,7 +9,6 @@ import (
 
 	"github.com/libp2p/go-libp2p/core/pnet"
 
-	"github.com/davidlazar/go-crypto/salsa20"
 	pool "github.com/libp2p/go-buffer-pool"
 )
 
@@ -23,7 +22,7 @@ var (
 
 type pskConn struct {
 	net.Conn
-	psk *[32]byte
+	kp pnet.KeyProvider
 
 	writeS20 cipher.Stream
 	readS20  cipher.Stream
@@ -36,7 +35,9 @@ func (c *pskConn) Read(out []byte) (int, error) {
 		if err != nil {
 			return 0, fmt.Errorf("%w: %w", errShortNonce, err)
 		}
-		c.readS20 = salsa20.New(c.psk, nonce)
+		if c.readS20, err = c.kp.XSalsa20(nonce); err != nil {
+			return 0, err
+		}
 	}
 
 	n, err := c.Conn.Read(out) // read to in
@@ -57,8 +58,9 @@ func (c *pskConn) Write(in []byte) (int, error) {
 		if err != nil {
 			return 0, err
 		}
-
-		c.writeS20 = salsa20.New(c.psk, nonce)
+		if c.writeS20, err = c.kp.XSalsa20(nonce); err != nil {
+			return 0, err
+		}
 	}
 	out := pool.Get(len(in))
 	defer pool.Put(out)
@@ -70,15 +72,15 @@ func (c *pskConn) Write(in []byte) (int, error) {
 
 var _ net.Conn = (*pskConn)(nil)
 
-func newPSKConn(psk *[32]byte, insecure net.Conn) (net.Conn, error) {
+func newPSKConn(kp pnet.KeyProvider, insecure net.Conn) (net.Conn, error) {
 	if insecure == nil {
 		return nil, errInsecureNil
 	}
-	if psk == nil {
+	if kp == nil {
 		return nil, errPSKNil
 	}
 	return &pskConn{
 		Conn: insecure,
-		psk:  psk,
+		kp:   kp,
 	}, nil
 }
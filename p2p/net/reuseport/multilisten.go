@@ -0,0 +1,206 @@
+package reuseport
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/libp2p/go-libp2p/p2p/metricshelper"
+
+	tec "github.com/jbenet/go-temp-err-catcher"
+	"github.com/libp2p/go-reuseport"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrReuseportUnavailable is returned by ListenN when it needs more than one
+// socket and reuseport isn't available.
+var ErrReuseportUnavailable = errors.New("reuseport is not available")
+
+// MultiListener accepts connections from several sockets listening on the same
+// address with SO_REUSEPORT. The kernel spreads inbound connections across the
+// sockets, and every socket has its own accept loop.
+type MultiListener struct {
+	listeners []manet.Listener
+	accepts   []atomic.Uint64
+
+	conns     chan acceptResult
+	closed    chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+type acceptResult struct {
+	conn manet.Conn
+	err  error
+}
+
+var _ manet.Listener = (*MultiListener)(nil)
+
+// ListenN listens on laddr with n sockets, see MultiListener. All sockets bind
+// to the port of the first one, so laddr may use port 0. If n is 1, ListenN
+// doesn't require reuseport.
+func (t *Transport) ListenN(laddr ma.Multiaddr, n int) (*MultiListener, error) {
+	if n < 1 {
+		return nil, errors.New("number of listeners must be positive")
+	}
+	if n > 1 && !reuseport.Available() {
+		return nil, ErrReuseportUnavailable
+	}
+
+	m := &MultiListener{
+		listeners: make([]manet.Listener, 0, n),
+		accepts:   make([]atomic.Uint64, n),
+		conns:     make(chan acceptResult),
+		closed:    make(chan struct{}),
+	}
+	for i := 0; i < n; i++ {
+		l, err := t.Listen(laddr)
+		if err != nil {
+			for _, l := range m.listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("listening on socket %d: %w", i, err)
+		}
+		m.listeners = append(m.listeners, l)
+		// the other sockets must bind to the same port
+		laddr = l.Multiaddr()
+	}
+
+	m.wg.Add(n)
+	for i := range m.listeners {
+		go m.acceptLoop(i)
+	}
+	registerMultiListener(m)
+	return m, nil
+}
+
+// acceptLoop accepts connections from the i-th socket. It retries temporary
+// errors with a backoff, and stops when the socket is closed or fails
+// permanently.
+func (m *MultiListener) acceptLoop(i int) {
+	defer m.wg.Done()
+	var catcher tec.TempErrCatcher
+	for {
+		c, err := m.listeners[i].Accept()
+		if err == nil {
+			catcher.Reset()
+			m.accepts[i].Add(1)
+		} else if catcher.IsTemporary(err) {
+			// Note: IsTemporary sleeps to back off.
+			log.Infof("temporary accept error: %s", err)
+			continue
+		}
+		select {
+		case m.conns <- acceptResult{conn: c, err: err}:
+		case <-m.closed:
+			if c != nil {
+				c.Close()
+			}
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Accept waits for the next connection on any of the sockets. It returns an
+// error if accepting from one of the sockets failed.
+func (m *MultiListener) Accept() (manet.Conn, error) {
+	select {
+	case res := <-m.conns:
+		return res.conn, res.err
+	case <-m.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close closes all sockets.
+func (m *MultiListener) Close() error {
+	var err error
+	m.closeOnce.Do(func() {
+		close(m.closed)
+		errs := make([]error, 0, len(m.listeners))
+		for _, l := range m.listeners {
+			errs = append(errs, l.Close())
+		}
+		m.wg.Wait()
+		unregisterMultiListener(m)
+		err = errors.Join(errs...)
+	})
+	return err
+}
+
+// Addr returns the address of the listener.
+func (m *MultiListener) Addr() net.Addr {
+	return m.listeners[0].Addr()
+}
+
+// Multiaddr returns the address of the listener.
+func (m *MultiListener) Multiaddr() ma.Multiaddr {
+	return m.listeners[0].Multiaddr()
+}
+
+// AcceptCounts returns the number of connections accepted by each socket.
+func (m *MultiListener) AcceptCounts() []uint64 {
+	counts := make([]uint64, len(m.accepts))
+	for i := range m.accepts {
+		counts[i] = m.accepts[i].Load()
+	}
+	return counts
+}
+
+var multiListeners struct {
+	sync.Mutex
+	m map[*MultiListener]struct{}
+}
+
+func registerMultiListener(m *MultiListener) {
+	multiListeners.Lock()
+	defer multiListeners.Unlock()
+	if multiListeners.m == nil {
+		multiListeners.m = make(map[*MultiListener]struct{})
+	}
+	multiListeners.m[m] = struct{}{}
+}
+
+func unregisterMultiListener(m *MultiListener) {
+	multiListeners.Lock()
+	defer multiListeners.Unlock()
+	delete(multiListeners.m, m)
+}
+
+var acceptsDesc = prometheus.NewDesc(
+	"libp2p_reuseport_listener_accepts_total",
+	"Connections accepted by each socket of a listener with several accept loops",
+	[]string{"addr", "socket"}, nil,
+)
+
+type acceptCollector struct{}
+
+func (acceptCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- acceptsDesc
+}
+
+func (acceptCollector) Collect(ch chan<- prometheus.Metric) {
+	multiListeners.Lock()
+	defer multiListeners.Unlock()
+	for m := range multiListeners.m {
+		addr := m.Multiaddr().String()
+		for i, n := range m.AcceptCounts() {
+			ch <- prometheus.MustNewConstMetric(acceptsDesc, prometheus.CounterValue, float64(n), addr, strconv.Itoa(i))
+		}
+	}
+}
+
+// RegisterAcceptMetrics registers the per-socket accept metrics of all
+// MultiListeners with reg, to verify that the kernel spreads connections
+// evenly.
+func RegisterAcceptMetrics(reg prometheus.Registerer) {
+	metricshelper.RegisterCollectors(reg, acceptCollector{})
+}
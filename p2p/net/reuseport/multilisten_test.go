@@ -0,0 +1,131 @@
+package reuseport
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/libp2p/go-reuseport"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestListenN(t *testing.T) {
+	if !reuseport.Available() {
+		t.Skip("reuseport not available")
+	}
+
+	var trA Transport
+	ml, err := trA.ListenN(loopbackV4, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ml.Close()
+
+	const conns = 64
+	accepted := make(chan error)
+	go func() {
+		for i := 0; i < conns; i++ {
+			c, err := ml.Accept()
+			if err == nil {
+				c.Close()
+			}
+			accepted <- err
+		}
+	}()
+	for i := 0; i < conns; i++ {
+		c, err := manet.Dial(ml.Multiaddr())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := <-accepted; err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+	}
+
+	counts := ml.AcceptCounts()
+	if len(counts) != 4 {
+		t.Fatalf("expected 4 sockets, got %d", len(counts))
+	}
+	var total uint64
+	var used int
+	for _, n := range counts {
+		total += n
+		if n > 0 {
+			used++
+		}
+	}
+	if total != conns {
+		t.Fatalf("expected %d accepted connections, got %d", conns, total)
+	}
+	if used < 2 {
+		t.Fatalf("expected connections to be spread across sockets, got %v", counts)
+	}
+
+	reg := prometheus.NewRegistry()
+	RegisterAcceptMetrics(reg)
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mfs) != 1 || len(mfs[0].Metric) != 4 || !strings.HasSuffix(mfs[0].GetName(), "accepts_total") {
+		t.Fatalf("unexpected metrics: %v", mfs)
+	}
+
+	if err := ml.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ml.Accept(); err == nil {
+		t.Fatal("expected error accepting from closed listener")
+	}
+	if mfs, _ := reg.Gather(); len(mfs) != 0 {
+		t.Fatalf("expected no metrics after closing, got %v", mfs)
+	}
+}
+
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "temporary error" }
+func (temporaryError) Temporary() bool { return true }
+
+// flakyListener fails the first Accept call with a temporary error.
+type flakyListener struct {
+	manet.Listener
+	failed bool
+}
+
+func (l *flakyListener) Accept() (manet.Conn, error) {
+	if !l.failed {
+		l.failed = true
+		return nil, temporaryError{}
+	}
+	return l.Listener.Accept()
+}
+
+func TestMultiListenerTemporaryError(t *testing.T) {
+	l, err := manet.Listen(loopbackV4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ml := &MultiListener{
+		listeners: []manet.Listener{&flakyListener{Listener: l}},
+		accepts:   make([]atomic.Uint64, 1),
+		conns:     make(chan acceptResult),
+		closed:    make(chan struct{}),
+	}
+	ml.wg.Add(1)
+	go ml.acceptLoop(0)
+	defer ml.Close()
+
+	c, err := manet.Dial(ml.Multiaddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ac, err := ml.Accept()
+	if err != nil {
+		t.Fatalf("expected the temporary error to be retried, got %s", err)
+	}
+	ac.Close()
+}
@@ -1,11 +1,14 @@
 package swarm
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/test"
 	ma "github.com/multiformats/go-multiaddr"
 )
@@ -355,3 +358,79 @@ func TestDelayRankerOtherTransportDelay(t *testing.T) {
 		})
 	}
 }
+
+func TestScopedDialRanker(t *testing.T) {
+	loopback := ma.StringCast("/ip4/127.0.0.1/tcp/1")
+	lan := ma.StringCast("/ip4/192.168.1.1/tcp/1")
+	wan := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+	addrs := []ma.Multiaddr{loopback, lan, wan}
+
+	if ClassifyAddr(loopback) != ScopeLoopback || ClassifyAddr(lan) != ScopeLAN || ClassifyAddr(wan) != ScopeWAN {
+		t.Fatal("unexpected address scopes")
+	}
+
+	clustered := test.RandPeerIDFatal(t)
+	other := test.RandPeerIDFatal(t)
+
+	testCase := []struct {
+		name   string
+		peer   peer.ID
+		opts   []ScopeOption
+		output []network.AddrDelay
+	}{
+		{
+			name: "no policies",
+			peer: other,
+			output: []network.AddrDelay{
+				{Addr: loopback, Delay: 0},
+				{Addr: lan, Delay: 0},
+				{Addr: wan, Delay: 0},
+			},
+		},
+		{
+			name: "lan head start",
+			peer: other,
+			opts: []ScopeOption{ScopeDelay(ScopeWAN, time.Second)},
+			output: []network.AddrDelay{
+				{Addr: loopback, Delay: 0},
+				{Addr: lan, Delay: 0},
+				{Addr: wan, Delay: time.Second},
+			},
+		},
+		{
+			name: "wan filtered for clustered peer",
+			peer: clustered,
+			opts: []ScopeOption{ScopeFilter(ScopeWAN, func(p peer.ID) bool { return p != clustered })},
+			output: []network.AddrDelay{
+				{Addr: loopback, Delay: 0},
+				{Addr: lan, Delay: 0},
+			},
+		},
+		{
+			name: "wan not filtered for other peer",
+			peer: other,
+			opts: []ScopeOption{ScopeFilter(ScopeWAN, func(p peer.ID) bool { return p != clustered })},
+			output: []network.AddrDelay{
+				{Addr: loopback, Delay: 0},
+				{Addr: lan, Delay: 0},
+				{Addr: wan, Delay: 0},
+			},
+		},
+	}
+	for _, tc := range testCase {
+		t.Run(tc.name, func(t *testing.T) {
+			ranker := ScopedDialRanker(NoDelayDialRanker, tc.opts...)
+			res := ranker(context.Background(), tc.peer, addrs)
+			if len(res) != len(tc.output) {
+				t.Fatalf("expected %+v got %+v", tc.output, res)
+			}
+			sortAddrDelays(res)
+			sortAddrDelays(tc.output)
+			for i := 0; i < len(tc.output); i++ {
+				if !tc.output[i].Addr.Equal(res[i].Addr) || tc.output[i].Delay != res[i].Delay {
+					t.Fatalf("expected %+v got %+v", tc.output, res)
+				}
+			}
+		})
+	}
+}
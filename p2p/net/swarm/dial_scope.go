@@ -0,0 +1,110 @@
+package swarm
+
+import (
+	"context"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// AddrScope classifies an address by the network it's reachable on.
+type AddrScope int
+
+const (
+	// ScopeLoopback is the scope of loopback addresses.
+	ScopeLoopback AddrScope = iota
+	// ScopeLAN is the scope of private addresses, e.g. RFC 1918 and link
+	// local addresses.
+	ScopeLAN
+	// ScopeWAN is the scope of all other addresses.
+	ScopeWAN
+)
+
+func (s AddrScope) String() string {
+	str := [...]string{"loopback", "lan", "wan"}
+	if s < 0 || int(s) >= len(str) {
+		return "unknown"
+	}
+	return str[s]
+}
+
+// ClassifyAddr returns the scope of a. Relay addresses are classified by the
+// address of the relay, and addresses without an IP, e.g. DNS addresses, are
+// classified as ScopeWAN.
+func ClassifyAddr(a ma.Multiaddr) AddrScope {
+	switch {
+	case manet.IsIPLoopback(a):
+		return ScopeLoopback
+	case manet.IsPrivateAddr(a):
+		return ScopeLAN
+	default:
+		return ScopeWAN
+	}
+}
+
+type scopePolicy struct {
+	allow func(p peer.ID) bool
+	delay time.Duration
+}
+
+// ScopeOption configures the dial policy of an address scope for
+// ScopedDialRanker.
+type ScopeOption func(policies map[AddrScope]*scopePolicy)
+
+func policyFor(policies map[AddrScope]*scopePolicy, s AddrScope) *scopePolicy {
+	p, ok := policies[s]
+	if !ok {
+		p = &scopePolicy{}
+		policies[s] = p
+	}
+	return p
+}
+
+// ScopeFilter restricts the peers that are dialed on addresses of scope s to
+// the peers for which allow returns true.
+func ScopeFilter(s AddrScope, allow func(p peer.ID) bool) ScopeOption {
+	return func(policies map[AddrScope]*scopePolicy) {
+		policyFor(policies, s).allow = allow
+	}
+}
+
+// ScopeDelay delays dials to addresses of scope s by d, in addition to the
+// delay assigned by the ranker. Delaying ScopeWAN gives LAN addresses a head
+// start.
+func ScopeDelay(s AddrScope, d time.Duration) ScopeOption {
+	return func(policies map[AddrScope]*scopePolicy) {
+		policyFor(policies, s).delay = d
+	}
+}
+
+// ScopedDialRanker returns a PeerDialRanker that ranks addresses with ranker
+// and applies per scope dial policies to the result. Use it with
+// WithPeerDialRanker. If ranker is nil, DefaultDialRanker is used.
+func ScopedDialRanker(ranker network.DialRanker, opts ...ScopeOption) network.PeerDialRanker {
+	if ranker == nil {
+		ranker = DefaultDialRanker
+	}
+	policies := make(map[AddrScope]*scopePolicy)
+	for _, opt := range opts {
+		opt(policies)
+	}
+	return func(_ context.Context, p peer.ID, addrs []ma.Multiaddr) []network.AddrDelay {
+		allowed := addrs[:0:0]
+		for _, a := range addrs {
+			if pol, ok := policies[ClassifyAddr(a)]; ok && pol.allow != nil && !pol.allow(p) {
+				continue
+			}
+			allowed = append(allowed, a)
+		}
+		res := ranker(allowed)
+		for i := range res {
+			if pol, ok := policies[ClassifyAddr(res[i].Addr)]; ok {
+				res[i].Delay += pol.delay
+			}
+		}
+		return res
+	}
+}
@@ -651,3 +651,36 @@ func TestDialQUICDraft29(t *testing.T) {
 	require.ErrorIs(t, err, swarm.ErrQUICDraft29)
 	require.ErrorIs(t, err, swarm.ErrNoTransport)
 }
+
+func TestDialCancelAbortsPendingDial(t *testing.T) {
+	s := makeDialOnlySwarm(t)
+	defer s.Close()
+
+	// the silent peer accepts TCP connections, but never completes the handshake
+	p, addr, lst := newSilentPeer(t)
+	go acceptAndHang(lst)
+	defer lst.Close()
+	s.Peerstore().AddAddr(p, addr, peerstore.PermanentAddrTTL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := s.DialPeer(ctx, p)
+		errCh <- err
+	}()
+
+	require.Eventually(t, func() bool { return len(s.PendingDials()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	pd := s.PendingDials()[0]
+	require.Equal(t, p, pd.Peer)
+	require.True(t, addr.Equal(pd.Addr))
+	require.Positive(t, pd.Age)
+
+	cancel()
+	select {
+	case err := <-errCh:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("dial wasn't aborted when its context was canceled")
+	}
+	require.Eventually(t, func() bool { return len(s.PendingDials()) == 0 }, time.Second, 10*time.Millisecond)
+}
@@ -1,8 +1,10 @@
 package swarm
 
 import (
+	"cmp"
 	"context"
 	"math"
+	"slices"
 	"sync"
 	"time"
 
@@ -46,6 +48,8 @@ type pendRequest struct {
 	// the addr is removed from the map and err is updated. On a successful dial, the dialRequest is
 	// completed and response is sent with the connection
 	addrs map[string]struct{}
+	// budget limits the dials made for this request
+	budget network.DialBudget
 }
 
 // addrDial tracks dials to a particular multiaddress.
@@ -130,6 +134,10 @@ func (w *dialWorker) loop() {
 		}
 		timerRunning = false
 		if dq.Len() > 0 {
+			if dialsInFlight >= w.maxConcurrentDials() {
+				// wait for a dial to complete
+				return
+			}
 			if dialsInFlight == 0 && !w.connected {
 				// if there are no dials in flight, trigger the next dials immediately
 				dialTimer.Reset(startTime)
@@ -190,14 +198,25 @@ loop:
 
 			// get the delays to dial these addrs from the swarms dialRanker
 			simConnect, _, _ := network.GetSimultaneousConnect(req.ctx)
-			addrRanking := w.rankAddrs(addrs, simConnect)
+			addrRanking := w.rankAddrs(req.ctx, addrs, simConnect)
+			budget := w.s.dialBudgetFor(req.ctx)
+			if budget.MaxAttempts > 0 && len(addrRanking) > budget.MaxAttempts {
+				// only dial the best ranked addresses
+				slices.SortStableFunc(addrRanking, func(a, b network.AddrDelay) int {
+					return cmp.Compare(a.Delay, b.Delay)
+				})
+				log.Debugf("dial budget exceeded for peer %s; dialing %d of %d addresses",
+					w.peer, budget.MaxAttempts, len(addrRanking))
+				addrRanking = addrRanking[:budget.MaxAttempts]
+			}
 			addrDelay := make(map[string]time.Duration, len(addrRanking))
 
 			// create the pending request object
 			pr := &pendRequest{
-				req:   req,
-				addrs: make(map[string]struct{}, len(addrRanking)),
-				err:   &DialError{Peer: w.peer, DialErrors: addrErrs},
+				req:    req,
+				addrs:  make(map[string]struct{}, len(addrRanking)),
+				err:    &DialError{Peer: w.peer, DialErrors: addrErrs},
+				budget: budget,
 			}
 			for _, adelay := range addrRanking {
 				pr.addrs[string(adelay.Addr.Bytes())] = struct{}{}
@@ -286,7 +305,16 @@ loop:
 			// the inflight dials have errored and we should dial the next batch of
 			// addresses
 			now := time.Now()
-			for _, adelay := range dq.NextBatch() {
+			batch := dq.NextBatch()
+			if n := w.maxConcurrentDials() - dialsInFlight; n < len(batch) {
+				// put back the addresses exceeding the concurrency budget.
+				// They're dialed as soon as a dial completes.
+				for _, adelay := range batch[max(n, 0):] {
+					dq.Add(adelay)
+				}
+				batch = batch[:max(n, 0)]
+			}
+			for _, adelay := range batch {
 				// spawn the dial
 				ad, ok := w.trackedDials[string(adelay.Addr.Bytes())]
 				if !ok {
@@ -386,6 +414,18 @@ loop:
 	}
 }
 
+// maxConcurrentDials returns the number of dials allowed in flight by the
+// budgets of the pending requests. The most restrictive budget applies.
+func (w *dialWorker) maxConcurrentDials() int {
+	limit := math.MaxInt
+	for pr := range w.pendingRequests {
+		if pr.budget.MaxConcurrent > 0 && pr.budget.MaxConcurrent < limit {
+			limit = pr.budget.MaxConcurrent
+		}
+	}
+	return limit
+}
+
 // dispatches an error to a specific addr dial
 func (w *dialWorker) dispatchError(ad *addrDial, err error) {
 	ad.err = err
@@ -421,10 +461,13 @@ func (w *dialWorker) dispatchError(ad *addrDial, err error) {
 
 // rankAddrs ranks addresses for dialing. if it's a simConnect request we
 // dial all addresses immediately without any delay
-func (w *dialWorker) rankAddrs(addrs []ma.Multiaddr, isSimConnect bool) []network.AddrDelay {
+func (w *dialWorker) rankAddrs(ctx context.Context, addrs []ma.Multiaddr, isSimConnect bool) []network.AddrDelay {
 	if isSimConnect {
 		return NoDelayDialRanker(addrs)
 	}
+	if w.s.peerDialRanker != nil {
+		return w.s.peerDialRanker(ctx, w.peer, addrs)
+	}
 	return w.s.dialRanker(addrs)
 }
 
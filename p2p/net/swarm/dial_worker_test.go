@@ -904,6 +904,7 @@ func TestDialWorkerLoopSchedulingProperty(t *testing.T) {
 		defer s1.Close()
 		// ignore limiter delays just check scheduling
 		s1.limiter.perPeerLimit = 10000
+		s1.dialBudget = network.DialBudget{}
 		s2 := makeSwarmWithNoListenAddrs(t)
 		defer s2.Close()
 		// setup the ranker to trigger dials according to the test case
@@ -1089,6 +1090,34 @@ func TestDialWorkerLoopAddrDedup(t *testing.T) {
 	}
 }
 
+func TestPeerDialRanker(t *testing.T) {
+	var rankedPeer peer.ID
+	var forceDirect bool
+	s1 := makeSwarmWithNoListenAddrs(t, WithPeerDialRanker(func(ctx context.Context, p peer.ID, addrs []ma.Multiaddr) []network.AddrDelay {
+		rankedPeer = p
+		forceDirect, _ = network.GetForceDirectDial(ctx)
+		// Only dial TCP.
+		var res []network.AddrDelay
+		for _, a := range addrs {
+			if _, err := a.ValueForProtocol(ma.P_TCP); err == nil {
+				res = append(res, network.AddrDelay{Addr: a})
+			}
+		}
+		return res
+	}))
+	s2 := makeSwarm(t)
+	defer s1.Close()
+	defer s2.Close()
+
+	s1.Peerstore().AddAddrs(s2.LocalPeer(), s2.ListenAddresses(), peerstore.PermanentAddrTTL)
+	c, err := s1.DialPeer(network.WithForceDirectDial(context.Background(), "test"), s2.LocalPeer())
+	require.NoError(t, err)
+	require.Equal(t, s2.LocalPeer(), rankedPeer)
+	require.True(t, forceDirect)
+	_, err = c.RemoteMultiaddr().ValueForProtocol(ma.P_TCP)
+	require.NoError(t, err)
+}
+
 func TestDialWorkerLoopTCPConnUpgradeWait(t *testing.T) {
 	s1 := makeSwarmWithNoListenAddrs(t, WithDialTimeout(10*time.Second))
 	s2 := makeSwarmWithNoListenAddrs(t, WithDialTimeout(10*time.Second))
@@ -1194,3 +1223,88 @@ func BenchmarkDialRanker(b *testing.B) {
 		}
 	})
 }
+
+func TestDialWorkerLoopBudget(t *testing.T) {
+	s1 := makeSwarmWithNoListenAddrs(t, WithDialRanker(NoDelayDialRanker))
+	defer s1.Close()
+	_, p2 := newPeer(t)
+
+	// the listeners accept connections but never complete the handshake
+	recvCh := make(chan struct{}, 5)
+	var closeChs []chan struct{}
+	for i := 0; i < 5; i++ {
+		l, ch := makeTCPListener(t, ma.StringCast("/ip4/127.0.0.1/tcp/0"), recvCh)
+		defer l.Close()
+		a, err := manet.FromNetAddr(l.Addr())
+		require.NoError(t, err)
+		s1.Peerstore().AddAddr(p2, a, peerstore.PermanentAddrTTL)
+		closeChs = append(closeChs, ch)
+	}
+
+	ctx := network.WithDialBudget(context.Background(), network.DialBudget{MaxConcurrent: 2, MaxAttempts: 3})
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := s1.DialPeer(ctx, p2)
+		errCh <- err
+	}()
+
+	expectDials := func(n int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			select {
+			case <-recvCh:
+			case <-time.After(5 * time.Second):
+				t.Fatal("expected a dial")
+			}
+		}
+		select {
+		case <-recvCh:
+			t.Fatal("didn't expect another dial")
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+	expectDials(2)
+	// failing a dial lets the next address be dialed
+	for _, ch := range closeChs {
+		select {
+		case ch <- struct{}{}:
+		default:
+			continue
+		}
+		break
+	}
+	expectDials(1)
+	// all 3 attempts are used up
+	for _, ch := range closeChs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	expectDials(0)
+	select {
+	case err := <-errCh:
+		require.ErrorIs(t, err, ErrAllDialsFailed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("dial didn't complete")
+	}
+}
+
+func TestDialBudgetTimeout(t *testing.T) {
+	s1 := makeSwarmWithNoListenAddrs(t, WithDialBudget(network.DialBudget{Timeout: 200 * time.Millisecond}))
+	defer s1.Close()
+	_, p2 := newPeer(t)
+
+	recvCh := make(chan struct{}, 1)
+	l, ch := makeTCPListener(t, ma.StringCast("/ip4/127.0.0.1/tcp/0"), recvCh)
+	defer l.Close()
+	defer close(ch)
+	a, err := manet.FromNetAddr(l.Addr())
+	require.NoError(t, err)
+	s1.Peerstore().AddAddr(p2, a, peerstore.PermanentAddrTTL)
+
+	start := time.Now()
+	_, err = s1.DialPeer(context.Background(), p2)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
@@ -0,0 +1,116 @@
+package swarm
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/transport"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// ErrListenAddrConflict is returned when a listen address conflicts with
+// another listen address, e.g. because both use the same port, and one of
+// them listens on the unspecified address.
+var ErrListenAddrConflict = errors.New("listen address conflicts with another listen address")
+
+// ListenError is the error type returned by Listen.
+type ListenError struct {
+	ListenErrors []ListenAddrError
+}
+
+func (e *ListenError) Error() string {
+	var builder strings.Builder
+	builder.WriteString("failed to listen:")
+	for _, le := range e.ListenErrors {
+		fmt.Fprintf(&builder, "\n  * %s", le.Error())
+	}
+	return builder.String()
+}
+
+func (e *ListenError) Unwrap() []error {
+	if e == nil {
+		return nil
+	}
+	errs := make([]error, 0, len(e.ListenErrors))
+	for i := range e.ListenErrors {
+		errs = append(errs, &e.ListenErrors[i])
+	}
+	return errs
+}
+
+var _ error = (*ListenError)(nil)
+
+// ListenAddrError is the error for a specific listen address.
+type ListenAddrError struct {
+	Address ma.Multiaddr
+	// Transport is the transport selected for listening on Address. It is
+	// nil if no transport can listen on Address.
+	Transport transport.Transport
+	// ConflictsWith is the address Address conflicts with, if Cause is
+	// ErrListenAddrConflict.
+	ConflictsWith ma.Multiaddr
+	Cause         error
+}
+
+func (e *ListenAddrError) Error() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "[%s]", e.Address)
+	if e.Transport != nil {
+		fmt.Fprintf(&builder, " (%s)", e.Transport)
+	}
+	fmt.Fprintf(&builder, " %s", e.Cause)
+	if e.ConflictsWith != nil {
+		fmt.Fprintf(&builder, ": %s", e.ConflictsWith)
+	}
+	return builder.String()
+}
+
+func (e *ListenAddrError) Unwrap() error {
+	return e.Cause
+}
+
+var _ error = (*ListenAddrError)(nil)
+
+// listenSocket is the socket a thin waist listen address binds to.
+type listenSocket struct {
+	tpt     transport.Transport
+	network int // ma.P_TCP or ma.P_UDP
+	ip      netip.Addr
+	port    string
+}
+
+// toListenSocket returns the socket of a listen address of the form
+// /ip{4,6}/<ip>/{tcp,udp}/<port>/... It returns false for other addresses
+// and for port 0, as they can't conflict.
+func toListenSocket(tpt transport.Transport, a ma.Multiaddr) (listenSocket, bool) {
+	if len(a) < 2 {
+		return listenSocket{}, false
+	}
+	if c := a[0].Code(); c != ma.P_IP4 && c != ma.P_IP6 {
+		return listenSocket{}, false
+	}
+	if c := a[1].Code(); c != ma.P_TCP && c != ma.P_UDP {
+		return listenSocket{}, false
+	}
+	if a[1].Value() == "0" {
+		return listenSocket{}, false
+	}
+	ip, err := netip.ParseAddr(a[0].Value())
+	if err != nil {
+		return listenSocket{}, false
+	}
+	return listenSocket{tpt: tpt, network: a[1].Code(), ip: ip, port: a[1].Value()}, true
+}
+
+// conflicts returns true if a transport can't listen on both sockets.
+// Different transports may share a port on purpose, e.g. QUIC and
+// WebTransport, so only sockets of the same transport can conflict.
+func (s listenSocket) conflicts(o listenSocket) bool {
+	if s.tpt != o.tpt || s.network != o.network || s.port != o.port || s.ip.Is4() != o.ip.Is4() {
+		return false
+	}
+	return s.ip == o.ip || s.ip.IsUnspecified() || o.ip.IsUnspecified()
+}
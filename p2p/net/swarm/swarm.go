@@ -115,6 +115,32 @@ func WithDialRanker(d network.DialRanker) Option {
 	}
 }
 
+// WithPeerDialRanker configures swarm to use r to schedule dials. It takes
+// precedence over the DialRanker set with WithDialRanker. Simultaneous connect
+// requests, used for hole punching, still dial all addresses immediately.
+func WithPeerDialRanker(r network.PeerDialRanker) Option {
+	return func(s *Swarm) error {
+		if r == nil {
+			return errors.New("swarm: peer dial ranker cannot be nil")
+		}
+		s.peerDialRanker = r
+		return nil
+	}
+}
+
+// WithDialBudget configures the budget for dialing a peer, unless another one
+// is set on the context with network.WithDialBudget. Defaults to
+// DefaultDialBudget.
+func WithDialBudget(b network.DialBudget) Option {
+	return func(s *Swarm) error {
+		if b.MaxConcurrent < 0 || b.MaxAttempts < 0 || b.Timeout < 0 {
+			return errors.New("swarm: dial budget cannot be negative")
+		}
+		s.dialBudget = b
+		return nil
+	}
+}
+
 // WithUDPBlackHoleSuccessCounter configures swarm to use the provided config for UDP black hole detection
 // n is the size of the sliding window used to evaluate black hole state
 // min is the minimum number of successes out of n required to not block requests
@@ -146,6 +172,24 @@ func WithReadOnlyBlackHoleDetector() Option {
 	}
 }
 
+// DialObserver is notified of the dials the swarm makes, including the dials
+// made implicitly when opening a stream.
+type DialObserver interface {
+	// DialStarted is called when the swarm starts dialing p because it
+	// doesn't have a usable connection to it.
+	DialStarted(p peer.ID)
+	// DialFailed is called when dialing p failed.
+	DialFailed(p peer.ID, err error)
+}
+
+// WithDialObserver sets an observer notified of the dials the swarm makes.
+func WithDialObserver(o DialObserver) Option {
+	return func(s *Swarm) error {
+		s.dialObserver = o
+		return nil
+	}
+}
+
 // Swarm is a connection muxer, allowing connections to other peers to
 // be opened and closed, while still using the same Chan for all
 // communication. The Chan sends/receives Messages, which note the
@@ -202,11 +246,21 @@ type Swarm struct {
 	// stream handlers
 	streamh atomic.Pointer[network.StreamHandler]
 
+	pendingDials struct {
+		sync.Mutex
+		m map[*PendingDial]struct{}
+	}
+
 	// dialing helpers
-	dsync   *dialSync
-	backf   DialBackoff
-	limiter *dialLimiter
-	gater   connmgr.ConnectionGater
+	dsync        *dialSync
+	backf        DialBackoff
+	dialFailures dialFailures
+	dialObserver DialObserver
+	limiter      *dialLimiter
+	gater        connmgr.ConnectionGater
+
+	// see PauseInbound
+	inboundPause atomic.Pointer[inboundPause]
 
 	closeOnce sync.Once
 	ctx       context.Context // is canceled when Close is called
@@ -215,13 +269,23 @@ type Swarm struct {
 	bwc           metrics.Reporter
 	metricsTracer MetricsTracer
 
-	dialRanker network.DialRanker
+	dialRanker     network.DialRanker
+	peerDialRanker network.PeerDialRanker
+	dialBudget     network.DialBudget
 
 	connectednessEventEmitter *connectednessEventEmitter
 	udpBHF                    *BlackHoleSuccessCounter
 	ipv6BHF                   *BlackHoleSuccessCounter
 	bhd                       *blackHoleDetector
 	readOnlyBHD               bool
+
+	idlePolicy  *IdleConnPolicy
+	idleEmitter event.Emitter
+
+	pathEmitter event.Emitter
+
+	// see WithStreamLinger
+	streamLinger *time.Duration
 }
 
 // NewSwarm constructs a Swarm.
@@ -241,6 +305,7 @@ func NewSwarm(local peer.ID, peers peerstore.Peerstore, eventBus event.Bus, opts
 		dialTimeoutLocal:  defaultDialTimeoutLocal,
 		multiaddrResolver: ResolverFromMaDNS{madns.DefaultResolver},
 		dialRanker:        DefaultDialRanker,
+		dialBudget:        DefaultDialBudget,
 
 		// A black hole is a binary property. On a network if UDP dials are blocked or there is
 		// no IPv6 connectivity, all dials will fail. So a low success rate of 5 out 100 dials
@@ -254,6 +319,7 @@ func NewSwarm(local peer.ID, peers peerstore.Peerstore, eventBus event.Bus, opts
 	s.transports.m = make(map[int]transport.Transport)
 	s.notifs.m = make(map[network.Notifiee]struct{})
 	s.directConnNotifs.m = make(map[peer.ID][]chan struct{})
+	s.pendingDials.m = make(map[*PendingDial]struct{})
 	s.connectednessEventEmitter = newConnectednessEventEmitter(s.Connectedness, emitter)
 
 	for _, opt := range opts {
@@ -261,6 +327,11 @@ func NewSwarm(local peer.ID, peers peerstore.Peerstore, eventBus event.Bus, opts
 			return nil, err
 		}
 	}
+	s.pathEmitter, err = eventBus.Emitter(new(event.EvtPeerConnectionPathChanged))
+	if err != nil {
+		emitter.Close()
+		return nil, err
+	}
 	if s.rcmgr == nil {
 		s.rcmgr = &network.NullResourceManager{}
 	}
@@ -276,6 +347,17 @@ func NewSwarm(local peer.ID, peers peerstore.Peerstore, eventBus event.Bus, opts
 		mt:       s.metricsTracer,
 		readOnly: s.readOnlyBHD,
 	}
+
+	if s.idlePolicy != nil {
+		s.idleEmitter, err = eventBus.Emitter(new(event.EvtPeerConnectionIdleClosed))
+		if err != nil {
+			emitter.Close()
+			s.pathEmitter.Close()
+			return nil, err
+		}
+		s.refs.Add(1)
+		go s.idleCloser()
+	}
 	return s, nil
 }
 
@@ -329,6 +411,10 @@ func (s *Swarm) close() {
 	s.refs.Wait()
 	s.connectednessEventEmitter.Close()
 	s.emitter.Close()
+	s.pathEmitter.Close()
+	if s.idleEmitter != nil {
+		s.idleEmitter.Close()
+	}
 
 	// Now close out any transports (if necessary). Do this after closing
 	// all connections/listeners.
@@ -400,6 +486,7 @@ func (s *Swarm) addConn(tc transport.CapableConn, dir network.Direction) (*Conn,
 
 	// Clear any backoffs
 	s.backf.Clear(p)
+	s.dialFailures.clear(p)
 
 	// Finally, add the peer.
 	s.conns.Lock()
@@ -411,6 +498,7 @@ func (s *Swarm) addConn(tc transport.CapableConn, dir network.Direction) (*Conn,
 	}
 
 	c.streams.m = make(map[*Stream]struct{})
+	c.markActive()
 	s.conns.m[p] = append(s.conns.m[p], c)
 	// Add two swarm refs:
 	// * One will be decremented after the close notifications fire in Conn.doClose
@@ -592,41 +680,18 @@ func (s *Swarm) ConnsToPeer(p peer.ID) []network.Conn {
 }
 
 func isBetterConn(a, b *Conn) bool {
-	// If one is limited and not the other, prefer the unlimited connection.
-	aLimited := a.Stat().Limited
-	bLimited := b.Stat().Limited
-	if aLimited != bLimited {
-		return !aLimited
-	}
-
-	// If one is direct and not the other, prefer the direct connection.
-	aDirect := isDirectConn(a)
-	bDirect := isDirectConn(b)
-	if aDirect != bDirect {
-		return aDirect
-	}
-
-	// Otherwise, prefer the connection with more open streams.
-	a.streams.Lock()
-	aLen := len(a.streams.m)
-	a.streams.Unlock()
-
-	b.streams.Lock()
-	bLen := len(b.streams.m)
-	b.streams.Unlock()
-
-	if aLen != bLen {
-		return aLen > bLen
-	}
-
-	// finally, pick the last connection.
-	return true
+	// Prefer unlimited connections, then direct connections, then connections
+	// with clearly better quality, i.e. fewer failures opening streams or a
+	// lower RTT, then connections with more open streams. Finally, pick the
+	// last connection.
+	return compareConnRanks(a.rank(), b.rank()) <= 0
 }
 
 // bestConnToPeer returns the best connection to peer.
 func (s *Swarm) bestConnToPeer(p peer.ID) *Conn {
 	// TODO: Prefer some transports over others.
-	// For now, prefers direct connections over Relayed connections.
+	// For now, prefers direct connections over Relayed connections, and
+	// connections with better quality.
 	// For tie-breaking, select the newest non-closed connection with the most streams.
 	s.conns.RLock()
 	defer s.conns.RUnlock()
@@ -50,6 +50,25 @@ func TestDialBadAddrs(t *testing.T) {
 	test(m("/ip4/127.0.0.1/udp/1234/utp")) // utp
 }
 
+func TestDialLinkLocalZone(t *testing.T) {
+	ll := test.LinkLocalAddr(t)
+	dialer := swarmt.GenSwarm(t, swarmt.OptDialOnly, swarmt.OptDisableQUIC)
+	listener := swarmt.GenSwarm(t, swarmt.OptDialOnly, swarmt.OptDisableQUIC)
+	defer dialer.Close()
+	defer listener.Close()
+	if err := listener.Listen(ll.Encapsulate(ma.StringCast("/tcp/0"))); err != nil {
+		t.Skipf("failed to listen on %s: %s", ll, err)
+	}
+	laddrs := listener.ListenAddresses()
+	require.Len(t, laddrs, 1)
+	require.Equal(t, ma.P_IP6ZONE, laddrs[0][0].Code(), "listen address lost its zone: %s", laddrs[0])
+
+	dialer.Peerstore().AddAddrs(listener.LocalPeer(), laddrs, peerstore.PermanentAddrTTL)
+	c, err := dialer.DialPeer(context.Background(), listener.LocalPeer())
+	require.NoError(t, err)
+	require.Equal(t, ma.P_IP6ZONE, c.RemoteMultiaddr()[0].Code())
+}
+
 func TestAddrRace(t *testing.T) {
 	s := makeSwarms(t, 1)[0]
 	defer s.Close()
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	ic "github.com/libp2p/go-libp2p/core/crypto"
@@ -29,6 +30,9 @@ type Conn struct {
 
 	closeOnce sync.Once
 	err       error
+	// closeReason is the reason the connection was closed. It is set before
+	// the Disconnected notification is sent.
+	closeReason atomic.Pointer[error]
 
 	notifyLk sync.Mutex
 
@@ -38,9 +42,22 @@ type Conn struct {
 	}
 
 	stat network.ConnStats
+
+	// lastActive is the time, in unix nanoseconds, of the last stream opened
+	// or closed, or data transferred on the connection.
+	lastActive atomic.Int64
+
+	quality connQuality
+
+	// see SetLinger
+	streamLinger atomic.Pointer[time.Duration]
 }
 
-var _ network.Conn = &Conn{}
+var (
+	_ network.Conn           = &Conn{}
+	_ network.ConnMuxerStats = &Conn{}
+	_ network.MigratableConn = &Conn{}
+)
 
 func (c *Conn) IsClosed() bool {
 	return c.conn.IsClosed()
@@ -59,19 +76,33 @@ func (c *Conn) ID() string {
 // notifications).
 func (c *Conn) Close() error {
 	c.closeOnce.Do(func() {
-		c.doClose(0)
+		c.doClose(0, nil)
 	})
 	return c.err
 }
 
 func (c *Conn) CloseWithError(errCode network.ConnErrorCode) error {
 	c.closeOnce.Do(func() {
-		c.doClose(errCode)
+		c.doClose(errCode, &network.ConnError{ErrorCode: errCode})
 	})
 	return c.err
 }
 
-func (c *Conn) doClose(errCode network.ConnErrorCode) {
+// CloseReason returns the reason the connection was closed: the error the
+// underlying connection failed with, or a *network.ConnError with the code it
+// was closed with locally. It returns nil if the connection is open or was
+// closed locally without an error code.
+func (c *Conn) CloseReason() error {
+	if r := c.closeReason.Load(); r != nil {
+		return *r
+	}
+	return nil
+}
+
+func (c *Conn) doClose(errCode network.ConnErrorCode, reason error) {
+	if reason != nil {
+		c.closeReason.Store(&reason)
+	}
 	c.swarm.removeConn(c)
 
 	// Prevent new streams from opening.
@@ -116,6 +147,7 @@ func (c *Conn) removeStream(s *Stream) {
 	c.stat.NumStreams--
 	delete(c.streams.m, s)
 	c.streams.Unlock()
+	c.markActive()
 	s.scope.Done()
 }
 
@@ -130,6 +162,9 @@ func (c *Conn) start() {
 		for {
 			ts, err := c.conn.AcceptStream()
 			if err != nil {
+				c.closeOnce.Do(func() {
+					c.doClose(0, err)
+				})
 				return
 			}
 			scope, err := c.swarm.ResourceManager().OpenStream(c.RemotePeer(), network.DirInbound)
@@ -205,8 +240,39 @@ func (c *Conn) ConnState() network.ConnectionState {
 // Stat returns metadata pertaining to this connection
 func (c *Conn) Stat() network.ConnStats {
 	c.streams.Lock()
-	defer c.streams.Unlock()
-	return c.stat
+	stat := c.stat
+	c.streams.Unlock()
+	stat.BytesRead = c.quality.bytesReceived.Load()
+	stat.BytesWritten = c.quality.bytesSent.Load()
+	return stat
+}
+
+// MuxerStats returns statistics about the streams multiplexed on this
+// connection. It is empty if the stream muxer doesn't report statistics.
+func (c *Conn) MuxerStats() network.MuxerStats {
+	tc := c.conn
+	if mc, ok := tc.(*connWithMetrics); ok {
+		tc = mc.CapableConn
+	}
+	if s, ok := tc.(network.ConnMuxerStats); ok {
+		return s.MuxerStats()
+	}
+	return network.MuxerStats{}
+}
+
+// Migrate moves the connection to a new local socket. It returns
+// network.ErrMigrationUnsupported if the transport of the connection doesn't
+// support connection migration.
+func (c *Conn) Migrate(ctx context.Context) error {
+	tc := c.conn
+	if mc, ok := tc.(*connWithMetrics); ok {
+		tc = mc.CapableConn
+	}
+	m, ok := tc.(network.MigratableConn)
+	if !ok {
+		return network.ErrMigrationUnsupported
+	}
+	return m.Migrate(ctx)
 }
 
 // NewStream returns a new Stream from this connection
@@ -229,6 +295,9 @@ func (c *Conn) NewStream(ctx context.Context) (network.Stream, error) {
 	}
 
 	s, err := c.openAndAddStream(ctx, scope)
+	if !errors.Is(err, context.Canceled) {
+		c.quality.recordStreamOpened(err)
+	}
 	if err != nil {
 		scope.Done()
 		if errors.Is(err, context.DeadlineExceeded) {
@@ -270,6 +339,7 @@ func (c *Conn) addStream(ts network.MuxedStream, dir network.Direction, scope ne
 	}
 	c.stat.NumStreams++
 	c.streams.m[s] = struct{}{}
+	c.markActive()
 
 	// Released once the stream disconnect notifications have finished
 	// firing (in Swarm.remove).
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/netip"
+	"slices"
 	"strconv"
 	"sync"
 	"time"
@@ -94,6 +95,10 @@ const ConcurrentFdDials = 160
 // per peer
 var DefaultPerPeerRateLimit = 8
 
+// DefaultDialBudget is the budget for dialing a peer used by swarms that
+// weren't configured with WithDialBudget.
+var DefaultDialBudget = network.DialBudget{MaxConcurrent: 8, MaxAttempts: 32}
+
 // DialBackoff is a type for tracking peer dial backoffs. Dialbackoff is used to
 // avoid over-dialing the same, dead peers. Whenever we totally time out on all
 // addresses of a peer, we add the addresses to DialBackoff. Then, whenever we
@@ -187,6 +192,31 @@ func (db *DialBackoff) AddBackoff(p peer.ID, addr ma.Multiaddr) {
 	ba.tries++
 }
 
+// backoffs returns the addresses of p that are backed off, and until when.
+func (db *DialBackoff) backoffs(p peer.ID) []addrBackoff {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	now := time.Now()
+	var res []addrBackoff
+	for saddr, ba := range db.entries[p] {
+		if !now.Before(ba.until) {
+			continue
+		}
+		a, err := ma.NewMultiaddrBytes([]byte(saddr))
+		if err != nil {
+			continue
+		}
+		res = append(res, addrBackoff{addr: a, until: ba.until})
+	}
+	return res
+}
+
+type addrBackoff struct {
+	addr  ma.Multiaddr
+	until time.Time
+}
+
 // Clear removes a backoff record. Clients should call this after a
 // successful Dial.
 func (db *DialBackoff) Clear(p peer.ID) {
@@ -254,16 +284,34 @@ func (s *Swarm) dialPeer(ctx context.Context, p peer.ID) (*Conn, error) {
 		return conn, nil
 	}
 
+	if s.dialObserver != nil {
+		s.dialObserver.DialStarted(p)
+	}
+	conn, err = s.dialNewConn(ctx, p)
+	if err != nil && s.dialObserver != nil {
+		s.dialObserver.DialFailed(p, err)
+	}
+	return conn, err
+}
+
+// dialNewConn dials a new connection to p.
+func (s *Swarm) dialNewConn(ctx context.Context, p peer.ID) (*Conn, error) {
 	if s.gater != nil && !s.gater.InterceptPeerDial(p) {
 		log.Debugf("gater disallowed outbound connection to peer %s", p)
-		return nil, &DialError{Peer: p, Cause: ErrGaterDisallowedConnection}
+		err := &DialError{Peer: p, Cause: ErrGaterDisallowedConnection}
+		s.dialFailures.record(p, err)
+		return nil, err
 	}
 
-	// apply the DialPeer timeout
-	ctx, cancel := context.WithTimeout(ctx, network.GetDialPeerTimeout(ctx))
+	// apply the DialPeer timeout, or the budget's if it's shorter
+	timeout := network.GetDialPeerTimeout(ctx)
+	if b := s.dialBudgetFor(ctx); b.Timeout > 0 && b.Timeout < timeout {
+		timeout = b.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	conn, err = s.dsync.Dial(ctx, p)
+	conn, err := s.dsync.Dial(ctx, p)
 	if err == nil {
 		// Ensure we connected to the correct peer.
 		// This was most likely already checked by the security protocol, but it doesn't hurt do it again here.
@@ -276,6 +324,9 @@ func (s *Swarm) dialPeer(ctx context.Context, p peer.ID) (*Conn, error) {
 	}
 
 	log.Debugf("network for %s finished dialing %s", s.local, p)
+	if s.ctx.Err() == nil && !errors.Is(ctx.Err(), context.Canceled) {
+		s.dialFailures.record(p, err)
+	}
 
 	if ctx.Err() != nil {
 		// Context error trumps any dial errors as it was likely the ultimate cause.
@@ -290,6 +341,14 @@ func (s *Swarm) dialPeer(ctx context.Context, p peer.ID) (*Conn, error) {
 	return nil, err
 }
 
+// dialBudgetFor returns the budget for a dial with ctx.
+func (s *Swarm) dialBudgetFor(ctx context.Context) network.DialBudget {
+	if b, ok := network.GetDialBudget(ctx); ok {
+		return b
+	}
+	return s.dialBudget
+}
+
 // dialWorkerLoop synchronizes and executes concurrent dials to a single peer
 func (s *Swarm) dialWorkerLoop(p peer.ID, reqch <-chan dialRequest) {
 	w := newDialWorker(s, p, reqch, nil)
@@ -486,7 +545,7 @@ var quicDraft29DialMatcher = mafmt.And(mafmt.IP, mafmt.Base(ma.P_UDP), mafmt.Bas
 
 // filterKnownUndialables takes a list of multiaddrs, and removes those
 // that we definitely don't want to dial: addresses configured to be blocked,
-// IPv6 link-local addresses, addresses without a dial-capable transport,
+// IPv6 link-local addresses without a zone, addresses without a dial-capable transport,
 // addresses that we know to be our own, and addresses with a better transport
 // available. This is an optimization to avoid wasting time on dials that we
 // know are going to fail or for which we have a better alternative.
@@ -496,7 +555,7 @@ func (s *Swarm) filterKnownUndialables(p peer.ID, addrs []ma.Multiaddr) (goodAdd
 	for _, addr := range lisAddrs {
 		// we're only sure about filtering out /ip4 and /ip6 addresses, so far
 		ma.ForEach(addr, func(c ma.Component) bool {
-			if c.Protocol().Code == ma.P_IP4 || c.Protocol().Code == ma.P_IP6 {
+			if c.Protocol().Code == ma.P_IP4 || c.Protocol().Code == ma.P_IP6 || c.Protocol().Code == ma.P_IP6ZONE {
 				ourAddrs = append(ourAddrs, addr)
 			}
 			return false
@@ -550,8 +609,8 @@ func (s *Swarm) filterKnownUndialables(p peer.ID, addrs []ma.Multiaddr) (goodAdd
 			}
 			return true
 		},
-		// TODO: Consider allowing link-local addresses
-		func(addr ma.Multiaddr) bool { return !manet.IsIP6LinkLocal(addr) },
+		// link-local addresses can only be dialed on the interface given by their zone
+		func(addr ma.Multiaddr) bool { return !manet.IsIP6LinkLocal(addr) || hasIP6Zone(addr) },
 		func(addr ma.Multiaddr) bool {
 			if s.gater != nil && !s.gater.InterceptAddrDial(p, addr) {
 				addrErrs = append(addrErrs, TransportError{Address: addr, Cause: ErrGaterDisallowedConnection})
@@ -562,6 +621,11 @@ func (s *Swarm) filterKnownUndialables(p peer.ID, addrs []ma.Multiaddr) (goodAdd
 	), addrErrs
 }
 
+// hasIP6Zone returns true if addr starts with an /ip6zone component.
+func hasIP6Zone(addr ma.Multiaddr) bool {
+	return len(addr) > 0 && addr[0].Code() == ma.P_IP6ZONE
+}
+
 // limitedDial will start a dial to the given peer when
 // it is able, respecting the various different types of rate
 // limiting that occur without using extra goroutines per addr
@@ -598,6 +662,16 @@ func (s *Swarm) dialAddr(ctx context.Context, p peer.ID, addr ma.Multiaddr, updC
 	}
 
 	start := time.Now()
+	pending := &PendingDial{Peer: p, Addr: addr, Started: start}
+	s.pendingDials.Lock()
+	s.pendingDials.m[pending] = struct{}{}
+	s.pendingDials.Unlock()
+	defer func() {
+		s.pendingDials.Lock()
+		delete(s.pendingDials.m, pending)
+		s.pendingDials.Unlock()
+	}()
+
 	var connC transport.CapableConn
 	var err error
 	if du, ok := tpt.(transport.DialUpdater); ok {
@@ -636,6 +710,33 @@ func (s *Swarm) dialAddr(ctx context.Context, p peer.ID, addr ma.Multiaddr, updC
 	return connC, nil
 }
 
+// PendingDial is a transport dial in flight.
+type PendingDial struct {
+	Peer    peer.ID
+	Addr    ma.Multiaddr
+	Started time.Time
+	// Age is how long the dial has been running when PendingDials was called.
+	Age time.Duration
+}
+
+// PendingDials returns the transport dials that are in flight, oldest first.
+// Dials are aborted when the context of the Connect or NewStream call that
+// started them is canceled, so a dial that stays pending longer than the dial
+// timeout indicates a transport that doesn't respect cancellation.
+func (s *Swarm) PendingDials() []PendingDial {
+	now := time.Now()
+	s.pendingDials.Lock()
+	dials := make([]PendingDial, 0, len(s.pendingDials.m))
+	for d := range s.pendingDials.m {
+		pd := *d
+		pd.Age = now.Sub(pd.Started)
+		dials = append(dials, pd)
+	}
+	s.pendingDials.Unlock()
+	slices.SortFunc(dials, func(a, b PendingDial) int { return a.Started.Compare(b.Started) })
+	return dials
+}
+
 // TODO We should have a `IsFdConsuming() bool` method on the `Transport` interface in go-libp2p/core/transport.
 // This function checks if any of the transport protocols in the address requires a file descriptor.
 // For now:
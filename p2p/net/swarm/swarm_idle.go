@@ -0,0 +1,122 @@
+package swarm
+
+import (
+	"errors"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// maxIdleCheckInterval bounds the interval at which connections are checked
+// for being idle.
+const maxIdleCheckInterval = time.Minute
+
+// IdleConnPolicy configures closing connections that have been idle, i.e.
+// had no streams and transferred no data, for longer than Timeout.
+type IdleConnPolicy struct {
+	// Timeout is the time after which an idle connection is closed.
+	Timeout time.Duration
+	// IsProtected returns whether connections to a peer are exempt from being
+	// closed. Use this to exempt peers protected by the connection manager,
+	// e.g. by passing func(p peer.ID) bool { return cm.IsProtected(p, "") }.
+	IsProtected func(p peer.ID) bool
+	// ExemptProtocols exempts connections to peers that, according to the
+	// peerstore, support any of these protocols.
+	ExemptProtocols []protocol.ID
+}
+
+// WithIdleConnPolicy configures the swarm to close idle connections. An
+// event.EvtPeerConnectionIdleClosed is emitted for every connection closed
+// this way.
+func WithIdleConnPolicy(p IdleConnPolicy) Option {
+	return func(s *Swarm) error {
+		if p.Timeout <= 0 {
+			return errors.New("idle connection timeout must be positive")
+		}
+		s.idlePolicy = &p
+		return nil
+	}
+}
+
+// idleCloser periodically closes the connections that were idle for longer
+// than the idle timeout.
+//
+// The caller must take a swarm ref before calling. This function decrements
+// the swarm ref count.
+func (s *Swarm) idleCloser() {
+	defer s.refs.Done()
+
+	interval := min(s.idlePolicy.Timeout/4, maxIdleCheckInterval)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case now := <-t.C:
+			s.closeIdleConns(now)
+		}
+	}
+}
+
+func (s *Swarm) closeIdleConns(now time.Time) {
+	var idle []*Conn
+	s.conns.RLock()
+	for _, cs := range s.conns.m {
+		for _, c := range cs {
+			if c.idleFor(now) >= s.idlePolicy.Timeout {
+				idle = append(idle, c)
+			}
+		}
+	}
+	s.conns.RUnlock()
+
+	for _, c := range idle {
+		p := c.RemotePeer()
+		if s.isIdleExempt(p) {
+			continue
+		}
+		// Check again, the connection might have been used in the meantime.
+		idleFor := c.idleFor(now)
+		if idleFor < s.idlePolicy.Timeout {
+			continue
+		}
+		log.Debugf("closing connection to %s, idle for %s", p, idleFor)
+		c.Close()
+		s.idleEmitter.Emit(event.EvtPeerConnectionIdleClosed{Peer: p, Conn: c, IdleFor: idleFor})
+	}
+}
+
+func (s *Swarm) isIdleExempt(p peer.ID) bool {
+	if s.idlePolicy.IsProtected != nil && s.idlePolicy.IsProtected(p) {
+		return true
+	}
+	if len(s.idlePolicy.ExemptProtocols) == 0 {
+		return false
+	}
+	protos, err := s.peers.SupportsProtocols(p, s.idlePolicy.ExemptProtocols...)
+	return err == nil && len(protos) > 0
+}
+
+// markActive records activity on the connection, resetting its idle time.
+// Activity isn't tracked without an idle connection policy.
+func (c *Conn) markActive() {
+	if c.swarm.idlePolicy == nil {
+		return
+	}
+	c.lastActive.Store(time.Now().UnixNano())
+}
+
+// idleFor returns how long the connection has been idle at now. A connection
+// with open streams is never idle.
+func (c *Conn) idleFor(now time.Time) time.Duration {
+	c.streams.Lock()
+	numStreams := c.stat.NumStreams
+	c.streams.Unlock()
+	if numStreams > 0 {
+		return 0
+	}
+	return now.Sub(time.Unix(0, c.lastActive.Load()))
+}
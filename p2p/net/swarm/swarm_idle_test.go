@@ -0,0 +1,95 @@
+package swarm_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+	. "github.com/libp2p/go-libp2p/p2p/net/swarm"
+	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func connectSwarm(t *testing.T, from, to *Swarm) {
+	t.Helper()
+	from.Peerstore().AddAddrs(to.LocalPeer(), to.ListenAddresses(), time.Hour)
+	_, err := from.DialPeer(context.Background(), to.LocalPeer())
+	require.NoError(t, err)
+}
+
+func TestIdleConnClosed(t *testing.T) {
+	const timeout = 300 * time.Millisecond
+	bus := eventbus.NewBus()
+	sub, err := bus.Subscribe(new(event.EvtPeerConnectionIdleClosed))
+	require.NoError(t, err)
+	defer sub.Close()
+	s1 := swarmt.GenSwarm(t, swarmt.EventBus(bus), swarmt.WithSwarmOpts(WithIdleConnPolicy(IdleConnPolicy{Timeout: timeout})))
+	defer s1.Close()
+	s2 := swarmt.GenSwarm(t)
+	defer s2.Close()
+	s2.SetStreamHandler(func(s network.Stream) {})
+
+	connectSwarm(t, s1, s2)
+	str, err := s1.NewStream(context.Background(), s2.LocalPeer())
+	require.NoError(t, err)
+
+	// A connection with an open stream isn't idle.
+	time.Sleep(3 * timeout)
+	require.Equal(t, network.Connected, s1.Connectedness(s2.LocalPeer()))
+
+	start := time.Now()
+	str.Close()
+	select {
+	case e := <-sub.Out():
+		evt := e.(event.EvtPeerConnectionIdleClosed)
+		require.Equal(t, s2.LocalPeer(), evt.Peer)
+		require.GreaterOrEqual(t, evt.IdleFor, timeout)
+		require.GreaterOrEqual(t, time.Since(start), timeout)
+		require.True(t, evt.Conn.IsClosed())
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the idle connection to be closed")
+	}
+	require.Eventually(t, func() bool {
+		return s1.Connectedness(s2.LocalPeer()) != network.Connected
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestIdleConnExemptions(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	const exemptProto = protocol.ID("/exempt")
+
+	protected := swarmt.GenSwarm(t)
+	defer protected.Close()
+	exempt := swarmt.GenSwarm(t)
+	defer exempt.Close()
+	other := swarmt.GenSwarm(t)
+	defer other.Close()
+
+	s := swarmt.GenSwarm(t, swarmt.WithSwarmOpts(WithIdleConnPolicy(IdleConnPolicy{
+		Timeout:         timeout,
+		IsProtected:     func(p peer.ID) bool { return p == protected.LocalPeer() },
+		ExemptProtocols: []protocol.ID{exemptProto},
+	})))
+	defer s.Close()
+	require.NoError(t, s.Peerstore().AddProtocols(exempt.LocalPeer(), exemptProto))
+
+	for _, to := range []*Swarm{protected, exempt, other} {
+		connectSwarm(t, s, to)
+	}
+	require.Eventually(t, func() bool {
+		return s.Connectedness(other.LocalPeer()) != network.Connected
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, network.Connected, s.Connectedness(protected.LocalPeer()))
+	require.Equal(t, network.Connected, s.Connectedness(exempt.LocalPeer()))
+}
+
+func TestIdleConnPolicyInvalidTimeout(t *testing.T) {
+	_, err := NewSwarm("", nil, eventbus.NewBus(), WithIdleConnPolicy(IdleConnPolicy{}))
+	require.Error(t, err)
+}
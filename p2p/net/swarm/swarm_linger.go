@@ -0,0 +1,53 @@
+package swarm
+
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+)
+
+var (
+	_ network.Lingerer = (*Conn)(nil)
+	_ network.Lingerer = (*Stream)(nil)
+)
+
+// WithStreamLinger sets how long closing a stream waits for the written data
+// to be received, for all streams of the swarm. See network.CloseWithLinger
+// for the meaning of linger. It can be overridden per connection with
+// Conn.SetLinger and per stream with Stream.SetLinger.
+//
+// By default, Close doesn't wait, and the behavior depends on the stream
+// muxer.
+func WithStreamLinger(linger time.Duration) Option {
+	return func(s *Swarm) error {
+		s.streamLinger = &linger
+		return nil
+	}
+}
+
+// SetLinger sets how long closing the streams of this connection waits for
+// the written data to be received. See network.CloseWithLinger.
+func (c *Conn) SetLinger(linger time.Duration) {
+	c.streamLinger.Store(&linger)
+}
+
+// SetLinger sets how long Close waits for the written data to be received.
+// See network.CloseWithLinger.
+func (s *Stream) SetLinger(linger time.Duration) {
+	s.linger.Store(&linger)
+}
+
+// lingerDuration returns the linger set on the stream, its connection or the
+// swarm, in that order.
+func (s *Stream) lingerDuration() (time.Duration, bool) {
+	if l := s.linger.Load(); l != nil {
+		return *l, true
+	}
+	if l := s.conn.streamLinger.Load(); l != nil {
+		return *l, true
+	}
+	if l := s.conn.swarm.streamLinger; l != nil {
+		return *l, true
+	}
+	return 0, false
+}
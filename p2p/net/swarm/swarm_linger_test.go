@@ -0,0 +1,97 @@
+package swarm_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	. "github.com/libp2p/go-libp2p/p2p/net/swarm"
+	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamLinger(t *testing.T) {
+	const linger = 300 * time.Millisecond
+	s1 := swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.WithSwarmOpts(WithStreamLinger(linger)))
+	defer s1.Close()
+	s2 := swarmt.GenSwarm(t, swarmt.OptDisableQUIC)
+	defer s2.Close()
+
+	type result struct {
+		data []byte
+		err  error
+	}
+	received := make(chan result, 1)
+	release := make(chan struct{})
+	accepted := make(chan struct{}, 1)
+	s2.SetStreamHandler(func(s network.Stream) {
+		select {
+		case accepted <- struct{}{}:
+		default:
+		}
+		b, err := io.ReadAll(s)
+		received <- result{data: b, err: err}
+		<-release
+		s.Close()
+	})
+	connectSwarm(t, s1, s2)
+
+	t.Run("acknowledged", func(t *testing.T) {
+		str, err := s1.NewStream(context.Background(), s2.LocalPeer())
+		require.NoError(t, err)
+		_, err = str.Write([]byte("request"))
+		require.NoError(t, err)
+		go func() {
+			time.Sleep(linger / 3)
+			release <- struct{}{}
+		}()
+		start := time.Now()
+		require.NoError(t, str.Close())
+		require.Less(t, time.Since(start), linger)
+		r := <-received
+		require.NoError(t, r.err)
+		require.Equal(t, []byte("request"), r.data)
+	})
+
+	t.Run("not acknowledged", func(t *testing.T) {
+		str, err := s1.NewStream(context.Background(), s2.LocalPeer())
+		require.NoError(t, err)
+		start := time.Now()
+		require.Error(t, str.Close())
+		require.GreaterOrEqual(t, time.Since(start), linger)
+		require.NoError(t, (<-received).err)
+		release <- struct{}{}
+	})
+
+	t.Run("per stream", func(t *testing.T) {
+		str, err := s1.NewStream(context.Background(), s2.LocalPeer())
+		require.NoError(t, err)
+		str.(network.Lingerer).SetLinger(-1)
+		start := time.Now()
+		require.NoError(t, str.Close())
+		require.Less(t, time.Since(start), linger)
+		require.NoError(t, (<-received).err)
+		release <- struct{}{}
+	})
+
+	t.Run("per connection", func(t *testing.T) {
+		conns := s1.ConnsToPeer(s2.LocalPeer())
+		require.Len(t, conns, 1)
+		conns[0].(network.Lingerer).SetLinger(0)
+		select {
+		case <-accepted:
+		default:
+		}
+		str, err := s1.NewStream(context.Background(), s2.LocalPeer())
+		require.NoError(t, err)
+		_, err = str.Write([]byte("request"))
+		require.NoError(t, err)
+		<-accepted
+		require.NoError(t, str.Close())
+		require.ErrorIs(t, (<-received).err, network.ErrReset)
+		release <- struct{}{}
+	})
+}
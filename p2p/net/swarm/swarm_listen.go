@@ -2,7 +2,6 @@ package swarm
 
 import (
 	"errors"
-	"fmt"
 	"slices"
 	"time"
 
@@ -24,6 +23,11 @@ type OrderedListener interface {
 
 // Listen sets up listeners for all of the given addresses.
 // It returns as long as we successfully listen on at least *one* address.
+//
+// Listen fails without listening on any address if no transport can listen on
+// one of the addresses, or if addresses conflict with each other or with an
+// existing listener, e.g. /ip4/0.0.0.0/tcp/4001 and /ip4/127.0.0.1/tcp/4001.
+// The returned *ListenError lists all offending addresses.
 func (s *Swarm) Listen(addrs ...ma.Multiaddr) error {
 	errs := make([]error, len(addrs))
 	var succeeded int
@@ -37,6 +41,15 @@ func (s *Swarm) Listen(addrs ...ma.Multiaddr) error {
 		t := s.TransportForListening(a)
 		sortedAddrsAndTpts = append(sortedAddrsAndTpts, addrAndListener{addr: a, lTpt: t})
 	}
+
+	select {
+	case <-s.ctx.Done():
+		return ErrSwarmClosed
+	default:
+	}
+	if err := s.checkListenAddrs(addrs); err != nil {
+		return err
+	}
 	slices.SortFunc(sortedAddrsAndTpts, func(a, b addrAndListener) int {
 		aOrder := 0
 		bOrder := 0
@@ -64,9 +77,57 @@ func (s *Swarm) Listen(addrs ...ma.Multiaddr) error {
 	}
 
 	if succeeded == 0 && len(sortedAddrsAndTpts) > 0 {
-		return fmt.Errorf("failed to listen on any addresses: %s", errs)
+		lerr := &ListenError{}
+		for i, a := range sortedAddrsAndTpts {
+			lerr.ListenErrors = append(lerr.ListenErrors, ListenAddrError{Address: a.addr, Transport: a.lTpt, Cause: errs[i]})
+		}
+		return lerr
+	}
+
+	return nil
+}
+
+// checkListenAddrs checks that there is a transport for every address, and
+// that the addresses don't conflict with each other or existing listeners.
+func (s *Swarm) checkListenAddrs(addrs []ma.Multiaddr) error {
+	type addrAndSocket struct {
+		addr   ma.Multiaddr
+		socket listenSocket
+	}
+	var sockets []addrAndSocket
+	for _, a := range s.ListenAddresses() {
+		if sock, ok := toListenSocket(s.TransportForListening(a), a); ok {
+			sockets = append(sockets, addrAndSocket{addr: a, socket: sock})
+		}
 	}
 
+	var lerr ListenError
+	for _, a := range addrs {
+		tpt := s.TransportForListening(a)
+		if tpt == nil {
+			lerr.ListenErrors = append(lerr.ListenErrors, ListenAddrError{Address: a, Cause: ErrNoTransport})
+			continue
+		}
+		sock, ok := toListenSocket(tpt, a)
+		if !ok {
+			continue
+		}
+		for _, other := range sockets {
+			if sock.conflicts(other.socket) {
+				lerr.ListenErrors = append(lerr.ListenErrors, ListenAddrError{
+					Address:       a,
+					Transport:     tpt,
+					ConflictsWith: other.addr,
+					Cause:         ErrListenAddrConflict,
+				})
+				break
+			}
+		}
+		sockets = append(sockets, addrAndSocket{addr: a, socket: sock})
+	}
+	if len(lerr.ListenErrors) > 0 {
+		return &lerr
+	}
 	return nil
 }
 
@@ -174,6 +235,11 @@ func (s *Swarm) AddListenAddr(a ma.Multiaddr) error {
 			s.refs.Add(1)
 			go func() {
 				defer s.refs.Done()
+				if !s.acceptInbound(c.RemotePeer()) {
+					log.Debugw("inbound connections are paused, closing connection", "peer", c.RemotePeer(), "addr", c.RemoteMultiaddr())
+					c.CloseWithError(network.ConnMaintenance)
+					return
+				}
 				_, err := s.addConn(c, network.DirInbound)
 				switch err {
 				case nil:
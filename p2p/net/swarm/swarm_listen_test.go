@@ -0,0 +1,85 @@
+package swarm_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenAddrConflicts(t *testing.T) {
+	s := swarmt.GenSwarm(t, swarmt.OptDialOnly, swarmt.OptDisableQUIC)
+	defer s.Close()
+
+	err := s.Listen(
+		ma.StringCast("/ip4/0.0.0.0/tcp/4001"),
+		ma.StringCast("/ip4/127.0.0.1/tcp/4001"),
+		ma.StringCast("/ip4/127.0.0.1/udp/4001/quic-v1"),
+		ma.StringCast("/ip6/::1/tcp/4001"),
+		ma.StringCast("/ip4/127.0.0.1/tcp/4002"),
+	)
+	var lerr *swarm.ListenError
+	require.ErrorAs(t, err, &lerr)
+	require.Len(t, lerr.ListenErrors, 2)
+
+	conflict := lerr.ListenErrors[0]
+	require.ErrorIs(t, &conflict, swarm.ErrListenAddrConflict)
+	require.Equal(t, "/ip4/127.0.0.1/tcp/4001", conflict.Address.String())
+	require.Equal(t, "/ip4/0.0.0.0/tcp/4001", conflict.ConflictsWith.String())
+	require.NotNil(t, conflict.Transport)
+
+	noTransport := lerr.ListenErrors[1]
+	require.ErrorIs(t, &noTransport, swarm.ErrNoTransport)
+	require.Equal(t, "/ip4/127.0.0.1/udp/4001/quic-v1", noTransport.Address.String())
+	require.Nil(t, noTransport.Transport)
+
+	require.ErrorIs(t, err, swarm.ErrListenAddrConflict)
+	require.ErrorIs(t, err, swarm.ErrNoTransport)
+	require.Contains(t, err.Error(), "/ip4/127.0.0.1/tcp/4001")
+	require.Contains(t, err.Error(), "/ip4/127.0.0.1/udp/4001/quic-v1")
+
+	// Nothing was listened on.
+	require.Empty(t, s.ListenAddresses())
+}
+
+func TestListenAddrConflictWithExistingListener(t *testing.T) {
+	s := swarmt.GenSwarm(t, swarmt.OptDialOnly)
+	defer s.Close()
+
+	require.NoError(t, s.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0")))
+	require.Len(t, s.ListenAddresses(), 1)
+	laddr := s.ListenAddresses()[0]
+	port, err := laddr.ValueForProtocol(ma.P_TCP)
+	require.NoError(t, err)
+
+	err = s.Listen(ma.StringCast("/ip4/0.0.0.0/tcp/" + port))
+	require.ErrorIs(t, err, swarm.ErrListenAddrConflict)
+	var lerr *swarm.ListenError
+	require.ErrorAs(t, err, &lerr)
+	require.Len(t, lerr.ListenErrors, 1)
+	require.True(t, lerr.ListenErrors[0].ConflictsWith.Equal(laddr))
+
+	// Different transports may share a port.
+	require.NoError(t, s.Listen(ma.StringCast("/ip4/127.0.0.1/udp/"+port+"/quic-v1")))
+	// Port 0 never conflicts.
+	require.NoError(t, s.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0")))
+}
+
+func TestListenAllFail(t *testing.T) {
+	s := swarmt.GenSwarm(t, swarmt.OptDialOnly)
+	defer s.Close()
+
+	// Not a local address.
+	err := s.Listen(ma.StringCast("/ip4/1.2.3.4/tcp/0"))
+	var lerr *swarm.ListenError
+	require.ErrorAs(t, err, &lerr)
+	require.Len(t, lerr.ListenErrors, 1)
+	require.Equal(t, "/ip4/1.2.3.4/tcp/0", lerr.ListenErrors[0].Address.String())
+	require.NotNil(t, lerr.ListenErrors[0].Transport)
+	require.Error(t, lerr.ListenErrors[0].Cause)
+	require.False(t, errors.Is(err, swarm.ErrListenAddrConflict))
+}
@@ -0,0 +1,103 @@
+package swarm_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/transport"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
+	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+// pathChangeTransport is a transport that lets the test trigger path change
+// notifications for the connections it dialed.
+type pathChangeTransport struct {
+	transport.Transport
+
+	mx     sync.Mutex
+	conns  []transport.CapableConn
+	notify func(transport.CapableConn, ma.Multiaddr)
+}
+
+var _ transport.PathChangeNotifier = &pathChangeTransport{}
+
+func (t *pathChangeTransport) Dial(ctx context.Context, raddr ma.Multiaddr, p peer.ID) (transport.CapableConn, error) {
+	c, err := t.Transport.Dial(ctx, raddr, p)
+	if err != nil {
+		return nil, err
+	}
+	t.mx.Lock()
+	t.conns = append(t.conns, c)
+	t.mx.Unlock()
+	return c, nil
+}
+
+func (t *pathChangeTransport) NotifyPathChange(f func(transport.CapableConn, ma.Multiaddr)) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.notify = f
+}
+
+func TestPathChangeEvent(t *testing.T) {
+	bus := eventbus.NewBus()
+	sub, err := bus.Subscribe(new(event.EvtPeerConnectionPathChanged))
+	require.NoError(t, err)
+	defer sub.Close()
+	s1 := swarmt.GenSwarm(t, swarmt.EventBus(bus), swarmt.OptDialOnly, swarmt.OptDisableTCP,
+		swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC)
+	defer s1.Close()
+	tcpTransport, err := tcp.NewTCPTransport(swarmt.GenUpgrader(t, s1, nil), nil, nil)
+	require.NoError(t, err)
+	tpt := &pathChangeTransport{Transport: tcpTransport}
+	require.NoError(t, s1.AddTransport(tpt))
+	s2 := swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC)
+	defer s2.Close()
+
+	connectSwarm(t, s1, s2)
+	conns := s1.ConnsToPeer(s2.LocalPeer())
+	require.Len(t, conns, 1)
+
+	oldRemote := ma.StringCast("/ip4/192.0.2.1/tcp/1234")
+	tpt.mx.Lock()
+	require.Len(t, tpt.conns, 1)
+	tc, notify := tpt.conns[0], tpt.notify
+	tpt.mx.Unlock()
+	require.NotNil(t, notify)
+	notify(tc, oldRemote)
+
+	select {
+	case e := <-sub.Out():
+		evt := e.(event.EvtPeerConnectionPathChanged)
+		require.Equal(t, s2.LocalPeer(), evt.Peer)
+		require.Equal(t, conns[0], evt.Conn)
+		require.True(t, oldRemote.Equal(evt.OldRemoteAddr))
+		require.True(t, conns[0].RemoteMultiaddr().Equal(evt.NewRemoteAddr))
+		require.True(t, conns[0].LocalMultiaddr().Equal(evt.LocalAddr))
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a path change event")
+	}
+	require.Contains(t, s1.Peerstore().Addrs(s2.LocalPeer()), conns[0].RemoteMultiaddr())
+}
+
+func TestMigrateUnsupported(t *testing.T) {
+	s1 := swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC)
+	defer s1.Close()
+	s2 := swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC)
+	defer s2.Close()
+
+	connectSwarm(t, s1, s2)
+	conns := s1.ConnsToPeer(s2.LocalPeer())
+	require.Len(t, conns, 1)
+	c, ok := conns[0].(network.MigratableConn)
+	require.True(t, ok)
+	require.ErrorIs(t, c.Migrate(context.Background()), network.ErrMigrationUnsupported)
+}
@@ -0,0 +1,25 @@
+package swarm
+
+import "github.com/libp2p/go-libp2p/core/peer"
+
+type inboundPause struct {
+	allow func(peer.ID) bool
+}
+
+// PauseInbound makes the swarm close new inbound connections with
+// network.ConnMaintenance, unless allow returns true for the remote peer.
+// allow may be nil. The listeners stay open, and neither existing connections
+// nor outbound dials are affected. Calling PauseInbound again replaces allow.
+func (s *Swarm) PauseInbound(allow func(peer.ID) bool) {
+	s.inboundPause.Store(&inboundPause{allow: allow})
+}
+
+// ResumeInbound undoes PauseInbound.
+func (s *Swarm) ResumeInbound() {
+	s.inboundPause.Store(nil)
+}
+
+func (s *Swarm) acceptInbound(p peer.ID) bool {
+	pause := s.inboundPause.Load()
+	return pause == nil || (pause.allow != nil && pause.allow(p))
+}
@@ -0,0 +1,49 @@
+package swarm_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	. "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPauseInbound(t *testing.T) {
+	sw1 := GenSwarm(t, OptDisableQUIC)
+	sw2 := GenSwarm(t, OptDisableQUIC)
+	sw3 := GenSwarm(t, OptDisableQUIC)
+	sw2.Peerstore().AddAddrs(sw1.LocalPeer(), sw1.ListenAddresses(), peerstore.PermanentAddrTTL)
+	sw3.Peerstore().AddAddrs(sw1.LocalPeer(), sw1.ListenAddresses(), peerstore.PermanentAddrTTL)
+
+	sw1.PauseInbound(func(p peer.ID) bool { return p == sw3.LocalPeer() })
+	// the dial may succeed before sw1 closes the connection
+	sw2.DialPeer(context.Background(), sw1.LocalPeer())
+	require.Eventually(t, func() bool {
+		return len(sw2.ConnsToPeer(sw1.LocalPeer())) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Empty(t, sw1.ConnsToPeer(sw2.LocalPeer()))
+
+	_, err := sw3.DialPeer(context.Background(), sw1.LocalPeer())
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return len(sw1.ConnsToPeer(sw3.LocalPeer())) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// outbound dials aren't affected
+	sw1.Peerstore().AddAddrs(sw2.LocalPeer(), sw2.ListenAddresses(), peerstore.PermanentAddrTTL)
+	_, err = sw1.DialPeer(context.Background(), sw2.LocalPeer())
+	require.NoError(t, err)
+	sw1.ClosePeer(sw2.LocalPeer())
+
+	sw1.ResumeInbound()
+	sw2.Backoff().Clear(sw1.LocalPeer())
+	_, err = sw2.DialPeer(context.Background(), sw1.LocalPeer())
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return len(sw1.ConnsToPeer(sw2.LocalPeer())) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,204 @@
+package swarm
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// rttSmoothing is the weight of a new sample in a connection's RTT moving
+// average.
+const rttSmoothing = 0.1
+
+// rttPreferenceMargin is the fraction by which a connection's RTT must be
+// lower than another's for it to be preferred. RTTs are compared on a
+// logarithmic scale with steps of this size, which avoids switching between
+// connections with similar RTTs while keeping the ranking of connections
+// consistent.
+const rttPreferenceMargin = 0.1
+
+// ConnQualityStat describes the quality of a connection.
+type ConnQualityStat struct {
+	Conn network.Conn
+	// RTT is the moving average of the RTT samples recorded for the
+	// connection. It is zero if no samples were recorded.
+	RTT time.Duration
+	// RTTSamples is the number of RTT samples recorded.
+	RTTSamples int
+	// StreamFailures is the number of streams that failed to open.
+	StreamFailures int
+	// ConsecutiveStreamFailures is the number of streams that failed to open
+	// since the last stream was opened successfully.
+	ConsecutiveStreamFailures int
+	// BytesSent and BytesReceived count the stream data transferred.
+	BytesSent     uint64
+	BytesReceived uint64
+	// Throughput is the average number of bytes transferred per second, in
+	// both directions, since the connection was opened.
+	Throughput float64
+}
+
+// QualityReport describes the quality of the connections to a peer.
+type QualityReport struct {
+	Peer peer.ID
+	// Conns are the open connections to the peer, from best to worst. New
+	// streams are opened on the first connection.
+	Conns []ConnQualityStat
+}
+
+// ConnQuality returns a report about the quality of the connections to p.
+func (s *Swarm) ConnQuality(p peer.ID) QualityReport {
+	s.conns.RLock()
+	conns := make([]*Conn, 0, len(s.conns.m[p]))
+	for _, c := range s.conns.m[p] {
+		if !c.conn.IsClosed() {
+			conns = append(conns, c)
+		}
+	}
+	// Sort from newest to oldest first, since bestConnToPeer picks the
+	// newest of otherwise equal connections.
+	slices.Reverse(conns)
+	ranks := make(map[*Conn]connRank, len(conns))
+	for _, c := range conns {
+		ranks[c] = c.rank()
+	}
+	slices.SortStableFunc(conns, func(a, b *Conn) int {
+		return compareConnRanks(ranks[a], ranks[b])
+	})
+	s.conns.RUnlock()
+
+	report := QualityReport{Peer: p, Conns: make([]ConnQualityStat, 0, len(conns))}
+	now := time.Now()
+	for _, c := range conns {
+		report.Conns = append(report.Conns, c.qualityStat(now))
+	}
+	return report
+}
+
+// connQuality tracks the samples used to judge the quality of a connection.
+type connQuality struct {
+	mx                  sync.Mutex
+	rtt                 time.Duration
+	rttSamples          int
+	streamFailures      int
+	consecutiveFailures int
+
+	bytesSent, bytesReceived atomic.Uint64
+}
+
+func (q *connQuality) recordRTT(rtt time.Duration) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	if q.rttSamples == 0 {
+		q.rtt = rtt
+	} else {
+		q.rtt = time.Duration(rttSmoothing*float64(rtt) + (1-rttSmoothing)*float64(q.rtt))
+	}
+	q.rttSamples++
+}
+
+func (q *connQuality) recordStreamOpened(err error) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	if err != nil {
+		q.streamFailures++
+		q.consecutiveFailures++
+	} else {
+		q.consecutiveFailures = 0
+	}
+}
+
+// RecordRTT records an RTT sample for the connection, e.g. measured by the
+// ping protocol. RTT samples are used to prefer connections with lower
+// latency when opening new streams.
+func (c *Conn) RecordRTT(rtt time.Duration) {
+	c.quality.recordRTT(rtt)
+}
+
+func (c *Conn) qualityStat(now time.Time) ConnQualityStat {
+	c.quality.mx.Lock()
+	st := ConnQualityStat{
+		Conn:                      c,
+		RTT:                       c.quality.rtt,
+		RTTSamples:                c.quality.rttSamples,
+		StreamFailures:            c.quality.streamFailures,
+		ConsecutiveStreamFailures: c.quality.consecutiveFailures,
+	}
+	c.quality.mx.Unlock()
+	st.BytesSent = c.quality.bytesSent.Load()
+	st.BytesReceived = c.quality.bytesReceived.Load()
+	if age := now.Sub(c.Stat().Opened); age > 0 {
+		st.Throughput = float64(st.BytesSent+st.BytesReceived) / age.Seconds()
+	}
+	return st
+}
+
+// connRank is a snapshot of the properties connections are ranked by.
+type connRank struct {
+	limited, direct bool
+	failures        int
+	// rttBucket is the RTT on a logarithmic scale, or -1 if no RTT samples
+	// were recorded.
+	rttBucket int
+	streams   int
+}
+
+func (c *Conn) rank() connRank {
+	r := connRank{
+		limited: c.Stat().Limited,
+		direct:  isDirectConn(c),
+	}
+	c.quality.mx.Lock()
+	r.failures = c.quality.consecutiveFailures
+	r.rttBucket = -1
+	if c.quality.rttSamples > 0 {
+		r.rttBucket = rttBucket(c.quality.rtt)
+	}
+	c.quality.mx.Unlock()
+	c.streams.Lock()
+	r.streams = len(c.streams.m)
+	c.streams.Unlock()
+	return r
+}
+
+// rttBucket returns the logarithm of rtt to the base 1/(1-rttPreferenceMargin).
+func rttBucket(rtt time.Duration) int {
+	return int(math.Log(float64(max(rtt, 1))) / -math.Log1p(-rttPreferenceMargin))
+}
+
+// compareConnRanks returns -1 if a ranks better than b, 1 if b ranks better
+// than a, and 0 if they rank the same. It's a strict weak ordering, so it can
+// be used to sort connections.
+func compareConnRanks(a, b connRank) int {
+	if a.limited != b.limited {
+		return boolRank(!a.limited)
+	}
+	if a.direct != b.direct {
+		return boolRank(a.direct)
+	}
+	if c := cmp.Compare(a.failures, b.failures); c != 0 {
+		return c
+	}
+	if a.rttBucket != b.rttBucket {
+		// Connections with RTT samples are preferred over those without.
+		if a.rttBucket < 0 || b.rttBucket < 0 {
+			return boolRank(a.rttBucket >= 0)
+		}
+		return cmp.Compare(a.rttBucket, b.rttBucket)
+	}
+	return cmp.Compare(b.streams, a.streams)
+}
+
+// boolRank returns -1 if aBetter, and 1 otherwise.
+func boolRank(aBetter bool) int {
+	if aBetter {
+		return -1
+	}
+	return 1
+}
@@ -0,0 +1,104 @@
+package swarm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnQuality(t *testing.T) {
+	s1 := makeSwarmWithNoListenAddrs(t)
+	defer s1.Close()
+	s2 := makeSwarmWithNoListenAddrs(t)
+	defer s2.Close()
+	require.NoError(t, s2.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0")))
+	s2.SetStreamHandler(func(s network.Stream) {
+		io.Copy(s, s)
+		s.Close()
+	})
+
+	p := s2.LocalPeer()
+	s1.Peerstore().AddAddrs(p, s2.ListenAddresses(), time.Hour)
+	dial := func() *Conn {
+		tc, err := s1.dialAddr(context.Background(), p, s2.ListenAddresses()[0], nil)
+		require.NoError(t, err)
+		c, err := s1.addConn(tc, network.DirOutbound)
+		require.NoError(t, err)
+		return c
+	}
+	c1 := dial()
+	c2 := dial()
+	// Without samples, the newest connection is preferred.
+	require.Equal(t, c2, s1.bestConnToPeer(p))
+
+	// A clearly lower RTT is preferred.
+	c1.RecordRTT(10 * time.Millisecond)
+	c2.RecordRTT(50 * time.Millisecond)
+	require.Equal(t, c1, s1.bestConnToPeer(p))
+	report := s1.ConnQuality(p)
+	require.Equal(t, p, report.Peer)
+	require.Len(t, report.Conns, 2)
+	require.Equal(t, c1, report.Conns[0].Conn)
+	require.Equal(t, 10*time.Millisecond, report.Conns[0].RTT)
+	require.Equal(t, 1, report.Conns[0].RTTSamples)
+	require.Equal(t, c2, report.Conns[1].Conn)
+
+	// Failures to open streams outweigh the RTT.
+	c1.quality.recordStreamOpened(errors.New("failed"))
+	require.Equal(t, c2, s1.bestConnToPeer(p))
+	report = s1.ConnQuality(p)
+	require.Equal(t, c2, report.Conns[0].Conn)
+	require.Equal(t, 1, report.Conns[1].StreamFailures)
+	require.Equal(t, 1, report.Conns[1].ConsecutiveStreamFailures)
+
+	// Streams are opened on the best connection, and their data is counted.
+	str, err := s1.NewStream(context.Background(), p)
+	require.NoError(t, err)
+	require.Equal(t, c2, str.Conn())
+	_, err = str.Write([]byte("foobar"))
+	require.NoError(t, err)
+	require.NoError(t, str.CloseWrite())
+	b, err := io.ReadAll(str)
+	require.NoError(t, err)
+	require.Equal(t, "foobar", string(b))
+	str.Close()
+	report = s1.ConnQuality(p)
+	require.Equal(t, uint64(6), report.Conns[0].BytesSent)
+	require.Equal(t, uint64(6), report.Conns[0].BytesReceived)
+	require.Positive(t, report.Conns[0].Throughput)
+
+	// A successful stream resets the consecutive failures.
+	_, err = c1.NewStream(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, c1, s1.bestConnToPeer(p))
+	report = s1.ConnQuality(p)
+	require.Equal(t, 1, report.Conns[0].StreamFailures)
+	require.Zero(t, report.Conns[0].ConsecutiveStreamFailures)
+}
+
+func TestCompareConnRanksTransitive(t *testing.T) {
+	// Adjacent RTTs are within the preference margin of each other, but the
+	// first and last aren't.
+	var ranks []connRank
+	for _, rtt := range []time.Duration{100 * time.Millisecond, 95 * time.Millisecond, 90 * time.Millisecond, 85 * time.Millisecond} {
+		ranks = append(ranks, connRank{direct: true, rttBucket: rttBucket(rtt)})
+	}
+	ranks = append(ranks, connRank{direct: true, rttBucket: -1, streams: 1}, connRank{direct: true, rttBucket: -1})
+	for _, a := range ranks {
+		for _, b := range ranks {
+			require.Equal(t, compareConnRanks(a, b), -compareConnRanks(b, a))
+			for _, c := range ranks {
+				if compareConnRanks(a, b) <= 0 && compareConnRanks(b, c) <= 0 {
+					require.LessOrEqual(t, compareConnRanks(a, c), 0)
+				}
+			}
+		}
+	}
+}
@@ -11,7 +11,10 @@ import (
 )
 
 // Validate Stream conforms to the go-libp2p-net Stream interface
-var _ network.Stream = &Stream{}
+var (
+	_ network.Stream            = &Stream{}
+	_ network.StreamPrioritizer = &Stream{}
+)
 
 // Stream is the stream type used by swarm. In general, you won't use this type
 // directly.
@@ -30,6 +33,13 @@ type Stream struct {
 	protocol atomic.Pointer[protocol.ID]
 
 	stat network.Stats
+
+	bytesRead, bytesWritten atomic.Uint64
+	// closed is the time, in unix nanoseconds, the stream was closed or reset.
+	closed atomic.Int64
+
+	// see SetLinger
+	linger atomic.Pointer[time.Duration]
 }
 
 func (s *Stream) ID() string {
@@ -56,6 +66,11 @@ func (s *Stream) Conn() network.Conn {
 // Read reads bytes from a stream.
 func (s *Stream) Read(p []byte) (int, error) {
 	n, err := s.stream.Read(p)
+	if n > 0 {
+		s.conn.markActive()
+		s.conn.quality.bytesReceived.Add(uint64(n))
+		s.bytesRead.Add(uint64(n))
+	}
 	// TODO: push this down to a lower level for better accuracy.
 	if s.conn.swarm.bwc != nil {
 		s.conn.swarm.bwc.LogRecvMessage(int64(n))
@@ -67,6 +82,11 @@ func (s *Stream) Read(p []byte) (int, error) {
 // Write writes bytes to a stream, flushing for each call.
 func (s *Stream) Write(p []byte) (int, error) {
 	n, err := s.stream.Write(p)
+	if n > 0 {
+		s.conn.markActive()
+		s.conn.quality.bytesSent.Add(uint64(n))
+		s.bytesWritten.Add(uint64(n))
+	}
 	// TODO: push this down to a lower level for better accuracy.
 	if s.conn.swarm.bwc != nil {
 		s.conn.swarm.bwc.LogSentMessage(int64(n))
@@ -76,9 +96,15 @@ func (s *Stream) Write(p []byte) (int, error) {
 }
 
 // Close closes the stream, closing both ends and freeing all associated
-// resources.
+// resources. If a linger is set, Close waits for the written data to be
+// received, see SetLinger.
 func (s *Stream) Close() error {
-	err := s.stream.Close()
+	var err error
+	if linger, ok := s.lingerDuration(); ok {
+		err = network.CloseWithLinger(s.stream, linger)
+	} else {
+		err = s.stream.Close()
+	}
 	s.closeAndRemoveStream()
 	return err
 }
@@ -104,6 +130,7 @@ func (s *Stream) closeAndRemoveStream() {
 		return
 	}
 	s.isClosed = true
+	s.closed.Store(time.Now().UnixNano())
 	// We don't want to keep swarm from closing till the stream handler has exited
 	s.conn.swarm.refs.Done()
 	// Cleanup the stream from connection only after the stream handler has completed
@@ -175,9 +202,28 @@ func (s *Stream) SetWriteDeadline(t time.Time) error {
 	return s.stream.SetWriteDeadline(t)
 }
 
-// Stat returns metadata information for this stream.
+// SetPriority sets the priority of the stream's writes. It returns
+// network.ErrStreamPriorityUnsupported if the stream muxer doesn't support
+// priorities.
+func (s *Stream) SetPriority(priority uint8) error {
+	if p, ok := s.stream.(network.StreamPrioritizer); ok {
+		return p.SetPriority(priority)
+	}
+	return network.ErrStreamPriorityUnsupported
+}
+
+// Stat returns metadata information for this stream, including the number of
+// bytes transferred and how long the stream has been open.
 func (s *Stream) Stat() network.Stats {
-	return s.stat
+	stat := s.stat
+	stat.BytesRead = s.bytesRead.Load()
+	stat.BytesWritten = s.bytesWritten.Load()
+	end := time.Now()
+	if closed := s.closed.Load(); closed != 0 {
+		end = time.Unix(0, closed)
+	}
+	stat.Duration = end.Sub(stat.Opened)
+	return stat
 }
 
 func (s *Stream) Scope() network.StreamScope {
@@ -0,0 +1,57 @@
+package swarm_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamSetPriority(t *testing.T) {
+	s1 := swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC)
+	defer s1.Close()
+	s2 := swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC)
+	defer s2.Close()
+	s2.SetStreamHandler(func(s network.Stream) {
+		defer s.Close()
+		io.Copy(s, s)
+	})
+	connectSwarm(t, s1, s2)
+
+	str, err := s1.NewStream(context.Background(), s2.LocalPeer())
+	require.NoError(t, err)
+	defer str.Close()
+	p, ok := str.(network.StreamPrioritizer)
+	require.True(t, ok)
+	require.NoError(t, p.SetPriority(5))
+
+	_, err = str.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(str, buf)
+	require.NoError(t, err)
+	require.Equal(t, []byte("ping"), buf)
+}
+
+func TestConnMuxerStats(t *testing.T) {
+	s1 := swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC)
+	defer s1.Close()
+	s2 := swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC)
+	defer s2.Close()
+	s2.SetStreamHandler(func(s network.Stream) {
+		defer s.Close()
+		io.Copy(s, s)
+	})
+	connectSwarm(t, s1, s2)
+
+	str, err := s1.NewStream(context.Background(), s2.LocalPeer())
+	require.NoError(t, err)
+	defer str.Close()
+	stats, ok := str.Conn().(network.ConnMuxerStats)
+	require.True(t, ok)
+	require.Equal(t, 1, stats.MuxerStats().OpenStreams)
+}
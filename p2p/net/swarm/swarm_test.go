@@ -456,6 +456,35 @@ func TestStreamCount(t *testing.T) {
 	require.Equal(t, 8, countStreams())
 }
 
+func TestStreamStat(t *testing.T) {
+	swarms := makeSwarms(t, 2, OptDisableQUIC, OptDisableWebTransport, OptDisableWebRTC)
+	s1, s2 := swarms[0], swarms[1]
+	connectSwarms(t, context.Background(), swarms)
+
+	str, err := s1.NewStream(context.Background(), s2.LocalPeer())
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := str.Write([]byte("ping"))
+		require.NoError(t, err)
+		_, err = io.ReadFull(str, make([]byte, 4))
+		require.NoError(t, err)
+	}
+	stat := str.Stat()
+	require.Equal(t, uint64(12), stat.BytesWritten)
+	require.Equal(t, uint64(12), stat.BytesRead)
+	require.Positive(t, stat.Duration)
+
+	connStat := str.Conn().Stat()
+	require.GreaterOrEqual(t, connStat.BytesWritten, uint64(12))
+	require.GreaterOrEqual(t, connStat.BytesRead, uint64(12))
+
+	// The duration stops increasing once the stream is closed.
+	require.NoError(t, str.Close())
+	d := str.Stat().Duration
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, d, str.Stat().Duration)
+}
+
 func TestResourceManager(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
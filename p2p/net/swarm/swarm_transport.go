@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
 	"github.com/libp2p/go-libp2p/core/transport"
 
 	ma "github.com/multiformats/go-multiaddr"
@@ -63,7 +66,13 @@ func (s *Swarm) TransportForListening(a ma.Multiaddr) transport.Transport {
 		return nil
 	}
 
-	selected := s.transports.m[protocols[len(protocols)-1].Code]
+	last := protocols[len(protocols)-1].Code
+	// An /http-path is part of the address of the transport before it, e.g. a
+	// WebSocket listening on a URL path.
+	if last == ma.P_HTTP_PATH && len(protocols) > 1 {
+		last = protocols[len(protocols)-2].Code
+	}
+	selected := s.transports.m[last]
 	for _, p := range protocols {
 		transport, ok := s.transports.m[p.Code]
 		if !ok {
@@ -112,5 +121,43 @@ func (s *Swarm) AddTransport(t transport.Transport) error {
 	for _, p := range protocols {
 		s.transports.m[p] = t
 	}
+	if n, ok := t.(transport.PathChangeNotifier); ok {
+		n.NotifyPathChange(s.handlePathChange)
+	}
 	return nil
 }
+
+// handlePathChange emits an EvtPeerConnectionPathChanged event for the
+// connection wrapping tc. The new address of a dialed connection is added to
+// the peerstore, as the peer is reachable at it.
+func (s *Swarm) handlePathChange(tc transport.CapableConn, oldRemote ma.Multiaddr) {
+	p := tc.RemotePeer()
+	var c *Conn
+	s.conns.RLock()
+	for _, sc := range s.conns.m[p] {
+		if sc.conn == tc {
+			c = sc
+			break
+		}
+		if mc, ok := sc.conn.(*connWithMetrics); ok && mc.CapableConn == tc {
+			c = sc
+			break
+		}
+	}
+	s.conns.RUnlock()
+	if c == nil {
+		return
+	}
+	newRemote := c.RemoteMultiaddr()
+	log.Debugw("connection path changed", "peer", p, "old", oldRemote, "new", newRemote)
+	if c.Stat().Direction == network.DirOutbound {
+		s.peers.AddAddr(p, newRemote, peerstore.ConnectedAddrTTL)
+	}
+	s.pathEmitter.Emit(event.EvtPeerConnectionPathChanged{
+		Peer:          p,
+		Conn:          c,
+		OldRemoteAddr: oldRemote,
+		NewRemoteAddr: newRemote,
+		LocalAddr:     c.LocalMultiaddr(),
+	})
+}
@@ -0,0 +1,110 @@
+package swarm
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// maxDialFailures is the maximum number of peers whose last dial failure we
+// keep for UnreachabilityReport.
+const maxDialFailures = 1024
+
+type dialFailure struct {
+	at  time.Time
+	err error
+}
+
+// dialFailures tracks the last failed dial of peers, until a connection to
+// the peer is established. It's safe to use its zero value.
+type dialFailures struct {
+	mx sync.Mutex
+	m  map[peer.ID]dialFailure
+}
+
+func (d *dialFailures) record(p peer.ID, err error) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	if d.m == nil {
+		d.m = make(map[peer.ID]dialFailure)
+	}
+	if _, ok := d.m[p]; !ok && len(d.m) >= maxDialFailures {
+		// evict the oldest failure
+		var oldest peer.ID
+		var oldestAt time.Time
+		for q, f := range d.m {
+			if oldestAt.IsZero() || f.at.Before(oldestAt) {
+				oldest, oldestAt = q, f.at
+			}
+		}
+		delete(d.m, oldest)
+	}
+	d.m[p] = dialFailure{at: time.Now(), err: err}
+}
+
+func (d *dialFailures) clear(p peer.ID) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	delete(d.m, p)
+}
+
+func (d *dialFailures) get(p peer.ID) (dialFailure, bool) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	f, ok := d.m[p]
+	return f, ok
+}
+
+func isGaterDenial(err error) bool {
+	var gerr *connmgr.GatedError
+	return errors.Is(err, ErrGaterDisallowedConnection) || errors.As(err, &gerr)
+}
+
+var _ network.UnreachabilityReporter = (*Swarm)(nil)
+
+// UnreachabilityReport returns the dial backoffs of the addresses of p, and
+// the errors of the last failed dial of p since the swarm was last connected
+// to it.
+func (s *Swarm) UnreachabilityReport(p peer.ID) network.UnreachabilityReport {
+	r := network.UnreachabilityReport{Peer: p, Connectedness: s.Connectedness(p)}
+	idx := make(map[string]int)
+	addr := func(a ma.Multiaddr) *network.AddrReachability {
+		k := string(a.Bytes())
+		i, ok := idx[k]
+		if !ok {
+			i = len(r.Addrs)
+			idx[k] = i
+			r.Addrs = append(r.Addrs, network.AddrReachability{Addr: a})
+		}
+		return &r.Addrs[i]
+	}
+
+	for _, a := range s.peers.Addrs(p) {
+		addr(a)
+	}
+	if f, ok := s.dialFailures.get(p); ok {
+		r.LastDialFailure = f.at
+		r.DialError = f.err
+		var derr *DialError
+		if errors.As(f.err, &derr) {
+			r.DialError = derr.Cause
+			for _, te := range derr.DialErrors {
+				ar := addr(te.Address)
+				ar.Error = te.Cause
+				ar.GaterDenied = isGaterDenial(te.Cause)
+				ar.ResourceLimited = errors.Is(te.Cause, network.ErrResourceLimitExceeded)
+			}
+		}
+		r.GaterDenied = isGaterDenial(r.DialError)
+	}
+	for _, b := range s.backf.backoffs(p) {
+		addr(b.addr).BackoffUntil = b.until
+	}
+	return r
+}
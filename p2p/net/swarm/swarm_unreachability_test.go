@@ -0,0 +1,80 @@
+package swarm_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	. "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"github.com/stretchr/testify/require"
+)
+
+// closedTCPAddr returns a local TCP address nothing listens on.
+func closedTCPAddr(t *testing.T) ma.Multiaddr {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr, err := manet.FromNetAddr(l.Addr())
+	require.NoError(t, err)
+	l.Close()
+	return addr
+}
+
+func TestUnreachabilityReport(t *testing.T) {
+	gater := DefaultMockConnectionGater()
+	sw1 := GenSwarm(t, OptDisableQUIC, OptConnGater(gater))
+	sw2 := GenSwarm(t, OptDisableQUIC)
+	p2 := sw2.LocalPeer()
+
+	r := sw1.UnreachabilityReport(p2)
+	require.Equal(t, network.NotConnected, r.Connectedness)
+	require.False(t, r.Unreachable())
+
+	// the peer is denied by the gater
+	gater.PeerDial = func(peer.ID) bool { return false }
+	_, err := sw1.DialPeer(context.Background(), p2)
+	require.Error(t, err)
+	r = sw1.UnreachabilityReport(p2)
+	require.True(t, r.Unreachable())
+	require.True(t, r.GaterDenied)
+	require.False(t, r.LastDialFailure.IsZero())
+
+	// one address is denied by the gater, the other one is closed
+	gater.PeerDial = func(peer.ID) bool { return true }
+	closed := closedTCPAddr(t)
+	denied := ma.StringCast("/ip4/127.0.0.2/tcp/1234")
+	gater.Dial = func(_ peer.ID, a ma.Multiaddr) bool { return !a.Equal(denied) }
+	sw1.Peerstore().AddAddrs(p2, []ma.Multiaddr{closed, denied}, peerstore.PermanentAddrTTL)
+	_, err = sw1.DialPeer(context.Background(), p2)
+	require.Error(t, err)
+	r = sw1.UnreachabilityReport(p2)
+	require.False(t, r.GaterDenied)
+	require.Len(t, r.Addrs, 2)
+	for _, a := range r.Addrs {
+		require.Error(t, a.Error)
+		if a.Addr.Equal(denied) {
+			require.True(t, a.GaterDenied)
+			require.True(t, a.BackoffUntil.IsZero())
+		} else {
+			require.False(t, a.GaterDenied)
+			require.False(t, a.BackoffUntil.IsZero())
+		}
+		require.False(t, a.ResourceLimited)
+	}
+	require.Contains(t, r.String(), "[denied by gater]")
+
+	// connecting clears the dial errors
+	sw1.Peerstore().AddAddrs(p2, sw2.ListenAddresses(), peerstore.PermanentAddrTTL)
+	sw1.Backoff().Clear(p2)
+	_, err = sw1.DialPeer(context.Background(), p2)
+	require.NoError(t, err)
+	r = sw1.UnreachabilityReport(p2)
+	require.Equal(t, network.Connected, r.Connectedness)
+	require.True(t, r.LastDialFailure.IsZero())
+	require.False(t, r.Unreachable())
+}
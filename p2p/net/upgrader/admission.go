@@ -0,0 +1,63 @@
+package upgrader
+
+import (
+	"maps"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// ConnInfo describes a raw connection that is about to be upgraded.
+type ConnInfo struct {
+	Direction network.Direction
+	// LocalAddr and RemoteAddr are the addresses of the raw connection.
+	LocalAddr  ma.Multiaddr
+	RemoteAddr ma.Multiaddr
+	// ListenAddr is the address of the listener that accepted the connection.
+	// It is nil for connections that weren't accepted by a listener, e.g.
+	// dialed connections.
+	ListenAddr ma.Multiaddr
+	// Peer is the peer expected on a dialed connection. It is empty for
+	// inbound connections, since the remote peer is only known after the
+	// security handshake.
+	Peer peer.ID
+}
+
+// AdmissionFunc decides whether a raw connection is upgraded. It is called
+// before any handshake bytes are exchanged. If it returns an error, the
+// connection is closed. Otherwise, the returned annotations are added to the
+// Extra field of the upgraded connection's Stat.
+type AdmissionFunc func(ConnInfo) (annotations map[any]any, err error)
+
+// WithConnAdmission sets a function that is called for every connection
+// before it is upgraded. Unlike the connection gater, it knows which listener
+// accepted a connection, so different policies can be applied to different
+// listeners, e.g. a public listener can be stricter than one on a VPN.
+func WithConnAdmission(f AdmissionFunc) Option {
+	return func(u *upgrader) error {
+		u.admission = f
+		return nil
+	}
+}
+
+// admit calls the admission function, if any, and adds the annotations it
+// returns to stat.
+func (u *upgrader) admit(info ConnInfo, stat *network.ConnStats) error {
+	if u.admission == nil {
+		return nil
+	}
+	annotations, err := u.admission(info)
+	if err != nil {
+		return err
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+	extra := make(map[any]any, len(stat.Extra)+len(annotations))
+	maps.Copy(extra, stat.Extra)
+	maps.Copy(extra, annotations)
+	stat.Extra = extra
+	return nil
+}
@@ -1,6 +1,7 @@
 package upgrader
 
 import (
+	"crypto/x509"
 	"fmt"
 
 	"github.com/libp2p/go-libp2p/core/network"
@@ -12,13 +13,15 @@ type transportConn struct {
 	network.MuxedConn
 	network.ConnMultiaddrs
 	network.ConnSecurity
-	transport transport.Transport
-	scope     network.ConnManagementScope
-	stat      network.ConnStats
+	transport     transport.Transport
+	transportName string
+	scope         network.ConnManagementScope
+	stat          network.ConnStats
 
 	muxer                     protocol.ID
 	security                  protocol.ID
 	usedEarlyMuxerNegotiation bool
+	tlsClientCerts            []*x509.Certificate
 }
 
 var _ transport.CapableConn = &transportConn{}
@@ -59,11 +62,19 @@ func (t *transportConn) ConnState() network.ConnectionState {
 	return network.ConnectionState{
 		StreamMultiplexer:         t.muxer,
 		Security:                  t.security,
-		Transport:                 "tcp",
+		Transport:                 t.transportName,
 		UsedEarlyMuxerNegotiation: t.usedEarlyMuxerNegotiation,
+		TLSClientCertificates:     t.tlsClientCerts,
 	}
 }
 
+func (t *transportConn) MuxerStats() network.MuxerStats {
+	if s, ok := t.MuxedConn.(network.ConnMuxerStats); ok {
+		return s.MuxerStats()
+	}
+	return network.MuxerStats{}
+}
+
 func (t *transportConn) CloseWithError(errCode network.ConnErrorCode) error {
 	defer t.scope.Done()
 	return t.MuxedConn.CloseWithError(errCode)
@@ -1,6 +1,7 @@
 package upgrader_test
 
 import (
+	"context"
 	"sync"
 
 	"github.com/libp2p/go-libp2p/core/connmgr"
@@ -58,3 +59,34 @@ func (t *testGater) InterceptSecured(_ network.Direction, _ peer.ID, _ network.C
 func (t *testGater) InterceptUpgraded(_ network.Conn) (allow bool, reason control.DisconnectReason) {
 	panic("not implemented")
 }
+
+// securedConnGater refuses all secured connections, recording their details.
+type securedConnGater struct {
+	testGater
+	infos chan connmgr.SecuredConnInfo
+}
+
+var _ connmgr.SecuredConnGater = (*securedConnGater)(nil)
+
+func (g *securedConnGater) InterceptSecuredConn(_ network.Direction, _ peer.ID, info connmgr.SecuredConnInfo) (allow bool) {
+	g.infos <- info
+	return false
+}
+
+// contextConnGater refuses secured connections with err, once ctx is done if
+// wait is set.
+type contextConnGater struct {
+	testGater
+	err  error
+	wait bool
+}
+
+var _ connmgr.ContextSecuredConnGater = (*contextConnGater)(nil)
+
+func (g *contextConnGater) InterceptSecuredCtx(ctx context.Context, _ network.Direction, _ peer.ID, _ connmgr.SecuredConnInfo) error {
+	if g.wait {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return g.err
+}
@@ -109,7 +109,7 @@ func (l *listener) handleIncoming() {
 			ctx, cancel := context.WithTimeout(l.ctx, l.upgrader.acceptTimeout)
 			defer cancel()
 
-			conn, err := l.upgrader.Upgrade(ctx, l.transport, maconn, network.DirInbound, "", connScope)
+			conn, err := l.upgrader.upgradeWithListenAddr(ctx, l.transport, maconn, network.DirInbound, "", connScope, l.Multiaddr())
 			if err != nil {
 				// Don't bother bubbling this up. We just failed
 				// to completely negotiate the connection.
@@ -0,0 +1,117 @@
+package upgrader
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	ipnet "github.com/libp2p/go-libp2p/core/pnet"
+	"github.com/libp2p/go-libp2p/p2p/net/pnet"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// PSKExemption describes the connections that are exempted from private
+// network (PSK) protection, e.g. connections to a local admin API or sidecar.
+// Both sides of a connection must exempt it, since an exempted connection
+// can't talk to a protected one.
+type PSKExemption struct {
+	// Loopback exempts connections whose remote address is a loopback address.
+	Loopback bool
+	// Addrs exempts inbound connections accepted by a listener on one of
+	// these addresses, and outbound connections dialed to one of them.
+	Addrs []ma.Multiaddr
+}
+
+func (e *PSKExemption) exempts(info ConnInfo) bool {
+	if e.Loopback && manet.IsIPLoopback(info.RemoteAddr) {
+		return true
+	}
+	for _, a := range e.Addrs {
+		switch info.Direction {
+		case network.DirInbound:
+			if (info.ListenAddr != nil && a.Equal(info.ListenAddr)) || a.Equal(info.LocalAddr) {
+				return true
+			}
+		case network.DirOutbound:
+			if a.Equal(info.RemoteAddr) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WithPSKExemption exempts the connections described by e from private network
+// protection. Protection is still enforced on all other connections.
+// Exemptions are logged, and emitted as event.EvtPrivateNetworkExemption if an
+// event bus is set with WithEventBus.
+func WithPSKExemption(e PSKExemption) Option {
+	return func(u *upgrader) error {
+		u.pskExemption = &e
+		return nil
+	}
+}
+
+// WithPSKProvider protects connections with a private network key provider
+// instead of a raw PSK, e.g. to keep the PSK in an HSM. The psk passed to New
+// must be nil.
+func WithPSKProvider(kp ipnet.KeyProvider) Option {
+	return func(u *upgrader) error {
+		if kp == nil {
+			return errors.New("nil PSK key provider")
+		}
+		u.pskProvider = kp
+		return nil
+	}
+}
+
+// WithPSKModes makes private network protection negotiate one of the
+// authenticated modes instead of using the legacy XSalsa20 protector, see
+// pnet.WithModes.
+func WithPSKModes(modes ...pnet.Mode) Option {
+	return func(u *upgrader) error {
+		if len(modes) == 0 {
+			return errors.New("no private network modes")
+		}
+		for _, m := range modes {
+			if !m.Negotiated() {
+				return fmt.Errorf("private network mode %s can't be negotiated", m)
+			}
+		}
+		u.pskOpts = append(u.pskOpts, pnet.WithModes(modes...))
+		return nil
+	}
+}
+
+// WithEventBus sets the event bus that the upgrader emits events on. The
+// upgrader implements io.Closer, and closing it closes its emitters.
+func WithEventBus(b event.Bus) Option {
+	return func(u *upgrader) error {
+		em, err := b.Emitter(new(event.EvtPrivateNetworkExemption))
+		if err != nil {
+			return err
+		}
+		u.emitters.evtPrivateNetworkExemption = em
+		return nil
+	}
+}
+
+// pskExempted returns whether the connection is exempted from private network
+// protection.
+func (u *upgrader) pskExempted(info ConnInfo) bool {
+	if u.pskExemption == nil || !u.pskExemption.exempts(info) {
+		return false
+	}
+	log.Infow("connection exempted from private network protection", "direction", info.Direction, "local", info.LocalAddr, "remote", info.RemoteAddr)
+	if u.emitters.evtPrivateNetworkExemption != nil {
+		u.emitters.evtPrivateNetworkExemption.Emit(event.EvtPrivateNetworkExemption{
+			Direction:  info.Direction,
+			LocalAddr:  info.LocalAddr,
+			RemoteAddr: info.RemoteAddr,
+		})
+	}
+	return true
+}
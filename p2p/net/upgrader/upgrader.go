@@ -2,12 +2,14 @@ package upgrader
 
 import (
 	"context"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"net"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	ipnet "github.com/libp2p/go-libp2p/core/pnet"
@@ -16,6 +18,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/transport"
 	"github.com/libp2p/go-libp2p/p2p/net/pnet"
 
+	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
 	mss "github.com/multiformats/go-multistream"
 )
@@ -49,7 +52,9 @@ type StreamMuxer struct {
 // Upgrader is a multistream upgrader that can upgrade an underlying connection
 // to a full transport connection (secure and multiplexed).
 type upgrader struct {
-	psk       ipnet.PSK
+	// pskProvider protects connections if a PSK or a key provider is set
+	pskProvider ipnet.KeyProvider
+
 	connGater connmgr.ConnectionGater
 	rcmgr     network.ResourceManager
 
@@ -67,16 +72,35 @@ type upgrader struct {
 	//
 	// If unset, the default value (15s) is used.
 	acceptTimeout time.Duration
+
+	// admission, if set, is called before upgrading a connection.
+	admission AdmissionFunc
+
+	// see WithPSKExemption
+	pskExemption *PSKExemption
+	// see WithPSKModes
+	pskOpts []pnet.Option
+
+	emitters struct {
+		evtPrivateNetworkExemption event.Emitter
+	}
 }
 
 var _ transport.Upgrader = &upgrader{}
 
+// Close closes the event emitters of the upgrader.
+func (u *upgrader) Close() error {
+	if u.emitters.evtPrivateNetworkExemption != nil {
+		return u.emitters.evtPrivateNetworkExemption.Close()
+	}
+	return nil
+}
+
 func New(security []sec.SecureTransport, muxers []StreamMuxer, psk ipnet.PSK, rcmgr network.ResourceManager, connGater connmgr.ConnectionGater, opts ...Option) (transport.Upgrader, error) {
 	u := &upgrader{
 		acceptTimeout: defaultAcceptTimeout,
 		rcmgr:         rcmgr,
 		connGater:     connGater,
-		psk:           psk,
 		muxerMuxer:    mss.NewMultistreamMuxer[protocol.ID](),
 		muxers:        muxers,
 		security:      security,
@@ -87,6 +111,16 @@ func New(security []sec.SecureTransport, muxers []StreamMuxer, psk ipnet.PSK, rc
 			return nil, err
 		}
 	}
+	if psk != nil {
+		if u.pskProvider != nil {
+			return nil, errors.New("cannot use both a PSK and a PSK key provider")
+		}
+		kp, err := pnet.NewKeyProvider(psk)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup private network protector: %w", err)
+		}
+		u.pskProvider = kp
+	}
 	if u.rcmgr == nil {
 		u.rcmgr = &network.NullResourceManager{}
 	}
@@ -135,7 +169,13 @@ func (u *upgrader) UpgradeGatedMaListener(t transport.Transport, l transport.Gat
 
 // Upgrade upgrades the multiaddr/net connection into a full libp2p-transport connection.
 func (u *upgrader) Upgrade(ctx context.Context, t transport.Transport, maconn manet.Conn, dir network.Direction, p peer.ID, connScope network.ConnManagementScope) (transport.CapableConn, error) {
-	c, err := u.upgrade(ctx, t, maconn, dir, p, connScope)
+	return u.upgradeWithListenAddr(ctx, t, maconn, dir, p, connScope, nil)
+}
+
+// upgradeWithListenAddr upgrades a connection. listenAddr is the address of
+// the listener that accepted the connection, if any.
+func (u *upgrader) upgradeWithListenAddr(ctx context.Context, t transport.Transport, maconn manet.Conn, dir network.Direction, p peer.ID, connScope network.ConnManagementScope, listenAddr ma.Multiaddr) (transport.CapableConn, error) {
+	c, err := u.upgrade(ctx, t, maconn, dir, p, connScope, listenAddr)
 	if err != nil {
 		connScope.Done()
 		return nil, err
@@ -143,7 +183,7 @@ func (u *upgrader) Upgrade(ctx context.Context, t transport.Transport, maconn ma
 	return c, nil
 }
 
-func (u *upgrader) upgrade(ctx context.Context, t transport.Transport, maconn manet.Conn, dir network.Direction, p peer.ID, connScope network.ConnManagementScope) (transport.CapableConn, error) {
+func (u *upgrader) upgrade(ctx context.Context, t transport.Transport, maconn manet.Conn, dir network.Direction, p peer.ID, connScope network.ConnManagementScope, listenAddr ma.Multiaddr) (transport.CapableConn, error) {
 	if dir == network.DirOutbound && p == "" {
 		return nil, ErrNilPeer
 	}
@@ -152,15 +192,28 @@ func (u *upgrader) upgrade(ctx context.Context, t transport.Transport, maconn ma
 		stat = cs.Stat()
 	}
 
+	info := ConnInfo{
+		Direction:  dir,
+		LocalAddr:  maconn.LocalMultiaddr(),
+		RemoteAddr: maconn.RemoteMultiaddr(),
+		ListenAddr: listenAddr,
+		Peer:       p,
+	}
+	if err := u.admit(info, &stat); err != nil {
+		maconn.Close()
+		return nil, fmt.Errorf("connection with addr %s rejected by admission function: %w", maconn.RemoteMultiaddr(), err)
+	}
+
 	var conn net.Conn = maconn
-	if u.psk != nil {
-		pconn, err := pnet.NewProtectedConn(u.psk, conn)
+	pskExempted := (u.pskProvider != nil || ipnet.ForcePrivateNetwork) && u.pskExempted(info)
+	if u.pskProvider != nil && !pskExempted {
+		pconn, err := pnet.NewProtectedConnFromProvider(u.pskProvider, conn, u.pskOpts...)
 		if err != nil {
 			conn.Close()
 			return nil, fmt.Errorf("failed to setup private network protector: %w", err)
 		}
 		conn = pconn
-	} else if ipnet.ForcePrivateNetwork {
+	} else if ipnet.ForcePrivateNetwork && !pskExempted {
 		log.Error("tried to dial with no Private Network Protector but usage of Private Networks is forced by the environment")
 		return nil, ipnet.ErrNotInPrivateNetwork
 	}
@@ -172,13 +225,26 @@ func (u *upgrader) upgrade(ctx context.Context, t transport.Transport, maconn ma
 		return nil, fmt.Errorf("failed to negotiate security protocol: %w", err)
 	}
 
+	var tlsClientCerts []*x509.Certificate
+	if c, ok := maconn.(network.ConnTLSClientCerts); ok {
+		tlsClientCerts = c.TLSClientCertificates()
+	}
+
+	tptName := transportName(t, maconn.RemoteMultiaddr())
 	// call the connection gater, if one is registered.
-	if u.connGater != nil && !u.connGater.InterceptSecured(dir, sconn.RemotePeer(), maconn) {
-		if err := maconn.Close(); err != nil {
-			log.Errorw("failed to close connection", "peer", p, "addr", maconn.RemoteMultiaddr(), "error", err)
+	if u.connGater != nil {
+		if err := connmgr.InterceptSecuredContext(ctx, u.connGater, dir, sconn.RemotePeer(), connmgr.SecuredConnInfo{
+			ConnMultiaddrs:        maconn,
+			Transport:             tptName,
+			Security:              security,
+			Relayed:               isRelayed(maconn.RemoteMultiaddr()),
+			TLSClientCertificates: tlsClientCerts,
+		}); err != nil {
+			if err := maconn.Close(); err != nil {
+				log.Errorw("failed to close connection", "peer", p, "addr", maconn.RemoteMultiaddr(), "error", err)
+			}
+			return nil, err
 		}
-		return nil, fmt.Errorf("gater rejected connection with peer %s and addr %s with direction %d",
-			sconn.RemotePeer(), maconn.RemoteMultiaddr(), dir)
 	}
 	// Only call SetPeer if it hasn't already been set -- this can happen when we don't know
 	// the peer in advance and in some bug scenarios.
@@ -204,15 +270,43 @@ func (u *upgrader) upgrade(ctx context.Context, t transport.Transport, maconn ma
 		ConnMultiaddrs:            maconn,
 		ConnSecurity:              sconn,
 		transport:                 t,
+		transportName:             tptName,
 		stat:                      stat,
 		scope:                     connScope,
 		muxer:                     muxer,
 		security:                  security,
 		usedEarlyMuxerNegotiation: sconn.ConnState().UsedEarlyMuxerNegotiation,
+		tlsClientCerts:            tlsClientCerts,
 	}
 	return tc, nil
 }
 
+func isRelayed(addr ma.Multiaddr) bool {
+	_, err := addr.ValueForProtocol(ma.P_CIRCUIT)
+	return err == nil
+}
+
+// transportName returns the name of the transport t of a connection with the
+// remote address addr. If t doesn't name itself, the name is derived from
+// addr.
+func transportName(t transport.Transport, addr ma.Multiaddr) string {
+	if nt, ok := t.(transport.NamedTransport); ok {
+		return nt.TransportName()
+	}
+	if isRelayed(addr) {
+		return "p2p-circuit"
+	}
+	for _, c := range addr {
+		switch c.Protocol().Code {
+		case ma.P_WS, ma.P_WSS:
+			return "websocket"
+		case ma.P_UNIX:
+			return "unix"
+		}
+	}
+	return "tcp"
+}
+
 func (u *upgrader) setupSecurity(ctx context.Context, conn net.Conn, p peer.ID, isServer bool) (sec.SecureConn, protocol.ID, error) {
 	st, err := u.negotiateSecurity(ctx, conn, isServer)
 	if err != nil {
@@ -4,18 +4,25 @@ import (
 	"context"
 	"crypto/rand"
 	"errors"
+	"io"
 	"net"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/connmgr"
 	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/network"
 	mocknetwork "github.com/libp2p/go-libp2p/core/network/mocks"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/libp2p/go-libp2p/core/sec"
 	"github.com/libp2p/go-libp2p/core/sec/insecure"
 	"github.com/libp2p/go-libp2p/core/transport"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
 	"github.com/libp2p/go-libp2p/p2p/muxer/yamux"
+	"github.com/libp2p/go-libp2p/p2p/net/pnet"
 	"github.com/libp2p/go-libp2p/p2p/net/upgrader"
 
 	ma "github.com/multiformats/go-multiaddr"
@@ -161,6 +168,95 @@ func TestOutboundConnectionGating(t *testing.T) {
 	require.Nil(conn)
 }
 
+func TestSecuredConnGating(t *testing.T) {
+	id, u := createUpgrader(t)
+	ln := createListener(t, u)
+	defer ln.Close()
+
+	gater := &securedConnGater{infos: make(chan connmgr.SecuredConnInfo, 1)}
+	_, dialUpgrader := createUpgraderWithConnGater(t, gater)
+	conn, err := dial(t, dialUpgrader, ln.Multiaddr(), id, &network.NullScope{})
+	require.Nil(t, conn)
+	var gerr *connmgr.GatedError
+	require.ErrorAs(t, err, &gerr)
+	require.Equal(t, id, gerr.Peer)
+	require.Equal(t, network.DirOutbound, gerr.Direction)
+	require.True(t, ln.Multiaddr().Equal(gerr.Addr))
+
+	info := <-gater.infos
+	require.Equal(t, "tcp", info.Transport)
+	require.Equal(t, protocol.ID(insecure.ID), info.Security)
+	require.False(t, info.Relayed)
+	require.True(t, ln.Multiaddr().Equal(info.RemoteMultiaddr()))
+}
+
+// namedTransport is a transport naming itself. Only TransportName is used by
+// the upgrader.
+type namedTransport struct {
+	transport.Transport
+	name string
+}
+
+func (t namedTransport) TransportName() string { return t.name }
+
+func TestTransportName(t *testing.T) {
+	id, u := createUpgrader(t)
+	ln := createListener(t, u)
+	defer ln.Close()
+
+	gater := &securedConnGater{infos: make(chan connmgr.SecuredConnInfo, 1)}
+	_, dialUpgrader := createUpgraderWithConnGater(t, gater)
+	macon, err := manet.Dial(ln.Multiaddr())
+	require.NoError(t, err)
+	_, err = dialUpgrader.Upgrade(context.Background(), namedTransport{name: "foo"}, macon, network.DirOutbound, id, &network.NullScope{})
+	require.Error(t, err)
+	require.Equal(t, "foo", (<-gater.infos).Transport)
+
+	_, dialUpgrader = createUpgrader(t)
+	macon, err = manet.Dial(ln.Multiaddr())
+	require.NoError(t, err)
+	conn, err := dialUpgrader.Upgrade(context.Background(), namedTransport{name: "foo"}, macon, network.DirOutbound, id, &network.NullScope{})
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, "foo", conn.ConnState().Transport)
+}
+
+func TestContextSecuredConnGating(t *testing.T) {
+	id, u := createUpgrader(t)
+	ln := createListener(t, u)
+	defer ln.Close()
+
+	t.Run("allowed", func(t *testing.T) {
+		_, dialUpgrader := createUpgraderWithConnGater(t, &contextConnGater{})
+		conn, err := dial(t, dialUpgrader, ln.Multiaddr(), id, &network.NullScope{})
+		require.NoError(t, err)
+		require.NoError(t, conn.Close())
+	})
+
+	t.Run("refused", func(t *testing.T) {
+		errDenied := errors.New("denied by policy")
+		_, dialUpgrader := createUpgraderWithConnGater(t, &contextConnGater{err: errDenied})
+		conn, err := dial(t, dialUpgrader, ln.Multiaddr(), id, &network.NullScope{})
+		require.Nil(t, conn)
+		var gerr *connmgr.GatedError
+		require.ErrorAs(t, err, &gerr)
+		require.Equal(t, id, gerr.Peer)
+		require.ErrorIs(t, err, errDenied)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		_, dialUpgrader := createUpgraderWithConnGater(t, &contextConnGater{wait: true})
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		macon, err := manet.Dial(ln.Multiaddr())
+		require.NoError(t, err)
+		_, err = dialUpgrader.Upgrade(ctx, nil, macon, network.DirOutbound, id, &network.NullScope{})
+		var gerr *connmgr.GatedError
+		require.ErrorAs(t, err, &gerr)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
 func TestOutboundResourceManagement(t *testing.T) {
 	t.Run("successful handshake", func(t *testing.T) {
 		id, upgrader := createUpgrader(t)
@@ -202,3 +298,183 @@ func TestOutboundResourceManagement(t *testing.T) {
 		require.Error(t, err)
 	})
 }
+
+func TestConnAdmission(t *testing.T) {
+	type annotationKey struct{}
+	var mx sync.Mutex
+	var infos []upgrader.ConnInfo
+	var reject bool
+	admission := func(info upgrader.ConnInfo) (map[any]any, error) {
+		mx.Lock()
+		defer mx.Unlock()
+		infos = append(infos, info)
+		if reject && info.Direction == network.DirInbound {
+			return nil, errors.New("rejected")
+		}
+		return map[any]any{annotationKey{}: info.Direction}, nil
+	}
+
+	id, u := createUpgraderWithOpts(t, upgrader.WithConnAdmission(admission))
+	ln := createListener(t, u)
+	defer ln.Close()
+
+	cconn, err := dial(t, u, ln.Multiaddr(), id, &network.NullScope{})
+	require.NoError(t, err)
+	defer cconn.Close()
+	sconn, err := ln.Accept()
+	require.NoError(t, err)
+	defer sconn.Close()
+	testConn(t, cconn, sconn)
+	require.Equal(t, network.DirOutbound, cconn.(network.ConnStat).Stat().Extra[annotationKey{}])
+	require.Equal(t, network.DirInbound, sconn.(network.ConnStat).Stat().Extra[annotationKey{}])
+
+	mx.Lock()
+	require.Len(t, infos, 2)
+	for _, info := range infos {
+		switch info.Direction {
+		case network.DirOutbound:
+			require.Equal(t, id, info.Peer)
+			require.Nil(t, info.ListenAddr)
+			require.True(t, ln.Multiaddr().Equal(info.RemoteAddr))
+		case network.DirInbound:
+			require.Empty(t, info.Peer)
+			require.True(t, ln.Multiaddr().Equal(info.ListenAddr))
+			require.True(t, ln.Multiaddr().Equal(info.LocalAddr))
+		}
+	}
+	reject = true
+	mx.Unlock()
+
+	// The dial fails since the listener closes the connection before the
+	// security handshake.
+	_, err = dial(t, u, ln.Multiaddr(), id, &network.NullScope{})
+	require.Error(t, err)
+}
+
+func TestPSKExemption(t *testing.T) {
+	psk := make([]byte, 32)
+	_, err := rand.Read(psk)
+	require.NoError(t, err)
+
+	newUpgrader := func(opts ...upgrader.Option) (peer.ID, transport.Upgrader) {
+		id, priv := newPeer(t)
+		u, err := upgrader.New([]sec.SecureTransport{insecure.NewWithIdentity(insecure.ID, id, priv)}, []upgrader.StreamMuxer{{ID: "negotiate", Muxer: &negotiatingMuxer{}}}, psk, nil, nil, opts...)
+		require.NoError(t, err)
+		return id, u
+	}
+
+	bus := eventbus.NewBus()
+	sub, err := bus.Subscribe(new(event.EvtPrivateNetworkExemption))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	id, u := newUpgrader(upgrader.WithPSKExemption(upgrader.PSKExemption{Loopback: true}), upgrader.WithEventBus(bus))
+	ln := createListener(t, u)
+	defer ln.Close()
+
+	cconn, err := dial(t, u, ln.Multiaddr(), id, &network.NullScope{})
+	require.NoError(t, err)
+	defer cconn.Close()
+	sconn, err := ln.Accept()
+	require.NoError(t, err)
+	defer sconn.Close()
+	testConn(t, cconn, sconn)
+
+	dirs := make(map[network.Direction]bool)
+	for range 2 {
+		select {
+		case e := <-sub.Out():
+			evt := e.(event.EvtPrivateNetworkExemption)
+			require.True(t, manet.IsIPLoopback(evt.RemoteAddr))
+			dirs[evt.Direction] = true
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for exemption event")
+		}
+	}
+	require.Equal(t, map[network.Direction]bool{network.DirInbound: true, network.DirOutbound: true}, dirs)
+
+	// A peer that doesn't exempt the connection uses the PSK, and can't
+	// connect to the exempted listener.
+	_, protected := newUpgrader()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	macon, err := manet.Dial(ln.Multiaddr())
+	require.NoError(t, err)
+	_, err = protected.Upgrade(ctx, nil, macon, network.DirOutbound, id, &network.NullScope{})
+	require.Error(t, err)
+}
+
+func TestCloseEmitters(t *testing.T) {
+	bus := eventbus.NewBus()
+	id, priv := newPeer(t)
+	u, err := upgrader.New([]sec.SecureTransport{insecure.NewWithIdentity(insecure.ID, id, priv)}, []upgrader.StreamMuxer{{ID: "negotiate", Muxer: &negotiatingMuxer{}}}, nil, nil, nil, upgrader.WithEventBus(bus))
+	require.NoError(t, err)
+	require.Len(t, bus.GetAllEventTypes(), 1)
+	require.NoError(t, u.(io.Closer).Close())
+	require.Empty(t, bus.GetAllEventTypes())
+}
+
+func TestPSKModes(t *testing.T) {
+	psk := make([]byte, 32)
+	_, err := rand.Read(psk)
+	require.NoError(t, err)
+
+	newUpgrader := func(opts ...upgrader.Option) (peer.ID, transport.Upgrader) {
+		id, priv := newPeer(t)
+		u, err := upgrader.New([]sec.SecureTransport{insecure.NewWithIdentity(insecure.ID, id, priv)}, []upgrader.StreamMuxer{{ID: "negotiate", Muxer: &negotiatingMuxer{}}}, psk, nil, nil, opts...)
+		require.NoError(t, err)
+		return id, u
+	}
+
+	id, u := newUpgrader(upgrader.WithPSKModes(pnet.ModeAESGCM, pnet.ModeXChaCha20Poly1305))
+	ln := createListener(t, u)
+	defer ln.Close()
+
+	_, cu := newUpgrader(upgrader.WithPSKModes(pnet.ModeXChaCha20Poly1305))
+	cconn, err := dial(t, cu, ln.Multiaddr(), id, &network.NullScope{})
+	require.NoError(t, err)
+	defer cconn.Close()
+	sconn, err := ln.Accept()
+	require.NoError(t, err)
+	defer sconn.Close()
+	testConn(t, cconn, sconn)
+
+	// a peer using the legacy protector can't connect
+	_, legacy := newUpgrader()
+	_, err = dial(t, legacy, ln.Multiaddr(), id, &network.NullScope{})
+	require.Error(t, err)
+
+	_, err = upgrader.New(nil, nil, psk, nil, nil, upgrader.WithPSKModes(pnet.ModeXSalsa20))
+	require.Error(t, err)
+}
+
+func TestPSKProvider(t *testing.T) {
+	psk := make([]byte, 32)
+	_, err := rand.Read(psk)
+	require.NoError(t, err)
+	kp, err := pnet.NewKeyProvider(psk)
+	require.NoError(t, err)
+
+	newUpgrader := func(psk []byte, opts ...upgrader.Option) (peer.ID, transport.Upgrader) {
+		id, priv := newPeer(t)
+		u, err := upgrader.New([]sec.SecureTransport{insecure.NewWithIdentity(insecure.ID, id, priv)}, []upgrader.StreamMuxer{{ID: "negotiate", Muxer: &negotiatingMuxer{}}}, psk, nil, nil, opts...)
+		require.NoError(t, err)
+		return id, u
+	}
+
+	// a provider interoperates with the raw PSK
+	id, u := newUpgrader(psk)
+	ln := createListener(t, u)
+	defer ln.Close()
+	_, cu := newUpgrader(nil, upgrader.WithPSKProvider(kp))
+	cconn, err := dial(t, cu, ln.Multiaddr(), id, &network.NullScope{})
+	require.NoError(t, err)
+	defer cconn.Close()
+	sconn, err := ln.Accept()
+	require.NoError(t, err)
+	defer sconn.Close()
+	testConn(t, cconn, sconn)
+
+	_, err = upgrader.New(nil, nil, psk, nil, nil, upgrader.WithPSKProvider(kp))
+	require.Error(t, err)
+}
@@ -292,3 +292,21 @@ func (p *peersMap) Delete(id peer.ID) {
 	p.peers = p.peers[:n-1]
 	delete(p.peerIdx, id)
 }
+
+// relayPeer returns the ID of the relay of a /p2p-circuit address.
+func relayPeer(a ma.Multiaddr) (peer.ID, bool) {
+	for i, c := range a {
+		if c.Code() != ma.P_CIRCUIT {
+			continue
+		}
+		if i == 0 || a[i-1].Code() != ma.P_P2P {
+			return "", false
+		}
+		id, err := peer.IDFromBytes(a[i-1].RawValue())
+		if err != nil {
+			return "", false
+		}
+		return id, true
+	}
+	return "", false
+}
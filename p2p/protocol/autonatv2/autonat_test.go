@@ -646,6 +646,60 @@ func TestAreAddrsConsistency(t *testing.T) {
 			dialAddr:  ma.StringCast("/ip4/1.2.3.4/udp/123/quic-v1/"),
 			success:   false,
 		},
+		{
+			name:      "websocket",
+			localAddr: ma.StringCast("/ip4/192.168.0.1/tcp/12345/ws"),
+			dialAddr:  ma.StringCast("/ip4/1.2.3.4/tcp/123/ws"),
+			success:   true,
+		},
+		{
+			name:      "websocket-vs-tcp",
+			localAddr: ma.StringCast("/ip4/192.168.0.1/tcp/12345"),
+			dialAddr:  ma.StringCast("/ip4/1.2.3.4/tcp/123/ws"),
+			success:   false,
+		},
+		{
+			name:      "secure-websocket",
+			localAddr: ma.StringCast("/ip4/192.168.0.1/tcp/12345/wss"),
+			dialAddr:  ma.StringCast("/dns4/lib.p2p/tcp/443/tls/sni/lib.p2p/ws"),
+			success:   true,
+		},
+		{
+			name:      "secure-websocket-vs-websocket",
+			localAddr: ma.StringCast("/ip4/192.168.0.1/tcp/12345/ws"),
+			dialAddr:  ma.StringCast("/ip4/1.2.3.4/tcp/443/tls/ws"),
+			success:   false,
+		},
+		{
+			name:      "websocket-http-path",
+			localAddr: ma.StringCast("/ip4/192.168.0.1/tcp/12345/ws"),
+			dialAddr:  ma.StringCast("/ip4/1.2.3.4/tcp/123/ws/http-path/libp2p"),
+			success:   true,
+		},
+		{
+			name:      "webtransport-vs-quic",
+			localAddr: ma.StringCast("/ip4/192.168.0.1/udp/12345/quic-v1/webtransport"),
+			dialAddr:  ma.StringCast("/ip4/1.2.3.4/udp/123/quic-v1"),
+			success:   false,
+		},
+		{
+			name:      "relay",
+			localAddr: ma.StringCast("/ip4/1.2.3.4/udp/123/quic-v1/p2p/12D3KooWJmGh1a6jmXRKeA6dCV6kiMS7MD6SPxJP3i4qk8BCeqWt/p2p-circuit"),
+			dialAddr:  ma.StringCast("/ip4/1.2.3.4/tcp/123/p2p/12D3KooWJmGh1a6jmXRKeA6dCV6kiMS7MD6SPxJP3i4qk8BCeqWt/p2p-circuit"),
+			success:   true,
+		},
+		{
+			name:      "relay-different-relay",
+			localAddr: ma.StringCast("/ip4/1.2.3.4/tcp/123/p2p/12D3KooWJmGh1a6jmXRKeA6dCV6kiMS7MD6SPxJP3i4qk8BCeqWt/p2p-circuit"),
+			dialAddr:  ma.StringCast("/ip4/1.2.3.4/tcp/123/p2p/12D3KooWGzxzKZYveHXtpG6AsrUJBcWxHBFS2HsEoGTxrMLvKXtf/p2p-circuit"),
+			success:   false,
+		},
+		{
+			name:      "relay-direct",
+			localAddr: ma.StringCast("/ip4/192.168.0.1/tcp/12345"),
+			dialAddr:  ma.StringCast("/ip4/1.2.3.4/tcp/123/p2p/12D3KooWJmGh1a6jmXRKeA6dCV6kiMS7MD6SPxJP3i4qk8BCeqWt/p2p-circuit"),
+			success:   false,
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -306,8 +306,14 @@ func (ac *client) handleDialBack(s network.Stream) {
 		s.Reset()
 		return
 	}
+	// A dial-back to a relay address arrives on a relayed connection. Its
+	// remote address is the relay address the server dialed.
+	dialBackAddr := s.Conn().LocalMultiaddr()
+	if _, ok := relayPeer(s.Conn().RemoteMultiaddr()); ok {
+		dialBackAddr = s.Conn().RemoteMultiaddr()
+	}
 	select {
-	case ch <- s.Conn().LocalMultiaddr():
+	case ch <- dialBackAddr:
 	default:
 		log.Debugf("multiple dialbacks received: localAddr: %s peer: %s", s.Conn().LocalMultiaddr(), s.Conn().RemotePeer())
 		s.Reset()
@@ -325,40 +331,67 @@ func (ac *client) areAddrsConsistent(connLocalAddr, dialedAddr ma.Multiaddr) boo
 	if len(connLocalAddr) == 0 || len(dialedAddr) == 0 {
 		return false
 	}
+	if dialedRelay, ok := relayPeer(dialedAddr); ok {
+		// The dial-back came through the relay we asked the server to use. The
+		// transport used to reach the relay doesn't matter.
+		connRelay, ok := relayPeer(connLocalAddr)
+		return ok && connRelay == dialedRelay
+	}
 	connLocalAddr = ac.normalizeMultiaddr(connLocalAddr)
 	dialedAddr = ac.normalizeMultiaddr(dialedAddr)
 
-	localProtos := connLocalAddr.Protocols()
-	externalProtos := dialedAddr.Protocols()
+	localProtos := transportProtocols(connLocalAddr)
+	externalProtos := transportProtocols(dialedAddr)
 	if len(localProtos) != len(externalProtos) {
 		return false
 	}
 	for i, lp := range localProtos {
 		ep := externalProtos[i]
 		if i == 0 {
-			switch ep.Code {
+			switch ep {
 			case ma.P_DNS, ma.P_DNSADDR:
-				if lp.Code == ma.P_IP4 || lp.Code == ma.P_IP6 {
+				if lp == ma.P_IP4 || lp == ma.P_IP6 {
 					continue
 				}
 				return false
 			case ma.P_DNS4:
-				if lp.Code == ma.P_IP4 {
+				if lp == ma.P_IP4 {
 					continue
 				}
 				return false
 			case ma.P_DNS6:
-				if lp.Code == ma.P_IP6 {
+				if lp == ma.P_IP6 {
 					continue
 				}
 				return false
 			}
-			if lp.Code != ep.Code {
+			if lp != ep {
 				return false
 			}
-		} else if lp.Code != ep.Code {
+		} else if lp != ep {
 			return false
 		}
 	}
 	return true
 }
+
+// transportProtocols returns the codes of the protocols of a that determine
+// the transport used to dial it. Components that only parameterize the dial,
+// like /sni, /http-path, and /certhash, are dropped, and /tls/ws is returned
+// as /wss, which is how the WebSocket transport reports its local addresses.
+func transportProtocols(a ma.Multiaddr) []int {
+	codes := make([]int, 0, len(a))
+	for _, c := range a {
+		switch c.Code() {
+		case ma.P_SNI, ma.P_HTTP_PATH, ma.P_CERTHASH:
+			continue
+		case ma.P_WS:
+			if len(codes) > 0 && codes[len(codes)-1] == ma.P_TLS {
+				codes[len(codes)-1] = ma.P_WSS
+				continue
+			}
+		}
+		codes = append(codes, c.Code())
+	}
+	return codes
+}
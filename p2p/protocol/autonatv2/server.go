@@ -54,6 +54,11 @@ type server struct {
 	amplificatonAttackPreventionDialWait time.Duration
 	metricsTracer                        MetricsTracer
 
+	// relayConns counts the in progress dial-backs over each relay. The dialer
+	// host's connection to a relay is closed once there are none left.
+	relayConnsMx sync.Mutex
+	relayConns   map[peer.ID]int
+
 	// for tests
 	now               func() time.Time
 	allowPrivateAddrs bool
@@ -72,6 +77,7 @@ func newServer(dialer host.Host, s *autoNATSettings) *server {
 			MaxConcurrentRequestsPerPeer: s.maxConcurrentRequestsPerPeer,
 			now:                          s.now,
 		},
+		relayConns:    make(map[peer.ID]int),
 		now:           s.now,
 		metricsTracer: s.metricsTracer,
 	}
@@ -181,6 +187,10 @@ func (as *server) serveDialRequest(s network.Stream) EventDialRequestCompleted {
 		if !as.allowPrivateAddrs && !manet.IsPublicAddr(a) {
 			continue
 		}
+		// Don't dial back through the peer itself.
+		if relay, ok := relayPeer(a); ok && relay == p {
+			continue
+		}
 		if !as.dialerHost.Network().CanDial(p, a) {
 			continue
 		}
@@ -342,7 +352,15 @@ func readDialData(numBytes int, r io.Reader) error {
 
 func (as *server) dialBack(ctx context.Context, p peer.ID, addr ma.Multiaddr, nonce uint64) pb.DialStatus {
 	ctx, cancel := context.WithTimeout(ctx, dialBackDialTimeout)
-	ctx = network.WithForceDirectDial(ctx, "autonatv2")
+	if relay, ok := relayPeer(addr); ok {
+		// Dial-backs to relay addresses verify that the peer's relay
+		// reservation works. They go over a limited connection through the relay.
+		ctx = network.WithAllowLimitedConn(ctx, "autonatv2")
+		as.acquireRelayConn(relay)
+		defer as.releaseRelayConn(relay)
+	} else {
+		ctx = network.WithForceDirectDial(ctx, "autonatv2")
+	}
 	as.dialerHost.Peerstore().AddAddr(p, addr, peerstore.TempAddrTTL)
 	defer func() {
 		cancel()
@@ -383,6 +401,25 @@ func (as *server) dialBack(ctx context.Context, p peer.ID, addr ma.Multiaddr, no
 	return pb.DialStatus_OK
 }
 
+func (as *server) acquireRelayConn(relay peer.ID) {
+	as.relayConnsMx.Lock()
+	defer as.relayConnsMx.Unlock()
+	as.relayConns[relay]++
+}
+
+// releaseRelayConn closes the dialer host's connection to the relay once no
+// other dial-back uses it.
+func (as *server) releaseRelayConn(relay peer.ID) {
+	as.relayConnsMx.Lock()
+	defer as.relayConnsMx.Unlock()
+	as.relayConns[relay]--
+	if as.relayConns[relay] > 0 {
+		return
+	}
+	delete(as.relayConns, relay)
+	as.dialerHost.Network().ClosePeer(relay)
+}
+
 // rateLimiter implements a sliding window rate limit of requests per minute. It allows 1 concurrent request
 // per peer. It rate limits requests globally, at a peer level and depending on whether it requires dial data.
 type rateLimiter struct {
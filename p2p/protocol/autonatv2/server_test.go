@@ -12,6 +12,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/test"
@@ -19,6 +20,8 @@ import (
 	"github.com/libp2p/go-libp2p/p2p/net/swarm"
 	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
 	"github.com/libp2p/go-libp2p/p2p/protocol/autonatv2/pb"
+	relayclient "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/client"
+	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
 	"github.com/libp2p/go-msgio/pbio"
 	ma "github.com/multiformats/go-multiaddr"
 	"github.com/multiformats/go-varint"
@@ -645,3 +648,69 @@ func BenchmarkDialData(b *testing.B) {
 		require.NoError(b, err)
 	}
 }
+
+func TestServerDialBackOverRelay(t *testing.T) {
+	addCircuitTransport := func(t *testing.T, h host.Host) {
+		t.Helper()
+		require.NoError(t, relayclient.AddTransport(h, swarmt.GenUpgrader(t, h.Network().(*swarm.Swarm), nil)))
+	}
+	newRelay := func(t *testing.T) host.Host {
+		t.Helper()
+		h := bhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+		r, err := relay.New(h)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			r.Close()
+			h.Close()
+		})
+		return h
+	}
+	relayAddr := func(r host.Host) ma.Multiaddr {
+		return r.Addrs()[0].Encapsulate(ma.StringCast("/p2p/" + r.ID().String() + "/p2p-circuit"))
+	}
+
+	dialer := bhost.NewBlankHost(swarmt.GenSwarm(t,
+		swarmt.WithSwarmOpts(
+			swarm.WithUDPBlackHoleSuccessCounter(nil),
+			swarm.WithIPv6BlackHoleSuccessCounter(nil))))
+	addCircuitTransport(t, dialer)
+	an := newAutoNAT(t, dialer, allowPrivateAddrs, withAmplificationAttackPreventionDialWait(0))
+	defer an.Close()
+	defer an.host.Close()
+
+	c := newAutoNAT(t, nil, allowPrivateAddrs)
+	defer c.Close()
+	defer c.host.Close()
+	addCircuitTransport(t, c.host)
+	idAndWait(t, c, an)
+
+	r := newRelay(t)
+	require.NoError(t, c.host.Connect(context.Background(), peer.AddrInfo{ID: r.ID(), Addrs: r.Addrs()}))
+	_, err := relayclient.Reserve(context.Background(), c.host, peer.AddrInfo{ID: r.ID(), Addrs: r.Addrs()})
+	require.NoError(t, err)
+
+	t.Run("reservation", func(t *testing.T) {
+		addr := relayAddr(r)
+		res, err := c.GetReachability(context.Background(), []Request{{Addr: addr, SendDialData: true}})
+		require.NoError(t, err)
+		require.Equal(t, Result{Addr: addr, Idx: 0, Reachability: network.ReachabilityPublic}, res)
+		// The dialer doesn't keep a connection to the relay.
+		require.Eventually(t, func() bool {
+			return dialer.Network().Connectedness(r.ID()) != network.Connected
+		}, 5*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("no reservation", func(t *testing.T) {
+		addr := relayAddr(newRelay(t))
+		res, err := c.GetReachability(context.Background(), []Request{{Addr: addr, SendDialData: true}})
+		require.NoError(t, err)
+		require.Equal(t, Result{Addr: addr, Idx: 0, Reachability: network.ReachabilityPrivate}, res)
+	})
+
+	t.Run("own relay address", func(t *testing.T) {
+		addr := ma.StringCast("/ip4/127.0.0.1/tcp/1/p2p/" + c.host.ID().String() + "/p2p-circuit")
+		res, err := c.GetReachability(context.Background(), []Request{{Addr: addr, SendDialData: true}})
+		require.NoError(t, err)
+		require.True(t, res.AllAddrsRefused)
+	})
+}
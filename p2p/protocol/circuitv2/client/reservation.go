@@ -175,3 +175,42 @@ func Reserve(ctx context.Context, h host.Host, ai peer.AddrInfo) (*Reservation,
 
 	return result, nil
 }
+
+// QueryStatus asks a relay whether it would currently accept a reservation
+// from us, without reserving a slot. It uses the proto.ProtoIDRelayStatus
+// protocol, which isn't part of the circuit v2 spec.
+//
+// It returns nil if the relay has room, and a ReservationError carrying the
+// relay's status if the relay reported that it has none. Any other error means
+// the relay's capacity is unknown, e.g. because it doesn't support status
+// queries.
+func QueryStatus(ctx context.Context, h host.Host, p peer.ID) error {
+	s, err := h.NewStream(ctx, p, proto.ProtoIDRelayStatus)
+	if err != nil {
+		return fmt.Errorf("failed to open status stream: %w", err)
+	}
+	defer s.Close()
+	s.CloseWrite()
+
+	rd := util.NewDelimitedReader(s, maxMessageSize)
+	defer rd.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline)
+	} else {
+		s.SetDeadline(time.Now().Add(ReserveTimeout))
+	}
+
+	var msg pbv2.HopMessage
+	if err := rd.ReadMsg(&msg); err != nil {
+		s.Reset()
+		return fmt.Errorf("error reading status response message: %w", err)
+	}
+	if msg.GetType() != pbv2.HopMessage_STATUS {
+		return fmt.Errorf("unexpected relay response: not a status message (%d)", msg.GetType())
+	}
+	if status := msg.GetStatus(); status != pbv2.Status_OK {
+		return ReservationError{Status: status, Reason: "relay has no room for a reservation"}
+	}
+	return nil
+}
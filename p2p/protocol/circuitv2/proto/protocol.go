@@ -3,4 +3,10 @@ package proto
 const (
 	ProtoIDv2Hop  = "/libp2p/circuit/relay/0.2.0/hop"
 	ProtoIDv2Stop = "/libp2p/circuit/relay/0.2.0/stop"
+
+	// ProtoIDRelayStatus lets a client ask a relay whether it would accept a
+	// reservation. It isn't part of the circuit v2 spec, and only go-libp2p
+	// relays support it. The client doesn't send anything; the relay responds
+	// with a STATUS HopMessage and closes the stream.
+	ProtoIDRelayStatus = "/go-libp2p/circuit/relay/status/1.0.0"
 )
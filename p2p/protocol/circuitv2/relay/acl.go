@@ -15,3 +15,23 @@ type ACLFilter interface {
 	// to a destination peer.
 	AllowConnect(src peer.ID, srcAddr ma.Multiaddr, dest peer.ID) bool
 }
+
+// NewPeerSetACL returns an ACLFilter admitting peers with peer.Admitted:
+// reservations are allowed for admitted peers, and relayed connections are
+// allowed from admitted peers to peers that aren't in deny. Either set may be
+// nil. Changes to the sets apply to new requests immediately.
+func NewPeerSetACL(allow, deny *peer.Set) ACLFilter {
+	return &peerSetACL{allow: allow, deny: deny}
+}
+
+type peerSetACL struct {
+	allow, deny *peer.Set
+}
+
+func (a *peerSetACL) AllowReserve(p peer.ID, _ ma.Multiaddr) bool {
+	return peer.Admitted(a.allow, a.deny, p)
+}
+
+func (a *peerSetACL) AllowConnect(src peer.ID, _ ma.Multiaddr, dest peer.ID) bool {
+	return peer.Admitted(a.allow, a.deny, src) && !a.deny.Contains(dest)
+}
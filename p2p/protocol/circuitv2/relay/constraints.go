@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	asnutil "github.com/libp2p/go-libp2p-asn-util"
 	"github.com/libp2p/go-libp2p/core/peer"
 
@@ -26,7 +27,8 @@ type peerWithExpiry struct {
 
 // constraints implements various reservation constraints
 type constraints struct {
-	rc *Resources
+	rc    *Resources
+	clock clock.Clock
 
 	mutex sync.Mutex
 	total []peerWithExpiry
@@ -37,11 +39,12 @@ type constraints struct {
 // newConstraints creates a new constraints object.
 // The methods are *not* thread-safe; an external lock must be held if synchronization
 // is required.
-func newConstraints(rc *Resources) *constraints {
+func newConstraints(rc *Resources, cl clock.Clock) *constraints {
 	return &constraints{
-		rc:   rc,
-		ips:  make(map[string][]peerWithExpiry),
-		asns: make(map[uint32][]peerWithExpiry),
+		rc:    rc,
+		clock: cl,
+		ips:   make(map[string][]peerWithExpiry),
+		asns:  make(map[uint32][]peerWithExpiry),
 	}
 }
 
@@ -51,7 +54,7 @@ func (c *constraints) Reserve(p peer.ID, a ma.Multiaddr, expiry time.Time) error
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	now := time.Now()
+	now := c.clock.Now()
 	c.cleanup(now)
 	// To handle refreshes correctly, remove the existing reservation for the peer.
 	c.cleanupPeer(p)
@@ -94,6 +97,49 @@ func (c *constraints) Reserve(p peer.ID, a ma.Multiaddr, expiry time.Time) error
 	return nil
 }
 
+// CanReserve checks whether a reservation for the given peer with the given
+// multiaddr would currently be accepted, without adding it.
+func (c *constraints) CanReserve(p peer.ID, a ma.Multiaddr) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.cleanup(c.clock.Now())
+
+	if countOthers(c.total, p) >= c.rc.MaxReservations {
+		return errTooManyReservations
+	}
+
+	ip, err := manet.ToIP(a)
+	if err != nil {
+		return errors.New("no IP address associated with peer")
+	}
+
+	if countOthers(c.ips[ip.String()], p) >= c.rc.MaxReservationsPerIP {
+		return errTooManyReservationsForIP
+	}
+
+	if ip.To4() == nil {
+		if asn := asnutil.AsnForIPv6(ip); asn != 0 {
+			if countOthers(c.asns[asn], p) >= c.rc.MaxReservationsPerASN {
+				return errTooManyReservationsForASN
+			}
+		}
+	}
+	return nil
+}
+
+// countOthers counts the reservations that don't belong to p. A refresh
+// replaces the peer's own reservation, so it doesn't take up a slot.
+func countOthers(rsvps []peerWithExpiry, p peer.ID) int {
+	n := 0
+	for _, pe := range rsvps {
+		if pe.Peer != p {
+			n++
+		}
+	}
+	return n
+}
+
 func (c *constraints) cleanup(now time.Time) {
 	expireFunc := func(pe peerWithExpiry) bool {
 		return pe.Expiry.Before(now)
@@ -10,6 +10,7 @@ import (
 
 	"github.com/libp2p/go-libp2p/core/test"
 
+	"github.com/benbjohnson/clock"
 	ma "github.com/multiformats/go-multiaddr"
 )
 
@@ -39,7 +40,7 @@ func TestConstraints(t *testing.T) {
 	t.Run("total reservations", func(t *testing.T) {
 		res := infResources()
 		res.MaxReservations = limit
-		c := newConstraints(res)
+		c := newConstraints(res, clock.New())
 		for i := 0; i < limit; i++ {
 			if err := c.Reserve(test.RandPeerIDFatal(t), randomIPv4Addr(t), expiry); err != nil {
 				t.Fatal(err)
@@ -55,7 +56,7 @@ func TestConstraints(t *testing.T) {
 		p2 := test.RandPeerIDFatal(t)
 		res := infResources()
 		res.MaxReservationsPerIP = 1
-		c := newConstraints(res)
+		c := newConstraints(res, clock.New())
 
 		ipAddr := randomIPv4Addr(t)
 		if err := c.Reserve(p, ipAddr, expiry); err != nil {
@@ -76,7 +77,7 @@ func TestConstraints(t *testing.T) {
 		ip := randomIPv4Addr(t)
 		res := infResources()
 		res.MaxReservationsPerIP = limit
-		c := newConstraints(res)
+		c := newConstraints(res, clock.New())
 		for i := 0; i < limit; i++ {
 			if err := c.Reserve(test.RandPeerIDFatal(t), ip, expiry); err != nil {
 				t.Fatal(err)
@@ -102,7 +103,7 @@ func TestConstraints(t *testing.T) {
 
 		res := infResources()
 		res.MaxReservationsPerASN = limit
-		c := newConstraints(res)
+		c := newConstraints(res, clock.New())
 		const ipv6Prefix = "2a03:2880:f003:c07:face:b00c::"
 		for i := 0; i < limit; i++ {
 			addr := getAddr(t, net.ParseIP(fmt.Sprintf("%s%d", ipv6Prefix, i+1)))
@@ -129,7 +130,7 @@ func TestConstraintsCleanup(t *testing.T) {
 		MaxReservationsPerIP:   math.MaxInt32,
 		MaxReservationsPerASN:  math.MaxInt32,
 	}
-	c := newConstraints(res)
+	c := newConstraints(res, clock.New())
 	for i := 0; i < limit; i++ {
 		if err := c.Reserve(test.RandPeerIDFatal(t), randomIPv4Addr(t), expiry); err != nil {
 			t.Fatal(err)
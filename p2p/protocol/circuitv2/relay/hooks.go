@@ -0,0 +1,119 @@
+package relay
+
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// Hooks is notified about reservations and relayed connections (circuits),
+// e.g. to integrate the relay with billing or abuse detection systems.
+// Methods are called synchronously from the relay's goroutines and must not
+// block.
+type Hooks interface {
+	// ReservationCreated is called when a reservation is created or renewed.
+	ReservationCreated(ReservationInfo)
+	// ReservationEnded is called when a reservation is removed.
+	ReservationEnded(p peer.ID, reason ReservationEndReason)
+	// CircuitOpened is called when a circuit is established.
+	CircuitOpened(CircuitInfo)
+	// CircuitClosed is called when a circuit is closed, with the data relayed
+	// over it.
+	CircuitClosed(CircuitInfo, CircuitUsage)
+}
+
+// ReservationInfo describes a reservation.
+type ReservationInfo struct {
+	Peer peer.ID
+	// Addr is the address the reservation was made from.
+	Addr   ma.Multiaddr
+	Expiry time.Time
+	// Renewal is true if the peer already had a reservation.
+	Renewal bool
+	// Restored is true if the reservation was loaded from the
+	// ReservationStore when the relay started.
+	Restored bool
+}
+
+// ReservationEndReason is the reason a reservation ended.
+type ReservationEndReason int
+
+const (
+	// ReservationExpired means the reservation wasn't renewed before its TTL.
+	ReservationExpired ReservationEndReason = iota
+	// ReservationDisconnected means the peer disconnected from the relay.
+	ReservationDisconnected
+	// ReservationRelayClosed means the relay service was closed.
+	ReservationRelayClosed
+)
+
+func (r ReservationEndReason) String() string {
+	switch r {
+	case ReservationExpired:
+		return "expired"
+	case ReservationDisconnected:
+		return "disconnected"
+	case ReservationRelayClosed:
+		return "relay closed"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitInfo describes a circuit from Src to Dest.
+type CircuitInfo struct {
+	Src     peer.ID
+	SrcAddr ma.Multiaddr
+	Dest    peer.ID
+	Opened  time.Time
+}
+
+// CircuitUsage is the data relayed over a circuit.
+type CircuitUsage struct {
+	Duration time.Duration
+	// BytesSrcToDest and BytesDestToSrc are the bytes relayed in each
+	// direction.
+	BytesSrcToDest int64
+	BytesDestToSrc int64
+}
+
+// HooksBundle implements Hooks by calling any of the functions set on it, and
+// ignoring the calls whose function is nil.
+type HooksBundle struct {
+	ReservationCreatedF func(ReservationInfo)
+	ReservationEndedF   func(peer.ID, ReservationEndReason)
+	CircuitOpenedF      func(CircuitInfo)
+	CircuitClosedF      func(CircuitInfo, CircuitUsage)
+}
+
+var _ Hooks = (*HooksBundle)(nil)
+
+// ReservationCreated calls ReservationCreatedF if it is not nil.
+func (hb *HooksBundle) ReservationCreated(info ReservationInfo) {
+	if hb.ReservationCreatedF != nil {
+		hb.ReservationCreatedF(info)
+	}
+}
+
+// ReservationEnded calls ReservationEndedF if it is not nil.
+func (hb *HooksBundle) ReservationEnded(p peer.ID, reason ReservationEndReason) {
+	if hb.ReservationEndedF != nil {
+		hb.ReservationEndedF(p, reason)
+	}
+}
+
+// CircuitOpened calls CircuitOpenedF if it is not nil.
+func (hb *HooksBundle) CircuitOpened(info CircuitInfo) {
+	if hb.CircuitOpenedF != nil {
+		hb.CircuitOpenedF(info)
+	}
+}
+
+// CircuitClosed calls CircuitClosedF if it is not nil.
+func (hb *HooksBundle) CircuitClosed(info CircuitInfo, usage CircuitUsage) {
+	if hb.CircuitClosedF != nil {
+		hb.CircuitClosedF(info, usage)
+	}
+}
@@ -83,6 +83,37 @@ var (
 			Help:      "Bytes Transferred Total",
 		},
 	)
+	dataRelayedBytesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "data_relayed_bytes_total",
+			Help:      "Bytes Relayed Total by Direction",
+		},
+		[]string{"direction"},
+	)
+
+	activeReservations = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Name:      "active_reservations",
+			Help:      "Active Relay Reservations",
+		},
+	)
+	activeConnections = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Name:      "active_connections",
+			Help:      "Active Relay Connections",
+		},
+	)
+	requestRejectionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "request_rejections_total",
+			Help:      "Relay Requests Rejected by Detailed Reason",
+		},
+		[]string{"request", "reason"},
+	)
 
 	collectors = []prometheus.Collector{
 		status,
@@ -94,6 +125,10 @@ var (
 		connectionRejectionsTotal,
 		connectionDurationSeconds,
 		dataTransferredBytesTotal,
+		dataRelayedBytesTotal,
+		activeReservations,
+		activeConnections,
+		requestRejectionsTotal,
 	}
 )
 
@@ -126,9 +161,26 @@ type MetricsTracer interface {
 	BytesTransferred(cnt int)
 }
 
+// ExtendedMetricsTracer is an optional interface a MetricsTracer can implement
+// to also track the direction of relayed data and the detailed reasons
+// requests are refused. The MetricsTracer returned by NewMetricsTracer
+// implements it.
+type ExtendedMetricsTracer interface {
+	// BytesRelayed tracks the bytes relayed from the source to the destination
+	// of a circuit, or the other way around. It also counts them as
+	// transferred, so BytesTransferred isn't called for them.
+	BytesRelayed(srcToDest bool, cnt int)
+	// RequestRejected tracks the detailed reason a reservation or connection
+	// request was refused
+	RequestRejected(isReservation bool, reason RejectionReason)
+}
+
 type metricsTracer struct{}
 
-var _ MetricsTracer = &metricsTracer{}
+var (
+	_ MetricsTracer         = &metricsTracer{}
+	_ ExtendedMetricsTracer = &metricsTracer{}
+)
 
 type metricsTracerSetting struct {
 	reg prometheus.Registerer
@@ -167,6 +219,7 @@ func (mt *metricsTracer) ConnectionOpened() {
 	*tags = append(*tags, "opened")
 
 	connectionsTotal.WithLabelValues(*tags...).Add(1)
+	activeConnections.Inc()
 }
 
 func (mt *metricsTracer) ConnectionClosed(d time.Duration) {
@@ -176,6 +229,7 @@ func (mt *metricsTracer) ConnectionClosed(d time.Duration) {
 
 	connectionsTotal.WithLabelValues(*tags...).Add(1)
 	connectionDurationSeconds.Observe(d.Seconds())
+	activeConnections.Dec()
 }
 
 func (mt *metricsTracer) ConnectionRequestHandled(status pbv2.Status) {
@@ -200,6 +254,7 @@ func (mt *metricsTracer) ReservationAllowed(isRenewal bool) {
 		*tags = append(*tags, "renewed")
 	} else {
 		*tags = append(*tags, "opened")
+		activeReservations.Inc()
 	}
 
 	reservationsTotal.WithLabelValues(*tags...).Add(1)
@@ -211,6 +266,7 @@ func (mt *metricsTracer) ReservationClosed(cnt int) {
 	*tags = append(*tags, "closed")
 
 	reservationsTotal.WithLabelValues(*tags...).Add(float64(cnt))
+	activeReservations.Sub(float64(cnt))
 }
 
 func (mt *metricsTracer) ReservationRequestHandled(status pbv2.Status) {
@@ -232,6 +288,32 @@ func (mt *metricsTracer) BytesTransferred(cnt int) {
 	dataTransferredBytesTotal.Add(float64(cnt))
 }
 
+func (mt *metricsTracer) BytesRelayed(srcToDest bool, cnt int) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+	if srcToDest {
+		*tags = append(*tags, "src_to_dest")
+	} else {
+		*tags = append(*tags, "dest_to_src")
+	}
+
+	dataRelayedBytesTotal.WithLabelValues(*tags...).Add(float64(cnt))
+	mt.BytesTransferred(cnt)
+}
+
+func (mt *metricsTracer) RequestRejected(isReservation bool, reason RejectionReason) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+	if isReservation {
+		*tags = append(*tags, "reservation")
+	} else {
+		*tags = append(*tags, "connection")
+	}
+	*tags = append(*tags, string(reason))
+
+	requestRejectionsTotal.WithLabelValues(*tags...).Add(1)
+}
+
 func getResponseStatus(status pbv2.Status) string {
 	responseStatus := "unknown"
 	switch status {
@@ -17,7 +17,14 @@ func TestNoCoverNoAlloc(t *testing.T) {
 		pbv2.Status_RESOURCE_LIMIT_EXCEEDED,
 		pbv2.Status_PERMISSION_DENIED,
 	}
+	reasons := []RejectionReason{
+		RejectPermissionDenied,
+		RejectNoReservation,
+		RejectTooManyCircuits,
+		RejectQuotaExceeded,
+	}
 	mt := NewMetricsTracer()
+	ext := mt.(ExtendedMetricsTracer)
 	tests := map[string]func(){
 		"RelayStatus":               func() { mt.RelayStatus(rand.Intn(2) == 1) },
 		"ConnectionOpened":          func() { mt.ConnectionOpened() },
@@ -27,6 +34,8 @@ func TestNoCoverNoAlloc(t *testing.T) {
 		"ReservationClosed":         func() { mt.ReservationClosed(rand.Intn(10)) },
 		"ReservationRequestHandled": func() { mt.ReservationRequestHandled(statuses[rand.Intn(len(statuses))]) },
 		"BytesTransferred":          func() { mt.BytesTransferred(rand.Intn(1000)) },
+		"BytesRelayed":              func() { ext.BytesRelayed(rand.Intn(2) == 1, rand.Intn(1000)) },
+		"RequestRejected":           func() { ext.RequestRejected(rand.Intn(2) == 1, reasons[rand.Intn(len(reasons))]) },
 	}
 	for method, f := range tests {
 		allocs := testing.AllocsPerRun(1000, f)
@@ -1,5 +1,11 @@
 package relay
 
+import (
+	"errors"
+
+	"github.com/benbjohnson/clock"
+)
+
 type Option func(*Relay) error
 
 // WithResources is a Relay option that sets specific relay resources for the relay.
@@ -34,10 +40,82 @@ func WithACL(acl ACLFilter) Option {
 	}
 }
 
+// WithHooks is a Relay option that supplies Hooks notified about reservations
+// and circuits.
+func WithHooks(h Hooks) Option {
+	return func(r *Relay) error {
+		r.hooks = h
+		return nil
+	}
+}
+
+// WithReservationStore is a Relay option that persists reservations in store,
+// so that they survive a restart of the relay. See FileReservationStore for a
+// store keeping them on disk.
+func WithReservationStore(store ReservationStore) Option {
+	return func(r *Relay) error {
+		if store == nil {
+			return errors.New("reservation store must not be nil")
+		}
+		r.store = store
+		return nil
+	}
+}
+
+// WithQuotas is a Relay option that limits the data relayed for the same
+// peer, IP prefix or ASN. See Quotas.
+func WithQuotas(q Quotas) Option {
+	return func(r *Relay) error {
+		if q.Window < 0 || q.PerPeer < 0 || q.PerIPPrefix < 0 || q.PerASN < 0 {
+			return errors.New("quotas must not be negative")
+		}
+		if q.IPv4PrefixLen < 0 || q.IPv4PrefixLen > 32 || q.IPv6PrefixLen < 0 || q.IPv6PrefixLen > 128 {
+			return errors.New("invalid IP prefix length")
+		}
+		r.quotaConf = &q
+		return nil
+	}
+}
+
+// WithCircuitPriority is a Relay option that prioritizes circuits once the
+// relay reaches a circuit limit (MaxCircuits or MaxTotalCircuits). Instead of
+// refusing the requested circuit, the relay closes the open circuit with the
+// lowest priority counting towards the limit, if its priority is lower than
+// the priority of the requested circuit. Otherwise, the circuit is refused
+// with RejectLowerPriority. See ReservationAgePriority for a
+// priority function preferring peers with longer-standing reservations.
+//
+// priority is called with the relay's lock held, for the requested circuit
+// and, once a limit is reached, for the open circuits that could be closed.
+// It must be fast, and must not call methods of the Relay. By default,
+// circuits are admitted first-come-first-served.
+func WithCircuitPriority(priority func(CircuitRequest) int) Option {
+	return func(r *Relay) error {
+		if priority == nil {
+			return errors.New("circuit priority function must not be nil")
+		}
+		r.circuitPriority = priority
+		return nil
+	}
+}
+
 // WithMetricsTracer is a Relay option that supplies a MetricsTracer for metrics
 func WithMetricsTracer(mt MetricsTracer) Option {
 	return func(r *Relay) error {
 		r.metricsTracer = mt
+		r.extMetricsTracer, _ = mt.(ExtendedMetricsTracer)
+		return nil
+	}
+}
+
+// WithClock sets the clock used to track the age of reservations, circuits and
+// quota windows. It is mostly useful for testing.
+func WithClock(cl clock.Clock) Option {
+	return func(r *Relay) error {
+		if cl == nil {
+			return errors.New("clock must not be nil")
+		}
+		r.clock = cl
 		return nil
 	}
 }
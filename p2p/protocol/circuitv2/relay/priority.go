@@ -0,0 +1,81 @@
+package relay
+
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// CircuitRequest describes a requested circuit, see WithCircuitPriority.
+type CircuitRequest struct {
+	Src     peer.ID
+	SrcAddr ma.Multiaddr
+	Dest    peer.ID
+	// DestReservedSince is the time the reservation of Dest was made.
+	// Renewing the reservation doesn't change it. For reservations restored
+	// from the ReservationStore, it's the time the relay was started.
+	DestReservedSince time.Time
+	// Now is the time the priority is evaluated at, according to the clock
+	// of the relay (see WithClock). Priorities of open circuits are
+	// re-evaluated when a new circuit contends with them.
+	Now time.Time
+}
+
+// ReservationAgePriority is a circuit priority function for
+// WithCircuitPriority that prefers circuits to peers with longer-standing
+// reservations. The priority is the age of the reservation in seconds.
+func ReservationAgePriority(req CircuitRequest) int {
+	return int(req.Now.Sub(req.DestReservedSince) / time.Second)
+}
+
+// involves returns a function matching the circuits from or to p.
+func involves(p peer.ID) func(*activeCircuit) bool {
+	return func(c *activeCircuit) bool { return c.info.Src == p || c.info.Dest == p }
+}
+
+// preemptableLocked returns the open circuit with the lowest priority that
+// matches all limits, if its priority is lower than the priority of req.
+// contested is true if there are open circuits matching all limits, but none
+// of them has a lower priority. It always returns nil if circuits aren't
+// prioritized.
+//
+// The priorities of the open circuits are evaluated now rather than when they
+// were admitted, so that priorities changing over time, like those of
+// ReservationAgePriority, compare consistently.
+func (r *Relay) preemptableLocked(req CircuitRequest, limits []func(*activeCircuit) bool) (preempt *activeCircuit, contested bool) {
+	if r.circuitPriority == nil {
+		return nil, false
+	}
+	var lowest *activeCircuit
+	var lowestPriority int
+	now := r.clock.Now()
+	req.Now = now
+circuits:
+	for c := range r.circuits {
+		if c.preempted {
+			continue
+		}
+		for _, match := range limits {
+			if !match(c) {
+				continue circuits
+			}
+		}
+		creq := c.req
+		creq.Now = now
+		priority := r.circuitPriority(creq)
+		// Among circuits with the same priority, preempt the youngest.
+		if lowest == nil || priority < lowestPriority ||
+			(priority == lowestPriority && c.info.Opened.After(lowest.info.Opened)) {
+			lowest, lowestPriority = c, priority
+		}
+	}
+	if lowest == nil {
+		return nil, false
+	}
+	if lowestPriority >= r.circuitPriority(req) {
+		return nil, true
+	}
+	return lowest, false
+}
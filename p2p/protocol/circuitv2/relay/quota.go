@@ -0,0 +1,250 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	asnutil "github.com/libp2p/go-libp2p-asn-util"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+var errQuotaExceeded = errors.New("relay quota exceeded")
+
+// Quotas limit the data relayed for the same peer, IP prefix or ASN within a
+// time window, so that a few heavy users can't monopolize a relay. Every byte
+// relayed over a circuit counts against both ends of the circuit. A circuit is
+// refused if one of its ends exhausted a quota, and reset when a quota is
+// exhausted while relaying. A zero limit means no limit.
+type Quotas struct {
+	// Window is the period after which the relayed data is accounted anew.
+	// Defaults to 1 hour.
+	Window time.Duration
+
+	// PerPeer is the data relayed for a peer.
+	PerPeer int64
+	// PerIPPrefix is the data relayed for peers in the same IP prefix.
+	PerIPPrefix int64
+	// IPv4PrefixLen and IPv6PrefixLen are the lengths of the IP prefixes.
+	// They default to 24 and 48.
+	IPv4PrefixLen int
+	IPv6PrefixLen int
+	// PerASN is the data relayed for peers in the same ASN. The ASN is only
+	// known for IPv6 addresses.
+	PerASN int64
+
+	// OnExceeded is called when a quota is exceeded, once per window. It is
+	// called synchronously from the relay's goroutines and must not block.
+	OnExceeded func(QuotaExceeded)
+}
+
+// QuotaKind is the kind of a quota.
+type QuotaKind int
+
+const (
+	QuotaPeer QuotaKind = iota
+	QuotaIPPrefix
+	QuotaASN
+)
+
+func (k QuotaKind) String() string {
+	switch k {
+	case QuotaPeer:
+		return "peer"
+	case QuotaIPPrefix:
+		return "ip prefix"
+	case QuotaASN:
+		return "asn"
+	default:
+		return "unknown"
+	}
+}
+
+// QuotaExceeded describes an exceeded quota.
+type QuotaExceeded struct {
+	Kind QuotaKind
+	// Peer is the peer that sent the data exceeding the quota.
+	Peer peer.ID
+	// Key is the peer ID, IP prefix or ASN the quota applies to.
+	Key   string
+	Used  int64
+	Limit int64
+}
+
+type quotaKey struct {
+	kind QuotaKind
+	key  string
+}
+
+type quotaUsage struct {
+	start time.Time
+	used  int64
+	// exceeded is set once a write was refused for exceeding the quota.
+	exceeded bool
+}
+
+// quotaTracker accounts the data relayed for every quota key.
+type quotaTracker struct {
+	q     Quotas
+	clock clock.Clock
+
+	mx    sync.Mutex
+	usage map[quotaKey]*quotaUsage
+}
+
+func newQuotaTracker(q Quotas, cl clock.Clock) *quotaTracker {
+	if q.Window == 0 {
+		q.Window = time.Hour
+	}
+	if q.IPv4PrefixLen == 0 {
+		q.IPv4PrefixLen = 24
+	}
+	if q.IPv6PrefixLen == 0 {
+		q.IPv6PrefixLen = 48
+	}
+	return &quotaTracker{q: q, clock: cl, usage: make(map[quotaKey]*quotaUsage)}
+}
+
+func (t *quotaTracker) limit(k QuotaKind) int64 {
+	switch k {
+	case QuotaPeer:
+		return t.q.PerPeer
+	case QuotaIPPrefix:
+		return t.q.PerIPPrefix
+	case QuotaASN:
+		return t.q.PerASN
+	default:
+		return 0
+	}
+}
+
+// keys appends the quota keys of peer p connected from a to keys.
+func (t *quotaTracker) keys(keys []quotaKey, p peer.ID, a ma.Multiaddr) []quotaKey {
+	add := func(k quotaKey) {
+		if t.limit(k.kind) > 0 && !slices.Contains(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+	add(quotaKey{QuotaPeer, p.String()})
+	ip, err := manet.ToIP(a)
+	if err != nil {
+		return keys
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		add(quotaKey{QuotaIPPrefix, fmt.Sprintf("%s/%d", ip4.Mask(net.CIDRMask(t.q.IPv4PrefixLen, 32)), t.q.IPv4PrefixLen)})
+		return keys
+	}
+	add(quotaKey{QuotaIPPrefix, fmt.Sprintf("%s/%d", ip.Mask(net.CIDRMask(t.q.IPv6PrefixLen, 128)), t.q.IPv6PrefixLen)})
+	if asn := asnutil.AsnForIPv6(ip); asn != 0 {
+		add(quotaKey{QuotaASN, fmt.Sprint(asn)})
+	}
+	return keys
+}
+
+// get returns the usage of k in the current window. t.mx must be held.
+func (t *quotaTracker) get(k quotaKey, now time.Time) *quotaUsage {
+	u, ok := t.usage[k]
+	if !ok || now.Sub(u.start) >= t.q.Window {
+		u = &quotaUsage{start: now}
+		t.usage[k] = u
+	}
+	return u
+}
+
+// allowed returns false if one of the quotas of keys is exhausted, or a write
+// was already refused for exceeding it.
+func (t *quotaTracker) allowed(keys []quotaKey) bool {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	now := t.clock.Now()
+	for _, k := range keys {
+		if u := t.get(k, now); u.exceeded || u.used >= t.limit(k.kind) {
+			return false
+		}
+	}
+	return true
+}
+
+// use accounts n bytes about to be sent by p against keys. It returns false,
+// without accounting anything, if that would exceed one of the quotas.
+func (t *quotaTracker) use(keys []quotaKey, p peer.ID, n int) bool {
+	ok := true
+	var exceeded []QuotaExceeded
+	t.mx.Lock()
+	now := t.clock.Now()
+	for _, k := range keys {
+		u := t.get(k, now)
+		if limit := t.limit(k.kind); u.used+int64(n) > limit {
+			ok = false
+			if !u.exceeded {
+				u.exceeded = true
+				exceeded = append(exceeded, QuotaExceeded{Kind: k.kind, Peer: p, Key: k.key, Used: u.used + int64(n), Limit: limit})
+			}
+		}
+	}
+	if ok {
+		for _, k := range keys {
+			t.get(k, now).used += int64(n)
+		}
+	}
+	t.mx.Unlock()
+
+	for _, e := range exceeded {
+		log.Debugf("relay %s quota exceeded for %s: %d > %d", e.Kind, e.Key, e.Used, e.Limit)
+		if t.q.OnExceeded != nil {
+			t.q.OnExceeded(e)
+		}
+	}
+	return ok
+}
+
+// refund gives back n bytes accounted by use that weren't actually sent.
+func (t *quotaTracker) refund(keys []quotaKey, n int) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	for _, k := range keys {
+		// the usage may have been reset by a new window in the meantime
+		if u, ok := t.usage[k]; ok {
+			u.used = max(u.used-int64(n), 0)
+		}
+	}
+}
+
+// gc removes the usage of past windows.
+func (t *quotaTracker) gc(now time.Time) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	for k, u := range t.usage {
+		if now.Sub(u.start) >= t.q.Window {
+			delete(t.usage, k)
+		}
+	}
+}
+
+// quotaWriter accounts the data written to a relayed stream, failing once a
+// quota is exceeded.
+type quotaWriter struct {
+	io.Writer
+	t    *quotaTracker
+	keys []quotaKey
+	peer peer.ID
+}
+
+func (w *quotaWriter) Write(b []byte) (int, error) {
+	if !w.t.use(w.keys, w.peer, len(b)) {
+		return 0, errQuotaExceeded
+	}
+	n, err := w.Writer.Write(b)
+	if n < len(b) {
+		w.t.refund(w.keys, len(b)-n)
+	}
+	return n, err
+}
@@ -0,0 +1,77 @@
+package relay
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestQuotaKeys(t *testing.T) {
+	qt := newQuotaTracker(Quotas{PerPeer: 1, PerIPPrefix: 1}, clock.New())
+	_, p1 := genKeyAndID(t)
+	_, p2 := genKeyAndID(t)
+	keys := qt.keys(nil, p1, ma.StringCast("/ip4/1.2.3.4/tcp/1"))
+	keys = qt.keys(keys, p2, ma.StringCast("/ip4/1.2.3.5/tcp/1"))
+	require.ElementsMatch(t, []quotaKey{
+		{QuotaPeer, p1.String()},
+		{QuotaPeer, p2.String()},
+		{QuotaIPPrefix, "1.2.3.0/24"},
+	}, keys)
+
+	keys = qt.keys(nil, p1, ma.StringCast("/ip6/2001:db8:1:2::1/udp/1/quic-v1"))
+	require.Contains(t, keys, quotaKey{QuotaIPPrefix, "2001:db8:1::/48"})
+
+	// disabled quotas have no keys
+	qt = newQuotaTracker(Quotas{PerIPPrefix: 1}, clock.New())
+	require.Equal(t, []quotaKey{{QuotaIPPrefix, "1.2.3.0/24"}}, qt.keys(nil, p1, ma.StringCast("/ip4/1.2.3.4/tcp/1")))
+}
+
+func TestQuotaWindow(t *testing.T) {
+	var exceeded []QuotaExceeded
+	cl := clock.NewMock()
+	qt := newQuotaTracker(Quotas{
+		Window:     time.Minute,
+		PerPeer:    100,
+		OnExceeded: func(e QuotaExceeded) { exceeded = append(exceeded, e) },
+	}, cl)
+	_, p := genKeyAndID(t)
+	keys := qt.keys(nil, p, ma.StringCast("/ip4/1.2.3.4/tcp/1"))
+
+	require.True(t, qt.use(keys, p, 60))
+	// rejected writes don't count against the quota
+	require.False(t, qt.use(keys, p, 50))
+	require.True(t, qt.use(keys, p, 40))
+	require.False(t, qt.allowed(keys))
+	require.False(t, qt.use(keys, p, 1))
+	require.False(t, qt.use(keys, p, 1))
+	require.Equal(t, []QuotaExceeded{{Kind: QuotaPeer, Peer: p, Key: p.String(), Used: 110, Limit: 100}}, exceeded)
+
+	cl.Add(time.Minute)
+	require.True(t, qt.allowed(keys))
+	qt.gc(cl.Now().Add(time.Minute))
+	require.Empty(t, qt.usage)
+}
+
+type failingWriter struct{ n int }
+
+func (w failingWriter) Write(b []byte) (int, error) {
+	return min(w.n, len(b)), errors.New("write failed")
+}
+
+func TestQuotaWriterFailedWrite(t *testing.T) {
+	qt := newQuotaTracker(Quotas{PerPeer: 100}, clock.New())
+	_, p := genKeyAndID(t)
+	keys := qt.keys(nil, p, ma.StringCast("/ip4/1.2.3.4/tcp/1"))
+
+	w := &quotaWriter{Writer: failingWriter{n: 30}, t: qt, keys: keys, peer: p}
+	n, err := w.Write(make([]byte, 100))
+	require.Error(t, err)
+	require.Equal(t, 30, n)
+	// only the bytes actually written are accounted
+	require.Equal(t, int64(30), qt.usage[quotaKey{QuotaPeer, p.String()}].used)
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/proto"
 	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/util"
 
+	"github.com/benbjohnson/clock"
 	logging "github.com/ipfs/go-log/v2"
 	pool "github.com/libp2p/go-buffer-pool"
 	ma "github.com/multiformats/go-multiaddr"
@@ -41,26 +42,63 @@ const (
 
 var log = logging.Logger("relay")
 
+// RestoredReservationTimeout is how long a reservation restored from the
+// ReservationStore is kept if its peer doesn't reconnect to the relay.
+var RestoredReservationTimeout = 5 * time.Minute
+
 // Relay is the (limited) relay service object.
 type Relay struct {
 	ctx    context.Context
 	cancel func()
 
 	host        host.Host
+	clock       clock.Clock
 	rc          Resources
 	acl         ACLFilter
 	constraints *constraints
 	scope       network.ResourceScopeSpan
 	notifiee    network.Notifiee
 
-	mx     sync.Mutex
-	rsvp   map[peer.ID]time.Time
-	conns  map[peer.ID]int
-	closed bool
+	mx   sync.Mutex
+	rsvp map[peer.ID]time.Time
+	// rsvpSince is the time the reservations were made, not counting
+	// renewals.
+	rsvpSince map[peer.ID]time.Time
+	conns     map[peer.ID]int
+	// numCircuits is the number of circuits, including the ones that are
+	// still being established.
+	numCircuits int
+	closed      bool
+
+	circuitPriority func(CircuitRequest) int
+	// preempted is the number of circuits closed for circuits with a higher
+	// priority.
+	preempted int64
+
+	circuits       map[*activeCircuit]struct{}
+	rsvpRejections map[RejectionReason]int64
+	connRejections map[RejectionReason]int64
+	bytesSrcToDest atomic.Int64
+	bytesDestToSrc atomic.Int64
 
 	selfAddr ma.Multiaddr
 
 	metricsTracer MetricsTracer
+	// extMetricsTracer is metricsTracer, if it implements
+	// ExtendedMetricsTracer
+	extMetricsTracer ExtendedMetricsTracer
+	hooks            Hooks
+	store            ReservationStore
+	storeWriter      *storeWriter
+	// restored are the peers whose reservations were restored from the
+	// store. Their reservations are dropped after
+	// RestoredReservationTimeout if they haven't reconnected.
+	restored      []peer.ID
+	restoredTimer *clock.Timer
+	// quotaConf is set by WithQuotas. The tracker is created once all
+	// options are applied, so that it uses the relay's clock.
+	quotaConf *Quotas
+	quotas    *quotaTracker
 }
 
 // New constructs a new limited relay that can provide relay services in the given host.
@@ -71,10 +109,17 @@ func New(h host.Host, opts ...Option) (*Relay, error) {
 		ctx:    ctx,
 		cancel: cancel,
 		host:   h,
+		clock:  clock.New(),
 		rc:     DefaultResources(),
 		acl:    nil,
 		rsvp:   make(map[peer.ID]time.Time),
 		conns:  make(map[peer.ID]int),
+
+		rsvpSince: make(map[peer.ID]time.Time),
+
+		circuits:       make(map[*activeCircuit]struct{}),
+		rsvpRejections: make(map[RejectionReason]int64),
+		connRejections: make(map[RejectionReason]int64),
 	}
 
 	for _, opt := range opts {
@@ -83,6 +128,9 @@ func New(h host.Host, opts ...Option) (*Relay, error) {
 			return nil, fmt.Errorf("error applying relay option: %w", err)
 		}
 	}
+	if r.quotaConf != nil {
+		r.quotas = newQuotaTracker(*r.quotaConf, r.clock)
+	}
 
 	// get a scope for memory reservations at service level
 	err := h.Network().ResourceManager().ViewService(ServiceName,
@@ -95,10 +143,19 @@ func New(h host.Host, opts ...Option) (*Relay, error) {
 		return nil, err
 	}
 
-	r.constraints = newConstraints(&r.rc)
+	r.constraints = newConstraints(&r.rc, r.clock)
 	r.selfAddr = ma.StringCast(fmt.Sprintf("/p2p/%s", h.ID()))
+	if r.store != nil {
+		r.storeWriter = newStoreWriter(r.store)
+		if err := r.restoreReservations(); err != nil {
+			r.scope.Done()
+			return nil, fmt.Errorf("failed to restore reservations: %w", err)
+		}
+		go r.storeWriter.run(r.ctx)
+	}
 
 	h.SetStreamHandler(proto.ProtoIDv2Hop, r.handleStream)
+	h.SetStreamHandler(proto.ProtoIDRelayStatus, r.handleStatus)
 	r.notifiee = &network.NotifyBundle{DisconnectedF: r.disconnected}
 	h.Network().Notify(r.notifiee)
 
@@ -117,10 +174,17 @@ func (r *Relay) Close() error {
 		r.mx.Unlock()
 
 		r.host.RemoveStreamHandler(proto.ProtoIDv2Hop)
+		r.host.RemoveStreamHandler(proto.ProtoIDRelayStatus)
 		r.host.Network().StopNotify(r.notifiee)
 		defer r.scope.Done()
 		r.cancel()
 		r.gc()
+		if r.storeWriter != nil {
+			if r.restoredTimer != nil {
+				r.restoredTimer.Stop()
+			}
+			<-r.storeWriter.done
+		}
 		if r.metricsTracer != nil {
 			r.metricsTracer.RelayStatus(false)
 		}
@@ -183,12 +247,14 @@ func (r *Relay) handleReserve(s network.Stream) pbv2.Status {
 
 	if isRelayAddr(a) {
 		log.Debugf("refusing relay reservation for %s; reservation attempt over relay connection")
+		r.reject(true, RejectRelayedConnection)
 		r.handleError(s, pbv2.Status_PERMISSION_DENIED)
 		return pbv2.Status_PERMISSION_DENIED
 	}
 
 	if r.acl != nil && !r.acl.AllowReserve(p, a) {
 		log.Debugf("refusing relay reservation for %s; permission denied", p)
+		r.reject(true, RejectPermissionDenied)
 		r.handleError(s, pbv2.Status_PERMISSION_DENIED)
 		return pbv2.Status_PERMISSION_DENIED
 	}
@@ -199,26 +265,37 @@ func (r *Relay) handleReserve(s network.Stream) pbv2.Status {
 	if r.closed {
 		r.mx.Unlock()
 		log.Debugf("refusing relay reservation for %s; relay closed", p)
+		r.reject(true, RejectRelayClosed)
 		r.handleError(s, pbv2.Status_PERMISSION_DENIED)
 		return pbv2.Status_PERMISSION_DENIED
 	}
-	now := time.Now()
+	now := r.clock.Now()
 	expire := now.Add(r.rc.ReservationTTL)
 
 	_, exists := r.rsvp[p]
 	if err := r.constraints.Reserve(p, a, expire); err != nil {
 		r.mx.Unlock()
 		log.Debugf("refusing relay reservation for %s; IP constraint violation: %s", p, err)
+		r.reject(true, constraintRejection(err))
 		r.handleError(s, pbv2.Status_RESERVATION_REFUSED)
 		return pbv2.Status_RESERVATION_REFUSED
 	}
 
 	r.rsvp[p] = expire
+	if !exists {
+		r.rsvpSince[p] = now
+	}
 	r.host.ConnManager().TagPeer(p, "relay-reservation", ReservationTagWeight)
+	if r.storeWriter != nil {
+		r.storeWriter.put(Reservation{Peer: p, Addr: a, Expiry: expire})
+	}
 	r.mx.Unlock()
 	if r.metricsTracer != nil {
 		r.metricsTracer.ReservationAllowed(exists)
 	}
+	if r.hooks != nil {
+		r.hooks.ReservationCreated(ReservationInfo{Peer: p, Addr: a, Expiry: expire, Renewal: exists})
+	}
 
 	log.Debugf("reserving relay slot for %s", p)
 
@@ -239,6 +316,52 @@ func (r *Relay) handleReserve(s network.Stream) pbv2.Status {
 	return pbv2.Status_OK
 }
 
+// handleStatus answers a status query on the proto.ProtoIDRelayStatus
+// protocol, which lets a client check whether the relay would currently accept
+// its reservation before asking for one.
+// The relay responds with OK and its limits if it has room for the peer, and
+// with the status a reservation request would fail with otherwise.
+// Nothing is reserved.
+func (r *Relay) handleStatus(s network.Stream) {
+	if err := s.Scope().SetService(ServiceName); err != nil {
+		log.Debugf("error attaching stream to relay service: %s", err)
+		s.Reset()
+		return
+	}
+
+	p := s.Conn().RemotePeer()
+	a := s.Conn().RemoteMultiaddr()
+
+	if isRelayAddr(a) {
+		r.handleError(s, pbv2.Status_PERMISSION_DENIED)
+		return
+	}
+	if r.acl != nil && !r.acl.AllowReserve(p, a) {
+		r.handleError(s, pbv2.Status_PERMISSION_DENIED)
+		return
+	}
+
+	r.mx.Lock()
+	closed := r.closed
+	r.mx.Unlock()
+	if closed {
+		r.handleError(s, pbv2.Status_PERMISSION_DENIED)
+		return
+	}
+	if err := r.constraints.CanReserve(p, a); err != nil {
+		log.Debugf("relay status for %s: no room for a reservation: %s", p, err)
+		r.handleError(s, pbv2.Status_RESERVATION_REFUSED)
+		return
+	}
+
+	if err := r.writeResponse(s, pbv2.Status_OK, nil, r.makeLimitMsg(p)); err != nil {
+		log.Debugf("error writing relay status response: %s", err)
+		s.Reset()
+		return
+	}
+	s.Close()
+}
+
 func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Status {
 	src := s.Conn().RemotePeer()
 	a := s.Conn().RemoteMultiaddr()
@@ -246,6 +369,7 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 	span, err := r.scope.BeginSpan()
 	if err != nil {
 		log.Debugf("failed to begin relay transaction: %s", err)
+		r.reject(false, RejectResourceLimit)
 		r.handleError(s, pbv2.Status_RESOURCE_LIMIT_EXCEEDED)
 		return pbv2.Status_RESOURCE_LIMIT_EXCEEDED
 	}
@@ -258,70 +382,118 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 	// reserve buffers for the relay
 	if err := span.ReserveMemory(2*r.rc.BufferSize, network.ReservationPriorityHigh); err != nil {
 		log.Debugf("error reserving memory for relay: %s", err)
+		r.reject(false, RejectResourceLimit)
 		fail(pbv2.Status_RESOURCE_LIMIT_EXCEEDED)
 		return pbv2.Status_RESOURCE_LIMIT_EXCEEDED
 	}
 
 	if isRelayAddr(a) {
 		log.Debugf("refusing connection from %s; connection attempt over relay connection")
+		r.reject(false, RejectRelayedConnection)
 		fail(pbv2.Status_PERMISSION_DENIED)
 		return pbv2.Status_PERMISSION_DENIED
 	}
 
 	dest, err := util.PeerToPeerInfoV2(msg.GetPeer())
 	if err != nil {
+		r.reject(false, RejectMalformedMessage)
 		fail(pbv2.Status_MALFORMED_MESSAGE)
 		return pbv2.Status_MALFORMED_MESSAGE
 	}
 
 	if r.acl != nil && !r.acl.AllowConnect(src, s.Conn().RemoteMultiaddr(), dest.ID) {
 		log.Debugf("refusing connection from %s to %s; permission denied", src, dest.ID)
+		r.reject(false, RejectPermissionDenied)
 		fail(pbv2.Status_PERMISSION_DENIED)
 		return pbv2.Status_PERMISSION_DENIED
 	}
 
+	var quotaKeys []quotaKey
+	if r.quotas != nil {
+		quotaKeys = r.quotas.keys(nil, src, a)
+		if !r.quotas.allowed(quotaKeys) {
+			log.Debugf("refusing connection from %s to %s; quota exceeded", src, dest.ID)
+			r.reject(false, RejectQuotaExceeded)
+			fail(pbv2.Status_RESOURCE_LIMIT_EXCEEDED)
+			return pbv2.Status_RESOURCE_LIMIT_EXCEEDED
+		}
+	}
+
 	r.mx.Lock()
 	_, rsvp := r.rsvp[dest.ID]
 	if !rsvp {
 		r.mx.Unlock()
 		log.Debugf("refusing connection from %s to %s; no reservation", src, dest.ID)
+		r.reject(false, RejectNoReservation)
 		fail(pbv2.Status_NO_RESERVATION)
 		return pbv2.Status_NO_RESERVATION
 	}
 
-	srcConns := r.conns[src]
-	if srcConns >= r.rc.MaxCircuits {
-		r.mx.Unlock()
-		log.Debugf("refusing connection from %s to %s; too many connections from %s", src, dest.ID, src)
-		fail(pbv2.Status_RESOURCE_LIMIT_EXCEEDED)
-		return pbv2.Status_RESOURCE_LIMIT_EXCEEDED
-	}
+	req := CircuitRequest{Src: src, SrcAddr: a, Dest: dest.ID, DestReservedSince: r.rsvpSince[dest.ID]}
 
-	destConns := r.conns[dest.ID]
-	if destConns >= r.rc.MaxCircuits {
-		r.mx.Unlock()
-		log.Debugf("refusing connection from %s to %s; too many connections to %s", src, dest.ID, dest.ID)
-		fail(pbv2.Status_RESOURCE_LIMIT_EXCEEDED)
-		return pbv2.Status_RESOURCE_LIMIT_EXCEEDED
+	// The circuits counting towards the limits the new circuit would exceed.
+	var limits []func(*activeCircuit) bool
+	reason := RejectTooManyCircuits
+	if r.conns[src] >= r.rc.MaxCircuits {
+		log.Debugf("connection from %s to %s exceeds the limit of connections from %s", src, dest.ID, src)
+		limits = append(limits, involves(src))
+	}
+	if r.conns[dest.ID] >= r.rc.MaxCircuits {
+		log.Debugf("connection from %s to %s exceeds the limit of connections to %s", src, dest.ID, dest.ID)
+		limits = append(limits, involves(dest.ID))
+	}
+	if r.rc.MaxTotalCircuits > 0 && r.numCircuits >= r.rc.MaxTotalCircuits {
+		log.Debugf("connection from %s to %s exceeds the limit of connections of the relay", src, dest.ID)
+		if len(limits) == 0 {
+			reason = RejectRelayFull
+		}
+		limits = append(limits, func(*activeCircuit) bool { return true })
+	}
+	var preempt *activeCircuit
+	if len(limits) > 0 {
+		var contested bool
+		preempt, contested = r.preemptableLocked(req, limits)
+		if preempt == nil {
+			r.mx.Unlock()
+			if contested {
+				reason = RejectLowerPriority
+			}
+			log.Debugf("refusing connection from %s to %s; %s", src, dest.ID, reason)
+			r.reject(false, reason)
+			fail(pbv2.Status_RESOURCE_LIMIT_EXCEEDED)
+			return pbv2.Status_RESOURCE_LIMIT_EXCEEDED
+		}
+		preempt.preempted = true
+		r.preempted++
 	}
 
 	r.addConn(src)
 	r.addConn(dest.ID)
+	r.numCircuits++
 	r.mx.Unlock()
 
+	if preempt != nil {
+		log.Debugf("closing connection from %s to %s for a connection from %s to %s with a higher priority",
+			preempt.info.Src, preempt.info.Dest, src, dest.ID)
+		preempt.reset()
+	}
+
 	if r.metricsTracer != nil {
 		r.metricsTracer.ConnectionOpened()
 	}
-	connStTime := time.Now()
+	connStTime := r.clock.Now()
+	circuit := CircuitInfo{Src: src, SrcAddr: a, Dest: dest.ID, Opened: connStTime}
+	ac := &activeCircuit{info: circuit, req: req}
 
 	cleanup := func() {
 		defer span.Done()
 		r.mx.Lock()
 		r.rmConn(src)
 		r.rmConn(dest.ID)
+		r.numCircuits--
 		r.mx.Unlock()
 		if r.metricsTracer != nil {
-			r.metricsTracer.ConnectionClosed(time.Since(connStTime))
+			r.metricsTracer.ConnectionClosed(r.clock.Since(connStTime))
 		}
 	}
 
@@ -346,13 +518,25 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 
 	if err := bs.Scope().SetService(ServiceName); err != nil {
 		log.Debugf("error attaching stream to relay service: %s", err)
+		r.reject(false, RejectResourceLimit)
 		fail(pbv2.Status_RESOURCE_LIMIT_EXCEEDED)
 		return pbv2.Status_RESOURCE_LIMIT_EXCEEDED
 	}
 
+	if r.quotas != nil {
+		quotaKeys = r.quotas.keys(quotaKeys, dest.ID, bs.Conn().RemoteMultiaddr())
+		if !r.quotas.allowed(quotaKeys) {
+			log.Debugf("refusing connection from %s to %s; quota exceeded", src, dest.ID)
+			r.reject(false, RejectQuotaExceeded)
+			fail(pbv2.Status_RESOURCE_LIMIT_EXCEEDED)
+			return pbv2.Status_RESOURCE_LIMIT_EXCEEDED
+		}
+	}
+
 	// handshake
 	if err := bs.Scope().ReserveMemory(maxMessageSize, network.ReservationPriorityAlways); err != nil {
 		log.Debugf("error reserving memory for stream: %s", err)
+		r.reject(false, RejectResourceLimit)
 		fail(pbv2.Status_RESOURCE_LIMIT_EXCEEDED)
 		return pbv2.Status_RESOURCE_LIMIT_EXCEEDED
 	}
@@ -416,6 +600,16 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 	bs.SetDeadline(time.Time{})
 
 	log.Infof("relaying connection from %s to %s", src, dest.ID)
+	ac.reset = func() {
+		s.Reset()
+		bs.Reset()
+	}
+	r.mx.Lock()
+	r.circuits[ac] = struct{}{}
+	r.mx.Unlock()
+	if r.hooks != nil {
+		r.hooks.CircuitOpened(circuit)
+	}
 
 	var goroutines atomic.Int32
 	goroutines.Store(2)
@@ -424,7 +618,17 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 		if goroutines.Add(-1) == 0 {
 			s.Close()
 			bs.Close()
+			r.mx.Lock()
+			delete(r.circuits, ac)
+			r.mx.Unlock()
 			cleanup()
+			if r.hooks != nil {
+				r.hooks.CircuitClosed(circuit, CircuitUsage{
+					Duration:       r.clock.Since(connStTime),
+					BytesSrcToDest: ac.srcToDest.Load(),
+					BytesDestToSrc: ac.destToSrc.Load(),
+				})
+			}
 		}
 	}
 
@@ -432,11 +636,11 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 		deadline := time.Now().Add(r.rc.Limit.Duration)
 		s.SetDeadline(deadline)
 		bs.SetDeadline(deadline)
-		go r.relayLimited(s, bs, src, dest.ID, r.rc.Limit.Data, done)
-		go r.relayLimited(bs, s, dest.ID, src, r.rc.Limit.Data, done)
+		go r.relayLimited(s, bs, src, dest.ID, r.rc.Limit.Data, quotaKeys, ac, true, done)
+		go r.relayLimited(bs, s, dest.ID, src, r.rc.Limit.Data, quotaKeys, ac, false, done)
 	} else {
-		go r.relayUnlimited(s, bs, src, dest.ID, done)
-		go r.relayUnlimited(bs, s, dest.ID, src, done)
+		go r.relayUnlimited(s, bs, src, dest.ID, quotaKeys, ac, true, done)
+		go r.relayUnlimited(bs, s, dest.ID, src, quotaKeys, ac, false, done)
 	}
 
 	return pbv2.Status_OK
@@ -462,7 +666,9 @@ func (r *Relay) rmConn(p peer.ID) {
 	}
 }
 
-func (r *Relay) relayLimited(src, dest network.Stream, srcID, destID peer.ID, limit int64, done func()) {
+// relayLimited relays up to limit bytes from src to dest, accounting them to c
+// in the given direction, and calls done.
+func (r *Relay) relayLimited(src, dest network.Stream, srcID, destID peer.ID, limit int64, quotaKeys []quotaKey, c *activeCircuit, srcToDest bool, done func()) {
 	defer done()
 
 	buf := pool.Get(r.rc.BufferSize)
@@ -470,7 +676,7 @@ func (r *Relay) relayLimited(src, dest network.Stream, srcID, destID peer.ID, li
 
 	limitedSrc := io.LimitReader(src, limit)
 
-	count, err := r.copyWithBuffer(dest, limitedSrc, buf)
+	count, err := r.copyWithBuffer(r.quotaWriter(dest, srcID, quotaKeys), limitedSrc, buf, c, srcToDest)
 	if err != nil {
 		log.Debugf("relay copy error: %s", err)
 		// Reset both.
@@ -488,13 +694,15 @@ func (r *Relay) relayLimited(src, dest network.Stream, srcID, destID peer.ID, li
 	log.Debugf("relayed %d bytes from %s to %s", count, srcID, destID)
 }
 
-func (r *Relay) relayUnlimited(src, dest network.Stream, srcID, destID peer.ID, done func()) {
+// relayUnlimited relays from src to dest, accounting the bytes to c in the
+// given direction, and calls done.
+func (r *Relay) relayUnlimited(src, dest network.Stream, srcID, destID peer.ID, quotaKeys []quotaKey, c *activeCircuit, srcToDest bool, done func()) {
 	defer done()
 
 	buf := pool.Get(r.rc.BufferSize)
 	defer pool.Put(buf)
 
-	count, err := r.copyWithBuffer(dest, src, buf)
+	count, err := r.copyWithBuffer(r.quotaWriter(dest, srcID, quotaKeys), src, buf, c, srcToDest)
 	if err != nil {
 		log.Debugf("relay copy error: %s", err)
 		// Reset both.
@@ -508,14 +716,24 @@ func (r *Relay) relayUnlimited(src, dest network.Stream, srcID, destID peer.ID,
 	log.Debugf("relayed %d bytes from %s to %s", count, srcID, destID)
 }
 
+// quotaWriter returns dest, accounting the data written to it against the
+// quotas if they are enabled.
+func (r *Relay) quotaWriter(dest io.Writer, srcID peer.ID, quotaKeys []quotaKey) io.Writer {
+	if r.quotas == nil || len(quotaKeys) == 0 {
+		return dest
+	}
+	return &quotaWriter{Writer: dest, t: r.quotas, keys: quotaKeys, peer: srcID}
+}
+
 // errInvalidWrite means that a write returned an impossible count.
 // copied from io.errInvalidWrite
 var errInvalidWrite = errors.New("invalid write result")
 
 // copyWithBuffer copies from src to dst using the provided buf until either EOF is reached
-// on src or an error occurs. It reports the number of bytes transferred to metricsTracer.
+// on src or an error occurs. It accounts the bytes transferred to c and the relay, and
+// reports them to metricsTracer.
 // The implementation is a modified form of io.CopyBuffer to support metrics tracking.
-func (r *Relay) copyWithBuffer(dst io.Writer, src io.Reader, buf []byte) (written int64, err error) {
+func (r *Relay) copyWithBuffer(dst io.Writer, src io.Reader, buf []byte, c *activeCircuit, srcToDest bool) (written int64, err error) {
 	for {
 		nr, er := src.Read(buf)
 		if nr > 0 {
@@ -527,6 +745,20 @@ func (r *Relay) copyWithBuffer(dst io.Writer, src io.Reader, buf []byte) (writte
 				}
 			}
 			written += int64(nw)
+			if srcToDest {
+				c.srcToDest.Add(int64(nw))
+				r.bytesSrcToDest.Add(int64(nw))
+			} else {
+				c.destToSrc.Add(int64(nw))
+				r.bytesDestToSrc.Add(int64(nw))
+			}
+			if nw > 0 {
+				if r.extMetricsTracer != nil {
+					r.extMetricsTracer.BytesRelayed(srcToDest, nw)
+				} else if r.metricsTracer != nil {
+					r.metricsTracer.BytesTransferred(nw)
+				}
+			}
 			if ew != nil {
 				err = ew
 				break
@@ -535,9 +767,6 @@ func (r *Relay) copyWithBuffer(dst io.Writer, src io.Reader, buf []byte) (writte
 				err = io.ErrShortWrite
 				break
 			}
-			if r.metricsTracer != nil {
-				r.metricsTracer.BytesTransferred(nw)
-			}
 		}
 		if er != nil {
 			if er != io.EOF {
@@ -668,19 +897,28 @@ func (r *Relay) background() {
 
 func (r *Relay) gc() {
 	r.mx.Lock()
-	defer r.mx.Unlock()
 
-	now := time.Now()
-	cnt := 0
+	now := r.clock.Now()
+	reason := ReservationExpired
+	if r.closed {
+		reason = ReservationRelayClosed
+	}
+	var ended []peer.ID
 	for p, expire := range r.rsvp {
 		if r.closed || expire.Before(now) {
 			delete(r.rsvp, p)
+			delete(r.rsvpSince, p)
 			r.host.ConnManager().UntagPeer(p, "relay-reservation")
-			cnt++
+			ended = append(ended, p)
+			// Keep the reservations of a closed relay, so that they are
+			// restored when it is started again.
+			if !r.closed {
+				r.deleteStored(p)
+			}
 		}
 	}
 	if r.metricsTracer != nil {
-		r.metricsTracer.ReservationClosed(cnt)
+		r.metricsTracer.ReservationClosed(len(ended))
 	}
 
 	for p, count := range r.conns {
@@ -688,6 +926,17 @@ func (r *Relay) gc() {
 			delete(r.conns, p)
 		}
 	}
+	r.mx.Unlock()
+
+	if r.quotas != nil {
+		r.quotas.gc(now)
+	}
+
+	if r.hooks != nil {
+		for _, p := range ended {
+			r.hooks.ReservationEnded(p, reason)
+		}
+	}
 }
 
 func (r *Relay) disconnected(n network.Network, c network.Conn) {
@@ -700,6 +949,8 @@ func (r *Relay) disconnected(n network.Network, c network.Conn) {
 	_, ok := r.rsvp[p]
 	if ok {
 		delete(r.rsvp, p)
+		delete(r.rsvpSince, p)
+		r.deleteStored(p)
 	}
 	r.constraints.cleanupPeer(p)
 	r.mx.Unlock()
@@ -707,6 +958,99 @@ func (r *Relay) disconnected(n network.Network, c network.Conn) {
 	if ok && r.metricsTracer != nil {
 		r.metricsTracer.ReservationClosed(1)
 	}
+	if ok && r.hooks != nil {
+		r.hooks.ReservationEnded(p, ReservationDisconnected)
+	}
+}
+
+// restoreReservations loads the reservations persisted in the store. They are
+// honored right away, without waiting for the peers to renew them, but
+// dropped after RestoredReservationTimeout if the peers haven't reconnected.
+func (r *Relay) restoreReservations() error {
+	rsvps, err := r.store.Load()
+	if err != nil {
+		return err
+	}
+	now := r.clock.Now()
+	var restored []ReservationInfo
+	r.mx.Lock()
+	for _, rsvp := range rsvps {
+		if rsvp.Expiry.Before(now) {
+			r.deleteStored(rsvp.Peer)
+			continue
+		}
+		if err := r.constraints.Reserve(rsvp.Peer, rsvp.Addr, rsvp.Expiry); err != nil {
+			log.Debugf("dropping persisted reservation for %s: %s", rsvp.Peer, err)
+			r.deleteStored(rsvp.Peer)
+			continue
+		}
+		r.rsvp[rsvp.Peer] = rsvp.Expiry
+		r.rsvpSince[rsvp.Peer] = now
+		r.host.ConnManager().TagPeer(rsvp.Peer, "relay-reservation", ReservationTagWeight)
+		restored = append(restored, ReservationInfo{Peer: rsvp.Peer, Addr: rsvp.Addr, Expiry: rsvp.Expiry, Restored: true})
+	}
+	for _, info := range restored {
+		r.restored = append(r.restored, info.Peer)
+	}
+	if len(restored) > 0 {
+		r.restoredTimer = r.clock.AfterFunc(RestoredReservationTimeout, r.dropAbsentRestored)
+	}
+	r.mx.Unlock()
+
+	log.Debugf("restored %d relay reservations", len(restored))
+	for _, info := range restored {
+		if r.metricsTracer != nil {
+			r.metricsTracer.ReservationAllowed(false)
+		}
+		if r.hooks != nil {
+			r.hooks.ReservationCreated(info)
+		}
+	}
+	return nil
+}
+
+// dropAbsentRestored drops the restored reservations of peers that haven't
+// reconnected, so that they don't hold slots for peers that may never return.
+// Reservations of peers that reconnected and disconnected again were already
+// dropped when they disconnected.
+func (r *Relay) dropAbsentRestored() {
+	r.mx.Lock()
+	if r.closed {
+		r.mx.Unlock()
+		return
+	}
+	var dropped []peer.ID
+	for _, p := range r.restored {
+		if _, ok := r.rsvp[p]; !ok || r.host.Network().Connectedness(p) == network.Connected {
+			continue
+		}
+		delete(r.rsvp, p)
+		delete(r.rsvpSince, p)
+		r.host.ConnManager().UntagPeer(p, "relay-reservation")
+		r.constraints.cleanupPeer(p)
+		r.deleteStored(p)
+		dropped = append(dropped, p)
+	}
+	r.restored = nil
+	r.mx.Unlock()
+
+	log.Debugf("dropped %d restored relay reservations of absent peers", len(dropped))
+	if r.metricsTracer != nil && len(dropped) > 0 {
+		r.metricsTracer.ReservationClosed(len(dropped))
+	}
+	if r.hooks != nil {
+		for _, p := range dropped {
+			r.hooks.ReservationEnded(p, ReservationDisconnected)
+		}
+	}
+}
+
+// deleteStored removes the reservation of p from the store, if any.
+func (r *Relay) deleteStored(p peer.ID) {
+	if r.storeWriter == nil {
+		return
+	}
+	r.storeWriter.delete(p)
 }
 
 func isRelayAddr(a ma.Multiaddr) bool {
@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"context"
 	"crypto/rand"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -23,10 +26,13 @@ import (
 	"github.com/libp2p/go-libp2p/p2p/net/swarm"
 	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
 	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/client"
+	pbv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/pb"
+	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/proto"
 	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
 	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
 	"github.com/stretchr/testify/require"
 
+	"github.com/benbjohnson/clock"
 	ma "github.com/multiformats/go-multiaddr"
 )
 
@@ -382,3 +388,490 @@ func TestRelayLimitData(t *testing.T) {
 	}
 
 }
+
+func TestRelayHooks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts, upgraders := getNetHosts(t, ctx, 3)
+	addTransport(t, hosts[0], upgraders[0])
+	addTransport(t, hosts[2], upgraders[2])
+
+	hosts[0].SetStreamHandler("test", func(s network.Stream) {
+		defer s.Close()
+		io.Copy(io.Discard, s)
+	})
+
+	created := make(chan relay.ReservationInfo, 1)
+	ended := make(chan relay.ReservationEndReason, 1)
+	opened := make(chan relay.CircuitInfo, 1)
+	closed := make(chan relay.CircuitUsage, 1)
+	r, err := relay.New(hosts[1], relay.WithHooks(&relay.HooksBundle{
+		ReservationCreatedF: func(info relay.ReservationInfo) { created <- info },
+		ReservationEndedF:   func(_ peer.ID, reason relay.ReservationEndReason) { ended <- reason },
+		CircuitOpenedF:      func(info relay.CircuitInfo) { opened <- info },
+		CircuitClosedF:      func(_ relay.CircuitInfo, usage relay.CircuitUsage) { closed <- usage },
+	}))
+	require.NoError(t, err)
+	defer r.Close()
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+
+	rinfo := hosts[1].Peerstore().PeerInfo(hosts[1].ID())
+	_, err = client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+	info := <-created
+	require.Equal(t, hosts[0].ID(), info.Peer)
+	require.False(t, info.Renewal)
+
+	raddr := ma.StringCast(fmt.Sprintf("/p2p/%s/p2p-circuit/p2p/%s", hosts[1].ID(), hosts[0].ID()))
+	require.NoError(t, hosts[2].Connect(ctx, peer.AddrInfo{ID: hosts[0].ID(), Addrs: []ma.Multiaddr{raddr}}))
+	circuit := <-opened
+	require.Equal(t, hosts[2].ID(), circuit.Src)
+	require.Equal(t, hosts[0].ID(), circuit.Dest)
+
+	s, err := hosts[2].NewStream(network.WithAllowLimitedConn(ctx, "test"), hosts[0].ID(), "test")
+	require.NoError(t, err)
+	msg := []byte("relay works!")
+	_, err = s.Write(msg)
+	require.NoError(t, err)
+	require.NoError(t, s.CloseWrite())
+	_, err = io.ReadAll(s)
+	require.NoError(t, err)
+
+	require.NoError(t, hosts[2].Network().ClosePeer(hosts[0].ID()))
+	select {
+	case usage := <-closed:
+		require.Greater(t, usage.BytesSrcToDest, int64(len(msg)))
+		require.Positive(t, usage.BytesDestToSrc)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the circuit to be closed")
+	}
+
+	r.Close()
+	require.Equal(t, relay.ReservationRelayClosed, <-ended)
+}
+
+func TestRelayReservationStore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts, upgraders := getNetHosts(t, ctx, 3)
+	addTransport(t, hosts[0], upgraders[0])
+	addTransport(t, hosts[2], upgraders[2])
+
+	hosts[0].SetStreamHandler("test", func(s network.Stream) {
+		defer s.Close()
+		io.Copy(s, s)
+	})
+
+	store, err := relay.NewFileReservationStore(t.TempDir())
+	require.NoError(t, err)
+	r, err := relay.New(hosts[1], relay.WithReservationStore(store))
+	require.NoError(t, err)
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+
+	rinfo := hosts[1].Peerstore().PeerInfo(hosts[1].ID())
+	rsvp, err := client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+
+	var stored []relay.Reservation
+	require.Eventually(t, func() bool {
+		stored, err = store.Load()
+		return err == nil && len(stored) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, hosts[0].ID(), stored[0].Peer)
+	require.Equal(t, rsvp.Expiration.Unix(), stored[0].Expiry.Unix())
+
+	// restart the relay, the reservation is honored without being renewed
+	require.NoError(t, r.Close())
+	restored := make(chan relay.ReservationInfo, 1)
+	r, err = relay.New(hosts[1], relay.WithReservationStore(store), relay.WithHooks(&relay.HooksBundle{
+		ReservationCreatedF: func(info relay.ReservationInfo) { restored <- info },
+	}))
+	require.NoError(t, err)
+	defer r.Close()
+	select {
+	case info := <-restored:
+		require.Equal(t, hosts[0].ID(), info.Peer)
+		require.True(t, info.Restored)
+	default:
+		t.Fatal("expected the reservation to be restored")
+	}
+
+	raddr := ma.StringCast(fmt.Sprintf("/p2p/%s/p2p-circuit/p2p/%s", hosts[1].ID(), hosts[0].ID()))
+	require.NoError(t, hosts[2].Connect(ctx, peer.AddrInfo{ID: hosts[0].ID(), Addrs: []ma.Multiaddr{raddr}}))
+	s, err := hosts[2].NewStream(network.WithAllowLimitedConn(ctx, "test"), hosts[0].ID(), "test")
+	require.NoError(t, err)
+	_, err = s.Write([]byte("restored"))
+	require.NoError(t, err)
+	require.NoError(t, s.CloseWrite())
+	data, err := io.ReadAll(s)
+	require.NoError(t, err)
+	require.Equal(t, "restored", string(data))
+
+	// the reservation is removed from the store when the peer disconnects
+	require.NoError(t, hosts[1].Network().ClosePeer(hosts[0].ID()))
+	require.Eventually(t, func() bool {
+		stored, err := store.Load()
+		return err == nil && len(stored) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRelayRestoredReservationTimeout(t *testing.T) {
+	timeout := relay.RestoredReservationTimeout
+	relay.RestoredReservationTimeout = 100 * time.Millisecond
+	defer func() { relay.RestoredReservationTimeout = timeout }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts, _ := getNetHosts(t, ctx, 3)
+	store, err := relay.NewFileReservationStore(t.TempDir())
+	require.NoError(t, err)
+	r, err := relay.New(hosts[1], relay.WithReservationStore(store))
+	require.NoError(t, err)
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+	rinfo := hosts[1].Peerstore().PeerInfo(hosts[1].ID())
+	_, err = client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+	_, err = client.Reserve(ctx, hosts[2], rinfo)
+	require.NoError(t, err)
+
+	// hosts[0] goes away while the relay is down
+	require.NoError(t, r.Close())
+	require.NoError(t, hosts[1].Network().ClosePeer(hosts[0].ID()))
+
+	ended := make(chan peer.ID, 2)
+	r, err = relay.New(hosts[1], relay.WithReservationStore(store), relay.WithHooks(&relay.HooksBundle{
+		ReservationEndedF: func(p peer.ID, reason relay.ReservationEndReason) {
+			if reason == relay.ReservationDisconnected {
+				ended <- p
+			}
+		},
+	}))
+	require.NoError(t, err)
+	defer r.Close()
+	require.Len(t, r.Status().Reservations, 2)
+
+	select {
+	case p := <-ended:
+		require.Equal(t, hosts[0].ID(), p)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the reservation of the absent peer to be dropped")
+	}
+	rsvps := r.Status().Reservations
+	require.Len(t, rsvps, 1)
+	require.Equal(t, hosts[2].ID(), rsvps[0].Peer)
+	require.Eventually(t, func() bool {
+		stored, err := store.Load()
+		return err == nil && len(stored) == 1 && stored[0].Peer == hosts[2].ID()
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRelayQuotas(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts, upgraders := getNetHosts(t, ctx, 3)
+	addTransport(t, hosts[0], upgraders[0])
+	addTransport(t, hosts[2], upgraders[2])
+
+	hosts[0].SetStreamHandler("test", func(s network.Stream) {
+		defer s.Close()
+		io.Copy(io.Discard, s)
+	})
+
+	exceeded := make(chan relay.QuotaExceeded, 2)
+	r, err := relay.New(hosts[1], relay.WithInfiniteLimits(), relay.WithQuotas(relay.Quotas{
+		PerPeer:    4096,
+		OnExceeded: func(e relay.QuotaExceeded) { exceeded <- e },
+	}))
+	require.NoError(t, err)
+	defer r.Close()
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+
+	rinfo := hosts[1].Peerstore().PeerInfo(hosts[1].ID())
+	_, err = client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+
+	raddr := ma.StringCast(fmt.Sprintf("/p2p/%s/p2p-circuit/p2p/%s", hosts[1].ID(), hosts[0].ID()))
+	require.NoError(t, hosts[2].Connect(ctx, peer.AddrInfo{ID: hosts[0].ID(), Addrs: []ma.Multiaddr{raddr}}))
+	s, err := hosts[2].NewStream(network.WithAllowLimitedConn(ctx, "test"), hosts[0].ID(), "test")
+	require.NoError(t, err)
+
+	// the circuit is reset once the quota is exceeded
+	buf := make([]byte, 1024)
+	require.Eventually(t, func() bool {
+		_, err := s.Write(buf)
+		return err != nil
+	}, 5*time.Second, time.Millisecond)
+
+	// both ends of the circuit exceeded their quota
+	for i := 0; i < 2; i++ {
+		select {
+		case e := <-exceeded:
+			require.Equal(t, relay.QuotaPeer, e.Kind)
+			require.Equal(t, hosts[2].ID(), e.Peer)
+			require.Contains(t, []string{hosts[0].ID().String(), hosts[2].ID().String()}, e.Key)
+			require.Equal(t, int64(4096), e.Limit)
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the quota to be exceeded")
+		}
+	}
+
+	// new circuits are refused
+	require.NoError(t, hosts[2].Network().ClosePeer(hosts[0].ID()))
+	require.Error(t, hosts[2].Connect(ctx, peer.AddrInfo{ID: hosts[0].ID(), Addrs: []ma.Multiaddr{raddr}}))
+}
+
+func TestRelayStatus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts, upgraders := getNetHosts(t, ctx, 3)
+	addTransport(t, hosts[0], upgraders[0])
+	addTransport(t, hosts[2], upgraders[2])
+
+	hosts[0].SetStreamHandler("test", func(s network.Stream) {
+		defer s.Close()
+		io.Copy(s, s)
+	})
+
+	r, err := relay.New(hosts[1], relay.WithInfiniteLimits())
+	require.NoError(t, err)
+	defer r.Close()
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+
+	// connecting to a peer without a reservation is refused
+	raddr := ma.StringCast(fmt.Sprintf("/p2p/%s/p2p-circuit/p2p/%s", hosts[1].ID(), hosts[0].ID()))
+	require.Error(t, hosts[2].Connect(ctx, peer.AddrInfo{ID: hosts[0].ID(), Addrs: []ma.Multiaddr{raddr}}))
+
+	rinfo := hosts[1].Peerstore().PeerInfo(hosts[1].ID())
+	_, err = client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+
+	hosts[2].Network().(*swarm.Swarm).Backoff().Clear(hosts[0].ID())
+	require.NoError(t, hosts[2].Connect(ctx, peer.AddrInfo{ID: hosts[0].ID(), Addrs: []ma.Multiaddr{raddr}}))
+	s, err := hosts[2].NewStream(network.WithAllowLimitedConn(ctx, "test"), hosts[0].ID(), "test")
+	require.NoError(t, err)
+	msg := []byte("relay status")
+	_, err = s.Write(msg)
+	require.NoError(t, err)
+	_, err = io.ReadFull(s, make([]byte, len(msg)))
+	require.NoError(t, err)
+
+	st := r.Status()
+	require.Len(t, st.Reservations, 1)
+	require.Equal(t, hosts[0].ID(), st.Reservations[0].Peer)
+	require.Len(t, st.Circuits, 1)
+	require.Equal(t, hosts[2].ID(), st.Circuits[0].Src)
+	require.Equal(t, hosts[0].ID(), st.Circuits[0].Dest)
+	require.GreaterOrEqual(t, st.Circuits[0].Usage.BytesSrcToDest, int64(len(msg)))
+	require.GreaterOrEqual(t, st.Circuits[0].Usage.BytesDestToSrc, int64(len(msg)))
+	require.Equal(t, st.Circuits[0].Usage.BytesSrcToDest, st.BytesSrcToDest)
+	require.Equal(t, map[relay.RejectionReason]int64{relay.RejectNoReservation: 1}, st.CircuitRejections)
+	require.Empty(t, st.ReservationRejections)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var decoded struct {
+		Reservations      []struct{ Peer peer.ID }
+		CircuitRejections map[string]int64
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &decoded))
+	require.Len(t, decoded.Reservations, 1)
+	require.Equal(t, hosts[0].ID(), decoded.Reservations[0].Peer)
+	require.Equal(t, int64(1), decoded.CircuitRejections[string(relay.RejectNoReservation)])
+
+	// the circuit is removed once closed
+	s.Close()
+	require.NoError(t, hosts[2].Network().ClosePeer(hosts[0].ID()))
+	require.Eventually(t, func() bool { return len(r.Status().Circuits) == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRelayCircuitPriority(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts, upgraders := getNetHosts(t, ctx, 4)
+	addTransport(t, hosts[0], upgraders[0])
+	addTransport(t, hosts[2], upgraders[2])
+	addTransport(t, hosts[3], upgraders[3])
+
+	rc := relay.DefaultResources()
+	rc.Limit = nil
+	rc.MaxTotalCircuits = 1
+	// circuits to hosts[3] are preferred
+	r, err := relay.New(hosts[1], relay.WithResources(rc), relay.WithCircuitPriority(func(req relay.CircuitRequest) int {
+		if req.Dest == hosts[3].ID() {
+			return 1
+		}
+		return 0
+	}))
+	require.NoError(t, err)
+	defer r.Close()
+
+	rinfo := hosts[1].Peerstore().PeerInfo(hosts[1].ID())
+	for _, h := range []host.Host{hosts[0], hosts[3]} {
+		h.SetStreamHandler("test", func(s network.Stream) {
+			defer s.Close()
+			io.Copy(s, s)
+		})
+		connect(t, h, hosts[1])
+		_, err = client.Reserve(ctx, h, rinfo)
+		require.NoError(t, err)
+	}
+	connect(t, hosts[1], hosts[2])
+
+	circuit := func(dest host.Host) error {
+		raddr := ma.StringCast(fmt.Sprintf("/p2p/%s/p2p-circuit/p2p/%s", hosts[1].ID(), dest.ID()))
+		hosts[2].Network().(*swarm.Swarm).Backoff().Clear(dest.ID())
+		return hosts[2].Connect(ctx, peer.AddrInfo{ID: dest.ID(), Addrs: []ma.Multiaddr{raddr}})
+	}
+
+	require.NoError(t, circuit(hosts[0]))
+	s, err := hosts[2].NewStream(network.WithAllowLimitedConn(ctx, "test"), hosts[0].ID(), "test")
+	require.NoError(t, err)
+	_, err = s.Write([]byte("foo"))
+	require.NoError(t, err)
+	_, err = io.ReadFull(s, make([]byte, 3))
+	require.NoError(t, err)
+
+	// the circuit to hosts[3] preempts the circuit to hosts[0]
+	require.NoError(t, circuit(hosts[3]))
+	_, err = io.ReadAll(s)
+	require.Error(t, err)
+	require.Eventually(t, func() bool {
+		st := r.Status()
+		return len(st.Circuits) == 1 && st.Circuits[0].Dest == hosts[3].ID()
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, int64(1), r.Status().PreemptedCircuits)
+
+	// but not the other way around
+	require.NoError(t, hosts[2].Network().ClosePeer(hosts[0].ID()))
+	require.Error(t, circuit(hosts[0]))
+	st := r.Status()
+	require.Equal(t, int64(1), st.PreemptedCircuits)
+	require.Equal(t, int64(1), st.CircuitRejections[relay.RejectLowerPriority])
+}
+
+func TestRelayReservationAgePriority(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts, upgraders := getNetHosts(t, ctx, 5)
+	for _, i := range []int{0, 2, 3, 4} {
+		addTransport(t, hosts[i], upgraders[i])
+	}
+
+	rc := relay.DefaultResources()
+	rc.Limit = nil
+	rc.MaxCircuits = 2
+	cl := clock.NewMock()
+	cl.Set(time.Now())
+	r, err := relay.New(hosts[1], relay.WithResources(rc), relay.WithCircuitPriority(relay.ReservationAgePriority), relay.WithClock(cl))
+	require.NoError(t, err)
+	defer r.Close()
+
+	connect(t, hosts[0], hosts[1])
+	rinfo := hosts[1].Peerstore().PeerInfo(hosts[1].ID())
+	_, err = client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+
+	circuit := func(src host.Host) error {
+		connect(t, src, hosts[1])
+		raddr := ma.StringCast(fmt.Sprintf("/p2p/%s/p2p-circuit/p2p/%s", hosts[1].ID(), hosts[0].ID()))
+		return src.Connect(ctx, peer.AddrInfo{ID: hosts[0].ID(), Addrs: []ma.Multiaddr{raddr}})
+	}
+	require.NoError(t, circuit(hosts[2]))
+	// the reservation ages, but all circuits to hosts[0] share its age
+	cl.Add(time.Second)
+	require.NoError(t, circuit(hosts[3]))
+	cl.Add(time.Second)
+	require.Error(t, circuit(hosts[4]))
+
+	st := r.Status()
+	require.Zero(t, st.PreemptedCircuits)
+	require.Equal(t, int64(1), st.CircuitRejections[relay.RejectLowerPriority])
+	require.Len(t, st.Circuits, 2)
+}
+
+func TestRelayFull(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts, upgraders := getNetHosts(t, ctx, 4)
+	addTransport(t, hosts[0], upgraders[0])
+	addTransport(t, hosts[2], upgraders[2])
+	addTransport(t, hosts[3], upgraders[3])
+
+	rc := relay.DefaultResources()
+	rc.Limit = nil
+	rc.MaxTotalCircuits = 1
+	r, err := relay.New(hosts[1], relay.WithResources(rc))
+	require.NoError(t, err)
+	defer r.Close()
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+	connect(t, hosts[1], hosts[3])
+	rinfo := hosts[1].Peerstore().PeerInfo(hosts[1].ID())
+	_, err = client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+
+	// without priorities, circuits are admitted first-come-first-served
+	raddr := ma.StringCast(fmt.Sprintf("/p2p/%s/p2p-circuit/p2p/%s", hosts[1].ID(), hosts[0].ID()))
+	require.NoError(t, hosts[2].Connect(ctx, peer.AddrInfo{ID: hosts[0].ID(), Addrs: []ma.Multiaddr{raddr}}))
+	require.Error(t, hosts[3].Connect(ctx, peer.AddrInfo{ID: hosts[0].ID(), Addrs: []ma.Multiaddr{raddr}}))
+	st := r.Status()
+	require.Zero(t, st.PreemptedCircuits)
+	require.Equal(t, map[relay.RejectionReason]int64{relay.RejectRelayFull: 1}, st.CircuitRejections)
+}
+
+func TestRelayStatusQuery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts, _ := getNetHosts(t, ctx, 3)
+
+	rc := relay.DefaultResources()
+	rc.MaxReservations = 1
+	r, err := relay.New(hosts[1], relay.WithResources(rc))
+	require.NoError(t, err)
+	defer r.Close()
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+	rinfo := hosts[1].Peerstore().PeerInfo(hosts[1].ID())
+
+	require.NoError(t, client.QueryStatus(ctx, hosts[0], hosts[1].ID()))
+	require.NoError(t, client.QueryStatus(ctx, hosts[2], hosts[1].ID()))
+	_, err = client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+
+	// the reservation holder can still refresh, but there's no room for anyone else
+	require.NoError(t, client.QueryStatus(ctx, hosts[0], hosts[1].ID()))
+	err = client.QueryStatus(ctx, hosts[2], hosts[1].ID())
+	var rerr client.ReservationError
+	require.ErrorAs(t, err, &rerr)
+	require.Equal(t, pbv2.Status_RESERVATION_REFUSED, rerr.Status)
+	// a status query doesn't count as a rejected reservation
+	require.Empty(t, r.Status().ReservationRejections)
+
+	// relays without status queries have an unknown capacity
+	hosts[1].RemoveStreamHandler(proto.ProtoIDRelayStatus)
+	err = client.QueryStatus(ctx, hosts[2], hosts[1].ID())
+	require.Error(t, err)
+	require.False(t, errors.As(err, &rerr))
+}
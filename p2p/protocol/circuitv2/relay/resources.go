@@ -17,6 +17,9 @@ type Resources struct {
 	MaxReservations int
 	// MaxCircuits is the maximum number of open relay connections for each peer; defaults to 16.
 	MaxCircuits int
+	// MaxTotalCircuits is the maximum number of open relay connections of the relay; defaults
+	// to 0, meaning no limit.
+	MaxTotalCircuits int
 	// BufferSize is the size of the relayed connection buffers; defaults to 2048.
 	BufferSize int
 
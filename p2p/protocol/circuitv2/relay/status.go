@@ -0,0 +1,164 @@
+package relay
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// RejectionReason is the reason a reservation or connection request was
+// refused.
+type RejectionReason string
+
+const (
+	RejectRelayedConnection   RejectionReason = "relayed connection"
+	RejectPermissionDenied    RejectionReason = "permission denied"
+	RejectRelayClosed         RejectionReason = "relay closed"
+	RejectTooManyReservations RejectionReason = "too many reservations"
+	RejectTooManyForIP        RejectionReason = "too many reservations for ip"
+	RejectTooManyForASN       RejectionReason = "too many reservations for asn"
+	RejectConstraintViolation RejectionReason = "constraint violation"
+	RejectResourceLimit       RejectionReason = "resource limit exceeded"
+	RejectMalformedMessage    RejectionReason = "malformed message"
+	RejectNoReservation       RejectionReason = "no reservation"
+	RejectTooManyCircuits     RejectionReason = "too many circuits"
+	RejectQuotaExceeded       RejectionReason = "quota exceeded"
+	RejectRelayFull           RejectionReason = "relay full"
+	RejectLowerPriority       RejectionReason = "lower priority"
+)
+
+func constraintRejection(err error) RejectionReason {
+	switch err {
+	case errTooManyReservations:
+		return RejectTooManyReservations
+	case errTooManyReservationsForIP:
+		return RejectTooManyForIP
+	case errTooManyReservationsForASN:
+		return RejectTooManyForASN
+	default:
+		return RejectConstraintViolation
+	}
+}
+
+// Status is a snapshot of the state of the relay.
+type Status struct {
+	Reservations []ReservationStatus
+	Circuits     []CircuitStatus
+	// BytesSrcToDest and BytesDestToSrc are the bytes relayed in each
+	// direction since the relay was started, including open circuits.
+	BytesSrcToDest int64
+	BytesDestToSrc int64
+	// ReservationRejections and CircuitRejections count the refused
+	// requests by reason since the relay was started.
+	ReservationRejections map[RejectionReason]int64
+	CircuitRejections     map[RejectionReason]int64
+	// PreemptedCircuits is the number of circuits closed for circuits with
+	// a higher priority, see WithCircuitPriority.
+	PreemptedCircuits int64
+}
+
+// ReservationStatus describes an active reservation.
+type ReservationStatus struct {
+	Peer   peer.ID
+	Expiry time.Time
+}
+
+// CircuitStatus describes an open circuit, with the data relayed over it so
+// far.
+type CircuitStatus struct {
+	CircuitInfo
+	Usage CircuitUsage
+}
+
+// activeCircuit accounts the data relayed over an open circuit.
+type activeCircuit struct {
+	info      CircuitInfo
+	srcToDest atomic.Int64
+	destToSrc atomic.Int64
+
+	// req is the request the circuit was admitted for. Its priority is
+	// re-evaluated when the circuit may be preempted.
+	req CircuitRequest
+	// reset resets the streams of the circuit.
+	reset func()
+	// preempted is set once the circuit is closed for a circuit with a
+	// higher priority.
+	preempted bool
+}
+
+// Status returns the active reservations and circuits of the relay, and
+// statistics about the data relayed and the refused requests.
+func (r *Relay) Status() Status {
+	st := Status{
+		BytesSrcToDest:        r.bytesSrcToDest.Load(),
+		BytesDestToSrc:        r.bytesDestToSrc.Load(),
+		ReservationRejections: make(map[RejectionReason]int64),
+		CircuitRejections:     make(map[RejectionReason]int64),
+	}
+
+	r.mx.Lock()
+	st.Reservations = make([]ReservationStatus, 0, len(r.rsvp))
+	for p, expiry := range r.rsvp {
+		st.Reservations = append(st.Reservations, ReservationStatus{Peer: p, Expiry: expiry})
+	}
+	now := r.clock.Now()
+	st.Circuits = make([]CircuitStatus, 0, len(r.circuits))
+	for c := range r.circuits {
+		st.Circuits = append(st.Circuits, CircuitStatus{
+			CircuitInfo: c.info,
+			Usage: CircuitUsage{
+				Duration:       now.Sub(c.info.Opened),
+				BytesSrcToDest: c.srcToDest.Load(),
+				BytesDestToSrc: c.destToSrc.Load(),
+			},
+		})
+	}
+	for reason, n := range r.rsvpRejections {
+		st.ReservationRejections[reason] = n
+	}
+	for reason, n := range r.connRejections {
+		st.CircuitRejections[reason] = n
+	}
+	st.PreemptedCircuits = r.preempted
+	r.mx.Unlock()
+
+	slices.SortFunc(st.Reservations, func(a, b ReservationStatus) int {
+		return strings.Compare(string(a.Peer), string(b.Peer))
+	})
+	slices.SortFunc(st.Circuits, func(a, b CircuitStatus) int {
+		return a.Opened.Compare(b.Opened)
+	})
+	return st
+}
+
+// ServeHTTP implements http.Handler, serving the Status of the relay as
+// JSON.
+func (r *Relay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(r.Status()); err != nil {
+		log.Debugf("error writing relay status: %s", err)
+	}
+}
+
+// reject records a refused request. r.mx must not be held.
+func (r *Relay) reject(isReservation bool, reason RejectionReason) {
+	r.mx.Lock()
+	if isReservation {
+		r.rsvpRejections[reason]++
+	} else {
+		r.connRejections[reason]++
+	}
+	r.mx.Unlock()
+	if r.extMetricsTracer != nil {
+		r.extMetricsTracer.RequestRejected(isReservation, reason)
+	}
+}
@@ -0,0 +1,275 @@
+package relay
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// Reservation is a reservation persisted in a ReservationStore.
+type Reservation struct {
+	Peer peer.ID
+	// Addr is the address the reservation was made from. It is used to
+	// enforce the IP and ASN constraints when the reservation is restored.
+	Addr   ma.Multiaddr
+	Expiry time.Time
+}
+
+// ReservationStore persists reservations, so that they survive a restart of
+// the relay. The relay calls Put when a reservation is created or renewed,
+// and Delete when it expires or the peer disconnects. Reservations are kept
+// when the relay is closed, and restored by Load when the relay is started.
+//
+// Load is called when the relay is created. Put and Delete are called from a
+// background goroutine, one call at a time, so they may block on I/O. If a
+// reservation changes several times while the store is busy, only the latest
+// change is written.
+type ReservationStore interface {
+	Put(Reservation) error
+	Delete(peer.ID) error
+	// Load returns the persisted reservations. Expired reservations may be
+	// included; the relay ignores and deletes them.
+	Load() ([]Reservation, error)
+}
+
+// expiryLen is the length of the expiry header of a reservation file.
+const expiryLen = 8
+
+// tmpPrefix is the prefix of the files reservations are written to before
+// they're renamed to their final name. Peer IDs never start with it.
+const tmpPrefix = ".tmp-"
+
+// FileReservationStore is a ReservationStore storing every reservation as a
+// file in a directory. Every reservation is written and synced to a temporary
+// file that then replaces the reservation's file, and the directory is synced
+// after every change. A crash never leaves a partially written reservation
+// behind, and a change is durable once Put or Delete returns.
+type FileReservationStore struct {
+	dir string
+	mx  sync.Mutex
+}
+
+var _ ReservationStore = (*FileReservationStore)(nil)
+
+// NewFileReservationStore returns a FileReservationStore storing reservations
+// in dir. dir is created if it doesn't exist.
+func NewFileReservationStore(dir string) (*FileReservationStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create reservation directory: %w", err)
+	}
+	// clean up temporary files left behind by a crash
+	tmps, err := filepath.Glob(filepath.Join(dir, tmpPrefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range tmps {
+		os.Remove(f)
+	}
+	return &FileReservationStore{dir: dir}, nil
+}
+
+func (s *FileReservationStore) path(p peer.ID) string {
+	return filepath.Join(s.dir, p.String())
+}
+
+// Put stores rsvp, replacing the reservation of the same peer.
+func (s *FileReservationStore) Put(rsvp Reservation) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	buf := make([]byte, expiryLen, expiryLen+len(rsvp.Addr.Bytes()))
+	binary.BigEndian.PutUint64(buf, uint64(rsvp.Expiry.UnixNano()))
+	buf = append(buf, rsvp.Addr.Bytes()...)
+
+	f, err := os.CreateTemp(s.dir, tmpPrefix+"*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), s.path(rsvp.Peer)); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return s.syncDir()
+}
+
+// Delete removes the reservation of p.
+func (s *FileReservationStore) Delete(p peer.ID) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if err := os.Remove(s.path(p)); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return s.syncDir()
+}
+
+// syncDir syncs the directory, making renames and removals in it durable.
+func (s *FileReservationStore) syncDir() error {
+	d, err := os.Open(s.dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Load returns the stored reservations. Files that can't be parsed are
+// removed.
+func (s *FileReservationStore) Load() ([]Reservation, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var rsvps []Reservation
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), tmpPrefix) {
+			continue
+		}
+		path := filepath.Join(s.dir, e.Name())
+		rsvp, err := readReservation(e.Name(), path)
+		if err != nil {
+			log.Warnf("removing invalid reservation file %s: %s", path, err)
+			os.Remove(path)
+			continue
+		}
+		rsvps = append(rsvps, rsvp)
+	}
+	return rsvps, nil
+}
+
+func readReservation(name, path string) (Reservation, error) {
+	p, err := peer.Decode(name)
+	if err != nil {
+		return Reservation{}, err
+	}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return Reservation{}, err
+	}
+	if len(buf) < expiryLen {
+		return Reservation{}, errors.New("reservation file too short")
+	}
+	addr, err := ma.NewMultiaddrBytes(buf[expiryLen:])
+	if err != nil {
+		return Reservation{}, err
+	}
+	return Reservation{
+		Peer:   p,
+		Addr:   addr,
+		Expiry: time.Unix(0, int64(binary.BigEndian.Uint64(buf))),
+	}, nil
+}
+
+// storeWriter writes reservation changes to a ReservationStore in a
+// background goroutine, so that a slow store doesn't block the relay.
+type storeWriter struct {
+	store ReservationStore
+
+	mx sync.Mutex
+	// pending are the changes not written yet, by peer. A nil reservation
+	// deletes the peer's reservation.
+	pending map[peer.ID]*Reservation
+
+	wake chan struct{}
+	done chan struct{}
+}
+
+func newStoreWriter(store ReservationStore) *storeWriter {
+	return &storeWriter{
+		store:   store,
+		pending: make(map[peer.ID]*Reservation),
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+// put schedules writing rsvp, replacing any pending change of the same peer.
+func (w *storeWriter) put(rsvp Reservation) {
+	w.schedule(rsvp.Peer, &rsvp)
+}
+
+// delete schedules deleting the reservation of p, replacing any pending change
+// of p.
+func (w *storeWriter) delete(p peer.ID) {
+	w.schedule(p, nil)
+}
+
+func (w *storeWriter) schedule(p peer.ID, rsvp *Reservation) {
+	w.mx.Lock()
+	w.pending[p] = rsvp
+	w.mx.Unlock()
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run writes the pending changes until ctx is canceled. The changes pending at
+// that time are written before it returns.
+func (w *storeWriter) run(ctx context.Context) {
+	defer close(w.done)
+	for {
+		select {
+		case <-w.wake:
+			w.flush()
+		case <-ctx.Done():
+			w.flush()
+			return
+		}
+	}
+}
+
+func (w *storeWriter) flush() {
+	for {
+		w.mx.Lock()
+		pending := w.pending
+		if len(pending) == 0 {
+			w.mx.Unlock()
+			return
+		}
+		w.pending = make(map[peer.ID]*Reservation)
+		w.mx.Unlock()
+
+		for p, rsvp := range pending {
+			if rsvp == nil {
+				if err := w.store.Delete(p); err != nil {
+					log.Warnf("failed to delete persisted reservation for %s: %s", p, err)
+				}
+				continue
+			}
+			if err := w.store.Put(*rsvp); err != nil {
+				log.Warnf("failed to persist reservation for %s: %s", p, err)
+			}
+		}
+	}
+}
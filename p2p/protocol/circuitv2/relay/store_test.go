@@ -0,0 +1,45 @@
+package relay
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestFileReservationStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileReservationStore(dir)
+	require.NoError(t, err)
+
+	_, p1 := genKeyAndID(t)
+	_, p2 := genKeyAndID(t)
+	expiry := time.Now().Add(time.Hour).Round(0)
+	addr := ma.StringCast("/ip4/1.2.3.4/tcp/1234")
+	require.NoError(t, s.Put(Reservation{Peer: p1, Addr: addr, Expiry: expiry}))
+	require.NoError(t, s.Put(Reservation{Peer: p2, Addr: addr, Expiry: expiry}))
+	require.NoError(t, s.Put(Reservation{Peer: p1, Addr: addr, Expiry: expiry.Add(time.Minute)}))
+	require.NoError(t, s.Delete(p2))
+	require.NoError(t, s.Delete(p2))
+
+	// invalid and temporary files are removed
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo"), nil, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, tmpPrefix+"1"), nil, 0o600))
+
+	s, err = NewFileReservationStore(dir)
+	require.NoError(t, err)
+	rsvps, err := s.Load()
+	require.NoError(t, err)
+	require.Len(t, rsvps, 1)
+	require.Equal(t, p1, rsvps[0].Peer)
+	require.True(t, addr.Equal(rsvps[0].Addr))
+	require.True(t, expiry.Add(time.Minute).Equal(rsvps[0].Expiry))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
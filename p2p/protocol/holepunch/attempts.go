@@ -0,0 +1,193 @@
+package holepunch
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+const (
+	// maxAttemptsPerPeer is the number of attempts kept per peer.
+	maxAttemptsPerPeer = 10
+	// maxAttemptPeers is the number of peers whose attempts are kept.
+	maxAttemptPeers = 1000
+	// attemptTTL is how long attempts are kept.
+	attemptTTL = time.Hour
+)
+
+// Attempt describes an attempt to establish a direct connection with a peer.
+type Attempt struct {
+	Peer peer.ID
+	// Initiator is true if we initiated the DCUtR protocol, i.e. the peer
+	// connected to us through a relay.
+	Initiator bool
+	Start     time.Time
+	End       time.Time
+	// DirectDial is true if a direct dial was tried before hole punching.
+	// If it succeeded, there are no Rounds.
+	DirectDial bool
+	// Rounds are the hole punching rounds, in order.
+	Rounds []Round
+	// Success is true if a direct connection was established.
+	Success bool
+	// Addr is the remote address of the direct connection, if any.
+	Addr ma.Multiaddr
+	// Err is the reason the attempt failed.
+	Err error
+}
+
+// SuccessfulRound returns the number of the round that established the direct
+// connection, starting at 1. It returns 0 if no round succeeded.
+func (a *Attempt) SuccessfulRound() int {
+	if !a.Success || len(a.Rounds) == 0 || a.Rounds[len(a.Rounds)-1].Err != nil {
+		return 0
+	}
+	return len(a.Rounds)
+}
+
+// Round describes a single hole punching round: the exchange of addresses
+// with the peer over the relayed connection, followed by the simultaneous
+// connect.
+type Round struct {
+	Start time.Time
+	End   time.Time
+	// RTT is the round trip time to the peer over the relayed connection.
+	RTT time.Duration
+	// LocalAddrs are the addresses we sent to the peer.
+	LocalAddrs []ma.Multiaddr
+	// RemoteAddrs are the addresses of the peer that we punched through to.
+	RemoteAddrs []ma.Multiaddr
+	// Err is the reason the round failed.
+	Err error
+}
+
+// attemptLog keeps the recent attempts of each peer and notifies subscribers
+// of new ones.
+type attemptLog struct {
+	mx       sync.Mutex
+	attempts map[peer.ID][]Attempt
+	subs     map[int]func(Attempt)
+	nextSub  int
+}
+
+func newAttemptLog() *attemptLog {
+	return &attemptLog{
+		attempts: make(map[peer.ID][]Attempt),
+		subs:     make(map[int]func(Attempt)),
+	}
+}
+
+func (l *attemptLog) add(a Attempt) {
+	l.mx.Lock()
+	cutoff := a.End.Add(-attemptTTL)
+	for p, as := range l.attempts {
+		if as[len(as)-1].End.Before(cutoff) {
+			delete(l.attempts, p)
+		}
+	}
+	as, ok := l.attempts[a.Peer]
+	if !ok && len(l.attempts) >= maxAttemptPeers {
+		// drop the peer whose last attempt is the oldest
+		var oldest peer.ID
+		for p, as := range l.attempts {
+			if oldest == "" || as[len(as)-1].End.Before(l.attempts[oldest][len(l.attempts[oldest])-1].End) {
+				oldest = p
+			}
+		}
+		delete(l.attempts, oldest)
+	}
+	if len(as) >= maxAttemptsPerPeer {
+		as = slices.Delete(as, 0, len(as)-maxAttemptsPerPeer+1)
+	}
+	l.attempts[a.Peer] = append(as, a)
+	subs := make([]func(Attempt), 0, len(l.subs))
+	for _, f := range l.subs {
+		subs = append(subs, f)
+	}
+	l.mx.Unlock()
+
+	for _, f := range subs {
+		f(a)
+	}
+}
+
+func (l *attemptLog) get(p peer.ID) []Attempt {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	return slices.Clone(l.attempts[p])
+}
+
+func (l *attemptLog) subscribe(f func(Attempt)) (cancel func()) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	id := l.nextSub
+	l.nextSub++
+	l.subs[id] = f
+	return func() {
+		l.mx.Lock()
+		defer l.mx.Unlock()
+		delete(l.subs, id)
+	}
+}
+
+// RetryPolicy decides whether the initiator of a hole punch starts another
+// round after a round failed.
+type RetryPolicy interface {
+	// Retry is called after round (starting at 1) with p failed with err. It
+	// returns whether to start another round, and how long to wait before
+	// starting it.
+	Retry(p peer.ID, round int, err error) (retry bool, delay time.Duration)
+}
+
+// RetryPolicyFunc is a function implementing RetryPolicy.
+type RetryPolicyFunc func(p peer.ID, round int, err error) (bool, time.Duration)
+
+// Retry calls f.
+func (f RetryPolicyFunc) Retry(p peer.ID, round int, err error) (bool, time.Duration) {
+	return f(p, round, err)
+}
+
+// ExponentialBackoff returns a RetryPolicy making up to rounds rounds. It
+// waits initial before the second round and doubles the delay for every
+// following round, up to maxDelay.
+func ExponentialBackoff(rounds int, initial, maxDelay time.Duration) RetryPolicy {
+	return RetryPolicyFunc(func(_ peer.ID, round int, _ error) (bool, time.Duration) {
+		if round >= rounds {
+			return false, 0
+		}
+		delay := initial
+		for i := 1; i < round && delay < maxDelay; i++ {
+			delay *= 2
+		}
+		return true, min(delay, maxDelay)
+	})
+}
+
+// WithRetryPolicy sets the policy for retrying failed hole punching rounds.
+// By default, up to 3 rounds are made without waiting between them.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(s *Service) error {
+		if p == nil {
+			return errors.New("retry policy must not be nil")
+		}
+		s.retryPolicy = p
+		return nil
+	}
+}
+
+// Attempts returns the recent attempts to establish a direct connection with
+// p, oldest first.
+func (s *Service) Attempts(p peer.ID) []Attempt {
+	return s.attempts.get(p)
+}
+
+// Subscribe calls f for every attempt to establish a direct connection, once
+// it completed. f must not block. Call cancel to stop receiving attempts.
+func (s *Service) Subscribe(f func(Attempt)) (cancel func()) {
+	return s.attempts.subscribe(f)
+}
@@ -0,0 +1,83 @@
+package holepunch
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/test"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttemptLog(t *testing.T) {
+	l := newAttemptLog()
+	var got []Attempt
+	cancel := l.subscribe(func(a Attempt) { got = append(got, a) })
+
+	p1, p2 := test.RandPeerIDFatal(t), test.RandPeerIDFatal(t)
+	now := time.Now()
+	l.add(Attempt{Peer: p2, End: now.Add(-2 * attemptTTL)})
+	for i := 0; i < maxAttemptsPerPeer+2; i++ {
+		l.add(Attempt{Peer: p1, End: now.Add(time.Duration(i) * time.Second)})
+	}
+	attempts := l.get(p1)
+	require.Len(t, attempts, maxAttemptsPerPeer)
+	require.Equal(t, now.Add(2*time.Second), attempts[0].End)
+	require.Len(t, got, maxAttemptsPerPeer+3)
+	// expired
+	require.Empty(t, l.get(p2))
+
+	cancel()
+	l.add(Attempt{Peer: p1, End: now})
+	require.Len(t, got, maxAttemptsPerPeer+3)
+}
+
+func TestAttemptLogMaxPeers(t *testing.T) {
+	l := newAttemptLog()
+	now := time.Now()
+	var first peer.ID
+	for i := 0; i < maxAttemptPeers+1; i++ {
+		p := test.RandPeerIDFatal(t)
+		if i == 0 {
+			first = p
+		}
+		l.add(Attempt{Peer: p, End: now.Add(time.Duration(i) * time.Millisecond)})
+	}
+	require.Len(t, l.attempts, maxAttemptPeers)
+	require.Empty(t, l.get(first))
+}
+
+func TestSuccessfulRound(t *testing.T) {
+	a := Attempt{Success: true, DirectDial: true}
+	require.Zero(t, a.SuccessfulRound())
+	a.Rounds = []Round{{Err: errors.New("timeout")}, {}}
+	require.Equal(t, 2, a.SuccessfulRound())
+	a.Success = false
+	require.Zero(t, a.SuccessfulRound())
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	p := ExponentialBackoff(4, time.Second, 3*time.Second)
+	var delays []time.Duration
+	for round := 1; ; round++ {
+		retry, delay := p.Retry("", round, errors.New("failed"))
+		if !retry {
+			require.Equal(t, 4, round)
+			break
+		}
+		delays = append(delays, delay)
+	}
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}, delays)
+
+	// the default policy retries immediately
+	p = ExponentialBackoff(maxRetries, 0, 0)
+	for round := 1; round < maxRetries; round++ {
+		retry, delay := p.Retry("", round, nil)
+		require.True(t, retry)
+		require.Zero(t, delay)
+	}
+	retry, _ := p.Retry("", maxRetries, nil)
+	require.False(t, retry)
+}
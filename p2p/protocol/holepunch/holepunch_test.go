@@ -427,6 +427,14 @@ func TestFailuresOnInitiator(t *testing.T) {
 			if tc.errMsg != "" {
 				require.Contains(t, err.Error(), tc.errMsg)
 			}
+
+			attempts := hps.Attempts(h1.ID())
+			require.Len(t, attempts, 1)
+			require.True(t, attempts[0].Initiator)
+			require.False(t, attempts[0].Success)
+			require.Equal(t, err, attempts[0].Err)
+			require.Zero(t, attempts[0].SuccessfulRound())
+			require.NotEmpty(t, attempts[0].Rounds)
 		})
 	}
 }
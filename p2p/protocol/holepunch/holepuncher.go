@@ -46,8 +46,10 @@ type holePuncher struct {
 	closeMx sync.RWMutex
 	closed  bool
 
-	tracer *tracer
-	filter AddrFilter
+	tracer      *tracer
+	filter      AddrFilter
+	retryPolicy RetryPolicy
+	attempts    *attemptLog
 
 	// Prior to https://github.com/libp2p/go-libp2p/pull/3044, go-libp2p would
 	// pick the opposite roles for client/server a hole punch. Setting this to
@@ -113,6 +115,21 @@ func (hp *holePuncher) directConnect(rp peer.ID) error {
 		return nil
 	}
 
+	attempt := Attempt{Peer: rp, Initiator: true, Start: time.Now()}
+	err := hp.connect(rp, &attempt)
+	attempt.End = time.Now()
+	attempt.Success = err == nil
+	attempt.Err = err
+	if c := getDirectConnection(hp.host, rp); c != nil {
+		attempt.Addr = c.RemoteMultiaddr()
+	}
+	hp.attempts.add(attempt)
+	return err
+}
+
+// connect establishes a direct connection with rp, recording the direct dial
+// and the hole punching rounds in attempt.
+func (hp *holePuncher) connect(rp peer.ID, attempt *Attempt) error {
 	log.Debugw("attempting direct dial", "host", hp.host.ID(), "peer", rp, "addrs", hp.host.Peerstore().Addrs(rp))
 	// short-circuit hole punching if a direct dial works.
 	// attempt a direct connection ONLY if we have a public address for the remote peer
@@ -127,6 +144,7 @@ func (hp *holePuncher) directConnect(rp peer.ID) error {
 			dt := time.Since(tstart)
 			cancel()
 
+			attempt.DirectDial = true
 			if err != nil {
 				hp.tracer.DirectDialFailed(rp, dt, err)
 				break
@@ -140,12 +158,19 @@ func (hp *holePuncher) directConnect(rp peer.ID) error {
 	log.Debugw("got inbound proxy conn", "peer", rp)
 
 	// hole punch
-	for i := 1; i <= maxRetries; i++ {
+	for i := 1; ; i++ {
+		round := Round{Start: time.Now()}
 		addrs, obsAddrs, rtt, err := hp.initiateHolePunch(rp)
 		if err != nil {
 			hp.tracer.ProtocolError(rp, err)
+			round.End = time.Now()
+			round.Err = err
+			attempt.Rounds = append(attempt.Rounds, round)
 			return err
 		}
+		round.RTT = rtt
+		round.LocalAddrs = obsAddrs
+		round.RemoteAddrs = addrs
 		synTime := rtt / 2
 		log.Debugf("peer RTT is %s; starting hole punch in %s", rtt, synTime)
 
@@ -169,20 +194,33 @@ func (hp *holePuncher) directConnect(rp peer.ID) error {
 			cancel()
 			dt := time.Since(start)
 			hp.tracer.EndHolePunch(rp, dt, err)
+			round.End = time.Now()
+			round.Err = err
+			attempt.Rounds = append(attempt.Rounds, round)
 			if err == nil {
 				log.Debugw("hole punching with successful", "peer", rp, "time", dt)
 				hp.tracer.HolePunchFinished("initiator", i, addrs, obsAddrs, getDirectConnection(hp.host, rp))
 				return nil
 			}
+			retry, delay := hp.retryPolicy.Retry(rp, i, err)
+			if !retry {
+				hp.tracer.HolePunchFinished("initiator", i, addrs, obsAddrs, nil)
+				return fmt.Errorf("all retries for hole punch with peer %s failed", rp)
+			}
+			if delay > 0 {
+				t := time.NewTimer(delay)
+				select {
+				case <-t.C:
+				case <-hp.ctx.Done():
+					t.Stop()
+					return hp.ctx.Err()
+				}
+			}
 		case <-hp.ctx.Done():
 			timer.Stop()
 			return hp.ctx.Err()
 		}
-		if i == maxRetries {
-			hp.tracer.HolePunchFinished("initiator", maxRetries, addrs, obsAddrs, nil)
-		}
 	}
-	return fmt.Errorf("all retries for hole punch with peer %s failed", rp)
 }
 
 // initiateHolePunch opens a new hole punching coordination stream,
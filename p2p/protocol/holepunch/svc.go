@@ -12,6 +12,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/host/probecoord"
 	"github.com/libp2p/go-libp2p/p2p/protocol/holepunch/pb"
 	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
 	"github.com/libp2p/go-msgio/pbio"
@@ -47,6 +48,16 @@ func DirectDialTimeout(timeout time.Duration) Option {
 	}
 }
 
+// WithProbeCoordinator schedules the checks for a public address with the
+// timers of c, so that they are jittered and spaced out from the other
+// background probes of the host.
+func WithProbeCoordinator(c *probecoord.Coordinator) Option {
+	return func(s *Service) error {
+		s.coordinator = c
+		return nil
+	}
+}
+
 // The Service runs on every node that supports the DCUtR protocol.
 type Service struct {
 	ctx       context.Context
@@ -67,8 +78,11 @@ type Service struct {
 
 	hasPublicAddrsChan chan struct{}
 
-	tracer *tracer
-	filter AddrFilter
+	tracer      *tracer
+	filter      AddrFilter
+	retryPolicy RetryPolicy
+	attempts    *attemptLog
+	coordinator *probecoord.Coordinator
 
 	refCount sync.WaitGroup
 
@@ -106,6 +120,8 @@ func NewService(h host.Host, ids identify.IDService, listenAddrs func() []ma.Mul
 		hasPublicAddrsChan: make(chan struct{}),
 		directDialTimeout:  defaultDirectDialTimeout,
 		legacyBehavior:     true,
+		retryPolicy:        ExponentialBackoff(maxRetries, 0, 0),
+		attempts:           newAttemptLog(),
 	}
 
 	for _, opt := range opts {
@@ -133,7 +149,7 @@ func (s *Service) waitForPublicAddr() {
 	// regularly (exponential backoff starting at 250 ms, capped at 5s).
 	duration := 250 * time.Millisecond
 	const maxDuration = 5 * time.Second
-	t := time.NewTimer(duration)
+	t := s.coordinator.NewTimer(duration)
 	defer t.Stop()
 	for {
 		if len(s.listenAddrs()) > 0 {
@@ -161,6 +177,8 @@ func (s *Service) waitForPublicAddr() {
 	}
 	s.holePuncher = newHolePuncher(s.host, s.ids, s.listenAddrs, s.tracer, s.filter)
 	s.holePuncher.directDialTimeout = s.directDialTimeout
+	s.holePuncher.retryPolicy = s.retryPolicy
+	s.holePuncher.attempts = s.attempts
 	s.holePuncher.legacyBehavior = s.legacyBehavior
 	s.holePuncherMx.Unlock()
 	close(s.hasPublicAddrsChan)
@@ -265,11 +283,16 @@ func (s *Service) handleNewStream(str network.Stream) {
 	}
 
 	rp := str.Conn().RemotePeer()
+	attempt := Attempt{Peer: rp, Start: time.Now()}
 	rtt, addrs, ownAddrs, err := s.incomingHolePunch(str)
 	if err != nil {
 		s.tracer.ProtocolError(rp, err)
 		log.Debugw("error handling holepunching stream from", "peer", rp, "error", err)
 		str.Reset()
+		attempt.End = time.Now()
+		attempt.Err = err
+		attempt.Rounds = []Round{{Start: attempt.Start, End: attempt.End, Err: err}}
+		s.attempts.add(attempt)
 		return
 	}
 	str.Close()
@@ -292,7 +315,24 @@ func (s *Service) handleNewStream(str network.Stream) {
 	cancel()
 	dt := time.Since(start)
 	s.tracer.EndHolePunch(rp, dt, err)
-	s.tracer.HolePunchFinished("receiver", 1, addrs, ownAddrs, getDirectConnection(s.host, rp))
+	directConn := getDirectConnection(s.host, rp)
+	s.tracer.HolePunchFinished("receiver", 1, addrs, ownAddrs, directConn)
+
+	attempt.End = time.Now()
+	attempt.Rounds = []Round{{
+		Start:       attempt.Start,
+		End:         attempt.End,
+		RTT:         rtt,
+		LocalAddrs:  ownAddrs,
+		RemoteAddrs: addrs,
+		Err:         err,
+	}}
+	attempt.Success = err == nil
+	attempt.Err = err
+	if directConn != nil {
+		attempt.Addr = directConn.RemoteMultiaddr()
+	}
+	s.attempts.add(attempt)
 }
 
 // DirectConnect is only exposed for testing purposes.
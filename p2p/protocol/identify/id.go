@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/netip"
 	"slices"
 	"sync"
@@ -123,6 +124,30 @@ type IDService interface {
 	io.Closer
 }
 
+// ObservedAddrService is an optional interface an IDService can implement to
+// report the addresses peers observed for us in detail, and to override which
+// of them are advertised. The IDService returned by NewIDService implements it.
+type ObservedAddrService interface {
+	// ObservedAddrs returns all the addresses peers have reported we've dialed
+	// from, with the number of observations and observers for each of them.
+	ObservedAddrs() []ObservedAddr
+	// SetObservedAddrOverride pins or vetoes an observed address, overriding
+	// whether it is advertised.
+	SetObservedAddrOverride(addr ma.Multiaddr, ov ObservedAddrOverride) error
+}
+
+var _ ObservedAddrService = (*idService)(nil)
+
+// LastIdentifiedTracker is an optional interface an IDService can implement to
+// report how stale the identify information of a peer is. The IDService
+// returned by NewIDService implements it.
+type LastIdentifiedTracker interface {
+	// LastIdentified returns when we last received an identify message from
+	// the peer. It returns false if the peer wasn't identified on any current
+	// connection.
+	LastIdentified(peer.ID) (time.Time, bool)
+}
+
 type identifyPushSupport uint8
 
 const (
@@ -141,6 +166,13 @@ type entry struct {
 	PushSupport identifyPushSupport
 	// Sequence is the sequence number of the last snapshot we sent to this peer.
 	Sequence uint64
+	// LastIdentified is the time we last received an identify message on this connection.
+	LastIdentified time.Time
+	// LastPush is the time we last sent an identify push on this connection.
+	LastPush time.Time
+	// NextRefresh is the time identify is re-run on this connection. It is zero if
+	// refreshing is disabled, or if a refresh is in flight.
+	NextRefresh time.Time
 }
 
 // idService is a structure that implements ProtocolIdentify.
@@ -157,6 +189,9 @@ type idService struct {
 	ProtocolVersion string
 
 	metricsTracer MetricsTracer
+	// extMetricsTracer is metricsTracer, if it implements
+	// ExtendedMetricsTracer
+	extMetricsTracer ExtendedMetricsTracer
 
 	setupCompleted chan struct{} // is closed when Start has finished setting up
 	ctx            context.Context
@@ -166,6 +201,11 @@ type idService struct {
 
 	disableSignedPeerRecord bool
 	timeout                 time.Duration
+	refreshInterval         time.Duration
+	refreshJitter           time.Duration
+	pushDebounce            time.Duration
+	pushMinInterval         time.Duration
+	addrCrossCheck          AddrCrossCheck
 
 	connsMu sync.RWMutex
 	// The conns map contains all connections we're currently handling.
@@ -226,6 +266,11 @@ func NewIDService(h host.Host, opts ...Option) (*idService, error) {
 		setupCompleted:          make(chan struct{}),
 		metricsTracer:           cfg.metricsTracer,
 		timeout:                 cfg.timeout,
+		refreshInterval:         cfg.refreshInterval,
+		refreshJitter:           cfg.refreshJitter,
+		pushDebounce:            cfg.pushDebounce,
+		pushMinInterval:         cfg.pushMinInterval,
+		addrCrossCheck:          cfg.addrCrossCheck,
 		rateLimiter: &rate.Limiter{
 			GlobalLimit:         defaultGlobalRateLimit,
 			NetworkPrefixLimits: defaultNetworkPrefixRateLimits,
@@ -236,6 +281,7 @@ func NewIDService(h host.Host, opts ...Option) (*idService, error) {
 			},
 		},
 	}
+	s.extMetricsTracer, _ = cfg.metricsTracer.(ExtendedMetricsTracer)
 
 	var normalize func(ma.Multiaddr) ma.Multiaddr
 	if hn, ok := h.(normalizer); ok {
@@ -283,6 +329,11 @@ func (ids *idService) Start() {
 
 	ids.refCount.Add(1)
 	go ids.loop(ids.ctx)
+
+	if ids.refreshInterval > 0 {
+		ids.refCount.Add(1)
+		go ids.refreshLoop(ids.ctx)
+	}
 }
 
 func (ids *idService) loop(ctx context.Context) {
@@ -308,12 +359,38 @@ func (ids *idService) loop(ctx context.Context) {
 	go func() {
 		defer ids.refCount.Done()
 
+		// retry fires when pushes that were suppressed by the per-peer rate
+		// limit can be sent
+		retry := time.NewTimer(0)
+		if !retry.Stop() {
+			<-retry.C
+		}
+		defer retry.Stop()
 		for {
 			select {
 			case <-ctx.Done():
 				return
 			case <-triggerPush:
-				ids.sendPushes(ctx)
+			case <-retry.C:
+			}
+			if ids.pushDebounce > 0 {
+				select {
+				case <-time.After(ids.pushDebounce):
+				case <-ctx.Done():
+					return
+				}
+				// changes during the debounce window are sent with this push
+				select {
+				case <-triggerPush:
+					if ids.extMetricsTracer != nil {
+						ids.extMetricsTracer.PushCoalesced()
+					}
+				default:
+				}
+			}
+			retry.Stop()
+			if retryAt := ids.sendPushes(ctx, false); !retryAt.IsZero() {
+				retry.Reset(time.Until(retryAt))
 			}
 		}
 	}()
@@ -333,6 +410,9 @@ func (ids *idService) loop(ctx context.Context) {
 			select {
 			case triggerPush <- struct{}{}:
 			default: // we already have one more push queued, no need to queue another one
+				if ids.extMetricsTracer != nil {
+					ids.extMetricsTracer.PushCoalesced()
+				}
 			}
 		case <-ctx.Done():
 			return
@@ -340,7 +420,20 @@ func (ids *idService) loop(ctx context.Context) {
 	}
 }
 
-func (ids *idService) sendPushes(ctx context.Context) {
+// PushNow pushes the current protocols and addresses to all connected peers
+// that haven't received them yet, and waits for the pushes to complete or ctx
+// to be done. Unlike the pushes triggered by changes of the protocols or
+// addresses, it isn't debounced or rate limited.
+func (ids *idService) PushNow(ctx context.Context) {
+	ids.updateSnapshot()
+	ids.sendPushes(ctx, true)
+}
+
+// sendPushes pushes the current snapshot to all peers that haven't received it
+// yet. If pushes to some peers were suppressed by the per-peer rate limit, it
+// returns the earliest time they can be sent. If force is set, the rate limit
+// is ignored.
+func (ids *idService) sendPushes(ctx context.Context, force bool) (retryAt time.Time) {
 	ids.connsMu.RLock()
 	conns := make([]network.Conn, 0, len(ids.conns))
 	for c, e := range ids.conns {
@@ -354,6 +447,8 @@ func (ids *idService) sendPushes(ctx context.Context) {
 
 	sem := make(chan struct{}, maxPushConcurrency)
 	var wg sync.WaitGroup
+	var numPushes, numRateLimited int
+	now := time.Now()
 	for _, c := range conns {
 		// check if the connection is still alive
 		ids.connsMu.RLock()
@@ -370,7 +465,17 @@ func (ids *idService) sendPushes(ctx context.Context) {
 			log.Debugw("already sent this snapshot to peer", "peer", c.RemotePeer(), "seq", snapshot.seq)
 			continue
 		}
+		if !force && ids.pushMinInterval > 0 && !e.LastPush.IsZero() {
+			if next := e.LastPush.Add(ids.pushMinInterval); now.Before(next) {
+				numRateLimited++
+				if retryAt.IsZero() || next.Before(retryAt) {
+					retryAt = next
+				}
+				continue
+			}
+		}
 		// we haven't, send it now
+		numPushes++
 		sem <- struct{}{}
 		wg.Add(1)
 		go func(c network.Conn) {
@@ -391,6 +496,13 @@ func (ids *idService) sendPushes(ctx context.Context) {
 		}(c)
 	}
 	wg.Wait()
+	if ids.extMetricsTracer != nil {
+		ids.extMetricsTracer.PushesSent(numPushes)
+		if numRateLimited > 0 {
+			ids.extMetricsTracer.PushesRateLimited(numRateLimited)
+		}
+	}
+	return retryAt
 }
 
 // Close shuts down the idService
@@ -418,6 +530,20 @@ func (ids *idService) ObservedAddrsFor(local ma.Multiaddr) []ma.Multiaddr {
 	return ids.observedAddrMgr.AddrsFor(local)
 }
 
+func (ids *idService) ObservedAddrs() []ObservedAddr {
+	if ids.disableObservedAddrManager {
+		return nil
+	}
+	return ids.observedAddrMgr.ObservedAddrs()
+}
+
+func (ids *idService) SetObservedAddrOverride(addr ma.Multiaddr, ov ObservedAddrOverride) error {
+	if ids.disableObservedAddrManager {
+		return errors.New("observed address manager is disabled")
+	}
+	return ids.observedAddrMgr.SetOverride(addr, ov)
+}
+
 // IdentifyConn runs the Identify protocol on a connection.
 // It returns when we've received the peer's Identify message (or the request fails).
 // If successful, the peer store will contain the peer's addresses and supported protocols.
@@ -447,6 +573,9 @@ func (ids *idService) IdentifyWait(c network.Conn) <-chan struct{} {
 		}
 	}
 
+	if ids.extMetricsTracer != nil {
+		ids.extMetricsTracer.IdentifyWait(e.IdentifyWaitChan != nil)
+	}
 	if e.IdentifyWaitChan != nil {
 		return e.IdentifyWaitChan
 	}
@@ -555,6 +684,9 @@ func (ids *idService) sendIdentifyResp(s network.Stream, isPush bool) error {
 		return nil
 	}
 	e.Sequence = snapshot.seq
+	if isPush {
+		e.LastPush = time.Now()
+	}
 	ids.conns[s.Conn()] = e
 	return nil
 }
@@ -588,7 +720,12 @@ func (ids *idService) handleIdentifyResponse(s network.Stream, isPush bool) erro
 
 	log.Debugf("%s received message from %s %s", s.Protocol(), c.RemotePeer(), c.RemoteMultiaddr())
 
-	ids.consumeMessage(mes, c, isPush)
+	// Protocol updates are emitted for pushes and refreshes, i.e. for every
+	// message but the first one on a connection.
+	ids.connsMu.RLock()
+	isUpdate := isPush || !ids.conns[c].LastIdentified.IsZero()
+	ids.connsMu.RUnlock()
+	ids.consumeMessage(mes, c, isUpdate)
 
 	if ids.metricsTracer != nil {
 		ids.metricsTracer.IdentifyReceived(isPush, len(mes.Protocols), len(mes.ListenAddrs))
@@ -611,6 +748,9 @@ func (ids *idService) handleIdentifyResponse(s network.Stream, isPush bool) erro
 		ids.metricsTracer.ConnPushSupport(e.PushSupport)
 	}
 
+	now := time.Now()
+	e.LastIdentified = now
+	e.NextRefresh = ids.nextRefresh(now)
 	ids.conns[c] = e
 	return nil
 }
@@ -704,7 +844,7 @@ func (ids *idService) createBaseIdentifyResponse(conn network.Conn, snapshot *id
 	viaLoopback := manet.IsIPLoopback(localAddr) || manet.IsIPLoopback(remoteAddr)
 	mes.ListenAddrs = make([][]byte, 0, len(snapshot.addrs))
 	for _, addr := range snapshot.addrs {
-		if !viaLoopback && manet.IsIPLoopback(addr) {
+		if (!viaLoopback && manet.IsIPLoopback(addr)) || isUnixAddr(addr) {
 			continue
 		}
 		mes.ListenAddrs = append(mes.ListenAddrs, addr.Bytes())
@@ -781,14 +921,14 @@ func diff(a, b []protocol.ID) (added, removed []protocol.ID) {
 	return
 }
 
-func (ids *idService) consumeMessage(mes *pb.Identify, c network.Conn, isPush bool) {
+func (ids *idService) consumeMessage(mes *pb.Identify, c network.Conn, isUpdate bool) {
 	p := c.RemotePeer()
 
 	supported, _ := ids.Host.Peerstore().GetProtocols(p)
 	mesProtocols := protocol.ConvertFromStrings(mes.Protocols)
 	added, removed := diff(supported, mesProtocols)
 	ids.Host.Peerstore().SetProtocols(p, mesProtocols...)
-	if isPush {
+	if isUpdate {
 		ids.emitters.evtPeerProtocolsUpdated.Emit(event.EvtPeerProtocolsUpdated{
 			Peer:    p,
 			Added:   added,
@@ -864,12 +1004,29 @@ func (ids *idService) consumeMessage(mes *pb.Identify, c network.Conn, isPush bo
 	} else {
 		addrs = lmaddrs
 	}
+	// Don't learn /unix addresses from peers. We only dial the ones the user
+	// gave us.
+	addrs = ma.FilterAddrs(addrs, func(a ma.Multiaddr) bool { return !isUnixAddr(a) })
 	addrs = filterAddrs(addrs, c.RemoteMultiaddr())
+	addrs = zoneLinkLocalAddrs(addrs, c.RemoteMultiaddr())
+	var inconsistent []ma.Multiaddr
+	if ids.addrCrossCheck != AddrCrossCheckDisabled {
+		addrs, inconsistent = crossCheckAddrs(addrs, c.RemoteMultiaddr())
+		if len(inconsistent) > 0 {
+			log.Debugw("peer claimed addresses inconsistent with the connection", "peer", p,
+				"remote", c.RemoteMultiaddr(), "addrs", inconsistent)
+		}
+		if ids.addrCrossCheck == AddrCrossCheckDiscard {
+			inconsistent = nil
+		}
+	}
 	if len(addrs) > connectedPeerMaxAddrs {
 		addrs = addrs[:connectedPeerMaxAddrs]
 	}
+	inconsistent = inconsistent[:min(len(inconsistent), connectedPeerMaxAddrs-len(addrs))]
 
 	ids.Host.Peerstore().AddAddrs(p, addrs, ttl)
+	ids.Host.Peerstore().AddAddrs(p, inconsistent, peerstore.RecentlyConnectedAddrTTL)
 
 	// Finally, expire all temporary addrs.
 	ids.Host.Peerstore().UpdateAddrs(p, peerstore.TempAddrTTL, 0)
@@ -1126,6 +1283,80 @@ func filterAddrs(addrs []ma.Multiaddr, remote ma.Multiaddr) []ma.Multiaddr {
 	}
 }
 
+// isUnixAddr returns true if addr is a Unix domain socket address.
+func isUnixAddr(addr ma.Multiaddr) bool {
+	return len(addr) > 0 && addr[0].Code() == ma.P_UNIX
+}
+
+// zoneLinkLocalAddrs rewrites the zones of the IPv6 link-local addresses in
+// addrs. The zone a peer reports names one of its own interfaces, which means
+// nothing to us. If we're connected to the peer over a link-local address, we
+// can reach its other link-local addresses through the same interface, so we
+// use the zone of the connection. Otherwise the zone is dropped.
+func zoneLinkLocalAddrs(addrs []ma.Multiaddr, remote ma.Multiaddr) []ma.Multiaddr {
+	var zone *ma.Component
+	if manet.IsIP6LinkLocal(remote) && len(remote) > 0 && remote[0].Code() == ma.P_IP6ZONE {
+		zone = &remote[0]
+	}
+	out := make([]ma.Multiaddr, 0, len(addrs))
+	for _, a := range addrs {
+		if !manet.IsIP6LinkLocal(a) {
+			out = append(out, a)
+			continue
+		}
+		if len(a) > 0 && a[0].Code() == ma.P_IP6ZONE {
+			a = a[1:]
+		}
+		if zone != nil {
+			a = append(ma.Multiaddr{*zone}, a...)
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// crossCheckAddrs splits addrs into the addresses that are consistent with the
+// remote address of the connection and those that aren't. See
+// WithAddrCrossCheck.
+func crossCheckAddrs(addrs []ma.Multiaddr, remote ma.Multiaddr) (consistent, inconsistent []ma.Multiaddr) {
+	if !manet.IsPublicAddr(remote) {
+		return addrs, nil
+	}
+	remoteIP, err := manet.ToIP(remote)
+	if err != nil {
+		return addrs, nil
+	}
+	for _, a := range addrs {
+		if isRelayedAddress(a) {
+			consistent = append(consistent, a)
+			continue
+		}
+		ip, err := manet.ToIP(a)
+		if err != nil || sameIP(ip, remoteIP) {
+			consistent = append(consistent, a)
+		} else {
+			inconsistent = append(inconsistent, a)
+		}
+	}
+	return consistent, inconsistent
+}
+
+// sameIP returns true if a and b are the same IPv4 address, or IPv6 addresses
+// in the same /64 prefix. Addresses of different families can't be compared
+// and are considered the same.
+func sameIP(a, b net.IP) bool {
+	a4, b4 := a.To4(), b.To4()
+	switch {
+	case a4 != nil && b4 != nil:
+		return a4.Equal(b4)
+	case a4 == nil && b4 == nil:
+		mask := net.CIDRMask(64, 128)
+		return a.Mask(mask).Equal(b.Mask(mask))
+	default:
+		return true
+	}
+}
+
 func trimHostAddrList(addrs []ma.Multiaddr, maxSize int) []ma.Multiaddr {
 	totalSize := 0
 	for _, a := range addrs {
@@ -206,3 +206,49 @@ func TestIncomingAddrFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestZoneLinkLocalAddrs(t *testing.T) {
+	pubAddr := ma.StringCast("/ip4/1.2.3.4/tcp/1234")
+	llAddr := ma.StringCast("/ip6/fe80::1/tcp/1234")
+	llZoneAddr := ma.StringCast("/ip6zone/eth1/ip6/fe80::2/udp/1234/quic-v1")
+	input := []ma.Multiaddr{pubAddr, llAddr, llZoneAddr}
+
+	got := zoneLinkLocalAddrs(input, ma.StringCast("/ip6zone/eth0/ip6/fe80::3/tcp/4321"))
+	require.Equal(t, []ma.Multiaddr{
+		pubAddr,
+		ma.StringCast("/ip6zone/eth0/ip6/fe80::1/tcp/1234"),
+		ma.StringCast("/ip6zone/eth0/ip6/fe80::2/udp/1234/quic-v1"),
+	}, got)
+
+	got = zoneLinkLocalAddrs(input, ma.StringCast("/ip4/192.168.1.1/tcp/4321"))
+	require.Equal(t, []ma.Multiaddr{
+		pubAddr,
+		llAddr,
+		ma.StringCast("/ip6/fe80::2/udp/1234/quic-v1"),
+	}, got)
+}
+
+func TestCrossCheckAddrs(t *testing.T) {
+	remote4 := ma.StringCast("/ip4/1.2.3.4/tcp/1234")
+	remote6 := ma.StringCast("/ip6/2600::1/udp/1234/quic-v1")
+	same4 := ma.StringCast("/ip4/1.2.3.4/udp/4321/quic-v1")
+	other4 := ma.StringCast("/ip4/5.6.7.8/tcp/1234")
+	same6 := ma.StringCast("/ip6/2600::2/tcp/1234")
+	other6 := ma.StringCast("/ip6/2600:0:0:1::1/tcp/1234")
+	dnsAddr := ma.StringCast("/dns/example.com/tcp/1234")
+	relayAddr := ma.StringCast("/ip4/5.6.7.8/tcp/1234/p2p/QmcgpsyWgH8Y8ajJz1Cu72KnS5uo2Aa2LpzU7kinSupXvB/p2p-circuit")
+	addrs := []ma.Multiaddr{same4, other4, same6, other6, dnsAddr, relayAddr}
+
+	consistent, inconsistent := crossCheckAddrs(addrs, remote4)
+	require.Equal(t, []ma.Multiaddr{same4, same6, other6, dnsAddr, relayAddr}, consistent)
+	require.Equal(t, []ma.Multiaddr{other4}, inconsistent)
+
+	consistent, inconsistent = crossCheckAddrs(addrs, remote6)
+	require.Equal(t, []ma.Multiaddr{same4, other4, same6, dnsAddr, relayAddr}, consistent)
+	require.Equal(t, []ma.Multiaddr{other6}, inconsistent)
+
+	// connections from non-public addresses aren't checked
+	consistent, inconsistent = crossCheckAddrs(addrs, ma.StringCast("/ip4/192.168.1.1/tcp/1"))
+	require.Equal(t, addrs, consistent)
+	require.Empty(t, inconsistent)
+}
@@ -971,3 +971,96 @@ func waitForDisconnectNotification(swarm *swarm.Swarm) <-chan struct{} {
 
 	return done
 }
+
+func TestIdentifyRefresh(t *testing.T) {
+	const interval = 200 * time.Millisecond
+	h1 := blhost.NewBlankHost(swarmt.GenSwarm(t))
+	h2 := blhost.NewBlankHost(swarmt.GenSwarm(t))
+	defer h2.Close()
+	defer h1.Close()
+
+	ids1, err := identify.NewIDService(h1)
+	require.NoError(t, err)
+	defer ids1.Close()
+	ids1.Start()
+
+	ids2, err := identify.NewIDService(h2, identify.WithRefreshInterval(interval, 50*time.Millisecond))
+	require.NoError(t, err)
+	defer ids2.Close()
+	ids2.Start()
+	// h2 doesn't accept pushes, so it can only learn about changes by refreshing.
+	h2.RemoveStreamHandler(identify.IDPush)
+
+	sub, err := h2.EventBus().Subscribe(new(event.EvtPeerProtocolsUpdated))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	_, ok := ids2.LastIdentified(h1.ID())
+	require.False(t, ok)
+
+	require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+	ids2.IdentifyConn(h2.Network().ConnsToPeer(h1.ID())[0])
+	first, ok := ids2.LastIdentified(h1.ID())
+	require.True(t, ok)
+
+	h1.SetStreamHandler("rand", func(network.Stream) {})
+	select {
+	case e := <-sub.Out():
+		evt := e.(event.EvtPeerProtocolsUpdated)
+		require.Equal(t, h1.ID(), evt.Peer)
+		require.Equal(t, []protocol.ID{"rand"}, evt.Added)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the protocols to be updated by a refresh")
+	}
+	last, ok := ids2.LastIdentified(h1.ID())
+	require.True(t, ok)
+	require.GreaterOrEqual(t, last.Sub(first), interval)
+	sup, err := h2.Peerstore().SupportsProtocols(h1.ID(), "rand")
+	require.NoError(t, err)
+	require.Equal(t, []protocol.ID{"rand"}, sup)
+}
+
+func TestIdentifyPushRateLimit(t *testing.T) {
+	const minInterval = time.Second
+	h1 := blhost.NewBlankHost(swarmt.GenSwarm(t))
+	h2 := blhost.NewBlankHost(swarmt.GenSwarm(t))
+	defer h2.Close()
+	defer h1.Close()
+
+	ids1, err := identify.NewIDService(h1, identify.WithPushCoalescing(10*time.Millisecond, minInterval))
+	require.NoError(t, err)
+	defer ids1.Close()
+	ids1.Start()
+
+	ids2, err := identify.NewIDService(h2)
+	require.NoError(t, err)
+	defer ids2.Close()
+	ids2.Start()
+
+	sub, err := h2.EventBus().Subscribe(new(event.EvtPeerProtocolsUpdated))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+	ids2.IdentifyConn(h2.Network().ConnsToPeer(h1.ID())[0])
+
+	waitForProtocol := func(p protocol.ID) time.Time {
+		t.Helper()
+		for {
+			select {
+			case e := <-sub.Out():
+				if slices.Contains(e.(event.EvtPeerProtocolsUpdated).Added, p) {
+					return time.Now()
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("expected %s to be pushed", p)
+			}
+		}
+	}
+
+	h1.SetStreamHandler("first", func(network.Stream) {})
+	first := waitForProtocol("first")
+	h1.SetStreamHandler("second", func(network.Stream) {})
+	second := waitForProtocol("second")
+	require.GreaterOrEqual(t, second.Sub(first), minInterval-100*time.Millisecond)
+}
@@ -73,6 +73,30 @@ var (
 			Buckets:   buckets,
 		},
 	)
+	identifyWait = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "identify_wait_total",
+			Help:      "Identify Wait calls, by whether the connection was already identified or being identified",
+		},
+		[]string{"cache"},
+	)
+	pushesSuppressed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "pushes_suppressed_total",
+			Help:      "Identify pushes not sent, because they were coalesced with another push or rate limited",
+		},
+		[]string{"reason"},
+	)
+	pushFanout = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "push_fanout",
+			Help:      "Number of peers an identify push was sent to",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 14),
+		},
+	)
 	collectors = []prometheus.Collector{
 		pushesTriggered,
 		identify,
@@ -82,6 +106,9 @@ var (
 		addrsCount,
 		numProtocolsReceived,
 		numAddrsReceived,
+		identifyWait,
+		pushesSuppressed,
+		pushFanout,
 	}
 	// 1 to 20 and then up to 100 in steps of 5
 	buckets = append(
@@ -104,9 +131,31 @@ type MetricsTracer interface {
 	IdentifySent(isPush bool, numProtocols int, numAddrs int)
 }
 
+// ExtendedMetricsTracer is an optional interface a MetricsTracer can implement
+// to also track IdentifyWait calls, and the fanout and suppression of identify
+// pushes. The MetricsTracer returned by NewMetricsTracer implements it.
+type ExtendedMetricsTracer interface {
+	// IdentifyWait counts IdentifyWait calls. cached is true if the connection
+	// was already identified, or is being identified.
+	IdentifyWait(cached bool)
+
+	// PushesSent tracks the number of peers an identify push was sent to
+	PushesSent(numPeers int)
+
+	// PushCoalesced counts pushes that were merged into a pending push
+	PushCoalesced()
+
+	// PushesRateLimited counts pushes to peers that were delayed by the
+	// per-peer rate limit
+	PushesRateLimited(numPeers int)
+}
+
 type metricsTracer struct{}
 
-var _ MetricsTracer = &metricsTracer{}
+var (
+	_ MetricsTracer         = &metricsTracer{}
+	_ ExtendedMetricsTracer = &metricsTracer{}
+)
 
 type metricsTracerSetting struct {
 	reg prometheus.Registerer
@@ -194,6 +243,38 @@ func (t *metricsTracer) ConnPushSupport(support identifyPushSupport) {
 	connPushSupportTotal.WithLabelValues(*tags...).Inc()
 }
 
+func (t *metricsTracer) IdentifyWait(cached bool) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	if cached {
+		*tags = append(*tags, "hit")
+	} else {
+		*tags = append(*tags, "miss")
+	}
+	identifyWait.WithLabelValues(*tags...).Inc()
+}
+
+func (t *metricsTracer) PushesSent(numPeers int) {
+	pushFanout.Observe(float64(numPeers))
+}
+
+func (t *metricsTracer) PushCoalesced() {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, "coalesced")
+	pushesSuppressed.WithLabelValues(*tags...).Inc()
+}
+
+func (t *metricsTracer) PushesRateLimited(numPeers int) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, "rate_limited")
+	pushesSuppressed.WithLabelValues(*tags...).Add(float64(numPeers))
+}
+
 func getPushSupport(s identifyPushSupport) string {
 	switch s {
 	case identifyPushSupported:
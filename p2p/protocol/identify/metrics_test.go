@@ -23,11 +23,16 @@ func TestMetricsNoAllocNoCover(t *testing.T) {
 	}
 
 	tr := NewMetricsTracer()
+	ext := tr.(ExtendedMetricsTracer)
 	tests := map[string]func(){
-		"TriggeredPushes":  func() { tr.TriggeredPushes(events[rand.Intn(len(events))]) },
-		"ConnPushSupport":  func() { tr.ConnPushSupport(pushSupport[rand.Intn(len(pushSupport))]) },
-		"IdentifyReceived": func() { tr.IdentifyReceived(rand.Intn(2) == 0, rand.Intn(20), rand.Intn(20)) },
-		"IdentifySent":     func() { tr.IdentifySent(rand.Intn(2) == 0, rand.Intn(20), rand.Intn(20)) },
+		"TriggeredPushes":   func() { tr.TriggeredPushes(events[rand.Intn(len(events))]) },
+		"ConnPushSupport":   func() { tr.ConnPushSupport(pushSupport[rand.Intn(len(pushSupport))]) },
+		"IdentifyReceived":  func() { tr.IdentifyReceived(rand.Intn(2) == 0, rand.Intn(20), rand.Intn(20)) },
+		"IdentifySent":      func() { tr.IdentifySent(rand.Intn(2) == 0, rand.Intn(20), rand.Intn(20)) },
+		"IdentifyWait":      func() { ext.IdentifyWait(rand.Intn(2) == 0) },
+		"PushesSent":        func() { ext.PushesSent(rand.Intn(100)) },
+		"PushCoalesced":     func() { ext.PushCoalesced() },
+		"PushesRateLimited": func() { ext.PushesRateLimited(rand.Intn(100)) },
 	}
 	for method, f := range tests {
 		allocs := testing.AllocsPerRun(1000, f)
@@ -20,9 +20,11 @@ type natEmitter struct {
 	reachability    network.Reachability
 	eventInterval   time.Duration
 
-	currentUDPNATDeviceType  network.NATDeviceType
-	currentTCPNATDeviceType  network.NATDeviceType
-	emitNATDeviceTypeChanged event.Emitter
+	currentUDPNATDeviceType   network.NATDeviceType
+	currentTCPNATDeviceType   network.NATDeviceType
+	emitNATDeviceTypeChanged  event.Emitter
+	currentNATDeviceTypes     event.EvtNATDeviceTypesChanged
+	emitNATDeviceTypesChanged event.Emitter
 
 	observedAddrMgr *ObservedAddrManager
 }
@@ -47,6 +49,12 @@ func newNATEmitter(h host.Host, o *ObservedAddrManager, eventInterval time.Durat
 	}
 	n.emitNATDeviceTypeChanged = emitter
 
+	typesEmitter, err := h.EventBus().Emitter(new(event.EvtNATDeviceTypesChanged), eventbus.Stateful)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create emitter for NATDeviceTypes: %s", err)
+	}
+	n.emitNATDeviceTypesChanged = typesEmitter
+
 	n.wg.Add(1)
 	go n.worker()
 	return n, nil
@@ -94,20 +102,29 @@ func (n *natEmitter) worker() {
 func (n *natEmitter) maybeNotify() {
 	if n.reachability == network.ReachabilityPrivate {
 		tcpNATType, udpNATType := n.observedAddrMgr.getNATType()
-		if tcpNATType != n.currentTCPNATDeviceType {
-			n.currentTCPNATDeviceType = tcpNATType
+		if tcpNATType.NatDeviceType != n.currentTCPNATDeviceType {
+			n.currentTCPNATDeviceType = tcpNATType.NatDeviceType
 			n.emitNATDeviceTypeChanged.Emit(event.EvtNATDeviceTypeChanged{
 				TransportProtocol: network.NATTransportTCP,
 				NatDeviceType:     n.currentTCPNATDeviceType,
+				Confidence:        tcpNATType.Confidence,
+				Evidence:          tcpNATType.Evidence,
 			})
 		}
-		if udpNATType != n.currentUDPNATDeviceType {
-			n.currentUDPNATDeviceType = udpNATType
+		if udpNATType.NatDeviceType != n.currentUDPNATDeviceType {
+			n.currentUDPNATDeviceType = udpNATType.NatDeviceType
 			n.emitNATDeviceTypeChanged.Emit(event.EvtNATDeviceTypeChanged{
 				TransportProtocol: network.NATTransportUDP,
 				NatDeviceType:     n.currentUDPNATDeviceType,
+				Confidence:        udpNATType.Confidence,
+				Evidence:          udpNATType.Evidence,
 			})
 		}
+		types := event.EvtNATDeviceTypesChanged{UDP: udpNATType, TCP: tcpNATType}
+		if types != n.currentNATDeviceTypes {
+			n.currentNATDeviceTypes = types
+			n.emitNATDeviceTypesChanged.Emit(types)
+		}
 	}
 }
 
@@ -116,4 +133,5 @@ func (n *natEmitter) Close() {
 	n.wg.Wait()
 	n.reachabilitySub.Close()
 	n.emitNATDeviceTypeChanged.Close()
+	n.emitNATDeviceTypesChanged.Close()
 }
@@ -8,6 +8,7 @@ import (
 	"sort"
 	"sync"
 
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/network"
 
 	ma "github.com/multiformats/go-multiaddr"
@@ -133,6 +134,53 @@ func (s *observerSet) cacheMultiaddr(addr ma.Multiaddr) ma.Multiaddr {
 	return s.cachedMultiaddrs[addrStr]
 }
 
+// ObservedAddrOverride overrides whether an observed address is advertised,
+// irrespective of how many peers observed it.
+type ObservedAddrOverride int
+
+const (
+	// ObservedAddrNoOverride advertises the address once enough peers
+	// observed it. See ActivationThresh.
+	ObservedAddrNoOverride ObservedAddrOverride = iota
+	// ObservedAddrPinned advertises the address as soon as a single peer
+	// observed it.
+	ObservedAddrPinned
+	// ObservedAddrVetoed never advertises the address.
+	ObservedAddrVetoed
+)
+
+func (ov ObservedAddrOverride) String() string {
+	switch ov {
+	case ObservedAddrNoOverride:
+		return "none"
+	case ObservedAddrPinned:
+		return "pinned"
+	case ObservedAddrVetoed:
+		return "vetoed"
+	default:
+		return "unknown"
+	}
+}
+
+// ObservedAddr is an external address of ours, as observed by other peers.
+type ObservedAddr struct {
+	// Addr is the thin waist form of the observed address, e.g.
+	// /ip4/1.2.3.4/tcp/4001.
+	Addr ma.Multiaddr
+	// Local is the thin waist form of the local address Addr was observed on.
+	Local ma.Multiaddr
+	// Observations is the number of connections that observed Addr.
+	Observations int
+	// Observers is the number of distinct observers of Addr. All peers behind
+	// the same IPv4 address, or the same IPv6 /56 prefix, count as one
+	// observer.
+	Observers int
+	// Activated is whether Addr is advertised.
+	Activated bool
+	// Override is the override set for Addr with SetOverride.
+	Override ObservedAddrOverride
+}
+
 type observation struct {
 	conn     connMultiaddrs
 	observed ma.Multiaddr
@@ -166,6 +214,8 @@ type ObservedAddrManager struct {
 	// localMultiaddr => thin waist form with the count of the connections the multiaddr
 	// was seen on for tracking our local listen addresses
 	localAddrs map[string]*thinWaistWithCount
+	// external thin waist => override set by the user
+	overrides map[string]ObservedAddrOverride
 }
 
 // NewObservedAddrManager returns a new address manager using peerstore.OwnObservedAddressTTL as the TTL.
@@ -178,6 +228,7 @@ func NewObservedAddrManager(listenAddrs, hostAddrs func() []ma.Multiaddr,
 		externalAddrs:        make(map[string]map[string]*observerSet),
 		connObservedTWAddrs:  make(map[connMultiaddrs]ma.Multiaddr),
 		localAddrs:           make(map[string]*thinWaistWithCount),
+		overrides:            make(map[string]ObservedAddrOverride),
 		wch:                  make(chan observation, observedAddrManagerWorkerChannelSize),
 		addrRecordedNotif:    make(chan struct{}, 1),
 		listenAddrs:          listenAddrs,
@@ -275,14 +326,35 @@ func (o *ObservedAddrManager) Addrs() []ma.Multiaddr {
 	return addrs
 }
 
+// getTopExternalAddrs returns the observed addresses to advertise for the local
+// thin waist address: the pinned ones, and the ones observed by the most
+// observers, as long as they were observed by at least ActivationThresh
+// observers and aren't vetoed.
 func (o *ObservedAddrManager) getTopExternalAddrs(localTWStr string) []*observerSet {
 	observerSets := make([]*observerSet, 0, len(o.externalAddrs[localTWStr]))
-	for _, v := range o.externalAddrs[localTWStr] {
-		if len(v.ObservedBy) >= ActivationThresh {
+	pinned := 0
+	for observedTWStr, v := range o.externalAddrs[localTWStr] {
+		switch o.overrides[observedTWStr] {
+		case ObservedAddrPinned:
+			pinned++
 			observerSets = append(observerSets, v)
+		case ObservedAddrVetoed:
+		default:
+			if len(v.ObservedBy) >= ActivationThresh {
+				observerSets = append(observerSets, v)
+			}
 		}
 	}
 	slices.SortFunc(observerSets, func(a, b *observerSet) int {
+		// Pinned addresses always make the cut
+		aPinned := o.overrides[string(a.ObservedTWAddr.Bytes())] == ObservedAddrPinned
+		bPinned := o.overrides[string(b.ObservedTWAddr.Bytes())] == ObservedAddrPinned
+		if aPinned != bPinned {
+			if aPinned {
+				return -1
+			}
+			return 1
+		}
 		diff := len(b.ObservedBy) - len(a.ObservedBy)
 		if diff != 0 {
 			return diff
@@ -300,13 +372,73 @@ func (o *ObservedAddrManager) getTopExternalAddrs(localTWStr string) []*observer
 		}
 
 	})
-	n := len(observerSets)
-	if n > maxExternalThinWaistAddrsPerLocalAddr {
-		n = maxExternalThinWaistAddrsPerLocalAddr
-	}
+	n := min(len(observerSets), max(maxExternalThinWaistAddrsPerLocalAddr, pinned))
 	return observerSets[:n]
 }
 
+// ObservedAddrs returns all the addresses observed on our current
+// connections, activated or not, along with how confident we are in them.
+func (o *ObservedAddrManager) ObservedAddrs() []ObservedAddr {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	var res []ObservedAddr
+	for localTWStr, m := range o.externalAddrs {
+		local, err := ma.NewMultiaddrBytes([]byte(localTWStr))
+		if err != nil {
+			continue
+		}
+		activated := make(map[*observerSet]bool)
+		for _, s := range o.getTopExternalAddrs(localTWStr) {
+			activated[s] = true
+		}
+		for observedTWStr, s := range m {
+			observations := 0
+			for _, n := range s.ObservedBy {
+				observations += n
+			}
+			res = append(res, ObservedAddr{
+				Addr:         s.ObservedTWAddr,
+				Local:        local,
+				Observations: observations,
+				Observers:    len(s.ObservedBy),
+				Activated:    activated[s],
+				Override:     o.overrides[observedTWStr],
+			})
+		}
+	}
+	slices.SortFunc(res, func(a, b ObservedAddr) int {
+		if c := b.Observers - a.Observers; c != 0 {
+			return c
+		}
+		if c := a.Local.Compare(b.Local); c != 0 {
+			return c
+		}
+		return a.Addr.Compare(b.Addr)
+	})
+	return res
+}
+
+// SetOverride overrides whether the observed address addr is advertised.
+// Only the thin waist part of addr is considered, so pinning
+// /ip4/1.2.3.4/udp/4001/quic-v1 also pins /ip4/1.2.3.4/udp/4001/webrtc-direct.
+// Overrides apply to future observations as well, and can be removed by
+// setting ObservedAddrNoOverride.
+func (o *ObservedAddrManager) SetOverride(addr ma.Multiaddr, ov ObservedAddrOverride) error {
+	tw, err := thinWaistForm(o.normalize(addr))
+	if err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if ov == ObservedAddrNoOverride {
+		delete(o.overrides, string(tw.TW.Bytes()))
+	} else {
+		o.overrides[string(tw.TW.Bytes())] = ov
+	}
+	return nil
+}
+
 // Record enqueues an observation for recording
 func (o *ObservedAddrManager) Record(conn connMultiaddrs, observed ma.Multiaddr) {
 	select {
@@ -540,12 +672,11 @@ func (o *ObservedAddrManager) removeConn(conn connMultiaddrs) {
 	}
 }
 
-func (o *ObservedAddrManager) getNATType() (tcpNATType, udpNATType network.NATDeviceType) {
+func (o *ObservedAddrManager) getNATType() (tcpNATType, udpNATType event.NATDeviceTypeInfo) {
 	o.mu.RLock()
 	defer o.mu.RUnlock()
 
 	var tcpCounts, udpCounts []int
-	var tcpTotal, udpTotal int
 	for _, m := range o.externalAddrs {
 		isTCP := false
 		for _, v := range m {
@@ -557,42 +688,40 @@ func (o *ObservedAddrManager) getNATType() (tcpNATType, udpNATType network.NATDe
 		for _, v := range m {
 			if isTCP {
 				tcpCounts = append(tcpCounts, len(v.ObservedBy))
-				tcpTotal += len(v.ObservedBy)
 			} else {
 				udpCounts = append(udpCounts, len(v.ObservedBy))
-				udpTotal += len(v.ObservedBy)
 			}
 		}
 	}
+	return natDeviceType(tcpCounts), natDeviceType(udpCounts)
+}
 
-	sort.Sort(sort.Reverse(sort.IntSlice(tcpCounts)))
-	sort.Sort(sort.Reverse(sort.IntSlice(udpCounts)))
-
-	tcpTopCounts, udpTopCounts := 0, 0
-	for i := 0; i < maxExternalThinWaistAddrsPerLocalAddr && i < len(tcpCounts); i++ {
-		tcpTopCounts += tcpCounts[i]
+// natDeviceType determines the NAT device type from the number of
+// observations of every external address.
+func natDeviceType(counts []int) event.NATDeviceTypeInfo {
+	sort.Sort(sort.Reverse(sort.IntSlice(counts)))
+	ev := network.NATDeviceTypeEvidence{ExternalAddrs: len(counts)}
+	for i, c := range counts {
+		ev.Observations += c
+		if i < maxExternalThinWaistAddrsPerLocalAddr {
+			ev.TopObservations += c
+		}
 	}
-	for i := 0; i < maxExternalThinWaistAddrsPerLocalAddr && i < len(udpCounts); i++ {
-		udpTopCounts += udpCounts[i]
+	info := event.NATDeviceTypeInfo{Evidence: ev}
+	if ev.Observations < 3*maxExternalThinWaistAddrsPerLocalAddr {
+		return info
 	}
-
 	// If the top elements cover more than 1/2 of all the observations, there's a > 50% chance that
 	// hole punching based on outputs of observed address manager will succeed
-	if tcpTotal >= 3*maxExternalThinWaistAddrsPerLocalAddr {
-		if tcpTopCounts >= tcpTotal/2 {
-			tcpNATType = network.NATDeviceTypeCone
-		} else {
-			tcpNATType = network.NATDeviceTypeSymmetric
-		}
-	}
-	if udpTotal >= 3*maxExternalThinWaistAddrsPerLocalAddr {
-		if udpTopCounts >= udpTotal/2 {
-			udpNATType = network.NATDeviceTypeCone
-		} else {
-			udpNATType = network.NATDeviceTypeSymmetric
-		}
+	top := float64(ev.TopObservations) / float64(ev.Observations)
+	if ev.TopObservations >= ev.Observations/2 {
+		info.NatDeviceType = network.NATDeviceTypeCone
+		info.Confidence = top
+	} else {
+		info.NatDeviceType = network.NATDeviceTypeSymmetric
+		info.Confidence = 1 - top
 	}
-	return
+	return info
 }
 
 func (o *ObservedAddrManager) Close() error {
@@ -176,6 +176,49 @@ func TestObservedAddrManager(t *testing.T) {
 		}, 1*time.Second, 100*time.Millisecond)
 	})
 
+	t.Run("Overrides", func(t *testing.T) {
+		o := newObservedAddrMgr()
+		defer o.Close()
+		observed1 := ma.StringCast("/ip4/2.2.2.2/tcp/2")
+		observed2 := ma.StringCast("/ip4/3.3.3.3/tcp/2")
+		c1 := newConn(tcp4ListenAddr, ma.StringCast("/ip4/1.2.3.1/tcp/1"))
+		o.Record(c1, observed1)
+		for i := 0; i < ActivationThresh; i++ {
+			o.Record(newConn(tcp4ListenAddr, ma.StringCast(fmt.Sprintf("/ip4/1.2.4.%d/tcp/1", i))), observed2)
+		}
+		require.EventuallyWithT(t, func(t *assert.CollectT) {
+			matest.AssertEqualMultiaddrs(t, o.Addrs(), []ma.Multiaddr{observed2})
+		}, 1*time.Second, 100*time.Millisecond)
+
+		obs := o.ObservedAddrs()
+		require.Len(t, obs, 2)
+		require.True(t, obs[0].Addr.Equal(observed2))
+		require.True(t, obs[0].Local.Equal(tcp4ListenAddr))
+		require.Equal(t, ActivationThresh, obs[0].Observers)
+		require.Equal(t, ActivationThresh, obs[0].Observations)
+		require.True(t, obs[0].Activated)
+		require.True(t, obs[1].Addr.Equal(observed1))
+		require.Equal(t, 1, obs[1].Observers)
+		require.False(t, obs[1].Activated)
+
+		// Pin the address observed once, and veto the other one
+		require.NoError(t, o.SetOverride(observed1, ObservedAddrPinned))
+		require.NoError(t, o.SetOverride(observed2, ObservedAddrVetoed))
+		matest.AssertEqualMultiaddrs(t, o.Addrs(), []ma.Multiaddr{observed1})
+		obs = o.ObservedAddrs()
+		require.Len(t, obs, 2)
+		require.False(t, obs[0].Activated)
+		require.Equal(t, ObservedAddrVetoed, obs[0].Override)
+		require.True(t, obs[1].Activated)
+		require.Equal(t, ObservedAddrPinned, obs[1].Override)
+
+		require.NoError(t, o.SetOverride(observed1, ObservedAddrNoOverride))
+		require.NoError(t, o.SetOverride(observed2, ObservedAddrNoOverride))
+		matest.AssertEqualMultiaddrs(t, o.Addrs(), []ma.Multiaddr{observed2})
+
+		require.Error(t, o.SetOverride(ma.StringCast("/dns/example.com/tcp/1"), ObservedAddrPinned))
+	})
+
 	t.Run("SameObservers", func(t *testing.T) {
 		o := newObservedAddrMgr()
 		defer o.Close()
@@ -427,8 +470,15 @@ func TestObservedAddrManager(t *testing.T) {
 		}, 1*time.Second, 100*time.Millisecond)
 
 		tcpNAT, udpNAT := o.getNATType()
-		require.Equal(t, tcpNAT, network.NATDeviceTypeUnknown)
-		require.Equal(t, udpNAT, network.NATDeviceTypeCone)
+		require.Equal(t, tcpNAT.NatDeviceType, network.NATDeviceTypeUnknown)
+		require.Zero(t, tcpNAT.Confidence)
+		require.Equal(t, udpNAT.NatDeviceType, network.NATDeviceTypeCone)
+		require.Equal(t, 1.0, udpNAT.Confidence)
+		require.Equal(t, network.NATDeviceTypeEvidence{
+			Observations:    len(udpConns),
+			ExternalAddrs:   1,
+			TopObservations: len(udpConns),
+		}, udpNAT.Evidence)
 	})
 	t.Run("NATTypeSymmetric", func(t *testing.T) {
 		o := newObservedAddrMgr()
@@ -455,8 +505,14 @@ func TestObservedAddrManager(t *testing.T) {
 		}, 1*time.Second, 100*time.Millisecond)
 
 		tcpNAT, udpNAT := o.getNATType()
-		require.Equal(t, tcpNAT, network.NATDeviceTypeSymmetric)
-		require.Equal(t, udpNAT, network.NATDeviceTypeSymmetric)
+		require.Equal(t, tcpNAT.NatDeviceType, network.NATDeviceTypeSymmetric)
+		require.Equal(t, udpNAT.NatDeviceType, network.NATDeviceTypeSymmetric)
+		for _, info := range []event.NATDeviceTypeInfo{tcpNAT, udpNAT} {
+			require.Equal(t, 20, info.Evidence.ExternalAddrs)
+			require.Equal(t, N, info.Evidence.Observations)
+			require.InDelta(t, 1-float64(info.Evidence.TopObservations)/N, info.Confidence, 1e-9)
+			require.Greater(t, info.Confidence, 0.5)
+		}
 
 		for i := 0; i < N; i++ {
 			o.removeConn(tcpConns[i])
@@ -519,6 +575,21 @@ func TestObservedAddrManager(t *testing.T) {
 		evt := e.(event.EvtNATDeviceTypeChanged)
 		require.Equal(t, evt.TransportProtocol, network.NATTransportUDP)
 		require.Equal(t, evt.NatDeviceType, network.NATDeviceTypeCone)
+		require.Equal(t, 1.0, evt.Confidence)
+
+		// the per-protocol event is sticky
+		typesSub, err := bus.Subscribe(new(event.EvtNATDeviceTypesChanged))
+		require.NoError(t, err)
+		defer typesSub.Close()
+		select {
+		case e = <-typesSub.Out():
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected NAT types event")
+		}
+		types := e.(event.EvtNATDeviceTypesChanged)
+		require.Equal(t, network.NATDeviceTypeCone, types.UDP.NatDeviceType)
+		require.Equal(t, network.NATDeviceTypeUnknown, types.TCP.NatDeviceType)
+		require.Equal(t, len(udpConns), types.UDP.Evidence.Observations)
 	})
 	t.Run("Many connection many observations IP4 And IP6", func(t *testing.T) {
 		o := newObservedAddrMgr()
@@ -9,6 +9,11 @@ type config struct {
 	metricsTracer              MetricsTracer
 	disableObservedAddrManager bool
 	timeout                    time.Duration
+	refreshInterval            time.Duration
+	refreshJitter              time.Duration
+	pushDebounce               time.Duration
+	pushMinInterval            time.Duration
+	addrCrossCheck             AddrCrossCheck
 }
 
 // Option is an option function for identify.
@@ -57,3 +62,60 @@ func WithTimeout(timeout time.Duration) Option {
 		cfg.timeout = timeout
 	}
 }
+
+// WithPushCoalescing limits identify pushes when our addresses or protocols
+// change rapidly, e.g. when a NAT mapping is flapping. Pushes are delayed by
+// debounce, so that all changes within that window are sent in a single push.
+// No peer is pushed to more than once per minInterval; pushes that are
+// suppressed are sent with the latest state once the interval has elapsed.
+// Either is disabled if 0, the default.
+func WithPushCoalescing(debounce, minInterval time.Duration) Option {
+	return func(cfg *config) {
+		cfg.pushDebounce = debounce
+		cfg.pushMinInterval = minInterval
+	}
+}
+
+// WithRefreshInterval periodically re-runs identify on connections, to pick up
+// address and protocol changes of peers that don't send identify pushes. A
+// connection is refreshed when the peer wasn't identified for interval plus a
+// random duration of up to jitter. Only one connection per peer is refreshed.
+// Refreshing is disabled if interval is 0, the default.
+func WithRefreshInterval(interval, jitter time.Duration) Option {
+	return func(cfg *config) {
+		cfg.refreshInterval = interval
+		cfg.refreshJitter = jitter
+	}
+}
+
+// AddrCrossCheck is what identify does with the listen addresses claimed by a
+// peer that are inconsistent with the source of the connection. See
+// WithAddrCrossCheck.
+type AddrCrossCheck int
+
+const (
+	// AddrCrossCheckDisabled keeps all claimed addresses. This is the default.
+	AddrCrossCheckDisabled AddrCrossCheck = iota
+	// AddrCrossCheckDownrank keeps inconsistent addresses only for
+	// peerstore.RecentlyConnectedAddrTTL, and drops them first when the peer
+	// claims too many addresses.
+	AddrCrossCheckDownrank
+	// AddrCrossCheckDiscard drops inconsistent addresses.
+	AddrCrossCheckDiscard
+)
+
+// WithAddrCrossCheck checks the listen addresses claimed by peers against the
+// source address of the connection. When a peer connects from a public IP
+// address, a claimed address is inconsistent if it has a different IP address
+// of the same family (for IPv6, outside of the same /64 prefix). Such
+// addresses may point at a third party, using us for dial amplification.
+// Addresses without an IP address, like DNS and relay addresses, are never
+// inconsistent.
+//
+// Non-public addresses claimed by peers connecting from a public IP address
+// are dropped regardless of this option.
+func WithAddrCrossCheck(mode AddrCrossCheck) Option {
+	return func(cfg *config) {
+		cfg.addrCrossCheck = mode
+	}
+}
@@ -0,0 +1,114 @@
+package identify
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// maxRefreshCheckInterval bounds the interval at which connections are
+// checked for needing a refresh.
+const maxRefreshCheckInterval = time.Minute
+
+// LastIdentified returns when we last received an identify message, either an
+// identify response or an identify push, from p on any of our current
+// connections to p. It returns false if p wasn't identified yet.
+func (ids *idService) LastIdentified(p peer.ID) (time.Time, bool) {
+	var last time.Time
+	ids.connsMu.RLock()
+	defer ids.connsMu.RUnlock()
+	for _, c := range ids.Host.Network().ConnsToPeer(p) {
+		if e, ok := ids.conns[c]; ok && e.LastIdentified.After(last) {
+			last = e.LastIdentified
+		}
+	}
+	return last, !last.IsZero()
+}
+
+// nextRefresh returns when a connection identified at t should be refreshed.
+// It returns the zero time if refreshing is disabled.
+func (ids *idService) nextRefresh(t time.Time) time.Time {
+	if ids.refreshInterval <= 0 {
+		return time.Time{}
+	}
+	next := t.Add(ids.refreshInterval)
+	if ids.refreshJitter > 0 {
+		next = next.Add(rand.N(ids.refreshJitter))
+	}
+	return next
+}
+
+func (ids *idService) refreshLoop(ctx context.Context) {
+	defer ids.refCount.Done()
+
+	t := time.NewTicker(max(min(ids.refreshInterval/10, maxRefreshCheckInterval), time.Millisecond))
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			ids.refreshConns(ctx, now)
+		}
+	}
+}
+
+// refreshConns re-runs identify on the connections that are due for a
+// refresh.
+func (ids *idService) refreshConns(ctx context.Context, now time.Time) {
+	ids.connsMu.Lock()
+	lastIdentified := make(map[peer.ID]time.Time, len(ids.conns))
+	for c, e := range ids.conns {
+		if e.LastIdentified.After(lastIdentified[c.RemotePeer()]) {
+			lastIdentified[c.RemotePeer()] = e.LastIdentified
+		}
+	}
+	var conns []network.Conn
+	for c, e := range ids.conns {
+		if e.NextRefresh.IsZero() || now.Before(e.NextRefresh) || c.Stat().Limited {
+			continue
+		}
+		p := c.RemotePeer()
+		if last := lastIdentified[p]; now.Sub(last) < ids.refreshInterval {
+			// The peer was identified recently on another connection.
+			e.NextRefresh = ids.nextRefresh(last)
+		} else {
+			// The next refresh is scheduled once this one completes.
+			e.NextRefresh = time.Time{}
+			lastIdentified[p] = now
+			conns = append(conns, c)
+		}
+		ids.conns[c] = e
+	}
+	ids.connsMu.Unlock()
+
+	sem := make(chan struct{}, maxPushConcurrency)
+	var wg sync.WaitGroup
+	for _, c := range conns {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		}
+		wg.Add(1)
+		go func(c network.Conn) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := ids.identifyConn(c); err != nil {
+				log.Debugw("failed to refresh identify", "peer", c.RemotePeer(), "error", err)
+				ids.connsMu.Lock()
+				if e, ok := ids.conns[c]; ok {
+					e.NextRefresh = ids.nextRefresh(time.Now())
+					ids.conns[c] = e
+				}
+				ids.connsMu.Unlock()
+			}
+		}(c)
+	}
+	wg.Wait()
+}
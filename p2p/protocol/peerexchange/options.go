@@ -0,0 +1,70 @@
+package peerexchange
+
+import (
+	"errors"
+	"time"
+
+	"github.com/libp2p/go-libp2p/x/rate"
+)
+
+// Option configures the peer exchange service.
+type Option func(*PeerExchange) error
+
+// WithFilter sets the filter deciding which peer records are shared with a
+// requester. Only records of connected peers are considered, and the
+// requester's own record is never shared. By default, all other records are
+// shared.
+func WithFilter(f Filter) Option {
+	return func(px *PeerExchange) error {
+		if f == nil {
+			return errors.New("filter must not be nil")
+		}
+		px.filter = f
+		return nil
+	}
+}
+
+// WithMaxPeers sets the maximum number of peer records sent in a response.
+// Defaults to DefaultMaxPeers.
+func WithMaxPeers(n int) Option {
+	return func(px *PeerExchange) error {
+		if n <= 0 {
+			return errors.New("max peers must be positive")
+		}
+		px.maxPeers = n
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout for serving and making requests. Defaults to
+// DefaultTimeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(px *PeerExchange) error {
+		if timeout <= 0 {
+			return errors.New("timeout must be positive")
+		}
+		px.timeout = timeout
+		return nil
+	}
+}
+
+// WithAddrTTL sets the TTL of the addresses learned from peer records.
+// Defaults to peerstore.AddressTTL.
+func WithAddrTTL(ttl time.Duration) Option {
+	return func(px *PeerExchange) error {
+		px.addrTTL = ttl
+		return nil
+	}
+}
+
+// WithRateLimiter sets the rate limiter for incoming requests. Requests
+// exceeding the limit are reset with network.StreamRateLimited.
+func WithRateLimiter(l *rate.Limiter) Option {
+	return func(px *PeerExchange) error {
+		if l == nil {
+			return errors.New("rate limiter must not be nil")
+		}
+		px.rateLimiter = l
+		return nil
+	}
+}
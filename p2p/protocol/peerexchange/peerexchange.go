@@ -0,0 +1,316 @@
+// Package peerexchange implements a simple peer exchange protocol.
+//
+// Connected peers can ask each other for a sample of the signed peer records
+// of the peers they're connected to. Since every record is signed by the peer
+// it describes, the responder can't forge addresses for other peers. This makes peer exchange
+// usable as a lightweight discovery mechanism for networks that don't run a
+// DHT.
+package peerexchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/record"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+	"github.com/libp2p/go-libp2p/p2p/protocol/peerexchange/pb"
+	"github.com/libp2p/go-libp2p/x/rate"
+	"github.com/libp2p/go-msgio/pbio"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var log = logging.Logger("peerexchange")
+
+const (
+	// ID is the protocol ID of the peer exchange protocol.
+	ID = "/libp2p/peer-exchange/1.0.0"
+	// ServiceName is the name of the peer exchange service in the resource
+	// manager.
+	ServiceName = "libp2p.peer-exchange"
+
+	// DefaultMaxPeers is the default maximum number of peer records sent in a
+	// response.
+	DefaultMaxPeers = 16
+	// DefaultTimeout is the default timeout for a peer exchange request.
+	DefaultTimeout = 10 * time.Second
+
+	maxMsgSize = 64 << 10
+)
+
+var (
+	defaultNetworkPrefixRateLimits = []rate.PrefixLimit{
+		{Prefix: netip.MustParsePrefix("127.0.0.0/8"), Limit: rate.Limit{}}, // inf
+		{Prefix: netip.MustParsePrefix("::1/128"), Limit: rate.Limit{}},     // inf
+	}
+	defaultGlobalRateLimit      = rate.Limit{RPS: 10, Burst: 20}
+	defaultIPv4SubnetRateLimits = []rate.SubnetLimit{
+		{PrefixLength: 24, Limit: rate.Limit{RPS: 0.1, Burst: 5}}, // 1 every 10 seconds
+	}
+	defaultIPv6SubnetRateLimits = []rate.SubnetLimit{
+		{PrefixLength: 56, Limit: rate.Limit{RPS: 0.1, Burst: 5}},  // 1 every 10 seconds
+		{PrefixLength: 48, Limit: rate.Limit{RPS: 0.2, Burst: 10}}, // 1 every 5 seconds
+	}
+)
+
+// Filter decides whether the record of peer p is shared with the requester.
+type Filter func(requester, p peer.ID) bool
+
+// PeerExchange is the peer exchange service. It serves requests for peer
+// records and can request peer records from other peers.
+type PeerExchange struct {
+	host        host.Host
+	filter      Filter
+	maxPeers    int
+	timeout     time.Duration
+	addrTTL     time.Duration
+	rateLimiter *rate.Limiter
+
+	sub       event.Subscription
+	refCount  sync.WaitGroup
+	closeOnce sync.Once
+
+	mx sync.Mutex
+	// records are the signed peer records of connected peers, as received
+	// by identify.
+	records map[peer.ID]*record.Envelope
+}
+
+// New creates a peer exchange service and sets the stream handler on the
+// host. The service learns the signed peer records of connected peers from
+// identify, so it should be created before the host connects to other peers.
+func New(h host.Host, opts ...Option) (*PeerExchange, error) {
+	px := &PeerExchange{
+		host:     h,
+		records:  make(map[peer.ID]*record.Envelope),
+		maxPeers: DefaultMaxPeers,
+		timeout:  DefaultTimeout,
+		addrTTL:  peerstore.AddressTTL,
+		rateLimiter: &rate.Limiter{
+			GlobalLimit:         defaultGlobalRateLimit,
+			NetworkPrefixLimits: defaultNetworkPrefixRateLimits,
+			SubnetRateLimiter: rate.SubnetLimiter{
+				IPv4SubnetLimits: defaultIPv4SubnetRateLimits,
+				IPv6SubnetLimits: defaultIPv6SubnetRateLimits,
+				GracePeriod:      1 * time.Minute,
+			},
+		},
+	}
+	px.filter = func(requester, p peer.ID) bool { return true }
+	for _, o := range opts {
+		if err := o(px); err != nil {
+			return nil, err
+		}
+	}
+
+	sub, err := h.EventBus().Subscribe([]interface{}{
+		new(event.EvtPeerIdentificationCompleted),
+		new(event.EvtPeerConnectednessChanged),
+	}, eventbus.Name("peerexchange"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to events: %w", err)
+	}
+	px.sub = sub
+	px.refCount.Add(1)
+	go px.background()
+
+	h.SetStreamHandler(ID, px.rateLimiter.Limit(px.handleRequest))
+	return px, nil
+}
+
+// Close removes the stream handler from the host and stops the service.
+func (px *PeerExchange) Close() error {
+	px.closeOnce.Do(func() {
+		px.host.RemoveStreamHandler(ID)
+		px.sub.Close()
+		px.refCount.Wait()
+	})
+	return nil
+}
+
+func (px *PeerExchange) background() {
+	defer px.refCount.Done()
+	for e := range px.sub.Out() {
+		switch evt := e.(type) {
+		case event.EvtPeerIdentificationCompleted:
+			if evt.SignedPeerRecord == nil {
+				continue
+			}
+			px.mx.Lock()
+			px.records[evt.Peer] = evt.SignedPeerRecord
+			px.mx.Unlock()
+		case event.EvtPeerConnectednessChanged:
+			if evt.Connectedness != network.NotConnected {
+				continue
+			}
+			px.mx.Lock()
+			delete(px.records, evt.Peer)
+			px.mx.Unlock()
+		}
+	}
+}
+
+func (px *PeerExchange) handleRequest(s network.Stream) {
+	if err := s.Scope().SetService(ServiceName); err != nil {
+		log.Debugf("error attaching stream to peer exchange service: %s", err)
+		s.Reset()
+		return
+	}
+	if err := s.Scope().ReserveMemory(maxMsgSize, network.ReservationPriorityAlways); err != nil {
+		log.Debugf("error reserving memory for peer exchange stream: %s", err)
+		s.Reset()
+		return
+	}
+	defer s.Scope().ReleaseMemory(maxMsgSize)
+
+	s.SetDeadline(time.Now().Add(px.timeout))
+	requester := s.Conn().RemotePeer()
+
+	var req pb.Request
+	if err := pbio.NewDelimitedReader(s, maxMsgSize).ReadMsg(&req); err != nil {
+		log.Debugf("error reading peer exchange request from %s: %s", requester, err)
+		s.Reset()
+		return
+	}
+	n := px.maxPeers
+	if m := int(req.GetMaxPeers()); m > 0 && m < n {
+		n = m
+	}
+
+	resp := pb.Response{PeerRecords: px.sample(requester, n)}
+	if err := pbio.NewDelimitedWriter(s).WriteMsg(&resp); err != nil {
+		log.Debugf("error writing peer exchange response to %s: %s", requester, err)
+		s.Reset()
+		return
+	}
+	s.Close()
+}
+
+// sample returns up to n marshaled signed peer records of randomly selected
+// connected peers that pass the filter. The requester's own record is never
+// included.
+func (px *PeerExchange) sample(requester peer.ID, n int) [][]byte {
+	px.mx.Lock()
+	envs := make([]*record.Envelope, 0, len(px.records))
+	peers := make([]peer.ID, 0, len(px.records))
+	for p, env := range px.records {
+		peers = append(peers, p)
+		envs = append(envs, env)
+	}
+	px.mx.Unlock()
+	rand.Shuffle(len(peers), func(i, j int) {
+		peers[i], peers[j] = peers[j], peers[i]
+		envs[i], envs[j] = envs[j], envs[i]
+	})
+
+	records := make([][]byte, 0, n)
+	size := 0
+	for i, p := range peers {
+		if len(records) >= n {
+			break
+		}
+		if p == requester || !px.filter(requester, p) {
+			continue
+		}
+		b, err := envs[i].Marshal()
+		if err != nil {
+			log.Debugf("error marshaling peer record of %s: %s", p, err)
+			continue
+		}
+		// Don't exceed the maximum message size of the requester.
+		fieldSize := protowire.SizeTag(1) + protowire.SizeBytes(len(b))
+		if size+fieldSize > maxMsgSize {
+			break
+		}
+		size += fieldSize
+		records = append(records, b)
+	}
+	return records
+}
+
+// Request asks peer p for up to maxPeers signed peer records. If maxPeers is
+// 0, the responder decides how many records to send. The addresses of all
+// valid records are added to the peerstore, and returned. Records with invalid
+// signatures are dropped.
+func (px *PeerExchange) Request(ctx context.Context, p peer.ID, maxPeers int) ([]peer.AddrInfo, error) {
+	if maxPeers < 0 {
+		return nil, errors.New("maxPeers must not be negative")
+	}
+	s, err := px.host.NewStream(ctx, p, ID)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	if err := s.Scope().SetService(ServiceName); err != nil {
+		s.Reset()
+		return nil, fmt.Errorf("error attaching stream to peer exchange service: %w", err)
+	}
+	if err := s.Scope().ReserveMemory(maxMsgSize, network.ReservationPriorityAlways); err != nil {
+		s.Reset()
+		return nil, fmt.Errorf("error reserving memory for peer exchange stream: %w", err)
+	}
+	defer s.Scope().ReleaseMemory(maxMsgSize)
+
+	deadline := time.Now().Add(px.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	s.SetDeadline(deadline)
+
+	if err := pbio.NewDelimitedWriter(s).WriteMsg(&pb.Request{MaxPeers: uint32(maxPeers)}); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	if err := s.CloseWrite(); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	var resp pb.Response
+	if err := pbio.NewDelimitedReader(s, maxMsgSize).ReadMsg(&resp); err != nil {
+		s.Reset()
+		return nil, err
+	}
+
+	records := resp.GetPeerRecords()
+	if maxPeers > 0 && len(records) > maxPeers {
+		records = records[:maxPeers]
+	}
+	cab, hasCAB := peerstore.GetCertifiedAddrBook(px.host.Peerstore())
+	infos := make([]peer.AddrInfo, 0, len(records))
+	for _, b := range records {
+		env, rec, err := record.ConsumeEnvelope(b, peer.PeerRecordEnvelopeDomain)
+		if err != nil {
+			log.Debugf("invalid peer record from %s: %s", p, err)
+			continue
+		}
+		pr, ok := rec.(*peer.PeerRecord)
+		if !ok || pr.PeerID == px.host.ID() {
+			continue
+		}
+		if !pr.PeerID.MatchesPublicKey(env.PublicKey) {
+			log.Debugf("peer record from %s for %s signed by another key", p, pr.PeerID)
+			continue
+		}
+		if hasCAB {
+			if _, err := cab.ConsumePeerRecord(env, px.addrTTL); err != nil {
+				log.Debugf("error storing peer record of %s: %s", pr.PeerID, err)
+				continue
+			}
+		} else {
+			px.host.Peerstore().AddAddrs(pr.PeerID, pr.Addrs, px.addrTTL)
+		}
+		infos = append(infos, peer.AddrInfo{ID: pr.PeerID, Addrs: pr.Addrs})
+	}
+	return infos, nil
+}
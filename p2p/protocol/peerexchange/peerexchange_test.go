@@ -0,0 +1,184 @@
+package peerexchange_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/record"
+	"github.com/libp2p/go-libp2p/core/test"
+	"github.com/libp2p/go-libp2p/p2p/protocol/peerexchange"
+	"github.com/libp2p/go-libp2p/p2p/protocol/peerexchange/pb"
+	"github.com/libp2p/go-libp2p/x/rate"
+
+	"github.com/libp2p/go-msgio/pbio"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func newHost(t *testing.T) host.Host {
+	t.Helper()
+	h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func newPeerExchange(t *testing.T, h host.Host, opts ...peerexchange.Option) *peerexchange.PeerExchange {
+	t.Helper()
+	px, err := peerexchange.New(h, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { px.Close() })
+	return px
+}
+
+func connect(t *testing.T, a, b host.Host) {
+	t.Helper()
+	require.NoError(t, a.Connect(context.Background(), peer.AddrInfo{ID: b.ID(), Addrs: b.Addrs()}))
+}
+
+// requestN requests peer records until n records are returned.
+func requestN(t *testing.T, px *peerexchange.PeerExchange, p peer.ID, maxPeers, n int) []peer.AddrInfo {
+	t.Helper()
+	var infos []peer.AddrInfo
+	require.Eventually(t, func() bool {
+		var err error
+		infos, err = px.Request(context.Background(), p, maxPeers)
+		return err == nil && len(infos) == n
+	}, 5*time.Second, 50*time.Millisecond)
+	return infos
+}
+
+func TestPeerExchange(t *testing.T) {
+	a, b, c := newHost(t), newHost(t), newHost(t)
+	pxa := newPeerExchange(t, a)
+	newPeerExchange(t, b)
+	connect(t, b, a)
+	connect(t, b, c)
+
+	infos := requestN(t, pxa, b.ID(), 0, 1)
+	require.Equal(t, c.ID(), infos[0].ID)
+	require.ElementsMatch(t, c.Addrs(), infos[0].Addrs)
+
+	// The record was added to a's peerstore, so a can connect to c.
+	require.ElementsMatch(t, c.Addrs(), a.Peerstore().Addrs(c.ID()))
+	require.NoError(t, a.Connect(context.Background(), peer.AddrInfo{ID: c.ID()}))
+
+	// Records of disconnected peers aren't shared.
+	require.NoError(t, b.Network().ClosePeer(c.ID()))
+	requestN(t, pxa, b.ID(), 0, 0)
+}
+
+func TestMaxPeers(t *testing.T) {
+	a, b := newHost(t), newHost(t)
+	pxa := newPeerExchange(t, a)
+	newPeerExchange(t, b, peerexchange.WithMaxPeers(3))
+	connect(t, b, a)
+	for i := 0; i < 4; i++ {
+		connect(t, b, newHost(t))
+	}
+
+	requestN(t, pxa, b.ID(), 0, 3)
+	infos := requestN(t, pxa, b.ID(), 2, 2)
+	for _, ai := range infos {
+		require.NotEqual(t, a.ID(), ai.ID)
+		require.NotEqual(t, b.ID(), ai.ID)
+	}
+
+	_, err := peerexchange.New(a, peerexchange.WithMaxPeers(0))
+	require.Error(t, err)
+}
+
+func TestFilter(t *testing.T) {
+	a, b, c, d := newHost(t), newHost(t), newHost(t), newHost(t)
+	pxa := newPeerExchange(t, a)
+	newPeerExchange(t, b, peerexchange.WithFilter(func(requester, p peer.ID) bool {
+		return requester == a.ID() && p != c.ID()
+	}))
+	connect(t, b, a)
+	connect(t, b, c)
+	connect(t, b, d)
+
+	infos := requestN(t, pxa, b.ID(), 0, 1)
+	require.Equal(t, d.ID(), infos[0].ID)
+
+	_, err := peerexchange.New(a, peerexchange.WithFilter(nil))
+	require.Error(t, err)
+}
+
+func TestRateLimit(t *testing.T) {
+	a, b := newHost(t), newHost(t)
+	pxa := newPeerExchange(t, a)
+	newPeerExchange(t, b, peerexchange.WithRateLimiter(&rate.Limiter{
+		GlobalLimit: rate.Limit{RPS: 0.001, Burst: 1},
+	}))
+	connect(t, a, b)
+
+	_, err := pxa.Request(context.Background(), b.ID(), 0)
+	require.NoError(t, err)
+	_, err = pxa.Request(context.Background(), b.ID(), 0)
+	require.Error(t, err)
+}
+
+// noCABHost hides the certified address book of the host's peerstore.
+type noCABHost struct {
+	host.Host
+}
+
+type noCABPeerstore struct {
+	peerstore.Peerstore
+}
+
+func (h noCABHost) Peerstore() peerstore.Peerstore {
+	return noCABPeerstore{h.Host.Peerstore()}
+}
+
+func TestRecordSignedByOtherKey(t *testing.T) {
+	sealRecord := func(sk crypto.PrivKey, id peer.ID, addr ma.Multiaddr) []byte {
+		t.Helper()
+		env, err := record.Seal(&peer.PeerRecord{PeerID: id, Addrs: []ma.Multiaddr{addr}, Seq: 1}, sk)
+		require.NoError(t, err)
+		b, err := env.Marshal()
+		require.NoError(t, err)
+		return b
+	}
+	attackerKey, _, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+	require.NoError(t, err)
+	honestKey, _, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+	require.NoError(t, err)
+	honest, err := peer.IDFromPrivateKey(honestKey)
+	require.NoError(t, err)
+	victim := test.RandPeerIDFatal(t)
+	honestAddr := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+	forgedAddr := ma.StringCast("/ip4/6.6.6.6/tcp/1")
+	records := [][]byte{
+		sealRecord(attackerKey, victim, forgedAddr),
+		sealRecord(honestKey, honest, honestAddr),
+	}
+
+	for _, withCAB := range []bool{true, false} {
+		a, b := newHost(t), newHost(t)
+		b.SetStreamHandler(peerexchange.ID, func(s network.Stream) {
+			pbio.NewDelimitedWriter(s).WriteMsg(&pb.Response{PeerRecords: records})
+			s.Close()
+		})
+		var h host.Host = a
+		if !withCAB {
+			h = noCABHost{a}
+		}
+		pxa := newPeerExchange(t, h)
+		connect(t, a, b)
+
+		infos, err := pxa.Request(context.Background(), b.ID(), 0)
+		require.NoError(t, err)
+		require.Equal(t, []peer.AddrInfo{{ID: honest, Addrs: []ma.Multiaddr{honestAddr}}}, infos)
+		require.Empty(t, a.Peerstore().Addrs(victim))
+		require.Equal(t, []ma.Multiaddr{honestAddr}, a.Peerstore().Addrs(honest))
+	}
+}
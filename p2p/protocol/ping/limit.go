@@ -0,0 +1,57 @@
+package ping
+
+import (
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"golang.org/x/time/rate"
+)
+
+const limiterSweepInterval = time.Minute
+
+// peerLimiter is a per-peer token bucket rate limiter. Buckets that have been
+// refilled completely are dropped periodically, so the limiter doesn't grow
+// with the number of peers that ever pinged us.
+type peerLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mx        sync.Mutex
+	buckets   map[peer.ID]*rate.Limiter
+	lastSweep time.Time
+}
+
+func newPeerLimiter(limit rate.Limit, burst int) *peerLimiter {
+	return &peerLimiter{
+		limit:     limit,
+		burst:     burst,
+		buckets:   make(map[peer.ID]*rate.Limiter),
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *peerLimiter) Allow(p peer.ID) bool {
+	return l.allowAt(p, time.Now())
+}
+
+func (l *peerLimiter) allowAt(p peer.ID, now time.Time) bool {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	if now.Sub(l.lastSweep) > limiterSweepInterval {
+		l.lastSweep = now
+		for id, b := range l.buckets {
+			if b.TokensAt(now) >= float64(l.burst) {
+				delete(l.buckets, id)
+			}
+		}
+	}
+
+	b, ok := l.buckets[p]
+	if !ok {
+		b = rate.NewLimiter(l.limit, l.burst)
+		l.buckets[p] = b
+	}
+	return b.AllowN(now, 1)
+}
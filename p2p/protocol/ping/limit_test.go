@@ -0,0 +1,30 @@
+package ping
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeerLimiter(t *testing.T) {
+	l := newPeerLimiter(1, 2)
+	now := time.Now()
+	p1, p2 := peer.ID("p1"), peer.ID("p2")
+
+	require.True(t, l.allowAt(p1, now))
+	require.True(t, l.allowAt(p1, now))
+	require.False(t, l.allowAt(p1, now))
+	// buckets are per peer
+	require.True(t, l.allowAt(p2, now))
+
+	now = now.Add(time.Second)
+	require.True(t, l.allowAt(p1, now))
+	require.False(t, l.allowAt(p1, now))
+
+	// full buckets are swept
+	now = now.Add(2 * limiterSweepInterval)
+	require.True(t, l.allowAt(p1, now))
+	require.Len(t, l.buckets, 1)
+}
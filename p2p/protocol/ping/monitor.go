@@ -0,0 +1,248 @@
+package ping
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/host/probecoord"
+)
+
+const defaultMonitorWindow = 20
+
+// Stats are rolling RTT statistics computed over the most recent probes of a
+// Monitor.
+type Stats struct {
+	// Sent is the number of probes in the current window.
+	Sent int
+	// Received is the number of successful probes in the current window.
+	Received int
+	// Loss is the fraction of failed probes in the current window, in [0, 1].
+	Loss float64
+
+	Min time.Duration
+	Avg time.Duration
+	P95 time.Duration
+
+	// Last is the result of the most recent probe.
+	Last Result
+}
+
+// MonitorEvent is emitted by a Monitor after every probe.
+type MonitorEvent struct {
+	Stats Stats
+	// RTTBreached is set if the p95 RTT exceeds the configured RTT threshold.
+	RTTBreached bool
+	// LossBreached is set if the loss exceeds the configured loss threshold.
+	LossBreached bool
+}
+
+// Breached reports whether any of the configured thresholds was exceeded.
+func (e MonitorEvent) Breached() bool {
+	return e.RTTBreached || e.LossBreached
+}
+
+type monitorConfig struct {
+	window        int
+	rttThreshold  time.Duration
+	lossThreshold float64
+	breachesOnly  bool
+	coordinator   *probecoord.Coordinator
+}
+
+// MonitorOption configures a Monitor.
+type MonitorOption func(*monitorConfig) error
+
+// WithWindow sets the number of probes the rolling statistics are computed over.
+func WithWindow(n int) MonitorOption {
+	return func(c *monitorConfig) error {
+		if n <= 0 {
+			return errors.New("monitor window must be positive")
+		}
+		c.window = n
+		return nil
+	}
+}
+
+// WithRTTThreshold flags events whose p95 RTT exceeds d.
+func WithRTTThreshold(d time.Duration) MonitorOption {
+	return func(c *monitorConfig) error {
+		if d <= 0 {
+			return errors.New("RTT threshold must be positive")
+		}
+		c.rttThreshold = d
+		return nil
+	}
+}
+
+// WithLossThreshold flags events whose loss exceeds f, a fraction in [0, 1).
+func WithLossThreshold(f float64) MonitorOption {
+	return func(c *monitorConfig) error {
+		if f < 0 || f >= 1 {
+			return errors.New("loss threshold must be in [0, 1)")
+		}
+		c.lossThreshold = f
+		return nil
+	}
+}
+
+// WithBreachesOnly only emits events for which a threshold was breached.
+func WithBreachesOnly() MonitorOption {
+	return func(c *monitorConfig) error {
+		c.breachesOnly = true
+		return nil
+	}
+}
+
+// WithProbeCoordinator schedules probes with the timers of c, so that they are
+// jittered and spaced out from the other background probes of the host.
+func WithProbeCoordinator(c *probecoord.Coordinator) MonitorOption {
+	return func(cfg *monitorConfig) error {
+		cfg.coordinator = c
+		return nil
+	}
+}
+
+// Monitor uses the ping service's host to monitor p. See the package-level
+// Monitor function.
+func (ps *PingService) Monitor(ctx context.Context, p peer.ID, interval time.Duration, opts ...MonitorOption) (<-chan MonitorEvent, error) {
+	return Monitor(ctx, ps.Host, p, interval, opts...)
+}
+
+// Monitor pings the remote peer every interval until the context is canceled,
+// emitting rolling RTT statistics after every probe. Unlike Ping, a failed
+// probe doesn't end monitoring: it's counted as lost, and a new stream is
+// opened for the next probe.
+func Monitor(ctx context.Context, h host.Host, p peer.ID, interval time.Duration, opts ...MonitorOption) (<-chan MonitorEvent, error) {
+	if interval <= 0 {
+		return nil, errors.New("monitor interval must be positive")
+	}
+	cfg := monitorConfig{window: defaultMonitorWindow}
+	for _, o := range opts {
+		if err := o(&cfg); err != nil {
+			return nil, err
+		}
+	}
+	ra, err := newRandReader()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan MonitorEvent)
+	go func() {
+		defer close(out)
+
+		w := newRTTWindow(cfg.window)
+		t := cfg.coordinator.NewTimer(interval)
+		defer t.Stop()
+		for {
+			// measure the interval from the start of the probe, like a ticker
+			t.Reset(interval)
+			res := probe(ctx, h, p, ra)
+			if ctx.Err() != nil {
+				return
+			}
+			if res.Error == nil {
+				h.Peerstore().RecordLatency(p, res.RTT)
+			}
+			w.add(res)
+
+			ev := MonitorEvent{Stats: w.stats()}
+			ev.RTTBreached = cfg.rttThreshold > 0 && ev.Stats.Received > 0 && ev.Stats.P95 > cfg.rttThreshold
+			ev.LossBreached = cfg.lossThreshold > 0 && ev.Stats.Loss > cfg.lossThreshold
+			if !cfg.breachesOnly || ev.Breached() {
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// probe runs a single ping round trip on a fresh stream.
+func probe(ctx context.Context, h host.Host, p peer.ID, ra io.Reader) Result {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	s, err := newPingStream(ctx, h, p)
+	if err != nil {
+		return Result{Error: err}
+	}
+	stop := context.AfterFunc(ctx, func() { s.Reset() })
+	defer stop()
+
+	rtt, err := ping(s, ra, PingSize)
+	if err != nil {
+		s.Reset()
+		return Result{Error: err}
+	}
+	s.Close()
+	return Result{RTT: rtt}
+}
+
+// rttWindow is a ring buffer of the most recent probe results.
+type rttWindow struct {
+	results []Result
+	next    int
+	full    bool
+}
+
+func newRTTWindow(size int) *rttWindow {
+	return &rttWindow{results: make([]Result, size)}
+}
+
+func (w *rttWindow) add(r Result) {
+	w.results[w.next] = r
+	w.next = (w.next + 1) % len(w.results)
+	if w.next == 0 {
+		w.full = true
+	}
+}
+
+func (w *rttWindow) stats() Stats {
+	n := w.next
+	if w.full {
+		n = len(w.results)
+	}
+	var st Stats
+	if n == 0 {
+		return st
+	}
+	st.Last = w.results[(w.next+len(w.results)-1)%len(w.results)]
+	st.Sent = n
+
+	rtts := make([]time.Duration, 0, n)
+	var sum time.Duration
+	for _, r := range w.results[:n] {
+		if r.Error != nil {
+			continue
+		}
+		rtts = append(rtts, r.RTT)
+		sum += r.RTT
+	}
+	st.Received = len(rtts)
+	st.Loss = float64(st.Sent-st.Received) / float64(st.Sent)
+	if len(rtts) == 0 {
+		return st
+	}
+	slices.Sort(rtts)
+	st.Min = rtts[0]
+	st.Avg = sum / time.Duration(len(rtts))
+	// nearest-rank percentile
+	idx := (95*len(rtts)+99)/100 - 1
+	st.P95 = rtts[idx]
+	return st
+}
@@ -0,0 +1,37 @@
+package ping
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRTTWindowStats(t *testing.T) {
+	w := newRTTWindow(4)
+	require.Equal(t, Stats{}, w.stats())
+
+	w.add(Result{RTT: 30 * time.Millisecond})
+	w.add(Result{RTT: 10 * time.Millisecond})
+	w.add(Result{Error: errors.New("lost")})
+	st := w.stats()
+	require.Equal(t, 3, st.Sent)
+	require.Equal(t, 2, st.Received)
+	require.InDelta(t, 1.0/3, st.Loss, 1e-9)
+	require.Equal(t, 10*time.Millisecond, st.Min)
+	require.Equal(t, 20*time.Millisecond, st.Avg)
+	require.Equal(t, 30*time.Millisecond, st.P95)
+	require.Error(t, st.Last.Error)
+
+	// evict the oldest two results
+	w.add(Result{RTT: 20 * time.Millisecond})
+	w.add(Result{RTT: 40 * time.Millisecond})
+	w.add(Result{RTT: 50 * time.Millisecond})
+	st = w.stats()
+	require.Equal(t, 4, st.Sent)
+	require.Equal(t, 3, st.Received)
+	require.Equal(t, 20*time.Millisecond, st.Min)
+	require.Equal(t, 50*time.Millisecond, st.P95)
+	require.Equal(t, 50*time.Millisecond, st.Last.RTT)
+}
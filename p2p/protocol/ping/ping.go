@@ -6,8 +6,10 @@ import (
 	"crypto/rand"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 	mrand "math/rand"
+	"sync/atomic"
 	"time"
 
 	logging "github.com/ipfs/go-log/v2"
@@ -15,10 +17,13 @@ import (
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"golang.org/x/time/rate"
 )
 
 var log = logging.Logger("ping")
 
+var errRateLimited = errors.New("ping rate limit exceeded")
+
 const (
 	PingSize     = 32
 	pingTimeout  = 10 * time.Second
@@ -31,14 +36,51 @@ const (
 
 type PingService struct {
 	Host host.Host
+
+	payloadSize int
+	limiter     *peerLimiter
+	violations  atomic.Uint64
+}
+
+// Option configures a PingService.
+type Option func(*PingService)
+
+// WithPerPeerRateLimit caps the rate at which the ping handler echoes packets
+// of PingSize bytes for any single peer, across all of its streams. Streams
+// of peers exceeding the limit are reset, and counted as violations.
+func WithPerPeerRateLimit(rps float64, burst int) Option {
+	return func(ps *PingService) {
+		if rps <= 0 || burst <= 0 {
+			ps.limiter = nil
+			return
+		}
+		ps.limiter = newPeerLimiter(rate.Limit(rps), burst)
+	}
+}
+
+// WithServicePayloadSize sets the payload size used by the service's Ping
+// method. See WithPayloadSize.
+func WithServicePayloadSize(n int) Option {
+	return func(ps *PingService) {
+		ps.payloadSize = n
+	}
 }
 
-func NewPingService(h host.Host) *PingService {
-	ps := &PingService{h}
+func NewPingService(h host.Host, opts ...Option) *PingService {
+	ps := &PingService{Host: h}
+	for _, o := range opts {
+		o(ps)
+	}
 	h.SetStreamHandler(ID, ps.PingHandler)
 	return ps
 }
 
+// Violations returns the number of streams that were reset for exceeding the
+// per-peer rate limit.
+func (ps *PingService) Violations() uint64 {
+	return ps.violations.Load()
+}
+
 func (p *PingService) PingHandler(s network.Stream) {
 	if err := s.Scope().SetService(ServiceName); err != nil {
 		log.Debugf("error attaching stream to ping service: %s", err)
@@ -84,6 +126,13 @@ func (p *PingService) PingHandler(s network.Stream) {
 			return
 		}
 
+		if p.limiter != nil && !p.limiter.Allow(s.Conn().RemotePeer()) {
+			p.violations.Add(1)
+			s.ResetWithError(network.StreamRateLimited)
+			errCh <- errRateLimited
+			return
+		}
+
 		_, err = s.Write(buf)
 		if err != nil {
 			errCh <- err
@@ -100,8 +149,27 @@ type Result struct {
 	Error error
 }
 
-func (ps *PingService) Ping(ctx context.Context, p peer.ID) <-chan Result {
-	return Ping(ctx, ps.Host, p)
+func (ps *PingService) Ping(ctx context.Context, p peer.ID, opts ...PingOption) <-chan Result {
+	if ps.payloadSize != 0 {
+		opts = append([]PingOption{WithPayloadSize(ps.payloadSize)}, opts...)
+	}
+	return Ping(ctx, ps.Host, p, opts...)
+}
+
+type pingConfig struct {
+	payloadSize int
+}
+
+// PingOption configures a call to Ping.
+type PingOption func(*pingConfig)
+
+// WithPayloadSize sets the number of bytes sent in every ping. This is useful
+// for path MTU probing. The size must be a positive multiple of PingSize: the
+// remote echoes the payload in PingSize chunks. Defaults to PingSize.
+func WithPayloadSize(n int) PingOption {
+	return func(c *pingConfig) {
+		c.payloadSize = n
+	}
 }
 
 func pingError(err error) chan Result {
@@ -113,25 +181,25 @@ func pingError(err error) chan Result {
 
 // Ping pings the remote peer until the context is canceled, returning a stream
 // of RTTs or errors.
-func Ping(ctx context.Context, h host.Host, p peer.ID) <-chan Result {
-	s, err := h.NewStream(network.WithAllowLimitedConn(ctx, "ping"), p, ID)
-	if err != nil {
-		return pingError(err)
+func Ping(ctx context.Context, h host.Host, p peer.ID, opts ...PingOption) <-chan Result {
+	cfg := pingConfig{payloadSize: PingSize}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	if cfg.payloadSize <= 0 || cfg.payloadSize%PingSize != 0 {
+		return pingError(fmt.Errorf("invalid ping payload size %d: must be a positive multiple of %d", cfg.payloadSize, PingSize))
 	}
 
-	if err := s.Scope().SetService(ServiceName); err != nil {
-		log.Debugf("error attaching stream to ping service: %s", err)
-		s.Reset()
+	s, err := newPingStream(ctx, h, p)
+	if err != nil {
 		return pingError(err)
 	}
 
-	b := make([]byte, 8)
-	if _, err := rand.Read(b); err != nil {
-		log.Errorf("failed to get cryptographic random: %s", err)
+	ra, err := newRandReader()
+	if err != nil {
 		s.Reset()
 		return pingError(err)
 	}
-	ra := mrand.New(mrand.NewSource(int64(binary.BigEndian.Uint64(b))))
 
 	ctx, cancel := context.WithCancel(ctx)
 
@@ -142,7 +210,7 @@ func Ping(ctx context.Context, h host.Host, p peer.ID) <-chan Result {
 
 		for ctx.Err() == nil {
 			var res Result
-			res.RTT, res.Error = ping(s, ra)
+			res.RTT, res.Error = ping(s, ra, cfg.payloadSize)
 
 			// canceled, ignore everything.
 			if ctx.Err() != nil {
@@ -169,15 +237,40 @@ func Ping(ctx context.Context, h host.Host, p peer.ID) <-chan Result {
 	return out
 }
 
-func ping(s network.Stream, randReader io.Reader) (time.Duration, error) {
-	if err := s.Scope().ReserveMemory(2*PingSize, network.ReservationPriorityAlways); err != nil {
+// newPingStream opens a ping stream to p and attaches it to the ping service.
+func newPingStream(ctx context.Context, h host.Host, p peer.ID) (network.Stream, error) {
+	s, err := h.NewStream(network.WithAllowLimitedConn(ctx, "ping"), p, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Scope().SetService(ServiceName); err != nil {
+		log.Debugf("error attaching stream to ping service: %s", err)
+		s.Reset()
+		return nil, err
+	}
+	return s, nil
+}
+
+// newRandReader returns a fast, cryptographically seeded source for ping payloads.
+func newRandReader() (io.Reader, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		log.Errorf("failed to get cryptographic random: %s", err)
+		return nil, err
+	}
+	return mrand.New(mrand.NewSource(int64(binary.BigEndian.Uint64(b)))), nil
+}
+
+func ping(s network.Stream, randReader io.Reader, size int) (time.Duration, error) {
+	if err := s.Scope().ReserveMemory(2*size, network.ReservationPriorityAlways); err != nil {
 		log.Debugf("error reserving memory for ping stream: %s", err)
 		s.Reset()
 		return 0, err
 	}
-	defer s.Scope().ReleaseMemory(2 * PingSize)
+	defer s.Scope().ReleaseMemory(2 * size)
 
-	buf := pool.Get(PingSize)
+	buf := pool.Get(size)
 	defer pool.Put(buf)
 
 	if _, err := io.ReadFull(randReader, buf); err != nil {
@@ -189,7 +282,7 @@ func ping(s network.Stream, randReader io.Reader) (time.Duration, error) {
 		return 0, err
 	}
 
-	rbuf := pool.Get(PingSize)
+	rbuf := pool.Get(size)
 	defer pool.Put(rbuf)
 
 	if _, err := io.ReadFull(s, rbuf); err != nil {
@@ -200,5 +293,15 @@ func ping(s network.Stream, randReader io.Reader) (time.Duration, error) {
 		return 0, errors.New("ping packet was incorrect")
 	}
 
-	return time.Since(before), nil
+	rtt := time.Since(before)
+	if r, ok := s.Conn().(rttRecorder); ok {
+		r.RecordRTT(rtt)
+	}
+	return rtt, nil
+}
+
+// rttRecorder is implemented by connections that keep track of their RTT,
+// like swarm.Conn.
+type rttRecorder interface {
+	RecordRTT(time.Duration)
 }
@@ -7,36 +7,36 @@ import (
 
 	"github.com/libp2p/go-libp2p/core/peer"
 	bhost "github.com/libp2p/go-libp2p/p2p/host/basic"
+	"github.com/libp2p/go-libp2p/p2p/host/probecoord"
 	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
 	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
 
-	ma "github.com/multiformats/go-multiaddr"
 	"github.com/stretchr/testify/require"
 )
 
 func TestPing(t *testing.T) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	h1, h2 := connectedHosts(t)
+	ps1 := ping.NewPingService(h1)
+	ps2 := ping.NewPingService(h2)
+
+	testPing(t, ps1, h2.ID())
+	testPing(t, ps2, h1.ID())
+}
+
+// connectedHosts starts two hosts, and connects the first one to the second one.
+func connectedHosts(t *testing.T) (*bhost.BasicHost, *bhost.BasicHost) {
+	t.Helper()
 	h1, err := bhost.NewHost(swarmt.GenSwarm(t), nil)
 	require.NoError(t, err)
-	defer h1.Close()
+	t.Cleanup(func() { h1.Close() })
 	h1.Start()
 	h2, err := bhost.NewHost(swarmt.GenSwarm(t), nil)
 	require.NoError(t, err)
-	defer h2.Close()
+	t.Cleanup(func() { h2.Close() })
 	h2.Start()
 
-	err = h1.Connect(ctx, peer.AddrInfo{
-		ID:    h2.ID(),
-		Addrs: []ma.Multiaddr{h2.Addrs()[0]},
-	})
-	require.NoError(t, err)
-
-	ps1 := ping.NewPingService(h1)
-	ps2 := ping.NewPingService(h2)
-
-	testPing(t, ps1, h2.ID())
-	testPing(t, ps2, h1.ID())
+	require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()[:1]}))
+	return h1, h2
 }
 
 func testPing(t *testing.T, ps *ping.PingService, p peer.ID) {
@@ -55,3 +55,120 @@ func testPing(t *testing.T, ps *ping.PingService, p peer.ID) {
 	}
 
 }
+
+func TestMonitor(t *testing.T) {
+	h1, h2 := connectedHosts(t)
+	ps1 := ping.NewPingService(h1)
+	ping.NewPingService(h2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	evs, err := ps1.Monitor(ctx, h2.ID(), 10*time.Millisecond, ping.WithWindow(3), ping.WithRTTThreshold(time.Hour))
+	require.NoError(t, err)
+
+	for i := 1; i <= 5; i++ {
+		select {
+		case ev := <-evs:
+			require.NoError(t, ev.Stats.Last.Error)
+			require.Equal(t, min(i, 3), ev.Stats.Sent)
+			require.Equal(t, ev.Stats.Sent, ev.Stats.Received)
+			require.Zero(t, ev.Stats.Loss)
+			require.LessOrEqual(t, ev.Stats.Min, ev.Stats.Avg)
+			require.LessOrEqual(t, ev.Stats.Avg, ev.Stats.P95)
+			require.False(t, ev.Breached())
+		case <-time.After(5 * time.Second):
+			t.Fatal("failed to receive monitor event")
+		}
+	}
+
+	cancel()
+	require.Eventually(t, func() bool {
+		_, ok := <-evs
+		return !ok
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestMonitorLoss(t *testing.T) {
+	// h2 doesn't run the ping service, so every probe fails.
+	h1, h2 := connectedHosts(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	evs, err := ping.Monitor(ctx, h1, h2.ID(), 10*time.Millisecond, ping.WithLossThreshold(0.5), ping.WithBreachesOnly())
+	require.NoError(t, err)
+
+	select {
+	case ev := <-evs:
+		require.Error(t, ev.Stats.Last.Error)
+		require.True(t, ev.LossBreached)
+		require.Equal(t, 1.0, ev.Stats.Loss)
+	case <-time.After(5 * time.Second):
+		t.Fatal("failed to receive monitor event")
+	}
+}
+
+func TestMonitorProbeCoordinator(t *testing.T) {
+	h1, h2 := connectedHosts(t)
+	ping.NewPingService(h2)
+
+	// the minimum gap spaces out probes more than the interval does
+	const gap = 100 * time.Millisecond
+	c, err := probecoord.New(probecoord.WithMinGap(gap))
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	evs, err := ping.Monitor(ctx, h1, h2.ID(), time.Millisecond, ping.WithProbeCoordinator(c))
+	require.NoError(t, err)
+
+	// the first probe isn't timed, and the first timer finds the coordinator idle
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		select {
+		case ev := <-evs:
+			require.NoError(t, ev.Stats.Last.Error)
+		case <-time.After(5 * time.Second):
+			t.Fatal("failed to receive monitor event")
+		}
+	}
+	require.GreaterOrEqual(t, time.Since(start), 2*gap)
+}
+
+func TestPingPayloadSize(t *testing.T) {
+	h1, h2 := connectedHosts(t)
+	ps1 := ping.NewPingService(h1, ping.WithServicePayloadSize(40*ping.PingSize))
+	ping.NewPingService(h2)
+
+	testPing(t, ps1, h2.ID())
+
+	res := <-ping.Ping(context.Background(), h1, h2.ID(), ping.WithPayloadSize(ping.PingSize+1))
+	require.Error(t, res.Error)
+}
+
+func TestPingRateLimit(t *testing.T) {
+	h1, h2 := connectedHosts(t)
+	ping.NewPingService(h1)
+	ps2 := ping.NewPingService(h2, ping.WithPerPeerRateLimit(0.001, 3))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ts := ping.Ping(ctx, h1, h2.ID())
+	for i := 0; i < 3; i++ {
+		select {
+		case res := <-ts:
+			require.NoError(t, res.Error)
+		case <-time.After(4 * time.Second):
+			t.Fatal("failed to receive ping")
+		}
+	}
+	select {
+	case res := <-ts:
+		require.Error(t, res.Error)
+	case <-time.After(4 * time.Second):
+		t.Fatal("failed to receive ping")
+	}
+	require.Eventually(t, func() bool { return ps2.Violations() == 1 }, time.Second, 10*time.Millisecond)
+
+	// the limit applies per peer, not per stream
+	res := <-ping.Ping(ctx, h1, h2.ID())
+	require.Error(t, res.Error)
+}
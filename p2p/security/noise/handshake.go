@@ -231,6 +231,16 @@ func (s *secureSession) generateHandshakePayload(localStatic noise.DHKey, ext *p
 		return nil, fmt.Errorf("error sigining handshake payload: %w", err)
 	}
 
+	// advertise that we rekey, see WithRekeyInterval
+	if s.rekey {
+		if ext == nil {
+			ext = &pb.NoiseExtensions{}
+		} else {
+			ext = proto.Clone(ext).(*pb.NoiseExtensions)
+		}
+		ext.Rekey = proto.Bool(true)
+	}
+
 	// create payload
 	payloadEnc, err := proto.Marshal(&pb.NoiseHandshakePayload{
 		IdentityKey: localKeyRaw,
@@ -282,5 +292,9 @@ func (s *secureSession) handleRemoteHandshakePayload(payload []byte, remoteStati
 	// set remote peer key and id
 	s.remoteID = id
 	s.remoteKey = remotePubKey
+	// only rekey if the peer understands it. If the peer doesn't, the
+	// initiator doesn't advertise rekeying either, which is fine since the
+	// responder won't rekey anyway.
+	s.rekey = s.rekey && nhp.Extensions.GetRekey()
 	return nhp.Extensions, nil
 }
@@ -25,6 +25,8 @@ type NoiseExtensions struct {
 	state                  protoimpl.MessageState `protogen:"open.v1"`
 	WebtransportCerthashes [][]byte               `protobuf:"bytes,1,rep,name=webtransport_certhashes,json=webtransportCerthashes" json:"webtransport_certhashes,omitempty"`
 	StreamMuxers           []string               `protobuf:"bytes,2,rep,name=stream_muxers,json=streamMuxers" json:"stream_muxers,omitempty"`
+	ApplicationPayload     []byte                 `protobuf:"bytes,1000,opt,name=application_payload,json=applicationPayload" json:"application_payload,omitempty"`
+	Rekey                  *bool                  `protobuf:"varint,1001,opt,name=rekey" json:"rekey,omitempty"`
 	unknownFields          protoimpl.UnknownFields
 	sizeCache              protoimpl.SizeCache
 }
@@ -73,6 +75,20 @@ func (x *NoiseExtensions) GetStreamMuxers() []string {
 	return nil
 }
 
+func (x *NoiseExtensions) GetApplicationPayload() []byte {
+	if x != nil {
+		return x.ApplicationPayload
+	}
+	return nil
+}
+
+func (x *NoiseExtensions) GetRekey() bool {
+	if x != nil && x.Rekey != nil {
+		return *x.Rekey
+	}
+	return false
+}
+
 type NoiseHandshakePayload struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	IdentityKey   []byte                 `protobuf:"bytes,1,opt,name=identity_key,json=identityKey" json:"identity_key,omitempty"`
@@ -137,10 +153,12 @@ var File_p2p_security_noise_pb_payload_proto protoreflect.FileDescriptor
 
 const file_p2p_security_noise_pb_payload_proto_rawDesc = "" +
 	"\n" +
-	"#p2p/security/noise/pb/payload.proto\x12\x02pb\"o\n" +
+	"#p2p/security/noise/pb/payload.proto\x12\x02pb\"\xb8\x01\n" +
 	"\x0fNoiseExtensions\x127\n" +
 	"\x17webtransport_certhashes\x18\x01 \x03(\fR\x16webtransportCerthashes\x12#\n" +
-	"\rstream_muxers\x18\x02 \x03(\tR\fstreamMuxers\"\x92\x01\n" +
+	"\rstream_muxers\x18\x02 \x03(\tR\fstreamMuxers\x120\n" +
+	"\x13application_payload\x18\xe8\a \x01(\fR\x12applicationPayload\x12\x15\n" +
+	"\x05rekey\x18\xe9\a \x01(\bR\x05rekey\"\x92\x01\n" +
 	"\x15NoiseHandshakePayload\x12!\n" +
 	"\fidentity_key\x18\x01 \x01(\fR\videntityKey\x12!\n" +
 	"\fidentity_sig\x18\x02 \x01(\fR\videntitySig\x123\n" +
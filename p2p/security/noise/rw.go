@@ -3,6 +3,7 @@ package noise
 import (
 	"encoding/binary"
 	"io"
+	"time"
 
 	pool "github.com/libp2p/go-buffer-pool"
 	"golang.org/x/crypto/chacha20poly1305"
@@ -48,42 +49,56 @@ func (s *secureSession) Read(buf []byte) (int, error) {
 		return copied, nil
 	}
 
-	// length of the next encrypted message.
-	nextMsgLen, err := s.readNextInsecureMsgLen()
-	if err != nil {
-		return 0, err
-	}
-
-	// If the buffer is atleast as big as the encrypted message size,
-	// we can read AND decrypt in place.
-	if len(buf) >= nextMsgLen {
-		if err := s.readNextMsgInsecure(buf[:nextMsgLen]); err != nil {
+	for {
+		// length of the next encrypted message.
+		nextMsgLen, err := s.readNextInsecureMsgLen()
+		if err != nil {
 			return 0, err
 		}
 
-		dbuf, err := s.decrypt(buf[:0], buf[:nextMsgLen])
-		if err != nil {
+		// If the buffer is atleast as big as the encrypted message size,
+		// we can read AND decrypt in place.
+		if len(buf) >= nextMsgLen {
+			if err := s.readNextMsgInsecure(buf[:nextMsgLen]); err != nil {
+				return 0, err
+			}
+
+			dbuf, err := s.decrypt(buf[:0], buf[:nextMsgLen])
+			if err != nil {
+				return 0, err
+			}
+			if len(dbuf) == 0 && s.rekey {
+				// the peer rekeyed
+				s.dec.Rekey()
+				continue
+			}
+
+			return len(dbuf), nil
+		}
+
+		// otherwise, we get a buffer from the pool so we can read the message into it
+		// and then decrypt in place, since we're retaining the buffer (or a view thereof).
+		cbuf := pool.Get(nextMsgLen)
+		if err := s.readNextMsgInsecure(cbuf); err != nil {
 			return 0, err
 		}
 
-		return len(dbuf), nil
-	}
+		if s.qbuf, err = s.decrypt(cbuf[:0], cbuf); err != nil {
+			return 0, err
+		}
+		if len(s.qbuf) == 0 && s.rekey {
+			// the peer rekeyed
+			pool.Put(cbuf)
+			s.qbuf = nil
+			s.dec.Rekey()
+			continue
+		}
 
-	// otherwise, we get a buffer from the pool so we can read the message into it
-	// and then decrypt in place, since we're retaining the buffer (or a view thereof).
-	cbuf := pool.Get(nextMsgLen)
-	if err := s.readNextMsgInsecure(cbuf); err != nil {
-		return 0, err
-	}
+		// copy as many bytes as we can; update seek pointer.
+		s.qseek = copy(buf, s.qbuf)
 
-	if s.qbuf, err = s.decrypt(cbuf[:0], cbuf); err != nil {
-		return 0, err
+		return s.qseek, nil
 	}
-
-	// copy as many bytes as we can; update seek pointer.
-	s.qseek = copy(buf, s.qbuf)
-
-	return s.qseek, nil
 }
 
 // Write encrypts the plaintext `in` data and sends it on the
@@ -107,7 +122,15 @@ func (s *secureSession) Write(data []byte) (int, error) {
 	defer pool.Put(cbuf)
 
 	for written < total {
+		if err := s.maybeRekey(cbuf); err != nil {
+			return written, err
+		}
+
 		end := written + MaxPlaintextLength
+		if s.rekey && s.rekeyBytes > 0 && uint64(end-written) > s.rekeyBytes-s.sentSinceRekey {
+			// don't encrypt more than rekeyBytes with one key
+			end = written + int(s.rekeyBytes-s.sentSinceRekey)
+		}
 		if end > total {
 			end = total
 		}
@@ -123,11 +146,38 @@ func (s *secureSession) Write(data []byte) (int, error) {
 		if err != nil {
 			return written, err
 		}
+		s.sentSinceRekey += uint64(end - written)
 		written = end
 	}
 	return written, nil
 }
 
+// maybeRekey rekeys the encrypting cipher if a limit set with
+// WithRekeyInterval was reached. It tells the peer with an empty message,
+// encrypted with the old key, using buf as the work buffer.
+func (s *secureSession) maybeRekey(buf []byte) error {
+	if !s.rekey {
+		return nil
+	}
+	bytesReached := s.rekeyBytes > 0 && s.sentSinceRekey >= s.rekeyBytes
+	intervalReached := s.rekeyInterval > 0 && time.Since(s.lastRekey) >= s.rekeyInterval
+	if !bytesReached && !intervalReached {
+		return nil
+	}
+	b, err := s.encrypt(buf[:LengthPrefixLength], nil)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint16(b, uint16(len(b)-LengthPrefixLength))
+	if _, err := s.writeMsgInsecure(b); err != nil {
+		return err
+	}
+	s.enc.Rekey()
+	s.sentSinceRekey = 0
+	s.lastRekey = time.Now()
+	return nil
+}
+
 // readNextInsecureMsgLen reads the length of the next message on the insecureConn channel.
 func (s *secureSession) readNextInsecureMsgLen() (int, error) {
 	_, err := io.ReadFull(s.insecureReader, s.rlen[:])
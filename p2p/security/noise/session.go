@@ -38,6 +38,14 @@ type secureSession struct {
 	enc *noise.CipherState
 	dec *noise.CipherState
 
+	// rekeying, see WithRekeyInterval. After the handshake, rekey is only
+	// set if both sides advertised it.
+	rekey          bool
+	rekeyBytes     uint64
+	rekeyInterval  time.Duration
+	sentSinceRekey uint64    // guarded by writeLock
+	lastRekey      time.Time // guarded by writeLock
+
 	// noise prologue
 	prologue []byte
 
@@ -45,6 +53,9 @@ type secureSession struct {
 
 	// ConnectionState holds state information releated to the secureSession entity.
 	connectionState network.ConnectionState
+
+	// see ApplicationPayload
+	remoteApplicationPayload []byte
 }
 
 // newSecureSession creates a Noise session over the given insecureConn Conn, using
@@ -61,6 +72,10 @@ func newSecureSession(tpt *Transport, ctx context.Context, insecure net.Conn, re
 		initiatorEarlyDataHandler: initiatorEDH,
 		responderEarlyDataHandler: responderEDH,
 		checkPeerID:               checkPeerID,
+		rekey:                     tpt.rekeyEnabled(),
+		rekeyBytes:                tpt.rekeyBytes,
+		rekeyInterval:             tpt.rekeyInterval,
+		lastRekey:                 time.Now(),
 	}
 
 	// the go-routine we create to run the handshake will
@@ -2,6 +2,7 @@ package noise
 
 import (
 	"context"
+	"fmt"
 	"net"
 
 	"github.com/libp2p/go-libp2p/core/canonicallog"
@@ -11,8 +12,13 @@ import (
 	"github.com/libp2p/go-libp2p/p2p/security/noise/pb"
 
 	manet "github.com/multiformats/go-multiaddr/net"
+	"google.golang.org/protobuf/proto"
 )
 
+// MaxApplicationPayloadSize is the maximum size of an application payload,
+// see ApplicationPayload.
+const MaxApplicationPayloadSize = 1024
+
 type SessionOption = func(*SessionTransport) error
 
 // Prologue sets a prologue for the Noise session.
@@ -51,6 +57,27 @@ func EarlyData(initiator, responder EarlyDataHandler) SessionOption {
 	}
 }
 
+// ApplicationPayload sets a small payload, e.g. a protocol version or a
+// ticket, that is sent to the peer during the handshake, saving the round
+// trip of sending it afterwards. The payload of the peer is returned by
+// RemoteApplicationPayload once the handshake completes.
+//
+// Both payloads are authenticated and encrypted. However, the responder sends
+// its payload in the second handshake message, before the initiator is
+// authenticated, so it must not contain anything the responder isn't willing
+// to reveal to any peer. The initiator sends its payload in the third
+// message, after authenticating the responder. Payloads are limited to
+// MaxApplicationPayloadSize bytes.
+func ApplicationPayload(payload []byte) SessionOption {
+	return func(s *SessionTransport) error {
+		if len(payload) > MaxApplicationPayloadSize {
+			return fmt.Errorf("application payload of %d bytes exceeds the limit of %d bytes", len(payload), MaxApplicationPayloadSize)
+		}
+		s.applicationPayload = payload
+		return nil
+	}
+}
+
 // DisablePeerIDCheck disables checking the remote peer ID for a noise connection.
 // For outbound connections, this is the equivalent of calling `SecureInbound` with an empty
 // peer ID. This is susceptible to MITM attacks since we do not verify the identity of the remote
@@ -75,27 +102,92 @@ type SessionTransport struct {
 	protocolID protocol.ID
 
 	initiatorEarlyDataHandler, responderEarlyDataHandler EarlyDataHandler
+	applicationPayload                                   []byte
 }
 
 // SecureInbound runs the Noise handshake as the responder.
 // If p is empty, connections from any peer are accepted.
 func (i *SessionTransport) SecureInbound(ctx context.Context, insecure net.Conn, p peer.ID) (sec.SecureConn, error) {
 	checkPeerID := !i.disablePeerIDCheck && p != ""
-	c, err := newSecureSession(i.t, ctx, insecure, p, i.prologue, i.initiatorEarlyDataHandler, i.responderEarlyDataHandler, false, checkPeerID)
+	responderEDH := i.newPayloadEDH(i.responderEarlyDataHandler)
+	c, err := newSecureSession(i.t, ctx, insecure, p, i.prologue, i.initiatorEarlyDataHandler, responderEDH, false, checkPeerID)
 	if err != nil {
 		addr, maErr := manet.FromNetAddr(insecure.RemoteAddr())
 		if maErr == nil {
 			canonicallog.LogPeerStatus(100, p, addr, "handshake_failure", "noise", "err", err.Error())
 		}
+		return c, err
 	}
-	return c, err
+	c.remoteApplicationPayload = responderEDH.received
+	return c, nil
 }
 
 // SecureOutbound runs the Noise handshake as the initiator.
 func (i *SessionTransport) SecureOutbound(ctx context.Context, insecure net.Conn, p peer.ID) (sec.SecureConn, error) {
-	return newSecureSession(i.t, ctx, insecure, p, i.prologue, i.initiatorEarlyDataHandler, i.responderEarlyDataHandler, true, !i.disablePeerIDCheck)
+	initiatorEDH := i.newPayloadEDH(i.initiatorEarlyDataHandler)
+	c, err := newSecureSession(i.t, ctx, insecure, p, i.prologue, initiatorEDH, i.responderEarlyDataHandler, true, !i.disablePeerIDCheck)
+	if err != nil {
+		return c, err
+	}
+	c.remoteApplicationPayload = initiatorEDH.received
+	return c, nil
 }
 
 func (i *SessionTransport) ID() protocol.ID {
 	return i.protocolID
 }
+
+// RemoteApplicationPayload returns the payload the peer sent during the
+// handshake of conn, see ApplicationPayload. It returns nil if the peer didn't
+// send a payload, or if conn isn't a Noise session.
+func RemoteApplicationPayload(conn sec.SecureConn) []byte {
+	if s, ok := conn.(*secureSession); ok {
+		return s.remoteApplicationPayload
+	}
+	return nil
+}
+
+// payloadEarlyDataHandler adds the application payload to the early data of
+// the EarlyDataHandler it wraps, which may be nil.
+type payloadEarlyDataHandler struct {
+	EarlyDataHandler
+	payload  []byte
+	received []byte
+}
+
+var _ EarlyDataHandler = &payloadEarlyDataHandler{}
+
+func (i *SessionTransport) newPayloadEDH(edh EarlyDataHandler) *payloadEarlyDataHandler {
+	return &payloadEarlyDataHandler{EarlyDataHandler: edh, payload: i.applicationPayload}
+}
+
+func (h *payloadEarlyDataHandler) Send(ctx context.Context, conn net.Conn, p peer.ID) *pb.NoiseExtensions {
+	var ext *pb.NoiseExtensions
+	if h.EarlyDataHandler != nil {
+		ext = h.EarlyDataHandler.Send(ctx, conn, p)
+	}
+	if len(h.payload) == 0 {
+		return ext
+	}
+	if ext == nil {
+		ext = &pb.NoiseExtensions{}
+	} else {
+		// don't modify the extensions of the wrapped handler
+		ext = proto.Clone(ext).(*pb.NoiseExtensions)
+	}
+	ext.ApplicationPayload = h.payload
+	return ext
+}
+
+func (h *payloadEarlyDataHandler) Received(ctx context.Context, conn net.Conn, ext *pb.NoiseExtensions) error {
+	if payload := ext.GetApplicationPayload(); len(payload) > 0 {
+		if len(payload) > MaxApplicationPayloadSize {
+			return fmt.Errorf("peer sent an application payload of %d bytes, exceeding the limit of %d bytes", len(payload), MaxApplicationPayloadSize)
+		}
+		h.received = payload
+	}
+	if h.EarlyDataHandler != nil {
+		return h.EarlyDataHandler.Received(ctx, conn, ext)
+	}
+	return nil
+}
@@ -2,7 +2,9 @@ package noise
 
 import (
 	"context"
+	"errors"
 	"net"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/canonicallog"
 	"github.com/libp2p/go-libp2p/core/crypto"
@@ -24,13 +26,63 @@ type Transport struct {
 	localID    peer.ID
 	privateKey crypto.PrivKey
 	muxers     []protocol.ID
+
+	prologue      []byte
+	rekeyBytes    uint64
+	rekeyInterval time.Duration
 }
 
 var _ sec.SecureTransport = &Transport{}
 
+// Option configures the Noise transport.
+type Option func(*Transport) error
+
+// WithPrologue sets the prologue of all handshakes of the transport. The
+// handshake only succeeds if both sides use the same prologue, so a private
+// deployment can use it to domain-separate its handshakes from those of other
+// networks. See https://noiseprotocol.org/noise.html#prologue for details.
+//
+// The prologue of a SessionTransport created with WithSessionOptions defaults
+// to this prologue, and can be overridden with the Prologue session option.
+func WithPrologue(prologue []byte) Option {
+	return func(t *Transport) error {
+		t.prologue = prologue
+		return nil
+	}
+}
+
+// WithRekeyInterval makes sessions rekey the key they encrypt with after
+// sending bytes bytes, or after interval has passed, whichever comes first. A
+// zero value disables the respective limit. Rekeying limits the data
+// protected by a single key on long-lived connections, see
+// https://noiseprotocol.org/noise.html#rekey.
+//
+// A session signals that it rekeyed with an empty transport message, which
+// peers without rekeying enabled don't understand. Nodes therefore advertise
+// rekeying in the handshake, and a session only rekeys if both sides enabled
+// it, though their limits may differ.
+func WithRekeyInterval(bytes uint64, interval time.Duration) Option {
+	return func(t *Transport) error {
+		if interval < 0 {
+			return errors.New("negative rekey interval")
+		}
+		if bytes == 0 && interval == 0 {
+			return errors.New("no rekey limit")
+		}
+		t.rekeyBytes = bytes
+		t.rekeyInterval = interval
+		return nil
+	}
+}
+
+// rekeyEnabled reports whether sessions of t rekey.
+func (t *Transport) rekeyEnabled() bool {
+	return t.rekeyBytes > 0 || t.rekeyInterval > 0
+}
+
 // New creates a new Noise transport using the given private key as its
 // libp2p identity key.
-func New(id protocol.ID, privkey crypto.PrivKey, muxers []tptu.StreamMuxer) (*Transport, error) {
+func New(id protocol.ID, privkey crypto.PrivKey, muxers []tptu.StreamMuxer, opts ...Option) (*Transport, error) {
 	localID, err := peer.IDFromPrivateKey(privkey)
 	if err != nil {
 		return nil, err
@@ -41,19 +93,25 @@ func New(id protocol.ID, privkey crypto.PrivKey, muxers []tptu.StreamMuxer) (*Tr
 		muxerIDs = append(muxerIDs, m.ID)
 	}
 
-	return &Transport{
+	t := &Transport{
 		protocolID: id,
 		localID:    localID,
 		privateKey: privkey,
 		muxers:     muxerIDs,
-	}, nil
+	}
+	for _, opt := range opts {
+		if err := opt(t); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
 }
 
 // SecureInbound runs the Noise handshake as the responder.
 // If p is empty, connections from any peer are accepted.
 func (t *Transport) SecureInbound(ctx context.Context, insecure net.Conn, p peer.ID) (sec.SecureConn, error) {
 	responderEDH := newTransportEDH(t)
-	c, err := newSecureSession(t, ctx, insecure, p, nil, nil, responderEDH, false, p != "")
+	c, err := newSecureSession(t, ctx, insecure, p, t.prologue, nil, responderEDH, false, p != "")
 	if err != nil {
 		addr, maErr := manet.FromNetAddr(insecure.RemoteAddr())
 		if maErr == nil {
@@ -66,7 +124,7 @@ func (t *Transport) SecureInbound(ctx context.Context, insecure net.Conn, p peer
 // SecureOutbound runs the Noise handshake as the initiator.
 func (t *Transport) SecureOutbound(ctx context.Context, insecure net.Conn, p peer.ID) (sec.SecureConn, error) {
 	initiatorEDH := newTransportEDH(t)
-	c, err := newSecureSession(t, ctx, insecure, p, nil, initiatorEDH, nil, true, true)
+	c, err := newSecureSession(t, ctx, insecure, p, t.prologue, initiatorEDH, nil, true, true)
 	if err != nil {
 		return c, err
 	}
@@ -74,7 +132,7 @@ func (t *Transport) SecureOutbound(ctx context.Context, insecure net.Conn, p pee
 }
 
 func (t *Transport) WithSessionOptions(opts ...SessionOption) (*SessionTransport, error) {
-	st := &SessionTransport{t: t, protocolID: t.protocolID}
+	st := &SessionTransport{t: t, protocolID: t.protocolID, prologue: t.prologue}
 	for _, opt := range opts {
 		if err := opt(st); err != nil {
 			return nil, err
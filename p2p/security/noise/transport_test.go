@@ -713,3 +713,204 @@ func TestHandshakeWithTransportEarlyData(t *testing.T) {
 		})
 	}
 }
+
+func TestApplicationPayload(t *testing.T) {
+	handshake := func(t *testing.T, initOpts, respOpts []SessionOption) (initConn, respConn sec.SecureConn) {
+		t.Helper()
+		initTransport, err := newTestTransport(t, crypto.Ed25519, 2048).WithSessionOptions(initOpts...)
+		require.NoError(t, err)
+		tpt := newTestTransport(t, crypto.Ed25519, 2048)
+		respTransport, err := tpt.WithSessionOptions(respOpts...)
+		require.NoError(t, err)
+
+		initNetConn, respNetConn := newConnPair(t)
+
+		type result struct {
+			conn sec.SecureConn
+			err  error
+		}
+		resChan := make(chan result)
+		go func() {
+			conn, err := respTransport.SecureInbound(context.Background(), initNetConn, "")
+			resChan <- result{conn: conn, err: err}
+		}()
+
+		initConn, err = initTransport.SecureOutbound(context.Background(), respNetConn, tpt.localID)
+		require.NoError(t, err)
+		t.Cleanup(func() { initConn.Close() })
+		select {
+		case <-time.After(500 * time.Millisecond):
+			t.Fatal("timeout")
+		case res := <-resChan:
+			require.NoError(t, res.err)
+			respConn = res.conn
+			t.Cleanup(func() { respConn.Close() })
+		}
+		return initConn, respConn
+	}
+
+	t.Run("both sending", func(t *testing.T) {
+		initConn, respConn := handshake(t,
+			[]SessionOption{ApplicationPayload([]byte("client"))},
+			[]SessionOption{ApplicationPayload([]byte("server"))},
+		)
+		require.Equal(t, []byte("server"), RemoteApplicationPayload(initConn))
+		require.Equal(t, []byte("client"), RemoteApplicationPayload(respConn))
+	})
+
+	t.Run("only client sending", func(t *testing.T) {
+		initConn, respConn := handshake(t, []SessionOption{ApplicationPayload([]byte("client"))}, nil)
+		require.Nil(t, RemoteApplicationPayload(initConn))
+		require.Equal(t, []byte("client"), RemoteApplicationPayload(respConn))
+	})
+
+	t.Run("with early data handlers", func(t *testing.T) {
+		var receivedExtensions *pb.NoiseExtensions
+		receivingEDH := &earlyDataHandler{
+			received: func(_ context.Context, _ net.Conn, ext *pb.NoiseExtensions) error {
+				receivedExtensions = ext
+				return nil
+			},
+		}
+		sent := &pb.NoiseExtensions{WebtransportCerthashes: [][]byte{[]byte("foobar")}}
+		sendingEDH := &earlyDataHandler{
+			send: func(context.Context, net.Conn, peer.ID) *pb.NoiseExtensions { return sent },
+		}
+		_, respConn := handshake(t,
+			[]SessionOption{EarlyData(sendingEDH, nil), ApplicationPayload([]byte("client"))},
+			[]SessionOption{EarlyData(nil, receivingEDH)},
+		)
+		require.Equal(t, []byte("client"), RemoteApplicationPayload(respConn))
+		require.Equal(t, [][]byte{[]byte("foobar")}, receivedExtensions.WebtransportCerthashes)
+		require.Nil(t, sent.ApplicationPayload, "the extensions of the handler must not be modified")
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		_, err := newTestTransport(t, crypto.Ed25519, 2048).WithSessionOptions(ApplicationPayload(make([]byte, MaxApplicationPayloadSize+1)))
+		require.Error(t, err)
+	})
+}
+
+func TestTransportPrologue(t *testing.T) {
+	newTransport := func(t *testing.T, prologue string) *Transport {
+		tpt := newTestTransport(t, crypto.Ed25519, 2048)
+		require.NoError(t, WithPrologue([]byte(prologue))(tpt))
+		return tpt
+	}
+
+	t.Run("matching", func(t *testing.T) {
+		initConn, respConn := connect(t, newTransport(t, "private"), newTransport(t, "private"))
+		defer initConn.Close()
+		defer respConn.Close()
+	})
+
+	t.Run("mismatching", func(t *testing.T) {
+		initTransport, respTransport := newTransport(t, "private"), newTransport(t, "other")
+		init, resp := newConnPair(t)
+		done := make(chan error, 1)
+		go func() {
+			_, err := initTransport.SecureOutbound(context.Background(), init, respTransport.localID)
+			done <- err
+		}()
+		_, err := respTransport.SecureInbound(context.Background(), resp, "")
+		require.Error(t, err)
+		require.Error(t, <-done)
+	})
+}
+
+func TestRekey(t *testing.T) {
+	newTransport := func(t *testing.T, opts ...Option) *Transport {
+		tpt := newTestTransport(t, crypto.Ed25519, 2048)
+		for _, opt := range opts {
+			require.NoError(t, opt(tpt))
+		}
+		return tpt
+	}
+	// sendAndReceive sends data in writes of different sizes, and reads it
+	// with buffers of different sizes.
+	sendAndReceive := func(t *testing.T, w, r *secureSession, data []byte) {
+		t.Helper()
+		done := make(chan error, 1)
+		go func() {
+			for b, n := data, 1; len(b) > 0; n *= 3 {
+				n = min(n, len(b))
+				if _, err := w.Write(b[:n]); err != nil {
+					done <- err
+					return
+				}
+				b = b[n:]
+			}
+			done <- nil
+		}()
+		received := make([]byte, 0, len(data))
+		for n := 7; len(received) < len(data); n += 50 {
+			buf := make([]byte, n)
+			read, err := r.Read(buf)
+			require.NoError(t, err)
+			received = append(received, buf[:read]...)
+		}
+		require.NoError(t, <-done)
+		require.Equal(t, data, received)
+	}
+
+	t.Run("after bytes", func(t *testing.T) {
+		initConn, respConn := connect(t, newTransport(t, WithRekeyInterval(100, 0)), newTransport(t, WithRekeyInterval(1<<20, 0)))
+		defer initConn.Close()
+		defer respConn.Close()
+
+		key := initConn.enc.UnsafeKey()
+		data := make([]byte, 10000)
+		rand.Read(data)
+		sendAndReceive(t, initConn, respConn, data)
+		require.NotEqual(t, key, initConn.enc.UnsafeKey())
+		require.Equal(t, initConn.enc.UnsafeKey(), respConn.dec.UnsafeKey())
+		sendAndReceive(t, respConn, initConn, data)
+		require.Equal(t, respConn.enc.UnsafeKey(), initConn.dec.UnsafeKey())
+	})
+
+	t.Run("after interval", func(t *testing.T) {
+		initConn, respConn := connect(t, newTransport(t, WithRekeyInterval(0, 10*time.Millisecond)), newTransport(t, WithRekeyInterval(0, time.Hour)))
+		defer initConn.Close()
+		defer respConn.Close()
+
+		key := initConn.enc.UnsafeKey()
+		sendAndReceive(t, initConn, respConn, []byte("foo"))
+		require.Equal(t, key, initConn.enc.UnsafeKey())
+		time.Sleep(20 * time.Millisecond)
+		sendAndReceive(t, initConn, respConn, []byte("bar"))
+		require.NotEqual(t, key, initConn.enc.UnsafeKey())
+		require.Equal(t, initConn.enc.UnsafeKey(), respConn.dec.UnsafeKey())
+	})
+
+	t.Run("peer without rekeying", func(t *testing.T) {
+		rekeying := func() *Transport { return newTransport(t, WithRekeyInterval(10, 0)) }
+		plain := func() *Transport { return newTestTransport(t, crypto.Ed25519, 2048) }
+		for _, tc := range []struct {
+			name       string
+			init, resp *Transport
+		}{
+			{"initiator rekeys", rekeying(), plain()},
+			{"responder rekeys", plain(), rekeying()},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				initConn, respConn := connect(t, tc.init, tc.resp)
+				defer initConn.Close()
+				defer respConn.Close()
+				require.False(t, initConn.rekey)
+				require.False(t, respConn.rekey)
+
+				key := initConn.enc.UnsafeKey()
+				data := make([]byte, 100)
+				rand.Read(data)
+				sendAndReceive(t, initConn, respConn, data)
+				sendAndReceive(t, respConn, initConn, data)
+				require.Equal(t, key, initConn.enc.UnsafeKey())
+			})
+		}
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		require.Error(t, WithRekeyInterval(0, 0)(&Transport{}))
+		require.Error(t, WithRekeyInterval(100, -time.Second)(&Transport{}))
+	})
+}
@@ -2,7 +2,9 @@ package libp2ptls
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"net"
@@ -31,12 +33,47 @@ type Transport struct {
 	privKey    ci.PrivKey
 	muxers     []protocol.ID
 	protocolID protocol.ID
+
+	sessionCache tls.ClientSessionCache
+	verifyChain  func(peer.ID, []*x509.Certificate) error
 }
 
 var _ sec.SecureTransport = &Transport{}
 
+// Option configures the TLS transport.
+type Option func(*Transport) error
+
+// WithSessionResumption enables TLS session resumption. The transport caches
+// the session tickets of up to cacheSize peers, and resumes the session when
+// reconnecting to one of them, skipping the certificate exchange and the
+// signatures of a full handshake. As a server, it issues session tickets to
+// clients.
+//
+// The session ticket keys are generated when the transport is created and
+// aren't rotated, so tickets stay valid until the process restarts.
+func WithSessionResumption(cacheSize int) Option {
+	return func(t *Transport) error {
+		if cacheSize <= 0 {
+			return errors.New("session cache size must be positive")
+		}
+		t.sessionCache = tls.NewLRUClientSessionCache(cacheSize)
+		return nil
+	}
+}
+
+// WithCertificateChainVerifier sets a function that is called with the peer
+// and the certificate chain it presented, after the chain passed the libp2p
+// verification. If verify returns an error, the handshake fails. On resumed
+// sessions, the chain is the one presented in the original handshake.
+func WithCertificateChainVerifier(verify func(p peer.ID, chain []*x509.Certificate) error) Option {
+	return func(t *Transport) error {
+		t.verifyChain = verify
+		return nil
+	}
+}
+
 // New creates a TLS encrypted transport
-func New(id protocol.ID, key ci.PrivKey, muxers []tptu.StreamMuxer) (*Transport, error) {
+func New(id protocol.ID, key ci.PrivKey, muxers []tptu.StreamMuxer, opts ...Option) (*Transport, error) {
 	localPeer, err := peer.IDFromPrivateKey(key)
 	if err != nil {
 		return nil, err
@@ -51,19 +88,92 @@ func New(id protocol.ID, key ci.PrivKey, muxers []tptu.StreamMuxer) (*Transport,
 		privKey:    key,
 		muxers:     muxerIDs,
 	}
+	for _, opt := range opts {
+		if err := opt(t); err != nil {
+			return nil, err
+		}
+	}
 
 	identity, err := NewIdentity(key)
 	if err != nil {
 		return nil, err
 	}
+	if t.sessionCache != nil {
+		// Set the ticket key on the base config, so that the per-connection
+		// clones share it. Otherwise, every clone would use its own key.
+		var ticketKey [32]byte
+		if _, err := rand.Read(ticketKey[:]); err != nil {
+			return nil, err
+		}
+		identity.config.SetSessionTicketKeys([][32]byte{ticketKey})
+	}
 	t.identity = identity
 	return t, nil
 }
 
+// configForPeer returns the config to secure a connection to p with, see
+// Identity.ConfigForPeer.
+func (t *Transport) configForPeer(p peer.ID) (*tls.Config, <-chan ci.PubKey) {
+	config, keyCh := t.identity.ConfigForPeer(p)
+	if t.sessionCache == nil && t.verifyChain == nil {
+		return config, keyCh
+	}
+
+	if t.sessionCache != nil {
+		config.SessionTicketsDisabled = false
+		if p != "" {
+			config.ClientSessionCache = peerSessionCache{ClientSessionCache: t.sessionCache, peer: p}
+		}
+	}
+	// Resumed handshakes don't call VerifyPeerCertificate, so verify the chain
+	// in VerifyConnection, which is called for all handshakes.
+	verifyPeerCertificate := config.VerifyPeerCertificate
+	config.VerifyPeerCertificate = nil
+	verifiedKeyCh := make(chan ci.PubKey, 1)
+	config.VerifyConnection = func(cs tls.ConnectionState) error {
+		rawCerts := make([][]byte, 0, len(cs.PeerCertificates))
+		for _, cert := range cs.PeerCertificates {
+			rawCerts = append(rawCerts, cert.Raw)
+		}
+		if err := verifyPeerCertificate(rawCerts, nil); err != nil {
+			return err
+		}
+		// set by verifyPeerCertificate if it succeeds
+		pubKey := <-keyCh
+		if t.verifyChain != nil {
+			remote, err := peer.IDFromPublicKey(pubKey)
+			if err != nil {
+				return err
+			}
+			if err := t.verifyChain(remote, cs.PeerCertificates); err != nil {
+				return err
+			}
+		}
+		verifiedKeyCh <- pubKey
+		return nil
+	}
+	return config, verifiedKeyCh
+}
+
+// peerSessionCache stores the session of a single peer in a shared cache. It
+// uses the peer ID as the key, instead of the server name or address.
+type peerSessionCache struct {
+	tls.ClientSessionCache
+	peer peer.ID
+}
+
+func (c peerSessionCache) Get(string) (*tls.ClientSessionState, bool) {
+	return c.ClientSessionCache.Get(string(c.peer))
+}
+
+func (c peerSessionCache) Put(_ string, cs *tls.ClientSessionState) {
+	c.ClientSessionCache.Put(string(c.peer), cs)
+}
+
 // SecureInbound runs the TLS handshake as a server.
 // If p is empty, connections from any peer are accepted.
 func (t *Transport) SecureInbound(ctx context.Context, insecure net.Conn, p peer.ID) (sec.SecureConn, error) {
-	config, keyCh := t.identity.ConfigForPeer(p)
+	config, keyCh := t.configForPeer(p)
 	muxers := make([]string, 0, len(t.muxers))
 	for _, muxer := range t.muxers {
 		muxers = append(muxers, string(muxer))
@@ -108,7 +218,7 @@ func (t *Transport) SecureInbound(ctx context.Context, insecure net.Conn, p peer
 // If the handshake fails, the server will close the connection. The client will
 // notice this after 1 RTT when calling Read.
 func (t *Transport) SecureOutbound(ctx context.Context, insecure net.Conn, p peer.ID) (sec.SecureConn, error) {
-	config, keyCh := t.identity.ConfigForPeer(p)
+	config, keyCh := t.configForPeer(p)
 	muxers := make([]string, 0, len(t.muxers))
 	for _, muxer := range t.muxers {
 		muxers = append(muxers, (string)(muxer))
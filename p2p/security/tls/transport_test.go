@@ -11,12 +11,15 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
+	"errors"
 	"fmt"
+	"io"
 	"math/big"
 	mrand "math/rand"
 	"net"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -709,3 +712,93 @@ func TestInvalidCerts(t *testing.T) {
 		})
 	}
 }
+
+func TestSessionResumption(t *testing.T) {
+	clientID, clientKey := createPeer(t)
+	serverID, serverKey := createPeer(t)
+
+	var verifiedPeers []peer.ID
+	var mx sync.Mutex
+	verify := func(p peer.ID, chain []*x509.Certificate) error {
+		mx.Lock()
+		defer mx.Unlock()
+		if len(chain) != 1 {
+			return errors.New("unexpected chain length")
+		}
+		verifiedPeers = append(verifiedPeers, p)
+		return nil
+	}
+	clientTransport, err := New(ID, clientKey, nil, WithSessionResumption(10), WithCertificateChainVerifier(verify))
+	require.NoError(t, err)
+	serverTransport, err := New(ID, serverKey, nil, WithSessionResumption(10), WithCertificateChainVerifier(verify))
+	require.NoError(t, err)
+
+	handshake := func(t *testing.T) (didResume bool) {
+		clientInsecureConn, serverInsecureConn := connect(t)
+
+		serverConnChan := make(chan sec.SecureConn, 1)
+		go func() {
+			serverConn, err := serverTransport.SecureInbound(context.Background(), serverInsecureConn, "")
+			assert.NoError(t, err)
+			serverConnChan <- serverConn
+		}()
+
+		clientConn, err := clientTransport.SecureOutbound(context.Background(), clientInsecureConn, serverID)
+		require.NoError(t, err)
+		defer clientConn.Close()
+		serverConn := <-serverConnChan
+		require.NotNil(t, serverConn)
+		defer serverConn.Close()
+
+		require.Equal(t, serverID, clientConn.RemotePeer())
+		require.Equal(t, clientID, serverConn.RemotePeer())
+		require.True(t, clientConn.RemotePublicKey().Equals(serverKey.GetPublic()))
+		require.True(t, serverConn.RemotePublicKey().Equals(clientKey.GetPublic()))
+		// reading makes the client process the session ticket
+		_, err = serverConn.Write([]byte("foobar"))
+		require.NoError(t, err)
+		b := make([]byte, 6)
+		_, err = io.ReadFull(clientConn, b)
+		require.NoError(t, err)
+
+		clientResumed := clientConn.(*conn).ConnectionState().DidResume
+		require.Equal(t, clientResumed, serverConn.(*conn).ConnectionState().DidResume)
+		return clientResumed
+	}
+
+	require.False(t, handshake(t))
+	require.True(t, handshake(t))
+	require.ElementsMatch(t, []peer.ID{clientID, serverID, clientID, serverID}, verifiedPeers)
+}
+
+func TestCertificateChainVerifierRejects(t *testing.T) {
+	_, clientKey := createPeer(t)
+	serverID, serverKey := createPeer(t)
+
+	clientTransport, err := New(ID, clientKey, nil)
+	require.NoError(t, err)
+	serverTransport, err := New(ID, serverKey, nil, WithCertificateChainVerifier(func(peer.ID, []*x509.Certificate) error {
+		return errors.New("not allowed")
+	}))
+	require.NoError(t, err)
+
+	clientInsecureConn, serverInsecureConn := connect(t)
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := serverTransport.SecureInbound(context.Background(), serverInsecureConn, "")
+		errChan <- err
+	}()
+	clientConn, err := clientTransport.SecureOutbound(context.Background(), clientInsecureConn, serverID)
+	if err == nil {
+		// the client only notices the failure when reading
+		_, err = clientConn.Read([]byte{0})
+	}
+	require.Error(t, err)
+	require.ErrorContains(t, <-errChan, "not allowed")
+}
+
+func TestSessionResumptionInvalidCacheSize(t *testing.T) {
+	_, key := createPeer(t)
+	_, err := New(ID, key, nil, WithSessionResumption(0))
+	require.Error(t, err)
+}
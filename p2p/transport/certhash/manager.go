@@ -0,0 +1,304 @@
+// Package certhash manages the self-signed certificates used by transports
+// that are dialed by certificate hash (/certhash), i.e. WebTransport and
+// WebRTC Direct. Sharing a Manager between these transports makes them
+// present the same certificates, advertise the same hashes and rotate them at
+// the same time.
+package certhash
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	logging "github.com/ipfs/go-log/v2"
+	ic "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/multiformats/go-multihash"
+)
+
+var log = logging.Logger("certhash")
+
+// Validity is how long a certificate is valid for. Browsers don't accept
+// certificates valid for more than 14 days for WebTransport.
+const Validity = 14 * 24 * time.Hour
+
+// ClockSkewAllowance allows for a bit of clock skew.
+// When we generate a certificate, the NotBefore time is set to ClockSkewAllowance before the current time.
+// Similarly, we stop using a certificate one ClockSkewAllowance before its expiry time.
+const ClockSkewAllowance = time.Hour
+
+const validityMinusTwoSkew = Validity - (2 * ClockSkewAllowance)
+
+// Certificate is a certificate along with its private key.
+type Certificate struct {
+	Leaf       *x509.Certificate
+	PrivateKey *ecdsa.PrivateKey
+	// Hash is the SHA-256 hash of the DER encoded certificate. This is the
+	// hash advertised in /certhash multiaddr components.
+	Hash [32]byte
+}
+
+// Multihash returns the hash of the certificate as a SHA-256 multihash.
+func (c *Certificate) Multihash() ([]byte, error) {
+	h, err := multihash.Encode(c.Hash[:], multihash.SHA2_256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode certificate hash: %w", err)
+	}
+	return h, nil
+}
+
+func newCertificate(key ic.PrivKey, start, end time.Time) (*Certificate, error) {
+	cert, priv, err := generateCert(key, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return &Certificate{
+		Leaf:       cert,
+		PrivateKey: priv,
+		Hash:       sha256.Sum256(cert.Raw),
+	}, nil
+}
+
+func (c *Certificate) start() time.Time { return c.Leaf.NotBefore }
+func (c *Certificate) end() time.Time   { return c.Leaf.NotAfter }
+
+// Option configures a Manager.
+type Option func(*Manager) error
+
+// WithClock sets the clock used to rotate certificates.
+func WithClock(cl clock.Clock) Option {
+	return func(m *Manager) error {
+		m.clock = cl
+		return nil
+	}
+}
+
+// WithStore sets the store used to persist the key certificates are derived
+// from. The store is only used if the host key isn't exportable, since
+// certificates are derived from the host key otherwise.
+func WithStore(s Store) Option {
+	return func(m *Manager) error {
+		m.store = s
+		return nil
+	}
+}
+
+// Manager generates certificates and rotates them before they expire.
+//
+// Certificate renewal logic:
+//  1. On startup, we generate one cert that is valid from now (-1h, to allow for clock skew), and another
+//     cert that is valid from the expiry date of the first certificate (again, with allowance for clock skew).
+//  2. Once we reach 1h before expiry of the first certificate, we switch over to the second certificate.
+//     At the same time, we stop advertising the certhash of the first cert and generate the next cert.
+type Manager struct {
+	clock     clock.Clock
+	store     Store
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	refCount  sync.WaitGroup
+
+	mx      sync.RWMutex
+	last    *Certificate // initially nil
+	current *Certificate
+	next    *Certificate
+
+	subsMx  sync.Mutex
+	nextSub int
+	subs    map[int]func()
+}
+
+// NewManager creates a Manager and generates the initial certificates.
+//
+// Certificates are derived from the host key, so that the certhashes
+// don't change across restarts. If the host key isn't exportable, they are
+// derived from a key loaded from the Store (see WithStore), or from an
+// ephemeral key if no Store is set.
+func NewManager(hostKey ic.PrivKey, opts ...Option) (*Manager, error) {
+	m := &Manager{
+		clock: clock.New(),
+		subs:  make(map[int]func()),
+	}
+	for _, opt := range opts {
+		if err := opt(m); err != nil {
+			return nil, err
+		}
+	}
+	key, err := m.certKey(hostKey)
+	if err != nil {
+		return nil, err
+	}
+	m.ctx, m.ctxCancel = context.WithCancel(context.Background())
+	if err := m.init(key); err != nil {
+		return nil, err
+	}
+
+	m.background(key)
+	return m, nil
+}
+
+// certKey returns the key to derive certificates from.
+func (m *Manager) certKey(hostKey ic.PrivKey) (ic.PrivKey, error) {
+	if _, err := hostKey.Raw(); !errors.Is(err, ic.ErrKeyNotExportable) {
+		return hostKey, nil
+	}
+	if m.store != nil {
+		key, err := m.store.Load()
+		if err == nil {
+			return key, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("failed to load certificate key: %w", err)
+		}
+	}
+	key, _, err := ic.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if m.store != nil {
+		if err := m.store.Save(key); err != nil {
+			return nil, fmt.Errorf("failed to save certificate key: %w", err)
+		}
+	}
+	return key, nil
+}
+
+// getCurrentBucketStartTime returns the canonical start time of the given time as
+// bucketed by ranges of Validity since unix epoch (plus an offset). This
+// lets you get the same time ranges across reboots without having to persist
+// state.
+// ```
+// ... v--- epoch + offset
+// ... |--------|    |--------|        ...
+// ...        |--------|    |--------| ...
+// ```
+func getCurrentBucketStartTime(now time.Time, offset time.Duration) time.Time {
+	currentBucket := (now.UnixMilli() - offset.Milliseconds()) / validityMinusTwoSkew.Milliseconds()
+	return time.UnixMilli(offset.Milliseconds() + currentBucket*validityMinusTwoSkew.Milliseconds())
+}
+
+func (m *Manager) init(key ic.PrivKey) error {
+	start := m.clock.Now()
+	pubkeyBytes, err := key.GetPublic().Raw()
+	if err != nil {
+		return err
+	}
+
+	// We want to add a random offset to each start time so that not all certs
+	// rotate at the same time across the network. The offset represents moving
+	// the bucket start time some `offset` earlier.
+	offset := (time.Duration(binary.LittleEndian.Uint16(pubkeyBytes)) * time.Minute) % Validity
+
+	// We want the certificate have been valid for at least one ClockSkewAllowance
+	start = start.Add(-ClockSkewAllowance)
+	startTime := getCurrentBucketStartTime(start, offset)
+	m.next, err = newCertificate(key, startTime, startTime.Add(Validity))
+	if err != nil {
+		return err
+	}
+	return m.roll(key)
+}
+
+func (m *Manager) roll(key ic.PrivKey) error {
+	// We stop using the current certificate ClockSkewAllowance before its expiry time.
+	// At this point, the next certificate needs to be valid for one ClockSkewAllowance.
+	nextStart := m.next.end().Add(-2 * ClockSkewAllowance)
+	c, err := newCertificate(key, nextStart, nextStart.Add(Validity))
+	if err != nil {
+		return err
+	}
+	m.last = m.current
+	m.current = m.next
+	m.next = c
+	return nil
+}
+
+func (m *Manager) background(key ic.PrivKey) {
+	d := m.current.end().Add(-ClockSkewAllowance).Sub(m.clock.Now())
+	log.Debugw("setting timer", "duration", d.String())
+	t := m.clock.Timer(d)
+	m.refCount.Add(1)
+
+	go func() {
+		defer m.refCount.Done()
+		defer t.Stop()
+
+		for {
+			select {
+			case <-m.ctx.Done():
+				return
+			case <-t.C:
+				now := m.clock.Now()
+				m.mx.Lock()
+				err := m.roll(key)
+				if err != nil {
+					log.Errorw("rolling certificates failed", "error", err)
+				}
+				d := m.current.end().Add(-ClockSkewAllowance).Sub(now)
+				log.Debugw("rolling certificates", "next", d.String())
+				t.Reset(d)
+				m.mx.Unlock()
+				if err == nil {
+					m.notify()
+				}
+			}
+		}
+	}()
+}
+
+// Current returns the certificate to present to peers.
+func (m *Manager) Current() *Certificate {
+	m.mx.RLock()
+	defer m.mx.RUnlock()
+	return m.current
+}
+
+// Certificates returns the previous, the current and the next certificate.
+// Peers may still have the hash of the previous certificate, and the hash
+// of the next certificate should be advertised so that addresses remain valid
+// after the next rotation. last is nil until the first rotation.
+func (m *Manager) Certificates() (last, current, next *Certificate) {
+	m.mx.RLock()
+	defer m.mx.RUnlock()
+	return m.last, m.current, m.next
+}
+
+// Subscribe registers f to be called after the certificates were rotated.
+// Calling the returned function unsubscribes f.
+func (m *Manager) Subscribe(f func()) (cancel func()) {
+	m.subsMx.Lock()
+	defer m.subsMx.Unlock()
+	id := m.nextSub
+	m.nextSub++
+	m.subs[id] = f
+	return func() {
+		m.subsMx.Lock()
+		defer m.subsMx.Unlock()
+		delete(m.subs, id)
+	}
+}
+
+func (m *Manager) notify() {
+	m.subsMx.Lock()
+	subs := make([]func(), 0, len(m.subs))
+	for _, f := range m.subs {
+		subs = append(subs, f)
+	}
+	m.subsMx.Unlock()
+	for _, f := range subs {
+		f()
+	}
+}
+
+// Close stops rotating certificates.
+func (m *Manager) Close() error {
+	m.ctxCancel()
+	m.refCount.Wait()
+	return nil
+}
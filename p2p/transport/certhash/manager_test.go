@@ -0,0 +1,112 @@
+package certhash
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"sync/atomic"
+	"testing"
+	"testing/quick"
+	"time"
+
+	ic "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/test"
+
+	"github.com/benbjohnson/clock"
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotation(t *testing.T) {
+	cl := clock.NewMock()
+	// Add a year to avoid edge cases around the epoch
+	cl.Add(time.Hour * 24 * 365)
+	priv, _, err := test.SeededTestKeyPair(ic.Ed25519, 256, 0)
+	require.NoError(t, err)
+	m, err := NewManager(priv, WithClock(cl))
+	require.NoError(t, err)
+	defer m.Close()
+
+	last, current, next := m.Certificates()
+	require.Nil(t, last)
+	require.Equal(t, current, m.Current())
+	require.NotEqual(t, current.Hash, next.Hash)
+	require.Equal(t, current.Leaf.NotBefore.Add(Validity), current.Leaf.NotAfter)
+
+	var rotations atomic.Int32
+	cancel := m.Subscribe(func() { rotations.Add(1) })
+	cl.Set(current.Leaf.NotAfter.Add(-ClockSkewAllowance + time.Second))
+	require.Eventually(t, func() bool { return rotations.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+	last2, current2, next2 := m.Certificates()
+	require.Equal(t, current.Hash, last2.Hash)
+	require.Equal(t, next.Hash, current2.Hash)
+	require.NotEqual(t, next.Hash, next2.Hash)
+
+	cancel()
+	cl.Add(Validity)
+	require.Eventually(t, func() bool {
+		_, c, _ := m.Certificates()
+		return c.Hash == next2.Hash
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, int32(1), rotations.Load())
+}
+
+func TestStore(t *testing.T) {
+	_, stdKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	// Keys backed by a crypto.Signer can't be exported, so certificates
+	// can't be derived from them.
+	priv, _, err := ic.KeyPairFromSigner(stdKey)
+	require.NoError(t, err)
+
+	hashes := func(opts ...Option) [32]byte {
+		t.Helper()
+		m, err := NewManager(priv, opts...)
+		require.NoError(t, err)
+		defer m.Close()
+		return m.Current().Hash
+	}
+
+	// Without a store, the certificates change across restarts.
+	require.NotEqual(t, hashes(), hashes())
+
+	// With a store, they don't.
+	store := NewDatastoreStore(datastore.NewMapDatastore())
+	_, err = store.Load()
+	require.ErrorIs(t, err, ErrNotFound)
+	first := hashes(WithStore(store))
+	require.Equal(t, first, hashes(WithStore(store)))
+}
+
+func TestDeterministicTimeBuckets(t *testing.T) {
+	cl := clock.NewMock()
+	cl.Add(time.Hour * 24 * 365)
+	startA := getCurrentBucketStartTime(cl.Now(), 0)
+	startB := getCurrentBucketStartTime(cl.Now().Add(time.Hour*24), 0)
+	require.Equal(t, startA, startB)
+
+	// 15 Days later
+	startC := getCurrentBucketStartTime(cl.Now().Add(time.Hour*24*15), 0)
+	require.NotEqual(t, startC, startB)
+}
+
+func TestGetCurrentBucketStartTimeIsWithinBounds(t *testing.T) {
+	require.NoError(t, quick.Check(func(timeSinceUnixEpoch time.Duration, offset time.Duration) bool {
+		if offset < 0 {
+			offset = -offset
+		}
+		if timeSinceUnixEpoch < 0 {
+			timeSinceUnixEpoch = -timeSinceUnixEpoch
+		}
+
+		offset = offset % Validity
+		// Bound this to 100 years
+		timeSinceUnixEpoch = timeSinceUnixEpoch % (time.Hour * 24 * 365 * 100)
+		// Start a bit further in the future to avoid edge cases around epoch
+		timeSinceUnixEpoch += time.Hour * 24 * 365
+		start := time.UnixMilli(timeSinceUnixEpoch.Milliseconds())
+
+		bucketStart := getCurrentBucketStartTime(start.Add(-ClockSkewAllowance), offset)
+		return !bucketStart.After(start.Add(-ClockSkewAllowance)) || bucketStart.Equal(start.Add(-ClockSkewAllowance))
+	}, nil))
+}
@@ -0,0 +1,52 @@
+package certhash
+
+import (
+	"context"
+	"errors"
+
+	ic "github.com/libp2p/go-libp2p/core/crypto"
+
+	"github.com/ipfs/go-datastore"
+)
+
+// ErrNotFound is returned by Store.Load if no key was saved.
+var ErrNotFound = errors.New("certificate key not found")
+
+// Store persists the key certificates are derived from, so that certificate
+// hashes don't change across restarts.
+type Store interface {
+	// Load returns the saved key, or ErrNotFound if no key was saved.
+	Load() (ic.PrivKey, error)
+	// Save saves the key.
+	Save(ic.PrivKey) error
+}
+
+var dsKey = datastore.NewKey("/libp2p/certhash/key")
+
+type datastoreStore struct {
+	ds datastore.Datastore
+}
+
+// NewDatastoreStore returns a Store that saves the key in ds.
+func NewDatastoreStore(ds datastore.Datastore) Store {
+	return &datastoreStore{ds: ds}
+}
+
+func (s *datastoreStore) Load() (ic.PrivKey, error) {
+	b, err := s.ds.Get(context.Background(), dsKey)
+	if errors.Is(err, datastore.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ic.UnmarshalPrivateKey(b)
+}
+
+func (s *datastoreStore) Save(key ic.PrivKey) error {
+	b, err := ic.MarshalPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	return s.ds.Put(context.Background(), dsKey, b)
+}
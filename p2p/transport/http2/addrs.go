@@ -0,0 +1,74 @@
+package http2
+
+import (
+	"fmt"
+	"net"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// P_H2 is the multiaddr code of the h2 component, as in
+// /ip4/192.0.2.1/tcp/443/tls/h2. No code has been assigned to h2 in the
+// multicodec table yet, so it is taken from the private use range.
+const P_H2 = 0x300480
+
+var (
+	// h2Component is created once the protocol is registered.
+	h2Component     *ma.Component
+	tlsComponent, _ = ma.NewComponent("tls", "")
+	// errRegister is the error registering the h2 protocol failed with. New
+	// returns it.
+	errRegister error
+)
+
+func init() {
+	// h2 may already be registered, e.g. by a copy of this package.
+	if ma.ProtocolWithCode(P_H2).Name != "h2" {
+		if err := ma.AddProtocol(ma.Protocol{
+			Name:  "h2",
+			Code:  P_H2,
+			VCode: ma.CodeToVarint(P_H2),
+			Size:  0,
+		}); err != nil {
+			errRegister = fmt.Errorf("registering the h2 multiaddr protocol: %w", err)
+			return
+		}
+	}
+	h2Component, errRegister = ma.NewComponent("h2", "")
+}
+
+// parsedAddr is a parsed /tls[/sni/<name>]/h2 multiaddr.
+type parsedAddr struct {
+	// tcpAddr is the part before the /tls component.
+	tcpAddr ma.Multiaddr
+	// sni is the value of the /sni component, or empty.
+	sni string
+}
+
+func parseMultiaddr(a ma.Multiaddr) (parsedAddr, error) {
+	rest, last := ma.SplitLast(a)
+	if last == nil || last.Protocol().Code != P_H2 {
+		return parsedAddr{}, fmt.Errorf("not an h2 multiaddr: %s", a)
+	}
+	var out parsedAddr
+	rest, last = ma.SplitLast(rest)
+	if last != nil && last.Protocol().Code == ma.P_SNI {
+		out.sni = last.Value()
+		rest, last = ma.SplitLast(rest)
+	}
+	if last == nil || last.Protocol().Code != ma.P_TLS {
+		return parsedAddr{}, fmt.Errorf("h2 multiaddr without tls component: %s", a)
+	}
+	out.tcpAddr = rest
+	return out, nil
+}
+
+// toMultiaddr returns the h2 multiaddr of a TCP address.
+func toMultiaddr(a net.Addr) (ma.Multiaddr, error) {
+	tcpAddr, err := manet.FromNetAddr(a)
+	if err != nil {
+		return nil, err
+	}
+	return tcpAddr.AppendComponent(tlsComponent, h2Component), nil
+}
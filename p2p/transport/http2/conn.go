@@ -0,0 +1,291 @@
+package http2
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// readBufferSize is the size of the buffers the body of a stream is read
+// into. It matches the default HTTP/2 frame size.
+const readBufferSize = 16 << 10
+
+// stream is the HTTP/2 stream carrying a connection, as seen by the client or
+// the server.
+type stream interface {
+	// Read reads from the body of the stream.
+	io.Reader
+	// Write writes to the stream and flushes the data.
+	io.Writer
+	// abortWrite unblocks a pending Write. Future writes fail.
+	abortWrite()
+	// close closes the stream and the underlying TCP connection.
+	close() error
+}
+
+type readResult struct {
+	b   []byte
+	err error
+}
+
+// conn is a libp2p connection carried by an HTTP/2 stream.
+type conn struct {
+	stream stream
+	scope  network.ConnManagementScope
+
+	localAddr, remoteAddr net.Addr
+	laddr, raddr          ma.Multiaddr
+
+	closeOnce sync.Once
+	closeErr  error
+	closed    chan struct{}
+
+	// reads is fed by readLoop.
+	reads        chan readResult
+	readMx       sync.Mutex
+	rbuf         []byte
+	rerr         error
+	readDeadline *deadline
+
+	writeMx       sync.Mutex
+	writing       atomic.Bool
+	aborted       atomic.Bool
+	werr          error
+	writeDeadline *deadline
+}
+
+var _ manet.Conn = (*conn)(nil)
+
+func newConn(s stream, scope network.ConnManagementScope, localAddr, remoteAddr net.Addr) (*conn, error) {
+	laddr, err := toMultiaddr(localAddr)
+	if err != nil {
+		return nil, err
+	}
+	raddr, err := toMultiaddr(remoteAddr)
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{
+		stream:       s,
+		scope:        scope,
+		localAddr:    localAddr,
+		remoteAddr:   remoteAddr,
+		laddr:        laddr,
+		raddr:        raddr,
+		closed:       make(chan struct{}),
+		reads:        make(chan readResult),
+		readDeadline: newDeadline(nil),
+	}
+	c.writeDeadline = newDeadline(c.abortPendingWrite)
+	go c.readLoop()
+	return c, nil
+}
+
+// readLoop reads from the stream, so that reads can be interrupted by a
+// deadline. It alternates between two buffers: it only reads into a buffer
+// once Read received the other one, i.e. once Read is done with this one.
+func (c *conn) readLoop() {
+	bufs := [2][]byte{make([]byte, readBufferSize), make([]byte, readBufferSize)}
+	for i := 0; ; i ^= 1 {
+		n, err := c.stream.Read(bufs[i])
+		if n == 0 && err == nil {
+			continue
+		}
+		select {
+		case c.reads <- readResult{b: bufs[i][:n], err: err}:
+		case <-c.closed:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	c.readMx.Lock()
+	defer c.readMx.Unlock()
+
+	if len(b) == 0 {
+		return 0, nil
+	}
+	for len(c.rbuf) == 0 {
+		if c.rerr != nil {
+			return 0, c.rerr
+		}
+		select {
+		case r := <-c.reads:
+			c.rbuf, c.rerr = r.b, r.err
+		case <-c.readDeadline.wait():
+			return 0, os.ErrDeadlineExceeded
+		case <-c.closed:
+			return 0, net.ErrClosed
+		}
+	}
+	n := copy(b, c.rbuf)
+	c.rbuf = c.rbuf[n:]
+	return n, nil
+}
+
+// Write writes b to the stream. As with TLS connections, once a write timed
+// out, all future writes fail.
+func (c *conn) Write(b []byte) (int, error) {
+	c.writeMx.Lock()
+	defer c.writeMx.Unlock()
+
+	if c.werr != nil {
+		return 0, c.werr
+	}
+	// Close and the write deadline check writing after closing their
+	// channels, so they either see the write or we see their channel closed.
+	c.writing.Store(true)
+	defer c.writing.Store(false)
+	select {
+	case <-c.closed:
+		return 0, net.ErrClosed
+	case <-c.writeDeadline.wait():
+		return 0, os.ErrDeadlineExceeded
+	default:
+	}
+	n, err := c.stream.Write(b)
+	if err != nil {
+		select {
+		case <-c.closed:
+			err = net.ErrClosed
+		default:
+			if c.aborted.Load() {
+				err = os.ErrDeadlineExceeded
+			}
+		}
+		c.werr = err
+	}
+	return n, err
+}
+
+func (c *conn) abortPendingWrite() {
+	if c.writing.Load() {
+		c.aborted.Store(true)
+		c.stream.abortWrite()
+	}
+}
+
+// Close closes the connection. Subsequent calls return the same error.
+func (c *conn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.abortPendingWrite()
+		// Wait for a pending write to return.
+		c.writeMx.Lock()
+		c.werr = net.ErrClosed
+		c.writeMx.Unlock()
+
+		c.closeErr = c.stream.close()
+		c.scope.Done()
+	})
+	return c.closeErr
+}
+
+func (c *conn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+func (c *conn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+// LocalMultiaddr implements manet.Conn.
+func (c *conn) LocalMultiaddr() ma.Multiaddr {
+	return c.laddr
+}
+
+// RemoteMultiaddr implements manet.Conn.
+func (c *conn) RemoteMultiaddr() ma.Multiaddr {
+	return c.raddr
+}
+
+func (c *conn) SetDeadline(t time.Time) error {
+	return errors.Join(c.SetReadDeadline(t), c.SetWriteDeadline(t))
+}
+
+func (c *conn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+func (c *conn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.set(t)
+	return nil
+}
+
+// deadline is a read or write deadline, modeled after the deadlines of
+// net.Pipe. onExpire, if set, is called when the deadline passes.
+type deadline struct {
+	onExpire func()
+
+	mx      sync.Mutex
+	timer   *time.Timer
+	expired chan struct{}
+}
+
+func newDeadline(onExpire func()) *deadline {
+	return &deadline{onExpire: onExpire, expired: make(chan struct{})}
+}
+
+func (d *deadline) set(t time.Time) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	if d.timer != nil && !d.timer.Stop() {
+		// Wait for the timer to close expired.
+		<-d.expired
+	}
+	d.timer = nil
+
+	var isExpired bool
+	select {
+	case <-d.expired:
+		isExpired = true
+	default:
+	}
+	if t.IsZero() {
+		if isExpired {
+			d.expired = make(chan struct{})
+		}
+		return
+	}
+	if dur := time.Until(t); dur > 0 {
+		if isExpired {
+			d.expired = make(chan struct{})
+		}
+		expired := d.expired
+		d.timer = time.AfterFunc(dur, func() {
+			close(expired)
+			if d.onExpire != nil {
+				d.onExpire()
+			}
+		})
+		return
+	}
+	if !isExpired {
+		close(d.expired)
+		if d.onExpire != nil {
+			d.onExpire()
+		}
+	}
+}
+
+// wait returns a channel that is closed when the deadline passes.
+func (d *deadline) wait() <-chan struct{} {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	return d.expired
+}
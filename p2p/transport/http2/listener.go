@@ -0,0 +1,261 @@
+package http2
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/transport"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"go.uber.org/zap"
+	h2 "golang.org/x/net/http2"
+)
+
+var stdLog = zap.NewStdLog(log.Desugar())
+
+type listener struct {
+	netListener *netListener
+	server      http.Server
+	laddr       ma.Multiaddr
+
+	incoming chan *conn
+
+	closeOnce sync.Once
+	closeErr  error
+	closed    chan struct{}
+}
+
+var _ transport.GatedMaListener = &listener{}
+
+func newListener(a ma.Multiaddr, tlsConf *tls.Config, upgrader transport.Upgrader, handshakeTimeout time.Duration) (*listener, error) {
+	parsed, err := parseMultiaddr(a)
+	if err != nil {
+		return nil, err
+	}
+	mal, err := manet.Listen(parsed.tcpAddr)
+	if err != nil {
+		return nil, err
+	}
+	gmal := upgrader.GateMaListener(mal)
+
+	tlsConf = tlsConf.Clone()
+	// HTTP/1.1 is offered as well, as any HTTPS server would. Such requests
+	// are answered with 404 Not Found.
+	tlsConf.NextProtos = []string{h2.NextProtoTLS, "http/1.1"}
+	l := &listener{
+		netListener: &netListener{GatedMaListener: gmal, handshakeTimeout: handshakeTimeout},
+		// laddr has the correct port in case we listened on port 0.
+		laddr:    gmal.Multiaddr().Encapsulate(a[len(parsed.tcpAddr):]),
+		incoming: make(chan *conn),
+		closed:   make(chan struct{}),
+	}
+	l.server = http.Server{
+		Handler:     l,
+		ErrorLog:    stdLog,
+		ConnContext: l.connContext,
+		TLSConfig:   tlsConf,
+	}
+	return l, nil
+}
+
+func (l *listener) serve() {
+	defer close(l.closed)
+	l.server.ServeTLS(l.netListener, "", "")
+}
+
+type connKey struct{}
+
+func (l *listener) connContext(ctx context.Context, c net.Conn) context.Context {
+	if tc, ok := c.(*tls.Conn); ok {
+		c = tc.NetConn()
+	}
+	if tc, ok := c.(*tcpConn); ok {
+		return context.WithValue(ctx, connKey{}, tc)
+	}
+	log.Errorf("BUG: expected net.Conn of type *http2.tcpConn: got %T", c)
+	c.Close()
+	return ctx
+}
+
+func (l *listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor != 2 || r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	tc, ok := r.Context().Value(connKey{}).(*tcpConn)
+	if !ok {
+		log.Errorf("BUG: no *http2.tcpConn in request context")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	// Every TCP connection carries at most one libp2p connection.
+	if !tc.claim() {
+		http.NotFound(w, r)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		tc.Close()
+		return
+	}
+	s := &serverStream{
+		body:    r.Body,
+		w:       w,
+		rc:      rc,
+		tcp:     tc,
+		release: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	defer close(s.done)
+	c, err := newConn(s, tc.scope, tc.LocalAddr(), tc.RemoteAddr())
+	if err != nil {
+		log.Debugf("failed to create conn for %s: %s", r.RemoteAddr, err)
+		tc.Close()
+		return
+	}
+	select {
+	case l.incoming <- c:
+	case <-l.closed:
+		// Close waits for this handler to return.
+		go c.Close()
+	}
+	// The stream ends when the handler returns, so wait until the connection
+	// is closed.
+	<-s.release
+}
+
+func (l *listener) Accept() (manet.Conn, network.ConnManagementScope, error) {
+	select {
+	case c := <-l.incoming:
+		return c, c.scope, nil
+	case <-l.closed:
+		return nil, nil, transport.ErrListenerClosed
+	}
+}
+
+func (l *listener) Addr() net.Addr {
+	return l.netListener.Addr()
+}
+
+func (l *listener) Multiaddr() ma.Multiaddr {
+	return l.laddr
+}
+
+// Close stops accepting connections. Unlike http.Server.Close, it doesn't
+// close the accepted connections.
+func (l *listener) Close() error {
+	l.closeOnce.Do(func() {
+		l.closeErr = l.netListener.Close()
+		<-l.closed
+	})
+	return l.closeErr
+}
+
+// serverStream is the stream of an accepted connection. It's served by a
+// handler that waits for the connection to be closed.
+type serverStream struct {
+	body io.ReadCloser
+	w    http.ResponseWriter
+	rc   *http.ResponseController
+	tcp  *tcpConn
+
+	// The ResponseWriter must not be used once the handler returned.
+	mx       sync.Mutex
+	finished bool
+	// release is closed to make the handler return, done once it returned.
+	release chan struct{}
+	done    chan struct{}
+}
+
+func (s *serverStream) Read(b []byte) (int, error) {
+	return s.body.Read(b)
+}
+
+func (s *serverStream) Write(b []byte) (int, error) {
+	n, err := s.w.Write(b)
+	if err != nil {
+		return n, err
+	}
+	return n, s.rc.Flush()
+}
+
+func (s *serverStream) abortWrite() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if !s.finished {
+		s.rc.SetWriteDeadline(time.Now())
+	}
+}
+
+// close is called once no write is pending.
+func (s *serverStream) close() error {
+	s.mx.Lock()
+	s.finished = true
+	s.mx.Unlock()
+	close(s.release)
+	<-s.done
+	return s.tcp.Close()
+}
+
+// netListener adapts a transport.GatedMaListener to a net.Listener for the
+// http.Server.
+type netListener struct {
+	transport.GatedMaListener
+	handshakeTimeout time.Duration
+}
+
+var _ net.Listener = &netListener{}
+
+func (l *netListener) Accept() (net.Conn, error) {
+	c, scope, err := l.GatedMaListener.Accept()
+	if err != nil {
+		if scope != nil {
+			log.Errorf("BUG: scope non-nil when err is non nil: %v", err)
+			scope.Done()
+		}
+		return nil, err
+	}
+	tc := &tcpConn{Conn: c, scope: scope}
+	tc.timer = time.AfterFunc(l.handshakeTimeout, func() {
+		log.Debugf("handshake timeout for conn from: %s", c.RemoteAddr())
+		tc.Close()
+	})
+	return tc, nil
+}
+
+// tcpConn is an accepted TCP connection. It is closed unless a libp2p
+// connection is established on it before the handshake timeout.
+type tcpConn struct {
+	net.Conn
+	scope     network.ConnManagementScope
+	timer     *time.Timer
+	claimed   atomic.Bool
+	closeOnce sync.Once
+}
+
+// claim claims the connection for a libp2p connection. It fails if the
+// connection was claimed before, or timed out.
+func (c *tcpConn) claim() bool {
+	if !c.claimed.CompareAndSwap(false, true) {
+		return false
+	}
+	return c.timer.Stop()
+}
+
+// Close closes the connection. It's called by the http.Server, and by the
+// libp2p connection using it.
+func (c *tcpConn) Close() error {
+	c.closeOnce.Do(c.scope.Done)
+	return c.Conn.Close()
+}
@@ -0,0 +1,458 @@
+// Package http2 implements a go-libp2p transport that carries connections as
+// HTTP/2 streams over TLS, for networks that only allow HTTPS traffic.
+//
+// A connection is a POST request whose request and response bodies are the
+// two directions of the connection. To middleboxes, it looks like a regular
+// HTTPS request, usually to port 443, including the ALPN negotiation of h2.
+// Unlike WebSockets, no protocol upgrade is needed, so this works through
+// proxies and firewalls that block the WebSocket upgrade.
+//
+// Addresses have the form /ip4/192.0.2.1/tcp/443/tls/h2, or
+// /dns/example.com/tcp/443/tls/sni/example.com/h2 to set the TLS server name.
+package http2
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/transport"
+
+	logging "github.com/ipfs/go-log/v2"
+	ma "github.com/multiformats/go-multiaddr"
+	mafmt "github.com/multiformats/go-multiaddr-fmt"
+	manet "github.com/multiformats/go-multiaddr/net"
+	h2 "golang.org/x/net/http2"
+	"golang.org/x/net/proxy"
+)
+
+var log = logging.Logger("http2-transport")
+
+var dialMatcher = mafmt.And(
+	mafmt.Or(mafmt.IP, mafmt.And(mafmt.Base(ma.P_IP6ZONE), mafmt.Base(ma.P_IP6)), mafmt.DNS),
+	mafmt.Base(ma.P_TCP),
+	mafmt.Or(
+		mafmt.And(mafmt.Base(ma.P_TLS), mafmt.Base(ma.P_SNI)),
+		mafmt.Base(ma.P_TLS),
+	),
+	mafmt.Base(P_H2),
+)
+
+const defaultHandshakeTimeout = 15 * time.Second
+
+// GracefulCloseTimeout is the time to wait for buffered data to be sent when
+// closing a dialed connection.
+var GracefulCloseTimeout = 100 * time.Millisecond
+
+type Option func(*Transport) error
+
+// WithTLSConfig sets the TLS configuration for listeners. It must contain a
+// certificate. Listening fails without it.
+func WithTLSConfig(conf *tls.Config) Option {
+	return func(t *Transport) error {
+		if conf == nil {
+			return errors.New("tls config is nil")
+		}
+		t.tlsConf = conf
+		return nil
+	}
+}
+
+// WithTLSClientConfig sets the TLS configuration used when dialing, e.g. to
+// trust additional CAs.
+func WithTLSClientConfig(conf *tls.Config) Option {
+	return func(t *Transport) error {
+		if conf == nil {
+			return errors.New("tls client config is nil")
+		}
+		t.tlsClientConf = conf
+		return nil
+	}
+}
+
+// WithHandshakeTimeout sets the time an accepted TCP connection has to
+// complete the TLS handshake and send the request that starts the libp2p
+// connection.
+func WithHandshakeTimeout(timeout time.Duration) Option {
+	return func(t *Transport) error {
+		if timeout <= 0 {
+			return errors.New("handshake timeout must be positive")
+		}
+		t.handshakeTimeout = timeout
+		return nil
+	}
+}
+
+// WithProxy sets the function that returns the proxy to use for a dial. The
+// request passed to proxy is for an https URL whose host is the address
+// dialed. If proxy returns a nil URL, no proxy is used. HTTP and HTTPS
+// proxies, which are asked to CONNECT to the address, and SOCKS5 proxies are
+// supported.
+//
+// Defaults to http.ProxyFromEnvironment, i.e. HTTPS_PROXY and NO_PROXY. Use
+// http.ProxyURL to use a fixed proxy, and a nil proxy function to disable
+// proxies.
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) Option {
+	return func(t *Transport) error {
+		t.proxy = proxy
+		return nil
+	}
+}
+
+// Transport is the HTTP/2 transport.
+type Transport struct {
+	upgrader         transport.Upgrader
+	rcmgr            network.ResourceManager
+	tlsConf          *tls.Config
+	tlsClientConf    *tls.Config
+	handshakeTimeout time.Duration
+	proxy            func(*http.Request) (*url.URL, error)
+	h2Transport      *h2.Transport
+}
+
+var _ transport.Transport = (*Transport)(nil)
+var _ transport.Resolver = (*Transport)(nil)
+
+func New(u transport.Upgrader, rcmgr network.ResourceManager, opts ...Option) (*Transport, error) {
+	if errRegister != nil {
+		return nil, errRegister
+	}
+	if rcmgr == nil {
+		rcmgr = &network.NullResourceManager{}
+	}
+	t := &Transport{
+		upgrader:         u,
+		rcmgr:            rcmgr,
+		tlsClientConf:    &tls.Config{},
+		handshakeTimeout: defaultHandshakeTimeout,
+		proxy:            http.ProxyFromEnvironment,
+		h2Transport:      &h2.Transport{},
+	}
+	for _, opt := range opts {
+		if err := opt(t); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (t *Transport) CanDial(a ma.Multiaddr) bool {
+	return dialMatcher.Matches(a)
+}
+
+func (t *Transport) Protocols() []int {
+	return []int{P_H2}
+}
+
+// TransportName returns the name of the transport in network.ConnectionState.
+func (t *Transport) TransportName() string {
+	return "http2"
+}
+
+func (t *Transport) Proxy() bool {
+	return false
+}
+
+// Resolve adds an /sni component with the DNS name of a, so that the name is
+// used for the TLS handshake after the DNS name is resolved.
+func (t *Transport) Resolve(_ context.Context, a ma.Multiaddr) ([]ma.Multiaddr, error) {
+	parsed, err := parseMultiaddr(a)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.sni != "" {
+		return []ma.Multiaddr{a}, nil
+	}
+	for _, c := range parsed.tcpAddr {
+		switch c.Protocol().Code {
+		case ma.P_DNS, ma.P_DNS4, ma.P_DNS6:
+			sni, err := ma.NewComponent("sni", c.Value())
+			if err != nil {
+				return nil, err
+			}
+			return []ma.Multiaddr{parsed.tcpAddr.AppendComponent(tlsComponent, sni, h2Component)}, nil
+		}
+	}
+	return []ma.Multiaddr{a}, nil
+}
+
+func (t *Transport) Dial(ctx context.Context, raddr ma.Multiaddr, p peer.ID) (transport.CapableConn, error) {
+	connScope, err := t.rcmgr.OpenConnection(network.DirOutbound, true, raddr)
+	if err != nil {
+		return nil, err
+	}
+	c, err := t.dialWithScope(ctx, raddr, p, connScope)
+	if err != nil {
+		connScope.Done()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (t *Transport) dialWithScope(ctx context.Context, raddr ma.Multiaddr, p peer.ID, connScope network.ConnManagementScope) (transport.CapableConn, error) {
+	c, err := t.maDial(ctx, raddr, connScope)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := t.upgrader.Upgrade(ctx, t, c, network.DirOutbound, p, connScope)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (t *Transport) maDial(ctx context.Context, raddr ma.Multiaddr, scope network.ConnManagementScope) (*conn, error) {
+	parsed, err := parseMultiaddr(raddr)
+	if err != nil {
+		return nil, err
+	}
+	netw, host, err := manet.DialArgs(parsed.tcpAddr)
+	if err != nil {
+		return nil, err
+	}
+	switch netw {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("unsupported h2 network %s", netw)
+	}
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return nil, err
+	}
+	authority := host
+	if parsed.sni != "" {
+		hostname = parsed.sni
+		authority = net.JoinHostPort(parsed.sni, port)
+	}
+
+	nc, err := t.dialTCP(ctx, netw, host)
+	if err != nil {
+		return nil, err
+	}
+	tlsConf := t.tlsClientConf.Clone()
+	tlsConf.NextProtos = []string{h2.NextProtoTLS}
+	if tlsConf.ServerName == "" {
+		tlsConf.ServerName = hostname
+	}
+	tc := tls.Client(nc, tlsConf)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	if p := tc.ConnectionState().NegotiatedProtocol; p != h2.NextProtoTLS {
+		tc.Close()
+		return nil, fmt.Errorf("server doesn't support HTTP/2, negotiated %q", p)
+	}
+	cc, err := t.h2Transport.NewClientConn(tc)
+	if err != nil {
+		tc.Close()
+		return nil, err
+	}
+
+	// The request lives as long as the connection, so it can't use ctx.
+	reqCtx, cancel := context.WithCancel(context.Background())
+	pr, pw := io.Pipe()
+	body := &requestBody{PipeReader: pr, sent: make(chan struct{})}
+	u := &url.URL{Scheme: "https", Host: authority, Path: "/"}
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, u.String(), body)
+	if err != nil {
+		cancel()
+		cc.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	stop := context.AfterFunc(ctx, cancel)
+	resp, err := cc.RoundTrip(req)
+	if !stop() {
+		err = errors.Join(ctx.Err(), err)
+	}
+	if err == nil && resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		cancel()
+		cc.Close()
+		return nil, err
+	}
+	s := &clientStream{body: resp.Body, pw: pw, reqBody: body, cc: cc, cancel: cancel}
+	c, err := newConn(s, scope, tc.LocalAddr(), tc.RemoteAddr())
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// dialTCP dials addr, through a proxy if one is configured for it.
+func (t *Transport) dialTCP(ctx context.Context, netw, addr string) (net.Conn, error) {
+	var d net.Dialer
+	if t.proxy == nil {
+		return d.DialContext(ctx, netw, addr)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, (&url.URL{Scheme: "https", Host: addr}).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	proxyURL, err := t.proxy(req)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL == nil {
+		return d.DialContext(ctx, netw, addr)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https":
+		return dialConnectProxy(ctx, proxyURL, addr)
+	case "socks5", "socks5h":
+		pd, err := proxy.FromURL(proxyURL, &d)
+		if err != nil {
+			return nil, err
+		}
+		return pd.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+}
+
+// dialConnectProxy connects to addr through the HTTP(S) proxy at proxyURL,
+// with a CONNECT request.
+func dialConnectProxy(ctx context.Context, proxyURL *url.URL, addr string) (_ net.Conn, err error) {
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+	var d net.Dialer
+	c, err := d.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			c.Close()
+		}
+	}()
+	if proxyURL.Scheme == "https" {
+		tc := tls.Client(c, &tls.Config{ServerName: proxyURL.Hostname()})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			return nil, err
+		}
+		c = tc
+	}
+
+	// unblock the CONNECT exchange when ctx is done
+	stop := context.AfterFunc(ctx, func() { c.SetDeadline(time.Unix(1, 0)) })
+	defer func() {
+		if !stop() {
+			err = errors.Join(ctx.Err(), err)
+		}
+	}()
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if u := proxyURL.User; u != nil {
+		password, _ := u.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if err := req.Write(c); err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(c)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("proxy refused to connect to %s: %s", addr, resp.Status)
+	}
+	// The TLS handshake starts with our ClientHello, so the proxy mustn't
+	// have sent anything else.
+	if br.Buffered() > 0 {
+		return nil, errors.New("unexpected data from proxy after CONNECT")
+	}
+	return c, nil
+}
+
+// clientStream is the stream of a dialed connection. Writes go through a pipe
+// that is the body of the request.
+type clientStream struct {
+	body    io.ReadCloser
+	pw      *io.PipeWriter
+	reqBody *requestBody
+	cc      *h2.ClientConn
+	cancel  context.CancelFunc
+}
+
+func (s *clientStream) Read(b []byte) (int, error) {
+	return s.body.Read(b)
+}
+
+func (s *clientStream) Write(b []byte) (int, error) {
+	return s.pw.Write(b)
+}
+
+func (s *clientStream) abortWrite() {
+	s.pw.CloseWithError(net.ErrClosed)
+}
+
+func (s *clientStream) close() error {
+	s.pw.Close()
+	// Wait for the request body to be sent, so that data written before
+	// closing isn't lost.
+	t := time.NewTimer(GracefulCloseTimeout)
+	defer t.Stop()
+	select {
+	case <-s.reqBody.sent:
+	case <-t.C:
+	}
+	s.body.Close()
+	s.cancel()
+	return s.cc.Close()
+}
+
+// requestBody is the body of the request of a dialed connection. The HTTP/2
+// transport closes it once it's done sending it.
+type requestBody struct {
+	*io.PipeReader
+	closeOnce sync.Once
+	sent      chan struct{}
+}
+
+func (b *requestBody) Close() error {
+	b.closeOnce.Do(func() { close(b.sent) })
+	return b.PipeReader.Close()
+}
+
+func (t *Transport) Listen(a ma.Multiaddr) (transport.Listener, error) {
+	if t.tlsConf == nil {
+		return nil, fmt.Errorf("cannot listen on h2 address %s without a tls.Config", a)
+	}
+	l, err := newListener(a, t.tlsConf, t.upgrader, t.handshakeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	go l.serve()
+	return t.upgrader.UpgradeGatedMaListener(t, l), nil
+}
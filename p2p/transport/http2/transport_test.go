@@ -0,0 +1,340 @@
+package http2
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/sec"
+	"github.com/libp2p/go-libp2p/core/sec/insecure"
+	"github.com/libp2p/go-libp2p/core/test"
+	"github.com/libp2p/go-libp2p/core/transport"
+	"github.com/libp2p/go-libp2p/p2p/muxer/yamux"
+	tptu "github.com/libp2p/go-libp2p/p2p/net/upgrader"
+	ttransport "github.com/libp2p/go-libp2p/p2p/transport/testsuite"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"github.com/stretchr/testify/require"
+)
+
+func newUpgrader(t *testing.T) (peer.ID, transport.Upgrader) {
+	t.Helper()
+	priv, _, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+	require.NoError(t, err)
+	id, err := peer.IDFromPrivateKey(priv)
+	require.NoError(t, err)
+	st := []sec.SecureTransport{insecure.NewWithIdentity(insecure.ID, id, priv)}
+	u, err := tptu.New(st, []tptu.StreamMuxer{{ID: "/yamux", Muxer: yamux.DefaultTransport}}, nil, nil, nil)
+	require.NoError(t, err)
+	return id, u
+}
+
+func generateTLSConfig(t *testing.T) *tls.Config {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{},
+		SignatureAlgorithm:    x509.SHA256WithRSA,
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour), // valid for an hour
+		BasicConstraintsValid: true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, priv.Public(), priv)
+	require.NoError(t, err)
+	return &tls.Config{
+		Certificates: []tls.Certificate{{
+			PrivateKey:  priv,
+			Certificate: [][]byte{certDER},
+		}},
+	}
+}
+
+func newTransport(t *testing.T, opts ...Option) (peer.ID, *Transport) {
+	t.Helper()
+	id, u := newUpgrader(t)
+	opts = append([]Option{
+		WithTLSConfig(generateTLSConfig(t)),
+		WithTLSClientConfig(&tls.Config{InsecureSkipVerify: true}),
+	}, opts...)
+	tpt, err := New(u, nil, opts...)
+	require.NoError(t, err)
+	return id, tpt
+}
+
+func TestCanDial(t *testing.T) {
+	tpt := &Transport{}
+	for _, a := range []string{
+		"/ip4/127.0.0.1/tcp/443/tls/h2",
+		"/ip6/::1/tcp/443/tls/h2",
+		"/dns/example.com/tcp/443/tls/h2",
+		"/ip4/127.0.0.1/tcp/443/tls/sni/example.com/h2",
+	} {
+		require.True(t, tpt.CanDial(ma.StringCast(a)), a)
+	}
+	for _, a := range []string{
+		"/ip4/127.0.0.1/tcp/443",
+		"/ip4/127.0.0.1/tcp/443/h2",
+		"/ip4/127.0.0.1/tcp/443/tls/ws",
+		"/ip4/127.0.0.1/udp/443/tls/h2",
+	} {
+		require.False(t, tpt.CanDial(ma.StringCast(a)), a)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tpt := &Transport{}
+	for in, out := range map[string]string{
+		"/dns/example.com/tcp/443/tls/h2":                 "/dns/example.com/tcp/443/tls/sni/example.com/h2",
+		"/dns/example.com/tcp/443/tls/sni/example.org/h2": "/dns/example.com/tcp/443/tls/sni/example.org/h2",
+		"/ip4/127.0.0.1/tcp/443/tls/h2":                   "/ip4/127.0.0.1/tcp/443/tls/h2",
+	} {
+		addrs, err := tpt.Resolve(context.Background(), ma.StringCast(in))
+		require.NoError(t, err)
+		require.Equal(t, []ma.Multiaddr{ma.StringCast(out)}, addrs)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	peerA, ta := newTransport(t)
+	peerB, tb := newTransport(t)
+	ttransport.SubtestTransport(t, ta, tb, "/ip4/127.0.0.1/tcp/0/tls/h2", peerA)
+	ttransport.SubtestTransport(t, tb, ta, "/ip4/127.0.0.1/tcp/0/tls/h2", peerB)
+}
+
+func TestListenWithoutTLSConfig(t *testing.T) {
+	_, u := newUpgrader(t)
+	tpt, err := New(u, nil)
+	require.NoError(t, err)
+	_, err = tpt.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0/tls/h2"))
+	require.ErrorContains(t, err, "tls.Config")
+}
+
+func TestConnection(t *testing.T) {
+	server, tpt := newTransport(t)
+	require.Equal(t, "http2", tpt.TransportName())
+	l, err := tpt.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0/tls/h2"))
+	require.NoError(t, err)
+	defer l.Close()
+	_, last := ma.SplitLast(l.Multiaddr())
+	require.Equal(t, P_H2, last.Protocol().Code)
+
+	msg := []byte("HELLO WORLD")
+	errCh := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		_, tpt := newTransport(t)
+		c, err := tpt.Dial(context.Background(), l.Multiaddr(), server)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		defer c.Close()
+		if c.ConnState().Transport != "http2" {
+			errCh <- errors.New("unexpected transport name")
+			return
+		}
+		str, err := c.OpenStream(context.Background())
+		if err != nil {
+			errCh <- err
+			return
+		}
+		defer str.Close()
+		_, err = str.Write(msg)
+		errCh <- err
+		<-done
+	}()
+
+	c, err := l.Accept()
+	require.NoError(t, err)
+	defer c.Close()
+	require.Equal(t, "http2", c.ConnState().Transport)
+	str, err := c.AcceptStream()
+	require.NoError(t, err)
+	defer str.Close()
+	require.NoError(t, <-errCh)
+	out := make([]byte, len(msg))
+	_, err = io.ReadFull(str, out)
+	require.NoError(t, err)
+	require.Equal(t, msg, out)
+	close(done)
+
+	// Closing the connection ends the stream on the other side.
+	_, err = c.AcceptStream()
+	require.Error(t, err)
+}
+
+func TestDialThroughProxy(t *testing.T) {
+	server, tpt := newTransport(t)
+	l, err := tpt.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0/tls/h2"))
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		c, err := l.Accept()
+		if err == nil {
+			defer c.Close()
+			c.AcceptStream()
+		}
+	}()
+
+	targets := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "only CONNECT is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		targets <- r.Host
+		dst, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer dst.Close()
+		src, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer src.Close()
+		io.WriteString(src, "HTTP/1.1 200 OK\r\n\r\n")
+		go io.Copy(dst, src)
+		io.Copy(src, dst)
+	}))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(t, err)
+
+	_, client := newTransport(t, WithProxy(http.ProxyURL(proxyURL)))
+	c, err := client.Dial(context.Background(), l.Multiaddr(), server)
+	require.NoError(t, err)
+	defer c.Close()
+	_, host, err := manet.DialArgs(l.Multiaddr())
+	require.NoError(t, err)
+	require.Equal(t, host, <-targets)
+}
+
+func TestDialThroughRefusingProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer proxy.Close()
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(t, err)
+
+	_, client := newTransport(t, WithProxy(http.ProxyURL(proxyURL)))
+	_, err = client.Dial(context.Background(), ma.StringCast("/ip4/127.0.0.1/tcp/1/tls/h2"), "")
+	require.ErrorContains(t, err, "403")
+}
+
+// TestLooksLikeHTTPS checks that requests other than the one establishing a
+// connection get the response of a regular web server.
+func TestLooksLikeHTTPS(t *testing.T) {
+	_, tpt := newTransport(t)
+	l, err := tpt.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0/tls/h2"))
+	require.NoError(t, err)
+	defer l.Close()
+	addr := l.Addr().String()
+
+	for _, proto := range []int{1, 2} {
+		tr := &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			ForceAttemptHTTP2: true,
+		}
+		if proto == 1 {
+			// A non-nil map disables HTTP/2.
+			tr.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		}
+		resp, err := (&http.Client{Transport: tr}).Get("https://" + addr + "/")
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, proto, resp.ProtoMajor)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		tr.CloseIdleConnections()
+	}
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	_, tpt := newTransport(t, WithHandshakeTimeout(100*time.Millisecond))
+	l, err := tpt.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0/tls/h2"))
+	require.NoError(t, err)
+	defer l.Close()
+
+	conn, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2"}})
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	// The server sends its HTTP/2 settings, and closes the connection once the
+	// handshake timeout expired.
+	_, err = io.Copy(io.Discard, conn)
+	require.NoError(t, err)
+}
+
+func TestDeadlines(t *testing.T) {
+	_, u := newUpgrader(t)
+	_, tpt := newTransport(t)
+	l, err := newListener(ma.StringCast("/ip4/127.0.0.1/tcp/0/tls/h2"), tpt.tlsConf, u, time.Second)
+	require.NoError(t, err)
+	go l.serve()
+	defer l.Close()
+
+	scope, err := (&network.NullResourceManager{}).OpenConnection(network.DirOutbound, true, l.Multiaddr())
+	require.NoError(t, err)
+	client, err := tpt.maDial(context.Background(), l.Multiaddr(), scope)
+	require.NoError(t, err)
+	defer client.Close()
+	server, _, err := l.Accept()
+	require.NoError(t, err)
+	defer server.Close()
+
+	// A read that times out doesn't break the connection.
+	require.NoError(t, server.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+	_, err = server.Read(make([]byte, 10))
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	require.NoError(t, server.SetReadDeadline(time.Time{}))
+	_, err = client.Write([]byte("foobar"))
+	require.NoError(t, err)
+	b := make([]byte, 10)
+	n, err := server.Read(b)
+	require.NoError(t, err)
+	require.Equal(t, "foobar", string(b[:n]))
+
+	// Writes block once the flow control window is exhausted.
+	require.NoError(t, client.SetWriteDeadline(time.Now().Add(200*time.Millisecond)))
+	buf := make([]byte, 1<<20)
+	for {
+		_, err = client.Write(buf)
+		if err != nil {
+			break
+		}
+	}
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	_, err = client.Write([]byte("foobar"))
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	// Closing unblocks reads.
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Read(b)
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	client.Close()
+	require.Error(t, <-done)
+}
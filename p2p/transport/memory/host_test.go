@@ -0,0 +1,32 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+	"github.com/libp2p/go-libp2p/p2p/transport/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHosts(t *testing.T) {
+	hub := memory.NewHub()
+	opts := []libp2p.Option{
+		libp2p.NoTransports,
+		libp2p.Transport(memory.New, memory.WithHub(hub)),
+		libp2p.ListenAddrStrings("/memory/0"),
+	}
+	h1, err := libp2p.New(opts...)
+	require.NoError(t, err)
+	defer h1.Close()
+	h2, err := libp2p.New(opts...)
+	require.NoError(t, err)
+	defer h2.Close()
+	require.Equal(t, "/memory/1", h1.Addrs()[0].String())
+
+	require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+	res := <-ping.Ping(context.Background(), h2, h1.ID())
+	require.NoError(t, res.Error)
+}
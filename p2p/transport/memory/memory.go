@@ -0,0 +1,323 @@
+// Package memory implements an in-memory transport for go-libp2p.
+//
+// Connections between memory transports never touch the operating system's
+// network stack, but go through the full upgrader path, i.e. they're gated,
+// accounted for by the resource manager, secured and multiplexed like any
+// other connection. This makes the transport suitable for in-process
+// integration tests.
+//
+// Memory addresses have the form /memory/<id>. Listening on /memory/0
+// allocates the next free id on the transport's Hub. Ids are allocated
+// sequentially, starting at 1, so they're deterministic for a given order of
+// Listen calls. The local address of a dialed connection is allocated
+// downwards from the top of the id space, so dialing doesn't shift the ids
+// handed out to listeners.
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/transport"
+
+	logging "github.com/ipfs/go-log/v2"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+var log = logging.Logger("memory-tpt")
+
+// Network is the name of the network returned by Addr.Network.
+const Network = "memory"
+
+// ErrAddrInUse is returned when listening on an id that's already in use.
+var ErrAddrInUse = errors.New("memory address already in use")
+
+// ErrConnRefused is returned when dialing an id nobody listens on.
+var ErrConnRefused = errors.New("connection refused")
+
+// Hub connects memory listeners and dialers. A transport can only reach
+// listeners of transports on the same hub.
+type Hub struct {
+	mx        sync.Mutex
+	lastID    uint64
+	dialIDs   uint64
+	listeners map[uint64]*listener
+}
+
+// NewHub creates a new Hub, isolated from all other hubs.
+func NewHub() *Hub {
+	return &Hub{listeners: make(map[uint64]*listener)}
+}
+
+// defaultHub is used by transports not configured with WithHub.
+var defaultHub = NewHub()
+
+// nextID allocates an id that's not used by any listener. Must be called with
+// the lock held.
+func (h *Hub) nextID() uint64 {
+	for {
+		h.lastID++
+		if _, ok := h.listeners[h.lastID]; !ok && h.lastID != 0 {
+			return h.lastID
+		}
+	}
+}
+
+// nextDialID allocates the local id of a dialed connection. Must be called
+// with the lock held.
+func (h *Hub) nextDialID() uint64 {
+	h.dialIDs++
+	return math.MaxUint64 - h.dialIDs + 1
+}
+
+func (h *Hub) listen(id uint64) (*listener, error) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	if id == 0 {
+		id = h.nextID()
+	} else if _, ok := h.listeners[id]; ok {
+		return nil, fmt.Errorf("failed to listen on /memory/%d: %w", id, ErrAddrInUse)
+	}
+	l := &listener{
+		hub:      h,
+		id:       id,
+		laddr:    memoryAddr(id),
+		incoming: make(chan *conn),
+		closed:   make(chan struct{}),
+	}
+	h.listeners[id] = l
+	return l, nil
+}
+
+func (h *Hub) removeListener(l *listener) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	if h.listeners[l.id] == l {
+		delete(h.listeners, l.id)
+	}
+}
+
+func (h *Hub) dial(ctx context.Context, id uint64) (*conn, error) {
+	h.mx.Lock()
+	l, ok := h.listeners[id]
+	localID := h.nextDialID()
+	h.mx.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("failed to dial /memory/%d: %w", id, ErrConnRefused)
+	}
+
+	local, remote := net.Pipe()
+	lc := &conn{Conn: local, laddr: memoryAddr(localID), raddr: l.laddr}
+	rc := &conn{Conn: remote, laddr: l.laddr, raddr: memoryAddr(localID)}
+	select {
+	case l.incoming <- rc:
+		return lc, nil
+	case <-l.closed:
+		lc.Close()
+		return nil, fmt.Errorf("failed to dial /memory/%d: %w", id, ErrConnRefused)
+	case <-ctx.Done():
+		lc.Close()
+		return nil, ctx.Err()
+	}
+}
+
+// Addr is the net.Addr of a memory connection or listener.
+type Addr uint64
+
+var _ net.Addr = Addr(0)
+
+func (a Addr) Network() string { return Network }
+func (a Addr) String() string  { return strconv.FormatUint(uint64(a), 10) }
+
+func memoryAddr(id uint64) ma.Multiaddr {
+	return ma.StringCast("/memory/" + strconv.FormatUint(id, 10))
+}
+
+// parseAddr returns the id of a /memory multiaddr.
+func parseAddr(a ma.Multiaddr) (uint64, error) {
+	if len(a) != 1 || a[0].Protocol().Code != ma.P_MEMORY {
+		return 0, fmt.Errorf("not a memory multiaddr: %s", a)
+	}
+	return strconv.ParseUint(a[0].Value(), 10, 64)
+}
+
+// conn is one end of an in-memory connection.
+type conn struct {
+	net.Conn
+	laddr, raddr ma.Multiaddr
+}
+
+var _ manet.Conn = &conn{}
+
+func (c *conn) LocalMultiaddr() ma.Multiaddr  { return c.laddr }
+func (c *conn) RemoteMultiaddr() ma.Multiaddr { return c.raddr }
+
+func (c *conn) LocalAddr() net.Addr {
+	id, _ := parseAddr(c.laddr)
+	return Addr(id)
+}
+
+func (c *conn) RemoteAddr() net.Addr {
+	id, _ := parseAddr(c.raddr)
+	return Addr(id)
+}
+
+type listener struct {
+	hub      *Hub
+	id       uint64
+	laddr    ma.Multiaddr
+	incoming chan *conn
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+var _ manet.Listener = &listener{}
+
+func (l *listener) Accept() (manet.Conn, error) {
+	select {
+	case c := <-l.incoming:
+		return c, nil
+	case <-l.closed:
+		return nil, transport.ErrListenerClosed
+	}
+}
+
+func (l *listener) Close() error {
+	l.closeOnce.Do(func() {
+		l.hub.removeListener(l)
+		close(l.closed)
+	})
+	return nil
+}
+
+func (l *listener) Addr() net.Addr {
+	return Addr(l.id)
+}
+
+func (l *listener) Multiaddr() ma.Multiaddr {
+	return l.laddr
+}
+
+type Option func(*Transport) error
+
+// WithHub connects the transport to the given hub instead of the process-wide
+// default hub. This allows isolating groups of hosts, e.g. in parallel tests.
+func WithHub(h *Hub) Option {
+	return func(t *Transport) error {
+		if h == nil {
+			return errors.New("hub must not be nil")
+		}
+		t.hub = h
+		return nil
+	}
+}
+
+// Transport is the in-memory transport.
+type Transport struct {
+	upgrader transport.Upgrader
+	rcmgr    network.ResourceManager
+	hub      *Hub
+}
+
+var _ transport.Transport = &Transport{}
+
+// New creates a new memory transport.
+func New(upgrader transport.Upgrader, rcmgr network.ResourceManager, opts ...Option) (*Transport, error) {
+	if rcmgr == nil {
+		rcmgr = &network.NullResourceManager{}
+	}
+	t := &Transport{
+		upgrader: upgrader,
+		rcmgr:    rcmgr,
+		hub:      defaultHub,
+	}
+	for _, o := range opts {
+		if err := o(t); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// CanDial returns true for /memory multiaddrs.
+func (t *Transport) CanDial(addr ma.Multiaddr) bool {
+	_, err := parseAddr(addr)
+	return err == nil
+}
+
+// Dial dials the peer listening on the given memory address.
+func (t *Transport) Dial(ctx context.Context, raddr ma.Multiaddr, p peer.ID) (transport.CapableConn, error) {
+	id, err := parseAddr(raddr)
+	if err != nil {
+		return nil, err
+	}
+	connScope, err := t.rcmgr.OpenConnection(network.DirOutbound, true, raddr)
+	if err != nil {
+		log.Debugw("resource manager blocked outgoing connection", "peer", p, "addr", raddr, "error", err)
+		return nil, err
+	}
+	c, err := t.dialWithScope(ctx, id, p, connScope)
+	if err != nil {
+		connScope.Done()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (t *Transport) dialWithScope(ctx context.Context, id uint64, p peer.ID, connScope network.ConnManagementScope) (transport.CapableConn, error) {
+	if err := connScope.SetPeer(p); err != nil {
+		log.Debugw("resource manager blocked outgoing connection for peer", "peer", p, "error", err)
+		return nil, err
+	}
+	c, err := t.hub.dial(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	uc, err := t.upgrader.Upgrade(ctx, t, c, network.DirOutbound, p, connScope)
+	if err != nil {
+		return nil, err
+	}
+	return uc, nil
+}
+
+// Listen listens on the given memory address. Use /memory/0 to listen on the
+// next free address.
+func (t *Transport) Listen(laddr ma.Multiaddr) (transport.Listener, error) {
+	id, err := parseAddr(laddr)
+	if err != nil {
+		return nil, err
+	}
+	l, err := t.hub.listen(id)
+	if err != nil {
+		return nil, err
+	}
+	return t.upgrader.UpgradeGatedMaListener(t, t.upgrader.GateMaListener(l)), nil
+}
+
+// Protocols returns the list of terminal protocols this transport can dial.
+func (t *Transport) Protocols() []int {
+	return []int{ma.P_MEMORY}
+}
+
+// Proxy always returns false for the memory transport.
+func (t *Transport) Proxy() bool {
+	return false
+}
+
+// TransportName returns the name of the transport in network.ConnectionState.
+func (t *Transport) TransportName() string {
+	return "memory"
+}
+
+func (t *Transport) String() string {
+	return "memory"
+}
@@ -0,0 +1,142 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/sec"
+	"github.com/libp2p/go-libp2p/core/transport"
+	"github.com/libp2p/go-libp2p/p2p/muxer/yamux"
+	tptu "github.com/libp2p/go-libp2p/p2p/net/upgrader"
+	"github.com/libp2p/go-libp2p/p2p/security/noise"
+	ttransport "github.com/libp2p/go-libp2p/p2p/transport/testsuite"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func newUpgrader(t *testing.T) (peer.ID, transport.Upgrader) {
+	t.Helper()
+	priv, _, err := crypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	id, err := peer.IDFromPrivateKey(priv)
+	require.NoError(t, err)
+	n, err := noise.New(noise.ID, priv, nil)
+	require.NoError(t, err)
+	u, err := tptu.New([]sec.SecureTransport{n}, []tptu.StreamMuxer{{ID: yamux.ID, Muxer: yamux.DefaultTransport}}, nil, nil, nil)
+	require.NoError(t, err)
+	return id, u
+}
+
+func TestMemoryTransport(t *testing.T) {
+	hub := NewHub()
+	peerA, ua := newUpgrader(t)
+	ta, err := New(ua, nil, WithHub(hub))
+	require.NoError(t, err)
+	peerB, ub := newUpgrader(t)
+	tb, err := New(ub, nil, WithHub(hub))
+	require.NoError(t, err)
+
+	ttransport.SubtestTransport(t, ta, tb, "/memory/0", peerA)
+	ttransport.SubtestTransport(t, tb, ta, "/memory/0", peerB)
+}
+
+func TestListenAddrs(t *testing.T) {
+	hub := NewHub()
+	_, u := newUpgrader(t)
+	tpt, err := New(u, nil, WithHub(hub))
+	require.NoError(t, err)
+
+	l1, err := tpt.Listen(ma.StringCast("/memory/0"))
+	require.NoError(t, err)
+	defer l1.Close()
+	require.Equal(t, "/memory/1", l1.Multiaddr().String())
+
+	l2, err := tpt.Listen(ma.StringCast("/memory/0"))
+	require.NoError(t, err)
+	require.Equal(t, "/memory/2", l2.Multiaddr().String())
+
+	_, err = tpt.Listen(ma.StringCast("/memory/2"))
+	require.ErrorIs(t, err, ErrAddrInUse)
+	require.NoError(t, l2.Close())
+	l2, err = tpt.Listen(ma.StringCast("/memory/2"))
+	require.NoError(t, err)
+	require.NoError(t, l2.Close())
+
+	require.True(t, tpt.CanDial(ma.StringCast("/memory/1")))
+	require.False(t, tpt.CanDial(ma.StringCast("/ip4/127.0.0.1/tcp/1")))
+	_, err = tpt.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0"))
+	require.Error(t, err)
+}
+
+func TestDialDoesntShiftListenIDs(t *testing.T) {
+	hub := NewHub()
+	l, err := hub.listen(0)
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		c := <-l.incoming
+		c.Close()
+	}()
+	c, err := hub.dial(context.Background(), l.id)
+	require.NoError(t, err)
+	defer c.Close()
+	require.Equal(t, fmt.Sprintf("/memory/%d", uint64(math.MaxUint64)), c.LocalMultiaddr().String())
+
+	l2, err := hub.listen(0)
+	require.NoError(t, err)
+	defer l2.Close()
+	require.Equal(t, "/memory/2", l2.laddr.String())
+}
+
+func TestDialRefused(t *testing.T) {
+	_, u := newUpgrader(t)
+	tpt, err := New(u, nil, WithHub(NewHub()))
+	require.NoError(t, err)
+	_, err = tpt.Dial(context.Background(), ma.StringCast("/memory/42"), "")
+	require.ErrorIs(t, err, ErrConnRefused)
+}
+
+func TestConnState(t *testing.T) {
+	hub := NewHub()
+	serverID, us := newUpgrader(t)
+	server, err := New(us, nil, WithHub(hub))
+	require.NoError(t, err)
+	_, uc := newUpgrader(t)
+	client, err := New(uc, nil, WithHub(hub))
+	require.NoError(t, err)
+
+	l, err := server.Listen(ma.StringCast("/memory/0"))
+	require.NoError(t, err)
+	defer l.Close()
+
+	accepted := make(chan transport.CapableConn, 1)
+	go func() {
+		c, err := l.Accept()
+		if err == nil {
+			accepted <- c
+		}
+	}()
+
+	c, err := client.Dial(context.Background(), l.Multiaddr(), serverID)
+	require.NoError(t, err)
+	defer c.Close()
+	sc := <-accepted
+	defer sc.Close()
+
+	for _, c := range []network.ConnMultiaddrs{c, sc} {
+		_, err := parseAddr(c.LocalMultiaddr())
+		require.NoError(t, err)
+		_, err = parseAddr(c.RemoteMultiaddr())
+		require.NoError(t, err)
+	}
+	require.True(t, sc.LocalMultiaddr().Equal(l.Multiaddr()))
+	require.True(t, c.LocalMultiaddr().Equal(sc.RemoteMultiaddr()))
+	require.Equal(t, "memory", c.ConnState().Transport)
+	require.Equal(t, "memory", sc.ConnState().Transport)
+}
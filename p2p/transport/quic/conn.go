@@ -2,13 +2,19 @@ package libp2pquic
 
 import (
 	"context"
+	"net"
+	"sync/atomic"
 
+	"github.com/libp2p/go-libp2p/core/connmgr"
 	ic "github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	tpt "github.com/libp2p/go-libp2p/core/transport"
 
+	"github.com/libp2p/go-libp2p/p2p/muxer/muxstats"
+	"github.com/libp2p/go-libp2p/p2p/transport/quicreuse"
 	ma "github.com/multiformats/go-multiaddr"
+
 	"github.com/quic-go/quic-go"
 )
 
@@ -18,14 +24,51 @@ type conn struct {
 	scope     network.ConnManagementScope
 
 	localPeer      peer.ID
-	localMultiaddr ma.Multiaddr
+	localMultiaddr *pathMultiaddr
 
 	remotePeerID    peer.ID
 	remotePubKey    ic.PubKey
-	remoteMultiaddr ma.Multiaddr
+	remoteMultiaddr *pathMultiaddr
+
+	openStreams atomic.Int64
+	stalls      muxstats.WriteStalls
+}
+
+// pathMultiaddr is the local or remote multiaddr of a connection. The address
+// changes when the connection migrates to a new path.
+type pathMultiaddr struct {
+	version quic.Version
+	current atomic.Pointer[addrAndMultiaddr]
+}
+
+type addrAndMultiaddr struct {
+	addr  net.Addr
+	maddr ma.Multiaddr
+}
+
+func newPathMultiaddr(addr net.Addr, maddr ma.Multiaddr, v quic.Version) *pathMultiaddr {
+	m := &pathMultiaddr{version: v}
+	m.current.Store(&addrAndMultiaddr{addr: addr, maddr: maddr})
+	return m
+}
+
+// get returns the multiaddr of addr, the current local or remote address of
+// the connection.
+func (m *pathMultiaddr) get(addr net.Addr) ma.Multiaddr {
+	cur := m.current.Load()
+	if addr == nil || quicreuse.SameAddr(cur.addr, addr) {
+		return cur.maddr
+	}
+	maddr, err := quicreuse.ToQuicMultiaddr(addr, m.version)
+	if err != nil {
+		return cur.maddr
+	}
+	m.current.CompareAndSwap(cur, &addrAndMultiaddr{addr: addr, maddr: maddr})
+	return maddr
 }
 
 var _ tpt.CapableConn = &conn{}
+var _ network.MigratableConn = &conn{}
 
 // Close closes the connection.
 // It must be called even if the peer closed the connection in order for
@@ -63,7 +106,7 @@ func (c *conn) OpenStream(ctx context.Context) (network.MuxedStream, error) {
 	if err != nil {
 		return nil, parseStreamError(err)
 	}
-	return &stream{Stream: qstr}, nil
+	return c.newStream(qstr), nil
 }
 
 // AcceptStream accepts a stream opened by the other side.
@@ -72,7 +115,17 @@ func (c *conn) AcceptStream() (network.MuxedStream, error) {
 	if err != nil {
 		return nil, parseStreamError(err)
 	}
-	return &stream{Stream: qstr}, nil
+	return c.newStream(qstr), nil
+}
+
+// MuxerStats returns statistics about the streams of the connection.
+func (c *conn) MuxerStats() network.MuxerStats {
+	stalled, stalledTime := c.stalls.Stats()
+	return network.MuxerStats{
+		OpenStreams:      int(c.openStreams.Load()),
+		StalledWrites:    stalled,
+		StalledWriteTime: stalledTime,
+	}
 }
 
 // LocalPeer returns our peer ID
@@ -85,15 +138,32 @@ func (c *conn) RemotePeer() peer.ID { return c.remotePeerID }
 func (c *conn) RemotePublicKey() ic.PubKey { return c.remotePubKey }
 
 // LocalMultiaddr returns the local Multiaddr associated
-func (c *conn) LocalMultiaddr() ma.Multiaddr { return c.localMultiaddr }
+// It reflects the migration of the connection to a new local socket.
+func (c *conn) LocalMultiaddr() ma.Multiaddr {
+	return c.localMultiaddr.get(c.quicConn.LocalAddr())
+}
 
 // RemoteMultiaddr returns the remote Multiaddr associated
-func (c *conn) RemoteMultiaddr() ma.Multiaddr { return c.remoteMultiaddr }
+// It reflects the migration of the connection to a new path by the peer.
+func (c *conn) RemoteMultiaddr() ma.Multiaddr {
+	return c.remoteMultiaddr.get(c.quicConn.RemoteAddr())
+}
 
 func (c *conn) Transport() tpt.Transport { return c.transport }
 
+// Migrate moves a dialed connection to a new local UDP socket. It fails unless
+// connection migration was enabled using quicreuse.EnableConnectionMigration.
+func (c *conn) Migrate(ctx context.Context) error {
+	return c.transport.connManager.MigrateConn(ctx, c.quicConn)
+}
+
 func (c *conn) Scope() network.ConnScope { return c.scope }
 
+// securedConnInfo describes the connection to the connection gater.
+func (c *conn) securedConnInfo() connmgr.SecuredConnInfo {
+	return connmgr.SecuredConnInfo{ConnMultiaddrs: c, Transport: c.ConnState().Transport}
+}
+
 // ConnState is the state of security connection.
 func (c *conn) ConnState() network.ConnectionState {
 	t := "quic-v1"
@@ -12,6 +12,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/libp2p/go-libp2p/core/connmgr"
 	ic "github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/network"
 	mocknetwork "github.com/libp2p/go-libp2p/core/network/mocks"
@@ -346,6 +347,60 @@ func testStreamsErrorCode(t *testing.T, tc *connTestCase) {
 
 }
 
+func TestMuxerStats(t *testing.T) {
+	for _, tc := range connTestCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			testMuxerStats(t, tc)
+		})
+	}
+}
+
+func testMuxerStats(t *testing.T, tc *connTestCase) {
+	serverID, serverKey := createPeer(t)
+	_, clientKey := createPeer(t)
+
+	serverTransport, err := NewTransport(serverKey, newConnManager(t, tc.Options...), nil, nil, nil)
+	require.NoError(t, err)
+	defer serverTransport.(io.Closer).Close()
+	ln := runServer(t, serverTransport, "/ip4/127.0.0.1/udp/0/quic-v1")
+	defer ln.Close()
+
+	clientTransport, err := NewTransport(clientKey, newConnManager(t, tc.Options...), nil, nil, nil)
+	require.NoError(t, err)
+	defer clientTransport.(io.Closer).Close()
+	conn, err := clientTransport.Dial(context.Background(), ln.Multiaddr(), serverID)
+	require.NoError(t, err)
+	defer conn.Close()
+	serverConn, err := ln.Accept()
+	require.NoError(t, err)
+	defer serverConn.Close()
+
+	openStreams := func(c tpt.CapableConn) int {
+		return c.(network.ConnMuxerStats).MuxerStats().OpenStreams
+	}
+	str, err := conn.OpenStream(context.Background())
+	require.NoError(t, err)
+	_, err = str.Write([]byte("foobar"))
+	require.NoError(t, err)
+	require.Equal(t, 1, openStreams(conn))
+	sstr, err := serverConn.AcceptStream()
+	require.NoError(t, err)
+	require.Equal(t, 1, openStreams(serverConn))
+
+	// the stream stays open until both sides are done
+	require.NoError(t, str.CloseWrite())
+	data, err := io.ReadAll(sstr)
+	require.NoError(t, err)
+	require.Equal(t, []byte("foobar"), data)
+	require.Equal(t, 1, openStreams(serverConn))
+	require.NoError(t, sstr.Close())
+	require.Eventually(t, func() bool { return openStreams(serverConn) == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	_, err = io.ReadAll(str)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return openStreams(conn) == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
 func TestHandshakeFailPeerIDMismatch(t *testing.T) {
 	for _, tc := range connTestCases {
 		t.Run(tc.Name, func(t *testing.T) {
@@ -464,8 +519,9 @@ func testConnectionGating(t *testing.T, tc *connTestCase) {
 
 		// make sure that connection attempts fails
 		_, err = clientTransport.Dial(context.Background(), ln.Multiaddr(), serverID)
-		require.Error(t, err)
-		require.Contains(t, err.Error(), "connection gated")
+		var gerr *connmgr.GatedError
+		require.ErrorAs(t, err, &gerr)
+		require.Equal(t, serverID, gerr.Peer)
 
 		// now allow the peerId and make sure the connection goes through
 		cg.EXPECT().InterceptSecured(gomock.Any(), gomock.Any(), gomock.Any()).Return(true)
@@ -475,6 +531,44 @@ func testConnectionGating(t *testing.T, tc *connTestCase) {
 	})
 }
 
+func TestSlowGaterDoesntBlockAccept(t *testing.T) {
+	serverID, serverKey := createPeer(t)
+	slowID, slowKey := createPeer(t)
+	fastID, fastKey := createPeer(t)
+
+	release := make(chan struct{})
+	defer close(release)
+	cg := NewMockConnectionGater(gomock.NewController(t))
+	cg.EXPECT().InterceptAccept(gomock.Any()).Return(true).AnyTimes()
+	cg.EXPECT().InterceptSecured(network.DirInbound, gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ network.Direction, p peer.ID, _ network.ConnMultiaddrs) bool {
+			if p == slowID {
+				<-release
+			}
+			return true
+		}).AnyTimes()
+
+	serverTransport, err := NewTransport(serverKey, newConnManager(t), nil, cg, nil)
+	require.NoError(t, err)
+	defer serverTransport.(io.Closer).Close()
+	ln := runServer(t, serverTransport, "/ip4/127.0.0.1/udp/0/quic-v1")
+	defer ln.Close()
+
+	for _, key := range []ic.PrivKey{slowKey, fastKey} {
+		clientTransport, err := NewTransport(key, newConnManager(t), nil, nil, nil)
+		require.NoError(t, err)
+		defer clientTransport.(io.Closer).Close()
+		conn, err := clientTransport.Dial(context.Background(), ln.Multiaddr(), serverID)
+		require.NoError(t, err)
+		defer conn.Close()
+	}
+
+	// the fast peer is accepted while the slow one is still being gated
+	conn, err := ln.Accept()
+	require.NoError(t, err)
+	require.Equal(t, fastID, conn.RemotePeer())
+}
+
 func TestDialTwo(t *testing.T) {
 	for _, tc := range connTestCases {
 		t.Run(tc.Name, func(t *testing.T) {
@@ -730,3 +824,55 @@ func TestHolePunching(t *testing.T) {
 	<-done1
 	<-done2
 }
+
+func TestPathChangeNotification(t *testing.T) {
+	serverID, serverKey := createPeer(t)
+	_, clientKey := createPeer(t)
+
+	serverTransport, err := NewTransport(serverKey, newConnManager(t), nil, nil, nil)
+	require.NoError(t, err)
+	defer serverTransport.(io.Closer).Close()
+	type pathChange struct {
+		conn      tpt.CapableConn
+		oldRemote ma.Multiaddr
+	}
+	changes := make(chan pathChange, 10)
+	serverTransport.(tpt.PathChangeNotifier).NotifyPathChange(func(c tpt.CapableConn, oldRemote ma.Multiaddr) {
+		changes <- pathChange{conn: c, oldRemote: oldRemote}
+	})
+	ln := runServer(t, serverTransport, "/ip4/127.0.0.1/udp/0/quic-v1")
+	defer ln.Close()
+
+	clientConnManager := newConnManager(t, quicreuse.EnableConnectionMigration())
+	clientTransport, err := NewTransport(clientKey, clientConnManager, nil, nil, nil)
+	require.NoError(t, err)
+	defer clientTransport.(io.Closer).Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	clientConn, err := clientTransport.Dial(ctx, ln.Multiaddr(), serverID)
+	require.NoError(t, err)
+	defer clientConn.Close()
+	serverConn, err := ln.Accept()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	oldRemote := serverConn.RemoteMultiaddr()
+
+	require.NoError(t, clientConnManager.MigrateConn(ctx, clientConn.(*conn).quicConn))
+	// The server only switches to the new path after receiving data on it.
+	str, err := clientConn.OpenStream(ctx)
+	require.NoError(t, err)
+	for {
+		_, err := str.Write([]byte("foobar"))
+		require.NoError(t, err)
+		select {
+		case c := <-changes:
+			require.Equal(t, serverConn, c.conn)
+			require.True(t, oldRemote.Equal(c.oldRemote))
+			require.False(t, oldRemote.Equal(serverConn.RemoteMultiaddr()))
+			return
+		case <-time.After(50 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("expected a path change notification")
+		}
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"net"
+	"sync"
 
+	"github.com/libp2p/go-libp2p/core/connmgr"
 	ic "github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -15,6 +17,11 @@ import (
 	"github.com/quic-go/quic-go"
 )
 
+// acceptQueueLength is the number of inbound connections that are gated in
+// parallel, or wait to be accepted, before the listener stops accepting new
+// connections.
+const acceptQueueLength = 16
+
 // A listener listens for QUIC connections.
 type listener struct {
 	reuseListener   quicreuse.Listener
@@ -23,9 +30,20 @@ type listener struct {
 	privKey         ic.PrivKey
 	localPeer       peer.ID
 	localMultiaddrs map[quic.Version]ma.Multiaddr
+
+	// threshold limits the number of connections being gated or waiting in
+	// incoming.
+	threshold chan struct{}
+	incoming  chan *conn
+	// err is the error the accept loop stopped with. It is set before
+	// incoming is closed.
+	err error
+
+	closeOnce sync.Once
+	closed    chan struct{}
 }
 
-func newListener(ln quicreuse.Listener, t *transport, localPeer peer.ID, key ic.PrivKey, rcmgr network.ResourceManager) (listener, error) {
+func newListener(ln quicreuse.Listener, t *transport, localPeer peer.ID, key ic.PrivKey, rcmgr network.ResourceManager) (*listener, error) {
 	localMultiaddrs := make(map[quic.Version]ma.Multiaddr)
 	for _, addr := range ln.Multiaddrs() {
 		if _, err := addr.ValueForProtocol(ma.P_QUIC_V1); err == nil {
@@ -33,22 +51,36 @@ func newListener(ln quicreuse.Listener, t *transport, localPeer peer.ID, key ic.
 		}
 	}
 
-	return listener{
+	l := &listener{
 		reuseListener:   ln,
 		transport:       t,
 		rcmgr:           rcmgr,
 		privKey:         key,
 		localPeer:       localPeer,
 		localMultiaddrs: localMultiaddrs,
-	}, nil
+		threshold:       make(chan struct{}, acceptQueueLength),
+		incoming:        make(chan *conn),
+		closed:          make(chan struct{}),
+	}
+	go l.acceptLoop()
+	return l, nil
 }
 
-// Accept accepts new connections.
-func (l *listener) Accept() (tpt.CapableConn, error) {
+// acceptLoop accepts QUIC connections and gates each of them in its own
+// goroutine, so that a slow connection gater doesn't hold up the connections
+// accepted after it.
+func (l *listener) acceptLoop() {
+	var wg sync.WaitGroup
+	defer func() {
+		wg.Wait()
+		close(l.incoming)
+	}()
+
 	for {
 		qconn, err := l.reuseListener.Accept(context.Background())
 		if err != nil {
-			return nil, err
+			l.err = err
+			return
 		}
 		c, err := l.wrapConn(qconn)
 		if err != nil {
@@ -57,27 +89,66 @@ func (l *listener) Accept() (tpt.CapableConn, error) {
 			continue
 		}
 		l.transport.addConn(qconn, c)
-		if l.transport.gater != nil && !(l.transport.gater.InterceptAccept(c) && l.transport.gater.InterceptSecured(network.DirInbound, c.remotePeerID, c)) {
+		if l.transport.gater != nil && !l.transport.gater.InterceptAccept(c) {
 			c.closeWithError(quic.ApplicationErrorCode(network.ConnGated), "connection gated")
 			continue
 		}
 
-		// return through active hole punching if any
-		key := holePunchKey{addr: qconn.RemoteAddr().String(), peer: c.remotePeerID}
-		var wasHolePunch bool
-		l.transport.holePunchingMx.Lock()
-		holePunch, ok := l.transport.holePunching[key]
-		if ok && !holePunch.fulfilled {
-			holePunch.connCh <- c
-			wasHolePunch = true
-			holePunch.fulfilled = true
-		}
-		l.transport.holePunchingMx.Unlock()
-		if wasHolePunch {
+		select {
+		case l.threshold <- struct{}{}:
+		case <-l.closed:
+			c.closeWithError(quic.ApplicationErrorCode(network.ConnRateLimited), "")
 			continue
 		}
-		return c, nil
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-l.threshold }()
+			l.handleConn(qconn, c)
+		}()
+	}
+}
+
+// handleConn gates a secured connection, and hands it over to an active hole
+// punch or queues it to be accepted.
+func (l *listener) handleConn(qconn *quic.Conn, c *conn) {
+	if l.transport.gater != nil &&
+		connmgr.InterceptSecuredContext(qconn.Context(), l.transport.gater, network.DirInbound, c.remotePeerID, c.securedConnInfo()) != nil {
+		c.closeWithError(quic.ApplicationErrorCode(network.ConnGated), "connection gated")
+		return
+	}
+
+	// return through active hole punching if any
+	key := holePunchKey{addr: qconn.RemoteAddr().String(), peer: c.remotePeerID}
+	var wasHolePunch bool
+	l.transport.holePunchingMx.Lock()
+	holePunch, ok := l.transport.holePunching[key]
+	if ok && !holePunch.fulfilled {
+		holePunch.connCh <- c
+		wasHolePunch = true
+		holePunch.fulfilled = true
+	}
+	l.transport.holePunchingMx.Unlock()
+	if wasHolePunch {
+		return
+	}
+
+	select {
+	case l.incoming <- c:
+	case <-l.closed:
+		c.closeWithError(quic.ApplicationErrorCode(network.ConnRateLimited), "")
+	}
+}
+
+// Accept accepts new connections.
+func (l *listener) Accept() (tpt.CapableConn, error) {
+	for c := range l.incoming {
+		// Could have been closed while waiting to be accepted.
+		if !c.IsClosed() {
+			return c, nil
+		}
 	}
+	return nil, l.err
 }
 
 // wrapConn wraps a QUIC connection into a libp2p [tpt.CapableConn].
@@ -140,8 +211,8 @@ func (l *listener) wrapConnWithScope(qconn *quic.Conn, connScope network.ConnMan
 		transport:       l.transport,
 		scope:           connScope,
 		localPeer:       l.localPeer,
-		localMultiaddr:  localMultiaddr,
-		remoteMultiaddr: remoteMultiaddr,
+		localMultiaddr:  newPathMultiaddr(qconn.LocalAddr(), localMultiaddr, qconn.ConnectionState().Version),
+		remoteMultiaddr: newPathMultiaddr(qconn.RemoteAddr(), remoteMultiaddr, qconn.ConnectionState().Version),
 		remotePeerID:    remotePeerID,
 		remotePubKey:    remotePubKey,
 	}, nil
@@ -149,6 +220,7 @@ func (l *listener) wrapConnWithScope(qconn *quic.Conn, connScope network.ConnMan
 
 // Close closes the listener.
 func (l *listener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
 	return l.reuseListener.Close()
 }
 
@@ -15,6 +15,7 @@ import (
 	ic "github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/network"
 	mocknetwork "github.com/libp2p/go-libp2p/core/network/mocks"
+	"github.com/libp2p/go-libp2p/core/test"
 	tpt "github.com/libp2p/go-libp2p/core/transport"
 	"github.com/libp2p/go-libp2p/p2p/transport/quicreuse"
 	"github.com/quic-go/quic-go"
@@ -66,6 +67,15 @@ func TestListenAddr(t *testing.T) {
 		}
 		require.Contains(t, multiaddrsStrings, fmt.Sprintf("/ip6/::/udp/%d/quic-v1", port))
 	})
+
+	t.Run("for IPv6 link-local", func(t *testing.T) {
+		ll := test.LinkLocalAddr(t)
+		ln, err := tr.Listen(ll.Encapsulate(ma.StringCast("/udp/0/quic-v1")))
+		require.NoError(t, err)
+		defer ln.Close()
+		port := ln.Addr().(*net.UDPAddr).Port
+		require.Equal(t, ll.Encapsulate(ma.StringCast(fmt.Sprintf("/udp/%d/quic-v1", port))).String(), ln.Multiaddr().String())
+	})
 }
 
 func TestAccepting(t *testing.T) {
@@ -1,8 +1,11 @@
 package libp2pquic
 
 import (
+	"context"
 	"errors"
 	"math"
+	"sync/atomic"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/network"
 
@@ -15,10 +18,42 @@ const (
 
 type stream struct {
 	*quic.Stream
+	state *streamState
 }
 
 var _ network.MuxedStream = stream{}
 
+// streamState counts a stream as open on its connection, until both its read
+// and its write side are done.
+type streamState struct {
+	conn     *conn
+	readDone atomic.Bool
+	// sides is the number of sides of the stream that aren't done yet.
+	sides atomic.Int32
+}
+
+func (c *conn) newStream(qstr *quic.Stream) *stream {
+	st := &streamState{conn: c}
+	st.sides.Store(2)
+	c.openStreams.Add(1)
+	// The context is canceled when the write side is closed or reset, or
+	// when the peer stops reading.
+	context.AfterFunc(qstr.Context(), st.sideDone)
+	return &stream{Stream: qstr, state: st}
+}
+
+func (st *streamState) doneReading() {
+	if st.readDone.CompareAndSwap(false, true) {
+		st.sideDone()
+	}
+}
+
+func (st *streamState) sideDone() {
+	if st.sides.Add(-1) == 0 {
+		st.conn.openStreams.Add(-1)
+	}
+}
+
 func parseStreamError(err error) error {
 	if err == nil {
 		return err
@@ -56,36 +91,49 @@ func parseStreamError(err error) error {
 
 func (s stream) Read(b []byte) (n int, err error) {
 	n, err = s.Stream.Read(b)
+	if err != nil && s.state != nil {
+		s.state.doneReading()
+	}
 	return n, parseStreamError(err)
 }
 
 func (s stream) Write(b []byte) (n int, err error) {
+	if s.state != nil {
+		defer s.state.conn.stalls.Track(time.Now())
+	}
 	n, err = s.Stream.Write(b)
 	return n, parseStreamError(err)
 }
 
 func (s stream) Reset() error {
-	s.Stream.CancelRead(reset)
+	s.cancelRead(reset)
 	s.Stream.CancelWrite(reset)
 	return nil
 }
 
 func (s stream) ResetWithError(errCode network.StreamErrorCode) error {
-	s.Stream.CancelRead(quic.StreamErrorCode(errCode))
+	s.cancelRead(quic.StreamErrorCode(errCode))
 	s.Stream.CancelWrite(quic.StreamErrorCode(errCode))
 	return nil
 }
 
 func (s stream) Close() error {
-	s.Stream.CancelRead(reset)
+	s.cancelRead(reset)
 	return s.Stream.Close()
 }
 
 func (s stream) CloseRead() error {
-	s.Stream.CancelRead(reset)
+	s.cancelRead(reset)
 	return nil
 }
 
 func (s stream) CloseWrite() error {
 	return s.Stream.Close()
 }
+
+func (s stream) cancelRead(code quic.StreamErrorCode) {
+	s.Stream.CancelRead(code)
+	if s.state != nil {
+		s.state.doneReading()
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"slices"
 	"sync"
 	"time"
 
@@ -52,12 +53,17 @@ type transport struct {
 	connMx sync.Mutex
 	conns  map[*quic.Conn]*conn
 
+	pathChangeMx       sync.Mutex
+	pathChangeHandlers []func(tpt.CapableConn, ma.Multiaddr)
+	removePathObserver func()
+
 	listenersMu sync.Mutex
 	// map of UDPAddr as string to a virtualListeners
 	listeners map[string][]*virtualListener
 }
 
 var _ tpt.Transport = &transport{}
+var _ tpt.PathChangeNotifier = &transport{}
 
 type holePunchKey struct {
 	addr string
@@ -161,14 +167,16 @@ func (t *transport) dialWithScope(ctx context.Context, raddr ma.Multiaddr, p pee
 		transport:       t,
 		scope:           scope,
 		localPeer:       t.localPeer,
-		localMultiaddr:  localMultiaddr,
+		localMultiaddr:  newPathMultiaddr(pconn.LocalAddr(), localMultiaddr, pconn.ConnectionState().Version),
 		remotePubKey:    remotePubKey,
 		remotePeerID:    p,
-		remoteMultiaddr: raddr,
+		remoteMultiaddr: newPathMultiaddr(pconn.RemoteAddr(), raddr, pconn.ConnectionState().Version),
 	}
-	if t.gater != nil && !t.gater.InterceptSecured(network.DirOutbound, p, c) {
-		pconn.CloseWithError(quic.ApplicationErrorCode(network.ConnGated), "connection gated")
-		return nil, fmt.Errorf("secured connection gated")
+	if t.gater != nil {
+		if err := connmgr.InterceptSecuredContext(ctx, t.gater, network.DirOutbound, p, c.securedConnInfo()); err != nil {
+			pconn.CloseWithError(quic.ApplicationErrorCode(network.ConnGated), "connection gated")
+			return nil, err
+		}
 	}
 	t.addConn(pconn, c)
 	return c, nil
@@ -273,8 +281,13 @@ loop:
 	}
 }
 
-// Don't use mafmt.QUIC as we don't want to dial DNS addresses. Just /ip{4,6}/udp/quic-v1
-var dialMatcher = mafmt.And(mafmt.IP, mafmt.Base(ma.P_UDP), mafmt.Base(ma.P_QUIC_V1))
+// Don't use mafmt.QUIC as we don't want to dial DNS addresses. Just /ip{4,6}/udp/quic-v1,
+// optionally with an /ip6zone for IPv6 link-local addresses.
+var dialMatcher = mafmt.And(
+	mafmt.Or(mafmt.IP, mafmt.And(mafmt.Base(ma.P_IP6ZONE), mafmt.Base(ma.P_IP6))),
+	mafmt.Base(ma.P_UDP),
+	mafmt.Base(ma.P_QUIC_V1),
+)
 
 // CanDial determines if we can dial to an address
 func (t *transport) CanDial(addr ma.Multiaddr) bool {
@@ -321,7 +334,7 @@ func (t *transport) Listen(addr ma.Multiaddr) (tpt.Listener, error) {
 			_ = ln.Close()
 			return nil, err
 		}
-		underlyingListener = &l
+		underlyingListener = l
 
 		acceptRunner = &acceptLoopRunner{
 			acceptSem: make(chan struct{}, 1),
@@ -373,9 +386,49 @@ func (t *transport) String() string {
 }
 
 func (t *transport) Close() error {
+	t.pathChangeMx.Lock()
+	removePathObserver := t.removePathObserver
+	t.removePathObserver = nil
+	t.pathChangeMx.Unlock()
+	// Removing the observer waits for it to return, and the observer takes
+	// pathChangeMx in onPathChange.
+	if removePathObserver != nil {
+		removePathObserver()
+	}
 	return nil
 }
 
+// NotifyPathChange implements the tpt.PathChangeNotifier interface.
+func (t *transport) NotifyPathChange(f func(c tpt.CapableConn, oldRemote ma.Multiaddr)) {
+	t.pathChangeMx.Lock()
+	defer t.pathChangeMx.Unlock()
+	t.pathChangeHandlers = append(t.pathChangeHandlers, f)
+	if t.removePathObserver == nil {
+		t.removePathObserver = t.connManager.AddPathChangeObserver(t.onPathChange)
+	}
+}
+
+func (t *transport) onPathChange(qconn *quic.Conn, oldRemote, _ net.Addr) {
+	t.connMx.Lock()
+	c, ok := t.conns[qconn]
+	t.connMx.Unlock()
+	// The connection might belong to another transport sharing the ConnManager.
+	if !ok {
+		return
+	}
+	oldMaddr, err := quicreuse.ToQuicMultiaddr(oldRemote, qconn.ConnectionState().Version)
+	if err != nil {
+		log.Debugw("failed to convert previous remote address", "addr", oldRemote, "error", err)
+		return
+	}
+	t.pathChangeMx.Lock()
+	handlers := slices.Clone(t.pathChangeHandlers)
+	t.pathChangeMx.Unlock()
+	for _, h := range handlers {
+		h(c, oldMaddr)
+	}
+}
+
 func (t *transport) CloseVirtualListener(l *virtualListener) error {
 	t.listenersMu.Lock()
 	defer t.listenersMu.Unlock()
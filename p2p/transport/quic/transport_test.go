@@ -6,6 +6,7 @@ import (
 	"crypto/x509"
 	"io"
 	"testing"
+	"time"
 
 	ic "github.com/libp2p/go-libp2p/core/crypto"
 	tpt "github.com/libp2p/go-libp2p/core/transport"
@@ -51,6 +52,7 @@ func TestCanDial(t *testing.T) {
 	valid := []string{
 		"/ip4/127.0.0.1/udp/1234/quic-v1",
 		"/ip4/5.5.5.5/udp/0/quic-v1",
+		"/ip6zone/eth0/ip6/fe80::1/udp/1234/quic-v1",
 	}
 	for _, s := range invalid {
 		invalidAddr, err := ma.NewMultiaddr(s)
@@ -67,3 +69,23 @@ func TestCanDial(t *testing.T) {
 		}
 	}
 }
+
+func TestCloseWhileObservingPathChange(t *testing.T) {
+	tr := getTransport(t).(*transport)
+	// Removing the observer waits for a concurrent call of onPathChange, which
+	// takes pathChangeMx.
+	tr.removePathObserver = func() {
+		tr.pathChangeMx.Lock()
+		tr.pathChangeMx.Unlock()
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tr.Close()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close deadlocked")
+	}
+}
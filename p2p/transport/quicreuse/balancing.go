@@ -0,0 +1,80 @@
+package quicreuse
+
+import (
+	"cmp"
+	"fmt"
+	"net"
+	"slices"
+)
+
+// DialBalancing is the policy used to choose between multiple transports that
+// are equally suitable for a dial, e.g. several transports lent using
+// LendTransport.
+type DialBalancing int
+
+const (
+	// DialBalancingNone uses an arbitrary transport. This is the default.
+	DialBalancingNone DialBalancing = iota
+	// DialBalancingRoundRobin cycles through the transports.
+	DialBalancingRoundRobin
+	// DialBalancingLeastConns uses the transport with the fewest active
+	// connections. Connections are only counted once the handshake completed,
+	// so concurrent dials may be routed to the same transport.
+	DialBalancingLeastConns
+)
+
+// WithDialBalancing sets the policy used to spread dials over multiple
+// transports bound to different ports. This allows spreading the load over
+// multiple UDP sockets and their receive queues on high-throughput nodes.
+// Transports that are associated with the dial's association are still
+// preferred, and the policy only chooses between them.
+func WithDialBalancing(b DialBalancing) Option {
+	return func(m *ConnManager) error {
+		switch b {
+		case DialBalancingNone, DialBalancingRoundRobin, DialBalancingLeastConns:
+		default:
+			return fmt.Errorf("invalid dial balancing policy: %d", b)
+		}
+		m.dialBalancing = b
+		return nil
+	}
+}
+
+// selectTransportLocked chooses one of trs for a dial with the given
+// association. It returns nil if trs is empty. r.mutex must be held.
+func (r *reuse) selectTransportLocked(trs map[int]*refcountedTransport, association any) *refcountedTransport {
+	if len(trs) == 0 {
+		return nil
+	}
+	candidates := make([]*refcountedTransport, 0, len(trs))
+	for _, tr := range trs {
+		if tr.hasAssociation(association) {
+			candidates = append(candidates, tr)
+		}
+	}
+	// We don't have a transport with the association, use any one
+	if len(candidates) == 0 {
+		for _, tr := range trs {
+			candidates = append(candidates, tr)
+		}
+	}
+
+	switch r.dialBalancing {
+	case DialBalancingRoundRobin:
+		slices.SortFunc(candidates, compareLocalPort)
+		tr := candidates[r.nextDial%uint64(len(candidates))]
+		r.nextDial++
+		return tr
+	case DialBalancingLeastConns:
+		slices.SortFunc(candidates, compareLocalPort)
+		return slices.MinFunc(candidates, func(a, b *refcountedTransport) int {
+			return cmp.Compare(a.activeConns.Load(), b.activeConns.Load())
+		})
+	default:
+		return candidates[0]
+	}
+}
+
+func compareLocalPort(a, b *refcountedTransport) int {
+	return cmp.Compare(a.LocalAddr().(*net.UDPAddr).Port, b.LocalAddr().(*net.UDPAddr).Port)
+}
@@ -12,6 +12,7 @@ import (
 	"io"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/libp2p/go-netroute"
 	ma "github.com/multiformats/go-multiaddr"
@@ -50,16 +51,30 @@ type ConnManager struct {
 	reuseUDP4       *reuse
 	reuseUDP6       *reuse
 	enableReuseport bool
+	dialBalancing   DialBalancing
 
 	listenUDP          listenUDP
+	dscp               int
 	sourceIPSelectorFn func() (SourceIPSelector, error)
+	sourceFilter       *sourceFilter
 
 	enableMetrics bool
 	registerer    prometheus.Registerer
 
+	qlogWriter qlogWriterFunc
+
+	unclaimedHook       func(UnclaimedPacket)
+	unclaimedSampleRate float64
+
+	leakThreshold time.Duration
+	leakHook      func(TransportStat)
+
 	serverConfig *quic.Config
 	clientConfig *quic.Config
 
+	clientConfigOverridesMu sync.RWMutex
+	clientConfigOverrides   map[any]*quic.Config
+
 	quicListenersMu sync.Mutex
 	quicListeners   map[string]quicListenerEntry
 
@@ -68,6 +83,18 @@ type ConnManager struct {
 	connContext connContextFunc
 
 	verifySourceAddress func(addr net.Addr) bool
+
+	conns *connTracker
+
+	enableMigration bool
+	migrationFilter MigrationFilter
+
+	pathObserversMu sync.Mutex
+	pathObservers   map[*PathChangeObserver]struct{}
+	// stopObserver stops the goroutine calling the path change observers. It
+	// is only set while there are observers or a migration filter.
+	stopObserver func()
+	closed       bool
 }
 
 type quicListenerEntry struct {
@@ -92,19 +119,28 @@ const (
 // NewConnManager returns a new ConnManager
 func NewConnManager(statelessResetKey quic.StatelessResetKey, tokenKey quic.TokenGeneratorKey, opts ...Option) (*ConnManager, error) {
 	cm := &ConnManager{
-		enableReuseport:    true,
-		quicListeners:      make(map[string]quicListenerEntry),
-		srk:                statelessResetKey,
-		tokenKey:           tokenKey,
-		registerer:         prometheus.DefaultRegisterer,
-		listenUDP:          defaultListenUDP,
-		sourceIPSelectorFn: defaultSourceIPSelectorFn,
+		enableReuseport:       true,
+		quicListeners:         make(map[string]quicListenerEntry),
+		clientConfigOverrides: make(map[any]*quic.Config),
+		pathObservers:         make(map[*PathChangeObserver]struct{}),
+		srk:                   statelessResetKey,
+		tokenKey:              tokenKey,
+		registerer:            prometheus.DefaultRegisterer,
+		listenUDP:             defaultListenUDP,
+		sourceIPSelectorFn:    defaultSourceIPSelectorFn,
+		conns:                 newConnTracker(),
 	}
 	for _, o := range opts {
 		if err := o(cm); err != nil {
 			return nil, err
 		}
 	}
+	if cm.dscp != 0 {
+		cm.listenUDP = listenUDPWithDSCP(cm.listenUDP, cm.dscp)
+	}
+	if cm.qlogWriter == nil && qlogTracerDir != "" {
+		cm.qlogWriter = qlogDirWriter(qlogTracerDir)
+	}
 
 	quicConf := quicConfig.Clone()
 	quicConf.Tracer = cm.getTracer()
@@ -127,9 +163,32 @@ func NewConnManager(statelessResetKey quic.StatelessResetKey, tokenKey quic.Toke
 		}
 		return true
 	}
+	cm.connContext = cm.conns.refuseWhileDraining(cm.connContext)
 	if cm.enableReuseport {
 		cm.reuseUDP4 = newReuse(&statelessResetKey, &tokenKey, cm.listenUDP, cm.sourceIPSelectorFn, cm.connContext, cm.verifySourceAddress)
 		cm.reuseUDP6 = newReuse(&statelessResetKey, &tokenKey, cm.listenUDP, cm.sourceIPSelectorFn, cm.connContext, cm.verifySourceAddress)
+		cm.reuseUDP4.dialBalancing = cm.dialBalancing
+		cm.reuseUDP6.dialBalancing = cm.dialBalancing
+		if cm.unclaimedHook != nil || cm.enableMetrics {
+			var reg prometheus.Registerer
+			if cm.enableMetrics {
+				reg = cm.registerer
+			}
+			unclaimed := newUnclaimedTracker(cm.unclaimedHook, cm.unclaimedSampleRate, reg)
+			cm.reuseUDP4.unclaimed = unclaimed
+			cm.reuseUDP6.unclaimed = unclaimed
+		}
+		if cm.leakThreshold > 0 {
+			var reg prometheus.Registerer
+			if cm.enableMetrics {
+				reg = cm.registerer
+			}
+			cm.reuseUDP4.setLeakDetector(newLeakDetector("udp4", cm.leakThreshold, cm.leakHook, reg))
+			cm.reuseUDP6.setLeakDetector(newLeakDetector("udp6", cm.leakThreshold, cm.leakHook, reg))
+		}
+	}
+	if cm.needsObserver() {
+		cm.stopObserver = cm.startObserver()
 	}
 	return cm, nil
 }
@@ -147,15 +206,18 @@ func (c *ConnManager) getTracer() func(context.Context, quiclogging.Perspective,
 				log.Error("invalid logging perspective: %s", p)
 			}
 		}
-		var tracer *quiclogging.ConnectionTracer
-		if qlogTracerDir != "" {
-			tracer = qloggerForDir(qlogTracerDir, p, ci)
-			if promTracer != nil {
-				tracer = quiclogging.NewMultiplexedConnectionTracer(promTracer,
-					tracer)
-			}
+		var qlogger *quiclogging.ConnectionTracer
+		if c.qlogWriter != nil {
+			qlogger = qloggerFor(c.qlogWriter, p, ci)
+		}
+		switch {
+		case qlogger == nil:
+			return promTracer
+		case promTracer == nil:
+			return qlogger
+		default:
+			return quiclogging.NewMultiplexedConnectionTracer(promTracer, qlogger)
 		}
-		return tracer
 	}
 }
 
@@ -173,6 +235,8 @@ func (c *ConnManager) getReuse(network string) (*reuse, error) {
 // LendTransport is an advanced method used to lend an existing QUICTransport
 // to the ConnManager. The ConnManager will close the returned channel when it
 // is done with the transport, so that the owner may safely close the transport.
+// Multiple transports can be lent for the same network if they are bound to
+// different ports. Use WithDialBalancing to spread dials over them.
 func (c *ConnManager) LendTransport(network string, tr QUICTransport, conn net.PacketConn) (<-chan struct{}, error) {
 	c.quicListenersMu.Lock()
 	defer c.quicListenersMu.Unlock()
@@ -186,10 +250,13 @@ func (c *ConnManager) LendTransport(network string, tr QUICTransport, conn net.P
 		return nil, errors.New("transport is nil")
 	}
 
+	now := time.Now()
 	refCountedTr := &refcountedTransport{
 		QUICTransport:    tr,
 		packetConn:       conn,
 		borrowDoneSignal: make(chan struct{}),
+		created:          now,
+		lastActive:       now,
 	}
 
 	var reuse *reuse
@@ -225,6 +292,10 @@ func (c *ConnManager) ListenQUICAndAssociate(association any, addr ma.Multiaddr,
 	c.quicListenersMu.Lock()
 	defer c.quicListenersMu.Unlock()
 
+	if c.conns.draining.Load() {
+		return nil, ErrDraining
+	}
+
 	key := laddr.String()
 	entry, ok := c.quicListeners[key]
 	if !ok {
@@ -232,7 +303,7 @@ func (c *ConnManager) ListenQUICAndAssociate(association any, addr ma.Multiaddr,
 		if err != nil {
 			return nil, err
 		}
-		ln, err := newQuicListener(tr, c.serverConfig)
+		ln, err := newQuicListener(tr, c.serverConfig, c.conns)
 		if err != nil {
 			return nil, err
 		}
@@ -289,12 +360,14 @@ func (c *ConnManager) SharedNonQUICPacketConn(_ string, laddr *net.UDPAddr) (net
 	t := entry.ln.transport
 	if t, ok := t.(*refcountedTransport); ok {
 		t.IncreaseCount()
+		t.nonQUICReaders.Add(1)
 		ctx, cancel := context.WithCancel(context.Background())
 		return &nonQUICPacketConn{
 			ctx:             ctx,
 			ctxCancel:       cancel,
 			owningTransport: t,
 			tr:              t.QUICTransport,
+			readers:         &t.nonQUICReaders,
 		}, nil
 	}
 	return nil, errors.New("expected to be able to share with a QUIC listener, but the QUIC listener is not using a refcountedTransport. `DisableReuseport` should not be set")
@@ -331,6 +404,7 @@ func WithAssociation(ctx context.Context, association any) context.Context {
 
 // DialQUIC dials `raddr`. Use `WithAssociation` to select a specific transport that was previously used for listening.
 // see the documentation for `ListenQUICAndAssociate` for details on associate.
+// The dial uses the client config set for the association using `SetClientConfig`, if any.
 // The priority order for reusing the transport is as follows:
 // - Listening transport with the same association
 // - Any other listening transport
@@ -346,7 +420,12 @@ func (c *ConnManager) DialQUIC(ctx context.Context, raddr ma.Multiaddr, tlsConf
 		return nil, err
 	}
 
-	quicConf := c.clientConfig.Clone()
+	if c.conns.draining.Load() {
+		return nil, ErrDraining
+	}
+
+	association := ctx.Value(associationKey{})
+	quicConf := c.ClientConfigForAssociation(association).Clone()
 	quicConf.AllowConnectionWindowIncrease = allowWindowIncrease
 
 	if v == quic.Version1 {
@@ -357,7 +436,6 @@ func (c *ConnManager) DialQUIC(ctx context.Context, raddr ma.Multiaddr, tlsConf
 	}
 
 	var tr RefCountedQUICTransport
-	association := ctx.Value(associationKey{})
 	tr, err = c.TransportWithAssociationForDial(association, netw, naddr)
 	if err != nil {
 		return nil, err
@@ -367,6 +445,7 @@ func (c *ConnManager) DialQUIC(ctx context.Context, raddr ma.Multiaddr, tlsConf
 		tr.DecreaseCount()
 		return nil, err
 	}
+	c.conns.add(conn)
 	return conn, nil
 }
 
@@ -380,7 +459,14 @@ func (c *ConnManager) TransportForDial(network string, raddr *net.UDPAddr) (RefC
 // TransportWithAssociationForDial returns a transport for dialing `raddr`.
 // If reuseport is enabled, it attempts to reuse the QUIC Transport previously used for listening with `ListenQuicAndAssociate`
 // with the same `association`. If it fails to do so, it uses any other previously used transport.
+// If the source address for `raddr` isn't allowed by WithAllowedInterfaces or WithDeniedSubnets, it returns
+// ErrSourceNotAllowed.
 func (c *ConnManager) TransportWithAssociationForDial(association any, network string, raddr *net.UDPAddr) (RefCountedQUICTransport, error) {
+	if c.sourceFilter != nil {
+		if err := c.checkDialSource(raddr); err != nil {
+			return nil, err
+		}
+	}
 	if c.enableReuseport {
 		reuse, err := c.getReuse(network)
 		if err != nil {
@@ -423,6 +509,14 @@ func (c *ConnManager) Protocols() []int {
 }
 
 func (c *ConnManager) Close() error {
+	c.pathObserversMu.Lock()
+	c.closed = true
+	stopObserver := c.stopObserver
+	c.stopObserver = nil
+	c.pathObserversMu.Unlock()
+	if stopObserver != nil {
+		stopObserver()
+	}
 	if !c.enableReuseport {
 		return nil
 	}
@@ -436,6 +530,43 @@ func (c *ConnManager) ClientConfig() *quic.Config {
 	return c.clientConfig
 }
 
+// SetClientConfig sets the quic.Config used by `DialQUIC` for dials with the
+// given association, see `WithAssociation`. This allows, for example, WebTransport
+// dials to use different timeouts than QUIC dials. If conf doesn't set a Tracer,
+// the ConnManager's tracer is used. Passing a nil conf removes the override.
+func (c *ConnManager) SetClientConfig(association any, conf *quic.Config) error {
+	if association == nil {
+		return errors.New("association must not be nil")
+	}
+	c.clientConfigOverridesMu.Lock()
+	defer c.clientConfigOverridesMu.Unlock()
+	if conf == nil {
+		delete(c.clientConfigOverrides, association)
+		return nil
+	}
+	conf = conf.Clone()
+	if conf.Tracer == nil {
+		conf.Tracer = c.clientConfig.Tracer
+	}
+	c.clientConfigOverrides[association] = conf
+	return nil
+}
+
+// ClientConfigForAssociation returns the quic.Config used for dials with the
+// given association. It returns the config set using `SetClientConfig`, or
+// `ClientConfig` if none was set.
+func (c *ConnManager) ClientConfigForAssociation(association any) *quic.Config {
+	if association == nil {
+		return c.clientConfig
+	}
+	c.clientConfigOverridesMu.RLock()
+	defer c.clientConfigOverridesMu.RUnlock()
+	if conf, ok := c.clientConfigOverrides[association]; ok {
+		return conf
+	}
+	return c.clientConfig
+}
+
 // wrappedQUICTransport wraps a `quic.Transport` to confirm to `QUICTransport`
 type wrappedQUICTransport struct {
 	*quic.Transport
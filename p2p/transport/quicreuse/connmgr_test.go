@@ -489,3 +489,208 @@ func TestConnContext(t *testing.T) {
 		})
 	}
 }
+
+func TestDrain(t *testing.T) {
+	for _, reuse := range []bool{true, false} {
+		t.Run(fmt.Sprintf("reuseport:%t", reuse), func(t *testing.T) {
+			var opts []Option
+			if !reuse {
+				opts = append(opts, DisableReuseport())
+			}
+			cm, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{}, opts...)
+			require.NoError(t, err)
+			defer cm.Close()
+
+			_, tlsConf := getTLSConfForProto(t, "proto")
+			ln, err := cm.ListenQUIC(ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1"), tlsConf, nil)
+			require.NoError(t, err)
+			defer ln.Close()
+
+			clientKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+			require.NoError(t, err)
+			clientIdentity, err := libp2ptls.NewIdentity(clientKey)
+			require.NoError(t, err)
+			clientTLSConf, _ := clientIdentity.ConfigForPeer("")
+			clientTLSConf.NextProtos = []string{"proto"}
+			cconn, err := net.ListenUDP("udp4", nil)
+			require.NoError(t, err)
+			defer cconn.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			clientConn, err := quic.Dial(ctx, cconn, ln.Addr(), clientTLSConf, nil)
+			require.NoError(t, err)
+			serverConn, err := ln.Accept(ctx)
+			require.NoError(t, err)
+			require.Equal(t, 1, cm.conns.numConns())
+
+			drainCtx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			require.ErrorIs(t, cm.Drain(drainCtx), context.DeadlineExceeded)
+
+			// Existing connections keep working.
+			require.NoError(t, serverConn.Context().Err())
+			str, err := clientConn.OpenStream()
+			require.NoError(t, err)
+			_, err = str.Write([]byte("foobar"))
+			require.NoError(t, err)
+
+			// New connections are refused.
+			_, err = connectWithProtocol(t, ln.Addr(), "proto")
+			require.ErrorContains(t, err, "CONNECTION_REFUSED")
+			_, err = cm.ListenQUIC(ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1"), tlsConf, nil)
+			require.ErrorIs(t, err, ErrDraining)
+			_, err = cm.DialQUIC(context.Background(), ma.StringCast(fmt.Sprintf("/ip4/127.0.0.1/udp/%d/quic-v1", ln.Addr().(*net.UDPAddr).Port)), clientTLSConf, nil)
+			require.ErrorIs(t, err, ErrDraining)
+
+			done := make(chan error, 1)
+			go func() { done <- cm.Drain(context.Background()) }()
+			select {
+			case <-done:
+				t.Fatal("drain returned before the connection was closed")
+			case <-time.After(50 * time.Millisecond):
+			}
+			clientConn.CloseWithError(0, "")
+			select {
+			case err := <-done:
+				require.NoError(t, err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("drain didn't return")
+			}
+			require.Zero(t, cm.conns.numConns())
+		})
+	}
+}
+
+func TestDrainWithoutConnections(t *testing.T) {
+	cm, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{})
+	require.NoError(t, err)
+	defer cm.Close()
+	require.NoError(t, cm.Drain(context.Background()))
+	_, tlsConf := getTLSConfForProto(t, "proto")
+	_, err = cm.ListenQUIC(ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1"), tlsConf, nil)
+	require.ErrorIs(t, err, ErrDraining)
+}
+
+func TestTransportStats(t *testing.T) {
+	server, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{})
+	require.NoError(t, err)
+	defer server.Close()
+	client, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{})
+	require.NoError(t, err)
+	defer client.Close()
+
+	require.Empty(t, server.TransportStats())
+
+	serverID, serverTLSConf := getTLSConfForProto(t, "proto")
+	assoc := "association"
+	ln, err := server.ListenQUICAndAssociate(assoc, ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1"), serverTLSConf, nil)
+	require.NoError(t, err)
+	defer ln.Close()
+
+	clientKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	clientIdentity, err := libp2ptls.NewIdentity(clientKey)
+	require.NoError(t, err)
+	clientTLSConf, _ := clientIdentity.ConfigForPeer(serverID)
+	clientTLSConf.NextProtos = []string{"proto"}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := client.DialQUIC(ctx, ln.Multiaddrs()[0], clientTLSConf, nil)
+	require.NoError(t, err)
+	defer conn.CloseWithError(0, "")
+	_, err = ln.Accept(ctx)
+	require.NoError(t, err)
+
+	stats := server.TransportStats()
+	require.Len(t, stats, 1)
+	s := stats[0]
+	require.Equal(t, "udp4", s.Network)
+	require.Equal(t, ln.Addr().String(), s.LocalAddr.String())
+	require.True(t, s.Listening)
+	require.False(t, s.Borrowed)
+	require.Equal(t, 1, s.RefCount)
+	require.Equal(t, 1, s.ActiveConns)
+	require.Equal(t, []any{assoc}, s.Associations)
+	require.NotZero(t, s.BytesSent)
+	require.NotZero(t, s.BytesReceived)
+
+	stats = client.TransportStats()
+	require.Len(t, stats, 1)
+	s = stats[0]
+	require.Equal(t, conn.LocalAddr().(*net.UDPAddr).Port, s.LocalAddr.(*net.UDPAddr).Port)
+	require.False(t, s.Listening)
+	require.Equal(t, 1, s.ActiveConns)
+	require.Empty(t, s.Associations)
+	require.NotZero(t, s.BytesSent)
+	require.NotZero(t, s.BytesReceived)
+
+	conn.CloseWithError(0, "")
+	require.Eventually(t, func() bool {
+		return client.TransportStats()[0].ActiveConns == 0 && server.TransportStats()[0].ActiveConns == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestTransportStatsReuseportDisabled(t *testing.T) {
+	cm, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{}, DisableReuseport())
+	require.NoError(t, err)
+	defer cm.Close()
+	_, tlsConf := getTLSConfForProto(t, "proto")
+	ln, err := cm.ListenQUIC(ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1"), tlsConf, nil)
+	require.NoError(t, err)
+	defer ln.Close()
+	require.Nil(t, cm.TransportStats())
+}
+
+func TestClientConfigForAssociation(t *testing.T) {
+	cm, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{})
+	require.NoError(t, err)
+	defer cm.Close()
+
+	assoc := "association"
+	require.Equal(t, cm.ClientConfig(), cm.ClientConfigForAssociation(assoc))
+	require.Error(t, cm.SetClientConfig(nil, &quic.Config{}))
+
+	conf := &quic.Config{MaxIdleTimeout: 200 * time.Millisecond}
+	require.NoError(t, cm.SetClientConfig(assoc, conf))
+	override := cm.ClientConfigForAssociation(assoc)
+	require.Equal(t, 200*time.Millisecond, override.MaxIdleTimeout)
+	require.NotNil(t, override.Tracer, "expected the ConnManager's tracer to be used")
+	require.Nil(t, conf.Tracer, "expected the passed config not to be modified")
+	require.Equal(t, cm.ClientConfig(), cm.ClientConfigForAssociation("other"))
+
+	serverID, serverTLSConf := getTLSConfForProto(t, "proto")
+	ln, err := cm.ListenQUIC(ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1"), serverTLSConf, nil)
+	require.NoError(t, err)
+	defer ln.Close()
+	clientKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	clientIdentity, err := libp2ptls.NewIdentity(clientKey)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dial := func(ctx context.Context) *quic.Conn {
+		t.Helper()
+		clientTLSConf, _ := clientIdentity.ConfigForPeer(serverID)
+		clientTLSConf.NextProtos = []string{"proto"}
+		conn, err := cm.DialQUIC(ctx, ln.Multiaddrs()[0], clientTLSConf, nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.CloseWithError(0, "") })
+		_, err = ln.Accept(ctx)
+		require.NoError(t, err)
+		return conn
+	}
+	// Dials with the association use the short idle timeout of the override.
+	conn := dial(WithAssociation(ctx, assoc))
+	select {
+	case <-conn.Context().Done():
+	case <-ctx.Done():
+		t.Fatal("expected the connection to time out")
+	}
+	conn = dial(ctx)
+	time.Sleep(500 * time.Millisecond)
+	require.NoError(t, conn.Context().Err())
+
+	require.NoError(t, cm.SetClientConfig(assoc, nil))
+	require.Equal(t, cm.ClientConfig(), cm.ClientConfigForAssociation(assoc))
+}
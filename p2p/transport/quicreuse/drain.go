@@ -0,0 +1,128 @@
+package quicreuse
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/quic-go/quic-go"
+)
+
+// ErrDraining is returned when listening or dialing on a ConnManager that is
+// draining.
+var ErrDraining = errors.New("quicreuse: connection manager is draining")
+
+// connTracker keeps track of the connections dialed and accepted by a
+// ConnManager, so that Drain can wait for them to close.
+type connTracker struct {
+	draining atomic.Bool
+
+	mx sync.Mutex
+	// conns maps the open connections to their remote address at the time
+	// they were added.
+	conns map[*quic.Conn]net.Addr
+	// idle is closed once all connections are closed. It's only created
+	// when someone waits for it.
+	idle chan struct{}
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{conns: make(map[*quic.Conn]net.Addr)}
+}
+
+// add tracks c until it is closed.
+func (t *connTracker) add(c *quic.Conn) {
+	t.mx.Lock()
+	t.conns[c] = c.RemoteAddr()
+	t.mx.Unlock()
+	context.AfterFunc(c.Context(), func() { t.remove(c) })
+}
+
+func (t *connTracker) remove(c *quic.Conn) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	delete(t.conns, c)
+	if len(t.conns) == 0 && t.idle != nil {
+		close(t.idle)
+		t.idle = nil
+	}
+}
+
+// list returns the open connections, along with their remote address at the
+// time they were added.
+func (t *connTracker) list() map[*quic.Conn]net.Addr {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return maps.Clone(t.conns)
+}
+
+// numConns returns the number of open connections.
+func (t *connTracker) numConns() int {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return len(t.conns)
+}
+
+// wait waits until all connections are closed, or ctx is done.
+func (t *connTracker) wait(ctx context.Context) error {
+	t.mx.Lock()
+	if len(t.conns) == 0 {
+		t.mx.Unlock()
+		return nil
+	}
+	if t.idle == nil {
+		t.idle = make(chan struct{})
+	}
+	idle := t.idle
+	t.mx.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// refuseWhileDraining wraps a ConnContext callback to reject new handshakes
+// once the ConnManager is draining. quic-go refuses these connections with a
+// CONNECTION_REFUSED error.
+func (t *connTracker) refuseWhileDraining(connContext connContextFunc) connContextFunc {
+	return func(ctx context.Context, info *quic.ClientInfo) (context.Context, error) {
+		if t.draining.Load() {
+			return ctx, ErrDraining
+		}
+		if connContext != nil {
+			return connContext(ctx, info)
+		}
+		return ctx, nil
+	}
+}
+
+// closeDraining closes a connection that completed its handshake after
+// draining started.
+func closeDraining(c *quic.Conn) {
+	c.CloseWithError(quic.ApplicationErrorCode(network.ConnShutdown), "draining")
+}
+
+// Drain prepares the ConnManager for a shutdown without interrupting
+// existing connections, e.g. for a zero-downtime restart where a new process
+// takes over the UDP sockets using LendTransport.
+//
+// Once Drain is called, the listeners stop accepting connections: new
+// handshakes are refused, and connections that were still handshaking are
+// closed once the handshake completes. New calls to ListenQUIC and DialQUIC
+// fail with ErrDraining. Drain then waits until all connections dialed with
+// DialQUIC or accepted by a listener are closed, or until ctx is done, in
+// which case it returns ctx.Err(). Drain doesn't close any connection,
+// listener or socket; close the ConnManager once it returns.
+//
+// A ConnManager can't be undrained.
+func (c *ConnManager) Drain(ctx context.Context) error {
+	c.conns.draining.Store(true)
+	return c.conns.wait(ctx)
+}
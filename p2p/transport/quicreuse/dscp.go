@@ -0,0 +1,42 @@
+package quicreuse
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// maxDSCP is the largest DSCP value, DSCP is a 6 bit field.
+const maxDSCP = 63
+
+// listenUDPWithDSCP wraps f to set the DSCP value on the UDP sockets it
+// creates.
+func listenUDPWithDSCP(f listenUDP, dscp int) listenUDP {
+	return func(network string, laddr *net.UDPAddr) (net.PacketConn, error) {
+		conn, err := f(network, laddr)
+		if err != nil {
+			return nil, err
+		}
+		if err := setDSCP(conn, network, dscp); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to set DSCP on %s socket: %w", network, err)
+		}
+		return conn, nil
+	}
+}
+
+// setDSCP sets the DSCP bits, the upper 6 bits of the IPv4 TOS field or the
+// IPv6 traffic class field, on conn.
+func setDSCP(conn net.PacketConn, network string, dscp int) error {
+	tos := dscp << 2
+	switch network {
+	case "udp4":
+		return ipv4.NewPacketConn(conn).SetTOS(tos)
+	case "udp6":
+		return ipv6.NewPacketConn(conn).SetTrafficClass(tos)
+	default:
+		return fmt.Errorf("unsupported network: %s", network)
+	}
+}
@@ -0,0 +1,70 @@
+package quicreuse
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+	"testing"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/quic-go/quic-go"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+func TestDSCP(t *testing.T) {
+	const dscp = 46 // Expedited Forwarding
+	for _, reuseport := range []bool{true, false} {
+		name := "reuseport"
+		if !reuseport {
+			name = "no reuseport"
+		}
+		t.Run(name, func(t *testing.T) {
+			var mx sync.Mutex
+			conns := make(map[string]net.PacketConn)
+			opts := []Option{
+				WithDSCP(dscp),
+				OverrideListenUDP(func(network string, laddr *net.UDPAddr) (net.PacketConn, error) {
+					conn, err := net.ListenUDP(network, laddr)
+					if err != nil {
+						return nil, err
+					}
+					mx.Lock()
+					conns[network] = conn
+					mx.Unlock()
+					return conn, nil
+				}),
+			}
+			if !reuseport {
+				opts = append(opts, DisableReuseport())
+			}
+			cm, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{}, opts...)
+			require.NoError(t, err)
+			defer cm.Close()
+
+			ln, err := cm.ListenQUIC(ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1"), &tls.Config{NextProtos: []string{"proto"}}, nil)
+			require.NoError(t, err)
+			defer ln.Close()
+			tos, err := ipv4.NewPacketConn(conns["udp4"]).TOS()
+			require.NoError(t, err)
+			require.Equal(t, dscp<<2, tos)
+
+			ln6, err := cm.ListenQUIC(ma.StringCast("/ip6/::1/udp/0/quic-v1"), &tls.Config{NextProtos: []string{"proto"}}, nil)
+			if err != nil {
+				t.Skipf("IPv6 not available: %s", err)
+			}
+			defer ln6.Close()
+			tc, err := ipv6.NewPacketConn(conns["udp6"]).TrafficClass()
+			require.NoError(t, err)
+			require.Equal(t, dscp<<2, tc)
+		})
+	}
+}
+
+func TestDSCPInvalid(t *testing.T) {
+	for _, v := range []int{-1, 64} {
+		_, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{}, WithDSCP(v))
+		require.Error(t, err)
+	}
+}
@@ -0,0 +1,180 @@
+package quicreuse
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/p2p/metricshelper"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var suspectedLeaksTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "quicreuse",
+		Name:      "suspected_transport_leaks_total",
+		Help:      "Transports with a nonzero refcount, but no listeners, connections or recent dials",
+	},
+	[]string{"network"},
+)
+
+// AssociationStat describes the QUIC transports associated with a value
+// using ListenQUICAndAssociate.
+type AssociationStat struct {
+	// Association is the associated value. It is nil for the transports
+	// without any association.
+	Association any
+	// Transports is the number of transports with the association.
+	Transports int
+	// RefCount is the sum of the refcounts of these transports.
+	RefCount int
+	// OldestCreated is the time the oldest of these transports was created.
+	OldestCreated time.Time
+}
+
+// AssociationStats returns statistics about the QUIC transports per
+// association. A transport with several associations is counted for each of
+// them. It returns nil if reuseport is disabled.
+func (c *ConnManager) AssociationStats() []AssociationStat {
+	var stats []AssociationStat
+	index := make(map[any]int)
+	add := func(a any, ts TransportStat) {
+		i, ok := index[a]
+		if !ok {
+			i = len(stats)
+			index[a] = i
+			stats = append(stats, AssociationStat{Association: a, OldestCreated: ts.Created})
+		}
+		s := &stats[i]
+		s.Transports++
+		s.RefCount += ts.RefCount
+		if ts.Created.Before(s.OldestCreated) {
+			s.OldestCreated = ts.Created
+		}
+	}
+	for _, ts := range c.TransportStats() {
+		if len(ts.Associations) == 0 {
+			add(nil, ts)
+		}
+		for _, a := range ts.Associations {
+			add(a, ts)
+		}
+	}
+	return stats
+}
+
+// WithLeakDetection reports transports that are likely leaked: transports
+// with a nonzero refcount, but without listeners, open connections or
+// non-QUIC readers, and that weren't used for at least threshold. These are
+// usually transports returned by TransportForDial whose DecreaseCount was
+// never called. Their sockets are never closed.
+//
+// Suspected leaks are logged, counted if metrics are enabled, and passed to
+// hook, if it is not nil. Each transport is reported once, unless it's used
+// again. Transports are checked every 30 seconds, and only if reuseport is
+// enabled.
+func WithLeakDetection(threshold time.Duration, hook func(TransportStat)) Option {
+	return func(m *ConnManager) error {
+		if threshold <= 0 {
+			return errors.New("leak detection threshold must be positive")
+		}
+		m.leakThreshold = threshold
+		m.leakHook = hook
+		return nil
+	}
+}
+
+// leakDetector reports the transports of a reuse that are likely leaked.
+type leakDetector struct {
+	network   string
+	threshold time.Duration
+	hook      func(TransportStat)
+	metrics   bool
+}
+
+func newLeakDetector(network string, threshold time.Duration, hook func(TransportStat), reg prometheus.Registerer) *leakDetector {
+	d := &leakDetector{network: network, threshold: threshold, hook: hook, metrics: reg != nil}
+	if reg != nil {
+		metricshelper.RegisterCollectors(reg, suspectedLeaksTotal)
+	}
+	return d
+}
+
+// check returns the stat of tr if it's likely leaked and wasn't reported since
+// it was last used.
+func (d *leakDetector) check(tr *refcountedTransport, listening bool, now time.Time) (TransportStat, bool) {
+	tr.mutex.Lock()
+	if tr.leakReported || !tr.suspectedLeakLocked(now, d.threshold) {
+		tr.mutex.Unlock()
+		return TransportStat{}, false
+	}
+	tr.leakReported = true
+	tr.mutex.Unlock()
+	return tr.stat(d.network, listening, d.threshold), true
+}
+
+func (d *leakDetector) report(s TransportStat) {
+	log.Warnw("QUIC transport refcount likely leaked", "network", d.network, "addr", s.LocalAddr,
+		"refcount", s.RefCount, "age", time.Since(s.Created), "associations", fmt.Sprint(s.Associations))
+	if d.metrics {
+		suspectedLeaksTotal.WithLabelValues(d.network).Inc()
+	}
+	if d.hook != nil {
+		d.hook(s)
+	}
+}
+
+// setLeakDetector enables leak detection. The gc goroutine checks for leaks
+// on every run.
+func (r *reuse) setLeakDetector(d *leakDetector) {
+	r.mutex.Lock()
+	r.leaks = d
+	r.mutex.Unlock()
+}
+
+// checkLeaks reports the transports that are likely leaked.
+func (r *reuse) checkLeaks(now time.Time) {
+	var leaked []TransportStat
+	check := func(tr *refcountedTransport, listening bool) {
+		if s, ok := r.leaks.check(tr, listening, now); ok {
+			leaked = append(leaked, s)
+		}
+	}
+	r.mutex.Lock()
+	for _, tr := range r.globalListeners {
+		check(tr, true)
+	}
+	for _, trs := range r.unicast {
+		for _, tr := range trs {
+			check(tr, true)
+		}
+	}
+	for _, tr := range r.globalDialers {
+		check(tr, false)
+	}
+	r.mutex.Unlock()
+
+	for _, s := range leaked {
+		r.leaks.report(s)
+	}
+}
+
+// suspectedLeakLocked returns whether the transport is likely leaked, see
+// WithLeakDetection. It must be called with the mutex held.
+func (c *refcountedTransport) suspectedLeakLocked(now time.Time, threshold time.Duration) bool {
+	return threshold > 0 && c.refCount > 0 &&
+		c.listeners.Load() == 0 && c.activeConns.Load() == 0 && c.nonQUICReaders.Load() == 0 &&
+		now.Sub(c.lastActive) >= threshold
+}
+
+// touch records that the transport was used.
+func (c *refcountedTransport) touch() {
+	c.mutex.Lock()
+	c.touchLocked()
+	c.mutex.Unlock()
+}
+
+func (c *refcountedTransport) touchLocked() {
+	c.lastActive = time.Now()
+	c.leakReported = false
+}
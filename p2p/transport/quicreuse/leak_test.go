@@ -0,0 +1,89 @@
+package quicreuse
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/quic-go/quic-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeakDetection(t *testing.T) {
+	defer func(d time.Duration) { garbageCollectInterval = d }(garbageCollectInterval)
+	garbageCollectInterval = 20 * time.Millisecond
+	const threshold = 100 * time.Millisecond
+
+	leaked := make(chan TransportStat, 10)
+	cm, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{},
+		WithLeakDetection(threshold, func(s TransportStat) { leaked <- s }))
+	require.NoError(t, err)
+	defer cm.Close()
+
+	// a transport with a listener isn't leaked
+	ln, err := cm.ListenQUIC(ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1"), &tls.Config{NextProtos: []string{"proto"}}, nil)
+	require.NoError(t, err)
+	defer ln.Close()
+	// but a dial transport that was never released is
+	tr, err := cm.TransportForDial("udp4", &net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 1234})
+	require.NoError(t, err)
+
+	var s TransportStat
+	select {
+	case s = <-leaked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("leak not reported")
+	}
+	require.Equal(t, tr.LocalAddr().String(), s.LocalAddr.String())
+	require.True(t, s.SuspectedLeak)
+	require.Equal(t, 1, s.RefCount)
+	require.Zero(t, s.Listeners)
+	require.GreaterOrEqual(t, time.Since(s.LastActive), threshold)
+
+	for _, s := range cm.TransportStats() {
+		require.Equal(t, s.LocalAddr.String() == tr.LocalAddr().String(), s.SuspectedLeak, s.LocalAddr)
+		if s.LocalAddr.String() == ln.Addr().String() {
+			require.Equal(t, 1, s.Listeners)
+		}
+	}
+
+	// leaks are reported once
+	time.Sleep(3 * threshold)
+	require.Empty(t, leaked)
+
+	// releasing the transport resolves the leak
+	tr.DecreaseCount()
+	for _, s := range cm.TransportStats() {
+		require.False(t, s.SuspectedLeak)
+	}
+}
+
+func TestLeakDetectionOption(t *testing.T) {
+	_, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{}, WithLeakDetection(0, nil))
+	require.Error(t, err)
+}
+
+func TestAssociationStats(t *testing.T) {
+	cm, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{})
+	require.NoError(t, err)
+	defer cm.Close()
+
+	start := time.Now()
+	ln, err := cm.ListenQUICAndAssociate("foo", ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1"), &tls.Config{NextProtos: []string{"proto"}}, nil)
+	require.NoError(t, err)
+	defer ln.Close()
+	tr, err := cm.TransportForDial("udp6", &net.UDPAddr{IP: net.IPv6loopback, Port: 1234})
+	require.NoError(t, err)
+	defer tr.DecreaseCount()
+
+	stats := cm.AssociationStats()
+	require.Len(t, stats, 2)
+	for _, s := range stats {
+		require.Equal(t, 1, s.Transports)
+		require.Equal(t, 1, s.RefCount)
+		require.False(t, s.OldestCreated.Before(start))
+		require.Contains(t, []any{"foo", nil}, s.Association)
+	}
+}
@@ -34,12 +34,13 @@ type quicListener struct {
 	transport RefCountedQUICTransport
 	running   chan struct{}
 	addrs     []ma.Multiaddr
+	conns     *connTracker
 
 	protocolsMu sync.Mutex
 	protocols   map[string]protoConf
 }
 
-func newQuicListener(tr RefCountedQUICTransport, quicConfig *quic.Config) (*quicListener, error) {
+func newQuicListener(tr RefCountedQUICTransport, quicConfig *quic.Config, conns *connTracker) (*quicListener, error) {
 	localMultiaddrs := make([]ma.Multiaddr, 0, 2)
 	a, err := ToQuicMultiaddr(tr.LocalAddr(), quic.Version1)
 	if err != nil {
@@ -51,6 +52,7 @@ func newQuicListener(tr RefCountedQUICTransport, quicConfig *quic.Config) (*quic
 		running:   make(chan struct{}),
 		transport: tr,
 		addrs:     localMultiaddrs,
+		conns:     conns,
 	}
 	tlsConf := &tls.Config{
 		SessionTicketsDisabled: true, // This is set for the config for client, but we set it here as well: https://github.com/quic-go/quic-go/issues/4029
@@ -134,6 +136,11 @@ func (l *quicListener) Run() error {
 			}
 			return err
 		}
+		if l.conns.draining.Load() {
+			closeDraining(conn)
+			continue
+		}
+		l.conns.add(conn)
 		proto := conn.ConnectionState().TLS.NegotiatedProtocol
 
 		l.protocolsMu.Lock()
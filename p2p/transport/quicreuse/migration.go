@@ -0,0 +1,219 @@
+package quicreuse
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+
+	"github.com/quic-go/quic-go"
+)
+
+// ErrMigrationDisabled is returned by MigrateConn if connection migration
+// wasn't enabled using the EnableConnectionMigration option.
+var ErrMigrationDisabled = errors.New("quicreuse: connection migration is disabled")
+
+// pathCheckInterval is the interval at which connections are checked for path
+// changes if a PathChangeObserver is set.
+var pathCheckInterval = time.Second
+
+// PathChangeObserver is called when the remote address of a connection
+// changes, i.e. when the peer migrated the connection to a new path, for
+// example because its NAT mapping changed or it roamed to a different network.
+type PathChangeObserver func(conn *quic.Conn, oldRemote, newRemote net.Addr)
+
+// MigrationFilter is called when a peer migrates a connection to a new remote
+// address. Returning false refuses the migration.
+type MigrationFilter func(conn *quic.Conn, oldRemote, newRemote net.Addr) bool
+
+// EnableConnectionMigration allows migrating dialed connections to a new local
+// socket using MigrateConn. Accepted connections follow the migrations of the
+// peer regardless of this option.
+func EnableConnectionMigration() Option {
+	return func(m *ConnManager) error {
+		m.enableMigration = true
+		return nil
+	}
+}
+
+// WithPathChangeObserver sets a callback that is called when a peer migrates a
+// connection dialed or accepted by the ConnManager to a new remote address.
+// Path changes are detected by periodically checking the remote addresses of
+// all connections, so the callback is called with a delay of up to a second.
+func WithPathChangeObserver(f PathChangeObserver) Option {
+	return func(m *ConnManager) error {
+		if f == nil {
+			return errors.New("path change observer is nil")
+		}
+		m.pathObservers[&f] = struct{}{}
+		return nil
+	}
+}
+
+// WithMigrationFilter sets a filter deciding whether to accept the migration
+// of a connection to a new remote address by the peer. QUIC switches to a new
+// path once it's validated, so a refused migration closes the connection.
+// Path changes are detected by periodically checking the remote addresses of
+// all connections, so the peer may use the new path for up to a second before
+// the connection is closed.
+func WithMigrationFilter(f MigrationFilter) Option {
+	return func(m *ConnManager) error {
+		if f == nil {
+			return errors.New("migration filter is nil")
+		}
+		m.migrationFilter = f
+		return nil
+	}
+}
+
+// AddPathChangeObserver registers f to be called when a peer migrates a
+// connection dialed or accepted by the ConnManager to a new remote address, in
+// addition to the observer set using WithPathChangeObserver. The returned
+// function removes the observer.
+func (c *ConnManager) AddPathChangeObserver(f PathChangeObserver) (remove func()) {
+	o := &f
+	c.pathObserversMu.Lock()
+	c.pathObservers[o] = struct{}{}
+	if c.stopObserver == nil && !c.closed {
+		c.stopObserver = c.startObserver()
+	}
+	c.pathObserversMu.Unlock()
+	return func() {
+		c.pathObserversMu.Lock()
+		delete(c.pathObservers, o)
+		var stopObserver func()
+		if !c.needsObserver() {
+			stopObserver = c.stopObserver
+			c.stopObserver = nil
+		}
+		c.pathObserversMu.Unlock()
+		if stopObserver != nil {
+			stopObserver()
+		}
+	}
+}
+
+// needsObserver returns true if connections need to be checked for path
+// changes. It must be called with pathObserversMu held, or before the
+// ConnManager is used.
+func (c *ConnManager) needsObserver() bool {
+	return len(c.pathObservers) > 0 || c.migrationFilter != nil
+}
+
+// startObserver starts checking connections for path changes. The returned
+// function stops checking, and waits for the goroutine to return.
+func (c *ConnManager) startObserver() (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.observePaths(ctx)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func (c *ConnManager) notifyPathChange(conn *quic.Conn, oldRemote, newRemote net.Addr) {
+	c.pathObserversMu.Lock()
+	observers := make([]PathChangeObserver, 0, len(c.pathObservers))
+	for o := range c.pathObservers {
+		observers = append(observers, *o)
+	}
+	c.pathObserversMu.Unlock()
+	for _, o := range observers {
+		o(conn, oldRemote, newRemote)
+	}
+}
+
+// MigrateConn migrates a dialed connection to a new UDP socket, e.g. after the
+// network interface the connection was using went away. The new path is probed
+// before switching to it. The socket is closed when the connection is closed.
+func (c *ConnManager) MigrateConn(ctx context.Context, conn *quic.Conn) error {
+	if !c.enableMigration {
+		return ErrMigrationDisabled
+	}
+	local, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return errors.New("expected a *net.UDPAddr local address")
+	}
+	network, laddr := "udp4", &net.UDPAddr{IP: net.IPv4zero}
+	if local.IP.To4() == nil {
+		network, laddr = "udp6", &net.UDPAddr{IP: net.IPv6zero}
+	}
+	pconn, err := c.listenUDP(network, laddr)
+	if err != nil {
+		return err
+	}
+	tr := newQUICTransport(pconn, &c.tokenKey, &c.srk, c.connContext, c.verifySourceAddress)
+	closeTransport := func() {
+		tr.Close()
+		pconn.Close()
+	}
+	path, err := conn.AddPath(tr)
+	if err != nil {
+		closeTransport()
+		return err
+	}
+	if err := path.Probe(ctx); err != nil {
+		path.Close()
+		closeTransport()
+		return err
+	}
+	if err := path.Switch(); err != nil {
+		path.Close()
+		closeTransport()
+		return err
+	}
+	context.AfterFunc(conn.Context(), closeTransport)
+	return nil
+}
+
+// observePaths reports changes of the connections' remote addresses to the
+// path change observers until ctx is done.
+func (c *ConnManager) observePaths(ctx context.Context) {
+	t := time.NewTicker(pathCheckInterval)
+	defer t.Stop()
+
+	remoteAddrs := make(map[*quic.Conn]net.Addr)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		conns := c.conns.list()
+		current := make(map[*quic.Conn]net.Addr, len(conns))
+		for conn, initial := range conns {
+			old, ok := remoteAddrs[conn]
+			if !ok {
+				old = initial
+			}
+			addr := conn.RemoteAddr()
+			if !SameAddr(old, addr) {
+				if c.migrationFilter != nil && !c.migrationFilter(conn, old, addr) {
+					log.Debugw("refusing connection migration", "old", old, "new", addr)
+					conn.CloseWithError(quic.ApplicationErrorCode(network.ConnGated), "migration refused")
+					continue
+				}
+				c.notifyPathChange(conn, old, addr)
+			}
+			current[conn] = addr
+		}
+		remoteAddrs = current
+	}
+}
+
+// SameAddr reports whether a and b are the same address. UDP addresses are
+// equal if they have the same IP, port and zone.
+func SameAddr(a, b net.Addr) bool {
+	ua, ok1 := a.(*net.UDPAddr)
+	ub, ok2 := b.(*net.UDPAddr)
+	if ok1 && ok2 {
+		return ua == ub || (ua.IP.Equal(ub.IP) && ua.Port == ub.Port && ua.Zone == ub.Zone)
+	}
+	return a != nil && b != nil && a.String() == b.String()
+}
@@ -0,0 +1,145 @@
+package quicreuse
+
+import (
+	"context"
+	"crypto/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	libp2ptls "github.com/libp2p/go-libp2p/p2p/security/tls"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/quic-go/quic-go"
+	"github.com/stretchr/testify/require"
+)
+
+type pathChange struct {
+	conn                 *quic.Conn
+	oldRemote, newRemote net.Addr
+}
+
+func TestConnectionMigration(t *testing.T) {
+	defer func(d time.Duration) { pathCheckInterval = d }(pathCheckInterval)
+	pathCheckInterval = 10 * time.Millisecond
+
+	changes := make(chan pathChange, 10)
+	server, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{},
+		WithPathChangeObserver(func(conn *quic.Conn, oldRemote, newRemote net.Addr) {
+			changes <- pathChange{conn: conn, oldRemote: oldRemote, newRemote: newRemote}
+		}))
+	require.NoError(t, err)
+	defer server.Close()
+	client, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{}, EnableConnectionMigration())
+	require.NoError(t, err)
+	defer client.Close()
+
+	serverID, serverTLSConf := getTLSConfForProto(t, "proto")
+	ln, err := server.ListenQUIC(ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1"), serverTLSConf, nil)
+	require.NoError(t, err)
+	defer ln.Close()
+
+	clientKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	clientIdentity, err := libp2ptls.NewIdentity(clientKey)
+	require.NoError(t, err)
+	clientTLSConf, _ := clientIdentity.ConfigForPeer(serverID)
+	clientTLSConf.NextProtos = []string{"proto"}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := client.DialQUIC(ctx, ln.Multiaddrs()[0], clientTLSConf, nil)
+	require.NoError(t, err)
+	defer conn.CloseWithError(0, "")
+	sconn, err := ln.Accept(ctx)
+	require.NoError(t, err)
+
+	oldLocal := conn.LocalAddr()
+	require.NoError(t, client.MigrateConn(ctx, conn))
+
+	// The server switches to the new path once it validated it, and received
+	// data on it.
+	str, err := conn.OpenStream()
+	require.NoError(t, err)
+	for {
+		_, err = str.Write([]byte("foobar"))
+		require.NoError(t, err)
+		select {
+		case c := <-changes:
+			require.Equal(t, sconn, c.conn)
+			require.Equal(t, oldLocal.(*net.UDPAddr).Port, c.oldRemote.(*net.UDPAddr).Port)
+			require.NotEqual(t, oldLocal.(*net.UDPAddr).Port, c.newRemote.(*net.UDPAddr).Port)
+			return
+		case <-time.After(50 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("expected a path change")
+		}
+	}
+}
+
+func TestConnectionMigrationDisabled(t *testing.T) {
+	cm, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{})
+	require.NoError(t, err)
+	defer cm.Close()
+	require.ErrorIs(t, cm.MigrateConn(context.Background(), nil), ErrMigrationDisabled)
+}
+
+func TestMigrationFilter(t *testing.T) {
+	defer func(d time.Duration) { pathCheckInterval = d }(pathCheckInterval)
+	pathCheckInterval = 10 * time.Millisecond
+
+	_, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{}, WithMigrationFilter(nil))
+	require.Error(t, err)
+
+	refused := make(chan pathChange, 10)
+	server, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{},
+		WithMigrationFilter(func(conn *quic.Conn, oldRemote, newRemote net.Addr) bool {
+			refused <- pathChange{conn: conn, oldRemote: oldRemote, newRemote: newRemote}
+			return false
+		}))
+	require.NoError(t, err)
+	defer server.Close()
+	client, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{}, EnableConnectionMigration())
+	require.NoError(t, err)
+	defer client.Close()
+
+	serverID, serverTLSConf := getTLSConfForProto(t, "proto")
+	ln, err := server.ListenQUIC(ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1"), serverTLSConf, nil)
+	require.NoError(t, err)
+	defer ln.Close()
+
+	clientKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	clientIdentity, err := libp2ptls.NewIdentity(clientKey)
+	require.NoError(t, err)
+	clientTLSConf, _ := clientIdentity.ConfigForPeer(serverID)
+	clientTLSConf.NextProtos = []string{"proto"}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := client.DialQUIC(ctx, ln.Multiaddrs()[0], clientTLSConf, nil)
+	require.NoError(t, err)
+	defer conn.CloseWithError(0, "")
+	sconn, err := ln.Accept(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, client.MigrateConn(ctx, conn))
+	str, err := conn.OpenStream()
+	require.NoError(t, err)
+	for {
+		_, err = str.Write([]byte("foobar"))
+		require.NoError(t, err)
+		select {
+		case c := <-refused:
+			require.Equal(t, sconn, c.conn)
+			select {
+			case <-sconn.Context().Done():
+			case <-ctx.Done():
+				t.Fatal("expected the connection to be closed")
+			}
+			return
+		case <-time.After(50 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("expected a path change")
+		}
+	}
+}
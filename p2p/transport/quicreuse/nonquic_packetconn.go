@@ -3,6 +3,8 @@ package quicreuse
 import (
 	"context"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,11 +18,15 @@ type nonQUICPacketConn struct {
 	ctxCancel       context.CancelFunc
 	readCtx         context.Context
 	readCancel      context.CancelFunc
+	// readers counts the open nonQUICPacketConns of the transport.
+	readers   *atomic.Int32
+	closeOnce sync.Once
 }
 
 // Close implements net.PacketConn.
 func (n *nonQUICPacketConn) Close() error {
 	n.ctxCancel()
+	n.closeOnce.Do(func() { n.readers.Add(-1) })
 
 	// Don't actually close the underlying transport since someone else might be using it.
 	// reuse has it's own gc to close unused transports.
@@ -3,10 +3,13 @@ package quicreuse
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"net"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/logging"
 )
 
 type Option func(*ConnManager) error
@@ -34,6 +37,20 @@ func DisableReuseport() Option {
 	}
 }
 
+// WithDSCP sets the DSCP value of the UDP sockets used by the ConnManager, so that
+// QUIC traffic can be prioritized in QoS-managed networks. It sets the TOS field of
+// IPv4 sockets and the traffic class field of IPv6 sockets. Sockets lent to the
+// ConnManager using LendTransport are not changed.
+func WithDSCP(value int) Option {
+	return func(m *ConnManager) error {
+		if value < 0 || value > maxDSCP {
+			return fmt.Errorf("invalid DSCP value %d: must be between 0 and %d", value, maxDSCP)
+		}
+		m.dscp = value
+		return nil
+	}
+}
+
 // ConnContext sets the context for all connections accepted by listeners. This doesn't affect the
 // context for dialed connections. To reject a connection, return a non nil error.
 func ConnContext(f func(ctx context.Context, clientInfo *quic.ClientInfo) (context.Context, error)) Option {
@@ -66,3 +83,18 @@ func EnableMetrics(reg prometheus.Registerer) Option {
 		return nil
 	}
 }
+
+// WithQlogWriter sets the writer qlogs of all connections are written to. This
+// allows streaming qlogs to a remote storage, or compressing them on the fly.
+// The writer is closed when the connection is closed. If w returns nil, no
+// qlog is written for the connection. It overrides the QLOGDIR environment
+// variable.
+func WithQlogWriter(w func(p logging.Perspective, connID quic.ConnectionID) io.WriteCloser) Option {
+	return func(m *ConnManager) error {
+		if w == nil {
+			return errors.New("qlog writer is nil")
+		}
+		m.qlogWriter = w
+		return nil
+	}
+}
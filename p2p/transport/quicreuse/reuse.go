@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/gopacket/routing"
@@ -89,6 +90,20 @@ type refcountedTransport struct {
 	borrowDoneSignal chan struct{}
 
 	assocations map[any]struct{}
+
+	activeConns atomic.Int64
+	// nonQUICReaders is the number of open non-QUIC PacketConns sharing the
+	// transport.
+	nonQUICReaders atomic.Int32
+	// listeners is the number of open QUIC listeners on the transport.
+	listeners atomic.Int32
+
+	created time.Time
+	// lastActive is the last time the transport was used, see touch.
+	lastActive time.Time
+	// leakReported is set once the transport was reported as leaked, until
+	// it's used again.
+	leakReported bool
 }
 
 type connContextFunc = func(context.Context, *quic.ClientInfo) (context.Context, error)
@@ -125,6 +140,7 @@ func (c *refcountedTransport) IncreaseCount() {
 	c.mutex.Lock()
 	c.refCount++
 	c.unusedSince = time.Time{}
+	c.touchLocked()
 	c.mutex.Unlock()
 }
 
@@ -145,8 +161,23 @@ func (c *refcountedTransport) LocalAddr() net.Addr {
 	return c.packetConn.LocalAddr()
 }
 
+func (c *refcountedTransport) Dial(ctx context.Context, addr net.Addr, tlsConf *tls.Config, conf *quic.Config) (*quic.Conn, error) {
+	conn, err := c.QUICTransport.Dial(ctx, addr, tlsConf, conf)
+	if err != nil {
+		return nil, err
+	}
+	c.trackConn(conn)
+	return conn, nil
+}
+
 func (c *refcountedTransport) Listen(tlsConf *tls.Config, conf *quic.Config) (QUICListener, error) {
-	return c.QUICTransport.Listen(tlsConf, conf)
+	ln, err := c.QUICTransport.Listen(tlsConf, conf)
+	if err != nil {
+		return nil, err
+	}
+	c.listeners.Add(1)
+	c.touch()
+	return &trackingListener{QUICListener: ln, tr: c}, nil
 }
 
 func (c *refcountedTransport) DecreaseCount() {
@@ -155,6 +186,7 @@ func (c *refcountedTransport) DecreaseCount() {
 	if c.refCount == 0 {
 		c.unusedSince = time.Now()
 	}
+	c.touchLocked()
 	c.mutex.Unlock()
 }
 
@@ -187,6 +219,17 @@ type reuse struct {
 	tokenGeneratorKey   *quic.TokenGeneratorKey
 	connContext         connContextFunc
 	verifySourceAddress func(addr net.Addr) bool
+
+	dialBalancing DialBalancing
+	// nextDial is used for DialBalancingRoundRobin.
+	nextDial uint64
+
+	// unclaimed reports packets no one claimed. It is nil if neither the
+	// unclaimed packet hook nor metrics are enabled.
+	unclaimed *unclaimedTracker
+	// leaks reports leaked transports. It is nil unless leak detection is
+	// enabled.
+	leaks *leakDetector
 }
 
 func newReuse(srk *quic.StatelessResetKey, tokenKey *quic.TokenGeneratorKey, listenUDP listenUDP, sourceIPSelectorFn func() (SourceIPSelector, error),
@@ -267,7 +310,11 @@ func (r *reuse) gc() {
 					}
 				}
 			}
+			leaks := r.leaks
 			r.mutex.Unlock()
+			if leaks != nil {
+				r.checkLeaks(now)
+			}
 		}
 	}
 }
@@ -303,35 +350,21 @@ func (r *reuse) TransportWithAssociationForDial(association any, network string,
 func (r *reuse) transportForDialLocked(association any, network string, source *net.IP) (*refcountedTransport, error) {
 	if source != nil {
 		// We already have at least one suitable transport...
-		if trs, ok := r.unicast[source.String()]; ok {
-			// Prefer a transport that has the given association. We want to
-			// reuse the transport the association used for listening.
-			for _, tr := range trs {
-				if tr.hasAssociation(association) {
-					return tr, nil
-				}
-			}
-			// We don't have a transport with the association, use any one
-			for _, tr := range trs {
-				return tr, nil
-			}
+		// Prefer a transport that has the given association. We want to
+		// reuse the transport the association used for listening.
+		if tr := r.selectTransportLocked(r.unicast[source.String()], association); tr != nil {
+			return tr, nil
 		}
 	}
 
 	// Use a transport listening on 0.0.0.0 (or ::).
 	// Again, prefer a transport that has the given association.
-	for _, tr := range r.globalListeners {
-		if tr.hasAssociation(association) {
-			return tr, nil
-		}
-	}
-	// We don't have a transport with the association, use any one
-	for _, tr := range r.globalListeners {
+	if tr := r.selectTransportLocked(r.globalListeners, association); tr != nil {
 		return tr, nil
 	}
 
 	// Use a transport we've previously dialed from
-	for _, tr := range r.globalDialers {
+	if tr := r.selectTransportLocked(r.globalDialers, nil); tr != nil {
 		return tr, nil
 	}
 
@@ -458,19 +491,27 @@ func (r *reuse) TransportForListen(network string, laddr *net.UDPAddr) (*refcoun
 	return tr, nil
 }
 
-func (r *reuse) newTransport(conn net.PacketConn) *refcountedTransport {
-	return &refcountedTransport{
-		QUICTransport: &wrappedQUICTransport{
-			Transport: newQUICTransport(
-				conn,
-				r.tokenGeneratorKey,
-				r.statelessResetKey,
-				r.connContext,
-				r.verifySourceAddress,
-			),
-		},
-		packetConn: conn,
+func (r *reuse) newTransport(pconn net.PacketConn) *refcountedTransport {
+	now := time.Now()
+	tr := &refcountedTransport{created: now, lastActive: now}
+	var inspect func([]byte, net.Addr)
+	if r.unclaimed != nil {
+		inspect = r.unclaimed.inspector(tr, pconn.LocalAddr())
+	}
+	conn := newCountingPacketConn(pconn, inspect)
+	qtr := newQUICTransport(
+		conn,
+		r.tokenGeneratorKey,
+		r.statelessResetKey,
+		r.connContext,
+		r.verifySourceAddress,
+	)
+	if r.unclaimed != nil {
+		qtr.Tracer = r.unclaimed.tracer(pconn.LocalAddr())
 	}
+	tr.QUICTransport = &wrappedQUICTransport{Transport: qtr}
+	tr.packetConn = conn
+	return tr
 }
 
 func (r *reuse) Close() error {
@@ -238,3 +238,51 @@ func TestReuseGarbageCollect(t *testing.T) {
 	}
 	require.Eventually(t, func() bool { return numGlobals() == 0 }, 4*garbageCollectInterval, 10*time.Millisecond)
 }
+
+func TestReuseDialBalancing(t *testing.T) {
+	setup := func(t *testing.T, b DialBalancing) (*reuse, []*refcountedTransport) {
+		reuse := newReuse(nil, nil, defaultListenUDP, defaultSourceIPSelectorFn, nil, nil)
+		reuse.dialBalancing = b
+		cleanup(t, reuse)
+		var trs []*refcountedTransport
+		for range 3 {
+			conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+			require.NoError(t, err)
+			tr := reuse.newTransport(conn)
+			t.Cleanup(func() { tr.Close() })
+			require.NoError(t, reuse.AddTransport(tr, conn.LocalAddr().(*net.UDPAddr)))
+			trs = append(trs, tr)
+		}
+		return reuse, trs
+	}
+	raddr, err := net.ResolveUDPAddr("udp4", "1.1.1.1:1234")
+	require.NoError(t, err)
+
+	t.Run("round robin", func(t *testing.T) {
+		reuse, trs := setup(t, DialBalancingRoundRobin)
+		used := make(map[*refcountedTransport]int)
+		for range 3 * len(trs) {
+			tr, err := reuse.TransportWithAssociationForDial(nil, "udp4", raddr)
+			require.NoError(t, err)
+			used[tr]++
+		}
+		require.Len(t, used, len(trs))
+		for _, tr := range trs {
+			require.Equal(t, 3, used[tr])
+		}
+	})
+
+	t.Run("least conns", func(t *testing.T) {
+		reuse, trs := setup(t, DialBalancingLeastConns)
+		trs[0].activeConns.Store(2)
+		trs[1].activeConns.Store(1)
+		trs[2].activeConns.Store(3)
+		tr, err := reuse.TransportWithAssociationForDial(nil, "udp4", raddr)
+		require.NoError(t, err)
+		require.Equal(t, trs[1], tr)
+		trs[1].activeConns.Store(5)
+		tr, err = reuse.TransportWithAssociationForDial(nil, "udp4", raddr)
+		require.NoError(t, err)
+		require.Equal(t, trs[0], tr)
+	})
+}
@@ -0,0 +1,111 @@
+package quicreuse
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+)
+
+// ErrSourceNotAllowed is returned when dialing an address would send packets
+// from a local address that isn't allowed by the WithAllowedInterfaces and
+// WithDeniedSubnets options.
+var ErrSourceNotAllowed = errors.New("quicreuse: source address not allowed")
+
+// sourceFilter restricts the local addresses that may be used for dialing.
+type sourceFilter struct {
+	allowedInterfaces []string
+	deniedSubnets     []netip.Prefix
+}
+
+// WithAllowedInterfaces restricts dialing to the network interfaces with the
+// given names. Before dialing, the ConnManager looks up the source address the
+// system would use to reach the remote address, and refuses the dial with
+// ErrSourceNotAllowed if that address isn't assigned to one of the interfaces.
+func WithAllowedInterfaces(names []string) Option {
+	return func(m *ConnManager) error {
+		if len(names) == 0 {
+			return errors.New("no allowed interfaces")
+		}
+		if m.sourceFilter == nil {
+			m.sourceFilter = &sourceFilter{}
+		}
+		m.sourceFilter.allowedInterfaces = slices.Clone(names)
+		return nil
+	}
+}
+
+// WithDeniedSubnets refuses dials with ErrSourceNotAllowed if the source
+// address the system would use to reach the remote address is contained in one
+// of the given subnets.
+func WithDeniedSubnets(subnets []netip.Prefix) Option {
+	return func(m *ConnManager) error {
+		for _, s := range subnets {
+			if !s.IsValid() {
+				return fmt.Errorf("invalid subnet: %s", s)
+			}
+		}
+		if m.sourceFilter == nil {
+			m.sourceFilter = &sourceFilter{}
+		}
+		m.sourceFilter.deniedSubnets = slices.Clone(subnets)
+		return nil
+	}
+}
+
+// allows returns whether packets may be sent from ip.
+func (f *sourceFilter) allows(ip net.IP) (bool, error) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false, nil
+	}
+	addr = addr.Unmap()
+	for _, s := range f.deniedSubnets {
+		if s.Contains(addr) {
+			return false, nil
+		}
+	}
+	if len(f.allowedInterfaces) == 0 {
+		return true, nil
+	}
+	for _, name := range f.allowedInterfaces {
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			// The interface might not exist (yet).
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return false, err
+		}
+		for _, a := range addrs {
+			ipnet, ok := a.(*net.IPNet)
+			if ok && ipnet.IP.Equal(ip) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// checkDialSource returns an error if dialing raddr would use a source address
+// that isn't allowed by the source filter.
+func (c *ConnManager) checkDialSource(raddr *net.UDPAddr) error {
+	selector, err := c.sourceIPSelectorFn()
+	if err != nil {
+		return fmt.Errorf("%w: failed to look up routes: %w", ErrSourceNotAllowed, err)
+	}
+	src, err := selector.PreferredSourceIPForDestination(raddr)
+	if err != nil {
+		return fmt.Errorf("%w: failed to determine source address for %s: %w", ErrSourceNotAllowed, raddr, err)
+	}
+	ok, err := c.sourceFilter.allows(src)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrSourceNotAllowed, src)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package quicreuse
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/quic-go/quic-go"
+	"github.com/stretchr/testify/require"
+)
+
+type fixedSourceIPSelector net.IP
+
+func (s fixedSourceIPSelector) PreferredSourceIPForDestination(*net.UDPAddr) (net.IP, error) {
+	return net.IP(s), nil
+}
+
+func loopbackInterface(t *testing.T) string {
+	t.Helper()
+	ifaces, err := net.Interfaces()
+	require.NoError(t, err)
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			return iface.Name
+		}
+	}
+	t.Skip("no loopback interface")
+	return ""
+}
+
+func TestSourceFilter(t *testing.T) {
+	lo := loopbackInterface(t)
+	raddr := &net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 1234}
+
+	for _, reuseport := range []bool{true, false} {
+		for _, tc := range []struct {
+			name    string
+			src     net.IP
+			opts    []Option
+			allowed bool
+		}{
+			{name: "allowed interface", src: net.IPv4(127, 0, 0, 1), opts: []Option{WithAllowedInterfaces([]string{lo})}, allowed: true},
+			{name: "other interface", src: net.IPv4(192, 0, 2, 1), opts: []Option{WithAllowedInterfaces([]string{lo})}},
+			{name: "unknown interface", src: net.IPv4(127, 0, 0, 1), opts: []Option{WithAllowedInterfaces([]string{"does-not-exist0"})}},
+			{name: "denied subnet", src: net.IPv4(10, 0, 0, 1), opts: []Option{WithDeniedSubnets([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})}},
+			{name: "other subnet", src: net.IPv4(192, 0, 2, 1), opts: []Option{WithDeniedSubnets([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})}, allowed: true},
+			{
+				name: "denied subnet on allowed interface",
+				src:  net.IPv4(127, 0, 0, 1),
+				opts: []Option{
+					WithAllowedInterfaces([]string{lo}),
+					WithDeniedSubnets([]netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}),
+				},
+			},
+		} {
+			name := tc.name
+			if !reuseport {
+				name += " (no reuseport)"
+			}
+			t.Run(name, func(t *testing.T) {
+				opts := append([]Option{
+					OverrideSourceIPSelector(func() (SourceIPSelector, error) { return fixedSourceIPSelector(tc.src), nil }),
+				}, tc.opts...)
+				if !reuseport {
+					opts = append(opts, DisableReuseport())
+				}
+				cm, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{}, opts...)
+				require.NoError(t, err)
+				defer cm.Close()
+
+				tr, err := cm.TransportForDial("udp4", raddr)
+				if !tc.allowed {
+					require.ErrorIs(t, err, ErrSourceNotAllowed)
+					return
+				}
+				require.NoError(t, err)
+				if reuseport {
+					tr.DecreaseCount()
+				} else {
+					tr.Close()
+				}
+			})
+		}
+	}
+}
+
+func TestSourceFilterInvalidOptions(t *testing.T) {
+	_, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{}, WithAllowedInterfaces(nil))
+	require.Error(t, err)
+	_, err = NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{}, WithDeniedSubnets([]netip.Prefix{{}}))
+	require.Error(t, err)
+}
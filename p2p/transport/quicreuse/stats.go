@@ -0,0 +1,247 @@
+package quicreuse
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"golang.org/x/net/ipv4"
+)
+
+// TransportStat describes a QUIC transport, i.e. a UDP socket, managed by
+// the ConnManager.
+type TransportStat struct {
+	// Network is either udp4 or udp6.
+	Network string
+	// LocalAddr is the address the socket is bound to.
+	LocalAddr net.Addr
+	// Listening is true if the transport is used by a listener. Otherwise the
+	// transport was created for dialing, or was lent using LendTransport.
+	Listening bool
+	// Borrowed is true if the transport was lent using LendTransport.
+	Borrowed bool
+	// RefCount is the number of listeners and dialers using the transport.
+	RefCount int
+	// ActiveConns is the number of open connections dialed or accepted on
+	// the transport.
+	ActiveConns int
+	// Associations are the values the transport was associated with using
+	// ListenQUICAndAssociate. Dials with one of these associations prefer
+	// this transport.
+	Associations []any
+	// BytesSent and BytesReceived count the bytes of all UDP packets sent and
+	// received on the socket, including packets that are not QUIC packets.
+	// They are not tracked for borrowed transports.
+	BytesSent     uint64
+	BytesReceived uint64
+	// Listeners is the number of open QUIC listeners on the transport.
+	Listeners int
+	// NonQUICReaders is the number of open PacketConns sharing the transport
+	// for non-QUIC protocols, see SharedNonQUICPacketConn.
+	NonQUICReaders int
+	// Created is the time the transport was created, or lent.
+	Created time.Time
+	// LastActive is the last time the transport was used by a dial, a
+	// listener or a connection.
+	LastActive time.Time
+	// SuspectedLeak is true if the transport has a nonzero refcount, but
+	// isn't used. It is only set if leak detection is enabled using
+	// WithLeakDetection.
+	SuspectedLeak bool
+}
+
+// TransportStats returns statistics about the QUIC transports the ConnManager
+// reuses for listening and dialing. It is meant for debugging, e.g. to find
+// out which transport a dial was routed to. It returns nil if reuseport is
+// disabled.
+func (c *ConnManager) TransportStats() []TransportStat {
+	if !c.enableReuseport {
+		return nil
+	}
+	return append(c.reuseUDP4.transportStats("udp4"), c.reuseUDP6.transportStats("udp6")...)
+}
+
+func (r *reuse) transportStats(network string) []TransportStat {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var leakThreshold time.Duration
+	if r.leaks != nil {
+		leakThreshold = r.leaks.threshold
+	}
+	var stats []TransportStat
+	for _, tr := range r.globalListeners {
+		stats = append(stats, tr.stat(network, true, leakThreshold))
+	}
+	for _, trs := range r.unicast {
+		for _, tr := range trs {
+			stats = append(stats, tr.stat(network, true, leakThreshold))
+		}
+	}
+	for _, tr := range r.globalDialers {
+		stats = append(stats, tr.stat(network, false, leakThreshold))
+	}
+	return stats
+}
+
+// stat returns the stat of the transport. It reports suspected leaks if
+// leakThreshold is positive.
+func (c *refcountedTransport) stat(network string, listening bool, leakThreshold time.Duration) TransportStat {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s := TransportStat{
+		Network:        network,
+		LocalAddr:      c.packetConn.LocalAddr(),
+		Listening:      listening,
+		Borrowed:       c.borrowDoneSignal != nil,
+		RefCount:       c.refCount,
+		ActiveConns:    int(c.activeConns.Load()),
+		Listeners:      int(c.listeners.Load()),
+		NonQUICReaders: int(c.nonQUICReaders.Load()),
+		Created:        c.created,
+		LastActive:     c.lastActive,
+		SuspectedLeak:  c.suspectedLeakLocked(time.Now(), leakThreshold),
+	}
+	for a := range c.assocations {
+		s.Associations = append(s.Associations, a)
+	}
+	if cc, ok := c.packetConn.(countingPacketConn); ok {
+		s.BytesSent, s.BytesReceived = cc.byteCounts()
+	}
+	return s
+}
+
+// trackConn counts c as an active connection until it is closed.
+func (c *refcountedTransport) trackConn(conn *quic.Conn) {
+	c.activeConns.Add(1)
+	c.touch()
+	context.AfterFunc(conn.Context(), func() {
+		c.activeConns.Add(-1)
+		c.touch()
+	})
+}
+
+// trackingListener counts the connections accepted by a QUICListener as
+// active connections of its transport.
+type trackingListener struct {
+	QUICListener
+	tr     *refcountedTransport
+	closed atomic.Bool
+}
+
+func (l *trackingListener) Accept(ctx context.Context) (*quic.Conn, error) {
+	conn, err := l.QUICListener.Accept(ctx)
+	if err != nil {
+		return nil, err
+	}
+	l.tr.trackConn(conn)
+	return conn, nil
+}
+
+func (l *trackingListener) Close() error {
+	if l.closed.CompareAndSwap(false, true) {
+		l.tr.listeners.Add(-1)
+		l.tr.touch()
+	}
+	return l.QUICListener.Close()
+}
+
+// byteCounters counts the bytes sent and received on a socket.
+type byteCounters struct {
+	sent, received atomic.Uint64
+}
+
+func (c *byteCounters) byteCounts() (sent, received uint64) {
+	return c.sent.Load(), c.received.Load()
+}
+
+type countingPacketConn interface {
+	net.PacketConn
+	byteCounts() (sent, received uint64)
+}
+
+// newCountingPacketConn wraps conn to count the bytes sent and received.
+// A *net.UDPConn is wrapped such that quic-go can still use the UDP
+// optimizations (ECN, GSO and batched reads) on it. If inspect is not nil, it
+// is called with every packet received.
+func newCountingPacketConn(conn net.PacketConn, inspect func([]byte, net.Addr)) countingPacketConn {
+	if udpConn, ok := conn.(*net.UDPConn); ok {
+		return &countingUDPConn{UDPConn: udpConn, batchConn: ipv4.NewPacketConn(udpConn), inspect: inspect}
+	}
+	return &countingConn{PacketConn: conn, inspect: inspect}
+}
+
+type countingConn struct {
+	net.PacketConn
+	byteCounters
+	inspect func([]byte, net.Addr)
+}
+
+func (c *countingConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(b)
+	c.received.Add(uint64(n))
+	if c.inspect != nil && err == nil {
+		c.inspect(b[:n], addr)
+	}
+	return n, addr, err
+}
+
+func (c *countingConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	n, err := c.PacketConn.WriteTo(b, addr)
+	c.sent.Add(uint64(n))
+	return n, err
+}
+
+type countingUDPConn struct {
+	*net.UDPConn
+	byteCounters
+	batchConn *ipv4.PacketConn
+	inspect   func([]byte, net.Addr)
+}
+
+var _ quic.OOBCapablePacketConn = &countingUDPConn{}
+
+func (c *countingUDPConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.UDPConn.ReadFrom(b)
+	c.received.Add(uint64(n))
+	if c.inspect != nil && err == nil {
+		c.inspect(b[:n], addr)
+	}
+	return n, addr, err
+}
+
+func (c *countingUDPConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	n, err := c.UDPConn.WriteTo(b, addr)
+	c.sent.Add(uint64(n))
+	return n, err
+}
+
+func (c *countingUDPConn) ReadMsgUDP(b, oob []byte) (n, oobn, flags int, addr *net.UDPAddr, err error) {
+	n, oobn, flags, addr, err = c.UDPConn.ReadMsgUDP(b, oob)
+	c.received.Add(uint64(n))
+	if c.inspect != nil && err == nil {
+		c.inspect(b[:n], addr)
+	}
+	return n, oobn, flags, addr, err
+}
+
+func (c *countingUDPConn) WriteMsgUDP(b, oob []byte, addr *net.UDPAddr) (n, oobn int, err error) {
+	n, oobn, err = c.UDPConn.WriteMsgUDP(b, oob, addr)
+	c.sent.Add(uint64(n))
+	return n, oobn, err
+}
+
+// ReadBatch is used by quic-go to read multiple packets at once.
+func (c *countingUDPConn) ReadBatch(ms []ipv4.Message, flags int) (int, error) {
+	n, err := c.batchConn.ReadBatch(ms, flags)
+	for _, m := range ms[:max(n, 0)] {
+		c.received.Add(uint64(m.N))
+		if c.inspect != nil && len(m.Buffers) > 0 {
+			c.inspect(m.Buffers[0][:m.N], m.Addr)
+		}
+	}
+	return n, err
+}
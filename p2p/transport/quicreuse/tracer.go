@@ -16,21 +16,37 @@ import (
 
 var log = golog.Logger("quic-utils")
 
-// QLOGTracer holds a qlog tracer dir, if qlogging is enabled (enabled using the QLOGDIR environment variable).
-// Otherwise it is an empty string.
+// qlogTracerDir holds a qlog tracer dir, if qlogging is enabled (enabled using the QLOGDIR environment variable).
+// Otherwise it is an empty string. It is ignored if a qlog writer is set using the WithQlogWriter option.
 var qlogTracerDir string
 
 func init() {
 	qlogTracerDir = os.Getenv("QLOGDIR")
 }
 
-func qloggerForDir(qlogDir string, p logging.Perspective, ci quic.ConnectionID) *logging.ConnectionTracer {
-	// create the QLOGDIR, if it doesn't exist
-	if err := os.MkdirAll(qlogDir, 0777); err != nil {
-		log.Errorf("creating the QLOGDIR failed: %s", err)
+// qlogWriterFunc returns the writer a connection's qlog is written to. The writer
+// is closed when the connection is closed. Returning nil disables qlogging for
+// the connection.
+type qlogWriterFunc func(p logging.Perspective, connID quic.ConnectionID) io.WriteCloser
+
+// qlogDirWriter writes compressed qlog files to qlogDir.
+func qlogDirWriter(qlogDir string) qlogWriterFunc {
+	return func(p logging.Perspective, ci quic.ConnectionID) io.WriteCloser {
+		// create the QLOGDIR, if it doesn't exist
+		if err := os.MkdirAll(qlogDir, 0777); err != nil {
+			log.Errorf("creating the QLOGDIR failed: %s", err)
+			return nil
+		}
+		return newQlogger(qlogDir, p, ci)
+	}
+}
+
+func qloggerFor(w qlogWriterFunc, p logging.Perspective, ci quic.ConnectionID) *logging.ConnectionTracer {
+	wc := w(p, ci)
+	if wc == nil {
 		return nil
 	}
-	return qlog.NewConnectionTracer(newQlogger(qlogDir, p, ci), p, ci)
+	return qlog.NewConnectionTracer(wc, p, ci)
 }
 
 // The qlogger logs qlog events to a temporary file: .<name>.qlog.swp.
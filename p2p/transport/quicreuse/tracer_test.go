@@ -2,12 +2,21 @@ package quicreuse
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
 	"io"
 	"os"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	libp2ptls "github.com/libp2p/go-libp2p/p2p/security/tls"
 
 	"github.com/klauspost/compress/zstd"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/quic-go/quic-go"
 	"github.com/quic-go/quic-go/logging"
 	"github.com/stretchr/testify/require"
@@ -66,3 +75,88 @@ func TestQlogCompression(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, []byte("foobar"), data)
 }
+
+type qlogBuffer struct {
+	mx     sync.Mutex
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (b *qlogBuffer) Write(p []byte) (int, error) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *qlogBuffer) Close() error {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.closed = true
+	return nil
+}
+
+func TestQlogWriter(t *testing.T) {
+	var mx sync.Mutex
+	var qlogs []*qlogBuffer
+	server, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{},
+		WithQlogWriter(func(p logging.Perspective, _ quic.ConnectionID) io.WriteCloser {
+			require.Equal(t, logging.PerspectiveServer, p)
+			mx.Lock()
+			defer mx.Unlock()
+			b := &qlogBuffer{}
+			qlogs = append(qlogs, b)
+			return b
+		}))
+	require.NoError(t, err)
+	defer server.Close()
+	// Returning nil disables qlogging for a connection.
+	client, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{},
+		WithQlogWriter(func(logging.Perspective, quic.ConnectionID) io.WriteCloser { return nil }))
+	require.NoError(t, err)
+	defer client.Close()
+
+	serverID, serverTLSConf := getTLSConfForProto(t, "proto")
+	ln, err := server.ListenQUIC(ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1"), serverTLSConf, nil)
+	require.NoError(t, err)
+	defer ln.Close()
+
+	clientKey, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	clientIdentity, err := libp2ptls.NewIdentity(clientKey)
+	require.NoError(t, err)
+	clientTLSConf, _ := clientIdentity.ConfigForPeer(serverID)
+	clientTLSConf.NextProtos = []string{"proto"}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := client.DialQUIC(ctx, ln.Multiaddrs()[0], clientTLSConf, nil)
+	require.NoError(t, err)
+	sconn, err := ln.Accept(ctx)
+	require.NoError(t, err)
+	conn.CloseWithError(0, "")
+	<-sconn.Context().Done()
+
+	mx.Lock()
+	defer mx.Unlock()
+	require.Len(t, qlogs, 1)
+	require.Eventually(t, func() bool {
+		qlogs[0].mx.Lock()
+		defer qlogs[0].mx.Unlock()
+		return qlogs[0].closed
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NotZero(t, qlogs[0].buf.Len())
+}
+
+func TestQlogWriterNil(t *testing.T) {
+	_, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{}, WithQlogWriter(nil))
+	require.Error(t, err)
+}
+
+func TestQlogWriterKeepsMetricsTracer(t *testing.T) {
+	cm, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{},
+		EnableMetrics(prometheus.NewRegistry()),
+		WithQlogWriter(func(logging.Perspective, quic.ConnectionID) io.WriteCloser { return nil }))
+	require.NoError(t, err)
+	defer cm.Close()
+	connID := quic.ConnectionIDFromBytes([]byte{1, 2, 3, 4})
+	require.NotNil(t, cm.getTracer()(context.Background(), logging.PerspectiveClient, connID))
+}
@@ -0,0 +1,140 @@
+package quicreuse
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+
+	"github.com/libp2p/go-libp2p/p2p/metricshelper"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/quic-go/quic-go/logging"
+	"golang.org/x/time/rate"
+)
+
+// unclaimedHeaderLen is the number of leading bytes of non-QUIC packets that
+// are passed to the unclaimed packet hook.
+const unclaimedHeaderLen = 16
+
+var unclaimedPacketsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "quicreuse",
+		Name:      "unclaimed_packets_total",
+		Help:      "Packets received on shared sockets that no QUIC connection or non-QUIC user claimed",
+	},
+	[]string{"type"},
+)
+
+// UnclaimedPacket describes a packet that was received on a socket managed
+// by the ConnManager, but was dropped because it matched no QUIC connection,
+// or because it isn't a QUIC packet and no non-QUIC transport (like WebRTC)
+// was reading from the socket.
+type UnclaimedPacket struct {
+	LocalAddr  net.Addr
+	RemoteAddr net.Addr
+	Size       int
+	// NonQUIC is true if the packet doesn't look like a QUIC packet.
+	NonQUIC bool
+	// PacketType is the type of a QUIC packet.
+	PacketType logging.PacketType
+	// Header holds the first bytes of a non-QUIC packet, to help identify
+	// the protocol it belongs to.
+	Header []byte
+	// Count is the number of unclaimed packets since the previous sample
+	// passed to the hook, including this one.
+	Count uint64
+}
+
+// WithUnclaimedPacketHook calls hook with a sample of the packets received on
+// shared sockets that no QUIC connection or non-QUIC transport claimed. This
+// helps to debug misconfigured peers, and other applications sending to a
+// port shared by QUIC and WebRTC. At most samplesPerSecond packets are passed
+// to hook. hook is called from the socket's read loop and must not block.
+//
+// If metrics are enabled using EnableMetrics, all unclaimed packets are
+// counted, regardless of this option.
+//
+// Sockets are only observed if reuseport is enabled.
+func WithUnclaimedPacketHook(hook func(UnclaimedPacket), samplesPerSecond float64) Option {
+	return func(m *ConnManager) error {
+		if hook == nil {
+			return errors.New("unclaimed packet hook is nil")
+		}
+		if samplesPerSecond <= 0 {
+			return errors.New("samples per second must be positive")
+		}
+		m.unclaimedHook = hook
+		m.unclaimedSampleRate = samplesPerSecond
+		return nil
+	}
+}
+
+// unclaimedTracker counts unclaimed packets and samples them for the hook.
+type unclaimedTracker struct {
+	hook    func(UnclaimedPacket)
+	limiter *rate.Limiter
+	metrics bool
+	// count is the number of unclaimed packets since the last sample.
+	count atomic.Uint64
+}
+
+func newUnclaimedTracker(hook func(UnclaimedPacket), samplesPerSecond float64, reg prometheus.Registerer) *unclaimedTracker {
+	t := &unclaimedTracker{hook: hook, metrics: reg != nil}
+	if hook != nil {
+		t.limiter = rate.NewLimiter(rate.Limit(samplesPerSecond), max(1, int(samplesPerSecond)))
+	}
+	if reg != nil {
+		metricshelper.RegisterCollectors(reg, unclaimedPacketsTotal)
+	}
+	return t
+}
+
+func (t *unclaimedTracker) record(p UnclaimedPacket, data []byte) {
+	if t.metrics {
+		tags := metricshelper.GetStringSlice()
+		if p.NonQUIC {
+			*tags = append(*tags, "non_quic")
+		} else {
+			*tags = append(*tags, "quic")
+		}
+		unclaimedPacketsTotal.WithLabelValues(*tags...).Inc()
+		metricshelper.PutStringSlice(tags)
+	}
+	if t.hook == nil {
+		return
+	}
+	t.count.Add(1)
+	if !t.limiter.Allow() {
+		return
+	}
+	p.Count = t.count.Swap(0)
+	if len(data) > 0 {
+		p.Header = append([]byte(nil), data[:min(len(data), unclaimedHeaderLen)]...)
+	}
+	t.hook(p)
+}
+
+// tracer returns a tracer for a QUIC transport, reporting packets that are
+// dropped because they match no connection.
+func (t *unclaimedTracker) tracer(laddr net.Addr) *logging.Tracer {
+	return &logging.Tracer{
+		DroppedPacket: func(raddr net.Addr, pt logging.PacketType, size logging.ByteCount, reason logging.PacketDropReason) {
+			if reason != logging.PacketDropUnknownConnectionID {
+				return
+			}
+			t.record(UnclaimedPacket{LocalAddr: laddr, RemoteAddr: raddr, Size: int(size), PacketType: pt}, nil)
+		},
+	}
+}
+
+// inspector returns a function checking the packets received on tr for
+// non-QUIC packets while no non-QUIC transport reads from tr.
+func (t *unclaimedTracker) inspector(tr *refcountedTransport, laddr net.Addr) func([]byte, net.Addr) {
+	return func(b []byte, raddr net.Addr) {
+		// quic-go considers packets with the two most significant bits
+		// unset non-QUIC packets.
+		if len(b) == 0 || b[0]&0xc0 != 0 || tr.nonQUICReaders.Load() > 0 {
+			return
+		}
+		t.record(UnclaimedPacket{LocalAddr: laddr, RemoteAddr: raddr, Size: len(b), NonQUIC: true}, b)
+	}
+}
@@ -0,0 +1,83 @@
+package quicreuse
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/quic-go/quic-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnclaimedPacketHook(t *testing.T) {
+	unclaimed := make(chan UnclaimedPacket, 10)
+	cm, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{},
+		WithUnclaimedPacketHook(func(p UnclaimedPacket) { unclaimed <- p }, 1000))
+	require.NoError(t, err)
+	defer cm.Close()
+
+	ln, err := cm.ListenQUIC(ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1"), &tls.Config{NextProtos: []string{"proto"}}, nil)
+	require.NoError(t, err)
+	defer ln.Close()
+	udpAddr := ln.Addr().(*net.UDPAddr)
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	next := func() UnclaimedPacket {
+		t.Helper()
+		select {
+		case p := <-unclaimed:
+			return p
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for unclaimed packet")
+			return UnclaimedPacket{}
+		}
+	}
+
+	// a non-QUIC packet while no non-QUIC transport is reading
+	stun := []byte{0x00, 0x01, 0x00, 0x00, 0x21, 0x12, 0xa4, 0x42, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	_, err = conn.WriteTo(stun, udpAddr)
+	require.NoError(t, err)
+	p := next()
+	require.True(t, p.NonQUIC)
+	require.Equal(t, len(stun), p.Size)
+	require.Equal(t, stun[:unclaimedHeaderLen], p.Header)
+	require.Equal(t, conn.LocalAddr().String(), p.RemoteAddr.String())
+	require.Equal(t, uint64(1), p.Count)
+
+	// a short header packet for an unknown connection
+	_, err = conn.WriteTo(append([]byte{0x40}, make([]byte, 19)...), udpAddr)
+	require.NoError(t, err)
+	p = next()
+	require.False(t, p.NonQUIC)
+	require.Empty(t, p.Header)
+	require.Equal(t, 20, p.Size)
+
+	// non-QUIC packets are claimed while a non-QUIC transport shares the socket
+	pconn, err := cm.SharedNonQUICPacketConn("udp4", udpAddr)
+	require.NoError(t, err)
+	_, err = conn.WriteTo(stun, udpAddr)
+	require.NoError(t, err)
+	buf := make([]byte, 100)
+	n, _, err := pconn.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, stun, buf[:n])
+	require.Empty(t, unclaimed)
+
+	// and unclaimed again once it's closed
+	require.NoError(t, pconn.Close())
+	_, err = conn.WriteTo(stun, udpAddr)
+	require.NoError(t, err)
+	require.True(t, next().NonQUIC)
+}
+
+func TestUnclaimedPacketHookOption(t *testing.T) {
+	_, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{}, WithUnclaimedPacketHook(nil, 1))
+	require.Error(t, err)
+	_, err = NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{}, WithUnclaimedPacketHook(func(UnclaimedPacket) {}, 0))
+	require.Error(t, err)
+}
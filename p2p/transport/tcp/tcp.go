@@ -20,6 +20,7 @@ import (
 	ma "github.com/multiformats/go-multiaddr"
 	mafmt "github.com/multiformats/go-multiaddr-fmt"
 	manet "github.com/multiformats/go-multiaddr/net"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 const defaultConnectTimeout = 5 * time.Second
@@ -118,6 +119,24 @@ func WithMetrics() Option {
 	}
 }
 
+// WithAcceptLoops makes every listener of the transport accept connections
+// from n sockets sharing the listen port via SO_REUSEPORT, to scale accepting
+// connections on many-core servers. It requires reuseport. If metrics are
+// enabled, the number of connections accepted by each socket is tracked, see
+// reuseport.RegisterAcceptMetrics.
+//
+// It doesn't apply to listeners shared with other transports, see
+// tcpreuse.WithAcceptLoops.
+func WithAcceptLoops(n int) Option {
+	return func(tr *TcpTransport) error {
+		if n < 1 {
+			return errors.New("number of accept loops must be positive")
+		}
+		tr.acceptLoops = n
+		return nil
+	}
+}
+
 // WithDialerForAddr sets a custom dialer for the given address.
 // If set, it will be the *ONLY* dialer used.
 func WithDialerForAddr(d DialerForAddr) Option {
@@ -149,6 +168,7 @@ type TcpTransport struct {
 
 	disableReuseport bool // Explicitly disable reuseport.
 	enableMetrics    bool
+	acceptLoops      int
 
 	// share and demultiplex TCP listeners across multiple transports
 	sharedTcp *tcpreuse.ConnMgr
@@ -186,7 +206,12 @@ func NewTCPTransport(upgrader transport.Upgrader, rcmgr network.ResourceManager,
 	return tr, nil
 }
 
-var dialMatcher = mafmt.And(mafmt.IP, mafmt.Base(ma.P_TCP))
+// dialMatcher matches /ip{4,6}/tcp addresses, and IPv6 link-local addresses
+// with a zone, e.g. /ip6zone/eth0/ip6/fe80::1/tcp/1234.
+var dialMatcher = mafmt.And(
+	mafmt.Or(mafmt.IP, mafmt.And(mafmt.Base(ma.P_IP6ZONE), mafmt.Base(ma.P_IP6))),
+	mafmt.Base(ma.P_TCP),
+)
 
 // CanDial returns true if this transport believes it can dial the given
 // multiaddr.
@@ -308,6 +333,19 @@ func (t *TcpTransport) UseReuseport() bool {
 }
 
 func (t *TcpTransport) unsharedMAListen(laddr ma.Multiaddr) (manet.Listener, error) {
+	if t.acceptLoops > 1 {
+		if !t.UseReuseport() {
+			return nil, errors.New("multiple accept loops require reuseport")
+		}
+		if t.enableMetrics {
+			reuseport.RegisterAcceptMetrics(prometheus.DefaultRegisterer)
+		}
+		ml, err := t.reuse.ListenN(laddr, t.acceptLoops)
+		if err != nil {
+			return nil, err
+		}
+		return ml, nil
+	}
 	if t.UseReuseport() {
 		return t.reuse.Listen(laddr)
 	}
@@ -353,6 +391,11 @@ func (t *TcpTransport) Proxy() bool {
 	return false
 }
 
+// TransportName returns the name of the transport in network.ConnectionState.
+func (t *TcpTransport) TransportName() string {
+	return "tcp"
+}
+
 func (t *TcpTransport) String() string {
 	return "TCP"
 }
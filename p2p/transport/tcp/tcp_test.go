@@ -12,6 +12,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/sec"
 	"github.com/libp2p/go-libp2p/core/sec/insecure"
+	"github.com/libp2p/go-libp2p/core/test"
 	"github.com/libp2p/go-libp2p/core/transport"
 	"github.com/libp2p/go-libp2p/p2p/muxer/yamux"
 	tptu "github.com/libp2p/go-libp2p/p2p/net/upgrader"
@@ -65,6 +66,33 @@ func TestTcpTransportWithMetrics(t *testing.T) {
 	ttransport.SubtestTransport(t, ta, tb, zero, peerA)
 }
 
+func TestTcpTransportAcceptLoops(t *testing.T) {
+	if !tcpreuse.ReuseportIsAvailable() {
+		t.Skip("reuseport not available")
+	}
+	peerA, ia := makeInsecureMuxer(t)
+	_, ib := makeInsecureMuxer(t)
+
+	ua, err := tptu.New(ia, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	ta, err := NewTCPTransport(ua, nil, nil, WithAcceptLoops(4), WithMetrics())
+	require.NoError(t, err)
+	ub, err := tptu.New(ib, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	tb, err := NewTCPTransport(ub, nil, nil, WithAcceptLoops(4))
+	require.NoError(t, err)
+
+	zero := "/ip4/127.0.0.1/tcp/0"
+	ttransport.SubtestTransport(t, ta, tb, zero, peerA)
+
+	_, err = NewTCPTransport(ua, nil, nil, WithAcceptLoops(0))
+	require.Error(t, err)
+	tr, err := NewTCPTransport(ua, nil, nil, WithAcceptLoops(2), DisableReuseport())
+	require.NoError(t, err)
+	_, err = tr.Listen(ma.StringCast(zero))
+	require.Error(t, err)
+}
+
 func TestResourceManager(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -278,3 +306,35 @@ func TestCustomOverrideTCPDialer(t *testing.T) {
 		}
 	})
 }
+
+func TestLinkLocalZone(t *testing.T) {
+	peerA, ia := makeInsecureMuxer(t)
+	_, ib := makeInsecureMuxer(t)
+	ua, err := tptu.New(ia, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	ta, err := NewTCPTransport(ua, nil, nil)
+	require.NoError(t, err)
+	ub, err := tptu.New(ib, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	tb, err := NewTCPTransport(ub, nil, nil)
+	require.NoError(t, err)
+
+	require.True(t, ta.CanDial(ma.StringCast("/ip6zone/eth0/ip6/fe80::1/tcp/1234")))
+
+	ln, err := ta.Listen(test.LinkLocalAddr(t).Encapsulate(ma.StringCast("/tcp/0")))
+	require.NoError(t, err)
+	defer ln.Close()
+	require.Equal(t, ma.P_IP6ZONE, ln.Multiaddr()[0].Code())
+
+	go func() {
+		c, err := ln.Accept()
+		if err == nil {
+			c.Close()
+		}
+	}()
+	conn, err := tb.Dial(context.Background(), ln.Multiaddr(), peerA)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, ma.P_IP6ZONE, conn.RemoteMultiaddr()[0].Code())
+	require.Equal(t, ma.P_IP6ZONE, conn.LocalMultiaddr()[0].Code())
+}
@@ -28,24 +28,56 @@ type ConnMgr struct {
 	enableReuseport bool
 	reuse           reuseport.Transport
 	upgrader        transport.Upgrader
+	acceptLoops     int
 
 	mx        sync.Mutex
 	listeners map[string]*multiplexedListener
 }
 
-func NewConnMgr(enableReuseport bool, upgrader transport.Upgrader) *ConnMgr {
-	return &ConnMgr{
+// Option is an option for NewConnMgr.
+type Option func(*ConnMgr)
+
+// WithAcceptLoops makes every shared listener accept connections from n
+// sockets sharing the listen port via SO_REUSEPORT, to scale accepting
+// connections on many-core servers. It requires reuseport. See
+// reuseport.RegisterAcceptMetrics for the number of connections accepted by
+// each socket. Listening fails if n isn't positive.
+func WithAcceptLoops(n int) Option {
+	return func(t *ConnMgr) {
+		t.acceptLoops = n
+	}
+}
+
+func NewConnMgr(enableReuseport bool, upgrader transport.Upgrader, opts ...Option) *ConnMgr {
+	t := &ConnMgr{
 		enableReuseport: enableReuseport,
 		reuse:           reuseport.Transport{},
 		upgrader:        upgrader,
+		acceptLoops:     1,
 		listeners:       make(map[string]*multiplexedListener),
 	}
+	for _, o := range opts {
+		o(t)
+	}
+	return t
 }
 
 func (t *ConnMgr) gatedMaListen(listenAddr ma.Multiaddr) (transport.GatedMaListener, error) {
 	var mal manet.Listener
 	var err error
-	if t.useReuseport() {
+	if t.acceptLoops < 1 {
+		return nil, errors.New("number of accept loops must be positive")
+	}
+	if t.acceptLoops > 1 {
+		if !t.useReuseport() {
+			return nil, errors.New("multiple accept loops require reuseport")
+		}
+		ml, err := t.reuse.ListenN(listenAddr, t.acceptLoops)
+		if err != nil {
+			return nil, err
+		}
+		mal = ml
+	} else if t.useReuseport() {
 		mal, err = t.reuse.Listen(listenAddr)
 		if err != nil {
 			return nil, err
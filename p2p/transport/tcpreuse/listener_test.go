@@ -245,6 +245,51 @@ func TestListenerSingle(t *testing.T) {
 	}
 }
 
+func TestListenerAcceptLoops(t *testing.T) {
+	if !ReuseportIsAvailable() {
+		t.Skip("reuseport not available")
+	}
+	listenAddr := ma.StringCast("/ip4/127.0.0.1/tcp/0")
+	const N = 32
+	cm := NewConnMgr(true, upgrader(t), WithAcceptLoops(4))
+	l, err := cm.DemultiplexedListen(listenAddr, DemultiplexedConnType_MultistreamSelect)
+	require.NoError(t, err)
+	defer l.Close()
+
+	errCh := make(chan error, N)
+	for i := 0; i < N; i++ {
+		go func() {
+			conn, err := net.Dial(l.Addr().Network(), l.Addr().String())
+			if err != nil {
+				errCh <- err
+				return
+			}
+			defer conn.Close()
+			_, err = multistream.NewMSSelect(conn, "a").Write([]byte("hello"))
+			errCh <- err
+		}()
+	}
+	for i := 0; i < N; i++ {
+		c, scope, err := l.Accept()
+		require.NoError(t, err)
+		scope.Done()
+		c.Close()
+	}
+	for i := 0; i < N; i++ {
+		require.NoError(t, <-errCh)
+	}
+
+	cm = NewConnMgr(false, upgrader(t), WithAcceptLoops(4))
+	_, err = cm.DemultiplexedListen(listenAddr, DemultiplexedConnType_MultistreamSelect)
+	require.Error(t, err)
+
+	for _, n := range []int{0, -1} {
+		cm = NewConnMgr(true, upgrader(t), WithAcceptLoops(n))
+		_, err = cm.DemultiplexedListen(listenAddr, DemultiplexedConnType_MultistreamSelect)
+		require.Error(t, err)
+	}
+}
+
 func TestListenerMultiplexed(t *testing.T) {
 	listenAddr := ma.StringCast("/ip4/0.0.0.0/tcp/0")
 	const N = 20
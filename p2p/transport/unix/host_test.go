@@ -0,0 +1,60 @@
+package unix_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+	"github.com/libp2p/go-libp2p/p2p/transport/unix"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHosts(t *testing.T) {
+	dir, err := os.MkdirTemp("", "unix")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	h1, err := libp2p.New(
+		libp2p.NoTransports,
+		libp2p.Transport(unix.New),
+		libp2p.ListenAddrStrings("/unix"+filepath.ToSlash(filepath.Join(dir, "h1.sock"))),
+	)
+	require.NoError(t, err)
+	defer h1.Close()
+	h2, err := libp2p.New(libp2p.NoTransports, libp2p.Transport(unix.New), libp2p.NoListenAddrs)
+	require.NoError(t, err)
+	defer h2.Close()
+
+	require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Network().ListenAddresses()}))
+	res := <-ping.Ping(context.Background(), h2, h1.ID())
+	require.NoError(t, res.Error)
+}
+
+func TestUnixAddrsAreNotShared(t *testing.T) {
+	dir, err := os.MkdirTemp("", "unix")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// h1 advertises its /unix address anyway.
+	addr := ma.StringCast("/unix" + filepath.ToSlash(filepath.Join(dir, "h1.sock")))
+	h1, err := libp2p.New(
+		libp2p.NoTransports,
+		libp2p.Transport(unix.New),
+		libp2p.ListenAddrs(addr),
+		libp2p.AddrsFactory(func(addrs []ma.Multiaddr) []ma.Multiaddr { return append(addrs, addr) }),
+	)
+	require.NoError(t, err)
+	defer h1.Close()
+	h2, err := libp2p.New(libp2p.NoTransports, libp2p.Transport(unix.New), libp2p.NoListenAddrs)
+	require.NoError(t, err)
+	defer h2.Close()
+
+	require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: []ma.Multiaddr{addr}}))
+	require.Empty(t, h1.(interface{ AllAddrs() []ma.Multiaddr }).AllAddrs())
+	require.Empty(t, h2.Peerstore().Addrs(h1.ID()))
+}
@@ -0,0 +1,196 @@
+// Package unix implements a Unix domain socket transport for go-libp2p.
+//
+// It allows co-located processes, e.g. a node and its sidecars, to connect
+// without going through the TCP loopback interface. Access to a listener can
+// be restricted using file system permissions, see WithFileMode.
+//
+// Unix addresses have the form /unix/<path>, e.g. /unix/run/libp2p.sock.
+// Connections are secured and multiplexed like any other connection.
+package unix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/transport"
+
+	logging "github.com/ipfs/go-log/v2"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+var log = logging.Logger("unix-tpt")
+
+// staleCheckTimeout is how long to wait for a connection when checking
+// whether a socket is still in use.
+const staleCheckTimeout = time.Second
+
+type Option func(*Transport) error
+
+// WithFileMode sets the permissions of the sockets the transport listens on.
+// Only processes allowed to write to a socket can connect to it.
+//
+// The socket is created with the permissions given by the umask of the
+// process, and changed to mode right after. To rule out connections in
+// between, place the socket in a directory only accessible to the intended
+// users.
+func WithFileMode(mode fs.FileMode) Option {
+	return func(t *Transport) error {
+		if mode&^fs.ModePerm != 0 {
+			return fmt.Errorf("invalid file mode: %s", mode)
+		}
+		t.fileMode = &mode
+		return nil
+	}
+}
+
+// Transport is the Unix domain socket transport.
+type Transport struct {
+	upgrader transport.Upgrader
+	rcmgr    network.ResourceManager
+	// fileMode is the mode of listening sockets. nil keeps the mode the
+	// socket was created with.
+	fileMode *fs.FileMode
+}
+
+var _ transport.Transport = &Transport{}
+
+// New creates a new Unix domain socket transport.
+func New(upgrader transport.Upgrader, rcmgr network.ResourceManager, opts ...Option) (*Transport, error) {
+	if rcmgr == nil {
+		rcmgr = &network.NullResourceManager{}
+	}
+	t := &Transport{
+		upgrader: upgrader,
+		rcmgr:    rcmgr,
+	}
+	for _, o := range opts {
+		if err := o(t); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// parseAddr returns the path of a /unix multiaddr.
+func parseAddr(a ma.Multiaddr) (string, error) {
+	if len(a) != 1 || a[0].Protocol().Code != ma.P_UNIX {
+		return "", fmt.Errorf("not a unix multiaddr: %s", a)
+	}
+	return a[0].Value(), nil
+}
+
+// CanDial returns true for /unix multiaddrs.
+func (t *Transport) CanDial(addr ma.Multiaddr) bool {
+	_, err := parseAddr(addr)
+	return err == nil
+}
+
+// Dial dials the peer listening on the given socket.
+func (t *Transport) Dial(ctx context.Context, raddr ma.Multiaddr, p peer.ID) (transport.CapableConn, error) {
+	if _, err := parseAddr(raddr); err != nil {
+		return nil, err
+	}
+	connScope, err := t.rcmgr.OpenConnection(network.DirOutbound, true, raddr)
+	if err != nil {
+		log.Debugw("resource manager blocked outgoing connection", "peer", p, "addr", raddr, "error", err)
+		return nil, err
+	}
+	c, err := t.dialWithScope(ctx, raddr, p, connScope)
+	if err != nil {
+		connScope.Done()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (t *Transport) dialWithScope(ctx context.Context, raddr ma.Multiaddr, p peer.ID, connScope network.ConnManagementScope) (transport.CapableConn, error) {
+	if err := connScope.SetPeer(p); err != nil {
+		log.Debugw("resource manager blocked outgoing connection for peer", "peer", p, "addr", raddr, "error", err)
+		return nil, err
+	}
+	var d manet.Dialer
+	c, err := d.DialContext(ctx, raddr)
+	if err != nil {
+		return nil, err
+	}
+	uc, err := t.upgrader.Upgrade(ctx, t, c, network.DirOutbound, p, connScope)
+	if err != nil {
+		return nil, err
+	}
+	return uc, nil
+}
+
+// Listen listens on the given socket. A socket left behind by a process that
+// didn't close its listener, i.e. one nobody accepts connections on, is
+// replaced. The socket is removed when the listener is closed.
+func (t *Transport) Listen(laddr ma.Multiaddr) (transport.Listener, error) {
+	path, err := parseAddr(laddr)
+	if err != nil {
+		return nil, err
+	}
+	l, err := listen(laddr, path)
+	if err != nil {
+		return nil, err
+	}
+	if t.fileMode != nil {
+		if err := os.Chmod(path, *t.fileMode); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	return t.upgrader.UpgradeGatedMaListener(t, t.upgrader.GateMaListener(l)), nil
+}
+
+func listen(laddr ma.Multiaddr, path string) (manet.Listener, error) {
+	l, err := manet.Listen(laddr)
+	if err == nil || !errors.Is(err, syscall.EADDRINUSE) || !isStale(path) {
+		return l, err
+	}
+	log.Debugw("replacing stale socket", "path", path)
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return manet.Listen(laddr)
+}
+
+// isStale returns true if path is a socket that refuses connections.
+func isStale(path string) bool {
+	fi, err := os.Lstat(path)
+	if err != nil || fi.Mode().Type() != fs.ModeSocket {
+		return false
+	}
+	c, err := net.DialTimeout("unix", path, staleCheckTimeout)
+	if err == nil {
+		c.Close()
+		return false
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// Protocols returns the list of terminal protocols this transport can dial.
+func (t *Transport) Protocols() []int {
+	return []int{ma.P_UNIX}
+}
+
+// Proxy always returns false for the unix transport.
+func (t *Transport) Proxy() bool {
+	return false
+}
+
+// TransportName returns the name of the transport in network.ConnectionState.
+func (t *Transport) TransportName() string {
+	return "unix"
+}
+
+func (t *Transport) String() string {
+	return "unix"
+}
@@ -0,0 +1,181 @@
+package unix
+
+import (
+	"context"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/sec"
+	"github.com/libp2p/go-libp2p/core/transport"
+	"github.com/libp2p/go-libp2p/p2p/muxer/yamux"
+	tptu "github.com/libp2p/go-libp2p/p2p/net/upgrader"
+	"github.com/libp2p/go-libp2p/p2p/security/noise"
+	ttransport "github.com/libp2p/go-libp2p/p2p/transport/testsuite"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func newUpgrader(t *testing.T) (peer.ID, transport.Upgrader) {
+	t.Helper()
+	priv, _, err := crypto.GenerateEd25519Key(nil)
+	require.NoError(t, err)
+	id, err := peer.IDFromPrivateKey(priv)
+	require.NoError(t, err)
+	n, err := noise.New(noise.ID, priv, nil)
+	require.NoError(t, err)
+	u, err := tptu.New([]sec.SecureTransport{n}, []tptu.StreamMuxer{{ID: yamux.ID, Muxer: yamux.DefaultTransport}}, nil, nil, nil)
+	require.NoError(t, err)
+	return id, u
+}
+
+// socketAddr returns the address of a socket in a new temporary directory.
+// t.TempDir is not used, as its paths may exceed the length limit of socket
+// paths.
+func socketAddr(t *testing.T, name string) ma.Multiaddr {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "unix")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return ma.StringCast("/unix" + filepath.ToSlash(filepath.Join(dir, name)))
+}
+
+func TestUnixTransport(t *testing.T) {
+	peerA, ua := newUpgrader(t)
+	ta, err := New(ua, nil)
+	require.NoError(t, err)
+	peerB, ub := newUpgrader(t)
+	tb, err := New(ub, nil)
+	require.NoError(t, err)
+
+	// SubtestStressManyConn10Stream50Msg listens on the address several times
+	// concurrently, which only works with addresses like port 0.
+	var tests []ttransport.TransportSubTestFn
+	for _, f := range ttransport.Subtests {
+		if reflect.ValueOf(f).Pointer() != reflect.ValueOf(ttransport.SubtestStressManyConn10Stream50Msg).Pointer() {
+			tests = append(tests, f)
+		}
+	}
+	ttransport.SubtestTransportWithFs(t, ta, tb, socketAddr(t, "a.sock").String(), peerA, tests)
+	ttransport.SubtestTransportWithFs(t, tb, ta, socketAddr(t, "b.sock").String(), peerB, tests)
+}
+
+func TestCanDial(t *testing.T) {
+	tpt := &Transport{}
+	require.True(t, tpt.CanDial(ma.StringCast("/unix/tmp/libp2p.sock")))
+	require.False(t, tpt.CanDial(ma.StringCast("/ip4/127.0.0.1/tcp/1")))
+	require.False(t, tpt.CanDial(ma.StringCast("/memory/1")))
+}
+
+func TestConnState(t *testing.T) {
+	serverID, us := newUpgrader(t)
+	server, err := New(us, nil)
+	require.NoError(t, err)
+	_, uc := newUpgrader(t)
+	client, err := New(uc, nil)
+	require.NoError(t, err)
+
+	l, err := server.Listen(socketAddr(t, "s.sock"))
+	require.NoError(t, err)
+	defer l.Close()
+
+	accepted := make(chan transport.CapableConn, 1)
+	go func() {
+		c, err := l.Accept()
+		if err == nil {
+			accepted <- c
+		}
+	}()
+
+	c, err := client.Dial(context.Background(), l.Multiaddr(), serverID)
+	require.NoError(t, err)
+	defer c.Close()
+	sc := <-accepted
+	defer sc.Close()
+
+	for _, c := range []network.ConnMultiaddrs{c, sc} {
+		_, err := parseAddr(c.LocalMultiaddr())
+		require.NoError(t, err)
+		_, err = parseAddr(c.RemoteMultiaddr())
+		require.NoError(t, err)
+	}
+	require.True(t, c.RemoteMultiaddr().Equal(l.Multiaddr()))
+	require.True(t, sc.LocalMultiaddr().Equal(l.Multiaddr()))
+	require.Equal(t, "unix", c.ConnState().Transport)
+	require.Equal(t, "unix", sc.ConnState().Transport)
+}
+
+func TestListenerRemovesSocket(t *testing.T) {
+	_, u := newUpgrader(t)
+	tpt, err := New(u, nil)
+	require.NoError(t, err)
+	addr := socketAddr(t, "s.sock")
+	path, err := parseAddr(addr)
+	require.NoError(t, err)
+
+	l, err := tpt.Listen(addr)
+	require.NoError(t, err)
+	_, err = tpt.Listen(addr)
+	require.Error(t, err, "the socket is in use")
+	require.NoError(t, l.Close())
+	_, err = os.Stat(path)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = tpt.Dial(context.Background(), addr, "")
+	require.Error(t, err)
+}
+
+func TestStaleSocket(t *testing.T) {
+	_, u := newUpgrader(t)
+	tpt, err := New(u, nil)
+	require.NoError(t, err)
+	addr := socketAddr(t, "s.sock")
+	path, err := parseAddr(addr)
+	require.NoError(t, err)
+
+	// Leave a socket behind, as a crashed process would.
+	nl, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	nl.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, nl.Close())
+	_, err = os.Stat(path)
+	require.NoError(t, err)
+
+	l, err := tpt.Listen(addr)
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+
+	// Other files are never replaced.
+	require.NoError(t, os.WriteFile(path, []byte("foo"), 0o600))
+	_, err = tpt.Listen(addr)
+	require.Error(t, err)
+}
+
+func TestFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes aren't supported on Windows")
+	}
+	_, err := New(nil, nil, WithFileMode(fs.ModeSocket|0o600))
+	require.Error(t, err)
+
+	_, u := newUpgrader(t)
+	tpt, err := New(u, nil, WithFileMode(0o600))
+	require.NoError(t, err)
+	addr := socketAddr(t, "s.sock")
+	l, err := tpt.Listen(addr)
+	require.NoError(t, err)
+	defer l.Close()
+	path, err := parseAddr(addr)
+	require.NoError(t, err)
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, fs.FileMode(0o600), fi.Mode().Perm())
+}
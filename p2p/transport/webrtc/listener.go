@@ -3,14 +3,13 @@ package libp2pwebrtc
 import (
 	"context"
 	"crypto"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"net"
-	"strings"
 	"sync"
 	"time"
 
+	"github.com/libp2p/go-libp2p/core/connmgr"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	tpt "github.com/libp2p/go-libp2p/core/transport"
@@ -18,8 +17,6 @@ import (
 
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
-	"github.com/multiformats/go-multibase"
-	"github.com/multiformats/go-multihash"
 	"github.com/pion/webrtc/v4"
 )
 
@@ -46,11 +43,8 @@ type listener struct {
 
 	mux *udpmux.UDPMux
 
-	config                    webrtc.Configuration
-	localFingerprint          webrtc.DTLSFingerprint
-	localFingerprintMultibase string
-
-	localAddr      net.Addr
+	localAddr net.Addr
+	// localMultiaddr is the listen address without the certhash
 	localMultiaddr ma.Multiaddr
 
 	// buffered incoming connections
@@ -64,33 +58,12 @@ type listener struct {
 
 var _ tpt.Listener = &listener{}
 
-func newListener(transport *WebRTCTransport, laddr ma.Multiaddr, socket net.PacketConn, config webrtc.Configuration) (*listener, error) {
-	localFingerprints, err := config.Certificates[0].GetFingerprints()
-	if err != nil {
-		return nil, err
-	}
-
-	localMh, err := hex.DecodeString(strings.ReplaceAll(localFingerprints[0].Value, ":", ""))
-	if err != nil {
-		return nil, err
-	}
-	localMhBuf, err := multihash.Encode(localMh, multihash.SHA2_256)
-	if err != nil {
-		return nil, err
-	}
-	localFpMultibase, err := multibase.Encode(multibase.Base64url, localMhBuf)
-	if err != nil {
-		return nil, err
-	}
-
+func newListener(transport *WebRTCTransport, laddr ma.Multiaddr, socket net.PacketConn) (*listener, error) {
 	l := &listener{
-		transport:                 transport,
-		config:                    config,
-		localFingerprint:          localFingerprints[0],
-		localFingerprintMultibase: localFpMultibase,
-		localMultiaddr:            laddr,
-		localAddr:                 socket.LocalAddr(),
-		acceptQueue:               make(chan tpt.CapableConn),
+		transport:      transport,
+		localMultiaddr: laddr,
+		localAddr:      socket.LocalAddr(),
+		acceptQueue:    make(chan tpt.CapableConn),
 	}
 
 	l.ctx, l.cancel = context.WithCancel(context.Background())
@@ -103,7 +76,7 @@ func newListener(transport *WebRTCTransport, laddr ma.Multiaddr, socket net.Pack
 		l.listen()
 	}()
 
-	return l, err
+	return l, nil
 }
 
 func (l *listener) listen() {
@@ -157,8 +130,7 @@ func (l *listener) handleCandidate(ctx context.Context, candidate udpmux.Candida
 		return nil, err
 	}
 	if l.transport.gater != nil {
-		localAddr, _ := ma.SplitFunc(l.localMultiaddr, func(c ma.Component) bool { return c.Protocol().Code == ma.P_CERTHASH })
-		if !l.transport.gater.InterceptAccept(&connMultiaddrs{local: localAddr, remote: remoteMultiaddr}) {
+		if !l.transport.gater.InterceptAccept(&connMultiaddrs{local: l.localMultiaddr, remote: remoteMultiaddr}) {
 			// The connection attempt is rejected before we can send the client an error.
 			// This means that the connection attempt will time out.
 			return nil, errors.New("connection gated")
@@ -173,9 +145,11 @@ func (l *listener) handleCandidate(ctx context.Context, candidate udpmux.Candida
 		scope.Done()
 		return nil, err
 	}
-	if l.transport.gater != nil && !l.transport.gater.InterceptSecured(network.DirInbound, conn.RemotePeer(), conn) {
-		conn.Close()
-		return nil, errors.New("connection gated")
+	if l.transport.gater != nil {
+		if err := connmgr.InterceptSecuredContext(ctx, l.transport.gater, network.DirInbound, conn.RemotePeer(), securedConnInfo(conn)); err != nil {
+			conn.Close()
+			return nil, err
+		}
 	}
 	return conn, nil
 }
@@ -218,7 +192,7 @@ func (l *listener) setupConnection(
 		return nil, err
 	}
 
-	w, err = newWebRTCConnection(settingEngine, l.config)
+	w, err = newWebRTCConnection(settingEngine, l.transport.cert.Load().config)
 	if err != nil {
 		return nil, fmt.Errorf("instantiating peer connection failed: %w", err)
 	}
@@ -268,14 +242,13 @@ func (l *listener) setupConnection(
 		return nil, err
 	}
 
-	localMultiaddrWithoutCerthash, _ := ma.SplitFunc(l.localMultiaddr, func(c ma.Component) bool { return c.Protocol().Code == ma.P_CERTHASH })
 	conn, err := newConnection(
 		network.DirInbound,
 		w.PeerConnection,
 		l.transport,
 		scope,
 		l.transport.localPeerId,
-		localMultiaddrWithoutCerthash,
+		l.localMultiaddr,
 		remotePeer,
 		remotePubKey,
 		remoteMultiaddr,
@@ -323,7 +296,8 @@ func (l *listener) Addr() net.Addr {
 }
 
 func (l *listener) Multiaddr() ma.Multiaddr {
-	return l.localMultiaddr
+	addr, _ := l.transport.AddCertHashes(l.localMultiaddr)
+	return addr
 }
 
 // addOnConnectionStateChangeCallback adds the OnConnectionStateChange to the PeerConnection.
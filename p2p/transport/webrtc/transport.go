@@ -12,6 +12,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sync/atomic"
 	"time"
 
 	mrand "math/rand/v2"
@@ -26,6 +27,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/sec"
 	tpt "github.com/libp2p/go-libp2p/core/transport"
 	"github.com/libp2p/go-libp2p/p2p/security/noise"
+	"github.com/libp2p/go-libp2p/p2p/transport/certhash"
 	libp2pquic "github.com/libp2p/go-libp2p/p2p/transport/quic"
 	"github.com/libp2p/go-libp2p/p2p/transport/webrtc/pb"
 	"github.com/libp2p/go-msgio"
@@ -78,12 +80,20 @@ const (
 )
 
 type WebRTCTransport struct {
-	webrtcConfig webrtc.Configuration
-	rcmgr        network.ResourceManager
-	gater        connmgr.ConnectionGater
-	privKey      ic.PrivKey
-	noiseTpt     *noise.Transport
-	localPeerId  peer.ID
+	rcmgr       network.ResourceManager
+	gater       connmgr.ConnectionGater
+	privKey     ic.PrivKey
+	noiseTpt    *noise.Transport
+	localPeerId peer.ID
+
+	// cert is the certificate presented to peers. It is replaced when
+	// certManager rotates certificates.
+	cert        atomic.Pointer[localCertificate]
+	certManager *certhash.Manager
+	// unsubscribeCerts stops following the certificate rotations of
+	// certManager.
+	unsubscribeCerts func()
+	listening        atomic.Bool // set to true once the transport started listening
 
 	listenUDP func(network string, laddr *net.UDPAddr) (net.PacketConn, error)
 
@@ -98,6 +108,45 @@ var _ tpt.Transport = &WebRTCTransport{}
 
 type Option func(*WebRTCTransport) error
 
+// WithCertManager makes the transport use the current certificate of m, instead
+// of a random certificate generated on startup. This allows sharing
+// certificates with other transports, e.g. WebTransport, so that they
+// advertise the same certificate hashes. Since a WebRTC Direct address only
+// has a single certificate hash, addresses advertised before the certificate
+// is rotated stop working once it is rotated.
+func WithCertManager(m *certhash.Manager) Option {
+	return func(t *WebRTCTransport) error {
+		t.certManager = m
+		return nil
+	}
+}
+
+// localCertificate is a certificate along with its fingerprint.
+type localCertificate struct {
+	config webrtc.Configuration
+	// certhash is the fingerprint as advertised in /certhash components.
+	certhash *ma.Component
+}
+
+func newLocalCertificate(cert webrtc.Certificate) (*localCertificate, error) {
+	fps, err := cert.GetFingerprints()
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := encodeDTLSFingerprint(fps[0])
+	if err != nil {
+		return nil, err
+	}
+	certComp, err := ma.NewComponent(ma.ProtocolWithCode(ma.P_CERTHASH).Name, encoded)
+	if err != nil {
+		return nil, err
+	}
+	return &localCertificate{
+		config:   webrtc.Configuration{Certificates: []webrtc.Certificate{cert}},
+		certhash: certComp,
+	}, nil
+}
+
 type iceTimeouts struct {
 	Disconnect time.Duration
 	Failed     time.Duration
@@ -118,6 +167,39 @@ func New(privKey ic.PrivKey, psk pnet.PSK, gater connmgr.ConnectionGater, rcmgr
 	if err != nil {
 		return nil, fmt.Errorf("get local peer ID: %w", err)
 	}
+	noiseTpt, err := noise.New(noise.ID, privKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create noise transport: %w", err)
+	}
+	transport := &WebRTCTransport{
+		rcmgr:       rcmgr,
+		gater:       gater,
+		privKey:     privKey,
+		noiseTpt:    noiseTpt,
+		localPeerId: localPeerID,
+
+		listenUDP: listenUDP,
+		peerConnectionTimeouts: iceTimeouts{
+			Disconnect: DefaultDisconnectedTimeout,
+			Failed:     DefaultFailedTimeout,
+			Keepalive:  DefaultKeepaliveTimeout,
+		},
+
+		maxInFlightConnections: DefaultMaxInFlightConnections,
+	}
+	for _, opt := range opts {
+		if err := opt(transport); err != nil {
+			return nil, err
+		}
+	}
+
+	if transport.certManager != nil {
+		if err := transport.useCertManager(transport.certManager); err != nil {
+			return nil, err
+		}
+		return transport, nil
+	}
+
 	// We use elliptic P-256 since it is widely supported by browsers.
 	//
 	// Implementation note: Testing with the browser,
@@ -137,36 +219,59 @@ func New(privKey ic.PrivKey, psk pnet.PSK, gater connmgr.ConnectionGater, rcmgr
 	if err != nil {
 		return nil, fmt.Errorf("generate certificate: %w", err)
 	}
-	config := webrtc.Configuration{
-		Certificates: []webrtc.Certificate{*cert},
-	}
-	noiseTpt, err := noise.New(noise.ID, privKey, nil)
+	lc, err := newLocalCertificate(*cert)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create noise transport: %w", err)
+		return nil, err
 	}
-	transport := &WebRTCTransport{
-		rcmgr:        rcmgr,
-		gater:        gater,
-		webrtcConfig: config,
-		privKey:      privKey,
-		noiseTpt:     noiseTpt,
-		localPeerId:  localPeerID,
+	transport.cert.Store(lc)
+	return transport, nil
+}
 
-		listenUDP: listenUDP,
-		peerConnectionTimeouts: iceTimeouts{
-			Disconnect: DefaultDisconnectedTimeout,
-			Failed:     DefaultFailedTimeout,
-			Keepalive:  DefaultKeepaliveTimeout,
-		},
+// SetCertManager makes the transport use the current certificate of m, unless
+// it already uses a certhash.Manager set with WithCertManager. It must be
+// called before the transport starts listening. libp2p.New uses it to share
+// certificates between transports when the ShareCertHashes option is set.
+func (t *WebRTCTransport) SetCertManager(m *certhash.Manager) error {
+	if t.listening.Load() {
+		return errors.New("cannot set the cert manager after listening")
+	}
+	if t.certManager != nil {
+		return nil
+	}
+	t.certManager = m
+	return t.useCertManager(m)
+}
 
-		maxInFlightConnections: DefaultMaxInFlightConnections,
+func (t *WebRTCTransport) useCertManager(m *certhash.Manager) error {
+	// The certificates of the certhash.Manager use ECDSA P-256 keys, which
+	// are supported by browsers.
+	if err := t.useCertificate(m.Current()); err != nil {
+		return err
 	}
-	for _, opt := range opts {
-		if err := opt(transport); err != nil {
-			return nil, err
+	t.unsubscribeCerts = m.Subscribe(func() {
+		if err := t.useCertificate(m.Current()); err != nil {
+			log.Errorw("failed to use rotated certificate", "error", err)
 		}
+	})
+	return nil
+}
+
+// Close stops following the certificate rotations of the certhash.Manager.
+// The manager itself isn't closed, as it may be shared with other transports.
+func (t *WebRTCTransport) Close() error {
+	if t.unsubscribeCerts != nil {
+		t.unsubscribeCerts()
 	}
-	return transport, nil
+	return nil
+}
+
+func (t *WebRTCTransport) useCertificate(cert *certhash.Certificate) error {
+	lc, err := newLocalCertificate(webrtc.CertificateFromX509(cert.PrivateKey, cert.Leaf))
+	if err != nil {
+		return err
+	}
+	t.cert.Store(lc)
+	return nil
 }
 
 func (t *WebRTCTransport) ListenOrder() int {
@@ -224,28 +329,13 @@ func (t *WebRTCTransport) listenSocket(socket net.PacketConn) (tpt.Listener, err
 	if err != nil {
 		return nil, err
 	}
+	listenerMultiaddr = listenerMultiaddr.AppendComponent(webrtcComponent)
 
-	listenerFingerprint, err := t.getCertificateFingerprint()
-	if err != nil {
-		return nil, err
-	}
-
-	encodedLocalFingerprint, err := encodeDTLSFingerprint(listenerFingerprint)
-	if err != nil {
-		return nil, err
-	}
-
-	certComp, err := ma.NewComponent(ma.ProtocolWithCode(ma.P_CERTHASH).Name, encodedLocalFingerprint)
-	if err != nil {
-		return nil, err
-	}
-	listenerMultiaddr = listenerMultiaddr.AppendComponent(webrtcComponent, certComp)
-
+	t.listening.Store(true)
 	return newListener(
 		t,
 		listenerMultiaddr,
 		socket,
-		t.webrtcConfig,
 	)
 }
 
@@ -329,7 +419,7 @@ func (t *WebRTCTransport) dial(ctx context.Context, scope network.ConnManagement
 		return nil, err
 	}
 
-	w, err = newWebRTCConnection(settingEngine, t.webrtcConfig)
+	w, err = newWebRTCConnection(settingEngine, t.cert.Load().config)
 	if err != nil {
 		return nil, fmt.Errorf("instantiating peer connection failed: %w", err)
 	}
@@ -412,12 +502,18 @@ func (t *WebRTCTransport) dial(ctx context.Context, scope network.ConnManagement
 		return nil, err
 	}
 
-	if t.gater != nil && !t.gater.InterceptSecured(network.DirOutbound, p, conn) {
-		return nil, fmt.Errorf("secured connection gated")
+	if t.gater != nil {
+		if err := connmgr.InterceptSecuredContext(ctx, t.gater, network.DirOutbound, p, securedConnInfo(conn)); err != nil {
+			return nil, err
+		}
 	}
 	return conn, nil
 }
 
+func securedConnInfo(c tpt.CapableConn) connmgr.SecuredConnInfo {
+	return connmgr.SecuredConnInfo{ConnMultiaddrs: c, Transport: c.ConnState().Transport}
+}
+
 func genUfrag() string {
 	const (
 		uFragAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890"
@@ -439,14 +535,6 @@ func genUfrag() string {
 	return string(b)
 }
 
-func (t *WebRTCTransport) getCertificateFingerprint() (webrtc.DTLSFingerprint, error) {
-	fps, err := t.webrtcConfig.Certificates[0].GetFingerprints()
-	if err != nil {
-		return webrtc.DTLSFingerprint{}, err
-	}
-	return fps[0], nil
-}
-
 func (t *WebRTCTransport) generateNoisePrologue(pc *webrtc.PeerConnection, hash crypto.Hash, inbound bool) ([]byte, error) {
 	raw := pc.SCTP().Transport().GetRemoteCertificate()
 	cert, err := x509.ParseCertificate(raw)
@@ -456,10 +544,17 @@ func (t *WebRTCTransport) generateNoisePrologue(pc *webrtc.PeerConnection, hash
 
 	// NOTE: should we want we can fork the cert code as well to avoid
 	// all the extra allocations due to unneeded string interspersing (hex)
-	localFp, err := t.getCertificateFingerprint()
+	//
+	// Use the fingerprint of the certificate this connection was established
+	// with, since the transport's certificate may have been rotated since.
+	localParams, err := pc.SCTP().Transport().GetLocalParameters()
 	if err != nil {
 		return nil, err
 	}
+	if len(localParams.Fingerprints) == 0 {
+		return nil, errors.New("no local certificate fingerprint")
+	}
+	localFp := localParams.Fingerprints[0]
 
 	remoteFpBytes, err := parseFingerprint(cert, hash)
 	if err != nil {
@@ -523,21 +618,7 @@ func (t *WebRTCTransport) noiseHandshake(ctx context.Context, pc *webrtc.PeerCon
 }
 
 func (t *WebRTCTransport) AddCertHashes(addr ma.Multiaddr) (ma.Multiaddr, bool) {
-	listenerFingerprint, err := t.getCertificateFingerprint()
-	if err != nil {
-		return nil, false
-	}
-
-	encodedLocalFingerprint, err := encodeDTLSFingerprint(listenerFingerprint)
-	if err != nil {
-		return nil, false
-	}
-
-	certComp, err := ma.NewComponent(ma.ProtocolWithCode(ma.P_CERTHASH).Name, encodedLocalFingerprint)
-	if err != nil {
-		return nil, false
-	}
-	return addr.AppendComponent(certComp), true
+	return addr.Encapsulate(t.cert.Load().certhash), true
 }
 
 type netConnWrapper struct {
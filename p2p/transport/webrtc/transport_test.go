@@ -19,6 +19,9 @@ import (
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	tpt "github.com/libp2p/go-libp2p/core/transport"
+	"github.com/libp2p/go-libp2p/p2p/transport/certhash"
+
+	"github.com/benbjohnson/clock"
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
 	"github.com/multiformats/go-multibase"
@@ -234,6 +237,92 @@ func WithListenerMaxInFlightConnections(m uint32) Option {
 	}
 }
 
+func TestTransportWebRTC_CertManagerRotation(t *testing.T) {
+	// pion refuses to use expired certificates
+	cl := clock.NewMock()
+	cl.Set(time.Now())
+	privKey, _, err := crypto.GenerateKeyPair(crypto.Ed25519, -1)
+	require.NoError(t, err)
+	m, err := certhash.NewManager(privKey, certhash.WithClock(cl))
+	require.NoError(t, err)
+	defer m.Close()
+
+	tr, listeningPeer := getTransport(t, WithCertManager(m))
+	tr1, _ := getTransport(t)
+	listener, err := tr.Listen(ma.StringCast("/ip4/127.0.0.1/udp/0/webrtc-direct"))
+	require.NoError(t, err)
+	defer listener.Close()
+
+	dial := func(addr ma.Multiaddr) {
+		t.Helper()
+		accepted := make(chan tpt.CapableConn, 1)
+		go func() {
+			conn, err := listener.Accept()
+			assert.NoError(t, err)
+			accepted <- conn
+		}()
+		conn, err := tr1.Dial(context.Background(), addr, listeningPeer)
+		require.NoError(t, err)
+		if c := <-accepted; c != nil {
+			c.Close()
+		}
+		conn.Close()
+	}
+	hashOf := func(addr ma.Multiaddr) []byte {
+		t.Helper()
+		v, err := addr.ValueForProtocol(ma.P_CERTHASH)
+		require.NoError(t, err)
+		_, b, err := multibase.Decode(v)
+		require.NoError(t, err)
+		mh, err := multihash.Decode(b)
+		require.NoError(t, err)
+		return mh.Digest
+	}
+
+	first := listener.Multiaddr()
+	require.Equal(t, m.Current().Hash[:], hashOf(first))
+	dial(first)
+
+	// rotate the certificates
+	_, _, next := m.Certificates()
+	cl.Set(m.Current().Leaf.NotAfter)
+	require.Eventually(t, func() bool {
+		return string(hashOf(listener.Multiaddr())) == string(next.Hash[:])
+	}, time.Second, 10*time.Millisecond)
+	dial(listener.Multiaddr())
+}
+
+func TestTransportWebRTC_SetCertManager(t *testing.T) {
+	cl := clock.NewMock()
+	cl.Set(time.Now())
+	privKey, _, err := crypto.GenerateKeyPair(crypto.Ed25519, -1)
+	require.NoError(t, err)
+	m, err := certhash.NewManager(privKey, certhash.WithClock(cl))
+	require.NoError(t, err)
+	defer m.Close()
+
+	tr, _ := getTransport(t)
+	require.NoError(t, tr.SetCertManager(m))
+	require.Equal(t, m.Current().Hash[:], tr.cert.Load().certhash.RawValue()[2:])
+	listener, err := tr.Listen(ma.StringCast("/ip4/127.0.0.1/udp/0/webrtc-direct"))
+	require.NoError(t, err)
+	defer listener.Close()
+	require.Error(t, tr.SetCertManager(m))
+
+	// once closed, the transport doesn't follow rotations anymore
+	cert := tr.cert.Load()
+	require.NoError(t, tr.Close())
+	rotated := make(chan struct{}, 1)
+	defer m.Subscribe(func() { rotated <- struct{}{} })()
+	cl.Set(m.Current().Leaf.NotAfter)
+	select {
+	case <-rotated:
+	case <-time.After(time.Second):
+		t.Fatal("certificates weren't rotated")
+	}
+	require.Never(t, func() bool { return tr.cert.Load() != cert }, 100*time.Millisecond, 10*time.Millisecond)
+}
+
 func TestTransportWebRTC_CanListenMultiple(t *testing.T) {
 	count := 3
 	tr, listeningPeer := getTransport(t, WithListenerMaxInFlightConnections(uint32(count)))
@@ -5,6 +5,7 @@ import (
 	"net"
 	"net/url"
 	"strconv"
+	"strings"
 
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
@@ -77,13 +78,14 @@ func ParseWebsocketNetAddr(a net.Addr) (ma.Multiaddr, error) {
 		return nil, fmt.Errorf("invalid port in url: '%q'", wsa.URL)
 	}
 
-	// NOTE: Ignoring IPv6 zones...
 	// Detect if host is IP address or DNS
-	if ip := net.ParseIP(host); ip != nil {
+	ipStr, zone, _ := strings.Cut(host, "%")
+	if ip := net.ParseIP(ipStr); ip != nil {
 		// Assume IP address
 		tcpma, err = manet.FromNetAddr(&net.TCPAddr{
 			IP:   ip,
 			Port: port,
+			Zone: zone,
 		})
 		if err != nil {
 			return nil, err
@@ -100,8 +102,16 @@ func ParseWebsocketNetAddr(a net.Addr) (ma.Multiaddr, error) {
 	if err != nil {
 		return nil, err
 	}
+	wsma = tcpma.Encapsulate(wsma)
 
-	return tcpma.Encapsulate(wsma), nil
+	if p := strings.TrimPrefix(wsa.Path, "/"); p != "" {
+		httpPath, err := ma.NewComponent("http-path", url.QueryEscape(p))
+		if err != nil {
+			return nil, err
+		}
+		wsma = wsma.AppendComponent(httpPath)
+	}
+	return wsma, nil
 }
 
 func parseMultiaddr(maddr ma.Multiaddr) (*url.URL, error) {
@@ -124,10 +134,14 @@ func parseMultiaddr(maddr ma.Multiaddr) (*url.URL, error) {
 	default:
 		return nil, fmt.Errorf("unsupported websocket network %s", network)
 	}
-	return &url.URL{
+	u := &url.URL{
 		Scheme: scheme,
 		Host:   host,
-	}, nil
+	}
+	if parsed.httpPath != nil {
+		u.Path = "/" + string(parsed.httpPath.RawValue())
+	}
+	return u, nil
 }
 
 type parsedWebsocketMultiaddr struct {
@@ -136,10 +150,23 @@ type parsedWebsocketMultiaddr struct {
 	sni *ma.Component
 	// the rest of the multiaddr before the /tls/sni/example.com/ws or /ws or /wss
 	restMultiaddr ma.Multiaddr
+	// httpPath is the URL path of the WebSocket endpoint, from a trailing
+	// /http-path component. nil means the root path.
+	httpPath *ma.Component
+}
+
+// splitHTTPPath splits a trailing /http-path component off a.
+func splitHTTPPath(a ma.Multiaddr) (ma.Multiaddr, *ma.Component) {
+	rest, last := ma.SplitLast(a)
+	if last == nil || last.Protocol().Code != ma.P_HTTP_PATH {
+		return a, nil
+	}
+	return rest, last
 }
 
 func parseWebsocketMultiaddr(a ma.Multiaddr) (parsedWebsocketMultiaddr, error) {
 	out := parsedWebsocketMultiaddr{}
+	a, out.httpPath = splitHTTPPath(a)
 	// First check if we have a WSS component. If so we'll canonicalize it into a /tls/ws
 	withoutWss := a.Decapsulate(wssComponent.Multiaddr())
 	if !withoutWss.Equal(a) {
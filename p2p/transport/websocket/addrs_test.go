@@ -22,6 +22,44 @@ func TestMultiaddrParsing(t *testing.T) {
 	}
 }
 
+func TestMultiaddrParsingHTTPPath(t *testing.T) {
+	addr := ma.StringCast("/dns4/example.com/tcp/443/tls/ws/http-path/foo%2Fbar")
+	wsaddr, err := parseMultiaddr(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wsaddr.String() != "wss://example.com:443/foo/bar" {
+		t.Fatalf("expected wss://example.com:443/foo/bar, got %s", wsaddr)
+	}
+
+	parsed, err := ParseWebsocketNetAddr(&Addr{URL: wsaddr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.String() != "/dns/example.com/tcp/443/wss/http-path/foo%2Fbar" {
+		t.Fatalf("expected \"/dns/example.com/tcp/443/wss/http-path/foo%%2Fbar\", got \"%s\"", parsed)
+	}
+}
+
+func TestMultiaddrParsingZone(t *testing.T) {
+	addr := ma.StringCast("/ip6zone/eth0/ip6/fe80::1/tcp/5555/ws")
+	wsaddr, err := parseMultiaddr(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wsaddr.String() != "ws://[fe80::1%25eth0]:5555" {
+		t.Fatalf("expected ws://[fe80::1%%25eth0]:5555, got %s", wsaddr)
+	}
+
+	parsed, err := ParseWebsocketNetAddr(&Addr{URL: wsaddr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.Equal(addr) {
+		t.Fatalf("expected %s, got %s", addr, parsed)
+	}
+}
+
 type httpAddr struct {
 	*url.URL
 }
@@ -1,6 +1,7 @@
 package websocket
 
 import (
+	"crypto/x509"
 	"errors"
 	"io"
 	"net"
@@ -28,12 +29,14 @@ type Conn struct {
 	closeOnceVal       func() error
 	laddr              ma.Multiaddr
 	raddr              ma.Multiaddr
+	tlsClientCerts     []*x509.Certificate
 
 	readLock, writeLock sync.Mutex
 }
 
 var _ net.Conn = (*Conn)(nil)
 var _ manet.Conn = (*Conn)(nil)
+var _ network.ConnTLSClientCerts = (*Conn)(nil)
 
 // newConn creates a Conn given a regular gorilla/websocket Conn.
 func newConn(raw *ws.Conn, secure bool, scope network.ConnManagementScope) *Conn {
@@ -63,6 +66,13 @@ func newConn(raw *ws.Conn, secure bool, scope network.ConnManagementScope) *Conn
 	return c
 }
 
+// TLSClientCertificates returns the certificates the client presented in the
+// TLS handshake of an accepted secure WebSocket connection, see
+// WithClientCertificates.
+func (c *Conn) TLSClientCertificates() []*x509.Certificate {
+	return c.tlsClientCerts
+}
+
 // LocalMultiaddr implements manet.Conn.
 func (c *Conn) LocalMultiaddr() ma.Multiaddr {
 	return c.laddr
@@ -0,0 +1,197 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	ws "github.com/gorilla/websocket"
+	"github.com/libp2p/go-libp2p/core/transport"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// WithHTTPHandlerRegistration serves the websocket listen address laddr
+// through an existing http.ServeMux instead of having the transport open its
+// own TCP listener. This allows sharing a port with other HTTP services. The
+// transport registers a handler for path on mux when the option is applied.
+// Once the host listens on laddr, WebSocket upgrade requests arriving through
+// the mux are accepted as libp2p connections. Until then, and after the
+// listener is closed, the handler responds with 404 Not Found.
+//
+// laddr is the address the listener announces, e.g. the public address of the
+// HTTP server. Use a /wss (or /tls/ws) address if the HTTP server terminates
+// TLS. Other nodes send their upgrade requests to the path given by the
+// /http-path component of laddr, or to the root path "/" if there is none, so
+// path should match it. The mux still routes requests for more specific
+// patterns to their handlers.
+func WithHTTPHandlerRegistration(laddr ma.Multiaddr, mux *http.ServeMux, path string) Option {
+	return func(t *WebsocketTransport) error {
+		parsed, err := parseWebsocketMultiaddr(laddr)
+		if err != nil {
+			return err
+		}
+		if _, err := manet.ToNetAddr(parsed.restMultiaddr); err != nil {
+			return fmt.Errorf("invalid websocket handler address %s: %w", laddr, err)
+		}
+		if t.handlerMounts == nil {
+			t.handlerMounts = make(map[string]*handlerMount)
+		}
+		key := string(laddr.Bytes())
+		if _, ok := t.handlerMounts[key]; ok {
+			return fmt.Errorf("handler for %s already registered", laddr)
+		}
+		m := &handlerMount{}
+		mux.Handle(path, m)
+		t.handlerMounts[key] = m
+		return nil
+	}
+}
+
+// handlerMount is the http.Handler registered on a user-provided ServeMux. It
+// dispatches requests to the listener for the address, if any.
+type handlerMount struct {
+	mx sync.Mutex
+	l  *handlerListener
+}
+
+func (m *handlerMount) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mx.Lock()
+	l := m.l
+	m.mx.Unlock()
+	if l == nil {
+		http.NotFound(w, r)
+		return
+	}
+	l.ServeHTTP(w, r)
+}
+
+func (m *handlerMount) listen(l *handlerListener) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.l != nil {
+		return errors.New("already listening")
+	}
+	m.l = l
+	return nil
+}
+
+func (m *handlerMount) unlisten(l *handlerListener) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.l == l {
+		m.l = nil
+	}
+}
+
+// handlerListener is a manet.Listener accepting websocket connections that
+// were upgraded by an http.Handler, rather than by a server owned by the
+// listener.
+type handlerListener struct {
+	mount      *handlerMount
+	laddr      ma.Multiaddr
+	addr       net.Addr
+	wsUpgrader ws.Upgrader
+	// compressionLevel is used if wsUpgrader.EnableCompression is set.
+	compressionLevel int
+	// connFilter, if set, is called before upgrading a request.
+	connFilter func(*http.Request) error
+
+	incoming  chan *Conn
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+var _ manet.Listener = &handlerListener{}
+
+func newHandlerListener(m *handlerMount, laddr ma.Multiaddr, t *WebsocketTransport) (*handlerListener, error) {
+	wsurl, err := parseMultiaddr(laddr)
+	if err != nil {
+		return nil, err
+	}
+	l := &handlerListener{
+		mount:    m,
+		laddr:    laddr,
+		addr:     &Addr{URL: wsurl},
+		incoming: make(chan *Conn),
+		closed:   make(chan struct{}),
+		wsUpgrader: ws.Upgrader{
+			// Allow requests from *all* origins.
+			CheckOrigin: func(_ *http.Request) bool {
+				return true
+			},
+			HandshakeTimeout:  t.handshakeTimeout,
+			EnableCompression: t.compression,
+		},
+		compressionLevel: t.compressionLevel,
+		connFilter:       t.connFilter,
+	}
+	if err := m.listen(l); err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", laddr, err)
+	}
+	return l, nil
+}
+
+func (l *handlerListener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-l.closed:
+		http.NotFound(w, r)
+		return
+	default:
+	}
+
+	if !filterRequest(l.connFilter, w, r) {
+		return
+	}
+	c, err := l.wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader writes a response for us.
+		return
+	}
+	if l.wsUpgrader.EnableCompression {
+		c.SetCompressionLevel(l.compressionLevel)
+	}
+	// The scope is attached by the gated listener wrapping this listener.
+	conn := newConn(c, r.TLS != nil, nil)
+	if conn == nil {
+		c.Close()
+		return
+	}
+	if r.TLS != nil {
+		conn.tlsClientCerts = r.TLS.PeerCertificates
+	}
+
+	select {
+	case l.incoming <- conn:
+	case <-l.closed:
+		conn.Close()
+	}
+	// The connection has been hijacked, it's safe to return.
+}
+
+func (l *handlerListener) Accept() (manet.Conn, error) {
+	select {
+	case c := <-l.incoming:
+		return c, nil
+	case <-l.closed:
+		return nil, transport.ErrListenerClosed
+	}
+}
+
+func (l *handlerListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		l.mount.unlisten(l)
+	})
+	return nil
+}
+
+func (l *handlerListener) Addr() net.Addr {
+	return l.addr
+}
+
+func (l *handlerListener) Multiaddr() ma.Multiaddr {
+	return l.laddr
+}
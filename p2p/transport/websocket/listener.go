@@ -31,6 +31,10 @@ type listener struct {
 	netListener *httpNetListener
 	server      http.Server
 	wsUpgrader  ws.Upgrader
+	// compressionLevel is used if wsUpgrader.EnableCompression is set.
+	compressionLevel int
+	// connFilter, if set, is called before upgrading a request.
+	connFilter func(*http.Request) error
 	// The Go standard library sets the http.Server.TLSConfig no matter if this is a WS or WSS,
 	// so we can't rely on checking if server.TLSConfig is set.
 	isWss bool
@@ -48,15 +52,19 @@ type listener struct {
 var _ transport.GatedMaListener = &listener{}
 
 func (pwma *parsedWebsocketMultiaddr) toMultiaddr() ma.Multiaddr {
-	if !pwma.isWSS {
-		return pwma.restMultiaddr.AppendComponent(wsComponent)
+	var a ma.Multiaddr
+	switch {
+	case !pwma.isWSS:
+		a = pwma.restMultiaddr.AppendComponent(wsComponent)
+	case pwma.sni == nil:
+		a = pwma.restMultiaddr.AppendComponent(tlsComponent, wsComponent)
+	default:
+		a = pwma.restMultiaddr.AppendComponent(tlsComponent, pwma.sni, wsComponent)
 	}
-
-	if pwma.sni == nil {
-		return pwma.restMultiaddr.AppendComponent(tlsComponent, wsComponent)
+	if pwma.httpPath != nil {
+		a = a.AppendComponent(pwma.httpPath)
 	}
-
-	return pwma.restMultiaddr.AppendComponent(tlsComponent, pwma.sni, wsComponent)
+	return a
 }
 
 // newListener creates a new listener from a raw net.Listener.
@@ -170,11 +178,22 @@ func (l *listener) extractConnFromContext(ctx context.Context) (*negotiatingConn
 }
 
 func (l *listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Listeners without an /http-path accept upgrade requests on any path.
+	if l.wsurl.Path != "" && r.URL.Path != l.wsurl.Path {
+		http.NotFound(w, r)
+		return
+	}
+	if !filterRequest(l.connFilter, w, r) {
+		return
+	}
 	c, err := l.wsUpgrader.Upgrade(w, r, nil)
 	if err != nil {
 		// The upgrader writes a response for us.
 		return
 	}
+	if l.wsUpgrader.EnableCompression {
+		c.SetCompressionLevel(l.compressionLevel)
+	}
 	nc, err := l.extractConnFromContext(r.Context())
 	if err != nil {
 		c.Close()
@@ -197,6 +216,9 @@ func (l *listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(500)
 		return
 	}
+	if r.TLS != nil {
+		conn.tlsClientCerts = r.TLS.PeerCertificates
+	}
 
 	select {
 	case l.incoming <- conn:
@@ -307,3 +329,18 @@ func (c *negotiatingConn) Unwrap() (connWithScope, error) {
 	}
 	return c.connWithScope, nil
 }
+
+// filterRequest applies the connection filter to a handshake request. It
+// returns false, after responding with 403 Forbidden, if the request was
+// rejected.
+func filterRequest(filter func(*http.Request) error, w http.ResponseWriter, r *http.Request) bool {
+	if filter == nil {
+		return true
+	}
+	if err := filter(r); err != nil {
+		log.Debugf("rejected websocket connection from %s: %s", r.RemoteAddr, err)
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return false
+	}
+	return true
+}
@@ -2,9 +2,18 @@
 package websocket
 
 import (
+	"compress/flate"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/network"
@@ -17,13 +26,14 @@ import (
 	manet "github.com/multiformats/go-multiaddr/net"
 
 	ws "github.com/gorilla/websocket"
+	"golang.org/x/net/http/httpproxy"
 )
 
 // WsFmt is multiaddr formatter for WsProtocol
 var WsFmt = mafmt.And(mafmt.TCP, mafmt.Base(ma.P_WS))
 
 var dialMatcher = mafmt.And(
-	mafmt.Or(mafmt.IP, mafmt.DNS),
+	mafmt.Or(mafmt.IP, mafmt.And(mafmt.Base(ma.P_IP6ZONE), mafmt.Base(ma.P_IP6)), mafmt.DNS),
 	mafmt.Base(ma.P_TCP),
 	mafmt.Or(
 		mafmt.Base(ma.P_WS),
@@ -72,6 +82,139 @@ func WithTLSConfig(conf *tls.Config) Option {
 	}
 }
 
+// WithClientCertificates makes secure WebSocket listeners request a TLS
+// certificate from clients, as set by auth, verifying it against clientCAs if
+// auth requires verification. The certificates presented by a client are
+// passed to the connection gater in connmgr.SecuredConnInfo, and are part of
+// the ConnectionState of the connection. This allows mTLS-style perimeter
+// policies on top of libp2p's own security.
+//
+// It overrides the ClientAuth and ClientCAs of the config set with
+// WithTLSConfig. For listeners registered with WithHTTPHandlerRegistration,
+// the http.Server of the application decides whether certificates are
+// requested, and the certificates are captured if they are.
+func WithClientCertificates(auth tls.ClientAuthType, clientCAs *x509.CertPool) Option {
+	return func(t *WebsocketTransport) error {
+		if auth == tls.NoClientCert {
+			return errors.New("client auth type doesn't request certificates")
+		}
+		if clientCAs == nil && (auth == tls.VerifyClientCertIfGiven || auth == tls.RequireAndVerifyClientCert) {
+			return errors.New("verifying client certificates requires a CA pool")
+		}
+		t.clientAuth = auth
+		t.clientCAs = clientCAs
+		return nil
+	}
+}
+
+// WithDialHeaders sets HTTP headers that are sent with every WebSocket
+// handshake request made when dialing, e.g. an Authorization header required
+// by a gateway. Headers managed by the WebSocket handshake itself (Upgrade,
+// Connection, Sec-Websocket-*) can't be set.
+func WithDialHeaders(h http.Header) Option {
+	return func(t *WebsocketTransport) error {
+		for k := range h {
+			switch k := http.CanonicalHeaderKey(k); {
+			case k == "Upgrade", k == "Connection", strings.HasPrefix(k, "Sec-Websocket-"):
+				return fmt.Errorf("header %s is set by the websocket handshake", k)
+			}
+		}
+		t.dialHeaders = h.Clone()
+		return nil
+	}
+}
+
+// WithCompression enables permessage-deflate compression (RFC 7692) with the
+// given compress/flate level, from flate.HuffmanOnly to flate.BestCompression.
+// Compression is offered when dialing and accepted when listening. It's only
+// used on connections where the other side supports it as well, so it's safe
+// to enable for nodes talking to peers that don't.
+//
+// Note that libp2p secures connections on top of the WebSocket layer, so the
+// compressor usually sees ciphertext, which doesn't compress. Compression only
+// saves bandwidth if the payload isn't encrypted by libp2p, e.g. when using
+// the insecure security transport.
+func WithCompression(level int) Option {
+	return func(t *WebsocketTransport) error {
+		if level < flate.HuffmanOnly || level > flate.BestCompression {
+			return fmt.Errorf("invalid compression level: %d", level)
+		}
+		t.compression = true
+		t.compressionLevel = level
+		return nil
+	}
+}
+
+// WithProxy sets the function that returns the proxy to use for a dial. The
+// request passed to proxy is the WebSocket handshake request, with an http or
+// https URL for ws and wss addresses respectively. If proxy returns a nil URL,
+// no proxy is used. HTTP, HTTPS and SOCKS5 proxies are supported.
+//
+// Defaults to ProxyFromEnvironment. Use http.ProxyURL to use a fixed proxy,
+// and a nil proxy function to disable proxies.
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) Option {
+	return func(t *WebsocketTransport) error {
+		t.proxy = proxy
+		return nil
+	}
+}
+
+// ProxyFromEnvironment returns the proxy for a WebSocket handshake request
+// based on the HTTPS_PROXY, HTTP_PROXY, ALL_PROXY and NO_PROXY environment
+// variables (or their lowercase versions). Dials to wss addresses use
+// HTTPS_PROXY, dials to ws addresses use HTTP_PROXY. ALL_PROXY is used if the
+// respective variable is not set. See http.ProxyFromEnvironment for details.
+//
+// As with http.ProxyFromEnvironment, the environment is read once, on first
+// use.
+func ProxyFromEnvironment(req *http.Request) (*url.URL, error) {
+	return envProxyFunc()(req.URL)
+}
+
+var envProxyFunc = sync.OnceValue(func() func(*url.URL) (*url.URL, error) {
+	return proxyFuncFromEnv(os.Getenv)
+})
+
+func proxyFuncFromEnv(getenv func(string) string) func(*url.URL) (*url.URL, error) {
+	get := func(names ...string) string {
+		for _, n := range names {
+			if v := getenv(n); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	all := get("ALL_PROXY", "all_proxy")
+	cfg := &httpproxy.Config{
+		HTTPProxy:  get("HTTP_PROXY", "http_proxy"),
+		HTTPSProxy: get("HTTPS_PROXY", "https_proxy"),
+		NoProxy:    get("NO_PROXY", "no_proxy"),
+	}
+	if cfg.HTTPProxy == "" {
+		cfg.HTTPProxy = all
+	}
+	if cfg.HTTPSProxy == "" {
+		cfg.HTTPSProxy = all
+	}
+	return cfg.ProxyFunc()
+}
+
+// WithConnectionFilter sets a function that is called for every incoming
+// WebSocket handshake request, before the connection is upgraded. If filter
+// returns an error, the request is rejected with 403 Forbidden, and no libp2p
+// handshake is attempted.
+//
+// The request gives access to the remote address (r.RemoteAddr), the headers,
+// e.g. Origin, and the URL path. This allows rejecting connections from banned
+// networks or enforcing an Origin allow-list cheaply. Note that when behind a
+// reverse proxy, r.RemoteAddr is the address of the proxy.
+func WithConnectionFilter(filter func(r *http.Request) error) Option {
+	return func(t *WebsocketTransport) error {
+		t.connFilter = filter
+		return nil
+	}
+}
+
 var defaultHandshakeTimeout = 15 * time.Second
 
 // WithHandshakeTimeout sets a timeout for the websocket upgrade.
@@ -88,8 +231,18 @@ type WebsocketTransport struct {
 	rcmgr            network.ResourceManager
 	tlsClientConf    *tls.Config
 	tlsConf          *tls.Config
+	clientAuth       tls.ClientAuthType
+	clientCAs        *x509.CertPool
 	sharedTcp        *tcpreuse.ConnMgr
 	handshakeTimeout time.Duration
+	dialHeaders      http.Header
+	proxy            func(*http.Request) (*url.URL, error)
+	compression      bool
+	compressionLevel int
+	connFilter       func(*http.Request) error
+	// handlerMounts are the handlers registered on user-provided ServeMuxes,
+	// keyed by the bytes of their listen address.
+	handlerMounts map[string]*handlerMount
 }
 
 var _ transport.Transport = (*WebsocketTransport)(nil)
@@ -104,6 +257,7 @@ func New(u transport.Upgrader, rcmgr network.ResourceManager, sharedTCP *tcpreus
 		tlsClientConf:    &tls.Config{},
 		sharedTcp:        sharedTCP,
 		handshakeTimeout: defaultHandshakeTimeout,
+		proxy:            ProxyFromEnvironment,
 	}
 	for _, opt := range opts {
 		if err := opt(t); err != nil {
@@ -114,6 +268,7 @@ func New(u transport.Upgrader, rcmgr network.ResourceManager, sharedTCP *tcpreus
 }
 
 func (t *WebsocketTransport) CanDial(a ma.Multiaddr) bool {
+	a, _ = splitHTTPPath(a)
 	return dialMatcher.Matches(a)
 }
 
@@ -121,6 +276,11 @@ func (t *WebsocketTransport) Protocols() []int {
 	return []int{ma.P_WS, ma.P_WSS}
 }
 
+// TransportName returns the name of the transport in network.ConnectionState.
+func (t *WebsocketTransport) TransportName() string {
+	return "websocket"
+}
+
 func (t *WebsocketTransport) Proxy() bool {
 	return false
 }
@@ -195,9 +355,9 @@ func (t *WebsocketTransport) maDial(ctx context.Context, raddr ma.Multiaddr, sco
 	}
 	isWss := wsurl.Scheme == "wss"
 	dialer := ws.Dialer{
-		HandshakeTimeout: t.handshakeTimeout,
-		// Inherit the default proxy behavior
-		Proxy: ws.DefaultDialer.Proxy,
+		HandshakeTimeout:  t.handshakeTimeout,
+		Proxy:             t.proxy,
+		EnableCompression: t.compression,
 	}
 	if isWss {
 		sni := ""
@@ -232,10 +392,14 @@ func (t *WebsocketTransport) maDial(ctx context.Context, raddr ma.Multiaddr, sco
 		}
 	}
 
-	wscon, _, err := dialer.DialContext(ctx, wsurl.String(), nil)
+	wscon, _, err := dialer.DialContext(ctx, wsurl.String(), t.dialHeaders.Clone())
 	if err != nil {
 		return nil, err
 	}
+	if t.compression {
+		// Only takes effect if the server accepted compression.
+		wscon.SetCompressionLevel(t.compressionLevel)
+	}
 
 	mnc, err := manet.WrapNetConn(newConn(wscon, isWss, scope))
 	if err != nil {
@@ -246,14 +410,29 @@ func (t *WebsocketTransport) maDial(ctx context.Context, raddr ma.Multiaddr, sco
 }
 
 func (t *WebsocketTransport) gatedMaListen(a ma.Multiaddr) (transport.GatedMaListener, error) {
+	if m, ok := t.handlerMounts[string(a.Bytes())]; ok {
+		l, err := newHandlerListener(m, a, t)
+		if err != nil {
+			return nil, err
+		}
+		return t.upgrader.GateMaListener(l), nil
+	}
+
 	var tlsConf *tls.Config
 	if t.tlsConf != nil {
 		tlsConf = t.tlsConf.Clone()
+		if t.clientAuth != tls.NoClientCert {
+			tlsConf.ClientAuth = t.clientAuth
+			tlsConf.ClientCAs = t.clientCAs
+		}
 	}
 	l, err := newListener(a, tlsConf, t.sharedTcp, t.upgrader, t.handshakeTimeout)
 	if err != nil {
 		return nil, err
 	}
+	l.wsUpgrader.EnableCompression = t.compression
+	l.compressionLevel = t.compressionLevel
+	l.connFilter = t.connFilter
 	go l.serve()
 	return l, nil
 }
@@ -2,6 +2,7 @@ package websocket
 
 import (
 	"bytes"
+	"compress/flate"
 	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
@@ -16,12 +17,16 @@ import (
 	"math/big"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	gws "github.com/gorilla/websocket"
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/control"
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -35,6 +40,7 @@ import (
 	ttransport "github.com/libp2p/go-libp2p/p2p/transport/testsuite"
 
 	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -142,6 +148,18 @@ func TestCanDial(t *testing.T) {
 	if !d.CanDial(ma.StringCast("/dnsaddr/example.com/tcp/5555/tls/sni/example.com/ws")) {
 		t.Fatal("expected to match secure websocket maddr with sni, but did not")
 	}
+	if !d.CanDial(ma.StringCast("/dns4/example.com/tcp/443/tls/ws/http-path/foo")) {
+		t.Fatal("expected to match secure websocket maddr with http path, but did not")
+	}
+	if !d.CanDial(ma.StringCast("/ip4/127.0.0.1/tcp/5555/ws/http-path/foo")) {
+		t.Fatal("expected to match websocket maddr with http path, but did not")
+	}
+	if d.CanDial(ma.StringCast("/ip4/127.0.0.1/tcp/5555/http-path/foo")) {
+		t.Fatal("expected to not match tcp maddr with http path, but did")
+	}
+	if !d.CanDial(ma.StringCast("/ip6zone/eth0/ip6/fe80::1/tcp/5555/ws")) {
+		t.Fatal("expected to match link-local websocket maddr with zone, but did not")
+	}
 }
 
 // testWSSServer returns a client hello info
@@ -254,6 +272,226 @@ func TestHostHeaderWss(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestDialHeaders(t *testing.T) {
+	server := &http.Server{}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer server.Close()
+
+	headers := make(chan http.Header, 1)
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	go server.Serve(l)
+
+	_, port, err := net.SplitHostPort(l.Addr().String())
+	require.NoError(t, err)
+	serverMA := ma.StringCast("/ip4/127.0.0.1/tcp/" + port + "/ws")
+
+	_, u := newUpgrader(t)
+	tpt, err := New(u, &network.NullResourceManager{}, nil, WithDialHeaders(http.Header{
+		"Authorization": []string{"Bearer secret"},
+		"User-Agent":    []string{"test-agent"},
+	}))
+	require.NoError(t, err)
+
+	_, err = tpt.Dial(context.Background(), serverMA, test.RandPeerIDFatal(t))
+	require.Error(t, err)
+
+	h := <-headers
+	require.Equal(t, "Bearer secret", h.Get("Authorization"))
+	require.Equal(t, "test-agent", h.Get("User-Agent"))
+	require.Equal(t, "websocket", h.Get("Upgrade"))
+
+	_, err = New(u, &network.NullResourceManager{}, nil, WithDialHeaders(http.Header{"Sec-WebSocket-Key": []string{"foo"}}))
+	require.Error(t, err)
+}
+
+func TestCompression(t *testing.T) {
+	for _, addr := range []string{"/ip4/127.0.0.1/tcp/0/ws", "/ip4/127.0.0.1/tcp/0/tls/ws"} {
+		t.Run(addr, func(t *testing.T) {
+			tlsConf := generateTLSConfig(t)
+			serverID, us := newUpgrader(t)
+			server, err := New(us, nil, nil, WithCompression(flate.BestSpeed), WithTLSConfig(tlsConf))
+			require.NoError(t, err)
+			_, uc := newUpgrader(t)
+			client, err := New(uc, nil, nil, WithCompression(flate.BestCompression), WithTLSClientConfig(&tls.Config{InsecureSkipVerify: true}))
+			require.NoError(t, err)
+
+			l, err := server.Listen(ma.StringCast(addr))
+			require.NoError(t, err)
+			defer l.Close()
+
+			done := make(chan struct{})
+			defer close(done)
+			go func() {
+				c, err := l.Accept()
+				if !assert.NoError(t, err) {
+					return
+				}
+				defer c.Close()
+				defer func() { <-done }()
+				str, err := c.AcceptStream()
+				if !assert.NoError(t, err) {
+					return
+				}
+				defer str.Close()
+				io.Copy(str, str)
+			}()
+
+			c, err := client.Dial(context.Background(), l.Multiaddr(), serverID)
+			require.NoError(t, err)
+			defer c.Close()
+			str, err := c.OpenStream(context.Background())
+			require.NoError(t, err)
+			msg := bytes.Repeat([]byte(`{"topic":"foo","data":"bar"}`), 1000)
+			_, err = str.Write(msg)
+			require.NoError(t, err)
+			require.NoError(t, str.CloseWrite())
+			b, err := io.ReadAll(str)
+			require.NoError(t, err)
+			require.Equal(t, msg, b)
+		})
+	}
+
+	t.Run("invalid level", func(t *testing.T) {
+		_, u := newUpgrader(t)
+		_, err := New(u, nil, nil, WithCompression(flate.BestCompression+1))
+		require.Error(t, err)
+		_, err = New(u, nil, nil, WithCompression(flate.HuffmanOnly-1))
+		require.Error(t, err)
+	})
+}
+
+func TestCompressionNegotiation(t *testing.T) {
+	listen := func(t *testing.T, opts ...Option) ma.Multiaddr {
+		_, u := newUpgrader(t)
+		tpt, err := New(u, nil, nil, opts...)
+		require.NoError(t, err)
+		l, err := tpt.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0/ws"))
+		require.NoError(t, err)
+		t.Cleanup(func() { l.Close() })
+		return l.Multiaddr()
+	}
+	extensions := func(t *testing.T, addr ma.Multiaddr) string {
+		wsurl, err := parseMultiaddr(addr)
+		require.NoError(t, err)
+		c, resp, err := (&gws.Dialer{EnableCompression: true}).Dial(wsurl.String(), nil)
+		require.NoError(t, err)
+		defer c.Close()
+		return resp.Header.Get("Sec-Websocket-Extensions")
+	}
+
+	t.Run("listener", func(t *testing.T) {
+		require.Contains(t, extensions(t, listen(t, WithCompression(flate.DefaultCompression))), "permessage-deflate")
+		require.Empty(t, extensions(t, listen(t)))
+	})
+
+	t.Run("dialer", func(t *testing.T) {
+		server := &http.Server{}
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer server.Close()
+
+		headers := make(chan http.Header, 2)
+		server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			headers <- r.Header
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+		go server.Serve(l)
+
+		_, port, err := net.SplitHostPort(l.Addr().String())
+		require.NoError(t, err)
+		serverMA := ma.StringCast("/ip4/127.0.0.1/tcp/" + port + "/ws")
+
+		_, u := newUpgrader(t)
+		tpt, err := New(u, nil, nil, WithCompression(flate.DefaultCompression))
+		require.NoError(t, err)
+		_, err = tpt.Dial(context.Background(), serverMA, test.RandPeerIDFatal(t))
+		require.Error(t, err)
+		require.Contains(t, (<-headers).Get("Sec-Websocket-Extensions"), "permessage-deflate")
+
+		tpt, err = New(u, nil, nil)
+		require.NoError(t, err)
+		_, err = tpt.Dial(context.Background(), serverMA, test.RandPeerIDFatal(t))
+		require.Error(t, err)
+		require.Empty(t, (<-headers).Get("Sec-Websocket-Extensions"))
+	})
+}
+
+func TestConnectionFilter(t *testing.T) {
+	var remoteAddrs []string
+	var mx sync.Mutex
+	filter := func(r *http.Request) error {
+		mx.Lock()
+		remoteAddrs = append(remoteAddrs, r.RemoteAddr)
+		mx.Unlock()
+		if origin := r.Header.Get("Origin"); origin != "https://allowed.example" {
+			return fmt.Errorf("origin %q not allowed", origin)
+		}
+		return nil
+	}
+	handshake := func(t *testing.T, addr ma.Multiaddr, origin string) int {
+		wsurl, err := parseMultiaddr(addr)
+		require.NoError(t, err)
+		c, resp, err := gws.DefaultDialer.Dial(wsurl.String(), http.Header{"Origin": []string{origin}})
+		if err == nil {
+			c.Close()
+		}
+		require.NotNil(t, resp)
+		return resp.StatusCode
+	}
+	check := func(t *testing.T, addr ma.Multiaddr) {
+		mx.Lock()
+		remoteAddrs = nil
+		mx.Unlock()
+		require.Equal(t, http.StatusForbidden, handshake(t, addr, "https://evil.example"))
+		require.Equal(t, http.StatusSwitchingProtocols, handshake(t, addr, "https://allowed.example"))
+		mx.Lock()
+		defer mx.Unlock()
+		require.Len(t, remoteAddrs, 2)
+		for _, a := range remoteAddrs {
+			host, _, err := net.SplitHostPort(a)
+			require.NoError(t, err)
+			require.Equal(t, "127.0.0.1", host)
+		}
+	}
+
+	t.Run("listener", func(t *testing.T) {
+		_, u := newUpgrader(t)
+		tpt, err := New(u, &network.NullResourceManager{}, nil, WithConnectionFilter(filter))
+		require.NoError(t, err)
+		l, err := tpt.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0/ws"))
+		require.NoError(t, err)
+		defer l.Close()
+		check(t, l.Multiaddr())
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		nl, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		laddr, err := manet.FromNetAddr(nl.Addr())
+		require.NoError(t, err)
+		laddr = laddr.AppendComponent(wsComponent)
+		mux := http.NewServeMux()
+		server := &http.Server{Handler: mux}
+		go server.Serve(nl)
+		defer server.Close()
+
+		_, u := newUpgrader(t)
+		tpt, err := New(u, &network.NullResourceManager{}, nil,
+			WithHTTPHandlerRegistration(laddr, mux, "/"),
+			WithConnectionFilter(filter),
+		)
+		require.NoError(t, err)
+		l, err := tpt.Listen(laddr)
+		require.NoError(t, err)
+		defer l.Close()
+		check(t, laddr)
+	})
+}
+
 func TestDialWss(t *testing.T) {
 	serverMA, rid, errChan := testWSSServer(t, ma.StringCast("/ip4/127.0.0.1/tcp/0/tls/sni/example.com/ws"))
 	require.Contains(t, serverMA.String(), "tls")
@@ -387,6 +625,9 @@ func connectAndExchangeData(t *testing.T, laddr ma.Multiaddr, secure bool) {
 	defer c.Close()
 	require.Equal(t, secure, isWSS(c.LocalMultiaddr()))
 	require.Equal(t, secure, isWSS(c.RemoteMultiaddr()))
+	// zones are preserved
+	require.Equal(t, laddr[0].Code(), c.LocalMultiaddr()[0].Code())
+	require.Equal(t, laddr[0].Code(), c.RemoteMultiaddr()[0].Code())
 	str, err := c.AcceptStream()
 	require.NoError(t, err)
 	defer str.Close()
@@ -403,6 +644,43 @@ func TestWebsocketConnection(t *testing.T) {
 	t.Run("encrypted", func(t *testing.T) {
 		connectAndExchangeData(t, ma.StringCast("/ip4/127.0.0.1/tcp/0/wss"), true)
 	})
+	t.Run("link-local", func(t *testing.T) {
+		connectAndExchangeData(t, test.LinkLocalAddr(t).Encapsulate(ma.StringCast("/tcp/0/ws")), false)
+	})
+}
+
+func TestHTTPPath(t *testing.T) {
+	server, u := newUpgrader(t)
+	tpt, err := New(u, &network.NullResourceManager{}, nil)
+	require.NoError(t, err)
+	l, err := tpt.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0/ws/http-path/libp2p%2Fws"))
+	require.NoError(t, err)
+	defer l.Close()
+	_, last := ma.SplitLast(l.Multiaddr())
+	require.Equal(t, "/http-path/libp2p%2Fws", last.String())
+	require.Regexp(t, `^ws://127\.0\.0\.1:[\d]+/libp2p/ws$`, l.Addr().String())
+
+	// Upgrade requests for other paths are rejected.
+	wsurl, err := parseMultiaddr(l.Multiaddr())
+	require.NoError(t, err)
+	wsurl.Path = "/"
+	_, resp, err := gws.DefaultDialer.Dial(wsurl.String(), nil)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	go func() {
+		_, u := newUpgrader(t)
+		tpt, err := New(u, &network.NullResourceManager{}, nil)
+		require.NoError(t, err)
+		c, err := tpt.Dial(context.Background(), l.Multiaddr(), server)
+		require.NoError(t, err)
+		require.Equal(t, server, c.RemotePeer())
+		c.Close()
+	}()
+	c, err := l.Accept()
+	require.NoError(t, err)
+	c.Close()
 }
 
 func TestWebsocketListenSecureFailWithoutTLSConfig(t *testing.T) {
@@ -459,6 +737,84 @@ func TestWebsocketListenSecureAndInsecure(t *testing.T) {
 	})
 }
 
+func TestHTTPHandlerRegistration(t *testing.T) {
+	nl, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	laddr, err := manet.FromNetAddr(nl.Addr())
+	require.NoError(t, err)
+	laddr = laddr.AppendComponent(wsComponent)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("api"))
+	})
+	server := &http.Server{Handler: mux}
+	go server.Serve(nl)
+	defer server.Close()
+
+	serverID, u := newUpgrader(t)
+	tpt, err := New(u, &network.NullResourceManager{}, nil, WithHTTPHandlerRegistration(laddr, mux, "/"))
+	require.NoError(t, err)
+
+	// Not listening yet.
+	resp, err := http.Get("http://" + nl.Addr().String() + "/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	l, err := tpt.Listen(laddr)
+	require.NoError(t, err)
+	require.True(t, l.Multiaddr().Equal(laddr))
+	_, err = tpt.Listen(laddr)
+	require.Error(t, err)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		c, err := l.Accept()
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer c.Close()
+		defer func() { <-done }()
+		str, err := c.AcceptStream()
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer str.Close()
+		io.Copy(str, str)
+	}()
+
+	_, u2 := newUpgrader(t)
+	tpt2, err := New(u2, &network.NullResourceManager{}, nil)
+	require.NoError(t, err)
+	c, err := tpt2.Dial(context.Background(), laddr, serverID)
+	require.NoError(t, err)
+	defer c.Close()
+	str, err := c.OpenStream(context.Background())
+	require.NoError(t, err)
+	_, err = str.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, str.CloseWrite())
+	b, err := io.ReadAll(str)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(b))
+
+	// The other handlers on the mux keep working.
+	resp, err = http.Get("http://" + nl.Addr().String() + "/api/")
+	require.NoError(t, err)
+	b, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, "api", string(b))
+
+	// Once closed, we can listen again.
+	require.NoError(t, l.Close())
+	l, err = tpt.Listen(laddr)
+	require.NoError(t, err)
+	l.Close()
+}
+
 func TestConcurrentClose(t *testing.T) {
 	_, u := newUpgrader(t)
 	tpt, err := New(u, &network.NullResourceManager{}, nil)
@@ -549,13 +905,14 @@ func TestWriteZero(t *testing.T) {
 func TestResolveMultiaddr(t *testing.T) {
 	// map[unresolved]resolved
 	testCases := map[string]string{
-		"/dns/example.com/tcp/1234/wss":        "/dns/example.com/tcp/1234/tls/sni/example.com/ws",
-		"/dns4/example.com/tcp/1234/wss":       "/dns4/example.com/tcp/1234/tls/sni/example.com/ws",
-		"/dns6/example.com/tcp/1234/wss":       "/dns6/example.com/tcp/1234/tls/sni/example.com/ws",
-		"/dnsaddr/example.com/tcp/1234/wss":    "/dnsaddr/example.com/tcp/1234/wss",
-		"/dns4/example.com/tcp/1234/tls/ws":    "/dns4/example.com/tcp/1234/tls/sni/example.com/ws",
-		"/dns6/example.com/tcp/1234/tls/ws":    "/dns6/example.com/tcp/1234/tls/sni/example.com/ws",
-		"/dnsaddr/example.com/tcp/1234/tls/ws": "/dnsaddr/example.com/tcp/1234/tls/ws",
+		"/dns/example.com/tcp/1234/wss":                "/dns/example.com/tcp/1234/tls/sni/example.com/ws",
+		"/dns4/example.com/tcp/1234/wss":               "/dns4/example.com/tcp/1234/tls/sni/example.com/ws",
+		"/dns6/example.com/tcp/1234/wss":               "/dns6/example.com/tcp/1234/tls/sni/example.com/ws",
+		"/dnsaddr/example.com/tcp/1234/wss":            "/dnsaddr/example.com/tcp/1234/wss",
+		"/dns4/example.com/tcp/1234/tls/ws":            "/dns4/example.com/tcp/1234/tls/sni/example.com/ws",
+		"/dns6/example.com/tcp/1234/tls/ws":            "/dns6/example.com/tcp/1234/tls/sni/example.com/ws",
+		"/dnsaddr/example.com/tcp/1234/tls/ws":         "/dnsaddr/example.com/tcp/1234/tls/ws",
+		"/dns4/example.com/tcp/1234/wss/http-path/foo": "/dns4/example.com/tcp/1234/tls/sni/example.com/ws/http-path/foo",
 	}
 
 	for unresolved, expectedMA := range testCases {
@@ -618,16 +975,12 @@ func TestSocksProxy(t *testing.T) {
 				proxyServerErr <- nil
 			}()
 
-			orig := gws.DefaultDialer.Proxy
-			defer func() { gws.DefaultDialer.Proxy = orig }()
-
 			proxyUrl, err := url.Parse("socks5://" + proxyServer.Addr().String())
 			require.NoError(t, err)
-			gws.DefaultDialer.Proxy = http.ProxyURL(proxyUrl)
 
 			tlsConfig := &tls.Config{InsecureSkipVerify: true} // Our test server doesn't have a cert signed by a CA
 			_, u := newSecureUpgrader(t)
-			tpt, err := New(u, &network.NullResourceManager{}, nil, WithTLSClientConfig(tlsConfig))
+			tpt, err := New(u, &network.NullResourceManager{}, nil, WithTLSClientConfig(tlsConfig), WithProxy(http.ProxyURL(proxyUrl)))
 			require.NoError(t, err)
 
 			// This can be any wss address. We aren't actually going to dial it.
@@ -646,6 +999,77 @@ func TestSocksProxy(t *testing.T) {
 	}
 }
 
+func TestProxyPerTransport(t *testing.T) {
+	// A host that can't be reached directly.
+	maToDial := ma.StringCast("/ip4/1.2.3.4/tcp/1/ws")
+
+	newProxy := func() (*url.URL, chan string) {
+		reqs := make(chan string, 1)
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqs <- r.Host
+			w.WriteHeader(http.StatusForbidden)
+		}))
+		t.Cleanup(proxy.Close)
+		u, err := url.Parse(proxy.URL)
+		require.NoError(t, err)
+		return u, reqs
+	}
+	proxy1, reqs1 := newProxy()
+	proxy2, reqs2 := newProxy()
+
+	_, u := newUpgrader(t)
+	tpt1, err := New(u, &network.NullResourceManager{}, nil, WithProxy(http.ProxyURL(proxy1)))
+	require.NoError(t, err)
+	tpt2, err := New(u, &network.NullResourceManager{}, nil, WithProxy(http.ProxyURL(proxy2)))
+	require.NoError(t, err)
+
+	_, err = tpt1.Dial(context.Background(), maToDial, "")
+	require.Error(t, err)
+	require.Equal(t, "1.2.3.4:1", <-reqs1)
+	_, err = tpt2.Dial(context.Background(), maToDial, "")
+	require.Error(t, err)
+	require.Equal(t, "1.2.3.4:1", <-reqs2)
+	require.Empty(t, reqs1)
+}
+
+func TestProxyFromEnvironment(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(k string) string { return vars[k] }
+	}
+	proxyFor := func(t *testing.T, f func(*url.URL) (*url.URL, error), target string) string {
+		t.Helper()
+		u, err := f(&url.URL{Scheme: target, Host: "example.com:443"})
+		require.NoError(t, err)
+		if u == nil {
+			return ""
+		}
+		return u.String()
+	}
+
+	f := proxyFuncFromEnv(env(map[string]string{
+		"HTTPS_PROXY": "http://secure-proxy:8080",
+		"http_proxy":  "http://plain-proxy:8080",
+	}))
+	require.Equal(t, "http://secure-proxy:8080", proxyFor(t, f, "https"))
+	require.Equal(t, "http://plain-proxy:8080", proxyFor(t, f, "http"))
+
+	f = proxyFuncFromEnv(env(map[string]string{
+		"ALL_PROXY":   "socks5://all-proxy:1080",
+		"HTTPS_PROXY": "http://secure-proxy:8080",
+	}))
+	require.Equal(t, "http://secure-proxy:8080", proxyFor(t, f, "https"))
+	require.Equal(t, "socks5://all-proxy:1080", proxyFor(t, f, "http"))
+
+	f = proxyFuncFromEnv(env(map[string]string{
+		"all_proxy": "socks5://all-proxy:1080",
+		"NO_PROXY":  "example.com",
+	}))
+	require.Empty(t, proxyFor(t, f, "https"))
+
+	f = proxyFuncFromEnv(env(nil))
+	require.Empty(t, proxyFor(t, f, "https"))
+}
+
 func TestListenerAddr(t *testing.T) {
 	_, upgrader := newUpgrader(t)
 	transport, err := New(upgrader, &network.NullResourceManager{}, nil, WithTLSConfig(generateTLSConfig(t)))
@@ -740,3 +1164,81 @@ func TestHandshakeTimeout(t *testing.T) {
 		}
 	})
 }
+
+// certGater records the TLS client certificates of secured connections.
+type certGater struct {
+	certs chan []*x509.Certificate
+}
+
+var _ connmgr.SecuredConnGater = &certGater{}
+
+func (g *certGater) InterceptPeerDial(peer.ID) bool                                  { return true }
+func (g *certGater) InterceptAddrDial(peer.ID, ma.Multiaddr) bool                    { return true }
+func (g *certGater) InterceptAccept(network.ConnMultiaddrs) bool                     { return true }
+func (g *certGater) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) { return true, 0 }
+func (g *certGater) InterceptSecured(network.Direction, peer.ID, network.ConnMultiaddrs) bool {
+	return true
+}
+
+func (g *certGater) InterceptSecuredConn(_ network.Direction, _ peer.ID, info connmgr.SecuredConnInfo) bool {
+	g.certs <- info.TLSClientCertificates
+	return true
+}
+
+func TestClientCertificates(t *testing.T) {
+	gater := &certGater{certs: make(chan []*x509.Certificate, 1)}
+	serverID, m := newInsecureMuxer(t)
+	us, err := tptu.New(m, []tptu.StreamMuxer{{ID: "/yamux", Muxer: yamux.DefaultTransport}}, nil, nil, gater)
+	require.NoError(t, err)
+	server, err := New(us, nil, nil, WithTLSConfig(generateTLSConfig(t)), WithClientCertificates(tls.RequireAnyClientCert, nil))
+	require.NoError(t, err)
+	l, err := server.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0/tls/ws"))
+	require.NoError(t, err)
+	defer l.Close()
+
+	t.Run("with certificate", func(t *testing.T) {
+		clientConf := generateTLSConfig(t)
+		clientConf.InsecureSkipVerify = true
+		_, uc := newUpgrader(t)
+		client, err := New(uc, nil, nil, WithTLSClientConfig(clientConf))
+		require.NoError(t, err)
+
+		accepted := make(chan transport.CapableConn, 1)
+		go func() {
+			c, err := l.Accept()
+			if assert.NoError(t, err) {
+				accepted <- c
+			}
+		}()
+		c, err := client.Dial(context.Background(), l.Multiaddr(), serverID)
+		require.NoError(t, err)
+		defer c.Close()
+		sc := <-accepted
+		defer sc.Close()
+
+		clientCert := clientConf.Certificates[0].Certificate[0]
+		certs := <-gater.certs
+		require.Len(t, certs, 1)
+		require.Equal(t, clientCert, certs[0].Raw)
+		require.Len(t, sc.ConnState().TLSClientCertificates, 1)
+		require.Equal(t, clientCert, sc.ConnState().TLSClientCertificates[0].Raw)
+		require.Equal(t, "websocket", sc.ConnState().Transport)
+		require.Empty(t, c.ConnState().TLSClientCertificates)
+	})
+
+	t.Run("without certificate", func(t *testing.T) {
+		_, uc := newUpgrader(t)
+		client, err := New(uc, nil, nil, WithTLSClientConfig(&tls.Config{InsecureSkipVerify: true}))
+		require.NoError(t, err)
+		_, err = client.Dial(context.Background(), l.Multiaddr(), serverID)
+		require.Error(t, err)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		_, u := newUpgrader(t)
+		_, err := New(u, nil, nil, WithClientCertificates(tls.NoClientCert, nil))
+		require.Error(t, err)
+		_, err = New(u, nil, nil, WithClientCertificates(tls.RequireAndVerifyClientCert, nil))
+		require.Error(t, err)
+	})
+}
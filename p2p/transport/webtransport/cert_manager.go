@@ -1,26 +1,18 @@
 package libp2pwebtransport
 
 import (
-	"context"
-	"crypto/sha256"
 	"crypto/tls"
-	"encoding/binary"
-	"fmt"
 	"sync"
 	"time"
 
-	"github.com/benbjohnson/clock"
 	ic "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/p2p/transport/certhash"
+
+	"github.com/benbjohnson/clock"
 	ma "github.com/multiformats/go-multiaddr"
-	"github.com/multiformats/go-multihash"
+	"github.com/quic-go/quic-go/http3"
 )
 
-// Allow for a bit of clock skew.
-// When we generate a certificate, the NotBefore time is set to clockSkewAllowance before the current time.
-// Similarly, we stop using a certificate one clockSkewAllowance before its expiry time.
-const clockSkewAllowance = time.Hour
-const validityMinusTwoSkew = certValidity - (2 * clockSkewAllowance)
-
 type certConfig struct {
 	tlsConf *tls.Config
 	sha256  [32]byte // cached from the tlsConf
@@ -29,128 +21,80 @@ type certConfig struct {
 func (c *certConfig) Start() time.Time { return c.tlsConf.Certificates[0].Leaf.NotBefore }
 func (c *certConfig) End() time.Time   { return c.tlsConf.Certificates[0].Leaf.NotAfter }
 
-func newCertConfig(key ic.PrivKey, start, end time.Time) (*certConfig, error) {
-	conf, err := getTLSConf(key, start, end)
-	if err != nil {
-		return nil, err
-	}
+func newCertConfig(cert *certhash.Certificate) *certConfig {
 	return &certConfig{
-		tlsConf: conf,
-		sha256:  sha256.Sum256(conf.Certificates[0].Leaf.Raw),
-	}, nil
+		tlsConf: &tls.Config{
+			Certificates: []tls.Certificate{{
+				Certificate: [][]byte{cert.Leaf.Raw},
+				PrivateKey:  cert.PrivateKey,
+				Leaf:        cert.Leaf,
+			}},
+			NextProtos: []string{http3.NextProtoH3},
+		},
+		sha256: cert.Hash,
+	}
 }
 
-// Certificate renewal logic:
-//  1. On startup, we generate one cert that is valid from now (-1h, to allow for clock skew), and another
-//     cert that is valid from the expiry date of the first certificate (again, with allowance for clock skew).
-//  2. Once we reach 1h before expiry of the first certificate, we switch over to the second certificate.
-//     At the same time, we stop advertising the certhash of the first cert and generate the next cert.
+// certManager caches the TLS config and the certhashes derived from the
+// certificates of a certhash.Manager, and updates them when the certificates
+// are rotated.
 type certManager struct {
-	clock     clock.Clock
-	ctx       context.Context
-	ctxCancel context.CancelFunc
-	refCount  sync.WaitGroup
+	m           *certhash.Manager
+	ownsManager bool // whether the certhash.Manager should be closed with the certManager
+	unsubscribe func()
 
 	mx            sync.RWMutex
-	lastConfig    *certConfig // initially nil
 	currentConfig *certConfig
-	nextConfig    *certConfig // nil until we have passed half the certValidity of the current config
 	addrComp      ma.Multiaddr
 
 	serializedCertHashes [][]byte
 }
 
 func newCertManager(hostKey ic.PrivKey, clock clock.Clock) (*certManager, error) {
-	m := &certManager{clock: clock}
-	m.ctx, m.ctxCancel = context.WithCancel(context.Background())
-	if err := m.init(hostKey); err != nil {
+	m, err := certhash.NewManager(hostKey, certhash.WithClock(clock))
+	if err != nil {
 		return nil, err
 	}
-
-	m.background(hostKey)
-	return m, nil
-}
-
-// getCurrentBucketStartTime returns the canonical start time of the given time as
-// bucketed by ranges of certValidity since unix epoch (plus an offset). This
-// lets you get the same time ranges across reboots without having to persist
-// state.
-// ```
-// ... v--- epoch + offset
-// ... |--------|    |--------|        ...
-// ...        |--------|    |--------| ...
-// ```
-func getCurrentBucketStartTime(now time.Time, offset time.Duration) time.Time {
-	currentBucket := (now.UnixMilli() - offset.Milliseconds()) / validityMinusTwoSkew.Milliseconds()
-	return time.UnixMilli(offset.Milliseconds() + currentBucket*validityMinusTwoSkew.Milliseconds())
-}
-
-func (m *certManager) init(hostKey ic.PrivKey) error {
-	start := m.clock.Now()
-	pubkeyBytes, err := hostKey.GetPublic().Raw()
+	cm, err := newCertManagerFrom(m)
 	if err != nil {
-		return err
+		m.Close()
+		return nil, err
 	}
+	cm.ownsManager = true
+	return cm, nil
+}
 
-	// We want to add a random offset to each start time so that not all certs
-	// rotate at the same time across the network. The offset represents moving
-	// the bucket start time some `offset` earlier.
-	offset := (time.Duration(binary.LittleEndian.Uint16(pubkeyBytes)) * time.Minute) % certValidity
-
-	// We want the certificate have been valid for at least one clockSkewAllowance
-	start = start.Add(-clockSkewAllowance)
-	startTime := getCurrentBucketStartTime(start, offset)
-	m.nextConfig, err = newCertConfig(hostKey, startTime, startTime.Add(certValidity))
-	if err != nil {
-		return err
+func newCertManagerFrom(m *certhash.Manager) (*certManager, error) {
+	cm := &certManager{m: m}
+	if err := cm.update(); err != nil {
+		return nil, err
 	}
-	return m.rollConfig(hostKey)
+	cm.unsubscribe = m.Subscribe(func() {
+		if err := cm.update(); err != nil {
+			log.Errorw("failed to update certificates", "error", err)
+		}
+	})
+	return cm, nil
 }
 
-func (m *certManager) rollConfig(hostKey ic.PrivKey) error {
-	// We stop using the current certificate clockSkewAllowance before its expiry time.
-	// At this point, the next certificate needs to be valid for one clockSkewAllowance.
-	nextStart := m.nextConfig.End().Add(-2 * clockSkewAllowance)
-	c, err := newCertConfig(hostKey, nextStart, nextStart.Add(certValidity))
+func (m *certManager) update() error {
+	last, current, next := m.m.Certificates()
+	hashes, err := serializeCertHashes(last, current, next)
 	if err != nil {
 		return err
 	}
-	m.lastConfig = m.currentConfig
-	m.currentConfig = m.nextConfig
-	m.nextConfig = c
-	if err := m.cacheSerializedCertHashes(); err != nil {
+	addrComp, err := addrComponentForCerts(current, next)
+	if err != nil {
 		return err
 	}
-	return m.cacheAddrComponent()
-}
-
-func (m *certManager) background(hostKey ic.PrivKey) {
-	d := m.currentConfig.End().Add(-clockSkewAllowance).Sub(m.clock.Now())
-	log.Debugw("setting timer", "duration", d.String())
-	t := m.clock.Timer(d)
-	m.refCount.Add(1)
-
-	go func() {
-		defer m.refCount.Done()
-		defer t.Stop()
-
-		for {
-			select {
-			case <-m.ctx.Done():
-				return
-			case <-t.C:
-				now := m.clock.Now()
-				m.mx.Lock()
-				if err := m.rollConfig(hostKey); err != nil {
-					log.Errorw("rolling config failed", "error", err)
-				}
-				d := m.currentConfig.End().Add(-clockSkewAllowance).Sub(now)
-				log.Debugw("rolling certificates", "next", d.String())
-				t.Reset(d)
-				m.mx.Unlock()
-			}
-		}
-	}()
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.currentConfig == nil || m.currentConfig.sha256 != current.Hash {
+		m.currentConfig = newCertConfig(current)
+	}
+	m.serializedCertHashes = hashes
+	m.addrComp = addrComp
+	return nil
 }
 
 func (m *certManager) GetConfig() *tls.Config {
@@ -166,50 +110,45 @@ func (m *certManager) AddrComponent() ma.Multiaddr {
 }
 
 func (m *certManager) SerializedCertHashes() [][]byte {
+	m.mx.RLock()
+	defer m.mx.RUnlock()
 	return m.serializedCertHashes
 }
 
-func (m *certManager) cacheSerializedCertHashes() error {
-	hashes := make([][32]byte, 0, 3)
-	if m.lastConfig != nil {
-		hashes = append(hashes, m.lastConfig.sha256)
-	}
-	hashes = append(hashes, m.currentConfig.sha256)
-	if m.nextConfig != nil {
-		hashes = append(hashes, m.nextConfig.sha256)
-	}
-
-	m.serializedCertHashes = m.serializedCertHashes[:0]
-	for _, certHash := range hashes {
-		h, err := multihash.Encode(certHash[:], multihash.SHA2_256)
+func serializeCertHashes(certs ...*certhash.Certificate) ([][]byte, error) {
+	hashes := make([][]byte, 0, len(certs))
+	for _, c := range certs {
+		if c == nil {
+			continue
+		}
+		h, err := c.Multihash()
 		if err != nil {
-			return fmt.Errorf("failed to encode certificate hash: %w", err)
+			return nil, err
 		}
-		m.serializedCertHashes = append(m.serializedCertHashes, h)
+		hashes = append(hashes, h)
 	}
-	return nil
+	return hashes, nil
 }
 
-func (m *certManager) cacheAddrComponent() error {
+func addrComponentForCerts(certs ...*certhash.Certificate) (ma.Multiaddr, error) {
 	var addr ma.Multiaddr
-	c, err := addrComponentForCert(m.currentConfig.sha256[:])
-	if err != nil {
-		return err
-	}
-	addr = addr.AppendComponent(c)
-	if m.nextConfig != nil {
-		comp, err := addrComponentForCert(m.nextConfig.sha256[:])
+	for _, c := range certs {
+		if c == nil {
+			continue
+		}
+		comp, err := addrComponentForCert(c.Hash[:])
 		if err != nil {
-			return err
+			return nil, err
 		}
 		addr = addr.AppendComponent(comp)
 	}
-	m.addrComp = addr
-	return nil
+	return addr, nil
 }
 
 func (m *certManager) Close() error {
-	m.ctxCancel()
-	m.refCount.Wait()
+	m.unsubscribe()
+	if m.ownsManager {
+		return m.m.Close()
+	}
 	return nil
 }
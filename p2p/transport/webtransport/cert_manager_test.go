@@ -5,11 +5,11 @@ import (
 	"crypto/tls"
 	"fmt"
 	"testing"
-	"testing/quick"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/test"
+	"github.com/libp2p/go-libp2p/p2p/transport/certhash"
 
 	"github.com/benbjohnson/clock"
 	ma "github.com/multiformats/go-multiaddr"
@@ -53,8 +53,8 @@ func TestInitialCert(t *testing.T) {
 	conf := m.GetConfig()
 	require.Len(t, conf.Certificates, 1)
 	cert := conf.Certificates[0]
-	require.GreaterOrEqual(t, cl.Now().Add(-clockSkewAllowance), cert.Leaf.NotBefore)
-	require.Equal(t, cert.Leaf.NotBefore.Add(certValidity), cert.Leaf.NotAfter)
+	require.GreaterOrEqual(t, cl.Now().Add(-certhash.ClockSkewAllowance), cert.Leaf.NotBefore)
+	require.Equal(t, cert.Leaf.NotBefore.Add(certhash.Validity), cert.Leaf.NotAfter)
 	addr := m.AddrComponent()
 	components := splitMultiaddr(addr)
 	require.Len(t, components, 2)
@@ -79,7 +79,7 @@ func TestCertRenewal(t *testing.T) {
 	require.Len(t, first, 2)
 	require.NotEqual(t, first[0].Value(), first[1].Value(), "the hashes should differ")
 	// wait for a new certificate to be generated
-	cl.Set(m.currentConfig.End().Add(-(clockSkewAllowance + time.Second)))
+	cl.Set(m.currentConfig.End().Add(-(certhash.ClockSkewAllowance + time.Second)))
 	require.Never(t, func() bool {
 		for i, c := range splitMultiaddr(m.AddrComponent()) {
 			if c.Value() != first[i].Value() {
@@ -101,7 +101,7 @@ func TestCertRenewal(t *testing.T) {
 	require.Equal(t, first[1].Value(), second[0].Value())
 	require.NotEqual(t, first[0].Value(), second[1].Value())
 
-	cl.Add(certValidity - 2*clockSkewAllowance + time.Second)
+	cl.Add(certhash.Validity - 2*certhash.ClockSkewAllowance + time.Second)
 	require.Eventually(t, func() bool { return m.GetConfig() != secondConf }, 200*time.Millisecond, 10*time.Millisecond)
 	third := splitMultiaddr(m.AddrComponent())
 	require.Len(t, third, 2)
@@ -142,36 +142,3 @@ func TestDeterministicCertsAcrossReboots(t *testing.T) {
 		})
 	}
 }
-
-func TestDeterministicTimeBuckets(t *testing.T) {
-	cl := clock.NewMock()
-	cl.Add(time.Hour * 24 * 365)
-	startA := getCurrentBucketStartTime(cl.Now(), 0)
-	startB := getCurrentBucketStartTime(cl.Now().Add(time.Hour*24), 0)
-	require.Equal(t, startA, startB)
-
-	// 15 Days later
-	startC := getCurrentBucketStartTime(cl.Now().Add(time.Hour*24*15), 0)
-	require.NotEqual(t, startC, startB)
-}
-
-func TestGetCurrentBucketStartTimeIsWithinBounds(t *testing.T) {
-	require.NoError(t, quick.Check(func(timeSinceUnixEpoch time.Duration, offset time.Duration) bool {
-		if offset < 0 {
-			offset = -offset
-		}
-		if timeSinceUnixEpoch < 0 {
-			timeSinceUnixEpoch = -timeSinceUnixEpoch
-		}
-
-		offset = offset % certValidity
-		// Bound this to 100 years
-		timeSinceUnixEpoch = timeSinceUnixEpoch % (time.Hour * 24 * 365 * 100)
-		// Start a bit further in the future to avoid edge cases around epoch
-		timeSinceUnixEpoch += time.Hour * 24 * 365
-		start := time.UnixMilli(timeSinceUnixEpoch.Milliseconds())
-
-		bucketStart := getCurrentBucketStartTime(start.Add(-clockSkewAllowance), offset)
-		return !bucketStart.After(start.Add(-clockSkewAllowance)) || bucketStart.Equal(start.Add(-clockSkewAllowance))
-	}, nil))
-}
@@ -3,6 +3,7 @@ package libp2pwebtransport
 import (
 	"context"
 
+	"github.com/libp2p/go-libp2p/core/connmgr"
 	"github.com/libp2p/go-libp2p/core/network"
 	tpt "github.com/libp2p/go-libp2p/core/transport"
 
@@ -86,6 +87,10 @@ func (c *conn) IsClosed() bool           { return c.session.Context().Err() != n
 func (c *conn) Scope() network.ConnScope { return c.scope }
 func (c *conn) Transport() tpt.Transport { return c.transport }
 
+func securedConnInfo(sconn *connSecurityMultiaddrs) connmgr.SecuredConnInfo {
+	return connmgr.SecuredConnInfo{ConnMultiaddrs: sconn, Transport: "webtransport"}
+}
+
 func (c *conn) ConnState() network.ConnectionState {
 	return network.ConnectionState{Transport: "webtransport"}
 }
@@ -9,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/libp2p/go-libp2p/core/connmgr"
 	"github.com/libp2p/go-libp2p/core/network"
 	tpt "github.com/libp2p/go-libp2p/core/transport"
 	"github.com/libp2p/go-libp2p/p2p/security/noise"
@@ -207,10 +208,12 @@ func (l *listener) httpHandlerWithConnScope(w http.ResponseWriter, r *http.Reque
 	}
 	cancel()
 
-	if l.transport.gater != nil && !l.transport.gater.InterceptSecured(network.DirInbound, sconn.RemotePeer(), sconn) {
-		// TODO: can we close with a specific error here?
-		sess.CloseWithError(errorCodeConnectionGating, "")
-		return errors.New("gater blocked connection")
+	if l.transport.gater != nil {
+		if err := connmgr.InterceptSecuredContext(r.Context(), l.transport.gater, network.DirInbound, sconn.RemotePeer(), securedConnInfo(sconn)); err != nil {
+			// TODO: can we close with a specific error here?
+			sess.CloseWithError(errorCodeConnectionGating, "")
+			return err
+		}
 	}
 
 	if err := connScope.SetPeer(sconn.RemotePeer()); err != nil {
@@ -34,34 +34,26 @@ type stream struct {
 
 var _ network.MuxedStream = stream{}
 
-func (s stream) Read(b []byte) (n int, err error) {
-	n, err = s.Stream.Read(b)
-	if err != nil {
-		var streamErr *webtransport.StreamError
-		if errors.As(err, &streamErr) {
-			err = &network.StreamError{
-				ErrorCode:      0,
-				Remote:         streamErr.Remote,
-				TransportError: err,
-			}
+func parseStreamError(err error) error {
+	var streamErr *webtransport.StreamError
+	if errors.As(err, &streamErr) {
+		err = &network.StreamError{
+			ErrorCode:      network.StreamErrorCode(streamErr.ErrorCode),
+			Remote:         streamErr.Remote,
+			TransportError: err,
 		}
 	}
-	return n, err
+	return err
+}
+
+func (s stream) Read(b []byte) (n int, err error) {
+	n, err = s.Stream.Read(b)
+	return n, parseStreamError(err)
 }
 
 func (s stream) Write(b []byte) (n int, err error) {
 	n, err = s.Stream.Write(b)
-	if err != nil {
-		var streamErr *webtransport.StreamError
-		if errors.As(err, &streamErr) {
-			err = &network.StreamError{
-				ErrorCode:      0,
-				Remote:         streamErr.Remote,
-				TransportError: err,
-			}
-		}
-	}
-	return n, err
+	return n, parseStreamError(err)
 }
 
 func (s stream) Reset() error {
@@ -70,14 +62,15 @@ func (s stream) Reset() error {
 	return nil
 }
 
-// ResetWithError resets the stream ignoring the error code. Error codes aren't
-// specified for WebTransport as the current implementation of WebTransport in
-// browsers(https://www.ietf.org/archive/id/draft-kinnear-webtransport-http2-02.html)
-// only supports 1 byte error codes. For more details, see
+// ResetWithError resets the stream with errCode. Note that the WebTransport
+// implementations of some browsers
+// (https://www.ietf.org/archive/id/draft-kinnear-webtransport-http2-02.html)
+// only support 1 byte error codes, so codes above 0xFF may not reach browser
+// peers. For more details, see
 // https://github.com/libp2p/specs/blob/4eca305185c7aef219e936bef76c48b1ab0a8b43/error-codes/README.md?plain=1#L84
-func (s stream) ResetWithError(_ network.StreamErrorCode) error {
-	s.Stream.CancelRead(reset)
-	s.Stream.CancelWrite(reset)
+func (s stream) ResetWithError(errCode network.StreamErrorCode) error {
+	s.Stream.CancelRead(webtransport.StreamErrorCode(errCode))
+	s.Stream.CancelWrite(webtransport.StreamErrorCode(errCode))
 	return nil
 }
 
@@ -20,6 +20,7 @@ import (
 	tpt "github.com/libp2p/go-libp2p/core/transport"
 	"github.com/libp2p/go-libp2p/p2p/security/noise"
 	"github.com/libp2p/go-libp2p/p2p/security/noise/pb"
+	"github.com/libp2p/go-libp2p/p2p/transport/certhash"
 	"github.com/libp2p/go-libp2p/p2p/transport/quicreuse"
 
 	"github.com/benbjohnson/clock"
@@ -38,8 +39,6 @@ const webtransportHTTPEndpoint = "/.well-known/libp2p-webtransport"
 
 const errorCodeConnectionGating = 0x47415445 // GATE in ASCII
 
-const certValidity = 14 * 24 * time.Hour
-
 type Option func(*transport) error
 
 func WithClock(cl clock.Clock) Option {
@@ -49,6 +48,31 @@ func WithClock(cl clock.Clock) Option {
 	}
 }
 
+// WithCertManager makes the transport use the certificates of m, instead of
+// managing its own. This allows sharing certificates with other transports,
+// e.g. WebRTC Direct, so that they advertise the same certificate hashes.
+// The transport doesn't close m.
+func WithCertManager(m *certhash.Manager) Option {
+	return func(t *transport) error {
+		t.sharedCertManager = m
+		return nil
+	}
+}
+
+// SetCertManager makes the transport use the certificates of m, unless it
+// already uses a certhash.Manager set with WithCertManager. It must be called
+// before the transport starts listening. libp2p.New uses it to share
+// certificates between transports when the ShareCertHashes option is set.
+func (t *transport) SetCertManager(m *certhash.Manager) error {
+	if t.hasCertManager.Load() {
+		return errors.New("cannot set the cert manager after listening")
+	}
+	if t.sharedCertManager == nil {
+		t.sharedCertManager = m
+	}
+	return nil
+}
+
 // WithTLSClientConfig sets a custom tls.Config used for dialing.
 // This option is most useful for setting a custom tls.Config.RootCAs certificate pool.
 // When dialing a multiaddr that contains a /certhash component, this library will set InsecureSkipVerify and
@@ -67,6 +91,23 @@ func WithHandshakeTimeout(d time.Duration) Option {
 	}
 }
 
+// WithQUICClientConfig sets the quic.Config used for dialing WebTransport
+// connections. This allows WebTransport dials to use different settings, e.g.
+// idle timeouts, than QUIC dials sharing the same ConnManager. WebTransport
+// requires datagram support, so c must enable datagrams.
+func WithQUICClientConfig(c *quic.Config) Option {
+	return func(t *transport) error {
+		if c == nil {
+			return errors.New("quic client config is nil")
+		}
+		if !c.EnableDatagrams {
+			return errors.New("WebTransport requires QUIC datagrams to be enabled")
+		}
+		t.quicClientConf = c
+		return nil
+	}
+}
+
 type transport struct {
 	privKey ic.PrivKey
 	pid     peer.ID
@@ -76,12 +117,14 @@ type transport struct {
 	rcmgr       network.ResourceManager
 	gater       connmgr.ConnectionGater
 
-	listenOnce     sync.Once
-	listenOnceErr  error
-	certManager    *certManager
-	hasCertManager atomic.Bool // set to true once the certManager is initialized
-	staticTLSConf  *tls.Config
-	tlsClientConf  *tls.Config
+	listenOnce        sync.Once
+	listenOnceErr     error
+	sharedCertManager *certhash.Manager
+	certManager       *certManager
+	hasCertManager    atomic.Bool // set to true once the certManager is initialized
+	staticTLSConf     *tls.Config
+	tlsClientConf     *tls.Config
+	quicClientConf    *quic.Config
 
 	noise *noise.Transport
 
@@ -121,6 +164,11 @@ func New(key ic.PrivKey, psk pnet.PSK, connManager *quicreuse.ConnManager, gater
 			return nil, err
 		}
 	}
+	if t.quicClientConf != nil {
+		if err := connManager.SetClientConfig(t, t.quicClientConf); err != nil {
+			return nil, err
+		}
+	}
 	n, err := noise.New(noise.ID, key, nil)
 	if err != nil {
 		return nil, err
@@ -178,10 +226,12 @@ func (t *transport) dialWithScope(ctx context.Context, raddr ma.Multiaddr, p pee
 		qconn.CloseWithError(1, "")
 		return nil, err
 	}
-	if t.gater != nil && !t.gater.InterceptSecured(network.DirOutbound, p, sconn) {
-		sess.CloseWithError(errorCodeConnectionGating, "")
-		qconn.CloseWithError(errorCodeConnectionGating, "")
-		return nil, fmt.Errorf("secured connection gated")
+	if t.gater != nil {
+		if err := connmgr.InterceptSecuredContext(ctx, t.gater, network.DirOutbound, p, securedConnInfo(sconn)); err != nil {
+			sess.CloseWithError(errorCodeConnectionGating, "")
+			qconn.CloseWithError(errorCodeConnectionGating, "")
+			return nil, err
+		}
 	}
 	conn := newConn(t, sess, sconn, scope, qconn)
 	t.addConn(qconn, conn)
@@ -218,7 +268,7 @@ func (t *transport) dial(ctx context.Context, addr ma.Multiaddr, url, sni string
 		DialAddr: func(_ context.Context, _ string, _ *tls.Config, _ *quic.Config) (*quic.Conn, error) {
 			return conn, nil
 		},
-		QUICConfig: t.connManager.ClientConfig().Clone(),
+		QUICConfig: t.connManager.ClientConfigForAssociation(t).Clone(),
 	}
 	rsp, sess, err := dialer.Dial(ctx, url, nil)
 	if err != nil {
@@ -317,8 +367,12 @@ func (t *transport) Listen(laddr ma.Multiaddr) (tpt.Listener, error) {
 	}
 	if t.staticTLSConf == nil {
 		t.listenOnce.Do(func() {
-			t.certManager, t.listenOnceErr = newCertManager(t.privKey, t.clock)
-			t.hasCertManager.Store(true)
+			if t.sharedCertManager != nil {
+				t.certManager, t.listenOnceErr = newCertManagerFrom(t.sharedCertManager)
+			} else {
+				t.certManager, t.listenOnceErr = newCertManager(t.privKey, t.clock)
+			}
+			t.hasCertManager.Store(t.listenOnceErr == nil)
 		})
 		if t.listenOnceErr != nil {
 			return nil, t.listenOnceErr
@@ -351,6 +405,9 @@ func (t *transport) Proxy() bool {
 
 func (t *transport) Close() error {
 	t.listenOnce.Do(func() {})
+	if t.quicClientConf != nil {
+		t.connManager.SetClientConfig(t, nil)
+	}
 	if t.certManager != nil {
 		return t.certManager.Close()
 	}
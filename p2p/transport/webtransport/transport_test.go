@@ -19,6 +19,7 @@ import (
 	"testing/quick"
 	"time"
 
+	"github.com/libp2p/go-libp2p/core/connmgr"
 	ic "github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/network"
 	mocknetwork "github.com/libp2p/go-libp2p/core/network/mocks"
@@ -388,7 +389,9 @@ func TestConnectionGaterDialing(t *testing.T) {
 	require.NoError(t, err)
 	defer cl.(io.Closer).Close()
 	_, err = cl.Dial(context.Background(), ln.Multiaddr(), serverID)
-	require.EqualError(t, err, "secured connection gated")
+	var gerr *connmgr.GatedError
+	require.ErrorAs(t, err, &gerr)
+	require.Equal(t, serverID, gerr.Peer)
 }
 
 func TestConnectionGaterInterceptAccept(t *testing.T) {
@@ -869,3 +872,33 @@ func TestH3ConnClosed(t *testing.T) {
 		return false
 	}, 10*time.Second, 1*time.Second)
 }
+
+func TestQUICClientConfig(t *testing.T) {
+	_, clientKey := newIdentity(t)
+	_, err := libp2pwebtransport.New(clientKey, nil, newConnManager(t), nil, nil,
+		libp2pwebtransport.WithQUICClientConfig(&quic.Config{}))
+	require.Error(t, err, "datagrams must be enabled")
+	_, err = libp2pwebtransport.New(clientKey, nil, newConnManager(t), nil, nil,
+		libp2pwebtransport.WithQUICClientConfig(nil))
+	require.Error(t, err)
+
+	serverID, serverKey := newIdentity(t)
+	tr, err := libp2pwebtransport.New(serverKey, nil, newConnManager(t), nil, nil)
+	require.NoError(t, err)
+	defer tr.(io.Closer).Close()
+	ln, err := tr.Listen(ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1/webtransport"))
+	require.NoError(t, err)
+	defer ln.Close()
+
+	cm := newConnManager(t)
+	tr2, err := libp2pwebtransport.New(clientKey, nil, cm, nil, nil,
+		libp2pwebtransport.WithQUICClientConfig(&quic.Config{EnableDatagrams: true, MaxIdleTimeout: 200 * time.Millisecond}))
+	require.NoError(t, err)
+	conn, err := tr2.Dial(context.Background(), ln.Multiaddr(), serverID)
+	require.NoError(t, err)
+	// The connection uses the short idle timeout of the WebTransport client config.
+	require.Eventually(t, conn.IsClosed, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, tr2.(io.Closer).Close())
+	require.Equal(t, cm.ClientConfig(), cm.ClientConfigForAssociation(tr2))
+}
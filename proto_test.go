@@ -16,6 +16,7 @@ import (
 	_ "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/pb"
 	_ "github.com/libp2p/go-libp2p/p2p/protocol/holepunch/pb"
 	_ "github.com/libp2p/go-libp2p/p2p/protocol/identify/pb"
+	_ "github.com/libp2p/go-libp2p/p2p/protocol/peerexchange/pb"
 	_ "github.com/libp2p/go-libp2p/p2p/security/noise/pb"
 	_ "github.com/libp2p/go-libp2p/p2p/transport/webrtc/pb"
 	"google.golang.org/protobuf/reflect/protoreflect"
@@ -6,6 +6,7 @@ root=$1
 proto_array=(
   core/crypto/pb/crypto.proto
   core/record/pb/envelope.proto
+  core/record/pb/key_rotation.proto
   core/peer/pb/peer_record.proto
   core/sec/insecure/pb/plaintext.proto
   p2p/host/autonat/pb/autonat.proto
@@ -16,6 +17,7 @@ proto_array=(
   p2p/protocol/circuitv2/pb/voucher.proto
   p2p/protocol/autonatv2/pb/autonatv2.proto
   p2p/protocol/holepunch/pb/holepunch.proto
+  p2p/protocol/peerexchange/pb/peerexchange.proto
   p2p/host/peerstore/pstoreds/pb/pstore.proto
 )
 